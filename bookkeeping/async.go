@@ -0,0 +1,134 @@
+package bookkeeping
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/provider"
+)
+
+const (
+	// DefaultQueueSize is the capacity of an asynchronous sink's queue when none is configured
+	DefaultQueueSize = 1000
+
+	// DefaultSinkName is the metric label value for an asynchronous sink with no configured name
+	DefaultSinkName = "default"
+)
+
+var (
+	// ErrQueueFull is returned when a record is dropped because an asynchronous sink's queue is full
+	ErrQueueFull = errors.New("The bookkeeping queue is full")
+
+	// ErrSinkClosed is returned when a record is sent to an asynchronous sink that has been closed
+	ErrSinkClosed = errors.New("The bookkeeping sink has been closed")
+)
+
+// AsyncOptions configures an AsyncSink
+type AsyncOptions struct {
+	// Name identifies the sink in metrics.  If unset, DefaultSinkName is used.
+	Name string
+
+	// QueueSize is the maximum number of records waiting to be sent.  If unset, DefaultQueueSize is used.
+	QueueSize int
+
+	// Logger receives errors from the wrapped sink.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger
+
+	// Measures are the metrics for the queue.  If unset, metrics are discarded.
+	Measures *Measures
+}
+
+// AsyncSink decorates another Sink so that records are sent from a separate goroutine.  Records are queued
+// in a bounded buffer, and records that arrive when the buffer is full are dropped rather than blocking.
+type AsyncSink struct {
+	next     Sink
+	queue    chan Record
+	errorLog log.Logger
+	measures Measures
+	name     string
+
+	lock      sync.RWMutex
+	closed    bool
+	waitGroup sync.WaitGroup
+}
+
+// NewAsyncSink wraps a Sink with a bounded queue and starts the goroutine that drains it
+func NewAsyncSink(next Sink, o AsyncOptions) *AsyncSink {
+	as := &AsyncSink{
+		next: next,
+		name: o.Name,
+	}
+
+	if len(as.name) == 0 {
+		as.name = DefaultSinkName
+	}
+
+	queueSize := o.QueueSize
+	if queueSize < 1 {
+		queueSize = DefaultQueueSize
+	}
+
+	as.queue = make(chan Record, queueSize)
+
+	logger := o.Logger
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	as.errorLog = logging.Error(logger, SinkLabel, as.name)
+
+	if o.Measures != nil {
+		as.measures = *o.Measures
+	} else {
+		as.measures = NewMeasures(provider.NewDiscardProvider())
+	}
+
+	as.waitGroup.Add(1)
+	go as.drain()
+	return as
+}
+
+func (as *AsyncSink) drain() {
+	defer as.waitGroup.Done()
+	depth := as.measures.QueueDepth.With(SinkLabel, as.name)
+	for r := range as.queue {
+		depth.Add(-1.0)
+		if err := as.next.Send(r); err != nil {
+			as.errorLog.Log(logging.MessageKey(), "unable to send bookkeeping record", logging.ErrorKey(), err)
+		}
+	}
+}
+
+// Send enqueues a record without blocking.  If the queue is full, the record is dropped and ErrQueueFull is returned.
+func (as *AsyncSink) Send(r Record) error {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+
+	if as.closed {
+		return ErrSinkClosed
+	}
+
+	select {
+	case as.queue <- r:
+		as.measures.QueueDepth.With(SinkLabel, as.name).Add(1.0)
+		return nil
+	default:
+		as.measures.Dropped.With(SinkLabel, as.name).Add(1.0)
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting records and waits for any queued records to be sent.  This method is idempotent.
+func (as *AsyncSink) Close() error {
+	as.lock.Lock()
+	if !as.closed {
+		as.closed = true
+		close(as.queue)
+	}
+
+	as.lock.Unlock()
+	as.waitGroup.Wait()
+	return nil
+}
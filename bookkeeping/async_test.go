@@ -0,0 +1,80 @@
+package bookkeeping
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAsyncSinkDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		sink   = NewAsyncSink(new(mockSink), AsyncOptions{})
+	)
+
+	defer sink.Close()
+	assert.Equal(DefaultSinkName, sink.name)
+	assert.Equal(DefaultQueueSize, cap(sink.queue))
+	assert.NotNil(sink.errorLog)
+	assert.NotNil(sink.measures.Dropped)
+	assert.NotNil(sink.measures.QueueDepth)
+}
+
+func TestAsyncSink(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+		measures = NewMeasures(provider)
+
+		block = make(chan struct{})
+		sent  = make(chan Record, 10)
+		next  = SinkFunc(func(r Record) error {
+			<-block
+			sent <- r
+			if len(r.KeyValues) > 0 {
+				return errors.New("expected")
+			}
+
+			return nil
+		})
+
+		sink = NewAsyncSink(next, AsyncOptions{
+			Name:      "test",
+			QueueSize: 2,
+			Logger:    logging.NewTestLogger(nil, t),
+			Measures:  &measures,
+		})
+	)
+
+	// the first record is dequeued and blocks in the next sink, which allows two more to be queued
+	require.NoError(sink.Send(Record{Timestamp: testTimestamp}))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.queue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	require.NoError(sink.Send(Record{Timestamp: testTimestamp.Add(time.Second), KeyValues: []interface{}{"code", 500}}))
+	require.NoError(sink.Send(Record{Timestamp: testTimestamp.Add(2 * time.Second)}))
+	assert.Equal(ErrQueueFull, sink.Send(Record{Timestamp: testTimestamp.Add(3 * time.Second)}))
+
+	provider.Assert(t, DroppedRecordCounter, SinkLabel, "test")(xmetricstest.Value(1.0))
+	provider.Assert(t, QueueDepthGauge, SinkLabel, "test")(xmetricstest.Value(2.0))
+
+	// closing waits for queued records to be sent
+	close(block)
+	assert.NoError(sink.Close())
+	assert.NoError(sink.Close())
+	require.Len(sent, 3)
+	for i := 0; i < 3; i++ {
+		assert.Equal(testTimestamp.Add(time.Duration(i)*time.Second), (<-sent).Timestamp)
+	}
+
+	provider.Assert(t, QueueDepthGauge, SinkLabel, "test")(xmetricstest.Value(0.0))
+	assert.Equal(ErrSinkClosed, sink.Send(Record{Timestamp: testTimestamp}))
+}
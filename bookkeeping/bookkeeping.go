@@ -0,0 +1,223 @@
+package bookkeeping
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xhttp"
+)
+
+// CapturedResponse holds the attributes of a response written by a decorated handler
+type CapturedResponse struct {
+	// Code is the status code written by the handler
+	Code int
+
+	// Header is the response header, as it stood when the handler returned
+	Header http.Header
+
+	// Duration is how long the handler took to serve the request
+	Duration time.Duration
+}
+
+// RequestFunc extracts key/value pairs from a request
+type RequestFunc func(*http.Request) []interface{}
+
+// ResponseFunc extracts key/value pairs from a captured response
+type ResponseFunc func(CapturedResponse) []interface{}
+
+// Option configures the bookkeeping middleware
+type Option func(*bookkeeper)
+
+// WithRequests adds functions that capture request attributes
+func WithRequests(requestFuncs ...RequestFunc) Option {
+	return func(b *bookkeeper) {
+		b.requestFuncs = append(b.requestFuncs, requestFuncs...)
+	}
+}
+
+// WithResponses adds functions that capture response attributes
+func WithResponses(responseFuncs ...ResponseFunc) Option {
+	return func(b *bookkeeper) {
+		b.responseFuncs = append(b.responseFuncs, responseFuncs...)
+	}
+}
+
+// WithSinks adds the destinations for captured records.  If no sinks are configured, each record is
+// logged synchronously to the request's contextual logger.
+func WithSinks(sinks ...Sink) Option {
+	return func(b *bookkeeper) {
+		b.sinks = append(b.sinks, sinks...)
+	}
+}
+
+// WithNow sets the source of the current time.  By default, time.Now is used.
+func WithNow(now func() time.Time) Option {
+	return func(b *bookkeeper) {
+		if now != nil {
+			b.now = now
+		}
+	}
+}
+
+type bookkeeper struct {
+	requestFuncs  []RequestFunc
+	responseFuncs []ResponseFunc
+	sinks         []Sink
+	now           func() time.Time
+}
+
+// record builds the Record for a request and delivers it to each sink
+func (b *bookkeeper) record(request *http.Request, start time.Time, response CapturedResponse) {
+	r := Record{Timestamp: start}
+	for _, rf := range b.requestFuncs {
+		r.KeyValues = append(r.KeyValues, rf(request)...)
+	}
+
+	for _, rf := range b.responseFuncs {
+		r.KeyValues = append(r.KeyValues, rf(response)...)
+	}
+
+	if len(b.sinks) == 0 {
+		LogSink(logging.Info(logging.GetLogger(request.Context()))).Send(r)
+		return
+	}
+
+	for _, s := range b.sinks {
+		if err := s.Send(r); err != nil {
+			logging.Error(logging.GetLogger(request.Context())).Log(
+				logging.MessageKey(), "unable to send bookkeeping record",
+				logging.ErrorKey(), err,
+			)
+		}
+	}
+}
+
+// New produces an Alice-style decorator that captures attributes of each request and response,
+// delivering them to the configured sinks once the decorated handler returns.
+func New(options ...Option) func(http.Handler) http.Handler {
+	b := &bookkeeper{
+		now: time.Now,
+	}
+
+	for _, o := range options {
+		o(b)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			var (
+				start   = b.now()
+				capture = &captureWriter{ResponseWriter: response, code: http.StatusOK}
+			)
+
+			next.ServeHTTP(capture, request)
+			b.record(request, start, CapturedResponse{
+				Code:     capture.code,
+				Header:   response.Header(),
+				Duration: b.now().Sub(start),
+			})
+		})
+	}
+}
+
+// captureWriter records the status code written to a response.  It forwards http.Flusher and http.Hijacker,
+// so that bookkeeping does not break streaming responses or protocol upgrades such as websockets.
+type captureWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	if !cw.wroteHeader && !xhttp.IsInformational(code) {
+		cw.code, cw.wroteHeader = code, true
+	}
+
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	cw.wroteHeader = true
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush delegates to the wrapped ResponseWriter.  If the delegate does not implement http.Flusher,
+// this method does nothing.
+func (cw *captureWriter) Flush() {
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack delegates to the wrapped ResponseWriter.  A successful hijack with no status code written
+// is recorded as http.StatusSwitchingProtocols.
+func (cw *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", cw.ResponseWriter)
+	}
+
+	c, rw, err := hijacker.Hijack()
+	if err == nil && !cw.wroteHeader {
+		cw.code, cw.wroteHeader = http.StatusSwitchingProtocols, true
+	}
+
+	return c, rw, err
+}
+
+// Method captures the request method
+func Method(request *http.Request) []interface{} {
+	return []interface{}{"method", request.Method}
+}
+
+// URI captures the unmodified request URI
+func URI(request *http.Request) []interface{} {
+	return []interface{}{"uri", request.RequestURI}
+}
+
+// RemoteAddr captures the remote address of the request
+func RemoteAddr(request *http.Request) []interface{} {
+	return []interface{}{"remoteAddr", request.RemoteAddr}
+}
+
+// RequestHeaders produces a RequestFunc that captures the given request headers.  Missing headers are skipped.
+func RequestHeaders(names ...string) RequestFunc {
+	return func(request *http.Request) []interface{} {
+		var kv []interface{}
+		for _, name := range names {
+			if values := request.Header[http.CanonicalHeaderKey(name)]; len(values) > 0 {
+				kv = append(kv, name, values)
+			}
+		}
+
+		return kv
+	}
+}
+
+// Code captures the response status code
+func Code(response CapturedResponse) []interface{} {
+	return []interface{}{"code", response.Code}
+}
+
+// Duration captures how long the request took, in the format produced by time.Duration.String
+func Duration(response CapturedResponse) []interface{} {
+	return []interface{}{"duration", response.Duration.String()}
+}
+
+// ResponseHeaders produces a ResponseFunc that captures the given response headers.  Missing headers are skipped.
+func ResponseHeaders(names ...string) ResponseFunc {
+	return func(response CapturedResponse) []interface{} {
+		var kv []interface{}
+		for _, name := range names {
+			if values := response.Header[http.CanonicalHeaderKey(name)]; len(values) > 0 {
+				kv = append(kv, name, values)
+			}
+		}
+
+		return kv
+	}
+}
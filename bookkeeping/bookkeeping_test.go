@@ -0,0 +1,191 @@
+package bookkeeping
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewDefaultSink(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/test", nil)
+		served   = false
+
+		decorator = New(WithRequests(Method), WithResponses(Code))
+	)
+
+	require.NotNil(decorator)
+	request = request.WithContext(logging.WithLogger(request.Context(), logging.NewTestLogger(nil, t)))
+
+	decorator(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		served = true
+		response.WriteHeader(http.StatusAccepted)
+	})).ServeHTTP(response, request)
+
+	assert.True(served)
+	assert.Equal(http.StatusAccepted, response.Code)
+}
+
+func testNewWithSinks(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/test?foo=bar", nil)
+
+		start    = time.Now()
+		current  = start
+		sink1    = new(mockSink)
+		sink2    = new(mockSink)
+		captured []Record
+	)
+
+	request.Header.Set("X-Test", "value")
+	request.RemoteAddr = "127.0.0.1:1234"
+	sink1.On("Send", mock.AnythingOfType("bookkeeping.Record")).Return(nil).Once().
+		Run(func(arguments mock.Arguments) {
+			captured = append(captured, arguments.Get(0).(Record))
+		})
+
+	sink2.On("Send", mock.AnythingOfType("bookkeeping.Record")).Return(errors.New("expected")).Once()
+
+	decorator := New(
+		WithRequests(Method, URI, RemoteAddr, RequestHeaders("X-Test", "X-Missing")),
+		WithResponses(Code, Duration, ResponseHeaders("Content-Type", "X-Missing")),
+		WithSinks(sink1, sink2),
+		WithNow(func() time.Time {
+			now := current
+			current = current.Add(time.Second)
+			return now
+		}),
+	)
+
+	require.NotNil(decorator)
+	request = request.WithContext(logging.WithLogger(request.Context(), logging.NewTestLogger(nil, t)))
+
+	decorator(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.Header().Set("Content-Type", "text/plain")
+		response.WriteHeader(http.StatusCreated)
+		response.WriteHeader(http.StatusInternalServerError)
+	})).ServeHTTP(response, request)
+
+	require.Len(captured, 1)
+	assert.Equal(start, captured[0].Timestamp)
+	assert.Equal(
+		[]interface{}{
+			"method", "POST",
+			"uri", "/test?foo=bar",
+			"remoteAddr", "127.0.0.1:1234",
+			"X-Test", []string{"value"},
+			"code", http.StatusCreated,
+			"duration", "1s",
+			"Content-Type", []string{"text/plain"},
+		},
+		captured[0].KeyValues,
+	)
+
+	sink1.AssertExpectations(t)
+	sink2.AssertExpectations(t)
+}
+
+func testNewImplicitCode(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/test", nil)
+		captured Record
+
+		decorator = New(
+			WithResponses(Code),
+			WithSinks(SinkFunc(func(r Record) error {
+				captured = r
+				return nil
+			})),
+		)
+	)
+
+	decorator(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.Write([]byte("body"))
+		response.WriteHeader(http.StatusNotFound)
+		response.(http.Flusher).Flush()
+	})).ServeHTTP(response, request)
+
+	assert.Equal([]interface{}{"code", http.StatusOK}, captured.KeyValues)
+	assert.True(response.Flushed)
+}
+
+func testNewInformationalCode(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/test", nil)
+		captured Record
+
+		decorator = New(
+			WithResponses(Code),
+			WithSinks(SinkFunc(func(r Record) error {
+				captured = r
+				return nil
+			})),
+		)
+	)
+
+	decorator(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.WriteHeader(http.StatusEarlyHints)
+		response.WriteHeader(http.StatusAccepted)
+	})).ServeHTTP(response, request)
+
+	assert.Equal([]interface{}{"code", http.StatusAccepted}, captured.KeyValues)
+}
+
+func testNewHijack(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		request  = httptest.NewRequest("GET", "/test", nil)
+		captured Record
+
+		decorator = New(
+			WithResponses(Code),
+			WithSinks(SinkFunc(func(r Record) error {
+				captured = r
+				return nil
+			})),
+		)
+
+		handler = decorator(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.(http.Hijacker).Hijack()
+		}))
+	)
+
+	response := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(response, request)
+	assert.True(response.hijacked)
+	assert.Equal([]interface{}{"code", http.StatusSwitchingProtocols}, captured.KeyValues)
+
+	// a delegate that cannot be hijacked produces an error, and the code is unchanged
+	handler = decorator(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		_, _, err := response.(http.Hijacker).Hijack()
+		assert.Error(err)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	assert.Equal([]interface{}{"code", http.StatusOK}, captured.KeyValues)
+}
+
+func TestNew(t *testing.T) {
+	t.Run("DefaultSink", testNewDefaultSink)
+	t.Run("WithSinks", testNewWithSinks)
+	t.Run("ImplicitCode", testNewImplicitCode)
+	t.Run("InformationalCode", testNewInformationalCode)
+	t.Run("Hijack", testNewHijack)
+}
@@ -0,0 +1,7 @@
+/*
+Package bookkeeping provides HTTP middleware that captures attributes of each request and its response.
+Captured attributes are delivered as a Record to one or more Sinks, such as a go-kit logger, a Kafka topic,
+or a file.  Sinks that may block can be made asynchronous with NewAsyncSink, which uses a bounded queue
+so that bookkeeping never delays request processing.
+*/
+package bookkeeping
@@ -0,0 +1,47 @@
+package bookkeeping
+
+import (
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/provider"
+)
+
+const (
+	DroppedRecordCounter = "bookkeeping_dropped_count"
+	QueueDepthGauge      = "bookkeeping_queue_depth"
+
+	// SinkLabel is the label identifying the asynchronous sink a metric applies to
+	SinkLabel = "sink"
+)
+
+// Metrics is the bookkeeping module function for metrics
+func Metrics() []xmetrics.Metric {
+	return []xmetrics.Metric{
+		{
+			Name:       DroppedRecordCounter,
+			Type:       "counter",
+			Help:       "The number of bookkeeping records dropped because a sink's queue was full",
+			LabelNames: []string{SinkLabel},
+		},
+		{
+			Name:       QueueDepthGauge,
+			Type:       "gauge",
+			Help:       "The number of bookkeeping records waiting to be sent",
+			LabelNames: []string{SinkLabel},
+		},
+	}
+}
+
+// Measures holds the metric objects for asynchronous sinks
+type Measures struct {
+	Dropped    metrics.Counter
+	QueueDepth metrics.Gauge
+}
+
+// NewMeasures constructs a Measures given a go-kit metrics Provider
+func NewMeasures(p provider.Provider) Measures {
+	return Measures{
+		Dropped:    p.NewCounter(DroppedRecordCounter),
+		QueueDepth: p.NewGauge(QueueDepthGauge),
+	}
+}
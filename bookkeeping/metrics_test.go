@@ -0,0 +1,38 @@
+package bookkeeping
+
+import (
+	"testing"
+
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/metrics/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	r, err := xmetrics.NewRegistry(nil, Metrics)
+	require.NoError(err)
+	require.NotNil(r)
+
+	m := NewMeasures(r)
+	assert.NotNil(m.Dropped)
+	assert.NotNil(m.QueueDepth)
+
+	m.Dropped.With(SinkLabel, "test").Add(1.0)
+	m.QueueDepth.With(SinkLabel, "test").Set(1.0)
+}
+
+func TestNewMeasures(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		m      = NewMeasures(provider.NewDiscardProvider())
+	)
+
+	assert.NotNil(m.Dropped)
+	assert.NotNil(m.QueueDepth)
+}
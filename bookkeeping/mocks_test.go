@@ -0,0 +1,38 @@
+package bookkeeping
+
+import (
+	"bufio"
+	"net"
+	"net/http/httptest"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSink struct {
+	mock.Mock
+}
+
+func (m *mockSink) Send(r Record) error {
+	return m.Called(r).Error(0)
+}
+
+type mockKafkaProducer struct {
+	mock.Mock
+}
+
+func (m *mockKafkaProducer) SendMessage(message *sarama.ProducerMessage) (int32, int64, error) {
+	arguments := m.Called(message)
+	return arguments.Get(0).(int32), arguments.Get(1).(int64), arguments.Error(2)
+}
+
+// hijackRecorder is an httptest.ResponseRecorder that also implements http.Hijacker
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (hr *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hr.hijacked = true
+	return nil, nil, nil
+}
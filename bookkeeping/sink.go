@@ -0,0 +1,131 @@
+package bookkeeping
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Shopify/sarama"
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	// TimestampKey is the key under which a Record's timestamp appears in its JSON form
+	TimestampKey = "timestamp"
+)
+
+// Record is the set of attributes captured for a single request
+type Record struct {
+	// Timestamp is the time at which the request began
+	Timestamp time.Time
+
+	// KeyValues are the captured attributes, as alternating keys and values in the manner of go-kit logging
+	KeyValues []interface{}
+}
+
+// MarshalJSON produces a JSON object from a Record's key/value pairs.  Keys that are not strings are formatted
+// with fmt.Sprint, and a trailing key without a value is paired with null.
+func (r Record) MarshalJSON() ([]byte, error) {
+	object := make(map[string]interface{}, len(r.KeyValues)/2+1)
+	object[TimestampKey] = r.Timestamp.UTC().Format(time.RFC3339Nano)
+	for i := 0; i < len(r.KeyValues); i += 2 {
+		var value interface{}
+		if i+1 < len(r.KeyValues) {
+			value = r.KeyValues[i+1]
+		}
+
+		object[fmt.Sprint(r.KeyValues[i])] = value
+	}
+
+	return json.Marshal(object)
+}
+
+// Sink is a destination for bookkeeping records
+type Sink interface {
+	Send(Record) error
+}
+
+// SinkFunc is a function type that implements Sink
+type SinkFunc func(Record) error
+
+func (sf SinkFunc) Send(r Record) error {
+	return sf(r)
+}
+
+// LogSink produces a Sink that logs each record to a go-kit logger.  If logger is nil, the default logger is used.
+func LogSink(logger log.Logger) Sink {
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	return SinkFunc(func(r Record) error {
+		return logger.Log(
+			append([]interface{}{logging.MessageKey(), "Bookkeeping response", TimestampKey, r.Timestamp}, r.KeyValues...)...,
+		)
+	})
+}
+
+// WriterSink produces a Sink that writes each record as a line of JSON.  Writes are serialized, so
+// the writer need not be safe for concurrent use.
+func WriterSink(w io.Writer) Sink {
+	var lock sync.Mutex
+	return SinkFunc(func(r Record) error {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		_, err = w.Write(append(data, '\n'))
+		return err
+	})
+}
+
+// FileSink is a Sink that appends records, as lines of JSON, to a file
+type FileSink struct {
+	Sink
+	file *os.File
+}
+
+// OpenFileSink opens the given file for appending, creating it if necessary
+func OpenFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{Sink: WriterSink(file), file: file}, nil
+}
+
+// Close closes the underlying file
+func (fs *FileSink) Close() error {
+	return fs.file.Close()
+}
+
+// KafkaProducer is the subset of sarama.SyncProducer used by a Kafka Sink
+type KafkaProducer interface {
+	SendMessage(*sarama.ProducerMessage) (int32, int64, error)
+}
+
+// KafkaSink produces a Sink that publishes each record as JSON to a Kafka topic.  A sarama.SyncProducer blocks
+// until the message is acknowledged, so this Sink is typically wrapped with NewAsyncSink.
+func KafkaSink(producer KafkaProducer, topic string) Sink {
+	return SinkFunc(func(r Record) error {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = producer.SendMessage(&sarama.ProducerMessage{
+			Topic: topic,
+			Value: sarama.ByteEncoder(data),
+		})
+
+		return err
+	})
+}
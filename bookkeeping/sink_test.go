@@ -0,0 +1,134 @@
+package bookkeeping
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testTimestamp = time.Date(2018, 6, 1, 12, 30, 0, 0, time.UTC)
+
+func TestRecordMarshalJSON(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	data, err := json.Marshal(Record{
+		Timestamp: testTimestamp,
+		KeyValues: []interface{}{"code", 200, 123, "numeric key", "dangling"},
+	})
+
+	require.NoError(err)
+	assert.JSONEq(
+		`{"timestamp": "2018-06-01T12:30:00Z", "code": 200, "123": "numeric key", "dangling": null}`,
+		string(data),
+	)
+}
+
+func TestSinkFunc(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+		sent          Record
+		sink          Sink = SinkFunc(func(r Record) error {
+			sent = r
+			return expectedError
+		})
+	)
+
+	assert.Equal(expectedError, sink.Send(Record{Timestamp: testTimestamp}))
+	assert.Equal(testTimestamp, sent.Timestamp)
+}
+
+func TestLogSink(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(LogSink(nil).Send(Record{Timestamp: testTimestamp}))
+	assert.NoError(LogSink(logging.NewTestLogger(nil, t)).Send(Record{Timestamp: testTimestamp, KeyValues: []interface{}{"code", 200}}))
+}
+
+func TestWriterSink(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output bytes.Buffer
+		sink   = WriterSink(&output)
+	)
+
+	require.NoError(sink.Send(Record{Timestamp: testTimestamp, KeyValues: []interface{}{"code", 200}}))
+	require.NoError(sink.Send(Record{Timestamp: testTimestamp, KeyValues: []interface{}{"code", 404}}))
+
+	lines := bytes.Split(bytes.TrimSpace(output.Bytes()), []byte{'\n'})
+	require.Len(lines, 2)
+	assert.JSONEq(`{"timestamp": "2018-06-01T12:30:00Z", "code": 200}`, string(lines[0]))
+	assert.JSONEq(`{"timestamp": "2018-06-01T12:30:00Z", "code": 404}`, string(lines[1]))
+
+	assert.Error(sink.Send(Record{KeyValues: []interface{}{"unsupported", func() {}}}))
+}
+
+func TestFileSink(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	directory, err := ioutil.TempDir("", "bookkeeping")
+	require.NoError(err)
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "records.json")
+	for _, code := range []int{200, 404} {
+		sink, err := OpenFileSink(path)
+		require.NoError(err)
+		require.NotNil(sink)
+
+		assert.NoError(sink.Send(Record{Timestamp: testTimestamp, KeyValues: []interface{}{"code", code}}))
+		assert.NoError(sink.Close())
+	}
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(err)
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte{'\n'})
+	require.Len(lines, 2)
+	assert.JSONEq(`{"timestamp": "2018-06-01T12:30:00Z", "code": 200}`, string(lines[0]))
+	assert.JSONEq(`{"timestamp": "2018-06-01T12:30:00Z", "code": 404}`, string(lines[1]))
+
+	sink, err := OpenFileSink(filepath.Join(directory, "nosuch", "records.json"))
+	assert.Nil(sink)
+	assert.Error(err)
+}
+
+func TestKafkaSink(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		producer      = new(mockKafkaProducer)
+		expectedError = errors.New("expected")
+		sink          = KafkaSink(producer, "bookkeeping")
+	)
+
+	producer.On("SendMessage", mock.MatchedBy(func(m *sarama.ProducerMessage) bool {
+		data, err := m.Value.Encode()
+		return m.Topic == "bookkeeping" && err == nil && string(data) == `{"code":200,"timestamp":"2018-06-01T12:30:00Z"}`
+	})).Return(int32(0), int64(0), nil).Once()
+
+	producer.On("SendMessage", mock.AnythingOfType("*sarama.ProducerMessage")).Return(int32(0), int64(0), expectedError).Once()
+
+	assert.NoError(sink.Send(Record{Timestamp: testTimestamp, KeyValues: []interface{}{"code", 200}}))
+	assert.Equal(expectedError, sink.Send(Record{Timestamp: testTimestamp, KeyValues: []interface{}{"code", 500}}))
+	assert.Error(sink.Send(Record{KeyValues: []interface{}{"unsupported", func() {}}}))
+
+	producer.AssertExpectations(t)
+}
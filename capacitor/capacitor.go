@@ -0,0 +1,231 @@
+package capacitor
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDelay is the quiet period used when no delay is configured
+	DefaultDelay = time.Second
+)
+
+// Strategy determines when a capacitor discharges relative to a burst of triggers
+type Strategy int
+
+const (
+	// Trailing discharges once a burst of triggers has been followed by a quiet period.  This is the default.
+	Trailing Strategy = iota
+
+	// Leading discharges immediately on the first trigger after a quiet period.  Any triggers that arrive before the
+	// quiet period elapses are coalesced and discharged at the end of the period, so that no payload is lost.
+	Leading
+)
+
+// MergeFunc combines the payload accumulated so far with the payload of a new trigger
+type MergeFunc func(accumulated, next interface{}) interface{}
+
+// LastWins is the default MergeFunc.  It discards the accumulated payload in favor of the most recent one.
+func LastWins(_, next interface{}) interface{} {
+	return next
+}
+
+// Interface represents a capacitor, which coalesces triggers into discharges
+type Interface interface {
+	// Submit triggers this capacitor with a payload, which may be nil.  Payloads submitted before a discharge
+	// are combined with the configured MergeFunc.
+	Submit(interface{})
+
+	// Discharge immediately discharges any pending payload, bypassing the delay
+	Discharge()
+
+	// Cancel discards any pending payload without discharging it
+	Cancel()
+}
+
+// Option is a configuration option for a capacitor
+type Option func(*capacitor)
+
+// WithDelay sets the quiet period.  Nonpositive values result in DefaultDelay.
+func WithDelay(d time.Duration) Option {
+	return func(c *capacitor) {
+		if d > 0 {
+			c.delay = d
+		} else {
+			c.delay = DefaultDelay
+		}
+	}
+}
+
+// WithMaxWait bounds how long a pending payload can be deferred.  Without a maximum wait, a continuous stream
+// of triggers can postpone a trailing discharge indefinitely.  Nonpositive values disable the maximum wait.
+func WithMaxWait(d time.Duration) Option {
+	return func(c *capacitor) {
+		c.maxWait = d
+	}
+}
+
+// WithStrategy sets the discharge strategy
+func WithStrategy(s Strategy) Option {
+	return func(c *capacitor) {
+		c.strategy = s
+	}
+}
+
+// WithMerge sets the function used to combine payloads.  A nil function results in LastWins.
+func WithMerge(m MergeFunc) Option {
+	return func(c *capacitor) {
+		if m != nil {
+			c.merge = m
+		} else {
+			c.merge = LastWins
+		}
+	}
+}
+
+// New constructs a capacitor that invokes the given function on each discharge.  The function is never
+// invoked concurrently with itself.
+func New(discharge func(interface{}), options ...Option) Interface {
+	if discharge == nil {
+		panic("a discharge function is required")
+	}
+
+	c := &capacitor{
+		delay:     DefaultDelay,
+		merge:     LastWins,
+		discharge: discharge,
+		afterFunc: func(d time.Duration, f func()) func() bool {
+			return time.AfterFunc(d, f).Stop
+		},
+	}
+
+	for _, o := range options {
+		o(c)
+	}
+
+	return c
+}
+
+// timer tracks a single pending timer.  Timer callbacks compare their token against the current one,
+// so that a callback which fires after its timer was stopped or replaced has no effect.  A zero token
+// indicates no timer.
+type timer struct {
+	token uint64
+	stop  func() bool
+}
+
+func (t *timer) reset() {
+	if t.stop != nil {
+		t.stop()
+	}
+
+	t.token, t.stop = 0, nil
+}
+
+// capacitor is the internal Interface implementation
+type capacitor struct {
+	delay     time.Duration
+	maxWait   time.Duration
+	strategy  Strategy
+	merge     MergeFunc
+	discharge func(interface{})
+	afterFunc func(time.Duration, func()) func() bool
+
+	dischargeLock sync.Mutex
+
+	lock     sync.Mutex
+	tokens   uint64
+	pending  bool
+	payload  interface{}
+	inWindow bool
+	delayT   timer
+	maxWaitT timer
+}
+
+func (c *capacitor) Submit(v interface{}) {
+	c.lock.Lock()
+
+	if c.strategy == Leading && !c.inWindow {
+		c.inWindow = true
+		c.startDelay()
+		c.startMaxWait()
+		c.lock.Unlock()
+
+		c.dischargeLock.Lock()
+		c.discharge(v)
+		c.dischargeLock.Unlock()
+		return
+	}
+
+	if c.pending {
+		c.payload = c.merge(c.payload, v)
+	} else {
+		c.pending, c.payload = true, v
+		if c.strategy == Trailing {
+			c.startMaxWait()
+		}
+	}
+
+	c.startDelay()
+	c.lock.Unlock()
+}
+
+func (c *capacitor) Discharge() {
+	c.flush(0)
+}
+
+func (c *capacitor) Cancel() {
+	c.lock.Lock()
+	c.clear()
+	c.lock.Unlock()
+}
+
+// startDelay (re)starts the quiet period.  This method must be invoked under the lock.
+func (c *capacitor) startDelay() {
+	c.delayT.reset()
+	c.tokens++
+	token := c.tokens
+	c.delayT.token = token
+	c.delayT.stop = c.afterFunc(c.delay, func() { c.flush(token) })
+}
+
+// startMaxWait starts the maximum wait timer, if one is configured.  This method must be invoked under the lock.
+func (c *capacitor) startMaxWait() {
+	if c.maxWait < 1 {
+		return
+	}
+
+	c.maxWaitT.reset()
+	c.tokens++
+	token := c.tokens
+	c.maxWaitT.token = token
+	c.maxWaitT.stop = c.afterFunc(c.maxWait, func() { c.flush(token) })
+}
+
+// clear discards all pending state.  This method must be invoked under the lock.
+func (c *capacitor) clear() {
+	c.delayT.reset()
+	c.maxWaitT.reset()
+	c.pending, c.payload, c.inWindow = false, nil, false
+}
+
+// flush discharges any pending payload.  If token is nonzero, the flush was triggered by a timer and
+// only proceeds if that timer is still current.
+func (c *capacitor) flush(token uint64) {
+	c.dischargeLock.Lock()
+	defer c.dischargeLock.Unlock()
+
+	c.lock.Lock()
+	if token != 0 && token != c.delayT.token && token != c.maxWaitT.token {
+		c.lock.Unlock()
+		return
+	}
+
+	pending, payload := c.pending, c.payload
+	c.clear()
+	c.lock.Unlock()
+
+	if pending {
+		c.discharge(payload)
+	}
+}
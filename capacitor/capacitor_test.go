@@ -0,0 +1,245 @@
+package capacitor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTimer is a manually fired timer
+type fakeTimer struct {
+	d       time.Duration
+	f       func()
+	stopped bool
+}
+
+// fakeTimers records the timers created by a capacitor, so that tests can fire them explicitly
+type fakeTimers struct {
+	lock   sync.Mutex
+	timers []*fakeTimer
+}
+
+func (ft *fakeTimers) afterFunc(d time.Duration, f func()) func() bool {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+
+	t := &fakeTimer{d: d, f: f}
+	ft.timers = append(ft.timers, t)
+	return func() bool {
+		ft.lock.Lock()
+		defer ft.lock.Unlock()
+		wasActive := !t.stopped
+		t.stopped = true
+		return wasActive
+	}
+}
+
+// active returns the unstopped timers with the given duration
+func (ft *fakeTimers) active(d time.Duration) []*fakeTimer {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+
+	var active []*fakeTimer
+	for _, t := range ft.timers {
+		if !t.stopped && t.d == d {
+			active = append(active, t)
+		}
+	}
+
+	return active
+}
+
+// fire fires the single active timer with the given duration
+func (ft *fakeTimers) fire(t *testing.T, d time.Duration) {
+	active := ft.active(d)
+	require.Len(t, active, 1)
+	active[0].stopped = true
+	active[0].f()
+}
+
+// recorder captures discharged payloads
+type recorder struct {
+	lock     sync.Mutex
+	payloads []interface{}
+}
+
+func (r *recorder) discharge(v interface{}) {
+	r.lock.Lock()
+	r.payloads = append(r.payloads, v)
+	r.lock.Unlock()
+}
+
+func (r *recorder) get() []interface{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]interface{}{}, r.payloads...)
+}
+
+func newTestCapacitor(options ...Option) (*capacitor, *fakeTimers, *recorder) {
+	var (
+		ft = new(fakeTimers)
+		r  = new(recorder)
+		c  = New(r.discharge, options...).(*capacitor)
+	)
+
+	c.afterFunc = ft.afterFunc
+	return c, ft, r
+}
+
+func appendMerge(accumulated, next interface{}) interface{} {
+	if accumulated == nil {
+		return []interface{}{next}
+	}
+
+	if slice, ok := accumulated.([]interface{}); ok {
+		return append(slice, next)
+	}
+
+	return []interface{}{accumulated, next}
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { New(nil) })
+
+	c := New(func(interface{}) {}).(*capacitor)
+	assert.Equal(DefaultDelay, c.delay)
+	assert.Zero(c.maxWait)
+	assert.Equal(Trailing, c.strategy)
+	assert.NotNil(c.merge)
+
+	c = New(func(interface{}) {}, WithDelay(-1), WithMerge(nil), WithMaxWait(time.Minute), WithStrategy(Leading)).(*capacitor)
+	assert.Equal(DefaultDelay, c.delay)
+	assert.Equal(time.Minute, c.maxWait)
+	assert.Equal(Leading, c.strategy)
+	assert.Equal("next", c.merge("accumulated", "next"))
+}
+
+func TestTrailing(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		c, ft, r    = newTestCapacitor(WithDelay(time.Second), WithMerge(appendMerge))
+		firstTimers []*fakeTimer
+	)
+
+	c.Submit(1)
+	firstTimers = ft.active(time.Second)
+	c.Submit(2)
+	c.Submit(3)
+	assert.Empty(r.get())
+
+	// each trigger restarts the quiet period, so a stale timer firing does nothing
+	require.Len(t, firstTimers, 1)
+	firstTimers[0].f()
+	assert.Empty(r.get())
+
+	ft.fire(t, time.Second)
+	assert.Equal([]interface{}{[]interface{}{1, 2, 3}}, r.get())
+
+	c.Submit(4)
+	ft.fire(t, time.Second)
+	assert.Equal([]interface{}{[]interface{}{1, 2, 3}, 4}, r.get())
+}
+
+func TestTrailingMaxWait(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		c, ft, r = newTestCapacitor(WithDelay(time.Second), WithMaxWait(time.Minute))
+	)
+
+	c.Submit("a")
+	c.Submit("b")
+	require.Len(t, ft.active(time.Minute), 1)
+
+	ft.fire(t, time.Minute)
+	assert.Equal([]interface{}{"b"}, r.get())
+	assert.Empty(ft.active(time.Second))
+
+	// the next burst starts a new maximum wait
+	c.Submit("c")
+	require.Len(t, ft.active(time.Minute), 1)
+	ft.fire(t, time.Second)
+	assert.Equal([]interface{}{"b", "c"}, r.get())
+	assert.Empty(ft.active(time.Minute))
+}
+
+func TestLeading(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		c, ft, r = newTestCapacitor(WithStrategy(Leading), WithDelay(time.Second), WithMerge(appendMerge))
+	)
+
+	c.Submit(1)
+	assert.Equal([]interface{}{1}, r.get())
+
+	// triggers inside the window are coalesced and discharged at its end
+	c.Submit(2)
+	c.Submit(3)
+	assert.Equal([]interface{}{1}, r.get())
+
+	ft.fire(t, time.Second)
+	assert.Equal([]interface{}{1, []interface{}{2, 3}}, r.get())
+
+	// the window has closed, so the next trigger discharges immediately
+	c.Submit(4)
+	assert.Equal([]interface{}{1, []interface{}{2, 3}, 4}, r.get())
+
+	// a window with no further triggers discharges nothing
+	ft.fire(t, time.Second)
+	assert.Equal([]interface{}{1, []interface{}{2, 3}, 4}, r.get())
+}
+
+func TestDischarge(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		c, ft, r = newTestCapacitor(WithMaxWait(time.Minute))
+	)
+
+	c.Discharge()
+	assert.Empty(r.get())
+
+	c.Submit("a")
+	c.Discharge()
+	assert.Equal([]interface{}{"a"}, r.get())
+	assert.Empty(ft.active(DefaultDelay))
+	assert.Empty(ft.active(time.Minute))
+}
+
+func TestCancel(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		c, ft, r = newTestCapacitor()
+	)
+
+	c.Submit("a")
+	timers := ft.active(DefaultDelay)
+	require.Len(t, timers, 1)
+
+	c.Cancel()
+	assert.Empty(ft.active(DefaultDelay))
+
+	// even if the timer fires after cancellation, nothing is discharged
+	timers[0].f()
+	c.Discharge()
+	assert.Empty(r.get())
+}
+
+func TestRealTimers(t *testing.T) {
+	var (
+		discharged = make(chan interface{}, 1)
+		c          = New(func(v interface{}) { discharged <- v }, WithDelay(10*time.Millisecond))
+	)
+
+	c.Submit("a")
+	c.Submit("b")
+
+	select {
+	case v := <-discharged:
+		assert.Equal(t, "b", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The capacitor did not discharge")
+	}
+}
@@ -0,0 +1,6 @@
+/*
+Package capacitor provides delayed, coalesced execution.  A capacitor accumulates triggers, each of which
+may carry a payload, and discharges them as a single invocation according to a Strategy.  This is useful
+for collapsing bursts of events, such as service discovery updates, into a single unit of work.
+*/
+package capacitor
@@ -0,0 +1,58 @@
+package concurrent
+
+import "sync"
+
+// keyedLock is a single mutex within a KeyedMutex, along with the number of goroutines holding or waiting for it
+type keyedLock struct {
+	sync.Mutex
+	references int
+}
+
+// KeyedMutex provides a separate mutex for each key.  Operations on the same key are serialized, while operations
+// on different keys proceed in parallel.  This is useful for read-modify-write sequences against stores, such as
+// a store.KV, which have no atomic update of their own.
+//
+// Mutexes are created on demand and discarded once no goroutine holds or waits for them, so the memory used
+// is bounded by the number of keys in use rather than the number of keys ever seen.  The zero value is ready to use.
+type KeyedMutex struct {
+	lock  sync.Mutex
+	locks map[string]*keyedLock
+}
+
+// Lock acquires the mutex for the given key, blocking until it is available
+func (km *KeyedMutex) Lock(key string) {
+	km.lock.Lock()
+	if km.locks == nil {
+		km.locks = make(map[string]*keyedLock)
+	}
+
+	kl, ok := km.locks[key]
+	if !ok {
+		kl = new(keyedLock)
+		km.locks[key] = kl
+	}
+
+	kl.references++
+	km.lock.Unlock()
+
+	kl.Lock()
+}
+
+// Unlock releases the mutex for the given key.  As with sync.Mutex, it is a runtime error to unlock a key
+// that is not locked.
+func (km *KeyedMutex) Unlock(key string) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+
+	kl, ok := km.locks[key]
+	if !ok {
+		panic("concurrent: unlock of unlocked key")
+	}
+
+	kl.references--
+	if kl.references == 0 {
+		delete(km.locks, key)
+	}
+
+	kl.Unlock()
+}
@@ -0,0 +1,69 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKeyedMutexSameKey(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		km        KeyedMutex
+		waitGroup sync.WaitGroup
+		counter   int
+	)
+
+	for i := 0; i < 10; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for j := 0; j < 100; j++ {
+				km.Lock("key")
+				counter++
+				km.Unlock("key")
+			}
+		}()
+	}
+
+	waitGroup.Wait()
+	assert.Equal(1000, counter)
+	assert.Empty(km.locks)
+}
+
+func testKeyedMutexDifferentKeys(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		km       KeyedMutex
+		acquired = make(chan struct{})
+	)
+
+	km.Lock("first")
+	go func() {
+		km.Lock("second")
+		km.Unlock("second")
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		assert.Fail("a locked key blocked a different key")
+	}
+
+	km.Unlock("first")
+	assert.Empty(km.locks)
+}
+
+func testKeyedMutexUnlockUnlocked(t *testing.T) {
+	var km KeyedMutex
+	assert.Panics(t, func() { km.Unlock("nosuch") })
+}
+
+func TestKeyedMutex(t *testing.T) {
+	t.Run("SameKey", testKeyedMutexSameKey)
+	t.Run("DifferentKeys", testKeyedMutexDifferentKeys)
+	t.Run("UnlockUnlocked", testKeyedMutexUnlockUnlocked)
+}
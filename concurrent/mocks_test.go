@@ -0,0 +1,44 @@
+package concurrent
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// testCounter is a metrics.Counter that records the total added under each set of label values.  It is used
+// instead of xmetricstest, which cannot be imported here without an import cycle.
+type testCounter struct {
+	lock   *sync.Mutex
+	totals map[string]float64
+	labels []string
+}
+
+func newTestCounter() *testCounter {
+	return &testCounter{
+		lock:   new(sync.Mutex),
+		totals: make(map[string]float64),
+	}
+}
+
+func (tc *testCounter) With(labelValues ...string) metrics.Counter {
+	return &testCounter{
+		lock:   tc.lock,
+		totals: tc.totals,
+		labels: append(append([]string{}, tc.labels...), labelValues...),
+	}
+}
+
+func (tc *testCounter) Add(delta float64) {
+	tc.lock.Lock()
+	tc.totals[strings.Join(tc.labels, ",")] += delta
+	tc.lock.Unlock()
+}
+
+// value returns the total added under the given label values
+func (tc *testCounter) value(labelValues ...string) float64 {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	return tc.totals[strings.Join(labelValues, ",")]
+}
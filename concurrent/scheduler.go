@@ -0,0 +1,212 @@
+package concurrent
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+// ScheduleMode determines how a Scheduler spaces the runs of its task
+type ScheduleMode int
+
+const (
+	// FixedRate starts runs on a fixed cadence, measured from when the Scheduler started.  Runs do not drift
+	// when the task takes time to execute.  If a run overruns one or more periods, the missed runs are skipped
+	// rather than executed back to back.
+	FixedRate ScheduleMode = iota
+
+	// FixedDelay starts each run a fixed period after the previous run completes
+	FixedDelay
+)
+
+// ScheduleOptions configures a Scheduler
+type ScheduleOptions struct {
+	// Name identifies the scheduled task in logging and metrics
+	Name string
+
+	// Logger is the go-kit logger to use.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger
+
+	// Mode is the scheduling mode.  The default is FixedRate.
+	Mode ScheduleMode
+
+	// Period is the interval between runs.  This field is required.
+	Period time.Duration
+
+	// Jitter is the upper bound of a random delay added to each run, which spreads out the runs of many
+	// instances that start at the same time.  If unset, runs are not jittered.
+	Jitter time.Duration
+
+	// RunOnStart runs the task as soon as the Scheduler starts, rather than after the first period
+	RunOnStart bool
+
+	// Panics is an optional counter incremented each time the task panics.  It is labeled with TaskLabel.
+	Panics metrics.Counter
+
+	// Now is the source of the current time.  If unset, time.Now is used.
+	Now func() time.Time
+
+	// After is used to wait between runs.  If unset, time.After is used.
+	After func(time.Duration) <-chan time.Time
+
+	// Random produces the jitter for each run, given Jitter as the exclusive upper bound.  If unset, rand.Int63n is used.
+	Random func(int64) int64
+}
+
+// Scheduler runs a task periodically in a goroutine.  A panic in the task is logged and counted, and does not
+// stop subsequent runs.  This centralizes the ticker loops that background updaters would otherwise each implement.
+// Scheduler implements Runnable.
+type Scheduler struct {
+	name       string
+	logger     log.Logger
+	mode       ScheduleMode
+	period     time.Duration
+	jitter     time.Duration
+	runOnStart bool
+	panics     metrics.Counter
+	now        func() time.Time
+	after      func(time.Duration) <-chan time.Time
+	random     func(int64) int64
+	task       func()
+
+	once sync.Once
+}
+
+// NewScheduler constructs a Scheduler for the given task.  This function panics if o.Period is nonpositive.
+func NewScheduler(o ScheduleOptions, task func()) *Scheduler {
+	if o.Period < 1 {
+		panic("a scheduled task requires a positive period")
+	}
+
+	s := &Scheduler{
+		name:       o.Name,
+		logger:     o.Logger,
+		mode:       o.Mode,
+		period:     o.Period,
+		jitter:     o.Jitter,
+		runOnStart: o.RunOnStart,
+		panics:     o.Panics,
+		now:        o.Now,
+		after:      o.After,
+		random:     o.Random,
+		task:       task,
+	}
+
+	if s.logger == nil {
+		s.logger = logging.DefaultLogger()
+	}
+
+	if s.panics == nil {
+		s.panics = discard.NewCounter()
+	}
+
+	if s.now == nil {
+		s.now = time.Now
+	}
+
+	if s.after == nil {
+		s.after = time.After
+	}
+
+	if s.random == nil {
+		s.random = rand.Int63n
+	}
+
+	return s
+}
+
+// Run starts the goroutine which runs the task until shutdown is closed.  This method is idempotent.
+func (s *Scheduler) Run(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
+	s.once.Do(func() {
+		waitGroup.Add(1)
+		go s.schedule(waitGroup, shutdown)
+	})
+
+	return nil
+}
+
+// runTask executes the task once, converting any panic into an error
+func (s *Scheduler) runTask() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("scheduled task panicked: %v", r)
+		}
+	}()
+
+	s.task()
+	return nil
+}
+
+// offset returns the jitter for a single run
+func (s *Scheduler) offset() time.Duration {
+	if s.jitter > 0 {
+		return time.Duration(s.random(int64(s.jitter)))
+	}
+
+	return 0
+}
+
+// schedule is the goroutine that runs the task
+func (s *Scheduler) schedule(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) {
+	defer waitGroup.Done()
+
+	var (
+		logger = log.With(s.logger, TaskLabel, s.name)
+		panics = s.panics.With(TaskLabel, s.name)
+
+		// next is the start of the next fixed rate slot, before jitter
+		next = s.now()
+		wait time.Duration
+	)
+
+	logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "scheduled task starting")
+	defer logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "scheduled task stopped")
+
+	if !s.runOnStart {
+		next = next.Add(s.period)
+		wait = s.period + s.offset()
+	}
+
+	for {
+		if wait > 0 {
+			select {
+			case <-shutdown:
+				return
+			case <-s.after(wait):
+			}
+		} else {
+			select {
+			case <-shutdown:
+				return
+			default:
+			}
+		}
+
+		if err := s.runTask(); err != nil {
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "scheduled task failed", logging.ErrorKey(), err)
+			panics.Add(1.0)
+		}
+
+		switch s.mode {
+		case FixedDelay:
+			wait = s.period + s.offset()
+
+		default:
+			// advance to the next slot that hasn't already passed, skipping any slots missed by a long run
+			now := s.now()
+			next = next.Add(s.period)
+			if behind := now.Sub(next); behind > 0 {
+				next = next.Add((behind/s.period + 1) * s.period)
+			}
+
+			wait = next.Sub(now) + s.offset()
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// limitedAfter is a time.After substitute that records the requested waits and returns immediately.  Once the
+// limit is reached, it closes shutdown and returns a channel that never fires, so that the scheduler stops
+// deterministically.
+type limitedAfter struct {
+	limit    int
+	shutdown chan struct{}
+	waits    []time.Duration
+}
+
+func newLimitedAfter(limit int) *limitedAfter {
+	return &limitedAfter{limit: limit, shutdown: make(chan struct{})}
+}
+
+func (la *limitedAfter) after(d time.Duration) <-chan time.Time {
+	la.waits = append(la.waits, d)
+	if len(la.waits) >= la.limit {
+		close(la.shutdown)
+		return nil
+	}
+
+	c := make(chan time.Time, 1)
+	c <- time.Now()
+	return c
+}
+
+// scriptedNow is a time.Now substitute that returns the given offsets from a fixed start, in order
+func scriptedNow(offsets ...time.Duration) func() time.Time {
+	start := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		next := start.Add(offsets[0])
+		if len(offsets) > 1 {
+			offsets = offsets[1:]
+		}
+
+		return next
+	}
+}
+
+func TestNewSchedulerInvalidPeriod(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { NewScheduler(ScheduleOptions{}, func() {}) })
+	assert.Panics(func() { NewScheduler(ScheduleOptions{Period: -1}, func() {}) })
+}
+
+func TestSchedulerFixedRate(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		after  = newLimitedAfter(3)
+		calls  int
+
+		s = NewScheduler(
+			ScheduleOptions{
+				Name:       "test",
+				Logger:     logging.NewTestLogger(nil, t),
+				Period:     10 * time.Second,
+				RunOnStart: true,
+				Now:        scriptedNow(0, 2*time.Second, 25*time.Second, 31*time.Second),
+				After:      after.after,
+			},
+			func() { calls++ },
+		)
+	)
+
+	waitGroup := new(sync.WaitGroup)
+	assert.NoError(s.Run(waitGroup, after.shutdown))
+	waitGroup.Wait()
+
+	assert.Equal(3, calls)
+
+	// the second run overran its slot at 20s, so the next run is at 30s rather than immediately
+	assert.Equal([]time.Duration{8 * time.Second, 5 * time.Second, 9 * time.Second}, after.waits)
+}
+
+func TestSchedulerFixedDelay(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		after  = newLimitedAfter(3)
+		calls  int
+
+		s = NewScheduler(
+			ScheduleOptions{
+				Name:   "test",
+				Logger: logging.NewTestLogger(nil, t),
+				Mode:   FixedDelay,
+				Period: 10 * time.Second,
+				Jitter: 4 * time.Second,
+				After:  after.after,
+				Random: func(n int64) int64 { return n / 2 },
+			},
+			func() { calls++ },
+		)
+	)
+
+	waitGroup := new(sync.WaitGroup)
+	assert.NoError(s.Run(waitGroup, after.shutdown))
+	waitGroup.Wait()
+
+	assert.Equal(2, calls)
+	assert.Equal([]time.Duration{12 * time.Second, 12 * time.Second, 12 * time.Second}, after.waits)
+}
+
+func TestSchedulerPanics(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		panics = newTestCounter()
+		after  = newLimitedAfter(3)
+		calls  int
+
+		s = NewScheduler(
+			ScheduleOptions{
+				Name:   "panicky",
+				Logger: logging.NewTestLogger(nil, t),
+				Mode:   FixedDelay,
+				Period: time.Second,
+				Panics: panics,
+				After:  after.after,
+			},
+			func() {
+				calls++
+				panic("expected")
+			},
+		)
+	)
+
+	waitGroup := new(sync.WaitGroup)
+	assert.NoError(s.Run(waitGroup, after.shutdown))
+	waitGroup.Wait()
+
+	assert.Equal(2, calls)
+	assert.Equal(2.0, panics.value(TaskLabel, "panicky"))
+}
+
+func TestSchedulerDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		lock  sync.Mutex
+		calls int
+
+		s = NewScheduler(
+			ScheduleOptions{Period: time.Millisecond, Jitter: time.Millisecond, RunOnStart: true},
+			func() {
+				lock.Lock()
+				calls++
+				lock.Unlock()
+			},
+		)
+
+		waitGroup = new(sync.WaitGroup)
+		shutdown  = make(chan struct{})
+	)
+
+	assert.NoError(s.Run(waitGroup, shutdown))
+	assert.NoError(s.Run(waitGroup, shutdown))
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		lock.Lock()
+		done := calls >= 3
+		lock.Unlock()
+		if done {
+			break
+		}
+	}
+
+	close(shutdown)
+	waitGroup.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.True(calls >= 3)
+}
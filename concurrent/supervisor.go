@@ -0,0 +1,235 @@
+package concurrent
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+const (
+	// TaskLabel is the metrics label used for the name of a supervised task
+	TaskLabel = "task"
+
+	// MinRestartDelay is the least amount of time a Supervisor waits before restarting a task.  This keeps
+	// a task that fails or completes immediately from spinning in a tight loop.
+	MinRestartDelay = 100 * time.Millisecond
+)
+
+// Task is a long-lived operation run under supervision, such as a watch loop or a heartbeat.  A Task should
+// run until the shutdown channel is closed.  Returning an error, or panicking, is a failure which may
+// result in a restart.  Returning nil indicates the task completed normally.
+type Task func(shutdown <-chan struct{}) error
+
+// RestartPolicy determines whether and when a supervised Task is restarted
+type RestartPolicy struct {
+	// Always restarts a task even when it completes normally.  By default, only failures are restarted.
+	Always bool
+
+	// MaxRestarts is the maximum number of times a task is restarted.  If zero, there is no limit.
+	// If negative, the task is never restarted.
+	MaxRestarts int
+
+	// InitialBackoff is the wait before the first restart.  Each consecutive failure doubles the wait,
+	// up to MaxBackoff.  A restart never waits less than MinRestartDelay, which is also the wait after a task
+	// completes normally or when this field is unset.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between restarts.  If unset, there is no cap.
+	MaxBackoff time.Duration
+}
+
+// nextBackoff computes the wait that follows the given one
+func (p RestartPolicy) nextBackoff(current time.Duration) time.Duration {
+	if current < 1 {
+		return p.InitialBackoff
+	}
+
+	next := current * 2
+	if p.MaxBackoff > 0 && next > p.MaxBackoff {
+		next = p.MaxBackoff
+	}
+
+	return next
+}
+
+// SupervisorOptions configures a Supervisor
+type SupervisorOptions struct {
+	// Logger is the go-kit logger to use.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger
+
+	// Restarts is an optional counter incremented each time a task is restarted.  It is labeled with TaskLabel.
+	Restarts metrics.Counter
+
+	// After is used to wait out backoffs.  If unset, time.After is used.
+	After func(time.Duration) <-chan time.Time
+}
+
+// supervisedTask is a Task along with its policy and restart count
+type supervisedTask struct {
+	name     string
+	policy   RestartPolicy
+	task     Task
+	restarts int
+}
+
+// Supervisor runs Tasks in goroutines, restarting them according to their RestartPolicy.  This centralizes
+// the recovery loops that long-lived goroutines would otherwise each implement.  Supervisor implements Runnable.
+type Supervisor struct {
+	logger   log.Logger
+	restarts metrics.Counter
+	after    func(time.Duration) <-chan time.Time
+
+	lock    sync.Mutex
+	tasks   map[string]*supervisedTask
+	started bool
+}
+
+// NewSupervisor constructs a Supervisor.  Tasks must be added prior to running the Supervisor.
+func NewSupervisor(o SupervisorOptions) *Supervisor {
+	s := &Supervisor{
+		logger:   o.Logger,
+		restarts: o.Restarts,
+		after:    o.After,
+		tasks:    make(map[string]*supervisedTask),
+	}
+
+	if s.logger == nil {
+		s.logger = logging.DefaultLogger()
+	}
+
+	if s.restarts == nil {
+		s.restarts = discard.NewCounter()
+	}
+
+	if s.after == nil {
+		s.after = time.After
+	}
+
+	return s
+}
+
+// Add registers a named Task.  This method panics if a task with the same name already exists, or if the
+// Supervisor has already been run.
+func (s *Supervisor) Add(name string, p RestartPolicy, t Task) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.started {
+		panic("tasks cannot be added to a supervisor that has been run")
+	}
+
+	if _, ok := s.tasks[name]; ok {
+		panic(fmt.Errorf("duplicate task name: %s", name))
+	}
+
+	s.tasks[name] = &supervisedTask{name: name, policy: p, task: t}
+}
+
+// Restarts returns the number of times the named task has been restarted
+func (s *Supervisor) Restarts(name string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if st, ok := s.tasks[name]; ok {
+		return st.restarts
+	}
+
+	return 0
+}
+
+// Run starts each task in its own goroutine.  This method is idempotent.
+func (s *Supervisor) Run(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.started {
+		return nil
+	}
+
+	s.started = true
+	for _, st := range s.tasks {
+		waitGroup.Add(1)
+		go s.supervise(waitGroup, shutdown, st)
+	}
+
+	return nil
+}
+
+// runOnce executes a task, converting any panic into an error
+func runOnce(t Task, shutdown <-chan struct{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+
+	return t(shutdown)
+}
+
+// supervise runs a single task until it should no longer be restarted
+func (s *Supervisor) supervise(waitGroup *sync.WaitGroup, shutdown <-chan struct{}, st *supervisedTask) {
+	defer waitGroup.Done()
+
+	var (
+		logger  = log.With(s.logger, TaskLabel, st.name)
+		counter = s.restarts.With(TaskLabel, st.name)
+		backoff time.Duration
+	)
+
+	for {
+		err := runOnce(st.task, shutdown)
+
+		select {
+		case <-shutdown:
+			return
+		default:
+		}
+
+		if err == nil {
+			backoff = 0
+			if !st.policy.Always {
+				logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "task completed")
+				return
+			}
+		} else {
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "task failed", logging.ErrorKey(), err)
+		}
+
+		s.lock.Lock()
+		canRestart := st.policy.MaxRestarts == 0 || (st.policy.MaxRestarts > 0 && st.restarts < st.policy.MaxRestarts)
+		if canRestart {
+			st.restarts++
+		}
+
+		s.lock.Unlock()
+
+		if !canRestart {
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "task will not be restarted")
+			return
+		}
+
+		if err != nil {
+			backoff = st.policy.nextBackoff(backoff)
+		}
+
+		wait := backoff
+		if wait < MinRestartDelay {
+			wait = MinRestartDelay
+		}
+
+		select {
+		case <-shutdown:
+			return
+		case <-s.after(wait):
+		}
+
+		counter.Add(1.0)
+		logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "restarting task", "backoff", wait)
+	}
+}
@@ -0,0 +1,220 @@
+package concurrent
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// immediateAfter is a time.After substitute that records the requested waits and returns immediately
+type immediateAfter struct {
+	lock  sync.Mutex
+	waits []time.Duration
+}
+
+func (ia *immediateAfter) after(d time.Duration) <-chan time.Time {
+	ia.lock.Lock()
+	ia.waits = append(ia.waits, d)
+	ia.lock.Unlock()
+
+	c := make(chan time.Time, 1)
+	c <- time.Now()
+	return c
+}
+
+func (ia *immediateAfter) get() []time.Duration {
+	ia.lock.Lock()
+	defer ia.lock.Unlock()
+	return append([]time.Duration{}, ia.waits...)
+}
+
+func TestRestartPolicyNextBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Zero(RestartPolicy{}.nextBackoff(0))
+
+	p := RestartPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(time.Second, p.nextBackoff(0))
+	assert.Equal(2*time.Second, p.nextBackoff(time.Second))
+	assert.Equal(4*time.Second, p.nextBackoff(2*time.Second))
+	assert.Equal(5*time.Second, p.nextBackoff(4*time.Second))
+
+	p = RestartPolicy{InitialBackoff: time.Second}
+	assert.Equal(16*time.Second, p.nextBackoff(8*time.Second))
+}
+
+func TestSupervisorAdd(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		s      = NewSupervisor(SupervisorOptions{})
+		task   = func(<-chan struct{}) error { return nil }
+	)
+
+	s.Add("test", RestartPolicy{}, task)
+	assert.Panics(func() { s.Add("test", RestartPolicy{}, task) })
+
+	waitGroup := new(sync.WaitGroup)
+	assert.NoError(s.Run(waitGroup, make(chan struct{})))
+	assert.NoError(s.Run(waitGroup, make(chan struct{})))
+	assert.Panics(func() { s.Add("another", RestartPolicy{}, task) })
+	waitGroup.Wait()
+
+	assert.Zero(s.Restarts("test"))
+	assert.Zero(s.Restarts("nosuch"))
+}
+
+func TestSupervisorMaxRestarts(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		restarts = newTestCounter()
+		after    = new(immediateAfter)
+		s        = NewSupervisor(SupervisorOptions{
+			Logger:   logging.NewTestLogger(nil, t),
+			Restarts: restarts,
+			After:    after.after,
+		})
+
+		lock  sync.Mutex
+		calls int
+	)
+
+	s.Add(
+		"failing",
+		RestartPolicy{MaxRestarts: 3, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second},
+		func(<-chan struct{}) error {
+			lock.Lock()
+			defer lock.Unlock()
+			calls++
+			if calls%2 == 0 {
+				panic("expected")
+			}
+
+			return errors.New("expected")
+		},
+	)
+
+	waitGroup := new(sync.WaitGroup)
+	s.Run(waitGroup, make(chan struct{}))
+	waitGroup.Wait()
+
+	assert.Equal(4, calls)
+	assert.Equal(3, s.Restarts("failing"))
+	assert.Equal([]time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, after.get())
+
+	assert.Equal(3.0, restarts.value(TaskLabel, "failing"))
+}
+
+func TestSupervisorMinRestartDelay(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		after  = new(immediateAfter)
+		s      = NewSupervisor(SupervisorOptions{Logger: logging.NewTestLogger(nil, t), After: after.after})
+		calls  int
+	)
+
+	s.Add("failing", RestartPolicy{MaxRestarts: 2}, func(<-chan struct{}) error {
+		calls++
+		return errors.New("expected")
+	})
+
+	waitGroup := new(sync.WaitGroup)
+	s.Run(waitGroup, make(chan struct{}))
+	waitGroup.Wait()
+
+	assert.Equal(3, calls)
+	assert.Equal([]time.Duration{MinRestartDelay, MinRestartDelay}, after.get())
+}
+
+func TestSupervisorNeverRestart(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		s      = NewSupervisor(SupervisorOptions{Logger: logging.NewTestLogger(nil, t)})
+		calls  int
+	)
+
+	s.Add("once", RestartPolicy{MaxRestarts: -1}, func(<-chan struct{}) error {
+		calls++
+		return errors.New("expected")
+	})
+
+	waitGroup := new(sync.WaitGroup)
+	s.Run(waitGroup, make(chan struct{}))
+	waitGroup.Wait()
+
+	assert.Equal(1, calls)
+	assert.Zero(s.Restarts("once"))
+}
+
+func TestSupervisorCompletion(t *testing.T) {
+	t.Run("NotRestarted", func(t *testing.T) {
+		var (
+			s     = NewSupervisor(SupervisorOptions{Logger: logging.NewTestLogger(nil, t)})
+			calls int
+		)
+
+		s.Add("completes", RestartPolicy{}, func(<-chan struct{}) error {
+			calls++
+			return nil
+		})
+
+		waitGroup := new(sync.WaitGroup)
+		s.Run(waitGroup, make(chan struct{}))
+		waitGroup.Wait()
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Always", func(t *testing.T) {
+		var (
+			after = new(immediateAfter)
+			s     = NewSupervisor(SupervisorOptions{Logger: logging.NewTestLogger(nil, t), After: after.after})
+			calls int
+		)
+
+		s.Add("completes", RestartPolicy{Always: true, MaxRestarts: 2, InitialBackoff: time.Second}, func(<-chan struct{}) error {
+			calls++
+			return nil
+		})
+
+		waitGroup := new(sync.WaitGroup)
+		s.Run(waitGroup, make(chan struct{}))
+		waitGroup.Wait()
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, 2, s.Restarts("completes"))
+
+		// clean completions do not back off, but still wait the minimum delay
+		assert.Equal(t, []time.Duration{MinRestartDelay, MinRestartDelay}, after.get())
+	})
+}
+
+func TestSupervisorShutdown(t *testing.T) {
+	var (
+		require  = require.New(t)
+		s        = NewSupervisor(SupervisorOptions{Logger: logging.NewTestLogger(nil, t)})
+		started  = make(chan struct{})
+		shutdown = make(chan struct{})
+	)
+
+	s.Add("longRunning", RestartPolicy{Always: true}, func(shutdown <-chan struct{}) error {
+		close(started)
+		<-shutdown
+		return nil
+	})
+
+	waitGroup := new(sync.WaitGroup)
+	require.NoError(s.Run(waitGroup, shutdown))
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		require.Fail("The task did not start")
+	}
+
+	close(shutdown)
+	waitGroup.Wait()
+	require.Zero(s.Restarts("longRunning"))
+}
@@ -0,0 +1,122 @@
+package convey
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The well-known keys within device convey data
+const (
+	FirmwareNameKey         = "fw-name"
+	HardwareModelKey        = "hw-model"
+	HardwareManufacturerKey = "hw-manufacturer"
+	HardwareSerialNumberKey = "hw-serial-number"
+	LastRebootReasonKey     = "hw-last-reboot-reason"
+	ProtocolKey             = "webpa-protocol"
+	InterfaceUsedKey        = "webpa-interface-used"
+	BootTimeKey             = "boot-time"
+)
+
+// Interface provides typed access to the well-known fields of device convey data.  String values
+// are trimmed of surrounding whitespace, and a missing or empty field produces the empty string.
+type Interface interface {
+	FirmwareName() string
+	HardwareModel() string
+	HardwareManufacturer() string
+	HardwareSerialNumber() string
+	LastRebootReason() string
+	Protocol() string
+	InterfaceUsed() string
+
+	// BootTime returns the time the device last booted.  The second return is false if the
+	// boot time is missing or cannot be interpreted as seconds since the epoch.
+	BootTime() (time.Time, bool)
+}
+
+var _ Interface = C(nil)
+
+// GetString returns the value of a field as a trimmed string.  Non-string scalar values are formatted
+// as strings, while missing fields and nested objects or arrays produce the empty string.
+func (c C) GetString(key string) string {
+	switch v := c[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case C, map[string]interface{}, []interface{}:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// GetStringOr is like GetString, but returns a default value when the field is missing or empty
+func (c C) GetStringOr(key, defaultValue string) string {
+	if v := c.GetString(key); len(v) > 0 {
+		return v
+	}
+
+	return defaultValue
+}
+
+// GetInt returns the value of a field as an integer.  Integral numbers and strings that parse as integers
+// are converted, while anything else results in false.
+func (c C) GetInt(key string) (int64, bool) {
+	switch v := c[key].(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v), true
+		}
+	case string:
+		if i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (c C) FirmwareName() string {
+	return c.GetString(FirmwareNameKey)
+}
+
+func (c C) HardwareModel() string {
+	return c.GetString(HardwareModelKey)
+}
+
+func (c C) HardwareManufacturer() string {
+	return c.GetString(HardwareManufacturerKey)
+}
+
+func (c C) HardwareSerialNumber() string {
+	return c.GetString(HardwareSerialNumberKey)
+}
+
+func (c C) LastRebootReason() string {
+	return c.GetString(LastRebootReasonKey)
+}
+
+func (c C) Protocol() string {
+	return c.GetString(ProtocolKey)
+}
+
+func (c C) InterfaceUsed() string {
+	return c.GetString(InterfaceUsedKey)
+}
+
+func (c C) BootTime() (time.Time, bool) {
+	seconds, ok := c.GetInt(BootTimeKey)
+	if !ok || seconds <= 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(seconds, 0).UTC(), true
+}
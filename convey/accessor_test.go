@@ -0,0 +1,115 @@
+package convey
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCGetString(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		c      = C{
+			"string":  "  value\t",
+			"empty":   "",
+			"integer": uint64(123),
+			"boolean": true,
+			"object":  C{"nested": "value"},
+			"array":   []interface{}{"value"},
+		}
+	)
+
+	assert.Equal("value", c.GetString("string"))
+	assert.Empty(c.GetString("empty"))
+	assert.Equal("123", c.GetString("integer"))
+	assert.Equal("true", c.GetString("boolean"))
+	assert.Empty(c.GetString("object"))
+	assert.Empty(c.GetString("array"))
+	assert.Empty(c.GetString("missing"))
+
+	assert.Equal("value", c.GetStringOr("string", "default"))
+	assert.Equal("default", c.GetStringOr("empty", "default"))
+	assert.Equal("default", c.GetStringOr("missing", "default"))
+
+	var nilC C
+	assert.Empty(nilC.GetString("string"))
+	assert.Equal("default", nilC.GetStringOr("string", "default"))
+}
+
+func TestCGetInt(t *testing.T) {
+	testData := []struct {
+		value      interface{}
+		expected   int64
+		expectedOK bool
+	}{
+		{nil, 0, false},
+		{int(-12), -12, true},
+		{int64(34), 34, true},
+		{uint64(56), 56, true},
+		{float64(78), 78, true},
+		{float64(7.5), 0, false},
+		{" 910 ", 910, true},
+		{"not a number", 0, false},
+		{true, 0, false},
+	}
+
+	for _, record := range testData {
+		t.Logf("%#v", record)
+		actual, ok := C{"key": record.value}.GetInt("key")
+		assert.Equal(t, record.expected, actual)
+		assert.Equal(t, record.expectedOK, ok)
+	}
+}
+
+func TestCInterface(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		encoded = base64.StdEncoding.EncodeToString([]byte(`{
+			"fw-name": "TG1682_2.1s1_PRODse",
+			"hw-model": " TG1682G ",
+			"hw-manufacturer": "ARRIS Group, Inc.",
+			"hw-serial-number": "123456789",
+			"hw-last-reboot-reason": "unknown",
+			"webpa-protocol": "PARODUS-2.0",
+			"webpa-interface-used": "erouter0",
+			"boot-time": 1530000000
+		}`))
+	)
+
+	c, err := ReadString(NewTranslator(nil), encoded)
+	require.NoError(err)
+
+	var i Interface = c
+	assert.Equal("TG1682_2.1s1_PRODse", i.FirmwareName())
+	assert.Equal("TG1682G", i.HardwareModel())
+	assert.Equal("ARRIS Group, Inc.", i.HardwareManufacturer())
+	assert.Equal("123456789", i.HardwareSerialNumber())
+	assert.Equal("unknown", i.LastRebootReason())
+	assert.Equal("PARODUS-2.0", i.Protocol())
+	assert.Equal("erouter0", i.InterfaceUsed())
+
+	bootTime, ok := i.BootTime()
+	assert.Equal(time.Unix(1530000000, 0).UTC(), bootTime)
+	assert.True(ok)
+
+	i = C{BootTimeKey: "1530000000"}
+	bootTime, ok = i.BootTime()
+	assert.Equal(time.Unix(1530000000, 0).UTC(), bootTime)
+	assert.True(ok)
+
+	for _, invalid := range []C{nil, {BootTimeKey: "yesterday"}, {BootTimeKey: uint64(0)}, {BootTimeKey: int64(-1)}} {
+		bootTime, ok = invalid.BootTime()
+		assert.True(bootTime.IsZero())
+		assert.False(ok)
+	}
+
+	i = C{}
+	assert.Empty(i.FirmwareName())
+	assert.Empty(i.HardwareModel())
+	assert.Empty(i.Protocol())
+}
@@ -0,0 +1,124 @@
+package convey
+
+import (
+	"compress/zlib"
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// ZlibPrefix is the byte that precedes zlib-compressed convey JSON
+	ZlibPrefix byte = 0x01
+
+	// ZstdPrefix is the byte that precedes zstd-compressed convey JSON
+	ZstdPrefix byte = 0x02
+
+	// DefaultMaxDecodedSize is the default limit on the size of convey JSON, after any decompression
+	DefaultMaxDecodedSize int64 = 64 * 1024
+
+	// zstdMaxMemory bounds the memory a zstd decoder will allocate, since a frame header may request
+	// an arbitrarily large window before any output is produced
+	zstdMaxMemory = 8 * 1024 * 1024
+)
+
+var (
+	// ErrUnsupportedCodec indicates that convey data was prefixed with a byte that did not identify any configured Codec
+	ErrUnsupportedCodec = errors.New("Unsupported convey compression codec")
+
+	// ErrDecodedSizeExceeded indicates that convey data exceeded the maximum allowed size once decoded
+	ErrDecodedSizeExceeded = errors.New("Convey data exceeds the maximum decoded size")
+)
+
+// Codec is a compression scheme for convey JSON.  Compressed convey data is the Codec's prefix byte followed
+// by the compressed JSON, with the whole being base64-encoded.  Uncompressed JSON never starts with a control
+// character, so prefixes are restricted to bytes below 0x20 that are not JSON whitespace.
+type Codec interface {
+	// Prefix is the byte that identifies this Codec
+	Prefix() byte
+
+	// NewReader produces a reader that decompresses the given source
+	NewReader(io.Reader) (io.ReadCloser, error)
+
+	// NewWriter produces a writer that compresses into the given destination.  The returned writer
+	// must be closed to flush any pending output.
+	NewWriter(io.Writer) (io.WriteCloser, error)
+}
+
+type zlibCodec struct{}
+
+func (zlibCodec) Prefix() byte {
+	return ZlibPrefix
+}
+
+func (zlibCodec) NewReader(source io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(source)
+}
+
+func (zlibCodec) NewWriter(destination io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(destination), nil
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) Prefix() byte {
+	return ZstdPrefix
+}
+
+func (zstdCodec) NewReader(source io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(source, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(zstdMaxMemory))
+	if err != nil {
+		return nil, err
+	}
+
+	return decoder.IOReadCloser(), nil
+}
+
+func (zstdCodec) NewWriter(destination io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(destination, zstd.WithEncoderConcurrency(1))
+}
+
+var (
+	// Zlib is the Codec for zlib compression
+	Zlib Codec = zlibCodec{}
+
+	// Zstd is the Codec for zstd compression
+	Zstd Codec = zstdCodec{}
+)
+
+// isPrefix tests if a byte is in the range reserved for Codec prefixes
+func isPrefix(b byte) bool {
+	return b < 0x20 && b != '\t' && b != '\n' && b != '\r'
+}
+
+// TranslatorOption configures a Translator
+type TranslatorOption func(*translator)
+
+// WithCodecs sets the Codecs a Translator accepts when reading.  By default, Zlib and Zstd are accepted.
+// Passing no Codecs restricts a Translator to uncompressed convey data.
+func WithCodecs(codecs ...Codec) TranslatorOption {
+	return func(t *translator) {
+		t.codecs = make(map[byte]Codec, len(codecs))
+		for _, c := range codecs {
+			t.codecs[c.Prefix()] = c
+		}
+	}
+}
+
+// WithWriteCodec sets the Codec a Translator uses to compress output.  By default, output is not compressed.
+func WithWriteCodec(c Codec) TranslatorOption {
+	return func(t *translator) {
+		t.writeCodec = c
+	}
+}
+
+// WithMaxDecodedSize sets the limit on the size of convey JSON after base64 decoding and any decompression.
+// Nonpositive values leave DefaultMaxDecodedSize in place.
+func WithMaxDecodedSize(n int64) TranslatorOption {
+	return func(t *translator) {
+		if n > 0 {
+			t.maxDecodedSize = n
+		}
+	}
+}
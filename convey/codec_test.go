@@ -0,0 +1,111 @@
+package convey
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCodecRoundTrip(t *testing.T, c Codec) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		writer = NewTranslator(nil, WithWriteCodec(c))
+		reader = NewTranslator(nil)
+		source = C{"hw-model": "TG1682", "fw-name": "TG1682_2.1", "nested": C{"value": uint64(1234)}}
+	)
+
+	encoded, err := WriteString(writer, source)
+	require.NoError(err)
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(err)
+	require.NotEmpty(raw)
+	assert.Equal(c.Prefix(), raw[0])
+
+	actual, err := ReadString(reader, encoded)
+	assert.Equal(source, actual)
+	assert.NoError(err)
+
+	// a translator that doesn't accept the codec rejects the data
+	actual, err = ReadString(NewTranslator(nil, WithCodecs()), encoded)
+	assert.Empty(actual)
+	assert.Equal(ErrUnsupportedCodec, err)
+}
+
+func TestCodecs(t *testing.T) {
+	t.Run("Zlib", func(t *testing.T) { testCodecRoundTrip(t, Zlib) })
+	t.Run("Zstd", func(t *testing.T) { testCodecRoundTrip(t, Zstd) })
+}
+
+func TestIsPrefix(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(isPrefix(ZlibPrefix))
+	assert.True(isPrefix(ZstdPrefix))
+	assert.False(isPrefix('{'))
+	assert.False(isPrefix(' '))
+	assert.False(isPrefix('\t'))
+	assert.False(isPrefix('\n'))
+	assert.False(isPrefix('\r'))
+}
+
+func TestTranslatorUnknownPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	actual, err := ReadString(NewTranslator(nil), base64.StdEncoding.EncodeToString([]byte("\x07{}")))
+	assert.Empty(actual)
+	assert.Equal(ErrUnsupportedCodec, err)
+}
+
+func TestTranslatorMaxDecodedSize(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		// highly compressible data, which expands well beyond the limit
+		source = C{"padding": strings.Repeat("x", 10000)}
+	)
+
+	for _, c := range []Codec{nil, Zlib, Zstd} {
+		var (
+			options = []TranslatorOption{WithMaxDecodedSize(1000)}
+			output  bytes.Buffer
+		)
+
+		if c != nil {
+			options = append(options, WithWriteCodec(c))
+		}
+
+		translator := NewTranslator(nil, options...)
+		require.NoError(translator.WriteTo(&output, source))
+		if c != nil {
+			assert.True(output.Len() < 1000)
+		}
+
+		actual, err := translator.ReadFrom(&output)
+		assert.Empty(actual)
+		assert.Equal(ErrDecodedSizeExceeded, err)
+	}
+
+	// nonpositive limits leave the default in place
+	translator := NewTranslator(nil, WithMaxDecodedSize(-1))
+	encoded, err := WriteString(translator, source)
+	require.NoError(err)
+
+	actual, err := ReadString(translator, encoded)
+	assert.Equal(source, actual)
+	assert.NoError(err)
+}
+
+func TestTranslatorEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	actual, err := ReadString(NewTranslator(nil), "")
+	assert.Empty(actual)
+	assert.Error(err)
+}
@@ -1,9 +1,11 @@
 package convey
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/base64"
 	"io"
+	"io/ioutil"
 	"reflect"
 	"strings"
 
@@ -41,41 +43,95 @@ type Translator interface {
 
 // translator is the internal Translator implementation
 type translator struct {
-	encoding *base64.Encoding
+	encoding       *base64.Encoding
+	codecs         map[byte]Codec
+	writeCodec     Codec
+	maxDecodedSize int64
 }
 
 // NewTranslator produces a Translator which uses the specified base64 encoding.  If
-// the encoding is nil, base64.StdEncoding is used.
-func NewTranslator(encoding *base64.Encoding) Translator {
+// the encoding is nil, base64.StdEncoding is used.  By default, the returned Translator reads
+// zlib and zstd compressed convey data in addition to plain JSON, but writes plain JSON.
+func NewTranslator(encoding *base64.Encoding, options ...TranslatorOption) Translator {
 	if encoding == nil {
 		encoding = base64.StdEncoding
 	}
 
-	return &translator{
-		encoding: encoding,
+	t := &translator{
+		encoding:       encoding,
+		maxDecodedSize: DefaultMaxDecodedSize,
 	}
+
+	WithCodecs(Zlib, Zstd)(t)
+	for _, o := range options {
+		o(t)
+	}
+
+	return t
 }
 
 func (t *translator) ReadFrom(source io.Reader) (C, error) {
-	decoder := codec.NewDecoder(
-		base64.NewDecoder(t.encoding, source),
-		conveyHandle,
-	)
+	decoded := bufio.NewReader(base64.NewDecoder(t.encoding, source))
+	prefix, err := decoded.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload io.Reader = decoded
+	if isPrefix(prefix[0]) {
+		c, ok := t.codecs[prefix[0]]
+		if !ok {
+			return nil, ErrUnsupportedCodec
+		}
+
+		decoded.Discard(1)
+		decompressor, err := c.NewReader(decoded)
+		if err != nil {
+			return nil, err
+		}
+
+		defer decompressor.Close()
+		payload = decompressor
+	}
+
+	// read one byte past the limit, so that oversized data can be detected without decompressing all of it
+	data, err := ioutil.ReadAll(io.LimitReader(payload, t.maxDecodedSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > t.maxDecodedSize {
+		return nil, ErrDecodedSizeExceeded
+	}
 
 	var convey C
-	err := decoder.Decode(&convey)
+	err = codec.NewDecoderBytes(data, conveyHandle).Decode(&convey)
 	return convey, err
 }
 
 func (t *translator) WriteTo(destination io.Writer, source C) error {
 	encoder := base64.NewEncoder(t.encoding, destination)
-	err := codec.NewEncoder(
-		encoder,
-		conveyHandle,
-	).Encode(source)
+	defer encoder.Close()
+
+	if t.writeCodec == nil {
+		return codec.NewEncoder(encoder, conveyHandle).Encode(source)
+	}
+
+	if _, err := encoder.Write([]byte{t.writeCodec.Prefix()}); err != nil {
+		return err
+	}
+
+	compressor, err := t.writeCodec.NewWriter(encoder)
+	if err != nil {
+		return err
+	}
+
+	if err := codec.NewEncoder(compressor, conveyHandle).Encode(source); err != nil {
+		compressor.Close()
+		return err
+	}
 
-	encoder.Close()
-	return err
+	return compressor.Close()
 }
 
 // ReadString uses the supplied Translator to extract a C instance from an arbitrary string
@@ -0,0 +1,274 @@
+package convey
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+const (
+	// FieldLabel is the metric label for the convey field that failed validation
+	FieldLabel = "field"
+
+	// ReasonLabel is the metric label for the reason a convey field failed validation
+	ReasonLabel = "reason"
+
+	// RequiredReason indicates that a required field was missing
+	RequiredReason = "required"
+
+	// TypeReason indicates that a field had the wrong JSON type
+	TypeReason = "type"
+
+	// EnumReason indicates that a field's value was not one of the allowed values
+	EnumReason = "enum"
+)
+
+// The JSON types supported by a Schema
+const (
+	StringType  = "string"
+	NumberType  = "number"
+	IntegerType = "integer"
+	BooleanType = "boolean"
+	ObjectType  = "object"
+	ArrayType   = "array"
+)
+
+// Schema is the subset of JSON Schema that is useful for describing convey data.  A schema may be expressed
+// as a standard JSON Schema document, e.g.:
+//
+//   {
+//     "type": "object",
+//     "required": ["hw-model", "fw-name"],
+//     "properties": {
+//       "hw-model": {"type": "string", "enum": ["TG1682", "TG3482"]},
+//       "fw-name": {"type": "string"}
+//     }
+//   }
+//
+// Keywords other than type, required, properties, and enum are ignored.
+type Schema struct {
+	// Type is the JSON type of the value.  If unset, any type is allowed.
+	Type string `json:"type,omitempty"`
+
+	// Required lists the properties that must be present when the value is an object
+	Required []string `json:"required,omitempty"`
+
+	// Properties are the schemas for the named properties of an object.  Properties not listed here are allowed.
+	Properties map[string]*Schema `json:"properties,omitempty"`
+
+	// Enum is the set of allowed values.  If empty, any value is allowed.
+	Enum []interface{} `json:"enum,omitempty"`
+}
+
+// ReadSchema parses a JSON Schema document
+func ReadSchema(source io.Reader) (*Schema, error) {
+	s := new(Schema)
+	if err := json.NewDecoder(source).Decode(s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Validate checks a convey map against this schema.  If validation fails, the returned error is
+// a ValidationError describing every field that failed.  A nil Schema allows anything.
+func (s *Schema) Validate(c C) error {
+	var failures ValidationError
+	s.validate("", c, &failures)
+	if len(failures) > 0 {
+		return failures
+	}
+
+	return nil
+}
+
+func (s *Schema) validate(field string, v interface{}, failures *ValidationError) {
+	if s == nil {
+		return
+	}
+
+	if len(s.Type) > 0 && !hasType(v, s.Type) {
+		*failures = append(*failures, FieldError{Field: field, Reason: TypeReason, Value: v})
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(v, s.Enum) {
+		*failures = append(*failures, FieldError{Field: field, Reason: EnumReason, Value: v})
+	}
+
+	object, ok := asObject(v)
+	if !ok {
+		return
+	}
+
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			*failures = append(*failures, FieldError{Field: join(field, name), Reason: RequiredReason})
+		}
+	}
+
+	// visit properties in a predictable order, so that failures are reported consistently
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		if value, ok := object[name]; ok {
+			s.Properties[name].validate(join(field, name), value, failures)
+		}
+	}
+}
+
+// join produces the dotted path of a nested field
+func join(parent, name string) string {
+	if len(parent) == 0 {
+		return name
+	}
+
+	return parent + "." + name
+}
+
+func asObject(v interface{}) (map[string]interface{}, bool) {
+	switch object := v.(type) {
+	case C:
+		return object, true
+	case map[string]interface{}:
+		return object, true
+	default:
+		return nil, false
+	}
+}
+
+// asNumber converts any of the numeric types produced by decoding convey JSON into a float64
+func asNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func hasType(v interface{}, t string) bool {
+	switch t {
+	case StringType:
+		_, ok := v.(string)
+		return ok
+
+	case NumberType:
+		_, ok := asNumber(v)
+		return ok
+
+	case IntegerType:
+		n, ok := asNumber(v)
+		return ok && n == float64(int64(n))
+
+	case BooleanType:
+		_, ok := v.(bool)
+		return ok
+
+	case ObjectType:
+		_, ok := asObject(v)
+		return ok
+
+	case ArrayType:
+		_, ok := v.([]interface{})
+		return ok
+
+	default:
+		return false
+	}
+}
+
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, allowed := range enum {
+		if an, ok := asNumber(allowed); ok {
+			if vn, ok := asNumber(v); ok && an == vn {
+				return true
+			}
+		} else if allowed == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FieldError describes a single convey field that failed validation
+type FieldError struct {
+	Field  string
+	Reason string
+	Value  interface{}
+}
+
+func (fe FieldError) Error() string {
+	switch fe.Reason {
+	case RequiredReason:
+		return fmt.Sprintf("missing required field %s", fe.Field)
+	case TypeReason:
+		return fmt.Sprintf("field %s has the wrong type: %v", fe.Field, fe.Value)
+	case EnumReason:
+		return fmt.Sprintf("field %s has a disallowed value: %v", fe.Field, fe.Value)
+	default:
+		return fmt.Sprintf("field %s is invalid: %s", fe.Field, fe.Reason)
+	}
+}
+
+// ValidationError is the error returned when a convey map does not conform to a Schema
+type ValidationError []FieldError
+
+func (ve ValidationError) Error() string {
+	messages := make([]string, len(ve))
+	for i, fe := range ve {
+		messages[i] = fe.Error()
+	}
+
+	return "Invalid convey data: " + strings.Join(messages, ", ")
+}
+
+// Validator checks decoded convey data.  Instances of Validator are safe for concurrent usage.
+type Validator interface {
+	Validate(C) error
+}
+
+// ValidatorFunc is a function type that implements Validator
+type ValidatorFunc func(C) error
+
+func (vf ValidatorFunc) Validate(c C) error {
+	return vf(c)
+}
+
+// NewValidator produces a Validator that checks convey data against a schema and increments a counter,
+// labeled with FieldLabel and ReasonLabel, for each field that fails.  If failures is nil, no metrics are recorded.
+func NewValidator(s *Schema, failures metrics.Counter) Validator {
+	if failures == nil {
+		failures = discard.NewCounter()
+	}
+
+	return ValidatorFunc(func(c C) error {
+		err := s.Validate(c)
+		if ve, ok := err.(ValidationError); ok {
+			for _, fe := range ve {
+				failures.With(FieldLabel, fe.Field, ReasonLabel, fe.Reason).Add(1.0)
+			}
+		}
+
+		return err
+	})
+}
@@ -0,0 +1,159 @@
+package convey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["hw-model", "fw-name"],
+	"properties": {
+		"hw-model": {"type": "string", "enum": ["TG1682", "TG3482"]},
+		"fw-name": {"type": "string"},
+		"boot-time": {"type": "integer"},
+		"interfaces": {"type": "array"},
+		"location": {
+			"type": "object",
+			"required": ["region"],
+			"properties": {
+				"region": {"type": "string"},
+				"priority": {"type": "number", "enum": [1, 2, 3]}
+			}
+		}
+	}
+}`
+
+func TestReadSchema(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	s, err := ReadSchema(strings.NewReader(testSchema))
+	require.NoError(err)
+	require.NotNil(s)
+
+	assert.Equal(ObjectType, s.Type)
+	assert.Equal([]string{"hw-model", "fw-name"}, s.Required)
+	assert.Len(s.Properties, 5)
+	assert.Equal(StringType, s.Properties["hw-model"].Type)
+	assert.Equal([]interface{}{"TG1682", "TG3482"}, s.Properties["hw-model"].Enum)
+
+	s, err = ReadSchema(strings.NewReader("this is not JSON"))
+	assert.Nil(s)
+	assert.Error(err)
+}
+
+func TestSchemaValidate(t *testing.T) {
+	s, err := ReadSchema(strings.NewReader(testSchema))
+	require.NoError(t, err)
+
+	testData := []struct {
+		convey   C
+		expected ValidationError
+	}{
+		{
+			C{"hw-model": "TG1682", "fw-name": "TG1682_2.1", "boot-time": int64(1530000000), "interfaces": []interface{}{"erouter0"}},
+			nil,
+		},
+		{
+			// decoded convey JSON produces unsigned integers and nested C maps
+			C{"hw-model": "TG3482", "fw-name": "TG3482_1.0", "boot-time": uint64(1530000000), "location": C{"region": "east", "priority": uint64(2)}},
+			nil,
+		},
+		{
+			C{},
+			ValidationError{
+				{Field: "hw-model", Reason: RequiredReason},
+				{Field: "fw-name", Reason: RequiredReason},
+			},
+		},
+		{
+			C{"hw-model": "unknown", "fw-name": 123, "boot-time": 1.5},
+			ValidationError{
+				{Field: "boot-time", Reason: TypeReason, Value: 1.5},
+				{Field: "fw-name", Reason: TypeReason, Value: 123},
+				{Field: "hw-model", Reason: EnumReason, Value: "unknown"},
+			},
+		},
+		{
+			C{"hw-model": "TG1682", "fw-name": "TG1682_2.1", "interfaces": "erouter0", "location": C{"priority": 7.0}},
+			ValidationError{
+				{Field: "interfaces", Reason: TypeReason, Value: "erouter0"},
+				{Field: "location.region", Reason: RequiredReason},
+				{Field: "location.priority", Reason: EnumReason, Value: 7.0},
+			},
+		},
+		{
+			C{"hw-model": "TG1682", "fw-name": "TG1682_2.1", "location": "east"},
+			ValidationError{
+				{Field: "location", Reason: TypeReason, Value: "east"},
+			},
+		},
+	}
+
+	for i, record := range testData {
+		t.Logf("%d: %v", i, record.convey)
+		err := s.Validate(record.convey)
+		if len(record.expected) == 0 {
+			assert.NoError(t, err)
+			continue
+		}
+
+		assert.Equal(t, record.expected, err)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), record.expected[0].Field)
+		}
+	}
+}
+
+func TestSchemaValidateNil(t *testing.T) {
+	var s *Schema
+	assert.NoError(t, s.Validate(C{"anything": "goes"}))
+}
+
+func TestFieldError(t *testing.T) {
+	assert := assert.New(t)
+	assert.Contains(FieldError{Field: "fw-name", Reason: RequiredReason}.Error(), "fw-name")
+	assert.Contains(FieldError{Field: "fw-name", Reason: TypeReason, Value: 123}.Error(), "123")
+	assert.Contains(FieldError{Field: "hw-model", Reason: EnumReason, Value: "unknown"}.Error(), "unknown")
+	assert.Contains(FieldError{Field: "hw-model", Reason: "custom"}.Error(), "custom")
+}
+
+func TestNewValidator(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		provider = xmetricstest.NewProvider(nil, func() []xmetrics.Metric {
+			return []xmetrics.Metric{
+				{Name: "failures", Type: "counter", LabelNames: []string{FieldLabel, ReasonLabel}},
+			}
+		})
+	)
+
+	s, err := ReadSchema(strings.NewReader(testSchema))
+	require.NoError(err)
+
+	v := NewValidator(s, provider.NewCounter("failures"))
+	require.NotNil(v)
+
+	assert.NoError(v.Validate(C{"hw-model": "TG1682", "fw-name": "TG1682_2.1"}))
+	assert.Error(v.Validate(C{"hw-model": "unknown"}))
+	assert.Error(v.Validate(C{"hw-model": "unknown"}))
+
+	provider.Expect("failures", FieldLabel, "hw-model", ReasonLabel, EnumReason)(xmetricstest.Value(2.0))
+	provider.Expect("failures", FieldLabel, "fw-name", ReasonLabel, RequiredReason)(xmetricstest.Value(2.0))
+	provider.AssertExpectations(t)
+
+	// a nil counter and schema are allowed
+	v = NewValidator(nil, nil)
+	require.NotNil(v)
+	assert.NoError(v.Validate(C{}))
+}
@@ -0,0 +1,80 @@
+package device
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Close categories sent to devices in close frames.  A device can use the category to choose its reconnect
+// backoff without having to understand every individual disconnect reason.
+const (
+	// CloseCategoryDrain indicates the server is shedding the connection, e.g. during a rehash, an administrative
+	// disconnect, or when the server has reached its device limit.  The device should reconnect, typically after
+	// a short randomized delay.
+	CloseCategoryDrain = "drain"
+
+	// CloseCategoryPolicy indicates the connection violated a server policy, such as a duplicate device ID
+	// or an oversized message.  Reconnecting immediately is likely to produce the same result.
+	CloseCategoryPolicy = "policy"
+
+	// CloseCategoryError indicates the connection failed due to an error.  The device should reconnect with
+	// its normal backoff.
+	CloseCategoryError = "error"
+)
+
+// closeFrameTimeout bounds the time spent writing a close frame to a device that is being disconnected
+const closeFrameTimeout = 5 * time.Second
+
+// closeReason is the websocket close code and category associated with a disconnect reason
+type closeReason struct {
+	code     int
+	category string
+}
+
+// closeReasons maps each server-initiated disconnect reason onto what is sent to the device
+var closeReasons = map[string]closeReason{
+	ReasonDisconnectRequested: {websocket.CloseGoingAway, CloseCategoryDrain},
+	ReasonDuplicate:           {websocket.ClosePolicyViolation, CloseCategoryPolicy},
+	ReasonDeviceLimitReached:  {websocket.CloseTryAgainLater, CloseCategoryDrain},
+	ReasonMessageTooLarge:     {websocket.CloseMessageTooBig, CloseCategoryPolicy},
+}
+
+// CloseCode returns the websocket close code sent to a device disconnected for the given reason, which
+// is one of the Reason constants.  Reasons without a more specific code, such as I/O errors, produce
+// websocket.CloseInternalServerErr.
+func CloseCode(reason string) int {
+	if cr, ok := closeReasons[reason]; ok {
+		return cr.code
+	}
+
+	return websocket.CloseInternalServerErr
+}
+
+// CloseText returns the close frame text sent to a device disconnected for the given reason.  The text has
+// the form category:reason, e.g. "drain:disconnect_requested", where category is one of the CloseCategory
+// constants.  Use ParseCloseText to take the text apart.
+func CloseText(reason string) string {
+	category := CloseCategoryError
+	if cr, ok := closeReasons[reason]; ok {
+		category = cr.category
+	}
+
+	return category + ":" + reason
+}
+
+// ParseCloseText extracts the category and reason from close frame text produced by CloseText.  If the text
+// is not in the expected format, the category is empty and the reason is the original text.
+func ParseCloseText(text string) (category, reason string) {
+	if i := strings.IndexByte(text, ':'); i > 0 {
+		return text[:i], text[i+1:]
+	}
+
+	return "", text
+}
+
+// FormatCloseMessage produces the websocket close frame payload for a device disconnected for the given reason
+func FormatCloseMessage(reason string) []byte {
+	return websocket.FormatCloseMessage(CloseCode(reason), CloseText(reason))
+}
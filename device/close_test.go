@@ -0,0 +1,63 @@
+package device
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseReasons(t *testing.T) {
+	testData := []struct {
+		reason           string
+		expectedCode     int
+		expectedCategory string
+	}{
+		{ReasonDisconnectRequested, websocket.CloseGoingAway, CloseCategoryDrain},
+		{ReasonDuplicate, websocket.ClosePolicyViolation, CloseCategoryPolicy},
+		{ReasonDeviceLimitReached, websocket.CloseTryAgainLater, CloseCategoryDrain},
+		{ReasonMessageTooLarge, websocket.CloseMessageTooBig, CloseCategoryPolicy},
+		{ReasonReadError, websocket.CloseInternalServerErr, CloseCategoryError},
+		{ReasonWriteError, websocket.CloseInternalServerErr, CloseCategoryError},
+		{"", websocket.CloseInternalServerErr, CloseCategoryError},
+	}
+
+	for _, record := range testData {
+		t.Logf("%#v", record)
+
+		var (
+			assert = assert.New(t)
+			text   = CloseText(record.reason)
+		)
+
+		assert.Equal(record.expectedCode, CloseCode(record.reason))
+		assert.Equal(record.expectedCategory+":"+record.reason, text)
+
+		category, reason := ParseCloseText(text)
+		assert.Equal(record.expectedCategory, category)
+		assert.Equal(record.reason, reason)
+
+		message := FormatCloseMessage(record.reason)
+		if assert.True(len(message) > 2) {
+			assert.Equal(uint16(record.expectedCode), binary.BigEndian.Uint16(message))
+			assert.Equal(text, string(message[2:]))
+		}
+	}
+}
+
+func TestParseCloseText(t *testing.T) {
+	assert := assert.New(t)
+
+	category, reason := ParseCloseText("drain:disconnect_requested")
+	assert.Equal(CloseCategoryDrain, category)
+	assert.Equal(ReasonDisconnectRequested, reason)
+
+	category, reason = ParseCloseText("no category")
+	assert.Empty(category)
+	assert.Equal("no category", reason)
+
+	category, reason = ParseCloseText(":leading")
+	assert.Empty(category)
+	assert.Equal(":leading", reason)
+}
@@ -112,6 +112,18 @@ func (ir *instrumentedReader) ReadMessage() (int, []byte, error) {
 	return messageType, data, err
 }
 
+// SetPongHandler decorates the given pong handler so that each pong is recorded in the statistics
+func (ir *instrumentedReader) SetPongHandler(h func(string) error) {
+	ir.ReadCloser.SetPongHandler(func(appData string) error {
+		ir.statistics.PongReceived()
+		if h != nil {
+			return h(appData)
+		}
+
+		return nil
+	})
+}
+
 func InstrumentReader(r ReadCloser, s Statistics) ReadCloser {
 	return &instrumentedReader{r, s}
 }
@@ -147,3 +159,19 @@ func (iw *instrumentedWriter) WritePreparedMessage(pm *websocket.PreparedMessage
 func InstrumentWriter(w WriteCloser, s Statistics) WriteCloser {
 	return &instrumentedWriter{w, s}
 }
+
+// InstrumentPinger decorates a ping closure, such as one created by NewPinger, so that each ping is recorded
+// in the statistics.  Together with a pong handler set through InstrumentReader, this tracks the round trip
+// time to the device.  The ping is recorded before it is written, since the device may answer before the
+// write returns.
+func InstrumentPinger(pinger func() error, s Statistics) func() error {
+	return func() error {
+		s.PingSent()
+		if err := pinger(); err != nil {
+			s.PingFailed()
+			return err
+		}
+
+		return nil
+	}
+}
@@ -2,6 +2,7 @@ package device
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -240,6 +241,84 @@ func TestInstrumentReader(t *testing.T) {
 	})
 }
 
+func TestInstrumentReaderPongHandler(t *testing.T) {
+	for _, withHandler := range []bool{true, false} {
+		t.Run(fmt.Sprintf("WithHandler=%t", withHandler), func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				current            = time.Now().UTC()
+				statistics         = NewStatistics(func() time.Time { return current }, current)
+				reader             = new(mockConnectionReader)
+				instrumentedReader = InstrumentReader(reader, statistics)
+
+				pongHandler   func(string) error
+				handlerCalled = false
+				handler       func(string) error
+			)
+
+			if withHandler {
+				handler = func(appData string) error {
+					handlerCalled = true
+					assert.Equal("pong data", appData)
+					return nil
+				}
+			}
+
+			reader.On("SetPongHandler", mock.MatchedBy(func(h func(string) error) bool { pongHandler = h; return h != nil })).Once()
+			instrumentedReader.SetPongHandler(handler)
+			require.NotNil(pongHandler)
+
+			statistics.PingSent()
+			current = current.Add(250 * time.Millisecond)
+			assert.NoError(pongHandler("pong data"))
+			assert.Equal(withHandler, handlerCalled)
+			assert.Equal(250*time.Millisecond, statistics.RTT())
+			assert.Equal(current, statistics.LastActivity())
+
+			reader.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInstrumentPinger(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		current       = time.Now().UTC()
+		statistics    = NewStatistics(func() time.Time { return current }, current)
+		expectedError = errors.New("expected")
+		pingError     error
+		pinger        = InstrumentPinger(func() error { return pingError }, statistics)
+	)
+
+	pingError = expectedError
+	assert.Equal(expectedError, pinger())
+	current = current.Add(time.Second)
+	statistics.PongReceived()
+	assert.Zero(statistics.RTT())
+
+	pingError = nil
+	assert.NoError(pinger())
+	current = current.Add(time.Second)
+	statistics.PongReceived()
+	assert.Equal(time.Second, statistics.RTT())
+
+	// a pong which arrives before the ping write returns is still measured
+	pinger = InstrumentPinger(
+		func() error {
+			current = current.Add(500 * time.Millisecond)
+			statistics.PongReceived()
+			return nil
+		},
+		statistics,
+	)
+
+	assert.NoError(pinger())
+	assert.Equal(500*time.Millisecond, statistics.RTT())
+}
+
 func TestInstrumentWriter(t *testing.T) {
 	t.Run("WriteMessage", func(t *testing.T) {
 		t.Run("Success", func(t *testing.T) {
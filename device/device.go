@@ -7,8 +7,10 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/Comcast/webpa-common/convey"
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
 )
 
 const (
@@ -19,9 +21,12 @@ const (
 // envelope is a tuple of a device Request and a send-only channel for errors.
 // The write pump goroutine will use the complete channel to communicate the result
 // of the write operation.
+//
+// If expires is not the zero time, the request must be written before that time.
 type envelope struct {
 	request  *Request
 	complete chan<- error
+	expires  time.Time
 }
 
 // Interface is the core type for this package.  It provides
@@ -70,14 +75,60 @@ type Interface interface {
 	//
 	// This method is synchronous.  If the request is of a type that should expect a response,
 	// that response is returned.  An error is returned if this device has been closed or
-	// if there were any I/O issues sending the request.
+	// if there were any I/O issues sending the request.  A *ClosedError is returned once the device
+	// has been closed, and a *QueueFullError if the request is cancelled before there is room
+	// for it in the device's message queue.
 	//
 	// Internally, the requests passed to this method are serviced by the write pump in
 	// the enclosing Manager instance.  The read pump will handle sending the response.
+	//
+	// If the Manager limits the number of unacknowledged messages in flight to a device, a request
+	// that would exceed the limit either waits for a response to an earlier request or fails with
+	// a *FlowControlError, depending on the FlowControlPolicy.
 	Send(*Request) (*Response, error)
 
 	// Statistics returns the current, tracked Statistics instance for this device
 	Statistics() Statistics
+
+	// History returns summaries of the most recent messages exchanged with this device, oldest first.
+	// This will be empty unless message history is enabled.
+	History() []MessageSummary
+
+	// ProtocolVersion returns the protocol version negotiated when this device connected
+	ProtocolVersion() string
+}
+
+// Conveyor is implemented by devices that expose the convey metadata they supplied when they connected.
+// The devices created by a Manager implement this interface.
+type Conveyor interface {
+	// Convey returns the convey metadata this device supplied when it connected.  This will be nil
+	// if the device did not supply any convey metadata.
+	Convey() convey.C
+}
+
+// conveyOf returns the convey metadata of a device, or nil if the device does not implement Conveyor
+func conveyOf(d Interface) convey.C {
+	if c, ok := d.(Conveyor); ok {
+		return c.Convey()
+	}
+
+	return nil
+}
+
+// ServiceAdvertiser is implemented by devices that expose the services they have advertised with WRP
+// service registration messages.  The devices created by a Manager implement this interface.
+type ServiceAdvertiser interface {
+	// Services returns the unexpired services this device has advertised, sorted by name
+	Services() []ServiceEntry
+}
+
+// servicesOf returns the services advertised by a device, or nil if the device does not implement ServiceAdvertiser
+func servicesOf(d Interface) []ServiceEntry {
+	if s, ok := d.(ServiceAdvertiser); ok {
+		return s.Services()
+	}
+
+	return nil
 }
 
 // device is the internal Interface implementation.  This type holds the internal
@@ -90,19 +141,45 @@ type device struct {
 	debugLog log.Logger
 
 	statistics Statistics
+	history    *History
+	services   *Services
+	convey     convey.C
+	protocol   Protocol
 
 	state int32
 
+	// closeReason is why this device was closed.  It is written once, before shutdown is closed.
+	closeReason string
+
+	// peerCloseCode is the close code the device sent when it closed its side of the connection, or zero if
+	// the device has sent no close frame.  This field is accessed atomically.
+	peerCloseCode int32
+
 	shutdown     chan struct{}
 	messages     chan *envelope
+	messageTTL   time.Duration
+	now          func() time.Time
 	transactions *Transactions
+	flowControl  *flowControl
 }
 
 type deviceOptions struct {
 	ID          ID
 	QueueSize   int
 	ConnectedAt time.Time
+	HistorySize int
+	ServiceTTL  time.Duration
+	MaxServices int
+	MessageTTL  time.Duration
+	Now         func() time.Time
 	Logger      log.Logger
+	Convey      convey.C
+	Protocol    Protocol
+
+	// MaxUnacknowledged, FlowControlPolicy, and FlowControlled configure the device's flow control
+	MaxUnacknowledged int
+	FlowControlPolicy FlowControlPolicy
+	FlowControlled    metrics.Counter
 }
 
 // newDevice is an internal factory function for devices
@@ -119,16 +196,31 @@ func newDevice(o deviceOptions) *device {
 		o.QueueSize = DefaultDeviceMessageQueueSize
 	}
 
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+
+	if len(o.Protocol.Version) == 0 {
+		o.Protocol = defaultProtocols[0]
+	}
+
 	return &device{
 		id:           o.ID,
 		errorLog:     logging.Error(o.Logger, "id", o.ID),
 		infoLog:      logging.Info(o.Logger, "id", o.ID),
 		debugLog:     logging.Debug(o.Logger, "id", o.ID),
 		statistics:   NewStatistics(nil, o.ConnectedAt),
+		history:      NewHistory(o.HistorySize),
+		services:     NewServices(o.ServiceTTL, o.MaxServices, o.Now),
+		convey:       o.Convey,
+		protocol:     o.Protocol,
 		state:        stateOpen,
 		shutdown:     make(chan struct{}),
 		messages:     make(chan *envelope, o.QueueSize),
+		messageTTL:   o.MessageTTL,
+		now:          o.Now,
 		transactions: NewTransactions(),
+		flowControl:  newFlowControl(o.ID, o.MaxUnacknowledged, o.FlowControlPolicy, o.FlowControlled),
 	}
 }
 
@@ -150,8 +242,21 @@ func (d *device) MarshalJSON() ([]byte, error) {
 	return output.Bytes(), err
 }
 
-func (d *device) requestClose() error {
+// peerClosed records the close code sent by the device in its close frame.  Only the first code is kept.
+func (d *device) peerClosed(code int) {
+	atomic.CompareAndSwapInt32(&d.peerCloseCode, 0, int32(code))
+}
+
+// closedByPeer tests if the device has sent a close frame
+func (d *device) closedByPeer() bool {
+	return atomic.LoadInt32(&d.peerCloseCode) != 0
+}
+
+// requestClose closes this device, recording the reason, which is sent to the device when its
+// connection is closed.  Only the first call has any effect.
+func (d *device) requestClose(reason string) error {
 	if atomic.CompareAndSwapInt32(&d.state, stateOpen, stateClosed) {
+		d.closeReason = reason
 		close(d.shutdown)
 		d.transactions.Close()
 	}
@@ -181,18 +286,33 @@ func (d *device) sendRequest(request *Request) error {
 		done     = request.Context().Done()
 		complete = make(chan error, 1)
 		envelope = &envelope{
-			request,
-			complete,
+			request:  request,
+			complete: complete,
 		}
 	)
 
-	// attempt to enqueue the message
+	if ttl := request.TTL; ttl > 0 {
+		envelope.expires = d.now().Add(ttl)
+	} else if d.messageTTL > 0 {
+		envelope.expires = d.now().Add(d.messageTTL)
+	}
+
+	// a request that has already been cancelled is never enqueued, as it would be sent anyway
+	if err := request.Context().Err(); err != nil {
+		return err
+	}
+
+	// attempt to enqueue the message, waiting for room in the queue if necessary
 	select {
-	case <-done:
-		return request.Context().Err()
-	case <-d.shutdown:
-		return ErrorDeviceClosed
 	case d.messages <- envelope:
+	default:
+		select {
+		case <-done:
+			return &QueueFullError{ID: d.id, Size: cap(d.messages), Cause: request.Context().Err()}
+		case <-d.shutdown:
+			return &ClosedError{ID: d.id}
+		case d.messages <- envelope:
+		}
 	}
 
 	// once enqueued, wait until the context is cancelled
@@ -201,7 +321,7 @@ func (d *device) sendRequest(request *Request) error {
 	case <-done:
 		return request.Context().Err()
 	case <-d.shutdown:
-		return ErrorDeviceClosed
+		return &ClosedError{ID: d.id}
 	case err := <-complete:
 		return err
 	}
@@ -215,10 +335,12 @@ func (d *device) awaitResponse(request *Request, result <-chan *Response) (*Resp
 	case <-request.Context().Done():
 		return nil, request.Context().Err()
 	case <-d.shutdown:
-		return nil, ErrorDeviceClosed
+		return nil, &ClosedError{ID: d.id}
 	case response := <-result:
 		if response == nil {
 			return nil, ErrorTransactionCancelled
+		} else if response.err != nil {
+			return nil, response.err
 		}
 
 		return response, nil
@@ -227,7 +349,7 @@ func (d *device) awaitResponse(request *Request, result <-chan *Response) (*Resp
 
 func (d *device) Send(request *Request) (*Response, error) {
 	if d.Closed() {
-		return nil, ErrorDeviceClosed
+		return nil, &ClosedError{ID: d.id}
 	}
 
 	var (
@@ -236,6 +358,13 @@ func (d *device) Send(request *Request) (*Response, error) {
 	)
 
 	if transactional {
+		// only request-response messages are acknowledged, so only they are subject to flow control
+		if err := d.flowControl.acquire(request, d.shutdown); err != nil {
+			return nil, err
+		}
+
+		defer d.flowControl.release()
+
 		var err error
 		if result, err = d.transactions.Register(transactionKey); err != nil {
 			// if a transaction key cannot be registered, we don't want to proceed.
@@ -259,6 +388,22 @@ func (d *device) Send(request *Request) (*Response, error) {
 	return d.awaitResponse(request, result)
 }
 
+func (d *device) Convey() convey.C {
+	return d.convey
+}
+
+func (d *device) ProtocolVersion() string {
+	return d.protocol.Version
+}
+
 func (d *device) Statistics() Statistics {
 	return d.statistics
 }
+
+func (d *device) History() []MessageSummary {
+	return d.history.Summaries()
+}
+
+func (d *device) Services() []ServiceEntry {
+	return d.services.Entries()
+}
@@ -76,10 +76,11 @@ func TestDevice(t *testing.T) {
 
 		assert.JSONEq(
 			fmt.Sprintf(
-				`{"id": "%s", "pending": 0, "statistics": {"duplications": 0, "bytesSent": 0, "messagesSent": 0, "bytesReceived": 0, "messagesReceived": 0, "connectedAt": "%s", "upTime": "%s"}}`,
+				`{"id": "%s", "pending": 0, "statistics": {"duplications": 0, "bytesSent": 0, "messagesSent": 0, "bytesReceived": 0, "messagesReceived": 0, "connectedAt": "%s", "upTime": "%s", "lastActivity": "%s", "rtt": "0s"}}`,
 				record.expectedID,
 				expectedConnectedAt.UTC().Format(time.RFC3339Nano),
 				expectedUpTime,
+				expectedConnectedAt.UTC().Format(time.RFC3339Nano),
 			),
 			string(data),
 		)
@@ -94,9 +95,9 @@ func TestDevice(t *testing.T) {
 		cancel()
 
 		assert.False(device.Closed())
-		device.requestClose()
+		device.requestClose(ReasonDisconnectRequested)
 		assert.True(device.Closed())
-		device.requestClose()
+		device.requestClose(ReasonDisconnectRequested)
 		assert.True(device.Closed())
 
 		response, err := device.Send(&Request{Message: testMessage})
@@ -104,3 +105,22 @@ func TestDevice(t *testing.T) {
 		assert.Error(err)
 	}
 }
+
+func TestDeviceSendCancelled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		ctx, cancel = context.WithCancel(context.Background())
+		device      = newDevice(deviceOptions{
+			ID:        ID("ID 1"),
+			QueueSize: 1,
+			Logger:    logging.NewTestLogger(nil, t),
+		})
+	)
+
+	// there is room in the queue, but a cancelled request must not be enqueued
+	cancel()
+	response, err := device.Send((&Request{Message: new(wrp.Message)}).WithContext(ctx))
+	assert.Nil(response)
+	assert.Equal(context.Canceled, err)
+	assert.Zero(len(device.messages))
+}
@@ -0,0 +1,262 @@
+package device
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	// DefaultEnumerateLimit is the page size used when a Query does not specify a limit
+	DefaultEnumerateLimit = 1000
+
+	// EnumerateNextHeader is the response header which holds the cursor for the next page of devices.
+	// This header is absent when there are no more devices.
+	EnumerateNextHeader = "X-Webpa-Device-Next"
+
+	// EnumerateContentType is the content type of device enumerations, which are newline-delimited JSON
+	EnumerateContentType = "application/x-ndjson"
+
+	// ConveyFilterPrefix is the query parameter prefix for convey metadata filters.  For example,
+	// convey.hw-model=XB3 matches devices whose convey hw-model is XB3.
+	ConveyFilterPrefix = "convey."
+)
+
+// The fields that can be selected in a device enumeration
+const (
+	IDField         = "id"
+	PendingField    = "pending"
+	ClosedField     = "closed"
+	StatisticsField = "statistics"
+	ConveyField     = "convey"
+)
+
+var (
+	// DefaultEnumerateFields are the fields written for each device when a Query does not select any
+	DefaultEnumerateFields = []string{IDField, PendingField, StatisticsField}
+
+	errInvalidLimit = errors.New("The limit must be a positive integer")
+)
+
+// Query describes a page of connected devices.  Devices are enumerated in order of their IDs.
+type Query struct {
+	// After is the cursor for this page.  Only devices whose IDs sort after this value are returned.
+	// If empty, enumeration starts with the first device.
+	After ID
+
+	// Limit is the maximum number of devices returned.  If nonpositive, DefaultEnumerateLimit is used.
+	Limit int
+
+	// Convey filters devices by their convey metadata.  A device matches when, for every entry, the
+	// device's convey value for the key has the same string representation as the entry's value.
+	Convey map[string]string
+}
+
+func (q *Query) limit() int {
+	if q.Limit > 0 {
+		return q.Limit
+	}
+
+	return DefaultEnumerateLimit
+}
+
+// matches tests if a device satisfies this query's filters
+func (q *Query) matches(d Interface) bool {
+	if len(q.Convey) == 0 {
+		return true
+	}
+
+	c := conveyOf(d)
+	for key, expected := range q.Convey {
+		actual, ok := c[key]
+		if !ok || fmt.Sprint(actual) != expected {
+			return false
+		}
+	}
+
+	return true
+}
+
+// page holds the devices with the smallest IDs visited so far.  It is a max-heap on ID, so that the device
+// with the largest ID is the one displaced when a device with a smaller ID is visited.
+type page []Interface
+
+func (p page) Len() int            { return len(p) }
+func (p page) Less(i, j int) bool  { return p[i].ID() > p[j].ID() }
+func (p page) Swap(i, j int)       { p[i], p[j] = p[j], p[i] }
+func (p *page) Push(x interface{}) { *p = append(*p, x.(Interface)) }
+
+func (p *page) Pop() interface{} {
+	last := (*p)[len(*p)-1]
+	*p = (*p)[:len(*p)-1]
+	return last
+}
+
+// Enumerate returns a page of the devices in a Registry, along with a flag indicating whether more devices
+// follow this page.  The last device's ID is the cursor for the next page.  Rather than sorting every matching
+// device, the devices on the page are selected with a bounded heap as the registry is visited, and only the
+// page itself is sorted.
+func Enumerate(r Registry, q Query) ([]Interface, bool) {
+	var (
+		limit = q.limit()
+
+		// one extra device is retained to determine whether more devices follow this page
+		selected = make(page, 0, limit+1)
+	)
+
+	r.VisitAll(func(d Interface) {
+		if d.ID() <= q.After || !q.matches(d) {
+			return
+		}
+
+		if len(selected) <= limit {
+			heap.Push(&selected, d)
+		} else if d.ID() < selected[0].ID() {
+			selected[0] = d
+			heap.Fix(&selected, 0)
+		}
+	})
+
+	sort.Slice(selected, func(i, j int) bool { return selected[i].ID() < selected[j].ID() })
+	if len(selected) > limit {
+		return selected[:limit], true
+	}
+
+	return selected, false
+}
+
+// selectFields produces the JSON object for a device that contains only the given fields
+func selectFields(d Interface, fields []string) map[string]interface{} {
+	selected := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		switch field {
+		case IDField:
+			selected[IDField] = d.ID()
+		case PendingField:
+			selected[PendingField] = d.Pending()
+		case ClosedField:
+			selected[ClosedField] = d.Closed()
+		case StatisticsField:
+			selected[StatisticsField] = d.Statistics()
+		case ConveyField:
+			selected[ConveyField] = conveyOf(d)
+		}
+	}
+
+	return selected
+}
+
+// EnumerateHandler is an http.Handler that writes a page of connected devices as newline-delimited JSON.
+// The following query parameters are supported:
+//
+//     after    - the cursor returned in the EnumerateNextHeader of the previous page
+//     limit    - the maximum number of devices to write
+//     fields   - a comma-delimited list of fields to write for each device
+//     convey.* - convey metadata filters, e.g. convey.fw-name=1.2.3
+type EnumerateHandler struct {
+	Logger   log.Logger
+	Registry Registry
+
+	// MaxLimit is the largest page size a client may request.  If nonpositive, DefaultEnumerateLimit is used.
+	MaxLimit int
+}
+
+func (eh *EnumerateHandler) logger() log.Logger {
+	if eh.Logger != nil {
+		return eh.Logger
+	}
+
+	return logging.DefaultLogger()
+}
+
+func (eh *EnumerateHandler) maxLimit() int {
+	if eh.MaxLimit > 0 {
+		return eh.MaxLimit
+	}
+
+	return DefaultEnumerateLimit
+}
+
+// parseQuery produces the Query and selected fields described by an HTTP request
+func (eh *EnumerateHandler) parseQuery(request *http.Request) (Query, []string, error) {
+	var (
+		values = request.URL.Query()
+		q      = Query{After: ID(values.Get("after")), Limit: eh.maxLimit()}
+		fields = DefaultEnumerateFields
+	)
+
+	if v := values.Get("limit"); len(v) > 0 {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return Query{}, nil, errInvalidLimit
+		}
+
+		if limit < q.Limit {
+			q.Limit = limit
+		}
+	}
+
+	if v := values.Get("fields"); len(v) > 0 {
+		fields = nil
+		for _, field := range strings.Split(v, ",") {
+			switch field = strings.TrimSpace(field); field {
+			case IDField, PendingField, ClosedField, StatisticsField, ConveyField:
+				fields = append(fields, field)
+			default:
+				return Query{}, nil, fmt.Errorf("Unrecognized field: %s", field)
+			}
+		}
+	}
+
+	for name := range values {
+		if strings.HasPrefix(name, ConveyFilterPrefix) && len(name) > len(ConveyFilterPrefix) {
+			if q.Convey == nil {
+				q.Convey = make(map[string]string)
+			}
+
+			q.Convey[name[len(ConveyFilterPrefix):]] = values.Get(name)
+		}
+	}
+
+	return q, fields, nil
+}
+
+func (eh *EnumerateHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	q, fields, err := eh.parseQuery(request)
+	if err != nil {
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	devices, more := Enumerate(eh.Registry, q)
+	response.Header().Set("Content-Type", EnumerateContentType)
+	if more {
+		response.Header().Set(EnumerateNextHeader, string(devices[len(devices)-1].ID()))
+	}
+
+	var (
+		encoder    = json.NewEncoder(response)
+		flusher, _ = response.(http.Flusher)
+	)
+
+	for i, d := range devices {
+		if err := encoder.Encode(selectFields(d, fields)); err != nil {
+			logging.Error(eh.logger()).Log(logging.MessageKey(), "unable to write device enumeration", logging.ErrorKey(), err)
+			return
+		}
+
+		// flush periodically, so that large pages are streamed rather than buffered
+		if flusher != nil && (i+1)%100 == 0 {
+			flusher.Flush()
+		}
+	}
+}
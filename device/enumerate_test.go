@@ -0,0 +1,227 @@
+package device
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/webpa-common/convey"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry is a simple Registry backed by a slice
+type testRegistry []Interface
+
+func (tr testRegistry) Get(id ID) (Interface, bool) {
+	for _, d := range tr {
+		if d.ID() == id {
+			return d, true
+		}
+	}
+
+	return nil, false
+}
+
+func (tr testRegistry) VisitAll(f func(Interface)) int {
+	for _, d := range tr {
+		f(d)
+	}
+
+	return len(tr)
+}
+
+func newTestEnumerateRegistry(t *testing.T) testRegistry {
+	logger := logging.NewTestLogger(nil, t)
+	return testRegistry{
+		newDevice(deviceOptions{ID: IntToMAC(0x03), Logger: logger, Convey: convey.C{"hw-model": "XB3", "boot-time": 1000}}),
+		newDevice(deviceOptions{ID: IntToMAC(0x01), Logger: logger, Convey: convey.C{"hw-model": "XB6"}}),
+		newDevice(deviceOptions{ID: IntToMAC(0x04), Logger: logger}),
+		newDevice(deviceOptions{ID: IntToMAC(0x02), Logger: logger, Convey: convey.C{"hw-model": "XB3", "boot-time": 2000}}),
+	}
+}
+
+func deviceIDs(devices []Interface) []ID {
+	ids := make([]ID, len(devices))
+	for i, d := range devices {
+		ids[i] = d.ID()
+	}
+
+	return ids
+}
+
+func TestEnumerate(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		registry = newTestEnumerateRegistry(t)
+	)
+
+	devices, more := Enumerate(registry, Query{})
+	assert.Equal([]ID{IntToMAC(0x01), IntToMAC(0x02), IntToMAC(0x03), IntToMAC(0x04)}, deviceIDs(devices))
+	assert.False(more)
+
+	devices, more = Enumerate(registry, Query{Limit: 2})
+	assert.Equal([]ID{IntToMAC(0x01), IntToMAC(0x02)}, deviceIDs(devices))
+	assert.True(more)
+
+	devices, more = Enumerate(registry, Query{After: IntToMAC(0x02), Limit: 2})
+	assert.Equal([]ID{IntToMAC(0x03), IntToMAC(0x04)}, deviceIDs(devices))
+	assert.False(more)
+
+	devices, more = Enumerate(registry, Query{Convey: map[string]string{"hw-model": "XB3"}})
+	assert.Equal([]ID{IntToMAC(0x02), IntToMAC(0x03)}, deviceIDs(devices))
+	assert.False(more)
+
+	devices, more = Enumerate(registry, Query{Convey: map[string]string{"hw-model": "XB3", "boot-time": "1000"}})
+	assert.Equal([]ID{IntToMAC(0x03)}, deviceIDs(devices))
+	assert.False(more)
+
+	devices, more = Enumerate(registry, Query{Convey: map[string]string{"nosuch": "value"}})
+	assert.Empty(devices)
+	assert.False(more)
+
+	devices, more = Enumerate(registry, Query{Convey: map[string]string{"hw-model": "XB3"}, Limit: 1})
+	assert.Equal([]ID{IntToMAC(0x02)}, deviceIDs(devices))
+	assert.True(more)
+}
+
+func TestEnumeratePages(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		logger   = logging.NewTestLogger(nil, t)
+		registry testRegistry
+		expected []ID
+	)
+
+	// visit devices in an order unrelated to their IDs
+	for i := 0; i < 100; i++ {
+		id := IntToMAC(uint64((i * 37) % 100))
+		registry = append(registry, newDevice(deviceOptions{ID: id, Logger: logger}))
+	}
+
+	for i := 0; i < 100; i++ {
+		expected = append(expected, IntToMAC(uint64(i)))
+	}
+
+	var (
+		actual []ID
+		q      = Query{Limit: 7}
+	)
+
+	for {
+		devices, more := Enumerate(registry, q)
+		actual = append(actual, deviceIDs(devices)...)
+		if !more {
+			break
+		}
+
+		q.After = devices[len(devices)-1].ID()
+	}
+
+	assert.Equal(expected, actual)
+}
+
+func TestEnumerateNotConveyor(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = new(mockDevice)
+	)
+
+	d.On("ID").Return(IntToMAC(0x01))
+	devices, _ := Enumerate(testRegistry{struct{ Interface }{d}}, Query{})
+	assert.Len(devices, 1)
+
+	// a device that does not expose convey metadata never matches a convey filter
+	devices, _ = Enumerate(testRegistry{struct{ Interface }{d}}, Query{Convey: map[string]string{"hw-model": "XB3"}})
+	assert.Empty(devices)
+	assert.Nil(selectFields(struct{ Interface }{d}, []string{ConveyField})[ConveyField])
+}
+
+// readEnumeration decodes each line of an NDJSON response
+func readEnumeration(t *testing.T, response *httptest.ResponseRecorder) []map[string]interface{} {
+	var (
+		lines   []map[string]interface{}
+		scanner = bufio.NewScanner(response.Body)
+	)
+
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func TestEnumerateHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		handler = &EnumerateHandler{
+			Logger:   logging.NewTestLogger(nil, t),
+			Registry: newTestEnumerateRegistry(t),
+			MaxLimit: 3,
+		}
+	)
+
+	t.Run("Default", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/devices", nil))
+		assert.Equal(http.StatusOK, response.Code)
+		assert.Equal(EnumerateContentType, response.Header().Get("Content-Type"))
+		assert.Equal(string(IntToMAC(0x03)), response.Header().Get(EnumerateNextHeader))
+
+		lines := readEnumeration(t, response)
+		require.Len(lines, 3)
+		assert.Equal(string(IntToMAC(0x01)), lines[0][IDField])
+		assert.Contains(lines[0], PendingField)
+		assert.Contains(lines[0], StatisticsField)
+		assert.NotContains(lines[0], ConveyField)
+	})
+
+	t.Run("NextPage", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/devices?after="+string(IntToMAC(0x03))+"&limit=10", nil))
+		assert.Equal(http.StatusOK, response.Code)
+		assert.Empty(response.Header().Get(EnumerateNextHeader))
+
+		lines := readEnumeration(t, response)
+		require.Len(lines, 1)
+		assert.Equal(string(IntToMAC(0x04)), lines[0][IDField])
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/devices?fields=id,convey,closed&convey.hw-model=XB3&limit=1", nil))
+		assert.Equal(http.StatusOK, response.Code)
+		assert.Equal(string(IntToMAC(0x02)), response.Header().Get(EnumerateNextHeader))
+
+		lines := readEnumeration(t, response)
+		require.Len(lines, 1)
+		assert.Equal(
+			map[string]interface{}{
+				IDField:     string(IntToMAC(0x02)),
+				ClosedField: false,
+				ConveyField: map[string]interface{}{"hw-model": "XB3", "boot-time": 2000.0},
+			},
+			lines[0],
+		)
+	})
+
+	t.Run("InvalidLimit", func(t *testing.T) {
+		for _, limit := range []string{"0", "-1", "abc"} {
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, httptest.NewRequest("GET", "/devices?limit="+limit, nil))
+			assert.Equal(http.StatusBadRequest, response.Code)
+		}
+	})
+
+	t.Run("InvalidField", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/devices?fields=id,nosuch", nil))
+		assert.Equal(http.StatusBadRequest, response.Code)
+	})
+}
@@ -1,7 +1,10 @@
 package device
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
 )
 
 var (
@@ -23,4 +26,101 @@ var (
 	ErrorDeviceClosed                 = errors.New("That device has been closed")
 	ErrorTransactionsClosed           = errors.New("Transactions are closed for that device")
 	ErrorTransactionsAlreadyClosed    = errors.New("That Transactions is already closed")
+	ErrorMessageTooLarge              = errors.New("The message exceeds the maximum allowed size")
+	ErrorMessageExpired               = errors.New("The message expired before it could be sent")
+	ErrorUnsupportedProtocolVersion   = errors.New("None of the offered protocol versions are supported")
+	ErrorServiceNotRegistered         = errors.New("The device has not registered that service")
+	ErrorTooManyServices              = errors.New("The device has registered the maximum number of services")
+	ErrorQueueFull                    = errors.New("The device's message queue is full")
 )
+
+// DeviceNotFoundError is returned when a message is routed to a device which is not connected.
+// errors.Is(err, ErrorDeviceNotFound) is true for this error.
+type DeviceNotFoundError struct {
+	// ID is the identifier of the device which was not found
+	ID ID
+}
+
+func (e *DeviceNotFoundError) Error() string {
+	return fmt.Sprintf("Device %s does not exist", e.ID)
+}
+
+func (e *DeviceNotFoundError) Is(target error) bool {
+	return target == ErrorDeviceNotFound
+}
+
+// QueueFullError is returned when a message could not be queued for a device before its request was cancelled
+// or timed out, because the device's message queue stayed full.  errors.Is(err, ErrorQueueFull) is true for this
+// error, as is errors.Is(err, Cause).
+type QueueFullError struct {
+	// ID is the identifier of the device whose queue is full
+	ID ID
+
+	// Size is the capacity of the device's message queue
+	Size int
+
+	// Cause is why the request stopped waiting for room in the queue, usually a context error.  This field is nil
+	// when a message was never going to wait.
+	Cause error
+}
+
+func (e *QueueFullError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("The message queue of device %s is full [size=%d]: %s", e.ID, e.Size, e.Cause)
+	}
+
+	return fmt.Sprintf("The message queue of device %s is full [size=%d]", e.ID, e.Size)
+}
+
+func (e *QueueFullError) Is(target error) bool {
+	return target == ErrorQueueFull
+}
+
+func (e *QueueFullError) Unwrap() error {
+	return e.Cause
+}
+
+// ClosedError is returned when a message cannot be sent to a device, or its response cannot be received,
+// because the device has disconnected.  errors.Is(err, ErrorDeviceClosed) is true for this error.
+type ClosedError struct {
+	// ID is the identifier of the closed device
+	ID ID
+}
+
+func (e *ClosedError) Error() string {
+	return fmt.Sprintf("Device %s has been closed", e.ID)
+}
+
+func (e *ClosedError) Is(target error) bool {
+	return target == ErrorDeviceClosed
+}
+
+// StatusCodeFor maps an error from routing a message to a device onto the HTTP status code that best describes it.
+// Both the sentinel errors of this package and the typed errors which wrap them are recognized.  Any other error
+// produces http.StatusInternalServerError.
+func StatusCodeFor(err error) int {
+	switch {
+	case errors.Is(err, ErrorDeviceBusy):
+		return http.StatusTooManyRequests
+
+	// checked before context errors, since a QueueFullError usually wraps one
+	case errors.Is(err, ErrorQueueFull), errors.Is(err, ErrorDeviceClosed):
+		return http.StatusServiceUnavailable
+
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrorTransactionCancelled):
+		return http.StatusGatewayTimeout
+
+	case errors.Is(err, ErrorDeviceNotFound), errors.Is(err, ErrorServiceNotRegistered):
+		return http.StatusNotFound
+
+	case errors.Is(err, ErrorInvalidDeviceName), errors.Is(err, ErrorNonUniqueID),
+		errors.Is(err, ErrorInvalidTransactionKey), errors.Is(err, ErrorTransactionAlreadyRegistered):
+		return http.StatusBadRequest
+
+	case errors.Is(err, ErrorMessageTooLarge):
+		return http.StatusRequestEntityTooLarge
+
+	default:
+		return http.StatusInternalServerError
+	}
+}
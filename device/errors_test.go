@@ -0,0 +1,96 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceNotFoundError(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		err    error = &DeviceNotFoundError{ID: ID("mac:112233445566")}
+	)
+
+	assert.Contains(err.Error(), "mac:112233445566")
+	assert.True(errors.Is(err, ErrorDeviceNotFound))
+	assert.False(errors.Is(err, ErrorDeviceClosed))
+
+	var target *DeviceNotFoundError
+	assert.True(errors.As(fmt.Errorf("routing failed: %w", err), &target))
+	assert.Equal(ID("mac:112233445566"), target.ID)
+}
+
+func TestQueueFullError(t *testing.T) {
+	t.Run("NoCause", func(t *testing.T) {
+		var (
+			assert       = assert.New(t)
+			err    error = &QueueFullError{ID: ID("mac:112233445566"), Size: 100}
+		)
+
+		assert.Contains(err.Error(), "mac:112233445566")
+		assert.Contains(err.Error(), "100")
+		assert.True(errors.Is(err, ErrorQueueFull))
+		assert.Nil(errors.Unwrap(err))
+	})
+
+	t.Run("Cause", func(t *testing.T) {
+		var (
+			assert       = assert.New(t)
+			err    error = &QueueFullError{ID: ID("mac:112233445566"), Size: 100, Cause: context.DeadlineExceeded}
+		)
+
+		assert.Contains(err.Error(), context.DeadlineExceeded.Error())
+		assert.True(errors.Is(err, ErrorQueueFull))
+		assert.True(errors.Is(err, context.DeadlineExceeded))
+		assert.False(errors.Is(err, context.Canceled))
+	})
+}
+
+func TestClosedError(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		err    error = &ClosedError{ID: ID("mac:112233445566")}
+	)
+
+	assert.Contains(err.Error(), "mac:112233445566")
+	assert.True(errors.Is(err, ErrorDeviceClosed))
+	assert.False(errors.Is(err, ErrorDeviceNotFound))
+}
+
+func TestStatusCodeFor(t *testing.T) {
+	testData := []struct {
+		err      error
+		expected int
+	}{
+		{errors.New("unrecognized"), http.StatusInternalServerError},
+		{ErrorDeviceBusy, http.StatusTooManyRequests},
+		{&FlowControlError{ID: ID("mac:112233445566"), Limit: 1}, http.StatusTooManyRequests},
+		{ErrorQueueFull, http.StatusServiceUnavailable},
+		{&QueueFullError{ID: ID("mac:112233445566"), Cause: context.DeadlineExceeded}, http.StatusServiceUnavailable},
+		{ErrorDeviceClosed, http.StatusServiceUnavailable},
+		{&ClosedError{ID: ID("mac:112233445566")}, http.StatusServiceUnavailable},
+		{context.Canceled, http.StatusGatewayTimeout},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{ErrorTransactionCancelled, http.StatusGatewayTimeout},
+		{ErrorDeviceNotFound, http.StatusNotFound},
+		{&DeviceNotFoundError{ID: ID("mac:112233445566")}, http.StatusNotFound},
+		{fmt.Errorf("wrapped: %w", &DeviceNotFoundError{ID: ID("mac:112233445566")}), http.StatusNotFound},
+		{ErrorServiceNotRegistered, http.StatusNotFound},
+		{ErrorInvalidDeviceName, http.StatusBadRequest},
+		{ErrorNonUniqueID, http.StatusBadRequest},
+		{ErrorInvalidTransactionKey, http.StatusBadRequest},
+		{ErrorTransactionAlreadyRegistered, http.StatusBadRequest},
+		{ErrorMessageTooLarge, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, record := range testData {
+		t.Run(record.err.Error(), func(t *testing.T) {
+			assert.Equal(t, record.expected, StatusCodeFor(record.err))
+		})
+	}
+}
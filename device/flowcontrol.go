@@ -0,0 +1,131 @@
+package device
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Comcast/webpa-common/semaphore"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+// FlowControlPolicy determines how a device handles a request-response message sent when the device already has
+// the maximum number of unacknowledged messages in flight
+type FlowControlPolicy string
+
+const (
+	// FlowControlQueue makes the sender wait until an in-flight message is acknowledged, the request's context is
+	// cancelled, or the device disconnects.  Waiting senders are admitted in the order they arrived.
+	FlowControlQueue FlowControlPolicy = "queue"
+
+	// FlowControlReject fails the message immediately with a *FlowControlError
+	FlowControlReject FlowControlPolicy = "reject"
+
+	// DefaultFlowControlPolicy is the policy used when none is configured
+	DefaultFlowControlPolicy = FlowControlQueue
+)
+
+// Outcomes of flow control used with OutcomeLabel
+const (
+	QueuedOutcome   = "queued"
+	RejectedOutcome = "rejected"
+)
+
+// FlowControlError is returned when a request-response message is rejected because the device already has
+// the maximum number of unacknowledged messages in flight.  errors.Is(err, ErrorDeviceBusy) is true for this error.
+type FlowControlError struct {
+	// ID is the device's identifier
+	ID ID
+
+	// Limit is the maximum number of unacknowledged messages allowed in flight to the device
+	Limit int
+}
+
+func (fce *FlowControlError) Error() string {
+	return fmt.Sprintf("Device %s has the maximum of %d unacknowledged messages in flight", fce.ID, fce.Limit)
+}
+
+func (fce *FlowControlError) Is(target error) bool {
+	return target == ErrorDeviceBusy
+}
+
+// flowControl limits the number of unacknowledged request-response messages in flight to a single device.
+// A nil *flowControl imposes no limit.
+type flowControl struct {
+	id      ID
+	policy  FlowControlPolicy
+	limit   int
+	slots   semaphore.Interface
+	counter metrics.Counter
+}
+
+// newFlowControl creates the flow control for a device.  If limit is nonpositive, this function returns nil.
+func newFlowControl(id ID, limit int, policy FlowControlPolicy, counter metrics.Counter) *flowControl {
+	if limit < 1 {
+		return nil
+	}
+
+	if policy != FlowControlReject {
+		policy = FlowControlQueue
+	}
+
+	if counter == nil {
+		counter = discard.NewCounter()
+	}
+
+	return &flowControl{
+		id:      id,
+		policy:  policy,
+		limit:   limit,
+		slots:   semaphore.New(int64(limit), semaphore.WithFair()),
+		counter: counter,
+	}
+}
+
+// acquire reserves an in-flight slot for a request, applying this flow control's policy when there is no free slot.
+// Each successful acquire must be matched by a release.
+func (fc *flowControl) acquire(request *Request, shutdown <-chan struct{}) error {
+	if fc == nil {
+		return nil
+	}
+
+	if fc.slots.TryAcquire() {
+		return nil
+	}
+
+	if fc.policy == FlowControlReject {
+		fc.counter.With(OutcomeLabel, RejectedOutcome).Add(1.0)
+		return &FlowControlError{ID: fc.id, Limit: fc.limit}
+	}
+
+	fc.counter.With(OutcomeLabel, QueuedOutcome).Add(1.0)
+
+	// stop waiting if the device disconnects
+	ctx, cancel := context.WithCancel(request.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := fc.slots.Acquire(ctx); err != nil {
+		select {
+		case <-shutdown:
+			return &ClosedError{ID: fc.id}
+		default:
+			return request.Context().Err()
+		}
+	}
+
+	return nil
+}
+
+// release frees an in-flight slot reserved by acquire
+func (fc *flowControl) release() {
+	if fc != nil {
+		fc.slots.Release()
+	}
+}
@@ -0,0 +1,247 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlowControlError(t *testing.T) {
+	assert := assert.New(t)
+	err := &FlowControlError{ID: ID("mac:112233445566"), Limit: 3}
+	assert.Contains(err.Error(), "mac:112233445566")
+	assert.Contains(err.Error(), "3")
+	assert.True(errors.Is(err, ErrorDeviceBusy))
+}
+
+func testNewFlowControlUnlimited(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		fc     = newFlowControl(ID("test"), 0, FlowControlReject, nil)
+	)
+
+	assert.Nil(fc)
+	for i := 0; i < 10; i++ {
+		assert.NoError(fc.acquire(new(Request), nil))
+	}
+
+	fc.release()
+}
+
+func testFlowControlReject(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		p      = xmetricstest.NewProvider(nil, Metrics)
+		fc     = newFlowControl(ID("test"), 2, FlowControlReject, p.NewCounter(FlowControlCounter))
+	)
+
+	assert.NoError(fc.acquire(new(Request), nil))
+	assert.NoError(fc.acquire(new(Request), nil))
+
+	err := fc.acquire(new(Request), nil)
+	assert.Equal(&FlowControlError{ID: ID("test"), Limit: 2}, err)
+	p.Assert(t, FlowControlCounter, OutcomeLabel, RejectedOutcome)(xmetricstest.Value(1.0))
+
+	fc.release()
+	assert.NoError(fc.acquire(new(Request), nil))
+}
+
+func testFlowControlQueue(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		p      = xmetricstest.NewProvider(nil, Metrics)
+		fc     = newFlowControl(ID("test"), 1, "", p.NewCounter(FlowControlCounter))
+
+		acquired = make(chan error, 1)
+	)
+
+	assert.Equal(FlowControlQueue, fc.policy)
+	assert.NoError(fc.acquire(new(Request), nil))
+
+	go func() {
+		acquired <- fc.acquire(new(Request), nil)
+	}()
+
+	select {
+	case <-acquired:
+		assert.Fail("The second acquire should have waited")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	fc.release()
+	select {
+	case err := <-acquired:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The second acquire did not proceed after release")
+	}
+
+	p.Assert(t, FlowControlCounter, OutcomeLabel, QueuedOutcome)(xmetricstest.Value(1.0))
+}
+
+func testFlowControlQueueCancelled(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		fc          = newFlowControl(ID("test"), 1, FlowControlQueue, nil)
+		ctx, cancel = context.WithCancel(context.Background())
+		shutdown    = make(chan struct{})
+	)
+
+	assert.NoError(fc.acquire(new(Request), nil))
+
+	cancel()
+	assert.Equal(context.Canceled, fc.acquire(new(Request).WithContext(ctx), shutdown))
+
+	close(shutdown)
+	assert.Equal(&ClosedError{ID: ID("test")}, fc.acquire(new(Request), shutdown))
+}
+
+func testFlowControlQueueOrder(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		fc     = newFlowControl(ID("test"), 1, FlowControlQueue, nil)
+		order  = make(chan int, 3)
+	)
+
+	assert.NoError(fc.acquire(new(Request), nil))
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			if fc.acquire(new(Request), nil) == nil {
+				order <- i
+				fc.release()
+			}
+		}(i)
+
+		// give each sender time to queue before the next one arrives
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	fc.release()
+	for expected := 0; expected < 3; expected++ {
+		select {
+		case actual := <-order:
+			assert.Equal(expected, actual)
+		case <-time.After(5 * time.Second):
+			assert.Fail("A queued sender did not proceed")
+			return
+		}
+	}
+}
+
+func TestFlowControl(t *testing.T) {
+	t.Run("Unlimited", testNewFlowControlUnlimited)
+	t.Run("Reject", testFlowControlReject)
+	t.Run("Queue", testFlowControlQueue)
+	t.Run("QueueCancelled", testFlowControlQueueCancelled)
+	t.Run("QueueOrder", testFlowControlQueueOrder)
+}
+
+// TestDeviceFlowControl verifies that a device limits unacknowledged request-response messages, but not events
+func TestDeviceFlowControl(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		d = newDevice(deviceOptions{
+			ID:                ID("mac:112233445566"),
+			Logger:            logging.NewTestLogger(nil, t),
+			MaxUnacknowledged: 1,
+			FlowControlPolicy: FlowControlReject,
+		})
+
+		responses = make(chan error, 1)
+	)
+
+	// simulate the write pump
+	go func() {
+		for {
+			select {
+			case <-d.shutdown:
+				return
+			case e := <-d.messages:
+				e.complete <- nil
+			}
+		}
+	}()
+
+	defer d.requestClose(ReasonClosed)
+
+	go func() {
+		_, err := d.Send(&Request{Message: &wrp.Message{Type: wrp.SimpleRequestResponseMessageType, TransactionUUID: "first"}})
+		responses <- err
+	}()
+
+	// wait for the first transaction to be in flight
+	for deadline := time.Now().Add(5 * time.Second); d.transactions.Len() == 0; time.Sleep(10 * time.Millisecond) {
+		require.True(time.Now().Before(deadline), "The first transaction was never registered")
+	}
+
+	_, err := d.Send(&Request{Message: &wrp.Message{Type: wrp.SimpleRequestResponseMessageType, TransactionUUID: "second"}})
+	assert.IsType(&FlowControlError{}, err)
+
+	// events are not acknowledged, so they are never subject to flow control
+	_, err = d.Send(&Request{Message: &wrp.Message{Type: wrp.SimpleEventMessageType}})
+	assert.NoError(err)
+
+	require.NoError(d.transactions.Complete("first", &Response{Message: new(wrp.Message)}))
+	select {
+	case err := <-responses:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The first transaction did not complete")
+	}
+
+	// once the first transaction is acknowledged, another may be sent
+	go func() {
+		_, err := d.Send(&Request{Message: &wrp.Message{Type: wrp.SimpleRequestResponseMessageType, TransactionUUID: "third"}})
+		responses <- err
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); d.transactions.Len() == 0; time.Sleep(10 * time.Millisecond) {
+		require.True(time.Now().Before(deadline), "The third transaction was never registered")
+	}
+
+	require.NoError(d.transactions.Complete("third", &Response{Message: new(wrp.Message)}))
+	assert.NoError(<-responses)
+}
+
+func TestMessageHandlerFlowControl(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		router  = new(mockRouter)
+		handler = MessageHandler{Router: router, Logger: logging.NewTestLogger(nil, t)}
+
+		message = wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "test.com",
+			Destination:     "mac:112233445566",
+			TransactionUUID: "test",
+		}
+
+		body     []byte
+		response = httptest.NewRecorder()
+	)
+
+	require.NoError(wrp.NewEncoderBytes(&body, wrp.JSON).Encode(&message))
+	router.On("Route", mock.AnythingOfType("*device.Request")).
+		Return(nil, &FlowControlError{ID: ID("mac:112233445566"), Limit: 1}).
+		Once()
+
+	request := httptest.NewRequest("POST", "/", strings.NewReader(string(body)))
+	request.Header.Set("Content-Type", wrp.JSON.ContentType())
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusTooManyRequests, response.Code)
+	router.AssertExpectations(t)
+}
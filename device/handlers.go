@@ -3,12 +3,14 @@ package device
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/semaphore"
 	"github.com/Comcast/webpa-common/wrp"
 	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/log"
@@ -19,6 +21,9 @@ import (
 const (
 	DefaultMessageTimeout time.Duration = 2 * time.Minute
 	DefaultListRefresh    time.Duration = 10 * time.Second
+
+	// DefaultAsyncLimit is the maximum number of messages a MessageHandler delivers in the background at once
+	DefaultAsyncLimit = 100
 )
 
 // Timeout returns an Alice-style constructor which enforces a timeout for all device request contexts.
@@ -107,13 +112,39 @@ func useID(f IDFromRequest) func(http.Handler) http.Handler {
 }
 
 // MessageHandler is a configurable http.Handler which handles inbound WRP traffic
-// to be sent to devices.
+// to be sent to devices.  Messages may be encoded in any WRP format, and the response
+// format is negotiated via the Accept header.
+//
+// By default, a transactional message waits for the device's correlated response, bounded
+// only by the request context.  Clients may alter this using RFC 7240 preferences:
+//
+//   Prefer: wait=<seconds>    bounds the time spent waiting for the device's response
+//   Prefer: respond-async     sends the message in the background and immediately returns a 202
+//
+// A wait of zero is ignored.  The respond-async preference is also ignored when AsyncLimit messages are
+// already being delivered in the background, in which case the message is sent synchronously.
 type MessageHandler struct {
 	// Logger is the sink for logging output.  If not set, logging will be sent to a NOP logger
 	Logger log.Logger
 
 	// Router is the device message Router to use.  This field is required.
 	Router Router
+
+	// AsyncTimeout bounds the background delivery of messages sent with the respond-async preference.
+	// If not set, DefaultMessageTimeout is used.
+	AsyncTimeout time.Duration
+
+	// AsyncLimit is the maximum number of messages delivered in the background at once.  If not set,
+	// DefaultAsyncLimit is used.
+	AsyncLimit int
+
+	// WRPErrors controls how a failure to route a transactional message is reported.  If true, the HTTP response
+	// body is a WRP error response, built with wrp.NewErrorResponse, in the response format.  Otherwise, the body
+	// is the usual JSON error.  In either case, the HTTP status code is the same.
+	WRPErrors bool
+
+	asyncOnce  sync.Once
+	asyncSlots semaphore.Interface
 }
 
 func (mh *MessageHandler) logger() log.Logger {
@@ -124,6 +155,43 @@ func (mh *MessageHandler) logger() log.Logger {
 	return logging.DefaultLogger()
 }
 
+func (mh *MessageHandler) asyncTimeout() time.Duration {
+	if mh.AsyncTimeout > 0 {
+		return mh.AsyncTimeout
+	}
+
+	return DefaultMessageTimeout
+}
+
+// tryAcquireAsync obtains one of the slots for background delivery, if one is available
+func (mh *MessageHandler) tryAcquireAsync() bool {
+	mh.asyncOnce.Do(func() {
+		limit := mh.AsyncLimit
+		if limit < 1 {
+			limit = DefaultAsyncLimit
+		}
+
+		mh.asyncSlots = semaphore.New(int64(limit))
+	})
+
+	return mh.asyncSlots.TryAcquire()
+}
+
+// routeAsync delivers a device request in the background, detached from the HTTP request that carried it.
+// Any device response is discarded.  The caller must have acquired a slot via tryAcquireAsync, which is
+// released when delivery completes.
+func (mh *MessageHandler) routeAsync(deviceRequest *Request) {
+	ctx, cancel := context.WithTimeout(xhttp.Detach(deviceRequest.Context()), mh.asyncTimeout())
+	deviceRequest = deviceRequest.WithContext(ctx)
+	go func() {
+		defer mh.asyncSlots.Release()
+		defer cancel()
+		if _, err := mh.Router.Route(deviceRequest); err != nil {
+			mh.logger().Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "Could not process asynchronous device request", logging.ErrorKey(), err)
+		}
+	}()
+}
+
 // decodeRequest transforms an HTTP request into a device request.
 func (mh *MessageHandler) decodeRequest(httpRequest *http.Request) (deviceRequest *Request, err error) {
 	format, err := wrp.FormatFromContentType(httpRequest.Header.Get("Content-Type"), wrp.Msgpack)
@@ -166,35 +234,48 @@ func (mh *MessageHandler) ServeHTTP(httpResponse http.ResponseWriter, httpReques
 		return
 	}
 
+	if xhttp.PrefersAsync(httpRequest.Header) && mh.tryAcquireAsync() {
+		mh.routeAsync(deviceRequest)
+		httpResponse.Header().Set(xhttp.PreferenceAppliedHeader, xhttp.RespondAsync)
+		httpResponse.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if wait, ok := xhttp.PreferredWait(httpRequest.Header); ok {
+		ctx, cancel := context.WithTimeout(deviceRequest.Context(), wait)
+		defer cancel()
+		deviceRequest = deviceRequest.WithContext(ctx)
+		httpResponse.Header().Set(xhttp.PreferenceAppliedHeader, fmt.Sprintf("%s=%d", xhttp.Wait, wait/time.Second))
+	}
+
 	// deviceRequest carries the context through the routing infrastructure
 	if deviceResponse, err := mh.Router.Route(deviceRequest); err != nil {
-		code := http.StatusInternalServerError
-		switch err {
-		case context.Canceled:
-			code = http.StatusGatewayTimeout
-		case context.DeadlineExceeded:
-			code = http.StatusGatewayTimeout
-		case ErrorTransactionCancelled:
-			code = http.StatusGatewayTimeout
-		case ErrorInvalidDeviceName:
-			code = http.StatusBadRequest
-		case ErrorDeviceNotFound:
-			code = http.StatusNotFound
-		case ErrorNonUniqueID:
-			code = http.StatusBadRequest
-		case ErrorInvalidTransactionKey:
-			code = http.StatusBadRequest
-		case ErrorTransactionAlreadyRegistered:
-			code = http.StatusBadRequest
+		code := StatusCodeFor(err)
+		mh.logger().Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "Could not process device request", logging.ErrorKey(), err)
+		var encoded []byte
+		if message, ok := deviceRequest.Message.(*wrp.Message); mh.WRPErrors && ok && message.IsTransactionPart() {
+			// encode before writing anything, so that the usual JSON error can still be sent if encoding fails
+			errorResponse := wrp.NewErrorResponse(message, code, fmt.Sprintf("Could not process device request: %s", err))
+			if encodeErr := wrp.NewEncoderBytes(&encoded, responseFormat).Encode(errorResponse); encodeErr != nil {
+				mh.logger().Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "Unable to encode WRP error response", logging.ErrorKey(), encodeErr)
+				encoded = nil
+			}
 		}
 
-		mh.logger().Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "Could not process device request", logging.ErrorKey(), err)
-		xhttp.WriteErrorf(
-			httpResponse,
-			code,
-			"Could not process device request: %s",
-			err,
-		)
+		if len(encoded) > 0 {
+			httpResponse.Header().Set("Content-Type", responseFormat.ContentType())
+			httpResponse.WriteHeader(code)
+			if _, writeErr := httpResponse.Write(encoded); writeErr != nil {
+				mh.logger().Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "Error while writing WRP error response", logging.ErrorKey(), writeErr)
+			}
+		} else {
+			xhttp.WriteErrorf(
+				httpResponse,
+				code,
+				"Could not process device request: %s",
+				err,
+			)
+		}
 	} else if deviceResponse != nil {
 		if err := EncodeResponse(httpResponse, deviceResponse, responseFormat); err != nil {
 			mh.logger().Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "Error while writing transaction response", logging.ErrorKey(), err)
@@ -311,8 +392,10 @@ func (lh *ListHandler) ServeHTTP(response http.ResponseWriter, request *http.Req
 	}
 }
 
-// StatHandler is an http.Handler that returns device statistics.  The device name is specified
-// as a gorilla path variable.
+// StatHandler is an http.Handler that returns device statistics, including message and byte counts, the
+// connection time, the time of last activity, and the most recent ping round trip time.  Any services the device
+// has registered are included, and if message history is enabled, summaries of the device's most recent messages
+// are included as well.  The device name is specified as a gorilla path variable.
 type StatHandler struct {
 	Logger   log.Logger
 	Registry Registry
@@ -349,6 +432,12 @@ func (sh *StatHandler) ServeHTTP(response http.ResponseWriter, request *http.Req
 	}
 
 	data, err := d.MarshalJSON()
+	if err == nil {
+		if services, history := servicesOf(d), d.History(); len(services) > 0 || len(history) > 0 {
+			data, err = marshalStats(data, services, history)
+		}
+	}
+
 	if err != nil {
 		sh.Logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to marshal device as JSON", "deviceName", name, logging.ErrorKey(), err)
 		response.WriteHeader(http.StatusInternalServerError)
@@ -358,3 +447,24 @@ func (sh *StatHandler) ServeHTTP(response http.ResponseWriter, request *http.Req
 	response.Header().Set("Content-Type", "application/json")
 	response.Write(data)
 }
+
+// deviceStats is the JSON object written by StatHandler for a device with services or message history
+type deviceStats struct {
+	ID         ID               `json:"id"`
+	Pending    int              `json:"pending"`
+	Statistics json.RawMessage  `json:"statistics,omitempty"`
+	Services   []ServiceEntry   `json:"services,omitempty"`
+	History    []MessageSummary `json:"history,omitempty"`
+}
+
+// marshalStats adds services and history to a device's JSON object
+func marshalStats(data []byte, services []ServiceEntry, history []MessageSummary) ([]byte, error) {
+	var stats deviceStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+
+	stats.Services = services
+	stats.History = history
+	return json.Marshal(stats)
+}
@@ -8,11 +8,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 	"github.com/stretchr/testify/assert"
@@ -499,22 +501,323 @@ func testMessageHandlerServeHTTPEncodeError(t *testing.T) {
 	device.AssertExpectations(t)
 }
 
+func testMessageHandlerAsyncTimeout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		handler = MessageHandler{}
+	)
+
+	assert.Equal(DefaultMessageTimeout, handler.asyncTimeout())
+
+	handler.AsyncTimeout = 17 * time.Second
+	assert.Equal(17*time.Second, handler.asyncTimeout())
+}
+
+func testMessageHandlerServeHTTPRespondAsync(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		message = &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "test.com",
+			Destination:     "mac:123412341234",
+			TransactionUUID: "transaction-key",
+		}
+
+		requestContents []byte
+	)
+
+	require.NoError(wrp.NewEncoderBytes(&requestContents, wrp.Msgpack).Encode(message))
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		response    = httptest.NewRecorder()
+		request     = httptest.NewRequest("POST", "/foo", bytes.NewReader(requestContents)).WithContext(ctx)
+
+		router  = new(mockRouter)
+		handler = MessageHandler{
+			Logger:       logging.NewTestLogger(nil, t),
+			Router:       router,
+			AsyncTimeout: time.Minute,
+		}
+
+		routed = make(chan error, 1)
+	)
+
+	request.Header.Set("Content-Type", wrp.Msgpack.ContentType())
+	request.Header.Set(xhttp.PreferHeader, xhttp.RespondAsync)
+
+	router.On(
+		"Route",
+		mock.MatchedBy(func(candidate *Request) bool {
+			_, ok := candidate.Context().Deadline()
+			return candidate.Message != nil && ok
+		}),
+	).Once().Return(nil, errors.New("expected")).Run(func(arguments mock.Arguments) {
+		// the background delivery must not be affected by the end of the HTTP request
+		routed <- arguments.Get(0).(*Request).Context().Err()
+	})
+
+	handler.ServeHTTP(response, request)
+	cancel()
+	assert.Equal(http.StatusAccepted, response.Code)
+	assert.Equal(xhttp.RespondAsync, response.HeaderMap.Get(xhttp.PreferenceAppliedHeader))
+	assert.Equal(0, response.Body.Len())
+
+	select {
+	case err := <-routed:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The message was not routed")
+	}
+
+	router.AssertExpectations(t)
+}
+
+func testMessageHandlerServeHTTPAsyncLimit(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		message = &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "test.com",
+			Destination:     "mac:123412341234",
+			TransactionUUID: "transaction-key",
+		}
+
+		requestContents []byte
+	)
+
+	require.NoError(wrp.NewEncoderBytes(&requestContents, wrp.Msgpack).Encode(message))
+
+	var (
+		router  = new(mockRouter)
+		handler = MessageHandler{
+			// the background delivery may still be logging when this test ends, so a test logger cannot be used
+			Logger:       logging.DefaultLogger(),
+			Router:       router,
+			AsyncTimeout: time.Minute,
+			AsyncLimit:   1,
+		}
+
+		blocker = make(chan time.Time)
+		routed  = make(chan struct{})
+	)
+
+	router.On(
+		"Route",
+		mock.MatchedBy(func(candidate *Request) bool {
+			_, ok := candidate.Context().Deadline()
+			return ok
+		}),
+	).Once().Return(nil, errors.New("expected")).WaitUntil(blocker).Run(func(mock.Arguments) {
+		close(routed)
+	})
+
+	router.On(
+		"Route",
+		mock.MatchedBy(func(candidate *Request) bool {
+			_, ok := candidate.Context().Deadline()
+			return !ok
+		}),
+	).Once().Return(nil, errors.New("expected"))
+
+	newRequest := func() *http.Request {
+		request := httptest.NewRequest("POST", "/foo", bytes.NewReader(requestContents))
+		request.Header.Set("Content-Type", wrp.Msgpack.ContentType())
+		request.Header.Set(xhttp.PreferHeader, xhttp.RespondAsync)
+		return request
+	}
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, newRequest())
+	assert.Equal(http.StatusAccepted, response.Code)
+
+	// the only background slot is in use, so the next message is delivered synchronously
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, newRequest())
+	assert.Equal(http.StatusInternalServerError, response.Code)
+	assert.Empty(response.HeaderMap.Get(xhttp.PreferenceAppliedHeader))
+
+	close(blocker)
+	select {
+	case <-routed:
+	case <-time.After(5 * time.Second):
+		assert.Fail("The message was not routed")
+	}
+
+	router.AssertExpectations(t)
+}
+
+func testMessageHandlerServeHTTPWaitZero(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		message = &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "test.com",
+			Destination:     "mac:123412341234",
+			TransactionUUID: "transaction-key",
+		}
+
+		requestContents []byte
+	)
+
+	require.NoError(wrp.NewEncoderBytes(&requestContents, wrp.Msgpack).Encode(message))
+
+	var (
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo", bytes.NewReader(requestContents))
+
+		router  = new(mockRouter)
+		handler = MessageHandler{
+			Logger: logging.NewTestLogger(nil, t),
+			Router: router,
+		}
+	)
+
+	request.Header.Set("Content-Type", wrp.Msgpack.ContentType())
+	request.Header.Set(xhttp.PreferHeader, "wait=0")
+
+	// wait=0 is not a preference, so the request context is used as is
+	router.On(
+		"Route",
+		mock.MatchedBy(func(candidate *Request) bool {
+			_, ok := candidate.Context().Deadline()
+			return !ok
+		}),
+	).Once().Return(nil, context.DeadlineExceeded)
+
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusGatewayTimeout, response.Code)
+	assert.Empty(response.HeaderMap.Get(xhttp.PreferenceAppliedHeader))
+
+	router.AssertExpectations(t)
+}
+
+func testMessageHandlerServeHTTPWait(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		message = &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "test.com",
+			Destination:     "mac:123412341234",
+			TransactionUUID: "transaction-key",
+		}
+
+		requestContents []byte
+	)
+
+	require.NoError(wrp.NewEncoderBytes(&requestContents, wrp.Msgpack).Encode(message))
+
+	var (
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo", bytes.NewReader(requestContents))
+
+		router  = new(mockRouter)
+		handler = MessageHandler{
+			Logger: logging.NewTestLogger(nil, t),
+			Router: router,
+		}
+
+		start = time.Now()
+	)
+
+	request.Header.Set("Content-Type", wrp.Msgpack.ContentType())
+	request.Header.Set(xhttp.PreferHeader, "wait=30")
+
+	router.On(
+		"Route",
+		mock.MatchedBy(func(candidate *Request) bool {
+			deadline, ok := candidate.Context().Deadline()
+			return ok && !deadline.Before(start.Add(30*time.Second)) && deadline.Before(time.Now().Add(31*time.Second))
+		}),
+	).Once().Return(nil, context.DeadlineExceeded)
+
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusGatewayTimeout, response.Code)
+	assert.Equal("wait=30", response.HeaderMap.Get(xhttp.PreferenceAppliedHeader))
+
+	router.AssertExpectations(t)
+}
+
+func testMessageHandlerServeHTTPWRPError(t *testing.T, responseFormat wrp.Format) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		router  = new(mockRouter)
+		handler = MessageHandler{Router: router, WRPErrors: true}
+
+		message = &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "test.com",
+			Destination:     "mac:123412341234",
+			TransactionUUID: "test-transaction",
+		}
+
+		requestContents []byte
+		response        = httptest.NewRecorder()
+	)
+
+	require.NoError(wrp.NewEncoderBytes(&requestContents, wrp.Msgpack).Encode(message))
+	router.On("Route", mock.AnythingOfType("*device.Request")).
+		Return(nil, &DeviceNotFoundError{ID: ID("mac:123412341234")}).
+		Once()
+
+	request := httptest.NewRequest("POST", "/foo", bytes.NewReader(requestContents))
+	request.Header.Set("Accept", responseFormat.ContentType())
+	handler.ServeHTTP(response, request)
+
+	assert.Equal(http.StatusNotFound, response.Code)
+	assert.Equal(responseFormat.ContentType(), response.HeaderMap.Get("Content-Type"))
+
+	var errorResponse wrp.Message
+	require.NoError(wrp.NewDecoder(response.Body, responseFormat).Decode(&errorResponse))
+	assert.Equal("mac:123412341234", errorResponse.Source)
+	assert.Equal("test.com", errorResponse.Destination)
+	assert.Equal("test-transaction", errorResponse.TransactionUUID)
+	require.NotNil(errorResponse.Status)
+	assert.Equal(int64(http.StatusNotFound), *errorResponse.Status)
+
+	router.AssertExpectations(t)
+}
+
 func TestMessageHandler(t *testing.T) {
 	t.Run("Logger", testMessageHandlerLogger)
+	t.Run("AsyncTimeout", testMessageHandlerAsyncTimeout)
 
 	t.Run("ServeHTTP", func(t *testing.T) {
 		t.Run("DecodeError", testMessageHandlerServeHTTPDecodeError)
+		t.Run("RespondAsync", testMessageHandlerServeHTTPRespondAsync)
+		t.Run("AsyncLimit", testMessageHandlerServeHTTPAsyncLimit)
+		t.Run("Wait", testMessageHandlerServeHTTPWait)
+		t.Run("WaitZero", testMessageHandlerServeHTTPWaitZero)
 		t.Run("EncodeError", testMessageHandlerServeHTTPEncodeError)
 
 		t.Run("RouteError", func(t *testing.T) {
 			testMessageHandlerServeHTTPRouteError(t, ErrorInvalidDeviceName, http.StatusBadRequest)
 			testMessageHandlerServeHTTPRouteError(t, ErrorDeviceNotFound, http.StatusNotFound)
+			testMessageHandlerServeHTTPRouteError(t, &DeviceNotFoundError{ID: ID("mac:123412341234")}, http.StatusNotFound)
+			testMessageHandlerServeHTTPRouteError(t, &ClosedError{ID: ID("mac:123412341234")}, http.StatusServiceUnavailable)
+			testMessageHandlerServeHTTPRouteError(t, ErrorServiceNotRegistered, http.StatusNotFound)
 			testMessageHandlerServeHTTPRouteError(t, ErrorNonUniqueID, http.StatusBadRequest)
 			testMessageHandlerServeHTTPRouteError(t, ErrorInvalidTransactionKey, http.StatusBadRequest)
 			testMessageHandlerServeHTTPRouteError(t, ErrorTransactionAlreadyRegistered, http.StatusBadRequest)
+			testMessageHandlerServeHTTPRouteError(t, ErrorMessageTooLarge, http.StatusRequestEntityTooLarge)
 			testMessageHandlerServeHTTPRouteError(t, errors.New("random error"), http.StatusInternalServerError)
 		})
 
+		t.Run("WRPError", func(t *testing.T) {
+			for _, responseFormat := range []wrp.Format{wrp.Msgpack, wrp.JSON} {
+				testMessageHandlerServeHTTPWRPError(t, responseFormat)
+			}
+		})
+
 		t.Run("Event", func(t *testing.T) {
 			for _, requestFormat := range []wrp.Format{wrp.Msgpack, wrp.JSON} {
 				testMessageHandlerServeHTTPEvent(t, requestFormat)
@@ -849,6 +1152,8 @@ func testStatHandlerSuccess(t *testing.T) {
 	router.Handle("/{deviceID}", &handler)
 	registry.On("Get", ID("mac:112233445566")).Return(device, true).Once()
 	device.On("MarshalJSON").Return([]byte(`{"foo": "bar"}`), (error)(nil)).Once()
+	device.On("Services").Return([]ServiceEntry(nil)).Once()
+	device.On("History").Return([]MessageSummary(nil)).Once()
 
 	router.ServeHTTP(response, request)
 	assert.Equal(http.StatusOK, response.Code)
@@ -858,6 +1163,119 @@ func testStatHandlerSuccess(t *testing.T) {
 	device.AssertExpectations(t)
 }
 
+func testStatHandlerHistory(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		registry = new(mockRegistry)
+		device   = new(mockDevice)
+
+		handler = StatHandler{
+			Logger:   logging.NewTestLogger(nil, t),
+			Registry: registry,
+			Variable: "deviceID",
+		}
+
+		timestamp = time.Date(2018, time.March, 1, 12, 0, 0, 0, time.UTC)
+		router    = mux.NewRouter()
+		request   = httptest.NewRequest("GET", "/mac:112233445566", nil)
+		response  = httptest.NewRecorder()
+	)
+
+	router.Handle("/{deviceID}", &handler)
+	registry.On("Get", ID("mac:112233445566")).Return(device, true).Once()
+	device.On("MarshalJSON").Return([]byte(`{"id": "mac:112233445566", "pending": 0, "statistics": {"bytesSent": 0}}`), (error)(nil)).Once()
+	device.On("Services").Return([]ServiceEntry(nil)).Once()
+	device.On("History").Return([]MessageSummary{
+		{Direction: InboundDirection, Type: "SimpleEvent", Destination: "event:test", Size: 123, Timestamp: timestamp},
+	}).Once()
+
+	router.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+	assert.JSONEq(
+		`{"id": "mac:112233445566", "pending": 0, "statistics": {"bytesSent": 0}, "history": [{"direction": "inbound", "type": "SimpleEvent", "dest": "event:test", "size": 123, "timestamp": "2018-03-01T12:00:00Z"}]}`,
+		response.Body.String(),
+	)
+
+	registry.AssertExpectations(t)
+	device.AssertExpectations(t)
+}
+
+func testStatHandlerServices(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		registry = new(mockRegistry)
+		device   = new(mockDevice)
+
+		handler = StatHandler{
+			Logger:   logging.NewTestLogger(nil, t),
+			Registry: registry,
+			Variable: "deviceID",
+		}
+
+		timestamp = time.Date(2018, time.March, 1, 12, 0, 0, 0, time.UTC)
+		router    = mux.NewRouter()
+		request   = httptest.NewRequest("GET", "/mac:112233445566", nil)
+		response  = httptest.NewRecorder()
+	)
+
+	router.Handle("/{deviceID}", &handler)
+	registry.On("Get", ID("mac:112233445566")).Return(device, true).Once()
+	device.On("MarshalJSON").Return([]byte(`{"id": "mac:112233445566", "pending": 0, "statistics": {"bytesSent": 0}}`), (error)(nil)).Once()
+	device.On("Services").Return([]ServiceEntry{
+		{Name: "config", URL: "tcp://127.0.0.1:6666", RegisteredAt: timestamp, LastAlive: timestamp.Add(time.Minute)},
+	}).Once()
+
+	device.On("History").Return([]MessageSummary(nil)).Once()
+
+	router.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.JSONEq(
+		`{"id": "mac:112233445566", "pending": 0, "statistics": {"bytesSent": 0}, "services": [{"name": "config", "url": "tcp://127.0.0.1:6666", "registeredAt": "2018-03-01T12:00:00Z", "lastAlive": "2018-03-01T12:01:00Z"}]}`,
+		response.Body.String(),
+	)
+
+	registry.AssertExpectations(t)
+	device.AssertExpectations(t)
+}
+
+func TestMarshalStats(t *testing.T) {
+	var (
+		history = []MessageSummary{{Direction: InboundDirection, Type: "SimpleEvent", Size: 1, Timestamp: time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC)}}
+		entry   = `[{"direction": "inbound", "type": "SimpleEvent", "size": 1, "timestamp": "2018-03-01T00:00:00Z"}]`
+	)
+
+	testData := []struct {
+		data     string
+		expected string
+	}{
+		{
+			`{"id": "mac:112233445566", "pending": 2, "statistics": {"bytesSent": 1}}`,
+			`{"id": "mac:112233445566", "pending": 2, "statistics": {"bytesSent": 1}, "history": ` + entry + `}`,
+		},
+		{`{"id": "mac:112233445566", "pending": 0}`, `{"id": "mac:112233445566", "pending": 0, "history": ` + entry + `}`},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			actual, err := marshalStats([]byte(record.data), nil, history)
+			require.NoError(err)
+			assert.JSONEq(record.expected, string(actual))
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		actual, err := marshalStats([]byte("not json"), nil, history)
+		assert.Nil(t, actual)
+		assert.Error(t, err)
+	})
+}
+
 func TestStatHandler(t *testing.T) {
 	t.Run("NoPathVariables", testStatHandlerNoPathVariables)
 	t.Run("NoDeviceName", testStatHandlerNoDeviceName)
@@ -865,4 +1283,6 @@ func TestStatHandler(t *testing.T) {
 	t.Run("MissingDevice", testStatHandlerMissingDevice)
 	t.Run("MarshalJSONFailed", testStatHandlerMarshalJSONFailed)
 	t.Run("Success", testStatHandlerSuccess)
+	t.Run("History", testStatHandlerHistory)
+	t.Run("Services", testStatHandlerServices)
 }
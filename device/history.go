@@ -0,0 +1,87 @@
+package device
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/ring"
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+// MessageSummary is a brief record of a single WRP message exchanged with a device.  Only metadata
+// is kept, never the message payload.
+type MessageSummary struct {
+	// Direction is either InboundDirection or OutboundDirection
+	Direction string `json:"direction"`
+
+	// Type is the friendly name of the WRP message type
+	Type string `json:"type"`
+
+	// Destination is the WRP destination, if the message was routable
+	Destination string `json:"dest,omitempty"`
+
+	// Size is the size in bytes of the encoded message
+	Size int `json:"size"`
+
+	// Timestamp is when the message was received from or written to the device
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// newMessageSummary creates a MessageSummary for a WRP message
+func newMessageSummary(direction string, message wrp.Typed, size int) MessageSummary {
+	summary := MessageSummary{
+		Direction: direction,
+		Size:      size,
+		Timestamp: time.Now().UTC(),
+	}
+
+	if message != nil {
+		summary.Type = message.MessageType().FriendlyName()
+		if routable, ok := message.(wrp.Routable); ok {
+			summary.Destination = routable.To()
+		}
+	}
+
+	return summary
+}
+
+// History is a bounded ring buffer of the most recent messages exchanged with a device.  A nil History
+// records nothing, which is the default since history costs memory for every connected device.
+type History struct {
+	lock      sync.Mutex
+	summaries *ring.Ring[MessageSummary]
+}
+
+// NewHistory creates a History that retains the given number of summaries.  If size is nonpositive,
+// this function returns nil.
+func NewHistory(size int) *History {
+	if size < 1 {
+		return nil
+	}
+
+	return &History{
+		summaries: ring.New[MessageSummary](size),
+	}
+}
+
+// Add records a summary, overwriting the oldest summary if this History is full
+func (h *History) Add(s MessageSummary) {
+	if h == nil {
+		return
+	}
+
+	h.lock.Lock()
+	h.summaries.Add(s)
+	h.lock.Unlock()
+}
+
+// Summaries returns a copy of the recorded summaries, oldest first
+func (h *History) Summaries() []MessageSummary {
+	if h == nil {
+		return nil
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.summaries.Values()
+}
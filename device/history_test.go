@@ -0,0 +1,106 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMessageSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	summary := newMessageSummary(InboundDirection, &wrp.Message{Type: wrp.SimpleEventMessageType, Destination: "event:test"}, 123)
+	assert.Equal(InboundDirection, summary.Direction)
+	assert.Equal(wrp.SimpleEventMessageType.FriendlyName(), summary.Type)
+	assert.Equal("event:test", summary.Destination)
+	assert.Equal(123, summary.Size)
+	assert.False(summary.Timestamp.IsZero())
+
+	summary = newMessageSummary(OutboundDirection, nil, 45)
+	assert.Equal(OutboundDirection, summary.Direction)
+	assert.Empty(summary.Type)
+	assert.Empty(summary.Destination)
+	assert.Equal(45, summary.Size)
+}
+
+func TestHistory(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, size := range []int{-1, 0} {
+			h := NewHistory(size)
+			assert.Nil(h)
+			h.Add(MessageSummary{Size: 1})
+			assert.Empty(h.Summaries())
+		}
+	})
+
+	t.Run("Wraparound", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			h      = NewHistory(3)
+			sizes  = func() (output []int) {
+				for _, s := range h.Summaries() {
+					output = append(output, s.Size)
+				}
+
+				return
+			}
+		)
+
+		assert.Empty(h.Summaries())
+
+		h.Add(MessageSummary{Size: 1})
+		h.Add(MessageSummary{Size: 2})
+		assert.Equal([]int{1, 2}, sizes())
+
+		h.Add(MessageSummary{Size: 3})
+		assert.Equal([]int{1, 2, 3}, sizes())
+
+		h.Add(MessageSummary{Size: 4})
+		h.Add(MessageSummary{Size: 5})
+		assert.Equal([]int{3, 4, 5}, sizes())
+
+		h.Add(MessageSummary{Size: 6})
+		assert.Equal([]int{4, 5, 6}, sizes())
+	})
+}
+
+func TestManagerMessageHistory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		manager, connection, events, stop = startInterceptorTest(t, &Options{MessageHistorySize: 10})
+		data                              []byte
+	)
+
+	defer stop()
+	require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(
+		&wrp.Message{Type: wrp.SimpleEventMessageType, Source: string(testDeviceIDs[0]), Destination: "event:test"},
+	))
+
+	require.NoError(connection.WriteMessage(websocket.BinaryMessage, data))
+	waitForEvent(t, events, MessageReceived)
+
+	_, err := manager.Route(&Request{
+		Message: &wrp.Message{Type: wrp.SimpleEventMessageType, Source: "test", Destination: string(testDeviceIDs[0]) + "/service"},
+		Format:  wrp.Msgpack,
+	})
+
+	require.NoError(err)
+	waitForEvent(t, events, MessageSent)
+
+	d, ok := manager.Get(testDeviceIDs[0])
+	require.True(ok)
+
+	history := d.History()
+	require.Len(history, 2)
+	assert.Equal(InboundDirection, history[0].Direction)
+	assert.Equal("event:test", history[0].Destination)
+	assert.Equal(len(data), history[0].Size)
+	assert.Equal(OutboundDirection, history[1].Direction)
+	assert.Equal(string(testDeviceIDs[0])+"/service", history[1].Destination)
+}
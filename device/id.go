@@ -34,6 +34,17 @@ var (
 	)
 )
 
+// ParseService returns the service portion of a raw device name, e.g. "config" for "mac:112233445566/config".
+// If the device name is invalid or has no service, this function returns false.
+func ParseService(deviceName string) (string, bool) {
+	match := idPattern.FindStringSubmatch(deviceName)
+	if match == nil || len(match[3]) < 2 {
+		return "", false
+	}
+
+	return match[3][1:], true
+}
+
 // IntToMAC accepts a 64-bit integer and formats that as a device MAC address identifier
 // The returned ID will be of the form mac:XXXXXXXXXXXX, where X is a hexadecimal digit using
 // lowercased letters.
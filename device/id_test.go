@@ -58,6 +58,29 @@ func TestParseID(t *testing.T) {
 	}
 }
 
+func TestParseService(t *testing.T) {
+	assert := assert.New(t)
+	testData := []struct {
+		deviceName string
+		expected   string
+		ok         bool
+	}{
+		{"mac:112233445566", "", false},
+		{"mac:112233445566/", "", false},
+		{"mac:112233445566/config", "config", true},
+		{"mac:112233445566/config/foo/bar", "config", true},
+		{"uuid:anything Goes!/iot", "iot", true},
+		{"invalid:a-BB-44-55/config", "", false},
+	}
+
+	for _, record := range testData {
+		t.Logf("%#v", record)
+		service, ok := ParseService(record.deviceName)
+		assert.Equal(record.expected, service)
+		assert.Equal(record.ok, ok)
+	}
+}
+
 func TestIDHashParser(t *testing.T) {
 	var (
 		assert            = assert.New(t)
@@ -0,0 +1,107 @@
+package device
+
+import (
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+// Interceptor is a hook invoked with each WRP message that passes through a device connection.  An Interceptor
+// may inspect the message or modify it in place, e.g. for auditing, enrichment, or protocol shims.  The message
+// is never one supplied by a caller: outbound interceptors see a copy of the caller's message, and inbound
+// interceptors see the message freshly decoded from the device.  Returning a non-nil error drops the message.
+type Interceptor func(Interface, *wrp.Message) error
+
+// Interceptors is an ordered chain of Interceptor hooks
+type Interceptors []Interceptor
+
+// Intercept invokes each Interceptor in order, stopping at the first error.  Each Interceptor sees
+// the message as modified by the ones before it.
+func (is Interceptors) Intercept(d Interface, message *wrp.Message) error {
+	for _, i := range is {
+		if err := i(d, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// interceptInbound enforces the metadata budget and runs the inbound interceptors against a message decoded
+// from a device.  If the message may have been modified, the returned contents are the message reencoded as
+// Msgpack.  Otherwise, the original data is returned as is.  Since an interceptor can modify the message in
+// place, any configured interceptor causes the message to be reencoded.
+func (m *manager) interceptInbound(d *device, message *wrp.Message, data []byte, encoder wrp.Encoder) ([]byte, error) {
+	var changed bool
+	if m.enforceMetadata != nil {
+		var err error
+		if changed, err = m.enforceMetadata(d, message); err != nil {
+			d.errorLog.Log(logging.MessageKey(), "inbound message dropped by metadata budget", logging.ErrorKey(), err)
+			return nil, err
+		}
+	}
+
+	if len(m.inboundInterceptors) > 0 {
+		if err := m.inboundInterceptors.Intercept(d, message); err != nil {
+			d.errorLog.Log(logging.MessageKey(), "inbound message dropped by interceptor", logging.ErrorKey(), err)
+			return nil, err
+		}
+
+		changed = true
+	}
+
+	if !changed {
+		return data, nil
+	}
+
+	var contents []byte
+	encoder.ResetBytes(&contents)
+	err := encoder.Encode(message)
+	encoder.ResetBytes(nil)
+	return contents, err
+}
+
+// dropInbound handles an inbound message dropped by interception.  If the message is part of a transaction, the
+// transaction is failed with the given error, so that the goroutine waiting on it does not have to time out.
+func (m *manager) dropInbound(d *device, message *wrp.Message, event *Event, err error) {
+	if !message.IsTransactionPart() {
+		return
+	}
+
+	if failErr := d.transactions.Fail(message.TransactionKey(), err); failErr != nil {
+		d.errorLog.Log(logging.MessageKey(), "Error while failing transaction", logging.ErrorKey(), failErr)
+		return
+	}
+
+	event.Type = TransactionBroken
+	event.Error = err
+	m.dispatch(event)
+}
+
+// interceptOutbound runs the outbound interceptors against a request prior to encoding.  If there are no
+// outbound interceptors, this method returns a nil message.  The request's message belongs to the caller,
+// and may be shared with requests to other devices, so interceptors always see a complete copy of it.
+func (m *manager) interceptOutbound(d *device, request *Request) (wrp.Typed, error) {
+	if len(m.outboundInterceptors) == 0 {
+		return nil, nil
+	}
+
+	data := request.Contents
+	if request.Format != wrp.Msgpack || len(data) == 0 {
+		data = nil
+		if err := wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(request.Message); err != nil {
+			return nil, err
+		}
+	}
+
+	message := new(wrp.Message)
+	if err := wrp.NewDecoderBytes(data, wrp.Msgpack).Decode(message); err != nil {
+		return nil, err
+	}
+
+	if err := m.outboundInterceptors.Intercept(d, message); err != nil {
+		d.errorLog.Log(logging.MessageKey(), "outbound message dropped by interceptor", logging.ErrorKey(), err)
+		return nil, err
+	}
+
+	return message, nil
+}
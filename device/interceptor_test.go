@@ -0,0 +1,296 @@
+package device
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptors(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.NoError(Interceptors(nil).Intercept(nil, new(wrp.Message)))
+	})
+
+	t.Run("Order", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			message = new(wrp.Message)
+			order   []string
+
+			is = Interceptors{
+				func(_ Interface, m *wrp.Message) error {
+					order = append(order, "first")
+					m.Source = "second"
+					return nil
+				},
+				func(_ Interface, m *wrp.Message) error { order = append(order, m.Source); return nil },
+			}
+		)
+
+		assert.NoError(is.Intercept(nil, message))
+		assert.Equal([]string{"first", "second"}, order)
+		assert.Equal("second", message.Source)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			expectedError = errors.New("expected")
+			called        = false
+
+			is = Interceptors{
+				func(Interface, *wrp.Message) error { return expectedError },
+				func(Interface, *wrp.Message) error { called = true; return nil },
+			}
+		)
+
+		assert.Equal(expectedError, is.Intercept(nil, new(wrp.Message)))
+		assert.False(called)
+	})
+}
+
+// startInterceptorTest connects a single device to a manager configured with the given interceptors.
+// Device events, including a copy of their contents, are sent to the returned channel.
+func startInterceptorTest(t *testing.T, o *Options) (Manager, *websocket.Conn, <-chan *Event, func()) {
+	events := make(chan *Event, 10)
+	o.AuthDelay = time.Hour
+	o.Listeners = []Listener{
+		func(e *Event) {
+			// events are reused, so copy what the tests need
+			events <- &Event{Type: e.Type, Error: e.Error, Contents: append([]byte(nil), e.Contents...)}
+		},
+	}
+
+	manager, server, connectURL := startWebsocketServer(o)
+	connection, _, err := DefaultDialer().DialDevice(string(testDeviceIDs[0]), connectURL, nil)
+	if err != nil {
+		server.Close()
+		require.NoError(t, err)
+	}
+
+	waitForEvent(t, events, Connect)
+	return manager, connection, events, func() {
+		connection.Close()
+		server.Close()
+	}
+}
+
+func testInterceptorInbound(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		dropError = errors.New("dropped")
+
+		_, connection, events, stop = startInterceptorTest(t, &Options{
+			InboundInterceptors: []Interceptor{
+				func(d Interface, m *wrp.Message) error {
+					if m.Destination == "event:drop" {
+						return dropError
+					}
+
+					m.Headers = append(m.Headers, "device="+string(d.ID()))
+					return nil
+				},
+			},
+		})
+	)
+
+	defer stop()
+	for _, destination := range []string{"event:drop", "event:keep"} {
+		var data []byte
+		require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(
+			&wrp.Message{Type: wrp.SimpleEventMessageType, Source: string(testDeviceIDs[0]), Destination: destination},
+		))
+
+		require.NoError(connection.WriteMessage(websocket.BinaryMessage, data))
+	}
+
+	received := waitForEvent(t, events, MessageReceived)
+	require.NotNil(received)
+
+	var message wrp.Message
+	require.NoError(wrp.NewDecoderBytes(received.Contents, wrp.Msgpack).Decode(&message))
+	assert.Equal("event:keep", message.Destination)
+	assert.Equal([]string{"device=" + string(testDeviceIDs[0])}, message.Headers)
+
+	select {
+	case e := <-events:
+		assert.Fail("Unexpected event", "event: %s", e.Type)
+	default:
+	}
+}
+
+func testInterceptorOutbound(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		dropError = errors.New("dropped")
+
+		manager, connection, events, stop = startInterceptorTest(t, &Options{
+			OutboundInterceptors: []Interceptor{
+				func(d Interface, m *wrp.Message) error {
+					if m.Source == "drop" {
+						return dropError
+					}
+
+					m.Headers = append(m.Headers, "device="+string(d.ID()))
+					return nil
+				},
+			},
+		})
+
+		newRequest = func(source string) *Request {
+			request := &Request{
+				Message: &wrp.Message{
+					Type:        wrp.SimpleEventMessageType,
+					Source:      source,
+					Destination: string(testDeviceIDs[0]) + "/service",
+				},
+				Format: wrp.Msgpack,
+			}
+
+			// precomputed contents must not bypass the interceptors
+			require.NoError(wrp.NewEncoderBytes(&request.Contents, wrp.Msgpack).Encode(request.Message))
+			return request
+		}
+	)
+
+	defer stop()
+
+	response, err := manager.Route(newRequest("drop"))
+	assert.Nil(response)
+	assert.Equal(dropError, err)
+
+	failed := waitForEvent(t, events, MessageFailed)
+	require.NotNil(failed)
+	assert.Equal(dropError, failed.Error)
+
+	_, ok := manager.Get(testDeviceIDs[0])
+	assert.True(ok)
+
+	keep := newRequest("keep")
+	response, err = manager.Route(keep)
+	assert.Nil(response)
+	assert.NoError(err)
+
+	connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	messageType, data, err := connection.ReadMessage()
+	require.NoError(err)
+	assert.Equal(websocket.BinaryMessage, messageType)
+
+	var message wrp.Message
+	require.NoError(wrp.NewDecoderBytes(data, wrp.Msgpack).Decode(&message))
+	assert.Equal("keep", message.Source)
+	assert.Equal([]string{"device=" + string(testDeviceIDs[0])}, message.Headers)
+
+	// the caller's message is never modified by interceptors
+	assert.Empty(keep.Message.(*wrp.Message).Headers)
+}
+
+func testInterceptorInboundUnchanged(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		d       = newDevice(deviceOptions{ID: testDeviceIDs[0], Logger: logging.NewTestLogger(nil, t)})
+		data    []byte
+
+		m = &manager{
+			enforceMetadata: func(Interface, *wrp.Message) (bool, error) { return false, nil },
+		}
+	)
+
+	require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(
+		&wrp.Message{Type: wrp.SimpleEventMessageType, Source: string(testDeviceIDs[0]), Destination: "event:keep"},
+	))
+
+	var message wrp.Message
+	require.NoError(wrp.NewDecoderBytes(data, wrp.Msgpack).Decode(&message))
+
+	// when nothing modified the message, the device's data is passed through without reencoding
+	contents, err := m.interceptInbound(d, &message, data, wrp.NewEncoder(nil, wrp.Msgpack))
+	assert.NoError(err)
+	require.NotEmpty(contents)
+	assert.True(&data[0] == &contents[0])
+}
+
+func testInterceptorInboundTransaction(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		dropError = errors.New("dropped")
+
+		manager, connection, events, stop = startInterceptorTest(t, &Options{
+			InboundInterceptors: []Interceptor{
+				func(d Interface, m *wrp.Message) error {
+					if m.Source == "drop" {
+						return dropError
+					}
+
+					return nil
+				},
+			},
+		})
+
+		result = make(chan error, 1)
+	)
+
+	defer stop()
+	go func() {
+		_, err := manager.Route(&Request{
+			Message: &wrp.Message{
+				Type:            wrp.SimpleRequestResponseMessageType,
+				Source:          "test",
+				Destination:     string(testDeviceIDs[0]) + "/service",
+				TransactionUUID: "transaction-key",
+			},
+			Format: wrp.Msgpack,
+		})
+
+		result <- err
+	}()
+
+	connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := connection.ReadMessage()
+	require.NoError(err)
+
+	var request wrp.Message
+	require.NoError(wrp.NewDecoderBytes(data, wrp.Msgpack).Decode(&request))
+
+	data = nil
+	require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(
+		&wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "drop",
+			Destination:     request.Source,
+			TransactionUUID: request.TransactionUUID,
+		},
+	))
+
+	require.NoError(connection.WriteMessage(websocket.BinaryMessage, data))
+
+	// the waiting transaction fails with the interceptor's error rather than timing out
+	select {
+	case err := <-result:
+		assert.Equal(dropError, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The transaction did not complete")
+	}
+
+	broken := waitForEvent(t, events, TransactionBroken)
+	require.NotNil(broken)
+	assert.Equal(dropError, broken.Error)
+}
+
+func TestInterceptor(t *testing.T) {
+	t.Run("Inbound", testInterceptorInbound)
+	t.Run("InboundUnchanged", testInterceptorInboundUnchanged)
+	t.Run("InboundTransaction", testInterceptorInboundTransaction)
+	t.Run("Outbound", testInterceptorOutbound)
+}
@@ -0,0 +1,164 @@
+package device
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/webpa-common/convey"
+	"github.com/Comcast/webpa-common/secure/handler"
+)
+
+// DefaultKeepAliveClass is the class of devices which match no KeepAlivePolicy
+const DefaultKeepAliveClass = "default"
+
+// KeepAlivePolicy assigns a keepalive schedule to a class of devices.  Devices are classified at connect time,
+// using their convey metadata and the partner ids in the JWT that authorized the connection.  This allows gentler schedules for devices, such as low-power devices,
+// which cannot tolerate the default ping traffic.
+//
+// A device matches a policy when it matches every criterion the policy supplies.  A policy with no criteria
+// matches every device.  Any timing value that is not supplied falls back to the corresponding Options value.
+type KeepAlivePolicy struct {
+	// Class is the name of the device class this policy applies to, used in logging
+	Class string
+
+	// Convey maps convey keys onto the values that match.  For each key, the device's convey value, formatted
+	// as a string, must be one of the listed values.
+	Convey map[string][]string
+
+	// Partners are the partner identifiers that match.  If supplied, one of the partner ids in the device's JWT,
+	// as returned by handler.PartnerIDsFromContext, must be one of these.  Devices connecting without a JWT never
+	// match a policy that supplies partners.
+	Partners []string
+
+	// PingPeriod is the time between pings sent to matching devices
+	PingPeriod time.Duration
+
+	// IdlePeriod is the length of time a matching device may go without sending traffic, including pongs,
+	// before it is disconnected
+	IdlePeriod time.Duration
+
+	// WriteTimeout is the write timeout for each matching device's websocket
+	WriteTimeout time.Duration
+}
+
+// matches tests whether a device with the given convey metadata and partner ids belongs to this policy's class
+func (p *KeepAlivePolicy) matches(c convey.C, partnerIDs []string) bool {
+	for key, values := range p.Convey {
+		raw, ok := c[key]
+		if !ok {
+			return false
+		}
+
+		if !contains(values, fmt.Sprint(raw)) {
+			return false
+		}
+	}
+
+	if len(p.Partners) > 0 && !containsAny(p.Partners, partnerIDs) {
+		return false
+	}
+
+	return true
+}
+
+func containsAny(values []string, candidates []string) bool {
+	for _, candidate := range candidates {
+		if contains(values, candidate) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, candidate string) bool {
+	for _, v := range values {
+		if v == candidate {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keepAlive is the resolved keepalive schedule for a class of devices
+type keepAlive struct {
+	class         string
+	pingPeriod    time.Duration
+	readDeadline  func() time.Time
+	writeDeadline func() time.Time
+}
+
+// keepAlives is the ordered policy table for a Manager, along with the schedule for devices matching no policy
+type keepAlives struct {
+	policies []KeepAlivePolicy
+	classes  []keepAlive
+	fallback keepAlive
+}
+
+// newKeepAlives resolves the policy table in the given options.  Each policy's unset timing values are taken
+// from the options.
+func newKeepAlives(o *Options) *keepAlives {
+	var (
+		now      = o.now()
+		policies = o.keepAlivePolicies()
+		ka       = &keepAlives{
+			policies: policies,
+			classes:  make([]keepAlive, len(policies)),
+			fallback: keepAlive{
+				class:         DefaultKeepAliveClass,
+				pingPeriod:    o.pingPeriod(),
+				readDeadline:  NewDeadline(o.idlePeriod(), now),
+				writeDeadline: NewDeadline(o.writeTimeout(), now),
+			},
+		}
+	)
+
+	for i, p := range policies {
+		var (
+			pingPeriod   = o.pingPeriod()
+			idlePeriod   = o.idlePeriod()
+			writeTimeout = o.writeTimeout()
+		)
+
+		if p.PingPeriod > 0 {
+			pingPeriod = p.PingPeriod
+		}
+
+		if p.IdlePeriod > 0 {
+			idlePeriod = p.IdlePeriod
+		}
+
+		if p.WriteTimeout > 0 {
+			writeTimeout = p.WriteTimeout
+		}
+
+		class := p.Class
+		if len(class) == 0 {
+			class = fmt.Sprintf("policy-%d", i)
+		}
+
+		ka.classes[i] = keepAlive{
+			class:         class,
+			pingPeriod:    pingPeriod,
+			readDeadline:  NewDeadline(idlePeriod, now),
+			writeDeadline: NewDeadline(writeTimeout, now),
+		}
+	}
+
+	return ka
+}
+
+// get returns the schedule of the first policy a device matches, or the fallback if no policy matches.
+// Partner ids are only ever taken from the connection's verified JWT claims, never from headers the device sends.
+func (ka *keepAlives) get(c convey.C, request *http.Request) keepAlive {
+	partnerIDs, _ := handler.PartnerIDsFromContext(request.Context())
+	for i := range ka.policies {
+		if ka.policies[i].matches(c, partnerIDs) {
+			return ka.classes[i]
+		}
+	}
+
+	return ka.fallback
+}
@@ -0,0 +1,121 @@
+package device
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/convey"
+	"github.com/Comcast/webpa-common/secure/handler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepAlivePolicyMatches(t *testing.T) {
+	testData := []struct {
+		policy     KeepAlivePolicy
+		convey     convey.C
+		partnerIDs []string
+		expected   bool
+	}{
+		{KeepAlivePolicy{}, nil, nil, true},
+		{KeepAlivePolicy{}, convey.C{"hw-model": "foo"}, []string{"comcast"}, true},
+		{KeepAlivePolicy{Convey: map[string][]string{"hw-model": {"foo", "bar"}}}, convey.C{"hw-model": "bar"}, nil, true},
+		{KeepAlivePolicy{Convey: map[string][]string{"hw-model": {"foo", "bar"}}}, convey.C{"hw-model": "baz"}, nil, false},
+		{KeepAlivePolicy{Convey: map[string][]string{"hw-model": {"foo"}}}, nil, nil, false},
+		{KeepAlivePolicy{Convey: map[string][]string{"battery": {"true"}}}, convey.C{"battery": true}, nil, true},
+		{KeepAlivePolicy{Convey: map[string][]string{"hw-model": {"foo"}, "fw-name": {"1.0"}}}, convey.C{"hw-model": "foo"}, nil, false},
+		{KeepAlivePolicy{Partners: []string{"comcast", "cox"}}, nil, []string{"cox"}, true},
+		{KeepAlivePolicy{Partners: []string{"comcast", "cox"}}, nil, nil, false},
+		{KeepAlivePolicy{Partners: []string{"comcast", "cox"}}, nil, []string{"sky", "cox"}, true},
+		{KeepAlivePolicy{Convey: map[string][]string{"hw-model": {"foo"}}, Partners: []string{"cox"}}, convey.C{"hw-model": "foo"}, []string{"comcast"}, false},
+		{KeepAlivePolicy{Convey: map[string][]string{"hw-model": {"foo"}}, Partners: []string{"cox"}}, convey.C{"hw-model": "foo"}, []string{"cox"}, true},
+	}
+
+	for i, record := range testData {
+		t.Logf("#%d: %#v", i, record)
+		assert.Equal(t, record.expected, record.policy.matches(record.convey, record.partnerIDs))
+	}
+}
+
+func testKeepAlivesDefault(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		now     = time.Now()
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	for _, o := range []*Options{nil, new(Options)} {
+		if o != nil {
+			o.Now = func() time.Time { return now }
+		}
+
+		ka := newKeepAlives(o).get(convey.C{"hw-model": "foo"}, request)
+		assert.Equal(DefaultKeepAliveClass, ka.class)
+		assert.Equal(DefaultPingPeriod, ka.pingPeriod)
+		if o != nil {
+			assert.Equal(now.Add(DefaultIdlePeriod), ka.readDeadline())
+			assert.Equal(now.Add(DefaultWriteTimeout), ka.writeDeadline())
+		}
+	}
+}
+
+func testKeepAlivesPolicies(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		now    = time.Now()
+
+		ka = newKeepAlives(&Options{
+			PingPeriod:   30 * time.Second,
+			IdlePeriod:   90 * time.Second,
+			WriteTimeout: 20 * time.Second,
+			Now:          func() time.Time { return now },
+			KeepAlivePolicies: []KeepAlivePolicy{
+				{
+					Class:      "low-power",
+					Convey:     map[string][]string{"hw-model": {"sensor"}},
+					PingPeriod: 5 * time.Minute,
+					IdlePeriod: 16 * time.Minute,
+				},
+				{
+					Partners:     []string{"partner"},
+					WriteTimeout: time.Minute,
+				},
+			},
+		})
+	)
+
+	lowPower := ka.get(convey.C{"hw-model": "sensor"}, httptest.NewRequest("GET", "/", nil))
+	assert.Equal("low-power", lowPower.class)
+	assert.Equal(5*time.Minute, lowPower.pingPeriod)
+	assert.Equal(now.Add(16*time.Minute), lowPower.readDeadline())
+	assert.Equal(now.Add(20*time.Second), lowPower.writeDeadline())
+
+	request := httptest.NewRequest("GET", "/", nil)
+	request = request.WithContext(handler.NewContextWithValue(request.Context(), &handler.ContextValues{
+		Claims: map[string]interface{}{
+			"allowedResources": map[string]interface{}{"allowedPartners": []interface{}{"partner"}},
+		},
+	}))
+
+	partner := ka.get(convey.C{"hw-model": "gateway"}, request)
+	assert.Equal("policy-1", partner.class)
+	assert.Equal(30*time.Second, partner.pingPeriod)
+	assert.Equal(now.Add(90*time.Second), partner.readDeadline())
+	assert.Equal(now.Add(time.Minute), partner.writeDeadline())
+
+	// a partner header supplied by the device is never trusted
+	spoofed := httptest.NewRequest("GET", "/", nil)
+	spoofed.Header.Set("X-Webpa-Partner-Id", "partner")
+	assert.Equal(DefaultKeepAliveClass, ka.get(convey.C{"hw-model": "gateway"}, spoofed).class)
+
+	fallback := ka.get(convey.C{"hw-model": "gateway"}, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(DefaultKeepAliveClass, fallback.class)
+	assert.Equal(30*time.Second, fallback.pingPeriod)
+	assert.Equal(now.Add(90*time.Second), fallback.readDeadline())
+	assert.Equal(now.Add(20*time.Second), fallback.writeDeadline())
+}
+
+func TestKeepAlives(t *testing.T) {
+	t.Run("Default", testKeepAlivesDefault)
+	t.Run("Policies", testKeepAlivesPolicies)
+}
@@ -25,7 +25,8 @@ const (
 
 	// MessageFailed indicates that a message could not be sent to a device, either because
 	// of a communications error or due to the device disconnecting.  For each enqueued message
-	// at the time of a device's disconnection, there will be (1) MessageFailed event.
+	// at the time of a device's disconnection, there will be (1) MessageFailed event.  A message
+	// that expired while waiting to be sent has an Error of ErrorMessageExpired.
 	MessageFailed
 
 	// TransactionComplete indicates that a response to a transaction has been received, and the
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Comcast/webpa-common/convey"
 	"github.com/Comcast/webpa-common/convey/conveyhttp"
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/wrp"
@@ -40,6 +41,9 @@ type Connector interface {
 
 	// Disconnect disconnects the device associated with the given id.
 	// If the id was found, this method returns true.
+	//
+	// Devices disconnected by the server are sent a close frame whose code and text
+	// describe the disconnect reason.  See CloseCode and CloseText.
 	Disconnect(ID) bool
 
 	// DisconnectIf iterates over all devices known to this manager, applying the
@@ -80,6 +84,17 @@ type Registry interface {
 	VisitAll(func(Interface)) int
 }
 
+// Observable is the strategy interface for attaching read-only observers of device events.  It is not part of
+// Manager, so that existing Manager implementations are unaffected.  Managers created by NewManager implement
+// this interface, and clients obtain it with a type assertion.
+type Observable interface {
+	// Observe attaches a new Observer that receives copies of all events dispatched after this method returns,
+	// until the Observer is closed.  If bufferSize is nonpositive, DefaultObserverBufferSize is used.
+	//
+	// Observers never block event delivery.  Events dispatched while an Observer's buffer is full are dropped.
+	Observe(bufferSize int) *Observer
+}
+
 // Manager supplies a hub for connecting and disconnecting devices as well as
 // an access point for obtaining device metadata.
 type Manager interface {
@@ -92,8 +107,9 @@ type Manager interface {
 // created from the options if one is not supplied.
 func NewManager(o *Options) Manager {
 	var (
-		logger   = o.logger()
-		measures = NewMeasures(o.metricsProvider())
+		logger    = o.logger()
+		measures  = NewMeasures(o.metricsProvider())
+		protocols = o.protocols()
 	)
 
 	return &manager{
@@ -101,18 +117,33 @@ func NewManager(o *Options) Manager {
 		errorLog: logging.Error(logger),
 		debugLog: logging.Debug(logger),
 
-		readDeadline:     NewDeadline(o.idlePeriod(), o.now()),
-		writeDeadline:    NewDeadline(o.writeTimeout(), o.now()),
+		keepAlives:       newKeepAlives(o),
 		upgrader:         o.upgrader(),
 		conveyTranslator: conveyhttp.NewHeaderTranslator("", nil),
+		conveyValidator:  convey.NewValidator(o.conveySchema(), measures.ConveyInvalid),
 		devices: newRegistry(registryOptions{
 			Logger:   logger,
 			Limit:    o.maxDevices(),
 			Measures: measures,
 		}),
 		deviceMessageQueueSize: o.deviceMessageQueueSize(),
-		pingPeriod:             o.pingPeriod(),
 		authDelay:              o.authDelay(),
+		maxInboundMessageSize:  o.maxInboundMessageSize(),
+		maxOutboundMessageSize: o.maxOutboundMessageSize(),
+		oversizePolicy:         o.oversizePolicy(),
+		messageTTL:             o.messageTTL(),
+		maxUnacknowledged:      o.maxUnacknowledged(),
+		flowControlPolicy:      o.flowControlPolicy(),
+		serviceTTL:             o.serviceTTL(),
+		maxServices:            o.maxServices(),
+		requireServices:        o.requireRegisteredServices(),
+		protocols:              protocols,
+		authStatuses:           authStatusMessages(protocols),
+		now:                    o.now(),
+		messageHistorySize:     o.messageHistorySize(),
+		enforceMetadata:        newMetadataEnforcer(logging.Debug(logger), o.maxMetadataSize(), o.trimmableMetadata(), measures.MetadataTrimmed),
+		inboundInterceptors:    o.inboundInterceptors(),
+		outboundInterceptors:   o.outboundInterceptors(),
 
 		listeners: o.listeners(),
 		measures:  measures,
@@ -125,18 +156,34 @@ type manager struct {
 	errorLog log.Logger
 	debugLog log.Logger
 
-	readDeadline     func() time.Time
-	writeDeadline    func() time.Time
+	keepAlives       *keepAlives
 	upgrader         *websocket.Upgrader
 	conveyTranslator conveyhttp.HeaderTranslator
+	conveyValidator  convey.Validator
 
 	devices *registry
 
 	deviceMessageQueueSize int
-	pingPeriod             time.Duration
 	authDelay              time.Duration
+	maxInboundMessageSize  int
+	maxOutboundMessageSize int
+	oversizePolicy         OversizePolicy
+	messageTTL             time.Duration
+	maxUnacknowledged      int
+	flowControlPolicy      FlowControlPolicy
+	serviceTTL             time.Duration
+	maxServices            int
+	requireServices        bool
+	protocols              []Protocol
+	authStatuses           map[wrp.Format]*websocket.PreparedMessage
+	now                    func() time.Time
+	messageHistorySize     int
+	enforceMetadata        metadataEnforcer
+	inboundInterceptors    Interceptors
+	outboundInterceptors   Interceptors
 
 	listeners []Listener
+	observers observers
 	measures  Measures
 }
 
@@ -153,22 +200,64 @@ func (m *manager) Connect(response http.ResponseWriter, request *http.Request, r
 		return nil, ErrorMissingDeviceNameContext
 	}
 
-	d := newDevice(deviceOptions{ID: id, QueueSize: m.deviceMessageQueueSize, Logger: m.logger})
-	if convey, err := m.conveyTranslator.FromHeader(request.Header); err == nil {
-		d.infoLog.Log("convey", convey)
+	// copy the response header, as negotiation adds to it
+	upgradeHeader := make(http.Header, len(responseHeader)+2)
+	for name, values := range responseHeader {
+		upgradeHeader[name] = values
+	}
+
+	protocol, err := negotiateProtocol(request, upgradeHeader, m.protocols)
+	if err != nil {
+		m.errorLog.Log(logging.MessageKey(), "protocol negotiation failed", "id", id, logging.ErrorKey(), err)
+		xhttp.WriteError(response, http.StatusBadRequest, err)
+		return nil, err
+	}
+
+	d := newDevice(deviceOptions{
+		ID:                id,
+		QueueSize:         m.deviceMessageQueueSize,
+		HistorySize:       m.messageHistorySize,
+		MessageTTL:        m.messageTTL,
+		ServiceTTL:        m.serviceTTL,
+		MaxServices:       m.maxServices,
+		Now:               m.now,
+		Logger:            m.logger,
+		Protocol:          protocol,
+		MaxUnacknowledged: m.maxUnacknowledged,
+		FlowControlPolicy: m.flowControlPolicy,
+		FlowControlled:    m.measures.FlowControl,
+	})
+	if c, err := m.conveyTranslator.FromHeader(request.Header); err == nil {
+		d.infoLog.Log("convey", c)
+		if err := m.conveyValidator.Validate(c); err != nil {
+			d.errorLog.Log(logging.MessageKey(), "invalid convey data", logging.ErrorKey(), err)
+		}
+
+		d.convey = c
 	} else if err != conveyhttp.ErrMissingHeader {
 		d.errorLog.Log(logging.MessageKey(), "badly formatted convey data", logging.ErrorKey(), err)
 	}
 
-	c, err := m.upgrader.Upgrade(response, request, responseHeader)
+	keepAlive := m.keepAlives.get(d.convey, request)
+	c, err := m.upgrader.Upgrade(response, request, upgradeHeader)
 	if err != nil {
 		d.errorLog.Log(logging.MessageKey(), "failed websocket upgrade", logging.ErrorKey(), err)
 		return nil, err
 	}
 
-	d.debugLog.Log(logging.MessageKey(), "websocket upgrade complete", "localAddress", c.LocalAddr().String())
+	if limit := m.readLimit(); limit > 0 {
+		c.SetReadLimit(limit)
+	}
+
+	// echo the device's close code, rather than sending a close frame of our own when the device shuts down
+	c.SetCloseHandler(func(code int, _ string) error {
+		d.peerClosed(code)
+		return c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), m.now().Add(closeFrameTimeout))
+	})
+
+	d.debugLog.Log(logging.MessageKey(), "websocket upgrade complete", "localAddress", c.LocalAddr().String(), "protocolVersion", protocol.Version, "keepAliveClass", keepAlive.class)
 
-	pinger, err := NewPinger(c, m.measures.Ping, []byte(d.ID()), m.writeDeadline)
+	pinger, err := NewPinger(c, m.measures.Ping, []byte(d.ID()), keepAlive.writeDeadline)
 	if err != nil {
 		d.errorLog.Log(logging.MessageKey(), "unable to create pinger", logging.ErrorKey(), err)
 		c.Close()
@@ -177,10 +266,12 @@ func (m *manager) Connect(response http.ResponseWriter, request *http.Request, r
 
 	if err := m.devices.add(d); err != nil {
 		d.errorLog.Log(logging.MessageKey(), "unable to register device", logging.ErrorKey(), err)
+		m.writeClose(d, c)
 		c.Close()
 		return nil, err
 	}
 
+	m.measures.ProtocolVersion.With(VersionLabel, protocol.Version).Add(1.0)
 	m.dispatch(
 		&Event{
 			Type:   Connect,
@@ -188,10 +279,11 @@ func (m *manager) Connect(response http.ResponseWriter, request *http.Request, r
 		},
 	)
 
-	SetPongHandler(c, m.measures.Pong, m.readDeadline)
+	reader := InstrumentReader(c, d.statistics)
+	SetPongHandler(reader, m.measures.Pong, keepAlive.readDeadline)
 	closeOnce := new(sync.Once)
-	go m.readPump(d, InstrumentReader(c, d.statistics), closeOnce)
-	go m.writePump(d, InstrumentWriter(c, d.statistics), pinger, closeOnce)
+	go m.readPump(d, reader, closeOnce)
+	go m.writePump(d, InstrumentWriter(c, d.statistics), InstrumentPinger(pinger, d.statistics), keepAlive.pingPeriod, closeOnce)
 
 	return d, nil
 }
@@ -200,6 +292,12 @@ func (m *manager) dispatch(e *Event) {
 	for _, listener := range m.listeners {
 		listener(e)
 	}
+
+	m.observers.dispatch(e, m.now, m.measures.ObserverDropped)
+}
+
+func (m *manager) Observe(bufferSize int) *Observer {
+	return m.observers.attach(bufferSize)
 }
 
 // pumpClose handles the proper shutdown and logging of a device's pumps.
@@ -209,7 +307,7 @@ func (m *manager) dispatch(e *Event) {
 // Note that the write pump does additional cleanup.  In particular, the write pump
 // dispatches message failed events for any messages that were waiting to be delivered
 // at the time of pump closure.
-func (m *manager) pumpClose(d *device, c io.Closer, pumpError error) {
+func (m *manager) pumpClose(d *device, c io.Closer, pumpError error, reason string) {
 	if pumpError != nil {
 		d.errorLog.Log(logging.MessageKey(), "pump close due to error", logging.ErrorKey(), pumpError)
 	} else {
@@ -217,7 +315,7 @@ func (m *manager) pumpClose(d *device, c io.Closer, pumpError error) {
 	}
 
 	// remove will invoke requestClose()
-	m.devices.remove(d.id)
+	m.devices.remove(d.id, reason)
 
 	if closeError := c.Close(); closeError != nil {
 		d.errorLog.Log(logging.MessageKey(), "Error closing device connection", logging.ErrorKey(), closeError)
@@ -233,6 +331,69 @@ func (m *manager) pumpClose(d *device, c io.Closer, pumpError error) {
 	)
 }
 
+// pumpCloseReason determines the disconnect reason for a pump that exited with the given error.  The ioReason
+// is used for any error other than ErrorMessageTooLarge.
+func pumpCloseReason(pumpError error, ioReason string) string {
+	switch pumpError {
+	case nil:
+		return ReasonClosed
+
+	case ErrorMessageTooLarge:
+		return ReasonMessageTooLarge
+
+	default:
+		return ioReason
+	}
+}
+
+// writeClose sends a close frame to a device that has been closed, so that the device can tell why it was
+// disconnected.  If the device initiated the close, its close frame has already been echoed and nothing is sent.
+// Failures are only logged, since the connection is about to be closed regardless.
+func (m *manager) writeClose(d *device, w Writer) {
+	if d.closedByPeer() {
+		return
+	}
+
+	err := w.SetWriteDeadline(m.now().Add(closeFrameTimeout))
+	if err == nil {
+		err = w.WriteMessage(websocket.CloseMessage, FormatCloseMessage(d.closeReason))
+	}
+
+	if err != nil {
+		d.debugLog.Log(logging.MessageKey(), "unable to send close frame", "reason", d.closeReason, logging.ErrorKey(), err)
+	}
+}
+
+// recordMessage updates the message throughput metrics for a message exchanged with a device
+func (m *manager) recordMessage(direction string, message wrp.Typed) {
+	var messageType string
+	if message != nil {
+		messageType = message.MessageType().FriendlyName()
+	}
+
+	m.measures.Messages.With(DirectionLabel, direction, TypeLabel, messageType).Add(1.0)
+}
+
+// updateServices applies a service registration or service alive message from a device to that device's service table
+func (m *manager) updateServices(d *device, message *wrp.Message) {
+	if message.Type == wrp.ServiceAliveMessageType {
+		d.services.Alive()
+		return
+	}
+
+	if len(message.ServiceName) == 0 {
+		d.errorLog.Log(logging.MessageKey(), "skipping service registration with no service name")
+		return
+	}
+
+	if err := d.services.Register(message.ServiceName, message.URL); err != nil {
+		d.errorLog.Log(logging.MessageKey(), "skipping service registration", "serviceName", message.ServiceName, logging.ErrorKey(), err)
+		return
+	}
+
+	d.debugLog.Log(logging.MessageKey(), "service registered", "serviceName", message.ServiceName, "url", message.URL)
+}
+
 // readPump is the goroutine which handles the stream of WRP messages from a device.
 // This goroutine exits when any error occurs on the connection.
 func (m *manager) readPump(d *device, r ReadCloser, closeOnce *sync.Once) {
@@ -241,16 +402,27 @@ func (m *manager) readPump(d *device, r ReadCloser, closeOnce *sync.Once) {
 
 	var (
 		readError error
-		decoder   = wrp.NewDecoder(nil, wrp.Msgpack)
+		format    = d.protocol.Format
+		decoder   = wrp.NewDecoder(nil, format)
+		encoder   = wrp.NewEncoder(nil, format)
 	)
 
 	// all the read pump has to do is ensure the device and the connection are closed
 	// it is the write pump's responsibility to do further cleanup
-	defer closeOnce.Do(func() { m.pumpClose(d, r, readError) })
+	defer closeOnce.Do(func() { m.pumpClose(d, r, readError, pumpCloseReason(readError, ReasonReadError)) })
 
 	for {
-		messageType, data, readError := r.ReadMessage()
-		if readError != nil {
+		var (
+			messageType int
+			data        []byte
+		)
+
+		messageType, data, readError = r.ReadMessage()
+		if readError == websocket.ErrReadLimit {
+			m.recordOversize(d, InboundDirection, int(m.readLimit()))
+			readError = ErrorMessageTooLarge
+			return
+		} else if readError != nil {
 			d.errorLog.Log(logging.MessageKey(), "read error", logging.ErrorKey(), readError)
 			return
 		}
@@ -260,13 +432,27 @@ func (m *manager) readPump(d *device, r ReadCloser, closeOnce *sync.Once) {
 			continue
 		}
 
+		if oversize(len(data), m.maxInboundMessageSize) {
+			m.recordOversize(d, InboundDirection, len(data))
+			switch m.oversizePolicy {
+			case OversizeDisconnect:
+				readError = ErrorMessageTooLarge
+				return
+
+			case OversizeReject:
+				m.rejectInbound(d, data, decoder)
+			}
+
+			continue
+		}
+
 		var (
 			message = new(wrp.Message)
 			event   = Event{
 				Type:     MessageReceived,
 				Device:   d,
 				Message:  message,
-				Format:   wrp.Msgpack,
+				Format:   format,
 				Contents: data,
 			}
 		)
@@ -279,8 +465,22 @@ func (m *manager) readPump(d *device, r ReadCloser, closeOnce *sync.Once) {
 			continue
 		}
 
-		if message.Type == wrp.SimpleRequestResponseMessageType {
+		d.history.Add(newMessageSummary(InboundDirection, message, len(data)))
+		m.recordMessage(InboundDirection, message)
+
+		if data, err = m.interceptInbound(d, message, data, encoder); err != nil {
+			m.dropInbound(d, message, &event, err)
+			continue
+		}
+
+		event.Contents = data
+
+		switch message.Type {
+		case wrp.SimpleRequestResponseMessageType:
 			m.measures.RequestResponse.Add(1.0)
+
+		case wrp.ServiceRegistrationMessageType, wrp.ServiceAliveMessageType:
+			m.updateServices(d, message)
 		}
 
 		// update any waiting transaction
@@ -290,7 +490,7 @@ func (m *manager) readPump(d *device, r ReadCloser, closeOnce *sync.Once) {
 				&Response{
 					Device:   d,
 					Message:  message,
-					Format:   wrp.Msgpack,
+					Format:   format,
 					Contents: data,
 				},
 			)
@@ -311,16 +511,18 @@ func (m *manager) readPump(d *device, r ReadCloser, closeOnce *sync.Once) {
 // writePump is the goroutine which services messages addressed to the device.
 // this goroutine exits when either an explicit shutdown is requested or any
 // error occurs on the connection.
-func (m *manager) writePump(d *device, w WriteCloser, pinger func() error, closeOnce *sync.Once) {
+func (m *manager) writePump(d *device, w WriteCloser, pinger func() error, pingPeriod time.Duration, closeOnce *sync.Once) {
 	defer d.debugLog.Log(logging.MessageKey(), "writePump exiting")
 	d.debugLog.Log(logging.MessageKey(), "writePump starting")
 
 	var (
 		envelope   *envelope
-		encoder    = wrp.NewEncoder(nil, wrp.Msgpack)
+		format     = d.protocol.Format
+		encoder    = wrp.NewEncoder(nil, format)
+		authStatus = m.authStatuses[format]
 		writeError error
 
-		pingTicker = time.NewTicker(m.pingPeriod)
+		pingTicker = time.NewTicker(pingPeriod)
 
 		// wait for the delay, then send an auth status request to the device
 		authStatusTimer = time.AfterFunc(m.authDelay, func() {
@@ -337,7 +539,7 @@ func (m *manager) writePump(d *device, w WriteCloser, pinger func() error, close
 	defer func() {
 		pingTicker.Stop()
 		authStatusTimer.Stop()
-		closeOnce.Do(func() { m.pumpClose(d, w, writeError) })
+		closeOnce.Do(func() { m.pumpClose(d, w, writeError, pumpCloseReason(writeError, ReasonWriteError)) })
 
 		// notify listener of any message that just now failed
 		// any writeError is passed via this event
@@ -380,24 +582,57 @@ func (m *manager) writePump(d *device, w WriteCloser, pinger func() error, close
 
 		select {
 		case <-d.shutdown:
-			d.debugLog.Log(logging.MessageKey(), "explicit shutdown")
+			d.debugLog.Log(logging.MessageKey(), "explicit shutdown", "reason", d.closeReason)
+			m.writeClose(d, w)
 			writeError = w.Close()
 			return
 
 		case envelope = <-d.messages:
-			var frameContents []byte
-			if envelope.request.Format == wrp.Msgpack && len(envelope.request.Contents) > 0 {
-				frameContents = envelope.request.Contents
+			var (
+				frameContents []byte
+				outbound      wrp.Typed
+				messageError  error
+			)
+
+			if envelope.expired(m.now()) {
+				m.recordExpired(d, envelope)
+				messageError = ErrorMessageExpired
 			} else {
-				// if the request was in a format other than Msgpack, or if the caller did not pass
-				// Contents, then do the encoding here.
-				encoder.ResetBytes(&frameContents)
-				writeError = encoder.Encode(envelope.request.Message)
-				encoder.ResetBytes(nil)
+				outbound, messageError = m.interceptOutbound(d, envelope.request)
+			}
+
+			if messageError == nil {
+				if outbound == nil && envelope.request.Format == format && len(envelope.request.Contents) > 0 {
+					frameContents = envelope.request.Contents
+				} else {
+					// if the request was in a format other than the device's protocol format, if the caller did not pass
+					// Contents, or if an interceptor saw the message, then do the encoding here.
+					if outbound == nil {
+						outbound = envelope.request.Message
+					}
+
+					encoder.ResetBytes(&frameContents)
+					writeError = encoder.Encode(outbound)
+					encoder.ResetBytes(nil)
+					messageError = writeError
+				}
 			}
 
-			if writeError == nil {
-				writeError = w.WriteMessage(websocket.BinaryMessage, frameContents)
+			if messageError == nil {
+				if oversize(len(frameContents), m.maxOutboundMessageSize) {
+					m.recordOversize(d, OutboundDirection, len(frameContents))
+					messageError = ErrorMessageTooLarge
+					if m.oversizePolicy == OversizeDisconnect {
+						writeError = ErrorMessageTooLarge
+					}
+				} else {
+					writeError = w.WriteMessage(websocket.BinaryMessage, frameContents)
+					messageError = writeError
+					if writeError == nil {
+						d.history.Add(newMessageSummary(OutboundDirection, envelope.request.Message, len(frameContents)))
+						m.recordMessage(OutboundDirection, envelope.request.Message)
+					}
+				}
 			}
 
 			event := Event{
@@ -405,11 +640,11 @@ func (m *manager) writePump(d *device, w WriteCloser, pinger func() error, close
 				Message:  envelope.request.Message,
 				Format:   envelope.request.Format,
 				Contents: envelope.request.Contents,
-				Error:    writeError,
+				Error:    messageError,
 			}
 
-			if writeError != nil {
-				envelope.complete <- writeError
+			if messageError != nil {
+				envelope.complete <- messageError
 				event.Type = MessageFailed
 			} else {
 				event.Type = MessageSent
@@ -425,7 +660,7 @@ func (m *manager) writePump(d *device, w WriteCloser, pinger func() error, close
 }
 
 func (m *manager) Disconnect(id ID) bool {
-	_, ok := m.devices.remove(id)
+	_, ok := m.devices.remove(id, ReasonDisconnectRequested)
 	return ok
 }
 
@@ -450,11 +685,23 @@ func (m *manager) VisitAll(visitor func(Interface)) int {
 }
 
 func (m *manager) Route(request *Request) (*Response, error) {
-	if destination, err := request.ID(); err != nil {
+	destination, err := request.ID()
+	if err != nil {
 		return nil, err
-	} else if d, ok := m.devices.get(destination); ok {
-		return d.Send(request)
-	} else {
-		return nil, ErrorDeviceNotFound
 	}
+
+	d, ok := m.devices.get(destination)
+	if !ok {
+		return nil, &DeviceNotFoundError{ID: destination}
+	}
+
+	if routable, ok := request.Message.(wrp.Routable); ok && m.requireServices {
+		if service, ok := ParseService(routable.To()); ok {
+			if _, registered := d.services.Get(service); !registered {
+				return nil, ErrorServiceNotRegistered
+			}
+		}
+	}
+
+	return d.Send(request)
 }
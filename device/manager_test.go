@@ -1,6 +1,7 @@
 package device
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -11,8 +12,10 @@ import (
 
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/websocket"
 	"github.com/justinas/alice"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -254,6 +257,107 @@ func testManagerDisconnectIf(t *testing.T) {
 	}
 }
 
+func testManagerCloseFrame(t *testing.T) {
+	testData := []struct {
+		reason       string
+		disconnect   func(Manager, Dialer, string)
+		expectedCode int
+		expectedText string
+	}{
+		{
+			ReasonDisconnectRequested,
+			func(m Manager, _ Dialer, _ string) { m.Disconnect(testDeviceIDs[0]) },
+			websocket.CloseGoingAway,
+			"drain:disconnect_requested",
+		},
+		{
+			ReasonDuplicate,
+			func(_ Manager, d Dialer, connectURL string) {
+				if c, _, err := d.DialDevice(string(testDeviceIDs[0]), connectURL, nil); err == nil {
+					defer c.Close()
+				}
+			},
+			websocket.ClosePolicyViolation,
+			"policy:duplicate",
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.reason, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+				dialer  = DefaultDialer()
+
+				manager, server, connectURL = startWebsocketServer(new(Options))
+			)
+
+			defer server.Close()
+
+			c, _, err := dialer.DialDevice(string(testDeviceIDs[0]), connectURL, nil)
+			require.NoError(err)
+			defer c.Close()
+
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				if _, ok := manager.Get(testDeviceIDs[0]); ok {
+					break
+				}
+			}
+
+			record.disconnect(manager, dialer, connectURL)
+			c.SetReadDeadline(time.Now().Add(5 * time.Second))
+			for {
+				_, _, err = c.ReadMessage()
+				if err != nil {
+					break
+				}
+			}
+
+			closeError, ok := err.(*websocket.CloseError)
+			require.True(ok, "expected a close error, got %v", err)
+			assert.Equal(record.expectedCode, closeError.Code)
+			assert.Equal(record.expectedText, closeError.Text)
+		})
+	}
+}
+
+func testManagerCloseFrameEcho(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		dialer  = DefaultDialer()
+
+		manager, server, connectURL = startWebsocketServer(new(Options))
+	)
+
+	defer server.Close()
+
+	c, _, err := dialer.DialDevice(string(testDeviceIDs[0]), connectURL, nil)
+	require.NoError(err)
+	defer c.Close()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, ok := manager.Get(testDeviceIDs[0]); ok {
+			break
+		}
+	}
+
+	// a device that closes its connection gets its own close code back
+	require.NoError(c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4001, "bye")))
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err = c.ReadMessage()
+		if err != nil {
+			break
+		}
+	}
+
+	closeError, ok := err.(*websocket.CloseError)
+	require.True(ok, "expected a close error, got %v", err)
+	assert.Equal(4001, closeError.Code)
+	assert.Empty(closeError.Text)
+}
+
 func testManagerRouteBadDestination(t *testing.T) {
 	var (
 		assert  = assert.New(t)
@@ -285,7 +389,8 @@ func testManagerRouteDeviceNotFound(t *testing.T) {
 
 	response, err := manager.Route(request)
 	assert.Nil(response)
-	assert.Equal(ErrorDeviceNotFound, err)
+	assert.Equal(&DeviceNotFoundError{ID: ID("mac:112233445566")}, err)
+	assert.True(errors.Is(err, ErrorDeviceNotFound))
 }
 
 func TestManager(t *testing.T) {
@@ -302,4 +407,6 @@ func TestManager(t *testing.T) {
 
 	t.Run("Disconnect", testManagerDisconnect)
 	t.Run("DisconnectIf", testManagerDisconnectIf)
+	t.Run("CloseFrame", testManagerCloseFrame)
+	t.Run("CloseFrameEcho", testManagerCloseFrameEcho)
 }
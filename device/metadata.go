@@ -0,0 +1,48 @@
+package device
+
+import (
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// OutcomeLabel is the metric label for what happened to a message whose metadata was over budget
+	OutcomeLabel = "outcome"
+
+	// TrimmedOutcome indicates a message whose metadata fit the budget after trimming
+	TrimmedOutcome = "trimmed"
+
+	// DroppedOutcome indicates a message whose metadata was still over budget after trimming
+	DroppedOutcome = "dropped"
+)
+
+// metadataEnforcer applies the metadata budget to a message decoded from a device, reporting whether the
+// message was modified.  Returning a non-nil error drops the message.
+type metadataEnforcer func(Interface, *wrp.Message) (bool, error)
+
+// newMetadataEnforcer produces a metadataEnforcer for a maximum aggregate metadata size.  Messages over budget have
+// their trimmable keys removed, and messages still over budget are dropped.  If maxSize is nonpositive, this function
+// returns nil.
+func newMetadataEnforcer(logger log.Logger, maxSize int, trimmable []string, trimmedCounter metrics.Counter) metadataEnforcer {
+	if maxSize < 1 {
+		return nil
+	}
+
+	return func(d Interface, message *wrp.Message) (bool, error) {
+		trimmed, err := wrp.TrimMetadata(message.Metadata, maxSize, trimmable)
+		if err != nil {
+			trimmedCounter.With(OutcomeLabel, DroppedOutcome).Add(1.0)
+			return false, err
+		}
+
+		if len(trimmed) > 0 {
+			trimmedCounter.With(OutcomeLabel, TrimmedOutcome).Add(1.0)
+			logger.Log(logging.MessageKey(), "trimmed inbound metadata", "id", d.ID(), "keys", trimmed)
+			return true, nil
+		}
+
+		return false, nil
+	}
+}
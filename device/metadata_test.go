@@ -0,0 +1,57 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewMetadataEnforcerUnlimited(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(newMetadataEnforcer(logging.NewTestLogger(nil, t), 0, []string{"/boot-time"}, nil))
+	assert.Nil(newMetadataEnforcer(logging.NewTestLogger(nil, t), -1, []string{"/boot-time"}, nil))
+}
+
+func testNewMetadataEnforcer(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		p        = xmetricstest.NewProvider(nil, Metrics)
+		d        = newDevice(deviceOptions{ID: ID("mac:112233445566"), Logger: logging.NewTestLogger(nil, t)})
+		enforcer = newMetadataEnforcer(logging.NewTestLogger(nil, t), 16, []string{"/boot-time"}, p.NewCounter(MetadataTrimmedCounter))
+	)
+
+	require.NotNil(enforcer)
+
+	within := &wrp.Message{Metadata: map[string]string{"/hw-model": "abc"}}
+	changed, err := enforcer(d, within)
+	assert.False(changed)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"/hw-model": "abc"}, within.Metadata)
+	p.Assert(t, MetadataTrimmedCounter, OutcomeLabel, TrimmedOutcome)(xmetricstest.Value(0.0))
+
+	trimmed := &wrp.Message{Metadata: map[string]string{"/hw-model": "abc", "/boot-time": "1234567890"}}
+	changed, err = enforcer(d, trimmed)
+	assert.True(changed)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"/hw-model": "abc"}, trimmed.Metadata)
+	p.Assert(t, MetadataTrimmedCounter, OutcomeLabel, TrimmedOutcome)(xmetricstest.Value(1.0))
+	p.Assert(t, MetadataTrimmedCounter, OutcomeLabel, DroppedOutcome)(xmetricstest.Value(0.0))
+
+	dropped := &wrp.Message{Metadata: map[string]string{"/hw-model": "a very long hardware model"}}
+	changed, err = enforcer(d, dropped)
+	assert.False(changed)
+	assert.Equal(wrp.ErrMetadataTooLarge, err)
+	p.Assert(t, MetadataTrimmedCounter, OutcomeLabel, TrimmedOutcome)(xmetricstest.Value(1.0))
+	p.Assert(t, MetadataTrimmedCounter, OutcomeLabel, DroppedOutcome)(xmetricstest.Value(1.0))
+}
+
+func TestNewMetadataEnforcer(t *testing.T) {
+	t.Run("Unlimited", testNewMetadataEnforcerUnlimited)
+	t.Run("Limited", testNewMetadataEnforcer)
+}
@@ -1,20 +1,70 @@
 package device
 
 import (
+	"github.com/Comcast/webpa-common/convey"
 	"github.com/Comcast/webpa-common/xmetrics"
 	"github.com/go-kit/kit/metrics"
 	"github.com/go-kit/kit/metrics/provider"
 )
 
 const (
-	DeviceCounter             = "device_count"
-	DuplicatesCounter         = "duplicate_count"
-	RequestResponseCounter    = "request_response_count"
-	PingCounter               = "ping_count"
-	PongCounter               = "pong_count"
-	ConnectCounter            = "connect_count"
-	DisconnectCounter         = "disconnect_count"
-	DeviceLimitReachedCounter = "device_limit_reached_count"
+	DeviceCounter               = "device_count"
+	DuplicatesCounter           = "duplicate_count"
+	RequestResponseCounter      = "request_response_count"
+	PingCounter                 = "ping_count"
+	PongCounter                 = "pong_count"
+	ConnectCounter              = "connect_count"
+	DisconnectCounter           = "disconnect_count"
+	DeviceLimitReachedCounter   = "device_limit_reached_count"
+	ConveyValidationCounter     = "convey_validation_failure_count"
+	OversizeMessageCounter      = "oversize_message_count"
+	MetadataTrimmedCounter      = "metadata_trimmed_count"
+	ObserverDroppedCounter      = "observer_dropped_count"
+	FlowControlCounter          = "flow_control_count"
+	ConnectReasonCounter        = "connect_reason_count"
+	DisconnectReasonCounter     = "disconnect_reason_count"
+	ConnectionDurationHistogram = "connection_duration_seconds"
+	MessageCounter              = "message_count"
+	MessageExpiredCounter       = "message_expired_count"
+	ProtocolVersionCounter      = "protocol_version_count"
+)
+
+const (
+	// ReasonLabel is the metric label for why a device connected or disconnected
+	ReasonLabel = "reason"
+
+	// TypeLabel is the metric label for the friendly name of a WRP message type
+	TypeLabel = "type"
+
+	// VersionLabel is the metric label for a device's negotiated protocol version
+	VersionLabel = "version"
+)
+
+// Connect and disconnect reasons used with ReasonLabel
+const (
+	// ReasonNew indicates a device connected with no existing connection under the same ID
+	ReasonNew = "new"
+
+	// ReasonDuplicate indicates a connection that replaced, or was replaced by, another connection with the same ID
+	ReasonDuplicate = "duplicate"
+
+	// ReasonDeviceLimitReached indicates a connection rejected because the maximum number of devices was reached
+	ReasonDeviceLimitReached = "device_limit_reached"
+
+	// ReasonDisconnectRequested indicates a device disconnected via the Registry, e.g. an administrative request
+	ReasonDisconnectRequested = "disconnect_requested"
+
+	// ReasonReadError indicates a device disconnected due to an error reading from its connection
+	ReasonReadError = "read_error"
+
+	// ReasonWriteError indicates a device disconnected due to an error writing to its connection
+	ReasonWriteError = "write_error"
+
+	// ReasonMessageTooLarge indicates a device disconnected due to OversizeDisconnect
+	ReasonMessageTooLarge = "message_too_large"
+
+	// ReasonClosed indicates a device's connection was closed for any other reason
+	ReasonClosed = "closed"
 )
 
 // Metrics is the device module function that adds default device metrics
@@ -52,6 +102,59 @@ func Metrics() []xmetrics.Metric {
 			Name: DeviceLimitReachedCounter,
 			Type: "counter",
 		},
+		{
+			Name:       ConveyValidationCounter,
+			Type:       "counter",
+			LabelNames: []string{convey.FieldLabel, convey.ReasonLabel},
+		},
+		{
+			Name:       OversizeMessageCounter,
+			Type:       "counter",
+			LabelNames: []string{DirectionLabel, PolicyLabel},
+		},
+		{
+			Name:       MetadataTrimmedCounter,
+			Type:       "counter",
+			LabelNames: []string{OutcomeLabel},
+		},
+		{
+			Name: ObserverDroppedCounter,
+			Type: "counter",
+		},
+		{
+			Name:       FlowControlCounter,
+			Type:       "counter",
+			LabelNames: []string{OutcomeLabel},
+		},
+		{
+			Name:       ConnectReasonCounter,
+			Type:       "counter",
+			LabelNames: []string{ReasonLabel},
+		},
+		{
+			Name:       DisconnectReasonCounter,
+			Type:       "counter",
+			LabelNames: []string{ReasonLabel},
+		},
+		{
+			Name:    ConnectionDurationHistogram,
+			Type:    "histogram",
+			Buckets: []float64{1, 10, 60, 300, 1800, 3600, 21600, 86400, 604800},
+		},
+		{
+			Name:       MessageCounter,
+			Type:       "counter",
+			LabelNames: []string{DirectionLabel, TypeLabel},
+		},
+		{
+			Name: MessageExpiredCounter,
+			Type: "counter",
+		},
+		{
+			Name:       ProtocolVersionCounter,
+			Type:       "counter",
+			LabelNames: []string{VersionLabel},
+		},
 	}
 }
 
@@ -65,6 +168,33 @@ type Measures struct {
 	Pong            xmetrics.Incrementer
 	Connect         xmetrics.Incrementer
 	Disconnect      xmetrics.Adder
+	ConveyInvalid   metrics.Counter
+	Oversize        metrics.Counter
+
+	// MetadataTrimmed counts inbound messages whose metadata was trimmed, labeled by OutcomeLabel
+	MetadataTrimmed metrics.Counter
+
+	// ObserverDropped counts the events dropped because an Observer's buffer was full
+	ObserverDropped metrics.Counter
+
+	// FlowControl counts messages that exceeded a device's limit of unacknowledged messages, labeled by OutcomeLabel
+	FlowControl metrics.Counter
+
+	// ConnectReason and DisconnectReason count connection lifecycle events labeled by ReasonLabel
+	ConnectReason    metrics.Counter
+	DisconnectReason metrics.Counter
+
+	// ConnectionDuration observes the number of seconds each device was connected
+	ConnectionDuration metrics.Histogram
+
+	// Messages counts the WRP messages exchanged with devices, labeled by DirectionLabel and TypeLabel
+	Messages metrics.Counter
+
+	// Expired counts outbound messages dropped because they waited in a device's queue longer than their TTL
+	Expired metrics.Counter
+
+	// ProtocolVersion counts device connections labeled by the negotiated VersionLabel
+	ProtocolVersion metrics.Counter
 }
 
 // NewMeasures constructs a Measures given a go-kit metrics Provider
@@ -78,5 +208,17 @@ func NewMeasures(p provider.Provider) Measures {
 		Duplicates:      xmetrics.NewIncrementer(p.NewCounter(DuplicatesCounter)),
 		Connect:         xmetrics.NewIncrementer(p.NewCounter(ConnectCounter)),
 		Disconnect:      p.NewCounter(DisconnectCounter),
+		ConveyInvalid:   p.NewCounter(ConveyValidationCounter),
+		Oversize:        p.NewCounter(OversizeMessageCounter),
+		MetadataTrimmed: p.NewCounter(MetadataTrimmedCounter),
+		ObserverDropped: p.NewCounter(ObserverDroppedCounter),
+		FlowControl:     p.NewCounter(FlowControlCounter),
+
+		ConnectReason:      p.NewCounter(ConnectReasonCounter),
+		DisconnectReason:   p.NewCounter(DisconnectReasonCounter),
+		ConnectionDuration: p.NewHistogram(ConnectionDurationHistogram, 10),
+		Messages:           p.NewCounter(MessageCounter),
+		Expired:            p.NewCounter(MessageExpiredCounter),
+		ProtocolVersion:    p.NewCounter(ProtocolVersionCounter),
 	}
 }
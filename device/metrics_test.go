@@ -1,10 +1,15 @@
 package device
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/Comcast/webpa-common/convey"
+	"github.com/Comcast/webpa-common/wrp"
 	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
 	"github.com/go-kit/kit/metrics/provider"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -28,6 +33,16 @@ func TestMetrics(t *testing.T) {
 		counter := r.NewCounter(counterName)
 		counter.Add(1.0)
 	}
+
+	r.NewCounter(ConveyValidationCounter).With(convey.FieldLabel, "hw-model", convey.ReasonLabel, convey.RequiredReason).Add(1.0)
+	r.NewCounter(OversizeMessageCounter).With(DirectionLabel, InboundDirection, PolicyLabel, string(OversizeReject)).Add(1.0)
+	r.NewCounter(MetadataTrimmedCounter).With(OutcomeLabel, TrimmedOutcome).Add(1.0)
+	r.NewCounter(ObserverDroppedCounter).Add(1.0)
+	r.NewCounter(FlowControlCounter).With(OutcomeLabel, RejectedOutcome).Add(1.0)
+	r.NewCounter(ConnectReasonCounter).With(ReasonLabel, ReasonNew).Add(1.0)
+	r.NewCounter(DisconnectReasonCounter).With(ReasonLabel, ReasonReadError).Add(1.0)
+	r.NewHistogram(ConnectionDurationHistogram, 10).Observe(12.5)
+	r.NewCounter(MessageCounter).With(DirectionLabel, OutboundDirection, TypeLabel, "SimpleEvent").Add(1.0)
 }
 
 func TestNewMeasures(t *testing.T) {
@@ -42,4 +57,57 @@ func TestNewMeasures(t *testing.T) {
 	assert.NotNil(m.Pong)
 	assert.NotNil(m.Connect)
 	assert.NotNil(m.Disconnect)
+	assert.NotNil(m.ConveyInvalid)
+	assert.NotNil(m.Oversize)
+	assert.NotNil(m.MetadataTrimmed)
+	assert.NotNil(m.ObserverDropped)
+	assert.NotNil(m.FlowControl)
+	assert.NotNil(m.ConnectReason)
+	assert.NotNil(m.DisconnectReason)
+	assert.NotNil(m.ConnectionDuration)
+	assert.NotNil(m.Messages)
+}
+
+func TestPumpCloseReason(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(ReasonClosed, pumpCloseReason(nil, ReasonReadError))
+	assert.Equal(ReasonMessageTooLarge, pumpCloseReason(ErrorMessageTooLarge, ReasonWriteError))
+	assert.Equal(ReasonWriteError, pumpCloseReason(errors.New("expected"), ReasonWriteError))
+}
+
+func TestManagerLifecycleMetrics(t *testing.T) {
+	var (
+		require = require.New(t)
+
+		p                                 = xmetricstest.NewProvider(nil, Metrics)
+		manager, connection, events, stop = startInterceptorTest(t, &Options{MetricsProvider: p})
+		data                              []byte
+	)
+
+	defer stop()
+	p.Assert(t, DeviceCounter)(xmetricstest.Value(1.0))
+	p.Assert(t, ConnectReasonCounter, ReasonLabel, ReasonNew)(xmetricstest.Value(1.0))
+
+	require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(
+		&wrp.Message{Type: wrp.SimpleEventMessageType, Source: string(testDeviceIDs[0]), Destination: "event:test"},
+	))
+
+	require.NoError(connection.WriteMessage(websocket.BinaryMessage, data))
+	waitForEvent(t, events, MessageReceived)
+	p.Assert(t, MessageCounter, DirectionLabel, InboundDirection, TypeLabel, wrp.SimpleEventMessageType.FriendlyName())(xmetricstest.Value(1.0))
+
+	_, err := manager.Route(&Request{
+		Message: &wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Source: "test", Destination: string(testDeviceIDs[0])},
+		Format:  wrp.Msgpack,
+	})
+
+	require.NoError(err)
+	waitForEvent(t, events, MessageSent)
+	p.Assert(t, MessageCounter, DirectionLabel, OutboundDirection, TypeLabel, wrp.SimpleRequestResponseMessageType.FriendlyName())(xmetricstest.Value(1.0))
+
+	require.True(manager.Disconnect(testDeviceIDs[0]))
+	waitForEvent(t, events, Disconnect)
+	p.Assert(t, DeviceCounter)(xmetricstest.Value(0.0))
+	p.Assert(t, DisconnectReasonCounter, ReasonLabel, ReasonDisconnectRequested)(xmetricstest.Value(1.0))
+	p.Assert(t, ConnectionDurationHistogram)(xmetricstest.Histogram)
 }
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Comcast/webpa-common/convey"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -103,6 +104,29 @@ func (m *mockDevice) Statistics() Statistics {
 	return first
 }
 
+func (m *mockDevice) History() []MessageSummary {
+	arguments := m.Called()
+	first, _ := arguments.Get(0).([]MessageSummary)
+	return first
+}
+
+func (m *mockDevice) Services() []ServiceEntry {
+	arguments := m.Called()
+	first, _ := arguments.Get(0).([]ServiceEntry)
+	return first
+}
+
+func (m *mockDevice) Convey() convey.C {
+	arguments := m.Called()
+	first, _ := arguments.Get(0).(convey.C)
+	return first
+}
+
+func (m *mockDevice) ProtocolVersion() string {
+	arguments := m.Called()
+	return arguments.String(0)
+}
+
 func (m *mockDevice) Send(request *Request) (*Response, error) {
 	arguments := m.Called(request)
 	first, _ := arguments.Get(0).(*Response)
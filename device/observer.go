@@ -0,0 +1,158 @@
+package device
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xmetrics"
+)
+
+// DefaultObserverBufferSize is the number of events an Observer buffers when no size is requested
+const DefaultObserverBufferSize = 100
+
+// ObservedEvent is a copy of an Event made for Observers.  Unlike an Event, an ObservedEvent is never reused,
+// so it is safe to retain and to use from any goroutine.  The same copy is delivered to every Observer, so
+// Contents must not be modified.
+type ObservedEvent struct {
+	// Type describes the kind of the original event
+	Type EventType
+
+	// DeviceID is the identifier of the device for which the event was sent
+	DeviceID ID
+
+	// Time is when the event was dispatched
+	Time time.Time
+
+	// MessageType is the type of the WRP message relevant to the event.  It is only meaningful when
+	// Contents is set.
+	MessageType wrp.MessageType
+
+	// Format is the encoding format of Contents
+	Format wrp.Format
+
+	// Contents is a copy of the encoded WRP message relevant to the event, if any
+	Contents []byte
+
+	// Error is the error carried by the original event, if any
+	Error error
+}
+
+// newObservedEvent copies an Event
+func newObservedEvent(e *Event, now time.Time) ObservedEvent {
+	oe := ObservedEvent{
+		Type:   e.Type,
+		Time:   now,
+		Format: e.Format,
+		Error:  e.Error,
+	}
+
+	if e.Device != nil {
+		oe.DeviceID = e.Device.ID()
+	}
+
+	if e.Message != nil {
+		oe.MessageType = e.Message.MessageType()
+	}
+
+	if len(e.Contents) > 0 {
+		oe.Contents = append([]byte(nil), e.Contents...)
+	}
+
+	return oe
+}
+
+// Observer receives copies of the events a Manager dispatches, e.g. for a debugging console or an analytics tap.
+// Observers are read-only and can never slow down or affect delivery:  events are buffered, and any event
+// dispatched while the buffer is full is dropped and counted rather than waited on.
+type Observer struct {
+	events    chan ObservedEvent
+	dropped   uint64
+	closeOnce sync.Once
+	detach    func(*Observer)
+}
+
+// Events returns the channel on which copies of events are delivered.  This channel is closed when the
+// Observer is closed.
+func (o *Observer) Events() <-chan ObservedEvent {
+	return o.events
+}
+
+// Dropped returns the number of events dropped because this Observer's buffer was full
+func (o *Observer) Dropped() uint64 {
+	return atomic.LoadUint64(&o.dropped)
+}
+
+// Close detaches this Observer from its Manager and closes the Events channel.  This method is idempotent.
+func (o *Observer) Close() {
+	o.closeOnce.Do(func() {
+		o.detach(o)
+	})
+}
+
+// offer delivers an event without blocking, returning false if the event was dropped
+func (o *Observer) offer(oe ObservedEvent) bool {
+	select {
+	case o.events <- oe:
+		return true
+	default:
+		return false
+	}
+}
+
+// observers is the set of Observers attached to a manager
+type observers struct {
+	lock sync.RWMutex
+	set  map[*Observer]bool
+}
+
+// attach creates an Observer with the given buffer size and adds it to this set
+func (s *observers) attach(bufferSize int) *Observer {
+	if bufferSize < 1 {
+		bufferSize = DefaultObserverBufferSize
+	}
+
+	o := &Observer{
+		events: make(chan ObservedEvent, bufferSize),
+		detach: s.detach,
+	}
+
+	s.lock.Lock()
+	if s.set == nil {
+		s.set = make(map[*Observer]bool)
+	}
+
+	s.set[o] = true
+	s.lock.Unlock()
+
+	return o
+}
+
+// detach removes an Observer from this set and closes its channel.  The channel is closed under the
+// write lock, so that no dispatch can send on it afterward.
+func (s *observers) detach(o *Observer) {
+	s.lock.Lock()
+	delete(s.set, o)
+	close(o.events)
+	s.lock.Unlock()
+}
+
+// dispatch offers a copy of an event to each Observer, counting each Observer that dropped it with the
+// given counter.  The copy is only made when there is at least one Observer.
+func (s *observers) dispatch(e *Event, now func() time.Time, dropped xmetrics.Adder) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if len(s.set) == 0 {
+		return
+	}
+
+	oe := newObservedEvent(e, now())
+	for o := range s.set {
+		if !o.offer(oe) {
+			dropped.Add(1.0)
+			atomic.AddUint64(&o.dropped, 1)
+		}
+	}
+}
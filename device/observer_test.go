@@ -0,0 +1,174 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testObserversNone(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		s       observers
+		called  = false
+		dropped = generic.NewCounter("test")
+	)
+
+	s.dispatch(&Event{Type: Connect}, func() time.Time { called = true; return time.Now() }, dropped)
+	assert.False(called)
+	assert.Zero(dropped.Value())
+}
+
+func testObserversDispatch(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		s        observers
+		now      = time.Now()
+		d        = newDevice(deviceOptions{ID: ID("mac:112233445566")})
+		contents = []byte("contents")
+
+		first   = s.attach(0)
+		second  = s.attach(1)
+		dropped = generic.NewCounter("test")
+	)
+
+	assert.Equal(DefaultObserverBufferSize, cap(first.events))
+	assert.Equal(1, cap(second.events))
+
+	s.dispatch(
+		&Event{
+			Type:     MessageReceived,
+			Device:   d,
+			Message:  &wrp.Message{Type: wrp.SimpleEventMessageType},
+			Format:   wrp.JSON,
+			Contents: contents,
+		},
+		func() time.Time { return now },
+		dropped,
+	)
+
+	assert.Zero(dropped.Value())
+
+	// the buffer of the second observer is now full
+	s.dispatch(&Event{Type: Disconnect, Device: d}, func() time.Time { return now }, dropped)
+	assert.Equal(1.0, dropped.Value())
+	contents[0] = 'X'
+
+	for _, o := range []*Observer{first, second} {
+		require.NotEmpty(o.Events())
+		oe := <-o.Events()
+		assert.Equal(MessageReceived, oe.Type)
+		assert.Equal(d.ID(), oe.DeviceID)
+		assert.Equal(now, oe.Time)
+		assert.Equal(wrp.SimpleEventMessageType, oe.MessageType)
+		assert.Equal(wrp.JSON, oe.Format)
+		assert.Equal([]byte("contents"), oe.Contents)
+	}
+
+	require.NotEmpty(first.Events())
+	assert.Equal(ObservedEvent{Type: Disconnect, DeviceID: d.ID(), Time: now}, <-first.Events())
+	assert.Zero(first.Dropped())
+
+	assert.Empty(second.Events())
+	assert.Equal(uint64(1), second.Dropped())
+}
+
+func testObserversClose(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		s      observers
+		o      = s.attach(1)
+	)
+
+	o.Close()
+	o.Close()
+
+	_, ok := <-o.Events()
+	assert.False(ok)
+	s.dispatch(&Event{Type: Connect}, time.Now, discard.NewCounter())
+}
+
+func TestObservers(t *testing.T) {
+	t.Run("None", testObserversNone)
+	t.Run("Dispatch", testObserversDispatch)
+	t.Run("Close", testObserversClose)
+}
+
+// nextObservedEvent waits for the next event of the given type, skipping any others
+func nextObservedEvent(t *testing.T, o *Observer, eventType EventType) ObservedEvent {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case oe := <-o.Events():
+			if oe.Type == eventType {
+				return oe
+			}
+
+		case <-timeout:
+			require.Fail(t, "No observed event received", "eventType: %s", eventType)
+			return ObservedEvent{}
+		}
+	}
+}
+
+func TestManagerObserve(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		p = xmetricstest.NewProvider(nil, Metrics)
+		o = &Options{MetricsProvider: p}
+
+		manager, server, connectURL = startWebsocketServer(o)
+	)
+
+	observable, ok := manager.(Observable)
+	require.True(ok)
+
+	var (
+		observer = observable.Observe(10)
+		full     = observable.Observe(1)
+	)
+
+	defer server.Close()
+	defer observer.Close()
+	defer full.Close()
+
+	c, _, err := DefaultDialer().DialDevice(string(testDeviceIDs[0]), connectURL, nil)
+	require.NoError(err)
+	defer c.Close()
+
+	connect := nextObservedEvent(t, observer, Connect)
+	assert.Equal(testDeviceIDs[0], connect.DeviceID)
+
+	message := &wrp.Message{Type: wrp.SimpleEventMessageType, Source: string(testDeviceIDs[0]), Destination: "event:test"}
+	require.NoError(c.WriteMessage(websocket.BinaryMessage, wrp.MustEncode(message, wrp.Msgpack)))
+
+	received := nextObservedEvent(t, observer, MessageReceived)
+	assert.Equal(testDeviceIDs[0], received.DeviceID)
+	assert.Equal(wrp.SimpleEventMessageType, received.MessageType)
+	assert.Equal(wrp.Msgpack, received.Format)
+
+	var decoded wrp.Message
+	require.NoError(wrp.NewDecoderBytes(received.Contents, wrp.Msgpack).Decode(&decoded))
+	assert.Equal(*message, decoded)
+
+	// the observer with a single slot dropped the message, but delivery was unaffected
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if full.Dropped() > 0 {
+			break
+		}
+	}
+
+	assert.Equal(uint64(1), full.Dropped())
+	p.Assert(t, ObserverDroppedCounter)(xmetricstest.Value(1.0))
+}
@@ -3,6 +3,7 @@ package device
 import (
 	"time"
 
+	"github.com/Comcast/webpa-common/convey"
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics/provider"
@@ -26,6 +27,7 @@ const (
 	DefaultReadBufferSize         = 0
 	DefaultWriteBufferSize        = 0
 	DefaultDeviceMessageQueueSize = 100
+	DefaultMaxServices            = 32
 )
 
 // Options represent the available configuration options for components
@@ -52,6 +54,11 @@ type Options struct {
 	// with no traffic coming from the device.  If not supplied, DefaultIdlePeriod is used.
 	IdlePeriod time.Duration
 
+	// KeepAlivePolicies vary the ping period, idle period, and write timeout by device class.  Policies are
+	// consulted in order when a device connects, and the first policy the device matches is used.  Devices
+	// which match no policy use PingPeriod, IdlePeriod, and WriteTimeout.
+	KeepAlivePolicies []KeepAlivePolicy
+
 	// RequestTimeout is the timeout for all inbound HTTP requests
 	RequestTimeout time.Duration
 
@@ -59,6 +66,79 @@ type Options struct {
 	// DefaultWriteTimeout is used.
 	WriteTimeout time.Duration
 
+	// MaxInboundMessageSize is the maximum size, in bytes, of an encoded WRP message received from a device.
+	// If nonpositive, inbound messages are not limited.
+	MaxInboundMessageSize int
+
+	// MaxOutboundMessageSize is the maximum size, in bytes, of an encoded WRP message sent to a device.
+	// If nonpositive, outbound messages are not limited.
+	MaxOutboundMessageSize int
+
+	// OversizePolicy determines how messages that exceed either size limit are handled.  If unset or
+	// unrecognized, DefaultOversizePolicy is used.  Regardless of policy, a device that sends a frame several
+	// times larger than MaxInboundMessageSize is disconnected without the frame being read in full.
+	OversizePolicy OversizePolicy
+
+	// MaxMetadataSize is the maximum aggregate size, in bytes, of the metadata of a WRP message received from a device,
+	// as computed by wrp.MetadataSize.  Messages over this limit have their TrimmableMetadata keys removed, and messages
+	// still over the limit after trimming are dropped.  If nonpositive, inbound metadata is not limited.
+	MaxMetadataSize int
+
+	// TrimmableMetadata are the non-essential metadata keys, in order, that are removed from inbound messages whose
+	// metadata exceeds MaxMetadataSize.  An entry ending with '*' matches all keys with that prefix.
+	TrimmableMetadata []string
+
+	// MessageTTL is the default maximum length of time an outbound message may wait in a device's queue
+	// before it is written.  Messages that wait longer are dropped with ErrorMessageExpired rather than
+	// delivered stale.  A Request.TTL overrides this value.  If nonpositive, which is the default, outbound
+	// messages do not expire.
+	MessageTTL time.Duration
+
+	// MaxUnacknowledged is the maximum number of request-response messages that may be in flight to each device,
+	// i.e. sent but not yet answered.  Devices with small buffers silently drop bursts of messages, so this limit
+	// should not exceed what the devices can hold.  If nonpositive, which is the default, there is no limit.
+	MaxUnacknowledged int
+
+	// FlowControlPolicy determines how a message that would exceed MaxUnacknowledged is handled.  If unset or
+	// unrecognized, DefaultFlowControlPolicy is used.
+	FlowControlPolicy FlowControlPolicy
+
+	// Protocols are the device protocol versions a Manager accepts, in order of preference.  If empty,
+	// only DefaultProtocolVersion using wrp.Msgpack is accepted.  Devices that do not negotiate a version
+	// are assigned DefaultProtocolVersion, so omitting that version from this list requires negotiation.
+	Protocols []Protocol
+
+	// MessageHistorySize is the number of recent message summaries kept for each device, which are exposed
+	// through the StatHandler for debugging.  If nonpositive, which is the default, no history is kept.
+	MessageHistorySize int
+
+	// ServiceTTL is the length of time a service advertised by a device remains registered without being confirmed
+	// by another registration or a service alive message.  If nonpositive, which is the default, services remain
+	// registered for as long as the device is connected.
+	ServiceTTL time.Duration
+
+	// MaxServices is the maximum number of services a single device may have registered at any time.  Registrations
+	// of new services beyond this limit are ignored.  If nonpositive, DefaultMaxServices is used.
+	MaxServices int
+
+	// RequireRegisteredServices controls whether a Manager only routes messages addressed to a service, e.g.
+	// mac:112233445566/config, when the device has registered that service.  Messages addressed to an unregistered
+	// service are rejected with ErrorServiceNotRegistered.  Messages addressed to a device without a service are
+	// always routed.
+	RequireRegisteredServices bool
+
+	// ConveySchema is the optional schema used to validate convey data when a device connects.  Devices
+	// whose convey data fails validation are still allowed to connect, but the failures are logged and counted.
+	ConveySchema *convey.Schema
+
+	// InboundInterceptors are invoked, in order, with each message decoded from a device before any transaction
+	// is completed or listeners are notified.  An interceptor error drops the message.
+	InboundInterceptors []Interceptor
+
+	// OutboundInterceptors are invoked, in order, with each message sent to a device before it is encoded.
+	// An interceptor error fails the message with that error.
+	OutboundInterceptors []Interceptor
+
 	// Listeners contains the event sinks for managers created using these options
 	Listeners []Listener
 
@@ -114,6 +194,14 @@ func (o *Options) pingPeriod() time.Duration {
 	return DefaultPingPeriod
 }
 
+func (o *Options) keepAlivePolicies() []KeepAlivePolicy {
+	if o != nil {
+		return o.KeepAlivePolicies
+	}
+
+	return nil
+}
+
 func (o *Options) authDelay() time.Duration {
 	if o != nil && o.AuthDelay > 0 {
 		return o.AuthDelay
@@ -146,6 +234,136 @@ func (o *Options) logger() log.Logger {
 	return logging.DefaultLogger()
 }
 
+func (o *Options) maxInboundMessageSize() int {
+	if o != nil && o.MaxInboundMessageSize > 0 {
+		return o.MaxInboundMessageSize
+	}
+
+	return 0
+}
+
+func (o *Options) maxOutboundMessageSize() int {
+	if o != nil && o.MaxOutboundMessageSize > 0 {
+		return o.MaxOutboundMessageSize
+	}
+
+	return 0
+}
+
+func (o *Options) messageTTL() time.Duration {
+	if o != nil && o.MessageTTL > 0 {
+		return o.MessageTTL
+	}
+
+	return 0
+}
+
+func (o *Options) serviceTTL() time.Duration {
+	if o != nil && o.ServiceTTL > 0 {
+		return o.ServiceTTL
+	}
+
+	return 0
+}
+
+func (o *Options) maxServices() int {
+	if o != nil && o.MaxServices > 0 {
+		return o.MaxServices
+	}
+
+	return DefaultMaxServices
+}
+
+func (o *Options) requireRegisteredServices() bool {
+	return o != nil && o.RequireRegisteredServices
+}
+
+func (o *Options) oversizePolicy() OversizePolicy {
+	if o != nil {
+		switch o.OversizePolicy {
+		case OversizeReject, OversizeDrop, OversizeDisconnect:
+			return o.OversizePolicy
+		}
+	}
+
+	return DefaultOversizePolicy
+}
+
+func (o *Options) maxMetadataSize() int {
+	if o != nil && o.MaxMetadataSize > 0 {
+		return o.MaxMetadataSize
+	}
+
+	return 0
+}
+
+func (o *Options) trimmableMetadata() []string {
+	if o != nil {
+		return o.TrimmableMetadata
+	}
+
+	return nil
+}
+
+func (o *Options) maxUnacknowledged() int {
+	if o != nil && o.MaxUnacknowledged > 0 {
+		return o.MaxUnacknowledged
+	}
+
+	return 0
+}
+
+func (o *Options) flowControlPolicy() FlowControlPolicy {
+	if o != nil {
+		switch o.FlowControlPolicy {
+		case FlowControlQueue, FlowControlReject:
+			return o.FlowControlPolicy
+		}
+	}
+
+	return DefaultFlowControlPolicy
+}
+
+func (o *Options) protocols() []Protocol {
+	if o != nil && len(o.Protocols) > 0 {
+		return o.Protocols
+	}
+
+	return defaultProtocols
+}
+
+func (o *Options) messageHistorySize() int {
+	if o != nil && o.MessageHistorySize > 0 {
+		return o.MessageHistorySize
+	}
+
+	return 0
+}
+
+func (o *Options) conveySchema() *convey.Schema {
+	if o != nil {
+		return o.ConveySchema
+	}
+
+	return nil
+}
+
+func (o *Options) inboundInterceptors() Interceptors {
+	if o != nil {
+		return o.InboundInterceptors
+	}
+
+	return nil
+}
+
+func (o *Options) outboundInterceptors() Interceptors {
+	if o != nil {
+		return o.OutboundInterceptors
+	}
+
+	return nil
+}
+
 func (o *Options) listeners() []Listener {
 	if o != nil {
 		return o.Listeners
@@ -4,7 +4,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Comcast/webpa-common/convey"
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
 	"github.com/go-kit/kit/metrics/provider"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
@@ -25,6 +27,22 @@ func TestOptionsDefault(t *testing.T) {
 		assert.Equal(DefaultWriteTimeout, o.writeTimeout())
 		assert.NotNil(o.logger())
 		assert.Empty(o.listeners())
+		assert.Empty(o.keepAlivePolicies())
+		assert.Empty(o.inboundInterceptors())
+		assert.Empty(o.outboundInterceptors())
+		assert.Nil(o.conveySchema())
+		assert.Equal(0, o.maxInboundMessageSize())
+		assert.Equal(0, o.maxOutboundMessageSize())
+		assert.Equal(DefaultOversizePolicy, o.oversizePolicy())
+		assert.Equal(0, o.maxMetadataSize())
+		assert.Empty(o.trimmableMetadata())
+		assert.Equal(0, o.maxUnacknowledged())
+		assert.Equal(DefaultFlowControlPolicy, o.flowControlPolicy())
+		assert.Equal(0, o.messageHistorySize())
+		assert.Equal(time.Duration(0), o.messageTTL())
+		assert.Equal(time.Duration(0), o.serviceTTL())
+		assert.Equal(DefaultMaxServices, o.maxServices())
+		assert.False(o.requireRegisteredServices())
 		assert.Equal(provider.NewDiscardProvider(), o.metricsProvider())
 	}
 }
@@ -42,15 +60,31 @@ func TestOptions(t *testing.T) {
 				WriteBufferSize:  DefaultWriteBufferSize + 926,
 				Subprotocols:     []string{"foobar"},
 			},
-			MaxDevices:             20000,
-			DeviceMessageQueueSize: DefaultDeviceMessageQueueSize + 287342,
-			IdlePeriod:             DefaultIdlePeriod + 3472*time.Minute,
-			PingPeriod:             DefaultPingPeriod + 384*time.Millisecond,
-			AuthDelay:              DefaultAuthDelay + 88*time.Millisecond,
-			WriteTimeout:           DefaultWriteTimeout + 327193*time.Second,
-			Logger:                 expectedLogger,
-			Listeners:              []Listener{func(*Event) {}},
-			MetricsProvider:        expectedMetricsProvider,
+			MaxDevices:                20000,
+			DeviceMessageQueueSize:    DefaultDeviceMessageQueueSize + 287342,
+			IdlePeriod:                DefaultIdlePeriod + 3472*time.Minute,
+			PingPeriod:                DefaultPingPeriod + 384*time.Millisecond,
+			AuthDelay:                 DefaultAuthDelay + 88*time.Millisecond,
+			WriteTimeout:              DefaultWriteTimeout + 327193*time.Second,
+			Logger:                    expectedLogger,
+			MaxInboundMessageSize:     1024,
+			MaxOutboundMessageSize:    2048,
+			OversizePolicy:            OversizeDisconnect,
+			MaxMetadataSize:           512,
+			TrimmableMetadata:         []string{"/boot-time", "/trace/*"},
+			MaxUnacknowledged:         8,
+			FlowControlPolicy:         FlowControlReject,
+			MessageHistorySize:        25,
+			MessageTTL:                2 * time.Minute,
+			ServiceTTL:                10 * time.Minute,
+			MaxServices:               5,
+			RequireRegisteredServices: true,
+			KeepAlivePolicies:         []KeepAlivePolicy{{Class: "low-power", PingPeriod: 5 * time.Minute}},
+			ConveySchema:              &convey.Schema{Required: []string{"hw-model"}},
+			Listeners:                 []Listener{func(*Event) {}},
+			InboundInterceptors:       []Interceptor{func(Interface, *wrp.Message) error { return nil }},
+			OutboundInterceptors:      []Interceptor{func(Interface, *wrp.Message) error { return nil }},
+			MetricsProvider:           expectedMetricsProvider,
 		}
 	)
 
@@ -71,6 +105,22 @@ func TestOptions(t *testing.T) {
 	assert.Equal(o.AuthDelay, o.authDelay())
 	assert.Equal(o.WriteTimeout, o.writeTimeout())
 	assert.Equal(expectedLogger, o.logger())
+	assert.Equal(1024, o.maxInboundMessageSize())
+	assert.Equal(2048, o.maxOutboundMessageSize())
+	assert.Equal(OversizeDisconnect, o.oversizePolicy())
+	assert.Equal(512, o.maxMetadataSize())
+	assert.Equal([]string{"/boot-time", "/trace/*"}, o.trimmableMetadata())
+	assert.Equal(8, o.maxUnacknowledged())
+	assert.Equal(FlowControlReject, o.flowControlPolicy())
+	assert.Equal(25, o.messageHistorySize())
+	assert.Equal(2*time.Minute, o.messageTTL())
+	assert.Equal(10*time.Minute, o.serviceTTL())
+	assert.Equal(5, o.maxServices())
+	assert.True(o.requireRegisteredServices())
+	assert.Equal(o.ConveySchema, o.conveySchema())
 	assert.Equal(o.Listeners, o.listeners())
+	assert.Equal(o.KeepAlivePolicies, o.keepAlivePolicies())
+	assert.Equal(Interceptors(o.InboundInterceptors), o.inboundInterceptors())
+	assert.Equal(Interceptors(o.OutboundInterceptors), o.outboundInterceptors())
 	assert.Equal(expectedMetricsProvider, o.metricsProvider())
 }
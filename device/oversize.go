@@ -0,0 +1,115 @@
+package device
+
+import (
+	"net/http"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+// OversizePolicy determines how a manager handles a WRP message that exceeds a configured size limit
+type OversizePolicy string
+
+const (
+	// OversizeReject refuses an oversize message.  If an inbound message is the device's response to a pending
+	// transaction, that transaction fails with ErrorMessageTooLarge.  If it is a transactional request from the
+	// device, the device is sent a WRP response with status 413.  Other inbound messages are discarded.
+	// An oversize outbound message fails with ErrorMessageTooLarge.
+	OversizeReject OversizePolicy = "reject"
+
+	// OversizeDrop discards an oversize message.  An oversize outbound message fails with ErrorMessageTooLarge,
+	// so that the sender does not wait on a message that will never be delivered.
+	OversizeDrop OversizePolicy = "drop"
+
+	// OversizeDisconnect discards an oversize message and disconnects the device
+	OversizeDisconnect OversizePolicy = "disconnect"
+
+	// DefaultOversizePolicy is the policy used when none is configured
+	DefaultOversizePolicy = OversizeReject
+)
+
+const (
+	// DirectionLabel is the metric label for whether a message was inbound from or outbound to a device
+	DirectionLabel = "direction"
+
+	// PolicyLabel is the metric label for the OversizePolicy applied to a message
+	PolicyLabel = "policy"
+
+	InboundDirection  = "inbound"
+	OutboundDirection = "outbound"
+)
+
+// oversizeReadFactor bounds the size of an inbound frame, as a multiple of the maximum inbound message size,
+// under the policies that must read an oversize message in order to handle it
+const oversizeReadFactor = 4
+
+// readLimit computes the websocket read limit for device connections, or zero if inbound messages are not limited.
+// Under OversizeDisconnect, the limit is the maximum inbound message size, so an oversize frame is never buffered.
+// The other policies read an oversize message before discarding it, so they allow frames up to oversizeReadFactor
+// times the maximum.  A device that sends a larger frame is disconnected regardless of policy, so that no device
+// can make the server buffer an arbitrarily large frame.
+func (m *manager) readLimit() int64 {
+	switch {
+	case m.maxInboundMessageSize < 1:
+		return 0
+
+	case m.oversizePolicy == OversizeDisconnect:
+		return int64(m.maxInboundMessageSize)
+
+	default:
+		return int64(m.maxInboundMessageSize) * oversizeReadFactor
+	}
+}
+
+// oversize tests if a message of the given size exceeds a limit, where a nonpositive limit means no limit
+func oversize(size, limit int) bool {
+	return limit > 0 && size > limit
+}
+
+// recordOversize logs and counts an oversize message
+func (m *manager) recordOversize(d *device, direction string, size int) {
+	m.measures.Oversize.With(DirectionLabel, direction, PolicyLabel, string(m.oversizePolicy)).Add(1.0)
+	d.errorLog.Log(
+		logging.MessageKey(), "oversize message",
+		"direction", direction,
+		"size", size,
+		"policy", m.oversizePolicy,
+	)
+}
+
+// rejectInbound handles an oversize inbound message under the OversizeReject policy.  A message that completes a
+// pending transaction is a response from the device, so that transaction is failed.  Otherwise, a transactional
+// message is a request from the device, which is answered with a 413.
+func (m *manager) rejectInbound(d *device, data []byte, decoder wrp.Decoder) {
+	var message wrp.Message
+	decoder.ResetBytes(data)
+	err := decoder.Decode(&message)
+	decoder.ResetBytes(nil)
+	if err != nil {
+		d.errorLog.Log(logging.MessageKey(), "skipping malformed oversize WRP message", logging.ErrorKey(), err)
+		return
+	}
+
+	if !message.IsTransactionPart() {
+		return
+	}
+
+	if d.transactions.Fail(message.TransactionKey(), ErrorMessageTooLarge) == nil {
+		return
+	}
+
+	rejection := &envelope{
+		request: &Request{
+			Message: wrp.NewErrorResponse(&message, http.StatusRequestEntityTooLarge, ErrorMessageTooLarge.Error()),
+			Format:  wrp.Msgpack,
+		},
+		complete: make(chan error, 1),
+	}
+
+	// never block the read pump waiting on the device's queue
+	select {
+	case d.messages <- rejection:
+	default:
+		d.errorLog.Log(logging.MessageKey(), "unable to queue oversize rejection", logging.ErrorKey(), &QueueFullError{ID: d.id, Size: cap(d.messages)})
+	}
+}
@@ -0,0 +1,320 @@
+package device
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startOversizeTest connects a single device to a manager configured with the given size limits and policy.
+// Device events are sent to the returned channel.  If the options have no Logger, output is sent to the test log.
+func startOversizeTest(t *testing.T, o *Options) (Manager, *websocket.Conn, <-chan *Event, func()) {
+	events := make(chan *Event, 10)
+	if o.Logger == nil {
+		// the pumps outlive each test, so a test logger would be written to after its test completes
+		o.Logger = logging.DefaultLogger()
+	}
+
+	o.AuthDelay = time.Hour
+	o.Listeners = []Listener{
+		func(e *Event) {
+			// copy only what the tests need, since events are reused
+			events <- &Event{Type: e.Type, Device: e.Device, Error: e.Error}
+		},
+	}
+
+	manager, server, connectURL := startWebsocketServer(o)
+	connection, _, err := DefaultDialer().DialDevice(string(testDeviceIDs[0]), connectURL, nil)
+	if err != nil {
+		server.Close()
+		require.NoError(t, err)
+	}
+
+	waitForEvent(t, events, Connect)
+	return manager, connection, events, func() {
+		connection.Close()
+		server.Close()
+	}
+}
+
+func waitForEvent(t *testing.T, events <-chan *Event, eventType EventType) *Event {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type == eventType {
+				return e
+			}
+		case <-timeout:
+			require.Fail(t, "No event received", "eventType: %s", eventType)
+			return nil
+		}
+	}
+}
+
+func newOversizeRequest() *Request {
+	return &Request{
+		Message: &wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      "test",
+			Destination: string(testDeviceIDs[0]) + "/service",
+			Payload:     bytes.Repeat([]byte{'x'}, 500),
+		},
+		Format: wrp.Msgpack,
+	}
+}
+
+func testOversizeOutbound(t *testing.T, policy OversizePolicy) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+
+		manager, _, events, stop = startOversizeTest(t, &Options{
+			MaxOutboundMessageSize: 100,
+			OversizePolicy:         policy,
+			MetricsProvider:        provider,
+		})
+	)
+
+	defer stop()
+	provider.Expect(OversizeMessageCounter, DirectionLabel, OutboundDirection, PolicyLabel, string(policy))(xmetricstest.Value(1.0))
+
+	response, err := manager.Route(newOversizeRequest())
+	assert.Nil(response)
+	assert.Equal(ErrorMessageTooLarge, err)
+
+	failed := waitForEvent(t, events, MessageFailed)
+	require.NotNil(failed)
+	assert.Equal(ErrorMessageTooLarge, failed.Error)
+
+	if policy == OversizeDisconnect {
+		waitForEvent(t, events, Disconnect)
+	} else {
+		_, ok := manager.Get(testDeviceIDs[0])
+		assert.True(ok)
+	}
+
+	provider.AssertExpectations(t)
+}
+
+func testOversizeInboundReject(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+
+		_, connection, events, stop = startOversizeTest(t, &Options{
+			MaxInboundMessageSize: 200,
+			MetricsProvider:       provider,
+		})
+
+		request = wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          string(testDeviceIDs[0]) + "/service",
+			Destination:     "dns:server",
+			TransactionUUID: "test-transaction",
+			Payload:         bytes.Repeat([]byte{'x'}, 500),
+		}
+
+		data []byte
+	)
+
+	defer stop()
+	provider.Expect(OversizeMessageCounter, DirectionLabel, InboundDirection, PolicyLabel, string(OversizeReject))(xmetricstest.Value(1.0))
+
+	require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(&request))
+	require.NoError(connection.WriteMessage(websocket.BinaryMessage, data))
+
+	connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	messageType, data, err := connection.ReadMessage()
+	require.NoError(err)
+	assert.Equal(websocket.BinaryMessage, messageType)
+
+	var rejection wrp.Message
+	require.NoError(wrp.NewDecoderBytes(data, wrp.Msgpack).Decode(&rejection))
+	assert.Equal(request.Destination, rejection.Source)
+	assert.Equal(request.Source, rejection.Destination)
+	assert.Equal(request.TransactionUUID, rejection.TransactionUUID)
+	require.NotNil(rejection.Status)
+	assert.Equal(int64(http.StatusRequestEntityTooLarge), *rejection.Status)
+	assert.Equal(wrp.ErrorPayloadContentType, rejection.ContentType)
+
+	// the rejection is sent, but the oversize message is never received
+	waitForEvent(t, events, MessageSent)
+	provider.AssertExpectations(t)
+}
+
+func testOversizeInboundRejectResponse(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+
+		manager, connection, _, stop = startOversizeTest(t, &Options{
+			MaxInboundMessageSize: 200,
+			MetricsProvider:       provider,
+		})
+
+		request = &Request{
+			Message: &wrp.Message{
+				Type:            wrp.SimpleRequestResponseMessageType,
+				Source:          "dns:server",
+				Destination:     string(testDeviceIDs[0]) + "/service",
+				TransactionUUID: "test-transaction",
+			},
+			Format: wrp.Msgpack,
+		}
+
+		routeErrors = make(chan error, 1)
+	)
+
+	defer stop()
+	provider.Expect(OversizeMessageCounter, DirectionLabel, InboundDirection, PolicyLabel, string(OversizeReject))(xmetricstest.Value(1.0))
+
+	go func() {
+		_, err := manager.Route(request)
+		routeErrors <- err
+	}()
+
+	connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := connection.ReadMessage()
+	require.NoError(err)
+
+	var received wrp.Message
+	require.NoError(wrp.NewDecoderBytes(data, wrp.Msgpack).Decode(&received))
+	assert.Equal("test-transaction", received.TransactionUUID)
+
+	response := wrp.Message{
+		Type:            wrp.SimpleRequestResponseMessageType,
+		Source:          received.Destination,
+		Destination:     received.Source,
+		TransactionUUID: received.TransactionUUID,
+		Payload:         bytes.Repeat([]byte{'x'}, 500),
+	}
+
+	data = nil
+	require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(&response))
+	require.NoError(connection.WriteMessage(websocket.BinaryMessage, data))
+
+	// the pending transaction fails, rather than waiting for a response that will never arrive
+	select {
+	case err := <-routeErrors:
+		assert.Equal(ErrorMessageTooLarge, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The transaction did not fail")
+	}
+
+	// the device is not sent a 413 for its own response
+	connection.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err = connection.ReadMessage()
+	assert.Error(err)
+
+	provider.AssertExpectations(t)
+}
+
+func testOversizeInbound(t *testing.T, policy OversizePolicy) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+
+		manager, connection, events, stop = startOversizeTest(t, &Options{
+			MaxInboundMessageSize: 200,
+			OversizePolicy:        policy,
+			MetricsProvider:       provider,
+		})
+
+		small = wrp.Message{Type: wrp.SimpleEventMessageType, Source: string(testDeviceIDs[0]), Destination: "event:test"}
+		large = small
+
+		data []byte
+	)
+
+	defer stop()
+	provider.Expect(OversizeMessageCounter, DirectionLabel, InboundDirection, PolicyLabel, string(policy))(xmetricstest.Value(1.0))
+
+	large.Payload = bytes.Repeat([]byte{'x'}, 500)
+	require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(&large))
+	require.NoError(connection.WriteMessage(websocket.BinaryMessage, data))
+
+	if policy == OversizeDisconnect {
+		waitForEvent(t, events, Disconnect)
+		_, ok := manager.Get(testDeviceIDs[0])
+		assert.False(ok)
+	} else {
+		data = nil
+		require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(&small))
+		require.NoError(connection.WriteMessage(websocket.BinaryMessage, data))
+
+		// only the small message is received
+		waitForEvent(t, events, MessageReceived)
+		select {
+		case e := <-events:
+			assert.Fail("Unexpected event", "event: %s", e.Type)
+		default:
+		}
+	}
+
+	provider.AssertExpectations(t)
+}
+
+func testOversizeInboundReadLimit(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+
+		manager, connection, events, stop = startOversizeTest(t, &Options{
+			MaxInboundMessageSize: 200,
+			OversizePolicy:        OversizeReject,
+			MetricsProvider:       provider,
+		})
+
+		huge = wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      string(testDeviceIDs[0]),
+			Destination: "event:test",
+			Payload:     bytes.Repeat([]byte{'x'}, 5000),
+		}
+
+		data []byte
+	)
+
+	defer stop()
+	provider.Expect(OversizeMessageCounter, DirectionLabel, InboundDirection, PolicyLabel, string(OversizeReject))(xmetricstest.Value(1.0))
+
+	// a frame beyond the read limit disconnects the device, even though the policy would otherwise reject the message
+	require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(&huge))
+	require.NoError(connection.WriteMessage(websocket.BinaryMessage, data))
+
+	waitForEvent(t, events, Disconnect)
+	_, ok := manager.Get(testDeviceIDs[0])
+	assert.False(ok)
+	provider.AssertExpectations(t)
+}
+
+func TestOversize(t *testing.T) {
+	t.Run("Outbound", func(t *testing.T) {
+		for _, policy := range []OversizePolicy{OversizeReject, OversizeDrop, OversizeDisconnect} {
+			t.Run(string(policy), func(t *testing.T) { testOversizeOutbound(t, policy) })
+		}
+	})
+
+	t.Run("Inbound", func(t *testing.T) {
+		t.Run(string(OversizeReject), testOversizeInboundReject)
+		t.Run("RejectResponse", testOversizeInboundRejectResponse)
+		t.Run("ReadLimit", testOversizeInboundReadLimit)
+		for _, policy := range []OversizePolicy{OversizeDrop, OversizeDisconnect} {
+			t.Run(string(policy), func(t *testing.T) { testOversizeInbound(t, policy) })
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package device
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// ProtocolVersionHeader is the HTTP header a device uses to offer a comma-separated list of the protocol
+	// versions it supports, in order of preference.  The negotiated version is returned in the same header.
+	ProtocolVersionHeader = "X-Webpa-Protocol-Version"
+
+	// SubprotocolPrefix is prepended to a protocol version to form the websocket subprotocol for that version,
+	// e.g. "wrp.v2".  Devices may offer versions as subprotocols instead of using ProtocolVersionHeader.
+	SubprotocolPrefix = "wrp.v"
+
+	// DefaultProtocolVersion is the version assigned to devices that do not offer any protocol versions
+	DefaultProtocolVersion = "1"
+)
+
+// Protocol describes a device protocol version, and how a Manager communicates with devices using that version
+type Protocol struct {
+	// Version is the protocol version, e.g. "2"
+	Version string
+
+	// Format is the WRP encoding of websocket frames exchanged with devices.  The zero value is wrp.Msgpack.
+	Format wrp.Format
+}
+
+// defaultProtocols is used when no protocols are configured
+var defaultProtocols = []Protocol{{Version: DefaultProtocolVersion, Format: wrp.Msgpack}}
+
+// Subprotocol returns the websocket subprotocol for a protocol version
+func Subprotocol(version string) string {
+	return SubprotocolPrefix + version
+}
+
+// offeredVersions returns the protocol versions offered by a device's upgrade request, and whether the
+// versions were offered as websocket subprotocols.  Subprotocols take precedence over ProtocolVersionHeader.
+func offeredVersions(request *http.Request) ([]string, bool) {
+	var versions []string
+	for _, subprotocol := range websocket.Subprotocols(request) {
+		if strings.HasPrefix(subprotocol, SubprotocolPrefix) {
+			versions = append(versions, strings.TrimPrefix(subprotocol, SubprotocolPrefix))
+		}
+	}
+
+	if len(versions) > 0 {
+		return versions, true
+	}
+
+	for _, value := range request.Header[ProtocolVersionHeader] {
+		for _, version := range strings.Split(value, ",") {
+			if version = strings.TrimSpace(version); len(version) > 0 {
+				versions = append(versions, version)
+			}
+		}
+	}
+
+	return versions, false
+}
+
+// negotiateProtocol selects the protocol for a connecting device.  The supported protocols are in the server's
+// order of preference, so the first supported protocol the device offers is chosen.  A device which offers
+// nothing is assigned DefaultProtocolVersion.  If the negotiated version was offered as a subprotocol, that
+// subprotocol is set in the response header so that the upgrade accepts it.
+//
+// If no supported protocol matches, ErrorUnsupportedProtocolVersion is returned.
+func negotiateProtocol(request *http.Request, responseHeader http.Header, supported []Protocol) (Protocol, error) {
+	offered, subprotocol := offeredVersions(request)
+	if len(offered) == 0 {
+		offered = []string{DefaultProtocolVersion}
+	}
+
+	for _, candidate := range supported {
+		for _, version := range offered {
+			if candidate.Version == version {
+				if subprotocol {
+					responseHeader.Set("Sec-Websocket-Protocol", Subprotocol(version))
+				}
+
+				responseHeader.Set(ProtocolVersionHeader, version)
+				return candidate, nil
+			}
+		}
+	}
+
+	return Protocol{}, ErrorUnsupportedProtocolVersion
+}
+
+// authStatusMessages prepares the authorization status message for each protocol's format.  As with the
+// default Msgpack message, this function panics if a message cannot be prepared.
+func authStatusMessages(protocols []Protocol) map[wrp.Format]*websocket.PreparedMessage {
+	messages := map[wrp.Format]*websocket.PreparedMessage{wrp.Msgpack: authStatus}
+	for _, p := range protocols {
+		if _, ok := messages[p.Format]; ok {
+			continue
+		}
+
+		message, err := websocket.NewPreparedMessage(
+			websocket.BinaryMessage,
+			wrp.MustEncode(&wrp.AuthorizationStatus{Status: wrp.AuthStatusAuthorized}, p.Format),
+		)
+
+		if err != nil {
+			panic(err)
+		}
+
+		messages[p.Format] = message
+	}
+
+	return messages
+}
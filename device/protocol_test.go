@@ -0,0 +1,177 @@
+package device
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubprotocol(t *testing.T) {
+	assert.Equal(t, "wrp.v2", Subprotocol("2"))
+}
+
+func TestNegotiateProtocol(t *testing.T) {
+	var (
+		v1 = Protocol{Version: "1"}
+		v2 = Protocol{Version: "2", Format: wrp.JSON}
+
+		testData = []struct {
+			header              http.Header
+			supported           []Protocol
+			expected            Protocol
+			expectedError       error
+			expectedSubprotocol string
+		}{
+			{http.Header{}, defaultProtocols, v1, nil, ""},
+			{http.Header{}, []Protocol{v2, v1}, v1, nil, ""},
+			{http.Header{}, []Protocol{v2}, Protocol{}, ErrorUnsupportedProtocolVersion, ""},
+			{http.Header{ProtocolVersionHeader: {"1, 2"}}, []Protocol{v2, v1}, v2, nil, ""},
+			{http.Header{ProtocolVersionHeader: {"1", "2"}}, []Protocol{v1, v2}, v1, nil, ""},
+			{http.Header{ProtocolVersionHeader: {" 2 ,"}}, []Protocol{v1, v2}, v2, nil, ""},
+			{http.Header{ProtocolVersionHeader: {"3"}}, []Protocol{v1, v2}, Protocol{}, ErrorUnsupportedProtocolVersion, ""},
+			{http.Header{"Sec-Websocket-Protocol": {"chat, wrp.v2"}}, []Protocol{v1, v2}, v2, nil, "wrp.v2"},
+			{http.Header{"Sec-Websocket-Protocol": {"wrp.v1"}, ProtocolVersionHeader: {"2"}}, []Protocol{v2, v1}, v1, nil, "wrp.v1"},
+			{http.Header{"Sec-Websocket-Protocol": {"chat"}, ProtocolVersionHeader: {"2"}}, []Protocol{v2, v1}, v2, nil, ""},
+			{http.Header{"Sec-Websocket-Protocol": {"wrp.v3"}}, []Protocol{v1, v2}, Protocol{}, ErrorUnsupportedProtocolVersion, ""},
+		}
+	)
+
+	for i, record := range testData {
+		var (
+			request        = httptest.NewRequest("GET", "/", nil)
+			responseHeader = make(http.Header)
+		)
+
+		request.Header = record.header
+		actual, err := negotiateProtocol(request, responseHeader, record.supported)
+		assert.Equal(t, record.expected, actual, "#%d", i)
+		assert.Equal(t, record.expectedError, err, "#%d", i)
+		assert.Equal(t, record.expectedSubprotocol, responseHeader.Get("Sec-Websocket-Protocol"), "#%d", i)
+		assert.Equal(t, record.expected.Version, responseHeader.Get(ProtocolVersionHeader), "#%d", i)
+	}
+}
+
+func TestAuthStatusMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	messages := authStatusMessages(defaultProtocols)
+	assert.Len(messages, 1)
+	assert.Equal(authStatus, messages[wrp.Msgpack])
+
+	messages = authStatusMessages([]Protocol{{Version: "2", Format: wrp.JSON}, {Version: "1"}, {Version: "3", Format: wrp.JSON}})
+	assert.Len(messages, 2)
+	assert.Equal(authStatus, messages[wrp.Msgpack])
+	assert.NotNil(messages[wrp.JSON])
+}
+
+func testProtocolNegotiated(t *testing.T, dialer Dialer, extra http.Header, expectedSubprotocol string) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+		events   = make(chan *Event, 10)
+
+		manager, server, connectURL = startWebsocketServer(&Options{
+			Protocols:       []Protocol{{Version: "2", Format: wrp.JSON}, {Version: "1"}},
+			AuthDelay:       time.Hour,
+			Logger:          logging.DefaultLogger(),
+			MetricsProvider: provider,
+			Listeners: []Listener{
+				func(e *Event) {
+					// copy only what the tests need, since events are reused
+					events <- &Event{Type: e.Type, Device: e.Device, Format: e.Format, Contents: e.Contents}
+				},
+			},
+		})
+	)
+
+	defer server.Close()
+	provider.Expect(ProtocolVersionCounter, VersionLabel, "2")(xmetricstest.Value(1.0))
+
+	connection, response, err := dialer.DialDevice(string(testDeviceIDs[0]), connectURL, extra)
+	require.NoError(err)
+	defer connection.Close()
+
+	assert.Equal("2", response.Header.Get(ProtocolVersionHeader))
+	assert.Equal(expectedSubprotocol, connection.Subprotocol())
+	waitForEvent(t, events, Connect)
+
+	d, ok := manager.Get(testDeviceIDs[0])
+	require.True(ok)
+	assert.Equal("2", d.ProtocolVersion())
+
+	// outbound messages are encoded using the negotiated format
+	_, err = manager.Route(&Request{
+		Message: &wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      "test",
+			Destination: string(testDeviceIDs[0]) + "/service",
+		},
+		Format: wrp.Msgpack,
+	})
+
+	require.NoError(err)
+	_, frame, err := connection.ReadMessage()
+	require.NoError(err)
+
+	var outbound wrp.Message
+	require.NoError(wrp.NewDecoderBytes(frame, wrp.JSON).Decode(&outbound))
+	assert.Equal("test", outbound.Source)
+
+	// inbound messages are decoded using the negotiated format
+	inbound := wrp.MustEncode(&wrp.Message{Type: wrp.SimpleEventMessageType, Source: string(testDeviceIDs[0]), Destination: "event:test"}, wrp.JSON)
+	require.NoError(connection.WriteMessage(websocket.BinaryMessage, inbound))
+
+	received := waitForEvent(t, events, MessageReceived)
+	require.NotNil(received)
+	assert.Equal(wrp.JSON, received.Format)
+	assert.Equal(inbound, received.Contents)
+
+	provider.AssertExpectations(t)
+}
+
+func testProtocolUnsupported(t *testing.T, protocols []Protocol, extra http.Header) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		_, server, connectURL = startWebsocketServer(&Options{
+			Protocols: protocols,
+			Logger:    logging.NewTestLogger(nil, t),
+		})
+	)
+
+	defer server.Close()
+	connection, response, err := DefaultDialer().DialDevice(string(testDeviceIDs[0]), connectURL, extra)
+	assert.Error(err)
+	assert.Nil(connection)
+	require.NotNil(response)
+	assert.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func TestProtocolNegotiation(t *testing.T) {
+	t.Run("Header", func(t *testing.T) {
+		testProtocolNegotiated(t, DefaultDialer(), http.Header{ProtocolVersionHeader: {"2"}}, "")
+	})
+
+	t.Run("Subprotocol", func(t *testing.T) {
+		dialer := NewDialer(DialerOptions{WSDialer: &websocket.Dialer{Subprotocols: []string{Subprotocol("2")}}})
+		testProtocolNegotiated(t, dialer, nil, Subprotocol("2"))
+	})
+
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		testProtocolUnsupported(t, nil, http.Header{ProtocolVersionHeader: {"3"}})
+	})
+
+	t.Run("NegotiationRequired", func(t *testing.T) {
+		testProtocolUnsupported(t, []Protocol{{Version: "2"}}, nil)
+	})
+}
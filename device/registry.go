@@ -6,6 +6,7 @@ import (
 
 	"github.com/Comcast/webpa-common/xmetrics"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
 )
 
 var errDeviceLimitReached = errors.New("Device limit reached")
@@ -31,6 +32,10 @@ type registry struct {
 	connect      xmetrics.Incrementer
 	disconnect   xmetrics.Adder
 	duplicates   xmetrics.Incrementer
+
+	connectReason      metrics.Counter
+	disconnectReason   metrics.Counter
+	connectionDuration metrics.Histogram
 }
 
 func newRegistry(o registryOptions) *registry {
@@ -48,6 +53,10 @@ func newRegistry(o registryOptions) *registry {
 		connect:         o.Measures.Connect,
 		disconnect:      o.Measures.Disconnect,
 		duplicates:      o.Measures.Duplicates,
+
+		connectReason:      o.Measures.ConnectReason,
+		disconnectReason:   o.Measures.DisconnectReason,
+		connectionDuration: o.Measures.ConnectionDuration,
 	}
 }
 
@@ -63,7 +72,8 @@ func (r *registry) add(newDevice *device) error {
 		r.lock.Unlock()
 		r.limitReached.Inc()
 		r.disconnect.Add(1.0)
-		newDevice.requestClose()
+		r.disconnectReason.With(ReasonLabel, ReasonDeviceLimitReached).Add(1.0)
+		newDevice.requestClose(ReasonDeviceLimitReached)
 		return errDeviceLimitReached
 	}
 
@@ -73,17 +83,28 @@ func (r *registry) add(newDevice *device) error {
 	r.lock.Unlock()
 
 	if existing != nil {
-		r.disconnect.Add(1.0)
+		r.disconnected(existing, ReasonDuplicate)
 		r.duplicates.Inc()
 		newDevice.Statistics().AddDuplications(existing.Statistics().Duplications() + 1)
-		existing.requestClose()
+		existing.requestClose(ReasonDuplicate)
+		r.connectReason.With(ReasonLabel, ReasonDuplicate).Add(1.0)
+	} else {
+		r.connectReason.With(ReasonLabel, ReasonNew).Add(1.0)
 	}
 
 	r.connect.Inc()
 	return nil
 }
 
-func (r *registry) remove(id ID) (*device, bool) {
+// disconnected records the metrics for a single device that has been removed from this registry
+func (r *registry) disconnected(d *device, reason string) {
+	r.disconnect.Add(1.0)
+	r.disconnectReason.With(ReasonLabel, reason).Add(1.0)
+	r.connectionDuration.Observe(d.Statistics().UpTime().Seconds())
+}
+
+// remove deletes the device with the given identifier, recording the reason for its disconnection
+func (r *registry) remove(id ID, reason string) (*device, bool) {
 	r.lock.Lock()
 	existing, ok := r.data[id]
 	if ok {
@@ -94,8 +115,8 @@ func (r *registry) remove(id ID) (*device, bool) {
 	r.lock.Unlock()
 
 	if existing != nil {
-		r.disconnect.Add(1.0)
-		existing.requestClose()
+		r.disconnected(existing, reason)
+		existing.requestClose(reason)
 	}
 
 	return existing, ok
@@ -134,14 +155,11 @@ func (r *registry) removeIf(f func(d *device) bool) int {
 
 		if ok {
 			count++
-			d.requestClose()
+			r.disconnected(d, ReasonDisconnectRequested)
+			d.requestClose(ReasonDisconnectRequested)
 		}
 	}
 
-	if count > 0 {
-		r.disconnect.Add(float64(count))
-	}
-
 	return count
 }
 
@@ -154,10 +172,10 @@ func (r *registry) removeAll() int {
 
 	count := len(original)
 	for _, d := range original {
-		d.requestClose()
+		r.disconnected(d, ReasonDisconnectRequested)
+		d.requestClose(ReasonDisconnectRequested)
 	}
 
-	r.disconnect.Add(float64(count))
 	return count
 }
 
@@ -63,6 +63,9 @@ func testRegistryAdd(t *testing.T) {
 		p.Assert(t, DisconnectCounter)(xmetricstest.Value(1.0))
 		p.Assert(t, DeviceLimitReachedCounter)(xmetricstest.Value(0.0))
 		p.Assert(t, DuplicatesCounter)(xmetricstest.Value(1.0))
+		p.Assert(t, ConnectReasonCounter, ReasonLabel, ReasonNew)(xmetricstest.Value(10.0))
+		p.Assert(t, ConnectReasonCounter, ReasonLabel, ReasonDuplicate)(xmetricstest.Value(1.0))
+		p.Assert(t, DisconnectReasonCounter, ReasonLabel, ReasonDuplicate)(xmetricstest.Value(1.0))
 
 		assert.True(existing.Closed())
 		assert.False(duplicate.Closed())
@@ -115,6 +118,7 @@ func testRegistryAdd(t *testing.T) {
 		p.Assert(t, DisconnectCounter)(xmetricstest.Value(1.0))
 		p.Assert(t, DeviceLimitReachedCounter)(xmetricstest.Value(1.0))
 		p.Assert(t, DuplicatesCounter)(xmetricstest.Value(0.0))
+		p.Assert(t, DisconnectReasonCounter, ReasonLabel, ReasonDeviceLimitReached)(xmetricstest.Value(1.0))
 
 		duplicate := newDevice(deviceOptions{
 			ID:     ID("test"),
@@ -179,7 +183,7 @@ func testRegistryRemoveAndGet(t *testing.T) {
 	p.Assert(t, DeviceLimitReachedCounter)(xmetricstest.Value(0.0))
 	p.Assert(t, DuplicatesCounter)(xmetricstest.Value(0.0))
 
-	existing, ok = r.remove(ID("nosuch"))
+	existing, ok = r.remove(ID("nosuch"), ReasonDisconnectRequested)
 	assert.Nil(existing)
 	assert.False(ok)
 	assert.False(initial.Closed())
@@ -189,7 +193,7 @@ func testRegistryRemoveAndGet(t *testing.T) {
 	p.Assert(t, DeviceLimitReachedCounter)(xmetricstest.Value(0.0))
 	p.Assert(t, DuplicatesCounter)(xmetricstest.Value(0.0))
 
-	existing, ok = r.remove(ID("test"))
+	existing, ok = r.remove(ID("test"), ReasonReadError)
 	assert.True(existing == initial)
 	assert.True(ok)
 	assert.True(initial.Closed())
@@ -198,6 +202,8 @@ func testRegistryRemoveAndGet(t *testing.T) {
 	p.Assert(t, DisconnectCounter)(xmetricstest.Value(1.0))
 	p.Assert(t, DeviceLimitReachedCounter)(xmetricstest.Value(0.0))
 	p.Assert(t, DuplicatesCounter)(xmetricstest.Value(0.0))
+	p.Assert(t, DisconnectReasonCounter, ReasonLabel, ReasonReadError)(xmetricstest.Value(1.0))
+	p.Assert(t, ConnectionDurationHistogram)(xmetricstest.Histogram)
 
 	existing, ok = r.get(ID("test"))
 	assert.Nil(existing)
@@ -264,6 +270,7 @@ func testRegistryRemoveIf(t *testing.T) {
 	p.Assert(t, DisconnectCounter)(xmetricstest.Value(1.0))
 	p.Assert(t, DeviceLimitReachedCounter)(xmetricstest.Value(0.0))
 	p.Assert(t, DuplicatesCounter)(xmetricstest.Value(0.0))
+	p.Assert(t, DisconnectReasonCounter, ReasonLabel, ReasonDisconnectRequested)(xmetricstest.Value(1.0))
 }
 
 func testRegistryRemoveAll(t *testing.T) {
@@ -296,6 +303,8 @@ func testRegistryRemoveAll(t *testing.T) {
 	p.Assert(t, DisconnectCounter)(xmetricstest.Value(3.0))
 	p.Assert(t, DeviceLimitReachedCounter)(xmetricstest.Value(0.0))
 	p.Assert(t, DuplicatesCounter)(xmetricstest.Value(0.0))
+	p.Assert(t, ConnectReasonCounter, ReasonLabel, ReasonNew)(xmetricstest.Value(3.0))
+	p.Assert(t, DisconnectReasonCounter, ReasonLabel, ReasonDisconnectRequested)(xmetricstest.Value(3.0))
 
 	for _, d := range devices {
 		assert.True(d.Closed())
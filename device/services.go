@@ -0,0 +1,153 @@
+package device
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ServiceEntry describes a service that a device has advertised with a WRP service registration message
+type ServiceEntry struct {
+	// Name is the service name from the registration message
+	Name string `json:"name"`
+
+	// URL is the URL at which the device's service is listening, if the device supplied one
+	URL string `json:"url,omitempty"`
+
+	// RegisteredAt is the time of the most recent registration message for this service
+	RegisteredAt time.Time `json:"registeredAt"`
+
+	// LastAlive is the time the device most recently confirmed this service, either with a registration
+	// or with a service alive message
+	LastAlive time.Time `json:"lastAlive"`
+}
+
+// Services is the table of services advertised by a single device.  Devices register services with
+// WRP service registration messages, and periodically confirm that all their registered services are still
+// running with service alive messages.  A nil Services never has any services.
+type Services struct {
+	lock    sync.RWMutex
+	ttl     time.Duration
+	max     int
+	now     func() time.Time
+	entries map[string]ServiceEntry
+}
+
+// NewServices creates an empty service table.  Services which are not confirmed within the given ttl are
+// considered expired.  If ttl is nonpositive, services never expire.  At most max services may be registered
+// at any time; if max is nonpositive, DefaultMaxServices is used.  If now is nil, time.Now is used.
+func NewServices(ttl time.Duration, max int, now func() time.Time) *Services {
+	if max < 1 {
+		max = DefaultMaxServices
+	}
+
+	if now == nil {
+		now = time.Now
+	}
+
+	return &Services{
+		ttl:     ttl,
+		max:     max,
+		now:     now,
+		entries: make(map[string]ServiceEntry),
+	}
+}
+
+// expired tests if an entry has gone without confirmation for longer than the ttl
+func (s *Services) expired(e ServiceEntry, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(e.LastAlive) > s.ttl
+}
+
+// Register adds a service to this table, or updates the URL of a service that is already registered.
+// If this table is full, expired services are removed to make room.  If there is still no room,
+// this method returns ErrorTooManyServices and the table is unchanged.
+func (s *Services) Register(name, url string) error {
+	if s == nil {
+		return nil
+	}
+
+	now := s.now().UTC()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.entries[name]; !ok && len(s.entries) >= s.max {
+		for n, e := range s.entries {
+			if s.expired(e, now) {
+				delete(s.entries, n)
+			}
+		}
+
+		if len(s.entries) >= s.max {
+			return ErrorTooManyServices
+		}
+	}
+
+	s.entries[name] = ServiceEntry{
+		Name:         name,
+		URL:          url,
+		RegisteredAt: now,
+		LastAlive:    now,
+	}
+
+	return nil
+}
+
+// Alive confirms all registered services which have not yet expired.  Expired services are removed,
+// since the device must register them again.
+func (s *Services) Alive() {
+	if s == nil {
+		return
+	}
+
+	now := s.now().UTC()
+	s.lock.Lock()
+	for name, e := range s.entries {
+		if s.expired(e, now) {
+			delete(s.entries, name)
+			continue
+		}
+
+		e.LastAlive = now
+		s.entries[name] = e
+	}
+
+	s.lock.Unlock()
+}
+
+// Get returns the entry for the given service.  If the service is not registered or has expired,
+// this method returns false.
+func (s *Services) Get(name string) (ServiceEntry, bool) {
+	if s == nil {
+		return ServiceEntry{}, false
+	}
+
+	s.lock.RLock()
+	e, ok := s.entries[name]
+	s.lock.RUnlock()
+
+	if !ok || s.expired(e, s.now()) {
+		return ServiceEntry{}, false
+	}
+
+	return e, true
+}
+
+// Entries returns the services which have not expired, sorted by name
+func (s *Services) Entries() []ServiceEntry {
+	if s == nil {
+		return nil
+	}
+
+	now := s.now()
+	s.lock.RLock()
+	output := make([]ServiceEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		if !s.expired(e, now) {
+			output = append(output, e)
+		}
+	}
+
+	s.lock.RUnlock()
+	sort.Slice(output, func(i, j int) bool { return output[i].Name < output[j].Name })
+	return output
+}
@@ -0,0 +1,178 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testServicesNil(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		services *Services
+	)
+
+	assert.NoError(services.Register("config", ""))
+	services.Alive()
+
+	entry, ok := services.Get("config")
+	assert.Equal(ServiceEntry{}, entry)
+	assert.False(ok)
+	assert.Empty(services.Entries())
+}
+
+func testServicesRegister(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		start    = time.Date(2018, time.March, 1, 12, 0, 0, 0, time.UTC)
+		now      = start
+		services = NewServices(0, 0, func() time.Time { return now })
+	)
+
+	entry, ok := services.Get("config")
+	assert.False(ok)
+	assert.Empty(services.Entries())
+
+	services.Register("config", "tcp://127.0.0.1:6666")
+	now = start.Add(time.Minute)
+	services.Register("a-service", "")
+
+	entry, ok = services.Get("config")
+	require.True(ok)
+	assert.Equal(ServiceEntry{Name: "config", URL: "tcp://127.0.0.1:6666", RegisteredAt: start, LastAlive: start}, entry)
+
+	now = start.Add(24 * time.Hour)
+	entries := services.Entries()
+	require.Len(entries, 2)
+	assert.Equal("a-service", entries[0].Name)
+	assert.Equal("config", entries[1].Name)
+
+	// registering again replaces the entry
+	services.Register("config", "tcp://127.0.0.1:7777")
+	entry, ok = services.Get("config")
+	require.True(ok)
+	assert.Equal(ServiceEntry{Name: "config", URL: "tcp://127.0.0.1:7777", RegisteredAt: now, LastAlive: now}, entry)
+}
+
+func testServicesExpiry(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		start    = time.Date(2018, time.March, 1, 12, 0, 0, 0, time.UTC)
+		now      = start
+		services = NewServices(time.Minute, 0, func() time.Time { return now })
+	)
+
+	services.Register("config", "")
+	now = start.Add(30 * time.Second)
+	services.Register("iot", "")
+
+	// an alive message refreshes every registered service
+	now = start.Add(45 * time.Second)
+	services.Alive()
+
+	now = start.Add(90 * time.Second)
+	entry, ok := services.Get("config")
+	require.True(ok)
+	assert.Equal(start, entry.RegisteredAt)
+	assert.Equal(start.Add(45*time.Second), entry.LastAlive)
+	assert.Len(services.Entries(), 2)
+
+	now = start.Add(2 * time.Minute)
+	_, ok = services.Get("config")
+	assert.False(ok)
+	assert.Empty(services.Entries())
+
+	// an alive message cannot revive expired services
+	services.Alive()
+	_, ok = services.Get("iot")
+	assert.False(ok)
+}
+
+func testServicesMax(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		start    = time.Date(2018, time.March, 1, 12, 0, 0, 0, time.UTC)
+		now      = start
+		services = NewServices(time.Minute, 2, func() time.Time { return now })
+	)
+
+	assert.NoError(services.Register("config", ""))
+	now = start.Add(30 * time.Second)
+	assert.NoError(services.Register("iot", ""))
+	assert.Equal(ErrorTooManyServices, services.Register("another", ""))
+	_, ok := services.Get("another")
+	assert.False(ok)
+
+	// existing services can always be registered again
+	assert.NoError(services.Register("iot", "tcp://127.0.0.1:6666"))
+
+	// expired services make room for new ones
+	now = start.Add(75 * time.Second)
+	assert.NoError(services.Register("another", ""))
+	_, ok = services.Get("config")
+	assert.False(ok)
+	assert.Len(services.Entries(), 2)
+}
+
+func TestServices(t *testing.T) {
+	t.Run("Nil", testServicesNil)
+	t.Run("Register", testServicesRegister)
+	t.Run("Expiry", testServicesExpiry)
+	t.Run("Max", testServicesMax)
+}
+
+func TestManagerServices(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		manager, connection, events, stop = startInterceptorTest(t, &Options{RequireRegisteredServices: true})
+
+		route = func(destination string) error {
+			_, err := manager.Route(&Request{
+				Message: &wrp.Message{Type: wrp.SimpleEventMessageType, Source: "test", Destination: destination},
+				Format:  wrp.Msgpack,
+			})
+
+			return err
+		}
+	)
+
+	defer stop()
+
+	assert.Equal(ErrorServiceNotRegistered, route(string(testDeviceIDs[0])+"/config"))
+
+	// messages without a service are always routed
+	require.NoError(route(string(testDeviceIDs[0])))
+	waitForEvent(t, events, MessageSent)
+
+	for _, message := range []*wrp.Message{
+		{Type: wrp.ServiceRegistrationMessageType},
+		{Type: wrp.ServiceRegistrationMessageType, ServiceName: "config", URL: "tcp://127.0.0.1:6666"},
+		{Type: wrp.ServiceAliveMessageType},
+	} {
+		var data []byte
+		require.NoError(wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(message))
+		require.NoError(connection.WriteMessage(websocket.BinaryMessage, data))
+		waitForEvent(t, events, MessageReceived)
+	}
+
+	d, ok := manager.Get(testDeviceIDs[0])
+	require.True(ok)
+
+	services := servicesOf(d)
+	require.Len(services, 1)
+	assert.Equal("config", services[0].Name)
+	assert.Equal("tcp://127.0.0.1:6666", services[0].URL)
+
+	require.NoError(route(string(testDeviceIDs[0]) + "/config"))
+	waitForEvent(t, events, MessageSent)
+
+	assert.Equal(ErrorServiceNotRegistered, route(string(testDeviceIDs[0])+"/iot"))
+}
@@ -49,6 +49,26 @@ type Statistics interface {
 
 	// UpTime computes the duration for which the device has been connected
 	UpTime() time.Duration
+
+	// LastActivity returns the time a message or pong was last exchanged with the device.  Initially,
+	// this is the connection time.
+	LastActivity() time.Time
+
+	// PingSent records that a ping is about to be sent to the device.  This is recorded before the ping
+	// is written, so that a pong which arrives quickly is never mistaken for an unsolicited pong.
+	PingSent()
+
+	// PingFailed records that the most recent ping could not be sent, so that no round trip time
+	// is computed for it
+	PingFailed()
+
+	// PongReceived records that a pong was just received from the device, which updates the RTT
+	// if a ping is outstanding
+	PongReceived()
+
+	// RTT returns the most recent round trip time between a ping and its pong.  This value is zero
+	// until a ping has been answered.
+	RTT() time.Duration
 }
 
 // NewStatistics creates a Statistics instance with the given connection time
@@ -61,6 +81,7 @@ func NewStatistics(now func() time.Time, connectedAt time.Time) Statistics {
 	connectedAt = connectedAt.UTC()
 	return &statistics{
 		now:                  now,
+		lastActivity:         connectedAt,
 		connectedAt:          connectedAt,
 		formattedConnectedAt: connectedAt.Format(time.RFC3339Nano),
 	}
@@ -75,6 +96,9 @@ type statistics struct {
 	messagesReceived int
 	messagesSent     int
 	duplications     int
+	lastActivity     time.Time
+	pingSentAt       time.Time
+	rtt              time.Duration
 
 	now                  func() time.Time
 	connectedAt          time.Time
@@ -120,6 +144,7 @@ func (s *statistics) MessagesReceived() int {
 func (s *statistics) AddMessagesReceived(delta int) {
 	s.lock.Lock()
 	s.messagesReceived += delta
+	s.lastActivity = s.now().UTC()
 	s.lock.Unlock()
 }
 
@@ -134,6 +159,7 @@ func (s *statistics) MessagesSent() int {
 func (s *statistics) AddMessagesSent(delta int) {
 	s.lock.Lock()
 	s.messagesSent += delta
+	s.lastActivity = s.now().UTC()
 	s.lock.Unlock()
 }
 
@@ -159,6 +185,46 @@ func (s *statistics) UpTime() time.Duration {
 	return s.now().Sub(s.connectedAt)
 }
 
+func (s *statistics) LastActivity() time.Time {
+	s.lock.RLock()
+	var result = s.lastActivity
+	s.lock.RUnlock()
+
+	return result
+}
+
+func (s *statistics) PingSent() {
+	s.lock.Lock()
+	s.pingSentAt = s.now()
+	s.lock.Unlock()
+}
+
+func (s *statistics) PingFailed() {
+	s.lock.Lock()
+	s.pingSentAt = time.Time{}
+	s.lock.Unlock()
+}
+
+func (s *statistics) PongReceived() {
+	s.lock.Lock()
+	now := s.now()
+	if !s.pingSentAt.IsZero() {
+		s.rtt = now.Sub(s.pingSentAt)
+		s.pingSentAt = time.Time{}
+	}
+
+	s.lastActivity = now.UTC()
+	s.lock.Unlock()
+}
+
+func (s *statistics) RTT() time.Duration {
+	s.lock.RLock()
+	var result = s.rtt
+	s.lock.RUnlock()
+
+	return result
+}
+
 func (s *statistics) String() string {
 	if data, err := s.MarshalJSON(); err == nil {
 		return string(data)
@@ -172,7 +238,7 @@ func (s *statistics) MarshalJSON() ([]byte, error) {
 	s.lock.RLock()
 	_, err := fmt.Fprintf(
 		output,
-		`{"bytesSent": %d, "messagesSent": %d, "bytesReceived": %d, "messagesReceived": %d, "duplications": %d, "connectedAt": "%s", "upTime": "%s"}`,
+		`{"bytesSent": %d, "messagesSent": %d, "bytesReceived": %d, "messagesReceived": %d, "duplications": %d, "connectedAt": "%s", "upTime": "%s", "lastActivity": "%s", "rtt": "%s"}`,
 		s.bytesSent,
 		s.messagesSent,
 		s.bytesReceived,
@@ -180,6 +246,8 @@ func (s *statistics) MarshalJSON() ([]byte, error) {
 		s.duplications,
 		s.formattedConnectedAt,
 		s.UpTime(),
+		s.lastActivity.Format(time.RFC3339Nano),
+		s.rtt,
 	)
 
 	s.lock.RUnlock()
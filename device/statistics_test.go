@@ -86,9 +86,10 @@ func testStatisticsInitialStateCustomNow(t *testing.T) {
 
 	assert.JSONEq(
 		fmt.Sprintf(
-			`{"duplications": 0, "bytesSent": 0, "messagesSent": 0, "bytesReceived": 0, "messagesReceived": 0, "connectedAt": "%s", "upTime": "%s"}`,
+			`{"duplications": 0, "bytesSent": 0, "messagesSent": 0, "bytesReceived": 0, "messagesReceived": 0, "connectedAt": "%s", "upTime": "%s", "lastActivity": "%s", "rtt": "0s"}`,
 			expectedConnectedAt.UTC().Format(time.RFC3339Nano),
 			expectedUpTime,
+			expectedConnectedAt.UTC().Format(time.RFC3339Nano),
 		),
 		string(data),
 	)
@@ -147,7 +148,7 @@ func testStatisticsConcurrency(t *testing.T) {
 
 	assert.JSONEq(
 		fmt.Sprintf(
-			`{"duplications": %d, "bytesSent": %d, "messagesSent": %d, "bytesReceived": %d, "messagesReceived": %d, "connectedAt": "%s", "upTime": "%s"}`,
+			`{"duplications": %d, "bytesSent": %d, "messagesSent": %d, "bytesReceived": %d, "messagesReceived": %d, "connectedAt": "%s", "upTime": "%s", "lastActivity": "%s", "rtt": "0s"}`,
 			expectedValue,
 			expectedValue,
 			expectedValue,
@@ -155,11 +156,61 @@ func testStatisticsConcurrency(t *testing.T) {
 			expectedValue,
 			expectedConnectedAt.UTC().Format(time.RFC3339Nano),
 			expectedUpTime,
+			expectedConnectedAt.Add(expectedUpTime).UTC().Format(time.RFC3339Nano),
 		),
 		string(data),
 	)
 }
 
+func testStatisticsActivity(t *testing.T) {
+	var (
+		assert              = assert.New(t)
+		expectedConnectedAt = time.Now().UTC()
+		current             = expectedConnectedAt
+
+		statistics = NewStatistics(
+			func() time.Time { return current },
+			expectedConnectedAt,
+		)
+	)
+
+	assert.Equal(expectedConnectedAt, statistics.LastActivity())
+	assert.Zero(statistics.RTT())
+
+	current = current.Add(time.Minute)
+	statistics.AddMessagesReceived(1)
+	assert.Equal(current, statistics.LastActivity())
+
+	current = current.Add(time.Minute)
+	statistics.AddMessagesSent(1)
+	assert.Equal(current, statistics.LastActivity())
+
+	// a pong without an outstanding ping is activity, but doesn't affect the RTT
+	current = current.Add(time.Minute)
+	statistics.PongReceived()
+	assert.Equal(current, statistics.LastActivity())
+	assert.Zero(statistics.RTT())
+
+	statistics.PingSent()
+	current = current.Add(150 * time.Millisecond)
+	assert.Zero(statistics.RTT())
+	statistics.PongReceived()
+	assert.Equal(150*time.Millisecond, statistics.RTT())
+	assert.Equal(current, statistics.LastActivity())
+
+	current = current.Add(time.Minute)
+	statistics.PongReceived()
+	assert.Equal(150*time.Millisecond, statistics.RTT())
+	assert.Contains(statistics.String(), `"rtt": "150ms"`)
+
+	// a failed ping is never answered, so a subsequent pong doesn't affect the RTT
+	statistics.PingSent()
+	statistics.PingFailed()
+	current = current.Add(time.Second)
+	statistics.PongReceived()
+	assert.Equal(150*time.Millisecond, statistics.RTT())
+}
+
 func TestStatistics(t *testing.T) {
 	t.Run("InitialState", func(t *testing.T) {
 		t.Run("DefaultNow", testStatisticsInitialStateDefaultNow)
@@ -167,4 +218,5 @@ func TestStatistics(t *testing.T) {
 	})
 
 	t.Run("Concurrency", testStatisticsConcurrency)
+	t.Run("Activity", testStatisticsActivity)
 }
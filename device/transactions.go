@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/Comcast/webpa-common/wrp"
 	"github.com/Comcast/webpa-common/xhttp"
@@ -25,6 +26,11 @@ type Request struct {
 	// then Routing will be encoded prior to sending to devices.
 	Contents []byte
 
+	// TTL is the maximum length of time this request may wait in a device's queue before it is written.
+	// A request that waits longer is dropped with ErrorMessageExpired.  If nonpositive, the Manager's
+	// MessageTTL is used.
+	TTL time.Duration
+
 	// ctx is the API context for this request, which can be nil.  Normally, it's best to
 	// set this to context.Background() if no cancellation semantics are desired.
 	ctx context.Context
@@ -111,6 +117,9 @@ type Response struct {
 
 	// Contents is the encoded form of Message, formatted in Format
 	Contents []byte
+
+	// err is the reason a transaction failed without a response from the device.  See Transactions.Fail.
+	err error
 }
 
 // EncodeResponse writes out a device transaction Response to an http Response.
@@ -213,6 +222,17 @@ func (t *Transactions) Complete(transactionKey string, response *Response) error
 	return nil
 }
 
+// Fail completes a transaction with an error rather than a response from the device, e.g. because the device's
+// response was dropped.  The goroutine waiting on the transaction in Send receives the given error.  This method
+// returns the same errors as Complete.
+func (t *Transactions) Fail(transactionKey string, err error) error {
+	if err == nil {
+		panic("nil error")
+	}
+
+	return t.Complete(transactionKey, &Response{err: err})
+}
+
 // Cancel simply cancels a transaction.  The transaction key is removed from the pending set.  If that
 // transaction key is not registered, this method does nothing.  The channel returned from Register
 // is closed, which will cause any code waiting for a response to get a nil Response.
@@ -287,6 +287,33 @@ func testTransactionsCancellation(t *testing.T) {
 	<-finished
 }
 
+func testTransactionsFail(t *testing.T) {
+	const transactionKey = "transaction-id"
+
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		transactions  = NewTransactions()
+		expectedError = errors.New("expected")
+	)
+
+	assert.Panics(func() {
+		transactions.Fail(transactionKey, nil)
+	})
+
+	assert.Equal(ErrorNoSuchTransactionKey, transactions.Fail(transactionKey, expectedError))
+
+	output, err := transactions.Register(transactionKey)
+	require.NoError(err)
+	require.NotNil(output)
+
+	assert.NoError(transactions.Fail(transactionKey, expectedError))
+	response := <-output
+	require.NotNil(response)
+	assert.Equal(expectedError, response.err)
+	assert.Zero(transactions.Len())
+}
+
 func TestTransactions(t *testing.T) {
 	t.Run("InitialState", testTransactionsInitialState)
 
@@ -296,6 +323,8 @@ func TestTransactions(t *testing.T) {
 		t.Run("NilResponse", testTransactionsCompleteNilResponse)
 	})
 
+	t.Run("Fail", testTransactionsFail)
+
 	t.Run("Register", func(t *testing.T) {
 		t.Run("EmptyTransactionKey", testTransactionsRegisterEmptyTransactionKey)
 		t.Run("DuplicateTransactionKey", testTransactionsRegisterDuplicateTransactionKey)
@@ -0,0 +1,22 @@
+package device
+
+import (
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+)
+
+// expired tests if this envelope's request can no longer be written at the given time
+func (e *envelope) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// recordExpired logs and counts an outbound message that waited in a device's queue beyond its TTL
+func (m *manager) recordExpired(d *device, e *envelope) {
+	m.measures.Expired.Add(1.0)
+	d.errorLog.Log(
+		logging.MessageKey(), "outbound message expired",
+		"expires", e.expires,
+		"pending", len(d.messages),
+	)
+}
@@ -0,0 +1,123 @@
+package device
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelopeExpired(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		now    = time.Now()
+	)
+
+	assert.False((&envelope{}).expired(now))
+	assert.False((&envelope{expires: now.Add(time.Second)}).expired(now))
+	assert.True((&envelope{expires: now}).expired(now))
+	assert.True((&envelope{expires: now.Add(-time.Second)}).expired(now))
+}
+
+func newTTLRequest(payload string, ttl time.Duration) *Request {
+	return &Request{
+		Message: &wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      "test",
+			Destination: string(testDeviceIDs[0]) + "/service",
+			Payload:     []byte(payload),
+		},
+		Format: wrp.Msgpack,
+		TTL:    ttl,
+	}
+}
+
+// testMessageTTL holds the write pump on a first message while a second message waits in the queue,
+// then advances the clock past the manager's MessageTTL before releasing the write pump
+func testMessageTTL(t *testing.T, requestTTL time.Duration, expectedError error) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+
+		offset  int64
+		blocked = make(chan struct{})
+		release = make(chan struct{})
+
+		// the pumps log as they exit, which can happen after this test completes
+		manager, _, events, stop = startOversizeTest(t, &Options{
+			Logger:          logging.DefaultLogger(),
+			MessageTTL:      time.Minute,
+			MetricsProvider: provider,
+			Now: func() time.Time {
+				return time.Now().Add(time.Duration(atomic.LoadInt64(&offset)))
+			},
+			OutboundInterceptors: []Interceptor{
+				func(_ Interface, message *wrp.Message) error {
+					if string(message.Payload) == "first" {
+						close(blocked)
+						<-release
+					}
+
+					return nil
+				},
+			},
+		})
+	)
+
+	defer stop()
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := manager.Route(newTTLRequest("first", 0))
+		first <- err
+	}()
+
+	select {
+	case <-blocked:
+	case <-time.After(5 * time.Second):
+		require.Fail("The write pump did not receive the first message")
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := manager.Route(newTTLRequest("second", requestTTL))
+		second <- err
+	}()
+
+	d, ok := manager.Get(testDeviceIDs[0])
+	require.True(ok)
+	require.NotNil(d)
+	for i := 0; d.Pending() == 0; i++ {
+		require.True(i < 500, "The second message was not enqueued")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	atomic.StoreInt64(&offset, int64(2*time.Minute))
+	close(release)
+
+	assert.NoError(<-first)
+	waitForEvent(t, events, MessageSent)
+
+	assert.Equal(expectedError, <-second)
+	if expectedError != nil {
+		failed := waitForEvent(t, events, MessageFailed)
+		require.NotNil(failed)
+		assert.Equal(expectedError, failed.Error)
+
+		provider.Assert(t, MessageExpiredCounter)(xmetricstest.Value(1.0))
+	} else {
+		waitForEvent(t, events, MessageSent)
+		provider.Assert(t, MessageExpiredCounter)(xmetricstest.Value(0.0))
+	}
+}
+
+func TestMessageTTL(t *testing.T) {
+	t.Run("Expired", func(t *testing.T) { testMessageTTL(t, 0, ErrorMessageExpired) })
+	t.Run("RequestTTL", func(t *testing.T) { testMessageTTL(t, time.Hour, nil) })
+}
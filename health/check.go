@@ -0,0 +1,229 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// State is the health of a single dependency, or of a server as a whole.  States are ordered
+// from best to worst, so that the overall state of a server is the worst state of its checks.
+type State int
+
+const (
+	Healthy State = iota
+
+	// Pending indicates that a check has not yet produced a result.  It is distinct from Degraded so that a
+	// server which is starting up is not mistaken for one with a failing dependency.
+	Pending
+
+	Degraded
+	Unhealthy
+)
+
+var stateNames = map[State]string{
+	Healthy:   "healthy",
+	Pending:   "pending",
+	Degraded:  "degraded",
+	Unhealthy: "unhealthy",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// MarshalText allows States to be used both as JSON values and as JSON map keys
+func (s State) MarshalText() ([]byte, error) {
+	if name, ok := stateNames[s]; ok {
+		return []byte(name), nil
+	}
+
+	return nil, fmt.Errorf("Invalid health state: %d", int(s))
+}
+
+// UnmarshalText parses the text produced by MarshalText
+func (s *State) UnmarshalText(text []byte) error {
+	for state, name := range stateNames {
+		if name == string(text) {
+			*s = state
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Invalid health state: %s", text)
+}
+
+// Status is the outcome of running a Check
+type Status struct {
+	// State is the health of the dependency
+	State State `json:"state"`
+
+	// Message is optional, human-readable detail about the dependency's health
+	Message string `json:"message,omitempty"`
+}
+
+// Check is a health check for a single dependency of a server, such as a database, service discovery,
+// or a key server.  A Check that is critical makes the whole server unhealthy when it fails, while a
+// noncritical Check can only degrade the server.
+type Check interface {
+	// Name uniquely identifies this check within a Health
+	Name() string
+
+	// Critical indicates whether the server can function at all when this check fails
+	Critical() bool
+
+	// Run executes this check.  Implementations should honor cancellation of the context.
+	Run(context.Context) Status
+}
+
+// check is the internal Check implementation used by NewCheck
+type check struct {
+	name     string
+	critical bool
+	run      func(context.Context) Status
+}
+
+func (c *check) Name() string {
+	return c.name
+}
+
+func (c *check) Critical() bool {
+	return c.critical
+}
+
+func (c *check) Run(ctx context.Context) Status {
+	return c.run(ctx)
+}
+
+// NewCheck creates a Check from a function
+func NewCheck(name string, critical bool, run func(context.Context) Status) Check {
+	return &check{name, critical, run}
+}
+
+// CheckerCheck adapts a readiness Checker into a Check.  A nil error from the Checker is healthy,
+// while an error is unhealthy with the error text as the message.
+func CheckerCheck(name string, critical bool, c Checker) Check {
+	return NewCheck(name, critical, func(context.Context) Status {
+		if err := c.Check(); err != nil {
+			return Status{State: Unhealthy, Message: err.Error()}
+		}
+
+		return Status{State: Healthy}
+	})
+}
+
+// CheckResult is the status of a single Check as reported by a Health
+type CheckResult struct {
+	Status
+
+	// Critical is copied from the Check
+	Critical bool `json:"critical"`
+
+	// Timestamp is when the Check was run.  For scheduled checks, this is when the cached result was produced.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Stale indicates that a scheduled check has missed its most recent runs, so its result may be out of date
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Report is the composite health of a server
+type Report struct {
+	// State is the overall state.  This is the worst state of any critical check.  Noncritical checks
+	// that are unhealthy result in, at worst, a degraded state.
+	State State `json:"status"`
+
+	// Checks holds the result of each Check, keyed by name
+	Checks map[string]CheckResult `json:"checks"`
+
+	// Stats is a copy of the Health's statistics
+	Stats Stats `json:"stats"`
+}
+
+// Aggregate computes the overall state of a set of check results
+func Aggregate(results map[string]CheckResult) State {
+	overall := Healthy
+	for _, result := range results {
+		state := result.State
+		if !result.Critical && state > Degraded {
+			state = Degraded
+		}
+
+		if state > overall {
+			overall = state
+		}
+	}
+
+	return overall
+}
+
+// AddCheck registers a Check with this Health.  If a check with the same name has already been added,
+// it is replaced.
+func (h *Health) AddCheck(c Check) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for i, existing := range h.checks {
+		if existing.Name() == c.Name() {
+			h.checks[i] = c
+			return
+		}
+	}
+
+	h.checks = append(h.checks, c)
+}
+
+// Checks returns a copy of the Checks registered with this Health
+func (h *Health) Checks() []Check {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]Check{}, h.checks...)
+}
+
+// runChecks produces a result for each check.  Scheduled checks report their cached results, while
+// all other checks are run in turn.  Each check that is run inline is bounded by DefaultCheckTimeout,
+// so that a hung dependency cannot block the health or readiness endpoints indefinitely.
+func (h *Health) runChecks(ctx context.Context, checks []Check) map[string]CheckResult {
+	results := make(map[string]CheckResult, len(checks))
+	for _, c := range checks {
+		if cached, ok := h.cachedResult(c, time.Now()); ok {
+			results[c.Name()] = cached
+			continue
+		}
+
+		results[c.Name()] = CheckResult{
+			Status:    runCheck(ctx, c, h.checkTimeout),
+			Critical:  c.Critical(),
+			Timestamp: time.Now(),
+		}
+	}
+
+	return results
+}
+
+// Report runs every registered Check and produces the composite health of this server.  Any
+// TransitionListeners are notified if the overall state has changed.
+func (h *Health) Report(ctx context.Context) Report {
+	var (
+		results = h.runChecks(ctx, h.Checks())
+		report  = Report{
+			State:  Aggregate(results),
+			Checks: results,
+		}
+	)
+
+	h.lock.Lock()
+	h.results = results
+	h.lock.Unlock()
+
+	h.SendEvent(func(stats Stats) {
+		stats.UpdateMemory(h.memInfoReader)
+		report.Stats = stats.Clone()
+	})
+
+	h.dispatchTransition(report)
+	return report
+}
@@ -0,0 +1,169 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestState(t *testing.T) {
+	assert := assert.New(t)
+
+	for state, name := range map[State]string{Healthy: "healthy", Pending: "pending", Degraded: "degraded", Unhealthy: "unhealthy"} {
+		assert.Equal(name, state.String())
+
+		text, err := state.MarshalText()
+		assert.Equal(name, string(text))
+		assert.NoError(err)
+
+		var unmarshaled State
+		assert.NoError(unmarshaled.UnmarshalText(text))
+		assert.Equal(state, unmarshaled)
+	}
+
+	assert.Equal("State(-1)", State(-1).String())
+	_, err := State(-1).MarshalText()
+	assert.Error(err)
+
+	var unmarshaled State
+	assert.Error(unmarshaled.UnmarshalText([]byte("nosuch")))
+}
+
+func TestNewCheck(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		ctx    = context.WithValue(context.Background(), "foo", "bar")
+		c      = NewCheck("database", true, func(actual context.Context) Status {
+			assert.Equal(ctx, actual)
+			return Status{State: Degraded, Message: "slow"}
+		})
+	)
+
+	assert.Equal("database", c.Name())
+	assert.True(c.Critical())
+	assert.Equal(Status{State: Degraded, Message: "slow"}, c.Run(ctx))
+}
+
+func TestCheckerCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	healthy := CheckerCheck("healthy", false, CheckerFunc(func() error { return nil }))
+	assert.Equal("healthy", healthy.Name())
+	assert.False(healthy.Critical())
+	assert.Equal(Status{State: Healthy}, healthy.Run(context.Background()))
+
+	unhealthy := CheckerCheck("unhealthy", true, CheckerFunc(func() error { return errors.New("expected") }))
+	assert.True(unhealthy.Critical())
+	assert.Equal(Status{State: Unhealthy, Message: "expected"}, unhealthy.Run(context.Background()))
+}
+
+func TestAggregate(t *testing.T) {
+	testData := []struct {
+		results  map[string]CheckResult
+		expected State
+	}{
+		{nil, Healthy},
+		{map[string]CheckResult{"a": {Status: Status{State: Healthy}, Critical: true}}, Healthy},
+		{map[string]CheckResult{"a": {Status: Status{State: Degraded}, Critical: true}}, Degraded},
+		{map[string]CheckResult{"a": {Status: Status{State: Unhealthy}, Critical: true}}, Unhealthy},
+		{map[string]CheckResult{"a": {Status: Status{State: Unhealthy}, Critical: false}}, Degraded},
+		{
+			map[string]CheckResult{
+				"a": {Status: Status{State: Healthy}, Critical: true},
+				"b": {Status: Status{State: Unhealthy}, Critical: false},
+				"c": {Status: Status{State: Unhealthy}, Critical: true},
+			},
+			Unhealthy,
+		},
+	}
+
+	for i, record := range testData {
+		t.Logf("#%d: %v", i, record)
+		assert.Equal(t, record.expected, Aggregate(record.results))
+	}
+}
+
+func TestAddCheck(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = setupHealth(t)
+
+		first       = NewCheck("first", true, func(context.Context) Status { return Status{State: Healthy} })
+		second      = NewCheck("second", false, func(context.Context) Status { return Status{State: Healthy} })
+		replacement = NewCheck("first", false, func(context.Context) Status { return Status{State: Degraded} })
+	)
+
+	assert.Empty(h.Checks())
+
+	h.AddCheck(first)
+	h.AddCheck(second)
+	assert.Equal([]Check{first, second}, h.Checks())
+
+	h.AddCheck(replacement)
+	assert.Equal([]Check{replacement, second}, h.Checks())
+}
+
+func TestReport(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = setupHealth(t)
+	)
+
+	h.AddCheck(NewCheck("database", true, func(context.Context) Status { return Status{State: Healthy} }))
+	h.AddCheck(NewCheck("cache", false, func(context.Context) Status { return Status{State: Unhealthy, Message: "unreachable"} }))
+
+	report := h.Report(context.Background())
+	assert.Equal(Degraded, report.State)
+	assert.Len(report.Checks, 2)
+	assert.Equal(Status{State: Healthy}, report.Checks["database"].Status)
+	assert.True(report.Checks["database"].Critical)
+	assert.Equal(Status{State: Unhealthy, Message: "unreachable"}, report.Checks["cache"].Status)
+	assert.False(report.Checks["cache"].Critical)
+	assert.False(report.Checks["cache"].Timestamp.IsZero())
+	assert.NotEmpty(report.Stats)
+}
+
+func TestServeHTTPWithChecks(t *testing.T) {
+	testData := []struct {
+		critical      bool
+		expectedCode  int
+		expectedState State
+	}{
+		{false, http.StatusOK, Degraded},
+		{true, http.StatusServiceUnavailable, Unhealthy},
+	}
+
+	for _, record := range testData {
+		t.Logf("%#v", record)
+
+		var (
+			assert   = assert.New(t)
+			require  = require.New(t)
+			h        = setupHealth(t)
+			response = httptest.NewRecorder()
+			request  = httptest.NewRequest("GET", "/health", nil)
+		)
+
+		h.AddCheck(NewCheck("dependency", record.critical, func(context.Context) Status {
+			return Status{State: Unhealthy, Message: "down"}
+		}))
+
+		h.ServeHTTP(response, request)
+		assert.Equal(record.expectedCode, response.Code)
+		assert.Equal("application/json", response.HeaderMap.Get("Content-Type"))
+
+		var result Report
+		require.NoError(json.Unmarshal(response.Body.Bytes(), &result))
+		assert.Equal(record.expectedState, result.State)
+		require.Contains(result.Checks, "dependency")
+		assert.Equal(Unhealthy, result.Checks["dependency"].State)
+		assert.Equal("down", result.Checks["dependency"].Message)
+		assert.Equal(record.critical, result.Checks["dependency"].Critical)
+	}
+}
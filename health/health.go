@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Comcast/webpa-common/concurrent"
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/go-kit/kit/log"
 )
@@ -46,11 +47,23 @@ type Health struct {
 	lock             sync.Mutex
 	stats            Stats
 	statDumpInterval time.Duration
+	logger           log.Logger
 	errorLog         log.Logger
 	debugLog         log.Logger
 	statsListeners   []StatsListener
 	memInfoReader    *MemInfoReader
-	once             sync.Once
+	checks           []Check
+	checkTimeout     time.Duration
+	schedules        map[string]*scheduledCheck
+	cache            map[string]CheckResult
+	results          map[string]CheckResult
+	shutdown         <-chan struct{}
+	waitGroup        *sync.WaitGroup
+
+	state               State
+	transitionListeners []TransitionListener
+	transitionLock      sync.Mutex
+	once                sync.Once
 }
 
 var _ Monitor = (*Health)(nil)
@@ -107,9 +120,11 @@ func New(interval time.Duration, logger log.Logger, options ...Option) *Health {
 	return &Health{
 		stats:            initialStats,
 		statDumpInterval: interval,
+		logger:           logger,
 		errorLog:         logging.Error(logger),
 		debugLog:         logging.Debug(logger),
 		memInfoReader:    &MemInfoReader{},
+		checkTimeout:     DefaultCheckTimeout,
 	}
 }
 
@@ -118,52 +133,49 @@ func New(interval time.Duration, logger log.Logger, options ...Option) *Health {
 func (h *Health) Run(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
 	h.once.Do(func() {
 		h.debugLog.Log(logging.MessageKey(), "Health Monitor Started")
-
-		waitGroup.Add(1)
-		go func() {
-			defer waitGroup.Done()
-			ticker := time.NewTicker(h.statDumpInterval)
-			defer ticker.Stop()
-			defer h.debugLog.Log(logging.MessageKey(), "Health Monitor Stopped")
-
-			for {
-				select {
-				case <-shutdown:
-					return
-
-				case <-ticker.C:
-					h.lock.Lock()
-					h.stats.UpdateMemory(h.memInfoReader)
-					dispatchStats := h.stats.Clone()
-					h.lock.Unlock()
-					for _, statsListener := range h.statsListeners {
-						statsListener.OnStats(dispatchStats)
-					}
-				}
-			}
-		}()
+		h.startSchedules(waitGroup, shutdown)
+
+		concurrent.NewScheduler(
+			concurrent.ScheduleOptions{
+				Name:   "health.stats",
+				Logger: h.logger,
+				Period: h.statDumpInterval,
+			},
+			h.dispatchStats,
+		).Run(waitGroup, shutdown)
 	})
 
 	return nil
 }
 
+// dispatchStats sends a snapshot of the current stats to each StatsListener
+func (h *Health) dispatchStats() {
+	h.lock.Lock()
+	h.stats.UpdateMemory(h.memInfoReader)
+	dispatchStats := h.stats.Clone()
+	h.lock.Unlock()
+	for _, statsListener := range h.statsListeners {
+		statsListener.OnStats(dispatchStats)
+	}
+}
+
+// ServeHTTP writes the composite health Report of this server.  The response status is 503 if
+// the overall state is Unhealthy, and 200 otherwise.
 func (h *Health) ServeHTTP(response http.ResponseWriter, request *http.Request) {
-	var (
-		data []byte
-		err  error
-	)
-
-	h.SendEvent(func(stats Stats) {
-		stats.UpdateMemory(h.memInfoReader)
-		data, err = json.Marshal(stats)
-	})
+	report := h.Report(request.Context())
+	data, err := json.Marshal(report)
 
 	response.Header().Set("Content-Type", "application/json")
 	if err != nil {
 		h.errorLog.Log(logging.MessageKey(), "Could not marshal stats", logging.ErrorKey(), err)
 		response.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintf(response, `{"message": "%s"}\n`, err.Error())
-	} else {
-		fmt.Fprintf(response, "%s", data)
+		return
 	}
+
+	if report.State == Unhealthy {
+		response.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	fmt.Fprintf(response, "%s", data)
 }
@@ -122,12 +122,14 @@ func TestServeHTTP(t *testing.T) {
 
 	assert.Equal(200, response.Code)
 
-	var result Stats
+	var result Report
 	assert.NoError(json.Unmarshal(response.Body.Bytes(), &result))
+	assert.Equal(Healthy, result.State)
+	assert.Empty(result.Checks)
 
 	// each key in commonStats should be present in the output
 	for _, stat := range memoryStats {
-		_, ok := result[stat.(Stat)]
+		_, ok := result.Stats[stat.(Stat)]
 		assert.True(ok)
 	}
 }
@@ -0,0 +1,187 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultHTTPCheckMethod is the HTTP method used to probe a dependency when none is supplied
+	DefaultHTTPCheckMethod = "GET"
+
+	// maxHTTPCheckDrain is the most response body an HTTP check will read in order to reuse its connection
+	maxHTTPCheckDrain = 4096
+)
+
+var (
+	ErrHTTPCheckNoURL       = errors.New("An HTTP check requires a URL")
+	ErrHTTPCheckRelativeURL = errors.New("An HTTP check requires an absolute URL")
+)
+
+// HTTPCheckOptions configures a Check that probes a dependency, such as another service's health endpoint,
+// over HTTP.  A probe that fails or returns an unexpected status code is Unhealthy.  A probe that succeeds,
+// but takes at least DegradedLatency, is Degraded.  This allows a slow dependency to be distinguished from
+// one that is down.
+type HTTPCheckOptions struct {
+	// Name is the name of the check.  If unset, the URL is used.
+	Name string `json:"name"`
+
+	// URL is the absolute URL of the dependency to probe.  This field is required.
+	URL string `json:"url"`
+
+	// Method is the HTTP method of each probe.  If unset, DefaultHTTPCheckMethod is used.
+	Method string `json:"method"`
+
+	// Header contains any headers sent with each probe, e.g. an authorization header
+	Header http.Header `json:"header"`
+
+	// ExpectedStatus are the status codes which indicate a healthy dependency.  If unset, any 2xx status code is healthy.
+	ExpectedStatus []int `json:"expectedStatus"`
+
+	// DegradedLatency is the latency at which the dependency is considered Degraded.  If unset, latency is not checked.
+	DegradedLatency time.Duration `json:"degradedLatency"`
+
+	// Critical indicates whether a failing dependency makes the whole server Unhealthy
+	Critical bool `json:"critical"`
+
+	// Interval is how often the dependency is probed.  If unset, DefaultCheckInterval is used.
+	Interval time.Duration `json:"interval"`
+
+	// Timeout bounds each probe.  A probe which times out is Unhealthy.  If unset, DefaultCheckTimeout is used.
+	Timeout time.Duration `json:"timeout"`
+
+	// Transactor is the strategy used to execute each probe.  If unset, http.DefaultClient.Do is used.
+	Transactor func(*http.Request) (*http.Response, error) `json:"-"`
+
+	// Now is the source of time used to measure latency.  If unset, time.Now is used.
+	Now func() time.Time `json:"-"`
+}
+
+func (o *HTTPCheckOptions) name() string {
+	if len(o.Name) > 0 {
+		return o.Name
+	}
+
+	return o.URL
+}
+
+func (o *HTTPCheckOptions) method() string {
+	if len(o.Method) > 0 {
+		return o.Method
+	}
+
+	return DefaultHTTPCheckMethod
+}
+
+func (o *HTTPCheckOptions) expected(statusCode int) bool {
+	if len(o.ExpectedStatus) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+
+	for _, c := range o.ExpectedStatus {
+		if c == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *HTTPCheckOptions) transactor() func(*http.Request) (*http.Response, error) {
+	if o.Transactor != nil {
+		return o.Transactor
+	}
+
+	return http.DefaultClient.Do
+}
+
+func (o *HTTPCheckOptions) now() func() time.Time {
+	if o.Now != nil {
+		return o.Now
+	}
+
+	return time.Now
+}
+
+// newRequest creates a single probe request
+func (o *HTTPCheckOptions) newRequest(ctx context.Context) (*http.Request, error) {
+	request, err := http.NewRequest(o.method(), o.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range o.Header {
+		for _, v := range values {
+			request.Header.Add(name, v)
+		}
+	}
+
+	return request.WithContext(ctx), nil
+}
+
+// Check produces the Check described by these options.  An error is returned if the URL is missing or
+// is not absolute, or if a probe request cannot be created.
+func (o *HTTPCheckOptions) Check() (Check, error) {
+	if len(o.URL) == 0 {
+		return nil, ErrHTTPCheckNoURL
+	}
+
+	if u, err := url.Parse(o.URL); err != nil {
+		return nil, err
+	} else if !u.IsAbs() {
+		return nil, ErrHTTPCheckRelativeURL
+	}
+
+	if _, err := o.newRequest(context.Background()); err != nil {
+		return nil, err
+	}
+
+	var (
+		options    = *o
+		transactor = o.transactor()
+		now        = o.now()
+	)
+
+	return NewCheck(o.name(), o.Critical, func(ctx context.Context) Status {
+		request, err := options.newRequest(ctx)
+		if err != nil {
+			return Status{State: Unhealthy, Message: err.Error()}
+		}
+
+		start := now()
+		response, err := transactor(request)
+		latency := now().Sub(start)
+		if err != nil {
+			return Status{State: Unhealthy, Message: err.Error()}
+		}
+
+		io.CopyN(ioutil.Discard, response.Body, maxHTTPCheckDrain)
+		response.Body.Close()
+
+		switch {
+		case !options.expected(response.StatusCode):
+			return Status{State: Unhealthy, Message: fmt.Sprintf("unexpected status code %d", response.StatusCode)}
+		case options.DegradedLatency > 0 && latency >= options.DegradedLatency:
+			return Status{State: Degraded, Message: fmt.Sprintf("latency %s exceeds %s", latency, options.DegradedLatency)}
+		default:
+			return Status{State: Healthy, Message: fmt.Sprintf("status code %d in %s", response.StatusCode, latency)}
+		}
+	}), nil
+}
+
+// AddHTTPCheck schedules an HTTP dependency check with this Health
+func (h *Health) AddHTTPCheck(o *HTTPCheckOptions) error {
+	c, err := o.Check()
+	if err != nil {
+		return err
+	}
+
+	h.AddScheduledCheck(c, o.Interval, o.Timeout)
+	return nil
+}
@@ -0,0 +1,164 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHTTPCheckInvalid(t *testing.T) {
+	assert := assert.New(t)
+	for _, o := range []HTTPCheckOptions{
+		{},
+		{URL: "/relative"},
+		{URL: "http://[::1"},
+		{URL: "http://localhost", Method: "bad method"},
+	} {
+		c, err := o.Check()
+		assert.Nil(c)
+		assert.Error(err)
+	}
+}
+
+func testHTTPCheckServer(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Equal("HEAD", request.Method)
+			assert.Equal([]string{"1", "2"}, request.Header["X-Test"])
+			if request.Header.Get("Authorization") != "secret" {
+				response.WriteHeader(http.StatusForbidden)
+			}
+		}))
+	)
+
+	defer server.Close()
+
+	o := HTTPCheckOptions{
+		URL:    server.URL,
+		Method: "HEAD",
+		Header: http.Header{"x-test": {"1", "2"}, "Authorization": {"secret"}},
+	}
+
+	c, err := o.Check()
+	require.NoError(err)
+	require.NotNil(c)
+	assert.Equal(server.URL, c.Name())
+	assert.False(c.Critical())
+	assert.Equal(Healthy, c.Run(context.Background()).State)
+
+	o.Name = "dependency"
+	o.Critical = true
+	o.Header.Del("Authorization")
+	c, err = o.Check()
+	require.NoError(err)
+	assert.Equal("dependency", c.Name())
+	assert.True(c.Critical())
+
+	status := c.Run(context.Background())
+	assert.Equal(Unhealthy, status.State)
+	assert.Equal("unexpected status code 403", status.Message)
+
+	o.ExpectedStatus = []int{http.StatusForbidden}
+	c, err = o.Check()
+	require.NoError(err)
+	assert.Equal(Healthy, c.Run(context.Background()).State)
+}
+
+func testHTTPCheckLatency(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now     = time.Now()
+		latency time.Duration
+		probes  int
+
+		o = HTTPCheckOptions{
+			URL:             "http://dependency.example.com/health",
+			DegradedLatency: time.Second,
+			Now:             func() time.Time { return now },
+			Transactor: func(request *http.Request) (*http.Response, error) {
+				assert.Equal(DefaultHTTPCheckMethod, request.Method)
+				probes++
+				now = now.Add(latency)
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader("OK")),
+				}, nil
+			},
+		}
+	)
+
+	c, err := o.Check()
+	require.NoError(err)
+
+	latency = 999 * time.Millisecond
+	assert.Equal(Healthy, c.Run(context.Background()).State)
+
+	latency = time.Second
+	status := c.Run(context.Background())
+	assert.Equal(Degraded, status.State)
+	assert.Equal("latency 1s exceeds 1s", status.Message)
+
+	latency = time.Minute
+	assert.Equal(Degraded, c.Run(context.Background()).State)
+	assert.Equal(3, probes)
+}
+
+func testHTTPCheckTransactorError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		o = HTTPCheckOptions{
+			URL: "http://dependency.example.com/health",
+			Transactor: func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("expected")
+			},
+		}
+	)
+
+	c, err := o.Check()
+	require.NoError(err)
+
+	status := c.Run(context.Background())
+	assert.Equal(Unhealthy, status.State)
+	assert.Equal("expected", status.Message)
+}
+
+func TestHTTPCheck(t *testing.T) {
+	t.Run("Invalid", testHTTPCheckInvalid)
+	t.Run("Server", testHTTPCheckServer)
+	t.Run("Latency", testHTTPCheckLatency)
+	t.Run("TransactorError", testHTTPCheckTransactorError)
+}
+
+func TestAddHTTPCheck(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		h       = New(time.Minute, logging.NewTestLogger(nil, t))
+	)
+
+	assert.Error(h.AddHTTPCheck(&HTTPCheckOptions{}))
+	assert.Empty(h.Checks())
+
+	require.NoError(h.AddHTTPCheck(&HTTPCheckOptions{Name: "dependency", URL: "http://localhost:8080/health", Interval: time.Minute}))
+	checks := h.Checks()
+	require.Len(checks, 1)
+	assert.Equal("dependency", checks[0].Name())
+	assert.Equal(time.Minute, h.schedules["dependency"].interval)
+	assert.Equal(DefaultCheckTimeout, h.schedules["dependency"].timeout)
+}
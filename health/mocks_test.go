@@ -75,3 +75,27 @@ func (m *mockResponseWriterFull) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 func (m *mockResponseWriterFull) Push(target string, opts *http.PushOptions) error {
 	return m.Called(target, opts).Error(0)
 }
+
+type mockGate struct {
+	mock.Mock
+}
+
+func (m *mockGate) Raise() bool {
+	return m.Called().Bool(0)
+}
+
+func (m *mockGate) Lower() bool {
+	return m.Called().Bool(0)
+}
+
+type mockRegistrar struct {
+	mock.Mock
+}
+
+func (m *mockRegistrar) Register() {
+	m.Called()
+}
+
+func (m *mockRegistrar) Deregister() {
+	m.Called()
+}
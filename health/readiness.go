@@ -0,0 +1,96 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Comcast/webpa-common/logging"
+)
+
+var (
+	// ErrorNotOpen is returned by an OpenCheck when the underlying Opener is closed
+	ErrorNotOpen = errors.New("Not open")
+)
+
+// Checker is a readiness check for some dependency of a server, e.g. service discovery
+// or a key resolver.  A nil error indicates the dependency is ready.
+type Checker interface {
+	Check() error
+}
+
+// CheckerFunc is a function type that implements Checker
+type CheckerFunc func() error
+
+func (cf CheckerFunc) Check() error {
+	return cf()
+}
+
+// Opener is implemented by anything that can report an open state.  In particular,
+// xhttp/gate.Interface implements this interface.
+type Opener interface {
+	IsOpen() bool
+}
+
+// OpenCheck produces a Checker that passes only when the given Opener is open
+func OpenCheck(o Opener) Checker {
+	return CheckerFunc(func() error {
+		if o.IsOpen() {
+			return nil
+		}
+
+		return ErrorNotOpen
+	})
+}
+
+// AddReadinessCheck registers a named Checker that must pass for this Health to report readiness.  This is
+// shorthand for adding a critical Check via CheckerCheck, so readiness checks are also part of the health Report.
+// As with any check that is not scheduled, the Checker runs each time a report is produced and is reported as
+// Unhealthy if it does not complete within DefaultCheckTimeout.
+func (h *Health) AddReadinessCheck(name string, check Checker) {
+	h.AddCheck(CheckerCheck(name, true, check))
+}
+
+// ServeLive is an http.HandlerFunc appropriate for a liveness probe, e.g. a Kubernetes livenessProbe.
+// Liveness only indicates that this process is able to serve HTTP, so this handler always returns http.StatusOK.
+func (h *Health) ServeLive(response http.ResponseWriter, _ *http.Request) {
+	response.Header().Set("Content-Type", "application/json")
+	response.Write([]byte(`{"status": "live"}`))
+}
+
+// ready tests if a report indicates readiness.  A server is not ready while any critical check is Unhealthy or
+// still Pending, e.g. a scheduled check that has not yet completed its first run.
+func ready(report Report) bool {
+	if report.State == Unhealthy {
+		return false
+	}
+
+	for _, result := range report.Checks {
+		if result.Critical && (result.State == Pending || result.State == Unhealthy) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ServeReady is an http.HandlerFunc appropriate for a readiness probe, e.g. a Kubernetes readinessProbe.
+// Every registered Check is evaluated, as with Report, and the result of each is written as a JSON object.
+// If the overall state is Unhealthy, or any critical check is Unhealthy or has not yet produced a result,
+// the response code is http.StatusServiceUnavailable.
+func (h *Health) ServeReady(response http.ResponseWriter, request *http.Request) {
+	report := h.Report(request.Context())
+	data, err := json.Marshal(report.Checks)
+	if err != nil {
+		h.errorLog.Log(logging.MessageKey(), "Could not marshal readiness", logging.ErrorKey(), err)
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	if !ready(report) {
+		response.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	response.Write(data)
+}
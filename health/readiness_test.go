@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testOpener bool
+
+func (o testOpener) IsOpen() bool {
+	return bool(o)
+}
+
+func TestOpenCheck(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(OpenCheck(testOpener(true)).Check())
+	assert.Equal(ErrorNotOpen, OpenCheck(testOpener(false)).Check())
+}
+
+func TestServeLive(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		h        = setupHealth(t)
+		response = httptest.NewRecorder()
+	)
+
+	h.ServeLive(response, httptest.NewRequest("GET", "/health/live", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.HeaderMap.Get("Content-Type"))
+}
+
+func testServeReadyNoChecks(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		h        = setupHealth(t)
+		response = httptest.NewRecorder()
+	)
+
+	h.ServeReady(response, httptest.NewRequest("GET", "/health/ready", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.JSONEq(`{}`, response.Body.String())
+}
+
+func testServeReadyChecks(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		h       = setupHealth(t)
+
+		checkError error
+	)
+
+	h.AddReadinessCheck("gate", OpenCheck(testOpener(true)))
+	h.AddReadinessCheck("custom", CheckerFunc(func() error { return checkError }))
+	h.AddCheck(NewCheck("optional", false, func(context.Context) Status { return Status{State: Unhealthy, Message: "down"} }))
+
+	response := httptest.NewRecorder()
+	h.ServeReady(response, httptest.NewRequest("GET", "/health/ready", nil))
+	assert.Equal(http.StatusOK, response.Code)
+
+	var results map[string]CheckResult
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &results))
+	require.Len(results, 3)
+	assert.Equal(Status{State: Healthy}, results["gate"].Status)
+	assert.True(results["gate"].Critical)
+	assert.Equal(Status{State: Healthy}, results["custom"].Status)
+	assert.Equal(Status{State: Unhealthy, Message: "down"}, results["optional"].Status)
+	assert.False(results["optional"].Critical)
+
+	checkError = errors.New("expected")
+	response = httptest.NewRecorder()
+	h.ServeReady(response, httptest.NewRequest("GET", "/health/ready", nil))
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+
+	results = nil
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &results))
+	assert.Equal(Status{State: Healthy}, results["gate"].Status)
+	assert.Equal(Status{State: Unhealthy, Message: "expected"}, results["custom"].Status)
+
+	// readiness checks are part of the health report
+	assert.Equal(Unhealthy, h.Report(context.Background()).State)
+}
+
+func testServeReadyPending(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		h       = setupHealth(t)
+	)
+
+	// a noncritical Degraded result would otherwise mask the pending critical check in the aggregate state
+	h.AddCheck(NewCheck("optional", false, func(context.Context) Status { return Status{State: Degraded, Message: "slow"} }))
+	h.AddScheduledCheck(
+		NewCheck("dependency", true, func(context.Context) Status { return Status{State: Healthy} }),
+		time.Hour,
+		time.Second,
+	)
+
+	// the scheduler has not been started, so the critical check has not produced a result
+	response := httptest.NewRecorder()
+	h.ServeReady(response, httptest.NewRequest("GET", "/health/ready", nil))
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+
+	var results map[string]CheckResult
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &results))
+	assert.Equal(Status{State: Pending, Message: pendingMessage}, results["dependency"].Status)
+	assert.True(results["dependency"].Critical)
+
+	// a pending noncritical check does not affect readiness
+	h = setupHealth(t)
+	h.AddScheduledCheck(
+		NewCheck("optional", false, func(context.Context) Status { return Status{State: Healthy} }),
+		time.Hour,
+		time.Second,
+	)
+
+	response = httptest.NewRecorder()
+	h.ServeReady(response, httptest.NewRequest("GET", "/health/ready", nil))
+	assert.Equal(http.StatusOK, response.Code)
+}
+
+func testServeReadyTimeout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		h       = setupHealth(t)
+		release = make(chan struct{})
+	)
+
+	defer close(release)
+	h.checkTimeout = 10 * time.Millisecond
+	h.AddReadinessCheck("hung", CheckerFunc(func() error {
+		<-release
+		return nil
+	}))
+
+	response := httptest.NewRecorder()
+	h.ServeReady(response, httptest.NewRequest("GET", "/health/ready", nil))
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+
+	var results map[string]CheckResult
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &results))
+	assert.Equal(Unhealthy, results["hung"].State)
+	assert.Contains(results["hung"].Message, "timed out")
+}
+
+func TestServeReady(t *testing.T) {
+	t.Run("NoChecks", testServeReadyNoChecks)
+	t.Run("Checks", testServeReadyChecks)
+	t.Run("Pending", testServeReadyPending)
+	t.Run("Timeout", testServeReadyTimeout)
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"net"
 	"net/http"
+
+	"github.com/Comcast/webpa-common/xhttp"
 )
 
 // Wrap returns a *health.ResponseWriter which wraps the given
@@ -21,12 +23,17 @@ type ResponseWriter struct {
 	statusCode int
 }
 
+// StatusCode returns the status code of the final response.  Informational responses, such as
+// 103 Early Hints, are not recorded.
 func (r *ResponseWriter) StatusCode() int {
 	return r.statusCode
 }
 
 func (r *ResponseWriter) WriteHeader(statusCode int) {
-	r.statusCode = statusCode
+	if !xhttp.IsInformational(statusCode) {
+		r.statusCode = statusCode
+	}
+
 	r.ResponseWriter.WriteHeader(statusCode)
 }
 
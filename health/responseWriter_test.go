@@ -17,8 +17,11 @@ func testResponseWriterWrapping(t *testing.T) {
 		composite = Wrap(delegate)
 	)
 
+	delegate.On("WriteHeader", 103).Once()
 	delegate.On("WriteHeader", 200).Once()
 
+	assert.Equal(0, composite.StatusCode())
+	composite.WriteHeader(103)
 	assert.Equal(0, composite.StatusCode())
 	composite.WriteHeader(200)
 	assert.Equal(200, composite.StatusCode())
@@ -0,0 +1,13 @@
+package health
+
+import "syscall"
+
+// fileDescriptorLimit returns the soft limit on open file descriptors for this process
+func fileDescriptorLimit() (uint64, error) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, err
+	}
+
+	return uint64(limit.Cur), nil
+}
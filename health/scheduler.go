@@ -0,0 +1,197 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+)
+
+const (
+	// DefaultCheckInterval is the interval used for a scheduled check when none is supplied
+	DefaultCheckInterval = 30 * time.Second
+
+	// DefaultCheckTimeout is the timeout used for a scheduled check when none is supplied
+	DefaultCheckTimeout = 5 * time.Second
+
+	// pendingMessage is reported for a scheduled check that has not yet completed its first run
+	pendingMessage = "check has not run yet"
+)
+
+// scheduledCheck is a Check that is run in the background rather than on demand
+type scheduledCheck struct {
+	check    Check
+	interval time.Duration
+	timeout  time.Duration
+
+	// stop is closed when this check is replaced or its Health is shut down
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// cancel stops the background runs of this check.  This method is idempotent.
+func (sc *scheduledCheck) cancel() {
+	sc.stopOnce.Do(func() { close(sc.stop) })
+}
+
+// runCheck executes a Check, giving up after the timeout.  A check that times out is reported as Unhealthy.
+// Note that a check which ignores its context will continue running in the background after it times out.
+func runCheck(ctx context.Context, c Check, timeout time.Duration) Status {
+	if timeout < 1 {
+		return c.Run(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := make(chan Status, 1)
+	go func() {
+		result <- c.Run(ctx)
+	}()
+
+	select {
+	case status := <-result:
+		return status
+	case <-ctx.Done():
+		return Status{State: Unhealthy, Message: fmt.Sprintf("check timed out after %s", timeout)}
+	}
+}
+
+// AddScheduledCheck registers a Check that runs in the background every interval, rather than each time
+// the health endpoint is hit.  Each run is bounded by the timeout.  The most recent result is cached and
+// reported along with the time it was produced, so that a slow dependency never blocks the health endpoint.
+// Until its first run completes, a scheduled check is reported as Pending.
+//
+// If interval or timeout are nonpositive, DefaultCheckInterval and DefaultCheckTimeout are used, respectively.
+// Scheduled checks start running when this Health is Run, or immediately if this Health is already running.
+// As with AddCheck, a check with the same name as an existing check replaces it:  the background runs of a
+// replaced scheduled check are stopped, and its cached result is discarded.
+func (h *Health) AddScheduledCheck(c Check, interval, timeout time.Duration) {
+	if interval < 1 {
+		interval = DefaultCheckInterval
+	}
+
+	if timeout < 1 {
+		timeout = DefaultCheckTimeout
+	}
+
+	sc := &scheduledCheck{
+		check:    c,
+		interval: interval,
+		timeout:  timeout,
+		stop:     make(chan struct{}),
+	}
+
+	h.AddCheck(c)
+	h.lock.Lock()
+	if h.schedules == nil {
+		h.schedules = make(map[string]*scheduledCheck)
+	}
+
+	replaced := h.schedules[c.Name()]
+	h.schedules[c.Name()] = sc
+	delete(h.cache, c.Name())
+	shutdown, waitGroup := h.shutdown, h.waitGroup
+	h.lock.Unlock()
+
+	if replaced != nil {
+		replaced.cancel()
+	}
+
+	if shutdown != nil {
+		h.schedule(sc, waitGroup, shutdown)
+	}
+}
+
+// startSchedules begins running every scheduled check.  This method is invoked when this Health is Run.
+func (h *Health) startSchedules(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) {
+	h.lock.Lock()
+	h.shutdown, h.waitGroup = shutdown, waitGroup
+	schedules := make([]*scheduledCheck, 0, len(h.schedules))
+	for _, sc := range h.schedules {
+		schedules = append(schedules, sc)
+	}
+
+	h.lock.Unlock()
+
+	for _, sc := range schedules {
+		h.schedule(sc, waitGroup, shutdown)
+	}
+}
+
+// schedule starts a concurrent.Scheduler that runs a check immediately and then on every interval until
+// either shutdown or the check is replaced.  Each run's context is cancelled at that point, so that
+// a check in progress does not hold up shutdown.
+func (h *Health) schedule(sc *scheduledCheck, waitGroup *sync.WaitGroup, shutdown <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		select {
+		case <-shutdown:
+			sc.cancel()
+		case <-sc.stop:
+		}
+	}()
+
+	concurrent.NewScheduler(
+		concurrent.ScheduleOptions{
+			Name:       sc.check.Name(),
+			Logger:     h.logger,
+			Period:     sc.interval,
+			RunOnStart: true,
+		},
+		func() {
+			status := runCheck(ctx, sc.check, sc.timeout)
+			if ctx.Err() != nil {
+				// this check was stopped while it was running, so its result is meaningless
+				return
+			}
+
+			h.lock.Lock()
+			if h.schedules[sc.check.Name()] != sc {
+				// this check was replaced while it was running
+				h.lock.Unlock()
+				return
+			}
+
+			if h.cache == nil {
+				h.cache = make(map[string]CheckResult)
+			}
+
+			h.cache[sc.check.Name()] = CheckResult{
+				Status:    status,
+				Critical:  sc.check.Critical(),
+				Timestamp: time.Now(),
+			}
+
+			h.lock.Unlock()
+			h.reevaluate()
+		},
+	).Run(waitGroup, sc.stop)
+}
+
+// cachedResult returns the most recent result of a scheduled check.  The second return value is false
+// if the named check is not scheduled.
+func (h *Health) cachedResult(c Check, now time.Time) (CheckResult, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	sc, ok := h.schedules[c.Name()]
+	if !ok {
+		return CheckResult{}, false
+	}
+
+	result, ok := h.cache[c.Name()]
+	if !ok {
+		return CheckResult{
+			Status:   Status{State: Pending, Message: pendingMessage},
+			Critical: c.Critical(),
+		}, true
+	}
+
+	// a result is stale once more than one interval has been missed
+	result.Stale = now.Sub(result.Timestamp) > 2*sc.interval
+	return result, true
+}
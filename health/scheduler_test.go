@@ -0,0 +1,231 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCheck(t *testing.T) {
+	t.Run("NoTimeout", func(t *testing.T) {
+		c := NewCheck("test", true, func(context.Context) Status { return Status{State: Degraded} })
+		assert.Equal(t, Status{State: Degraded}, runCheck(context.Background(), c, 0))
+	})
+
+	t.Run("Completes", func(t *testing.T) {
+		c := NewCheck("test", true, func(ctx context.Context) Status {
+			_, ok := ctx.Deadline()
+			assert.True(t, ok)
+			return Status{State: Healthy}
+		})
+
+		assert.Equal(t, Status{State: Healthy}, runCheck(context.Background(), c, time.Minute))
+	})
+
+	t.Run("TimesOut", func(t *testing.T) {
+		var (
+			block = make(chan struct{})
+			c     = NewCheck("test", true, func(context.Context) Status {
+				<-block
+				return Status{State: Healthy}
+			})
+		)
+
+		defer close(block)
+		status := runCheck(context.Background(), c, 10*time.Millisecond)
+		assert.Equal(t, Unhealthy, status.State)
+		assert.Contains(t, status.Message, "timed out")
+	})
+}
+
+// waitForRuns returns a check that signals each time it runs, and a function that waits for a run
+func waitForRuns(t *testing.T, name string, critical bool, status Status) (Check, func()) {
+	runs := make(chan struct{}, 10)
+	c := NewCheck(name, critical, func(context.Context) Status {
+		select {
+		case runs <- struct{}{}:
+		default:
+		}
+
+		return status
+	})
+
+	return c, func() {
+		select {
+		case <-runs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("The scheduled check did not run")
+		}
+	}
+}
+
+// waitForCache polls until the named check has a cached result, since the cache is updated after the check returns
+func waitForCache(t *testing.T, h *Health, name string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		h.lock.Lock()
+		_, ok := h.cache[name]
+		h.lock.Unlock()
+		if ok {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatal("No cached result was recorded")
+}
+
+func TestAddScheduledCheck(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		h       = setupHealth(t)
+
+		c, waitForRun = waitForRuns(t, "dependency", true, Status{State: Unhealthy, Message: "down"})
+		waitGroup     = new(sync.WaitGroup)
+		shutdown      = make(chan struct{})
+	)
+
+	h.AddScheduledCheck(c, 0, 0)
+	require.Contains(h.schedules, "dependency")
+	assert.Equal(DefaultCheckInterval, h.schedules["dependency"].interval)
+	assert.Equal(DefaultCheckTimeout, h.schedules["dependency"].timeout)
+
+	report := h.Report(context.Background())
+	assert.Equal(Pending, report.State)
+	assert.Equal(Status{State: Pending, Message: pendingMessage}, report.Checks["dependency"].Status)
+
+	h.Run(waitGroup, shutdown)
+	waitForRun()
+	waitForCache(t, h, "dependency")
+
+	report = h.Report(context.Background())
+	assert.Equal(Unhealthy, report.State)
+	result := report.Checks["dependency"]
+	assert.Equal(Status{State: Unhealthy, Message: "down"}, result.Status)
+	assert.True(result.Critical)
+	assert.False(result.Timestamp.IsZero())
+	assert.False(result.Stale)
+
+	// age the cached result so that it has missed several intervals
+	h.lock.Lock()
+	aged := h.cache["dependency"]
+	aged.Timestamp = aged.Timestamp.Add(-3 * DefaultCheckInterval)
+	h.cache["dependency"] = aged
+	h.lock.Unlock()
+
+	report = h.Report(context.Background())
+	assert.True(report.Checks["dependency"].Stale)
+
+	close(shutdown)
+	waitGroup.Wait()
+}
+
+func TestAddScheduledCheckWhileRunning(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = setupHealth(t)
+
+		c, waitForRun = waitForRuns(t, "dependency", false, Status{State: Healthy})
+		waitGroup     = new(sync.WaitGroup)
+		shutdown      = make(chan struct{})
+	)
+
+	h.Run(waitGroup, shutdown)
+	h.AddScheduledCheck(c, 10*time.Millisecond, time.Second)
+
+	// the check should run immediately and then again on the interval
+	waitForRun()
+	waitForRun()
+	waitForCache(t, h, "dependency")
+
+	report := h.Report(context.Background())
+	assert.Equal(Healthy, report.State)
+	assert.Equal(Status{State: Healthy}, report.Checks["dependency"].Status)
+
+	close(shutdown)
+	waitGroup.Wait()
+}
+
+func TestAddScheduledCheckReplace(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = setupHealth(t)
+
+		originalRuns int32
+		original     = NewCheck("dependency", true, func(context.Context) Status {
+			atomic.AddInt32(&originalRuns, 1)
+			return Status{State: Unhealthy}
+		})
+
+		replacement, waitForRun = waitForRuns(t, "dependency", true, Status{State: Healthy})
+		waitGroup               = new(sync.WaitGroup)
+		shutdown                = make(chan struct{})
+	)
+
+	h.Run(waitGroup, shutdown)
+	h.AddScheduledCheck(original, 10*time.Millisecond, time.Second)
+	waitForCache(t, h, "dependency")
+
+	h.AddScheduledCheck(replacement, 10*time.Millisecond, time.Second)
+	assert.Len(h.Checks(), 1)
+	waitForRun()
+	waitForRun()
+
+	// the original check has stopped, so its run count no longer changes
+	stopped := atomic.LoadInt32(&originalRuns)
+	waitForRun()
+	waitForRun()
+	assert.Equal(stopped, atomic.LoadInt32(&originalRuns))
+
+	waitForCache(t, h, "dependency")
+	report := h.Report(context.Background())
+	assert.Equal(Healthy, report.State)
+
+	close(shutdown)
+	waitGroup.Wait()
+}
+
+func TestAddScheduledCheckShutdown(t *testing.T) {
+	var (
+		h         = setupHealth(t)
+		started   = make(chan struct{})
+		cancelled = make(chan struct{})
+		waitGroup = new(sync.WaitGroup)
+		shutdown  = make(chan struct{})
+	)
+
+	h.AddScheduledCheck(
+		NewCheck("slow", true, func(ctx context.Context) Status {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return Status{State: Unhealthy}
+		}),
+		time.Hour,
+		time.Hour,
+	)
+
+	h.Run(waitGroup, shutdown)
+	<-started
+	close(shutdown)
+
+	// shutting down cancels the context of the check in progress
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The context of the running check was not cancelled")
+	}
+
+	waitGroup.Wait()
+	h.lock.Lock()
+	_, ok := h.cache["slow"]
+	h.lock.Unlock()
+	assert.False(t, ok)
+}
@@ -0,0 +1,216 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeapCheckName           = "heap"
+	RSSCheckName            = "rss"
+	FileDescriptorCheckName = "fileDescriptors"
+	GoroutineCheckName      = "goroutines"
+
+	// DefaultDegradedRatio is the fraction of a threshold at which a threshold check reports Degraded
+	DefaultDegradedRatio = 0.8
+
+	// DefaultProcSelf is the proc filesystem directory describing the current process
+	DefaultProcSelf = "/proc/self"
+)
+
+// ThresholdOptions configures the built-in resource checks.  Each check is only created if its threshold is set.
+// A threshold check reports Unhealthy once a measurement reaches its maximum, and Degraded once a measurement
+// reaches DegradedRatio of its maximum.  This allows an instance to be taken out of service before it tips over.
+type ThresholdOptions struct {
+	// MaxHeapBytes is the ceiling on allocated heap memory
+	MaxHeapBytes uint64 `json:"maxHeapBytes"`
+
+	// MaxRSSBytes is the ceiling on the resident set size of this process.  This check requires a proc filesystem.
+	MaxRSSBytes uint64 `json:"maxRSSBytes"`
+
+	// MaxFileDescriptorRatio is the ceiling on open file descriptors, expressed as a fraction of the
+	// process's soft limit.  This check requires a proc filesystem.
+	MaxFileDescriptorRatio float64 `json:"maxFileDescriptorRatio"`
+
+	// MaxGoroutines is the ceiling on the number of goroutines
+	MaxGoroutines int `json:"maxGoroutines"`
+
+	// DegradedRatio is the fraction of each threshold at which checks report Degraded.  If unset,
+	// DefaultDegradedRatio is used.
+	DegradedRatio float64 `json:"degradedRatio"`
+
+	// Critical indicates whether exceeding a threshold makes the whole server Unhealthy
+	Critical bool `json:"critical"`
+
+	// Interval is how often the threshold checks run.  If unset, DefaultCheckInterval is used.
+	Interval time.Duration `json:"interval"`
+
+	// Timeout bounds each run of a threshold check.  If unset, DefaultCheckTimeout is used.
+	Timeout time.Duration `json:"timeout"`
+
+	// ProcSelf is the proc filesystem directory for this process.  If unset, DefaultProcSelf is used.
+	ProcSelf string `json:"procSelf"`
+}
+
+func (o *ThresholdOptions) degradedRatio() float64 {
+	if o != nil && o.DegradedRatio > 0 {
+		return o.DegradedRatio
+	}
+
+	return DefaultDegradedRatio
+}
+
+func (o *ThresholdOptions) critical() bool {
+	if o != nil {
+		return o.Critical
+	}
+
+	return false
+}
+
+func (o *ThresholdOptions) interval() time.Duration {
+	if o != nil {
+		return o.Interval
+	}
+
+	return 0
+}
+
+func (o *ThresholdOptions) timeout() time.Duration {
+	if o != nil {
+		return o.Timeout
+	}
+
+	return 0
+}
+
+func (o *ThresholdOptions) procSelf() string {
+	if o != nil && len(o.ProcSelf) > 0 {
+		return o.ProcSelf
+	}
+
+	return DefaultProcSelf
+}
+
+// Checks produces a Check for each configured threshold.  A nil ThresholdOptions produces no checks.
+func (o *ThresholdOptions) Checks() []Check {
+	if o == nil {
+		return nil
+	}
+
+	var (
+		checks   []Check
+		ratio    = o.degradedRatio()
+		critical = o.critical()
+		procSelf = o.procSelf()
+	)
+
+	if o.MaxHeapBytes > 0 {
+		checks = append(checks, thresholdCheck(HeapCheckName, critical, ratio, float64(o.MaxHeapBytes), measureHeap))
+	}
+
+	if o.MaxRSSBytes > 0 {
+		checks = append(checks, thresholdCheck(RSSCheckName, critical, ratio, float64(o.MaxRSSBytes), func() (float64, error) {
+			return measureRSS(procSelf)
+		}))
+	}
+
+	if o.MaxFileDescriptorRatio > 0 {
+		checks = append(checks, thresholdCheck(FileDescriptorCheckName, critical, ratio, o.MaxFileDescriptorRatio, func() (float64, error) {
+			return measureFileDescriptorRatio(procSelf)
+		}))
+	}
+
+	if o.MaxGoroutines > 0 {
+		checks = append(checks, thresholdCheck(GoroutineCheckName, critical, ratio, float64(o.MaxGoroutines), measureGoroutines))
+	}
+
+	return checks
+}
+
+// AddThresholdChecks schedules each of the configured threshold checks with this Health
+func (h *Health) AddThresholdChecks(o *ThresholdOptions) {
+	for _, c := range o.Checks() {
+		h.AddScheduledCheck(c, o.interval(), o.timeout())
+	}
+}
+
+// thresholdCheck creates a Check that compares a measurement against a maximum
+func thresholdCheck(name string, critical bool, degradedRatio, max float64, measure func() (float64, error)) Check {
+	return NewCheck(name, critical, func(context.Context) Status {
+		value, err := measure()
+		if err != nil {
+			return Status{State: Unhealthy, Message: err.Error()}
+		}
+
+		message := fmt.Sprintf("%s of %s", formatMeasurement(value), formatMeasurement(max))
+		switch {
+		case value >= max:
+			return Status{State: Unhealthy, Message: message}
+		case value >= max*degradedRatio:
+			return Status{State: Degraded, Message: message}
+		default:
+			return Status{State: Healthy, Message: message}
+		}
+	})
+}
+
+func formatMeasurement(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func measureHeap() (float64, error) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return float64(stats.HeapAlloc), nil
+}
+
+func measureGoroutines() (float64, error) {
+	return float64(runtime.NumGoroutine()), nil
+}
+
+// measureRSS reads the resident set size, in bytes, from the statm file under procSelf
+func measureRSS(procSelf string) (float64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procSelf, "statm"))
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("Invalid statm contents: %s", data)
+	}
+
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(pages) * float64(os.Getpagesize()), nil
+}
+
+// measureFileDescriptorRatio computes the fraction of the file descriptor soft limit that is in use
+func measureFileDescriptorRatio(procSelf string) (float64, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(procSelf, "fd"))
+	if err != nil {
+		return 0, err
+	}
+
+	limit, err := fileDescriptorLimit()
+	if err != nil {
+		return 0, err
+	}
+
+	if limit == 0 {
+		return 0, fmt.Errorf("No file descriptor limit available")
+	}
+
+	return float64(len(entries)) / float64(limit), nil
+}
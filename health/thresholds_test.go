@@ -0,0 +1,188 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholdCheck(t *testing.T) {
+	testData := []struct {
+		value         float64
+		err           error
+		expectedState State
+	}{
+		{0, nil, Healthy},
+		{79, nil, Healthy},
+		{80, nil, Degraded},
+		{99, nil, Degraded},
+		{100, nil, Unhealthy},
+		{150, nil, Unhealthy},
+		{0, errors.New("expected"), Unhealthy},
+	}
+
+	for _, record := range testData {
+		t.Logf("%#v", record)
+
+		var (
+			assert = assert.New(t)
+			c      = thresholdCheck("test", true, 0.8, 100, func() (float64, error) { return record.value, record.err })
+		)
+
+		assert.Equal("test", c.Name())
+		assert.True(c.Critical())
+
+		status := c.Run(context.Background())
+		assert.Equal(record.expectedState, status.State)
+		if record.err != nil {
+			assert.Equal(record.err.Error(), status.Message)
+		} else {
+			assert.Equal(strconv.FormatFloat(record.value, 'f', -1, 64)+" of 100", status.Message)
+		}
+	}
+}
+
+func testProcSelf(t *testing.T, statm string, fds int) string {
+	dir, err := ioutil.TempDir("", "procSelf")
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "statm"), []byte(statm), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "fd"), 0755))
+	for i := 0; i < fds; i++ {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "fd", strconv.Itoa(i)), nil, 0644))
+	}
+
+	return dir
+}
+
+func TestMeasureRSS(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		dir := testProcSelf(t, "1000 25 10 1 0 50 0\n", 0)
+		defer os.RemoveAll(dir)
+
+		rss, err := measureRSS(dir)
+		assert.Equal(t, float64(25*os.Getpagesize()), rss)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, statm := range []string{"", "1000", "1000 notanumber"} {
+			dir := testProcSelf(t, statm, 0)
+			_, err := measureRSS(dir)
+			assert.Error(t, err)
+			os.RemoveAll(dir)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := measureRSS("/nosuch")
+		assert.Error(t, err)
+	})
+}
+
+func TestMeasureFileDescriptorRatio(t *testing.T) {
+	limit, err := fileDescriptorLimit()
+	if err != nil || limit == 0 {
+		t.Skip("File descriptor limits are not available")
+	}
+
+	dir := testProcSelf(t, "", 7)
+	defer os.RemoveAll(dir)
+
+	ratio, err := measureFileDescriptorRatio(dir)
+	assert.Equal(t, 7/float64(limit), ratio)
+	assert.NoError(t, err)
+
+	_, err = measureFileDescriptorRatio("/nosuch")
+	assert.Error(t, err)
+}
+
+func TestMeasureRuntime(t *testing.T) {
+	assert := assert.New(t)
+
+	heap, err := measureHeap()
+	assert.True(heap > 0)
+	assert.NoError(err)
+
+	goroutines, err := measureGoroutines()
+	assert.Equal(float64(runtime.NumGoroutine()), goroutines)
+	assert.NoError(err)
+}
+
+func TestThresholdOptions(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		var o *ThresholdOptions
+		assert.Empty(t, o.Checks())
+		assert.Equal(t, DefaultDegradedRatio, o.degradedRatio())
+		assert.False(t, o.critical())
+		assert.Zero(t, o.interval())
+		assert.Zero(t, o.timeout())
+		assert.Equal(t, DefaultProcSelf, o.procSelf())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Empty(t, new(ThresholdOptions).Checks())
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			dir    = testProcSelf(t, "1000 1 1 1 0 1 0", 1)
+			o      = &ThresholdOptions{
+				MaxHeapBytes:           1 << 40,
+				MaxRSSBytes:            1 << 40,
+				MaxFileDescriptorRatio: 1,
+				MaxGoroutines:          1000000,
+				DegradedRatio:          0.9,
+				Critical:               true,
+				Interval:               time.Minute,
+				Timeout:                time.Second,
+				ProcSelf:               dir,
+			}
+		)
+
+		defer os.RemoveAll(dir)
+
+		checks := o.Checks()
+		require.Len(t, checks, 4)
+
+		names := make([]string, 0, len(checks))
+		for _, c := range checks {
+			names = append(names, c.Name())
+			assert.True(c.Critical())
+		}
+
+		assert.Equal([]string{HeapCheckName, RSSCheckName, FileDescriptorCheckName, GoroutineCheckName}, names)
+
+		// the file descriptor check depends on rlimits, which may not be available everywhere
+		for _, c := range []Check{checks[0], checks[1], checks[3]} {
+			assert.Equal(Healthy, c.Run(context.Background()).State)
+		}
+	})
+}
+
+func TestAddThresholdChecks(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = setupHealth(t)
+	)
+
+	h.AddThresholdChecks(nil)
+	assert.Empty(h.Checks())
+
+	h.AddThresholdChecks(&ThresholdOptions{MaxGoroutines: 1, Interval: time.Minute, Timeout: time.Second})
+	checks := h.Checks()
+	require.Len(t, checks, 1)
+	assert.Equal(GoroutineCheckName, checks[0].Name())
+	assert.Equal(time.Minute, h.schedules[GoroutineCheckName].interval)
+	assert.Equal(time.Second, h.schedules[GoroutineCheckName].timeout)
+}
@@ -0,0 +1,144 @@
+package health
+
+import (
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+)
+
+// Transition describes a change in the overall state of a server
+type Transition struct {
+	// From is the overall state prior to the transition
+	From State
+
+	// To is the new overall state
+	To State
+
+	// Report is the composite health that produced the transition
+	Report Report
+}
+
+// TransitionListener receives notifications when the overall state of a Health changes
+type TransitionListener interface {
+	OnTransition(Transition)
+}
+
+// TransitionListenerFunc is a function type that implements TransitionListener
+type TransitionListenerFunc func(Transition)
+
+func (f TransitionListenerFunc) OnTransition(t Transition) {
+	f(t)
+}
+
+// AddTransitionListener registers a listener for transitions in overall state.  The state of a Health
+// is reevaluated whenever a Report is produced and each time a scheduled check runs.  Listeners
+// are invoked serially, in the order the transitions occurred, and must not produce a Report themselves.
+func (h *Health) AddTransitionListener(l TransitionListener) {
+	h.lock.Lock()
+	h.transitionListeners = append(h.transitionListeners, l)
+	h.lock.Unlock()
+}
+
+// reevaluate recomputes the overall state when a scheduled check produces a new result.  Unlike Report,
+// no checks are run:  unscheduled checks contribute their results from the most recent Report, if any.
+func (h *Health) reevaluate() {
+	var (
+		checks  = h.Checks()
+		now     = time.Now()
+		results = make(map[string]CheckResult, len(checks))
+	)
+
+	h.lock.Lock()
+	previous := h.results
+	h.lock.Unlock()
+
+	for _, c := range checks {
+		if cached, ok := h.cachedResult(c, now); ok {
+			results[c.Name()] = cached
+		} else if result, ok := previous[c.Name()]; ok {
+			results[c.Name()] = result
+		}
+	}
+
+	report := Report{
+		State:  Aggregate(results),
+		Checks: results,
+	}
+
+	h.SendEvent(func(stats Stats) {
+		report.Stats = stats.Clone()
+	})
+
+	h.dispatchTransition(report)
+}
+
+// dispatchTransition records the overall state of a report, notifying listeners if the state changed
+func (h *Health) dispatchTransition(report Report) {
+	h.transitionLock.Lock()
+	defer h.transitionLock.Unlock()
+
+	h.lock.Lock()
+	t := Transition{From: h.state, To: report.State, Report: report}
+	h.state = report.State
+	listeners := h.transitionListeners
+	h.lock.Unlock()
+
+	if t.From == t.To {
+		return
+	}
+
+	h.debugLog.Log(logging.MessageKey(), "Health state transition", "from", t.From, "to", t.To)
+	for _, l := range listeners {
+		l.OnTransition(t)
+	}
+}
+
+// State returns the most recently evaluated overall state of this Health.  A Health is initially Healthy.
+func (h *Health) State() State {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.state
+}
+
+// Gate is the behavior of a gate that can be closed when a server is unhealthy.  xhttp/gate.Interface
+// implements this interface.
+type Gate interface {
+	Raise() bool
+	Lower() bool
+}
+
+// GateListener produces a TransitionListener that lowers the given gate when the overall state crosses
+// the threshold, and raises it again when the state recovers.  Transitions that do not cross the threshold
+// have no effect, so a gate that was lowered for some other reason is left alone.  A threshold of Unhealthy
+// is typical.
+func GateListener(g Gate, threshold State) TransitionListener {
+	return TransitionListenerFunc(func(t Transition) {
+		switch {
+		case t.From < threshold && t.To >= threshold:
+			g.Lower()
+		case t.From >= threshold && t.To < threshold:
+			g.Raise()
+		}
+	})
+}
+
+// Registrar is the behavior of a service discovery registration.  go-kit's sd.Registrar, and thus
+// the Consul registrars in this library, implement this interface.
+type Registrar interface {
+	Register()
+	Deregister()
+}
+
+// RegistrarListener produces a TransitionListener that deregisters from service discovery when the overall
+// state crosses the threshold, and registers again when it recovers.  Transitions that do not cross the
+// threshold have no effect.
+func RegistrarListener(r Registrar, threshold State) TransitionListener {
+	return TransitionListenerFunc(func(t Transition) {
+		switch {
+		case t.From < threshold && t.To >= threshold:
+			r.Deregister()
+		case t.From >= threshold && t.To < threshold:
+			r.Register()
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransitionListeners(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = setupHealth(t)
+
+		current     = Status{State: Healthy}
+		transitions []Transition
+	)
+
+	h.AddCheck(NewCheck("dependency", true, func(context.Context) Status { return current }))
+	h.AddTransitionListener(TransitionListenerFunc(func(t Transition) {
+		transitions = append(transitions, t)
+	}))
+
+	assert.Equal(Healthy, h.State())
+	h.Report(context.Background())
+	assert.Empty(transitions)
+
+	current = Status{State: Degraded}
+	h.Report(context.Background())
+	h.Report(context.Background())
+	assert.Equal(Degraded, h.State())
+
+	current = Status{State: Unhealthy}
+	h.Report(context.Background())
+
+	current = Status{State: Healthy}
+	h.Report(context.Background())
+	assert.Equal(Healthy, h.State())
+
+	if assert.Len(transitions, 3) {
+		assert.Equal(Healthy, transitions[0].From)
+		assert.Equal(Degraded, transitions[0].To)
+		assert.Equal(Degraded, transitions[0].Report.State)
+
+		assert.Equal(Degraded, transitions[1].From)
+		assert.Equal(Unhealthy, transitions[1].To)
+
+		assert.Equal(Unhealthy, transitions[2].From)
+		assert.Equal(Healthy, transitions[2].To)
+	}
+}
+
+func TestGateListener(t *testing.T) {
+	var (
+		g = new(mockGate)
+		l = GateListener(g, Unhealthy)
+	)
+
+	// transitions that don't cross the threshold do nothing
+	l.OnTransition(Transition{From: Healthy, To: Pending})
+	l.OnTransition(Transition{From: Pending, To: Degraded})
+	l.OnTransition(Transition{From: Degraded, To: Healthy})
+
+	g.On("Lower").Return(true).Once()
+	l.OnTransition(Transition{From: Degraded, To: Unhealthy})
+
+	g.On("Raise").Return(true).Once()
+	l.OnTransition(Transition{From: Unhealthy, To: Healthy})
+
+	g.AssertExpectations(t)
+}
+
+func TestRegistrarListener(t *testing.T) {
+	var (
+		r = new(mockRegistrar)
+		l = RegistrarListener(r, Unhealthy)
+	)
+
+	// transitions that don't cross the threshold do nothing
+	l.OnTransition(Transition{From: Healthy, To: Degraded})
+	l.OnTransition(Transition{From: Degraded, To: Healthy})
+
+	r.On("Deregister").Once()
+	l.OnTransition(Transition{From: Degraded, To: Unhealthy})
+
+	r.On("Register").Once()
+	l.OnTransition(Transition{From: Unhealthy, To: Healthy})
+
+	r.AssertExpectations(t)
+}
+
+func TestScheduledCheckTransitions(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = setupHealth(t)
+
+		c, waitForRun = waitForRuns(t, "dependency", true, Status{State: Unhealthy})
+		transitions   = make(chan Transition, 10)
+		waitGroup     = new(sync.WaitGroup)
+		shutdown      = make(chan struct{})
+	)
+
+	h.AddTransitionListener(TransitionListenerFunc(func(t Transition) {
+		transitions <- t
+	}))
+
+	h.AddScheduledCheck(c, 0, 0)
+	h.Run(waitGroup, shutdown)
+	waitForRun()
+
+	select {
+	case transition := <-transitions:
+		assert.Equal(Healthy, transition.From)
+		assert.Equal(Unhealthy, transition.To)
+	case <-time.After(5 * time.Second):
+		assert.Fail("No transition was dispatched after a scheduled check ran")
+	}
+
+	close(shutdown)
+	waitGroup.Wait()
+}
+
+func TestScheduledCheckDoesNotRunOtherChecks(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = setupHealth(t)
+
+		onDemandRuns int32
+		onDemand     = NewCheck("onDemand", false, func(context.Context) Status {
+			atomic.AddInt32(&onDemandRuns, 1)
+			return Status{State: Unhealthy}
+		})
+
+		c, waitForRun = waitForRuns(t, "dependency", true, Status{State: Healthy})
+		transitions   = make(chan Transition, 10)
+		waitGroup     = new(sync.WaitGroup)
+		shutdown      = make(chan struct{})
+	)
+
+	h.AddTransitionListener(TransitionListenerFunc(func(t Transition) {
+		transitions <- t
+	}))
+
+	h.AddCheck(onDemand)
+	h.AddScheduledCheck(c, 10*time.Millisecond, time.Second)
+
+	// the on demand check contributes its most recent result to each reevaluation
+	assert.Equal(Degraded, h.Report(context.Background()).State)
+	assert.Equal(int32(1), atomic.LoadInt32(&onDemandRuns))
+	<-transitions
+
+	h.Run(waitGroup, shutdown)
+	waitForRun()
+	waitForRun()
+	waitForRun()
+
+	close(shutdown)
+	waitGroup.Wait()
+
+	assert.Equal(int32(1), atomic.LoadInt32(&onDemandRuns))
+	assert.Equal(Degraded, h.State())
+	select {
+	case transition := <-transitions:
+		assert.Fail("Unexpected transition", "%#v", transition)
+	default:
+	}
+}
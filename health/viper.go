@@ -0,0 +1,55 @@
+package health
+
+import (
+	"github.com/spf13/viper"
+)
+
+const (
+	// ThresholdsKey is the Viper subkey under which ThresholdOptions are typically stored.
+	// NewThresholdOptions *does not* assume this key.
+	ThresholdsKey = "health.thresholds"
+
+	// DependenciesKey is the Viper key under which a list of HTTPCheckOptions is stored
+	DependenciesKey = "health.dependencies"
+)
+
+// Sub returns the standard child Viper, using ThresholdsKey, for this package.
+// If passed nil, this function returns nil.
+func Sub(v *viper.Viper) *viper.Viper {
+	if v != nil {
+		return v.Sub(ThresholdsKey)
+	}
+
+	return nil
+}
+
+// NewThresholdOptions produces a ThresholdOptions from a (possibly nil) Viper instance.
+// Callers should use NewThresholdOptions(Sub(v)) if the standard subkey is desired.
+// A nil Viper produces a nil ThresholdOptions, which configures no threshold checks.
+func NewThresholdOptions(v *viper.Viper) (*ThresholdOptions, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	o := new(ThresholdOptions)
+	if err := v.Unmarshal(o); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// NewHTTPCheckOptions produces the HTTPCheckOptions stored under DependenciesKey in a (possibly nil) Viper instance.
+// A nil Viper, or one without any dependencies, produces no options.
+func NewHTTPCheckOptions(v *viper.Viper) ([]HTTPCheckOptions, error) {
+	if v == nil || !v.IsSet(DependenciesKey) {
+		return nil, nil
+	}
+
+	var o []HTTPCheckOptions
+	if err := v.UnmarshalKey(DependenciesKey, &o); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
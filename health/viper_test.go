@@ -0,0 +1,147 @@
+package health
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSub(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(Sub(nil))
+
+	v := viper.New()
+	v.SetConfigType("json")
+	require.NoError(t, v.ReadConfig(strings.NewReader(`{"health": {"thresholds": {"maxGoroutines": 100}}}`)))
+
+	sub := Sub(v)
+	require.NotNil(t, sub)
+	assert.Equal(100, sub.GetInt("maxGoroutines"))
+}
+
+func TestNewThresholdOptions(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		o, err := NewThresholdOptions(nil)
+		assert.Nil(t, o)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			v       = viper.New()
+		)
+
+		v.SetConfigType("json")
+		require.NoError(v.ReadConfig(strings.NewReader(`{
+			"maxHeapBytes": 1000,
+			"maxRSSBytes": 2000,
+			"maxFileDescriptorRatio": 0.75,
+			"maxGoroutines": 500,
+			"degradedRatio": 0.5,
+			"critical": true,
+			"interval": "15s",
+			"timeout": "2s",
+			"procSelf": "/proc/1"
+		}`)))
+
+		o, err := NewThresholdOptions(v)
+		require.NoError(err)
+		require.NotNil(o)
+
+		assert.Equal(
+			ThresholdOptions{
+				MaxHeapBytes:           1000,
+				MaxRSSBytes:            2000,
+				MaxFileDescriptorRatio: 0.75,
+				MaxGoroutines:          500,
+				DegradedRatio:          0.5,
+				Critical:               true,
+				Interval:               15 * time.Second,
+				Timeout:                2 * time.Second,
+				ProcSelf:               "/proc/1",
+			},
+			*o,
+		)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		v := viper.New()
+		v.Set("maxGoroutines", "notanumber")
+		o, err := NewThresholdOptions(v)
+		assert.Nil(t, o)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewHTTPCheckOptions(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		o, err := NewHTTPCheckOptions(nil)
+		assert.Nil(t, o)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		o, err := NewHTTPCheckOptions(viper.New())
+		assert.Nil(t, o)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			v       = viper.New()
+		)
+
+		v.SetConfigType("json")
+		require.NoError(v.ReadConfig(strings.NewReader(`{"health": {"dependencies": [
+			{
+				"name": "keys",
+				"url": "http://keys.example.com/health",
+				"method": "HEAD",
+				"header": {"Authorization": ["Basic abc"]},
+				"expectedStatus": [200, 204],
+				"degradedLatency": "500ms",
+				"critical": true,
+				"interval": "15s",
+				"timeout": "2s"
+			},
+			{
+				"url": "http://other.example.com/health"
+			}
+		]}}`)))
+
+		o, err := NewHTTPCheckOptions(v)
+		require.NoError(err)
+		require.Len(o, 2)
+
+		assert.Equal("keys", o[0].Name)
+		assert.Equal("http://keys.example.com/health", o[0].URL)
+		assert.Equal("HEAD", o[0].Method)
+		request, err := o[0].newRequest(context.Background())
+		require.NoError(err)
+		assert.Equal("Basic abc", request.Header.Get("Authorization"))
+		assert.Equal([]int{200, 204}, o[0].ExpectedStatus)
+		assert.Equal(500*time.Millisecond, o[0].DegradedLatency)
+		assert.True(o[0].Critical)
+		assert.Equal(15*time.Second, o[0].Interval)
+		assert.Equal(2*time.Second, o[0].Timeout)
+
+		assert.Equal(HTTPCheckOptions{URL: "http://other.example.com/health"}, o[1])
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		v := viper.New()
+		v.Set(DependenciesKey, "notalist")
+		o, err := NewHTTPCheckOptions(v)
+		assert.Nil(t, o)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// DefaultAsyncBufferSize is the number of pending writes an AsyncWriter buffers when no size is supplied
+const DefaultAsyncBufferSize = 1000
+
+// OverflowPolicy determines what an AsyncWriter does with a write when its buffer is full
+type OverflowPolicy string
+
+const (
+	// OverflowBlock makes writers wait for space in the buffer.  No output is lost, but a stalled
+	// output can eventually stall the goroutines that log.
+	OverflowBlock OverflowPolicy = "block"
+
+	// OverflowDrop discards writes that do not fit in the buffer.  Logging never stalls, but output can be lost.
+	OverflowDrop OverflowPolicy = "drop"
+)
+
+// ErrAsyncWriterClosed is returned when writing to an AsyncWriter that has been closed
+var ErrAsyncWriterClosed = errors.New("The asynchronous log writer has been closed")
+
+// AsyncWriter is an io.Writer that decouples logging from output I/O.  Each write is copied into a bounded buffer
+// and written to the underlying io.Writer by a single background goroutine, so that a slow disk or blocked pipe
+// cannot stall request goroutines.
+type AsyncWriter struct {
+	next     io.Writer
+	overflow OverflowPolicy
+	counter  metrics.Counter
+	dropped  uint64
+
+	lock    sync.RWMutex
+	closed  bool
+	pending chan []byte
+	done    chan struct{}
+}
+
+// NewAsyncWriter starts an AsyncWriter that buffers up to size writes for the given io.Writer.  If size is nonpositive,
+// DefaultAsyncBufferSize is used.  An unrecognized overflow policy, including the empty string, is equivalent
+// to OverflowBlock.  The optional dropped counter is incremented for each write discarded under OverflowDrop.
+func NewAsyncWriter(next io.Writer, size int, overflow OverflowPolicy, dropped metrics.Counter) *AsyncWriter {
+	if size < 1 {
+		size = DefaultAsyncBufferSize
+	}
+
+	if overflow != OverflowDrop {
+		overflow = OverflowBlock
+	}
+
+	aw := &AsyncWriter{
+		next:     next,
+		overflow: overflow,
+		counter:  dropped,
+		pending:  make(chan []byte, size),
+		done:     make(chan struct{}),
+	}
+
+	go aw.run()
+	return aw
+}
+
+// run is the background goroutine that writes buffered output
+func (aw *AsyncWriter) run() {
+	defer close(aw.done)
+	for p := range aw.pending {
+		// there is nowhere to report an error writing log output
+		aw.next.Write(p)
+	}
+}
+
+// Write buffers a copy of p for output.  Under OverflowDrop, a write that does not fit in the buffer is discarded,
+// though it is still reported as written so that callers do not treat it as an error.
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	aw.lock.RLock()
+	defer aw.lock.RUnlock()
+
+	if aw.closed {
+		return 0, ErrAsyncWriterClosed
+	}
+
+	// loggers commonly reuse their buffers, so the output must be copied
+	output := make([]byte, len(p))
+	copy(output, p)
+
+	if aw.overflow == OverflowDrop {
+		select {
+		case aw.pending <- output:
+		default:
+			atomic.AddUint64(&aw.dropped, 1)
+			if aw.counter != nil {
+				aw.counter.Add(1.0)
+			}
+		}
+	} else {
+		aw.pending <- output
+	}
+
+	return len(p), nil
+}
+
+// Dropped returns the number of writes discarded so far under OverflowDrop
+func (aw *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&aw.dropped)
+}
+
+// Close stops accepting writes and waits for all buffered output to be written.  If the underlying io.Writer is
+// also an io.Closer, it is closed afterward.  Calling Close more than once has no further effect.
+func (aw *AsyncWriter) Close() error {
+	aw.lock.Lock()
+	if aw.closed {
+		aw.lock.Unlock()
+		return nil
+	}
+
+	aw.closed = true
+	close(aw.pending)
+	aw.lock.Unlock()
+
+	<-aw.done
+	if closer, ok := aw.next.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingWriter is an io.Writer that waits on a channel before each write, simulating stalled I/O
+type blockingWriter struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+	block  chan struct{}
+	closed bool
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	<-bw.block
+	bw.lock.Lock()
+	defer bw.lock.Unlock()
+	return bw.buffer.Write(p)
+}
+
+func (bw *blockingWriter) Close() error {
+	bw.lock.Lock()
+	defer bw.lock.Unlock()
+	bw.closed = true
+	return errors.New("expected")
+}
+
+func (bw *blockingWriter) String() string {
+	bw.lock.Lock()
+	defer bw.lock.Unlock()
+	return bw.buffer.String()
+}
+
+func testAsyncWriterDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		output bytes.Buffer
+		aw     = NewAsyncWriter(&output, 0, "", nil)
+	)
+
+	assert.Equal(DefaultAsyncBufferSize, cap(aw.pending))
+	assert.Equal(OverflowBlock, aw.overflow)
+	assert.NoError(aw.Close())
+}
+
+func testAsyncWriterWrite(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		output  bytes.Buffer
+		aw      = NewAsyncWriter(&output, 2, OverflowBlock, nil)
+		message = []byte("first\n")
+	)
+
+	n, err := aw.Write(message)
+	require.NoError(err)
+	assert.Equal(len(message), n)
+
+	// the caller is free to reuse its buffer
+	copy(message, "xxxxx\n")
+	aw.Write([]byte("second\n"))
+	aw.Write([]byte("third\n"))
+
+	assert.NoError(aw.Close())
+	assert.Equal("first\nsecond\nthird\n", output.String())
+	assert.Zero(aw.Dropped())
+
+	n, err = aw.Write([]byte("after close"))
+	assert.Zero(n)
+	assert.Equal(ErrAsyncWriterClosed, err)
+	assert.NoError(aw.Close())
+}
+
+func testAsyncWriterDrop(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		output  = &blockingWriter{block: make(chan struct{})}
+		counter = generic.NewCounter("dropped")
+		aw      = NewAsyncWriter(output, 1, OverflowDrop, counter)
+	)
+
+	// the first write is taken by the background goroutine, which then stalls
+	aw.Write([]byte("first\n"))
+	output.block <- struct{}{}
+
+	aw.Write([]byte("second\n"))
+	for aw.Dropped() == 0 {
+		aw.Write([]byte("dropped\n"))
+	}
+
+	close(output.block)
+	assert.Error(aw.Close())
+	assert.True(output.closed)
+	assert.Equal(float64(aw.Dropped()), counter.Value())
+	assert.Equal("first\nsecond\n", output.String()[:len("first\nsecond\n")])
+}
+
+func testAsyncWriterBlock(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		output  = &blockingWriter{block: make(chan struct{})}
+		aw      = NewAsyncWriter(output, 1, OverflowBlock, nil)
+		written = make(chan struct{})
+	)
+
+	go func() {
+		defer close(written)
+		for i := 0; i < 3; i++ {
+			aw.Write([]byte("message\n"))
+		}
+	}()
+
+	close(output.block)
+	<-written
+	aw.Close()
+	assert.Equal("message\nmessage\nmessage\n", output.String())
+	assert.Zero(aw.Dropped())
+}
+
+func TestAsyncWriter(t *testing.T) {
+	t.Run("Defaults", testAsyncWriterDefaults)
+	t.Run("Write", testAsyncWriterWrite)
+	t.Run("Drop", testAsyncWriterDrop)
+	t.Run("Block", testAsyncWriterBlock)
+}
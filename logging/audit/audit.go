@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// SchemaVersion is the version of the audit event schema.  This changes whenever keys are added to,
+// removed from, or renamed in audit records, so that consumers can parse records from any release.
+const SchemaVersion = 1
+
+// The keys of every audit record, which are always written in this order
+const (
+	TimestampKey = "ts"
+	SchemaKey    = "audit_schema"
+	ActorKey     = "actor"
+	ActionKey    = "action"
+	ResourceKey  = "resource"
+	OutcomeKey   = "outcome"
+	ReasonKey    = "reason"
+)
+
+// Outcome is the result of an audited action
+type Outcome string
+
+const (
+	// Success indicates that the action was carried out
+	Success Outcome = "success"
+
+	// Denied indicates that the actor was not permitted to carry out the action
+	Denied Outcome = "denied"
+
+	// Failure indicates that the action was permitted but could not be carried out
+	Failure Outcome = "failure"
+)
+
+// Event is a single security-relevant occurrence.  All fields are required, except that Reason may be
+// omitted when the Outcome is Success.
+type Event struct {
+	// Actor identifies who performed the action, such as a token's subject or a remote address
+	Actor string
+
+	// Action is what was attempted, e.g. "authorize" or "webhook.register"
+	Action string
+
+	// Resource identifies what the action was performed on, e.g. a URL
+	Resource string
+
+	// Outcome is the result of the action
+	Outcome Outcome
+
+	// Reason explains a Denied or Failure outcome
+	Reason string
+}
+
+// Validate checks that this Event conforms to the schema
+func (e Event) Validate() error {
+	switch {
+	case len(e.Actor) == 0:
+		return fmt.Errorf("Invalid audit event: missing %s", ActorKey)
+
+	case len(e.Action) == 0:
+		return fmt.Errorf("Invalid audit event: missing %s", ActionKey)
+
+	case len(e.Resource) == 0:
+		return fmt.Errorf("Invalid audit event: missing %s", ResourceKey)
+	}
+
+	switch e.Outcome {
+	case Success:
+		return nil
+
+	case Denied, Failure:
+		if len(e.Reason) == 0 {
+			return fmt.Errorf("Invalid audit event: missing %s for outcome %s", ReasonKey, e.Outcome)
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("Invalid audit event: unrecognized %s %q", OutcomeKey, e.Outcome)
+	}
+}
+
+// Logger writes audit records to a go-kit Logger.  Only Events that conform to the schema are written, and each
+// record contains exactly the schema's keys, so that audit records can be consumed without regard to the
+// application that produced them.  Unlike application logs, audit records are never filtered by level.
+//
+// A nil *Logger is valid and discards all records, though Events are still validated.  This allows
+// components to treat auditing as optional.
+type Logger struct {
+	logger log.Logger
+	now    func() time.Time
+}
+
+// NewLogger creates an audit Logger which writes to the given go-kit Logger.  The given Logger should be
+// dedicated to audit records rather than shared with application logging.  If next is nil, this function
+// returns nil.
+func NewLogger(next log.Logger) *Logger {
+	if next == nil {
+		return nil
+	}
+
+	return &Logger{
+		logger: next,
+		now:    time.Now,
+	}
+}
+
+// Log validates and writes an audit record.  An Event that does not conform to the schema is not written,
+// and the validation error is returned instead.
+func (l *Logger) Log(e Event) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	if l == nil {
+		return nil
+	}
+
+	return l.logger.Log(
+		TimestampKey, l.now().UTC().Format(time.RFC3339Nano),
+		SchemaKey, SchemaVersion,
+		ActorKey, e.Actor,
+		ActionKey, e.Action,
+		ResourceKey, e.Resource,
+		OutcomeKey, string(e.Outcome),
+		ReasonKey, e.Reason,
+	)
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventValidate(t *testing.T) {
+	testData := []struct {
+		event         Event
+		expectedValid bool
+	}{
+		{Event{Actor: "user", Action: "authorize", Resource: "GET /foo", Outcome: Success}, true},
+		{Event{Actor: "user", Action: "authorize", Resource: "GET /foo", Outcome: Denied, Reason: "expired_token"}, true},
+		{Event{Actor: "user", Action: "authorize", Resource: "GET /foo", Outcome: Failure, Reason: "unavailable"}, true},
+		{Event{Action: "authorize", Resource: "GET /foo", Outcome: Success}, false},
+		{Event{Actor: "user", Resource: "GET /foo", Outcome: Success}, false},
+		{Event{Actor: "user", Action: "authorize", Outcome: Success}, false},
+		{Event{Actor: "user", Action: "authorize", Resource: "GET /foo"}, false},
+		{Event{Actor: "user", Action: "authorize", Resource: "GET /foo", Outcome: "unknown"}, false},
+		{Event{Actor: "user", Action: "authorize", Resource: "GET /foo", Outcome: Denied}, false},
+		{Event{Actor: "user", Action: "authorize", Resource: "GET /foo", Outcome: Failure}, false},
+	}
+
+	for i, record := range testData {
+		t.Logf("%d: %#v", i, record.event)
+		err := record.event.Validate()
+		assert.Equal(t, record.expectedValid, err == nil, err)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(NewLogger(nil))
+	assert.NotNil(NewLogger(log.NewNopLogger()))
+}
+
+func testLoggerLog(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedTime = time.Date(2018, time.March, 7, 14, 12, 0, 0, time.UTC)
+		keyvals      []interface{}
+
+		l = NewLogger(log.LoggerFunc(func(kv ...interface{}) error {
+			keyvals = kv
+			return nil
+		}))
+	)
+
+	l.now = func() time.Time { return expectedTime }
+
+	require.NoError(l.Log(Event{
+		Actor:    "user",
+		Action:   "authorize",
+		Resource: "GET /foo",
+		Outcome:  Denied,
+		Reason:   "expired_token",
+	}))
+
+	assert.Equal(
+		[]interface{}{
+			TimestampKey, "2018-03-07T14:12:00Z",
+			SchemaKey, SchemaVersion,
+			ActorKey, "user",
+			ActionKey, "authorize",
+			ResourceKey, "GET /foo",
+			OutcomeKey, "denied",
+			ReasonKey, "expired_token",
+		},
+		keyvals,
+	)
+
+	// invalid events are never written
+	keyvals = nil
+	assert.Error(l.Log(Event{Actor: "user"}))
+	assert.Nil(keyvals)
+}
+
+func testLoggerLogError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+		l             = NewLogger(log.LoggerFunc(func(...interface{}) error { return expectedError }))
+	)
+
+	assert.Equal(expectedError, l.Log(Event{Actor: "user", Action: "authorize", Resource: "GET /foo", Outcome: Success}))
+}
+
+func testLoggerNil(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		l      *Logger
+	)
+
+	assert.NoError(l.Log(Event{Actor: "user", Action: "authorize", Resource: "GET /foo", Outcome: Success}))
+	assert.Error(l.Log(Event{}))
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("Log", testLoggerLog)
+	t.Run("LogError", testLoggerLogError)
+	t.Run("Nil", testLoggerNil)
+}
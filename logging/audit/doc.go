@@ -0,0 +1,7 @@
+/*
+Package audit provides logging of security-relevant events, such as denied requests and changes to registrations.
+
+Audit records are kept separate from application logs.  Each record follows a fixed, versioned schema identified
+by SchemaVersion, is always written as JSON, and is written to its own sink configured with Options.
+*/
+package audit
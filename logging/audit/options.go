@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"io"
+	"os"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Options configures the sink for audit records.  This is deliberately separate from logging.Options, so that
+// audit records can be retained and shipped independently of application logs.
+type Options struct {
+	// File is the system file path for audit records.  If unset or set to logging.StdoutFile, records are written
+	// to os.Stdout.  Otherwise, a lumberjack.Logger is created.
+	File string `json:"file"`
+
+	// MaxSize is the lumberjack MaxSize
+	MaxSize int `json:"maxsize"`
+
+	// MaxAge is the lumberjack MaxAge
+	MaxAge int `json:"maxage"`
+
+	// MaxBackups is the lumberjack MaxBackups
+	MaxBackups int `json:"maxbackups"`
+}
+
+func (o *Options) output() io.Writer {
+	if o != nil && len(o.File) > 0 && o.File != logging.StdoutFile {
+		return &lumberjack.Logger{
+			Filename:   o.File,
+			MaxSize:    o.MaxSize,
+			MaxAge:     o.MaxAge,
+			MaxBackups: o.MaxBackups,
+		}
+	}
+
+	return log.NewSyncWriter(os.Stdout)
+}
+
+// New creates an audit Logger which writes JSON records to the sink described by a set of options.
+// The options may be nil, in which case records are written to os.Stdout.
+func New(o *Options) *Logger {
+	return NewLogger(log.NewJSONLogger(o.output()))
+}
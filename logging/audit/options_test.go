@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+func TestOptionsOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, o := range []*Options{nil, new(Options), {File: logging.StdoutFile}} {
+		output := o.output()
+		assert.NotNil(output)
+		_, isLumberjack := output.(*lumberjack.Logger)
+		assert.False(isLumberjack)
+	}
+
+	var (
+		rolling = &Options{
+			File:       "audit.log",
+			MaxSize:    100,
+			MaxAge:     30,
+			MaxBackups: 10,
+		}
+
+		lumberjackLogger, ok = rolling.output().(*lumberjack.Logger)
+	)
+
+	if assert.True(ok) {
+		assert.Equal("audit.log", lumberjackLogger.Filename)
+		assert.Equal(100, lumberjackLogger.MaxSize)
+		assert.Equal(30, lumberjackLogger.MaxAge)
+		assert.Equal(10, lumberjackLogger.MaxBackups)
+	}
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(New(nil))
+	assert.NotNil(New(&Options{File: logging.StdoutFile}))
+}
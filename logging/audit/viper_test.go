@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSub(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		v       = viper.New()
+	)
+
+	assert.Nil(Sub(nil))
+	assert.Nil(Sub(v))
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(strings.NewReader(`
+		{"audit": {
+			"file": "audit.log"
+		}}
+	`)))
+
+	child := Sub(v)
+	require.NotNil(child)
+	assert.Equal("audit.log", child.GetString("file"))
+}
+
+func TestFromViper(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		assert := assert.New(t)
+		o, err := FromViper(nil)
+		assert.NotNil(o)
+		assert.NoError(err)
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			v       = viper.New()
+		)
+
+		v.SetConfigType("json")
+		require.NoError(v.ReadConfig(strings.NewReader(`
+			{"file": "audit.log", "maxsize": 100, "maxage": 30, "maxbackups": 10}
+		`)))
+
+		o, err := FromViper(v)
+		require.NoError(err)
+		assert.Equal(Options{File: "audit.log", MaxSize: 100, MaxAge: 30, MaxBackups: 10}, *o)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			v       = viper.New()
+		)
+
+		v.SetConfigType("json")
+		require.NoError(v.ReadConfig(strings.NewReader(`{"maxsize": "this is not an int"}`)))
+
+		o, err := FromViper(v)
+		assert.Nil(o)
+		assert.Error(err)
+	})
+}
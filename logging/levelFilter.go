@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"sync/atomic"
+
+	"github.com/go-kit/kit/log"
+)
+
+// LevelFilter is a go-kit Logger whose level filtering can be changed while the application is running,
+// e.g. in response to a configuration change.  A LevelFilter is safe for concurrent use.
+type LevelFilter struct {
+	next    log.Logger
+	current atomic.Value
+}
+
+// NewLevelFilter produces a LevelFilter that initially filters according to the given level name.  Level names
+// are the same as for Options.Level.
+func NewLevelFilter(next log.Logger, levelName string) *LevelFilter {
+	lf := &LevelFilter{next: next}
+	lf.SetLevel(levelName)
+	return lf
+}
+
+// SetLevel changes the level of messages that are allowed through this filter
+func (lf *LevelFilter) SetLevel(levelName string) {
+	lf.current.Store(filterLevel(lf.next, levelName))
+}
+
+func (lf *LevelFilter) Log(keyvals ...interface{}) error {
+	return lf.current.Load().(log.Logger).Log(keyvals...)
+}
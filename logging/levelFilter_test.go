@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelFilter(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		output bytes.Buffer
+		filter = NewLevelFilter(log.NewLogfmtLogger(&output), "error")
+	)
+
+	level.Info(filter).Log(MessageKey(), "info before")
+	level.Error(filter).Log(MessageKey(), "error before")
+	assert.NotContains(output.String(), "info before")
+	assert.Contains(output.String(), "error before")
+
+	output.Reset()
+	filter.SetLevel("DEBUG")
+	level.Debug(filter).Log(MessageKey(), "debug after")
+	level.Info(filter).Log(MessageKey(), "info after")
+	assert.Contains(output.String(), "debug after")
+	assert.Contains(output.String(), "info after")
+
+	output.Reset()
+	filter.SetLevel("warn")
+	level.Info(filter).Log(MessageKey(), "info filtered")
+	level.Warn(filter).Log(MessageKey(), "warn allowed")
+	assert.NotContains(output.String(), "info filtered")
+	assert.Contains(output.String(), "warn allowed")
+
+	output.Reset()
+	filter.SetLevel("unrecognized")
+	level.Warn(filter).Log(MessageKey(), "warn filtered")
+	assert.Empty(output.String())
+}
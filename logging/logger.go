@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"io"
 	"strings"
 
 	"github.com/go-kit/kit/log"
@@ -50,18 +51,33 @@ func DefaultLogger() log.Logger {
 // Use either DefaultCaller in this package or the go-kit/kit/log API to add a Caller to the
 // returned Logger.
 func New(o *Options) log.Logger {
+	logger, _ := NewWithCloser(o)
+	return logger
+}
+
+// NewWithCloser is like New, but also returns an io.Closer for the logger's output.  Closing it flushes any
+// asynchronously buffered output and closes the log file, if any.  Applications that enable asynchronous output
+// should close it before exiting, or the most recent log entries may be lost.
+func NewWithCloser(o *Options) (log.Logger, io.Closer) {
+	output := o.output()
 	return NewFilter(
 		log.WithPrefix(
-			o.loggerFactory()(o.output()),
+			o.loggerFactory()(output),
 			TimestampKey(), log.DefaultTimestampUTC,
 		),
 		o,
-	)
+	), output
 }
 
 // NewFilter applies the Options filtering rules in the package to an arbitrary go-kit Logger.
 func NewFilter(next log.Logger, o *Options) log.Logger {
-	switch strings.ToUpper(o.level()) {
+	return filterLevel(next, o.level())
+}
+
+// filterLevel produces a go-kit Logger that filters according to a level name.  Unrecognized
+// level names, including the empty string, are equivalent to ERROR.
+func filterLevel(next log.Logger, levelName string) log.Logger {
+	switch strings.ToUpper(levelName) {
 	case "DEBUG":
 		return level.NewFilter(next, level.AllowDebug())
 
@@ -1,6 +1,8 @@
 package logging
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -8,6 +10,7 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCallerKey(t *testing.T) {
@@ -42,6 +45,29 @@ func TestNew(t *testing.T) {
 	assert.NotNil(New(new(Options)))
 }
 
+func TestNewWithCloser(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		file    = filepath.Join(t.TempDir(), "test.log")
+	)
+
+	logger, closer := NewWithCloser(&Options{File: file, AsyncBufferSize: 10})
+	require.NotNil(logger)
+	require.NotNil(closer)
+
+	logger.Log(level.Key(), level.ErrorValue(), MessageKey(), "buffered message")
+	require.NoError(closer.Close())
+
+	contents, err := ioutil.ReadFile(file)
+	require.NoError(err)
+	assert.Contains(string(contents), "buffered message")
+
+	logger, closer = NewWithCloser(nil)
+	assert.NotNil(logger)
+	assert.NoError(closer.Close())
+}
+
 func testNewFilter(t *testing.T, o *Options) {
 	var (
 		assert = assert.New(t)
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/log"
 )
 
@@ -39,6 +40,8 @@ func RemoteAddrKey() interface{} {
 // The supplied base go-kit Logger is decorated for each request with information about the request.  Downstream code
 // can then use this logger via logging.GetLogger(request.Context()).
 //
+// If the request context carries a request ID, as established by xhttp.RequestID, it is also added to the logger.
+//
 // If the base parameter is not supplied, the default logger is decorated for each request.
 func PopulateLogger(base log.Logger) func(http.Handler) http.Handler {
 	if base == nil {
@@ -47,17 +50,20 @@ func PopulateLogger(base log.Logger) func(http.Handler) http.Handler {
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, request *http.Request) {
-			ctx := logging.WithLogger(
-				request.Context(),
-				log.With(
-					base,
-					requestProtoKey, request.Proto,
-					requestMethodKey, request.Method,
-					requestURIKey, request.RequestURI,
-					remoteAddrKey, request.RemoteAddr,
-				),
+			logger := log.With(
+				base,
+				requestProtoKey, request.Proto,
+				requestMethodKey, request.Method,
+				requestURIKey, request.RequestURI,
+				remoteAddrKey, request.RemoteAddr,
 			)
 
+			if requestID, ok := xhttp.GetRequestID(request.Context()); ok {
+				logger = log.With(logger, xhttp.RequestIDKey(), requestID)
+			}
+
+			ctx := logging.WithLogger(request.Context(), logger)
+
 			next.ServeHTTP(rw, request.WithContext(ctx))
 		})
 	}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -58,6 +59,30 @@ func testPopulateLogger(t *testing.T, base log.Logger) {
 	assert.True(nextCalled)
 }
 
+func testPopulateLoggerRequestID(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		output   []interface{}
+		base     = log.LoggerFunc(func(keyvals ...interface{}) error { output = keyvals; return nil })
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+
+		next = http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+			logging.GetLogger(request.Context()).Log("foo", "bar")
+		})
+	)
+
+	request = request.WithContext(xhttp.WithRequestID(request.Context(), "test-request-id"))
+	decorated := PopulateLogger(base)(next)
+	require.NotNil(decorated)
+
+	decorated.ServeHTTP(response, request)
+	assert.Contains(output, xhttp.RequestIDKey())
+	assert.Contains(output, "test-request-id")
+}
+
 func TestPopulateLogger(t *testing.T) {
 	t.Run("DefaultLogger", func(t *testing.T) {
 		testPopulateLogger(t, nil)
@@ -66,4 +91,6 @@ func TestPopulateLogger(t *testing.T) {
 	t.Run("CustomLogger", func(t *testing.T) {
 		testPopulateLogger(t, logging.NewTestLogger(nil, t))
 	})
+
+	t.Run("RequestID", testPopulateLoggerRequestID)
 }
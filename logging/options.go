@@ -5,6 +5,8 @@ import (
 	"os"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
@@ -34,9 +36,47 @@ type Options struct {
 	// Level is the error level to output: ERROR, INFO, WARN, or DEBUG.  Any unrecognized string,
 	// including the empty string, is equivalent to passing ERROR.
 	Level string `json:"level"`
+
+	// AsyncBufferSize, if positive, enables asynchronous output through an AsyncWriter with this many
+	// buffered writes.  The default is synchronous output.
+	AsyncBufferSize int `json:"asyncBufferSize"`
+
+	// AsyncOverflow is the OverflowPolicy used when the asynchronous buffer is full: "block" or "drop".
+	// Any unrecognized string, including the empty string, is equivalent to "block".
+	AsyncOverflow OverflowPolicy `json:"asyncOverflow"`
+
+	// DroppedCounter is incremented for each log write discarded by asynchronous output under the "drop"
+	// policy.  This is typically a counter from the application's metrics registry.  If unset, drops are
+	// only available through AsyncWriter.Dropped.
+	DroppedCounter metrics.Counter `json:"-"`
+}
+
+// nopCloser is an io.WriteCloser for outputs, like os.Stdout, that must not be closed
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func (o *Options) output() io.WriteCloser {
+	if o != nil && o.AsyncBufferSize > 0 {
+		return NewAsyncWriter(o.syncOutput(), o.AsyncBufferSize, o.AsyncOverflow, o.droppedCounter())
+	}
+
+	return o.syncOutput()
+}
+
+func (o *Options) droppedCounter() metrics.Counter {
+	if o != nil && o.DroppedCounter != nil {
+		return o.DroppedCounter
+	}
+
+	return discard.NewCounter()
 }
 
-func (o *Options) output() io.Writer {
+func (o *Options) syncOutput() io.WriteCloser {
 	if o != nil && len(o.File) > 0 && o.File != StdoutFile {
 		return &lumberjack.Logger{
 			Filename:   o.File,
@@ -46,7 +86,7 @@ func (o *Options) output() io.Writer {
 		}
 	}
 
-	return log.NewSyncWriter(os.Stdout)
+	return nopCloser{log.NewSyncWriter(os.Stdout)}
 }
 
 func (o *Options) loggerFactory() func(io.Writer) log.Logger {
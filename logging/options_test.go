@@ -3,6 +3,7 @@ package logging
 import (
 	"testing"
 
+	"github.com/go-kit/kit/metrics/generic"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
@@ -44,6 +45,21 @@ func testOptionsOutput(t *testing.T) {
 	assert.Equal(689328, lumberjackLogger.MaxSize)
 	assert.Equal(9, lumberjackLogger.MaxAge)
 	assert.Equal(454, lumberjackLogger.MaxBackups)
+
+	async := &Options{AsyncBufferSize: 10, AsyncOverflow: OverflowDrop}
+	asyncWriter, ok := async.output().(*AsyncWriter)
+	assert.True(ok)
+	assert.Equal(OverflowDrop, asyncWriter.overflow)
+	assert.Equal(10, cap(asyncWriter.pending))
+	assert.NotNil(asyncWriter.counter)
+	assert.NoError(asyncWriter.Close())
+
+	dropped := generic.NewCounter("dropped")
+	async.DroppedCounter = dropped
+	asyncWriter, ok = async.output().(*AsyncWriter)
+	assert.True(ok)
+	assert.Equal(dropped, asyncWriter.counter)
+	assert.NoError(asyncWriter.Close())
 }
 
 func testOptionsLevel(t *testing.T) {
@@ -3,35 +3,27 @@ package middleware
 import (
 	"context"
 
+	"github.com/Comcast/webpa-common/semaphore"
 	"github.com/go-kit/kit/endpoint"
 )
 
 // Concurrent produces a middleware that allows only a set number of concurrent calls via
-// a semaphore implemented as a buffered channel.  The context is used for cancellation,
-// and if the context is cancelled then timeoutError is returned if it is not nil, ctx.Err() otherwise.
+// a fair semaphore, so that waiting calls are admitted in the order they arrived.  The context is used for
+// cancellation, and if the context is cancelled then timeoutError is returned if it is not nil, ctx.Err() otherwise.
+// This function panics if concurrency is nonpositive.
 func Concurrent(concurrency int, timeoutError error) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
-		semaphore := make(chan struct{}, concurrency)
-		for r := 0; r < concurrency; r++ {
-			semaphore <- struct{}{}
-		}
-
+		s := semaphore.New(int64(concurrency), semaphore.WithFair())
 		return func(ctx context.Context, value interface{}) (interface{}, error) {
-			select {
-			case <-ctx.Done():
+			if err := s.Acquire(ctx); err != nil {
 				if timeoutError != nil {
 					return nil, timeoutError
-				} else {
-					return nil, ctx.Err()
 				}
 
-			case <-semaphore:
+				return nil, err
 			}
 
-			defer func() {
-				semaphore <- struct{}{}
-			}()
-
+			defer s.Release()
 			return next(ctx, value)
 		}
 	}
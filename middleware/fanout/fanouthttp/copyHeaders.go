@@ -6,11 +6,13 @@ import (
 	"net/textproto"
 
 	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/xhttp"
 	gokithttp "github.com/go-kit/kit/transport/http"
 )
 
 // CopyHeaders is a component client RequestFunc for transferring certain headers from the original
-// request into each component request of a fanout.
+// request into each component request of a fanout.  Hop-by-hop headers, such as Connection, are never
+// transferred.  See xhttp.CopyForwardedHeaders.
 //
 // THe returned RequestFunc requires that the fanoutRequest is available in the context.
 func CopyHeaders(headers ...string) gokithttp.RequestFunc {
@@ -23,8 +25,9 @@ func CopyHeaders(headers ...string) gokithttp.RequestFunc {
 	return func(ctx context.Context, r *http.Request) context.Context {
 		if fr, ok := fanout.FromContext(ctx).(*fanoutRequest); ok {
 			for _, name := range headers {
-				if values, ok := fr.original.Header[name]; ok {
-					r.Header[name] = values
+				if _, ok := fr.original.Header[name]; ok {
+					delete(r.Header, name)
+					xhttp.CopyForwardedHeaders(r.Header, fr.original.Header, name)
 				}
 			}
 		}
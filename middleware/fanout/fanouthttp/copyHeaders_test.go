@@ -37,3 +37,35 @@ func TestCopyHeaders(t *testing.T) {
 	assert.Equal("1234", component.Header.Get("X-Scalar"))
 	assert.Equal([]string{"value1", "value2"}, component.Header["X-Multi"])
 }
+
+func TestCopyHeadersHopByHop(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		original      = httptest.NewRequest("GET", "/foo/bar", nil)
+		fanoutRequest = &fanoutRequest{
+			original: original,
+		}
+
+		component   = httptest.NewRequest("GET", "/", nil)
+		copyHeaders = CopyHeaders("X-Scalar", "Connection", "Keep-Alive", "X-Connection-Scoped", "X-Folded")
+	)
+
+	require.NotNil(copyHeaders)
+
+	original.Header.Set("X-Scalar", "1234")
+	original.Header.Set("Connection", "X-Connection-Scoped")
+	original.Header.Set("Keep-Alive", "timeout=5")
+	original.Header.Set("X-Connection-Scoped", "value")
+	original.Header["X-Folded"] = []string{" folded\r\n value "}
+	component.Header.Set("X-Scalar", "replaced")
+
+	ctx := fanout.NewContext(context.Background(), fanoutRequest)
+	assert.Equal(ctx, copyHeaders(ctx, component))
+
+	assert.Equal([]string{"1234"}, component.Header["X-Scalar"])
+	assert.Equal([]string{"folded value"}, component.Header["X-Folded"])
+	assert.Empty(component.Header.Get("Connection"))
+	assert.Empty(component.Header.Get("Keep-Alive"))
+	assert.Empty(component.Header.Get("X-Connection-Scoped"))
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 
 	"github.com/Comcast/webpa-common/middleware/fanout"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/endpoint"
 	gokithttp "github.com/go-kit/kit/transport/http"
 )
@@ -71,6 +72,9 @@ func encodeComponentRequest(enc gokithttp.EncodeRequestFunc) gokithttp.EncodeReq
 
 		component.Method = fanoutRequest.original.Method
 		component.URL = component.URL.ResolveReference(fanoutRequest.relativeURL)
+		if requestID, ok := xhttp.GetRequestID(fanoutRequest.original.Context()); ok {
+			component.Header.Set(xhttp.RequestIDHeader, requestID)
+		}
 
 		return enc(ctx, component, fanoutRequest.entity)
 	}
@@ -0,0 +1,6 @@
+/*
+Package ring provides a fixed-size buffer that retains the most recently added values.  Rings back the
+bounded, in-memory trails kept for debugging, such as per-device message history and the service discovery
+audit trail.
+*/
+package ring
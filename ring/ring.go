@@ -0,0 +1,54 @@
+package ring
+
+// Ring is a fixed-size buffer which overwrites its oldest value once it is full.  A Ring is not safe for
+// concurrent use, so callers that share a Ring across goroutines must supply their own locking.
+type Ring[T any] struct {
+	values []T
+	next   int
+	full   bool
+}
+
+// New creates a Ring that retains the given number of values.  This function panics if size is nonpositive.
+func New[T any](size int) *Ring[T] {
+	if size < 1 {
+		panic("The size of a ring must be positive")
+	}
+
+	return &Ring[T]{
+		values: make([]T, size),
+	}
+}
+
+// Add appends a value, overwriting the oldest value if this Ring is full
+func (r *Ring[T]) Add(v T) {
+	r.values[r.next] = v
+	r.next++
+	if r.next == len(r.values) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// Cap returns the maximum number of values this Ring retains
+func (r *Ring[T]) Cap() int {
+	return len(r.values)
+}
+
+// Len returns the number of values currently retained
+func (r *Ring[T]) Len() int {
+	if r.full {
+		return len(r.values)
+	}
+
+	return r.next
+}
+
+// Values returns a copy of the retained values, oldest first.  The returned slice is never nil.
+func (r *Ring[T]) Values() []T {
+	output := make([]T, 0, r.Len())
+	if r.full {
+		output = append(output, r.values[r.next:]...)
+	}
+
+	return append(output, r.values[:r.next]...)
+}
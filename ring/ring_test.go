@@ -0,0 +1,57 @@
+package ring
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { New[int](0) })
+	assert.Panics(func() { New[int](-1) })
+
+	r := New[int](3)
+	assert.Equal(3, r.Cap())
+	assert.Zero(r.Len())
+	assert.NotNil(r.Values())
+	assert.Empty(r.Values())
+}
+
+func TestRing(t *testing.T) {
+	testData := []struct {
+		size     int
+		added    int
+		expected []int
+	}{
+		{1, 1, []int{0}},
+		{1, 5, []int{4}},
+		{3, 2, []int{0, 1}},
+		{3, 3, []int{0, 1, 2}},
+		{3, 4, []int{1, 2, 3}},
+		{3, 7, []int{4, 5, 6}},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert = assert.New(t)
+				r      = New[int](record.size)
+			)
+
+			for v := 0; v < record.added; v++ {
+				r.Add(v)
+			}
+
+			assert.Equal(record.size, r.Cap())
+			assert.Equal(len(record.expected), r.Len())
+			assert.Equal(record.expected, r.Values())
+
+			// the values returned are a copy
+			values := r.Values()
+			values[0] = -1
+			assert.Equal(record.expected, r.Values())
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package abac
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/gorilla/mux"
+)
+
+// Built-in attribute names
+const (
+	AttributeMethod   = "method"
+	AttributePath     = "path"
+	AttributeDeviceID = "deviceID"
+	AttributePartner  = "partner"
+)
+
+// Built-in attribute prefixes.  The remainder of an attribute name after one of these prefixes identifies
+// the path parameter, header, query parameter, or claim.
+const (
+	ParamPrefix  = "param."
+	HeaderPrefix = "header."
+	QueryPrefix  = "query."
+	ClaimPrefix  = "claim."
+)
+
+const (
+	// DeviceIDParam is the path parameter consulted for the deviceID attribute
+	DeviceIDParam = "deviceID"
+
+	// DeviceNameHeader is the header consulted for the deviceID attribute when there is no DeviceIDParam
+	DeviceNameHeader = "X-Webpa-Device-Name"
+
+	// PartnerClaim is the dotted path of the JWT claim consulted for the partner attribute.  Partners are taken
+	// from the authenticated caller's claims rather than a request header, which the caller could forge.
+	PartnerClaim = "allowedResources.allowedPartners"
+)
+
+// Resolver produces the values of a single attribute for a request.  An attribute with no values is absent.
+type Resolver func(*http.Request) []string
+
+// Attributes supplies the request attributes that are not built in
+type Attributes struct {
+	// Claims returns the JWT claims of the caller.  If unset, claim attributes are always absent.
+	Claims func(context.Context) (map[string]interface{}, bool)
+
+	// Custom defines additional attributes by name.  A custom attribute overrides a built-in attribute of the same name.
+	Custom map[string]Resolver
+}
+
+// known tests if the given name is an attribute these Attributes can resolve
+func (a Attributes) known(name string) bool {
+	if _, ok := a.Custom[name]; ok {
+		return true
+	}
+
+	switch name {
+	case AttributeMethod, AttributePath, AttributeDeviceID, AttributePartner:
+		return true
+	}
+
+	for _, prefix := range []string{ParamPrefix, HeaderPrefix, QueryPrefix, ClaimPrefix} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolve produces the values of the named attribute for a request
+func (a Attributes) resolve(request *http.Request, name string) []string {
+	if r, ok := a.Custom[name]; ok {
+		return r(request)
+	}
+
+	switch {
+	case name == AttributeMethod:
+		return []string{request.Method}
+
+	case name == AttributePath:
+		return []string{xhttp.CleanPath(request.URL.Path)}
+
+	case name == AttributeDeviceID:
+		if deviceID := mux.Vars(request)[DeviceIDParam]; len(deviceID) > 0 {
+			return []string{deviceID}
+		}
+
+		return nonEmpty(request.Header.Get(DeviceNameHeader))
+
+	case name == AttributePartner:
+		return a.claim(request, PartnerClaim)
+
+	case strings.HasPrefix(name, ParamPrefix):
+		return nonEmpty(mux.Vars(request)[name[len(ParamPrefix):]])
+
+	case strings.HasPrefix(name, HeaderPrefix):
+		return request.Header[http.CanonicalHeaderKey(name[len(HeaderPrefix):])]
+
+	case strings.HasPrefix(name, QueryPrefix):
+		return request.URL.Query()[name[len(QueryPrefix):]]
+
+	case strings.HasPrefix(name, ClaimPrefix):
+		return a.claim(request, name[len(ClaimPrefix):])
+
+	default:
+		return nil
+	}
+}
+
+// claim produces the values of the JWT claim at the given dotted path
+func (a Attributes) claim(request *http.Request, path string) []string {
+	if a.Claims == nil {
+		return nil
+	}
+
+	claims, ok := a.Claims(request.Context())
+	if !ok {
+		return nil
+	}
+
+	return claimValues(claims, strings.Split(path, "."))
+}
+
+// nonEmpty returns a single element slice for a nonempty value, and nil otherwise
+func nonEmpty(value string) []string {
+	if len(value) > 0 {
+		return []string{value}
+	}
+
+	return nil
+}
+
+// claimValues navigates a claims tree along the given path, flattening the claim found there into strings.
+// Objects are never flattened, so a path that ends at an object produces no values.
+func claimValues(claims map[string]interface{}, path []string) []string {
+	var current interface{} = claims
+	for _, segment := range path {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		if current, ok = object[segment]; !ok {
+			return nil
+		}
+	}
+
+	switch v := current.(type) {
+	case nil, map[string]interface{}:
+		return nil
+
+	case string:
+		return nonEmpty(v)
+
+	case []string:
+		return v
+
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, element := range v {
+			switch element.(type) {
+			case nil, map[string]interface{}, []interface{}:
+				continue
+			default:
+				values = append(values, fmt.Sprint(element))
+			}
+		}
+
+		return values
+
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
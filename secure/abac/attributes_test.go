@@ -0,0 +1,139 @@
+package abac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type claimsKey struct{}
+
+// withClaims associates JWT claims with a request, as an authorization handler would
+func withClaims(request *http.Request, claims map[string]interface{}) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), claimsKey{}, claims))
+}
+
+func claimsFromContext(ctx context.Context) (map[string]interface{}, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(map[string]interface{})
+	return claims, ok
+}
+
+func testClaims(claims map[string]interface{}) func(context.Context) (map[string]interface{}, bool) {
+	return func(context.Context) (map[string]interface{}, bool) {
+		return claims, claims != nil
+	}
+}
+
+func TestAttributesKnown(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a      = Attributes{Custom: map[string]Resolver{"tenant": func(*http.Request) []string { return nil }}}
+	)
+
+	for _, name := range []string{"method", "path", "deviceID", "partner", "param.id", "header.X-Foo", "query.q", "claim.sub", "tenant"} {
+		assert.True(a.known(name), name)
+	}
+
+	for _, name := range []string{"", "nosuch", "param.", "claim.", "Method"} {
+		assert.False(a.known(name), name)
+	}
+}
+
+func TestAttributesResolve(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		request = httptest.NewRequest("POST", "/api/v2/device/mac:112233445566/stat?q=1&q=2", nil)
+
+		a = Attributes{
+			Claims: testClaims(map[string]interface{}{
+				"sub":          "client",
+				"capabilities": []interface{}{"x1:webpa:api:.*:all", 1.0, nil, map[string]interface{}{}},
+				"allowedResources": map[string]interface{}{
+					"allowedPartners": []interface{}{"comcast", "sky"},
+				},
+				"admin":    true,
+				"partners": []string{"cox"},
+				"empty":    "",
+				"nothing":  nil,
+			}),
+			Custom: map[string]Resolver{
+				"tenant": func(*http.Request) []string { return []string{"custom"} },
+				"method": func(*http.Request) []string { return []string{"OVERRIDDEN"} },
+			},
+		}
+	)
+
+	request.Header.Set("X-Foo", "bar")
+
+	// partners are only ever taken from the claims
+	request.Header.Set("X-Webpa-Partner-Id", "evil")
+	request = mux.SetURLVars(request, map[string]string{"deviceID": "mac:112233445566", "service": "stat"})
+
+	testData := []struct {
+		name     string
+		expected []string
+	}{
+		{"method", []string{"OVERRIDDEN"}},
+		{"tenant", []string{"custom"}},
+		{"path", []string{"/api/v2/device/mac:112233445566/stat"}},
+		{"deviceID", []string{"mac:112233445566"}},
+		{"partner", []string{"comcast", "sky"}},
+		{"param.service", []string{"stat"}},
+		{"param.nosuch", nil},
+		{"header.x-foo", []string{"bar"}},
+		{"header.nosuch", nil},
+		{"query.q", []string{"1", "2"}},
+		{"query.nosuch", nil},
+		{"claim.sub", []string{"client"}},
+		{"claim.capabilities", []string{"x1:webpa:api:.*:all", "1"}},
+		{"claim.allowedResources.allowedPartners", []string{"comcast", "sky"}},
+		{"claim.allowedResources", nil},
+		{"claim.sub.nested", nil},
+		{"claim.admin", []string{"true"}},
+		{"claim.partners", []string{"cox"}},
+		{"claim.empty", nil},
+		{"claim.nothing", nil},
+		{"claim.nosuch", nil},
+		{"nosuch", nil},
+	}
+
+	for _, record := range testData {
+		assert.Equal(record.expected, a.resolve(request, record.name), record.name)
+	}
+}
+
+func TestAttributesResolveDeviceNameHeader(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	assert.Empty(Attributes{}.resolve(request, AttributeDeviceID))
+	request.Header.Set(DeviceNameHeader, "mac:112233445566")
+	assert.Equal([]string{"mac:112233445566"}, Attributes{}.resolve(request, AttributeDeviceID))
+}
+
+func TestAttributesResolveNoClaims(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	assert.Empty(Attributes{}.resolve(request, "claim.sub"))
+	assert.Empty(Attributes{Claims: testClaims(nil)}.resolve(request, "claim.sub"))
+	assert.Empty(Attributes{}.resolve(request, AttributePartner))
+	assert.Empty(Attributes{Claims: testClaims(nil)}.resolve(request, AttributePartner))
+}
+
+func TestAttributesResolveCleanPath(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		request = httptest.NewRequest("GET", "/health/../api//v2/./device", nil)
+	)
+
+	assert.Equal([]string{"/api/v2/device"}, Attributes{}.resolve(request, AttributePath))
+}
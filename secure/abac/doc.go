@@ -0,0 +1,37 @@
+/*
+Package abac provides attribute based access control for HTTP servers.  A policy is an ordered list of rules,
+each of which allows or denies the requests whose attributes satisfy all of its conditions.  Policies are
+expressed in configuration, so that authorization which depends on the method, path parameters, device ID,
+partner, or JWT claims of a request does not require custom code in each service.
+
+The following attributes are built in:
+
+	method          the HTTP method
+	path            the cleaned URL path, with no "." or ".." elements or repeated slashes
+	deviceID        the deviceID path parameter, or the X-Webpa-Device-Name header
+	partner         the caller's partners, from the claim.allowedResources.allowedPartners JWT claim
+	param.NAME      the path parameter NAME, as matched by gorilla/mux
+	header.NAME     the values of the header NAME
+	query.NAME      the values of the query parameter NAME
+	claim.PATH      the JWT claim at the dotted PATH, e.g. claim.allowedResources.allowedPartners
+
+Claims are only available when Attributes.Claims is set, typically to handler.ClaimsFromContext, and the policy
+is applied after the request has been authenticated.  Services may define or override attributes with
+Attributes.Custom.
+
+Paths are best tested with the pathMatches operator, which uses the same patterns as xhttp.PathPattern.  The
+matches operator uses regular expressions, which must match an entire value.
+
+A condition's values may reference another attribute by enclosing its name in braces.  For example, this rule
+denies requests for a partner path parameter that is not one of the caller's partners:
+
+	{
+	    "name": "foreign-partner",
+	    "effect": "deny",
+	    "conditions": [
+	        {"attribute": "path", "operator": "pathMatches", "values": ["/api/v2/partner/"]},
+	        {"attribute": "param.partnerID", "operator": "notIn", "values": ["{partner}"]}
+	    ]
+	}
+*/
+package abac
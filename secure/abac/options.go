@@ -0,0 +1,111 @@
+package abac
+
+import (
+	"net/http"
+)
+
+// Effect is the outcome of a rule that matches a request
+type Effect string
+
+const (
+	// Allow permits matching requests
+	Allow Effect = "allow"
+
+	// Deny rejects matching requests
+	Deny Effect = "deny"
+)
+
+// Operator determines how a condition compares an attribute's values
+type Operator string
+
+const (
+	// In is satisfied when any of the attribute's values is one of the condition's values.  This is the default.
+	In Operator = "in"
+
+	// NotIn is satisfied when none of the attribute's values is one of the condition's values, including
+	// when the attribute is absent
+	NotIn Operator = "notIn"
+
+	// Present is satisfied when the attribute has at least one value
+	Present Operator = "present"
+
+	// Absent is satisfied when the attribute has no values
+	Absent Operator = "absent"
+
+	// Matches is satisfied when any of the attribute's values matches any of the condition's values, each of
+	// which is a regular expression.  Each expression must match an entire value, as if it were enclosed in ^ and $.
+	Matches Operator = "matches"
+
+	// PathMatches is satisfied when any of the attribute's values matches any of the condition's values, each of
+	// which is an xhttp.PathPattern.  Values are cleaned before they are matched.  This operator is intended for
+	// the path attribute.
+	PathMatches Operator = "pathMatches"
+)
+
+// Condition is a single test of a request attribute
+type Condition struct {
+	// Attribute is the name of the request attribute to test, e.g. "method" or "claim.sub"
+	Attribute string `json:"attribute"`
+
+	// Operator is how the attribute is compared with Values.  If unset, In is used.
+	Operator Operator `json:"operator,omitempty"`
+
+	// Values are what the attribute is compared with.  A value of the form {name} is replaced with the values of
+	// the named attribute.  Values are required for the In, NotIn, Matches, and PathMatches operators.
+	Values []string `json:"values,omitempty"`
+}
+
+// Rule allows or denies the requests that satisfy all of its conditions.  A rule with no conditions
+// matches every request.
+type Rule struct {
+	// Name identifies this rule in decisions and logging.  If unset, the rule's position is used.
+	Name string `json:"name,omitempty"`
+
+	// Effect is what happens to matching requests.  This field is required.
+	Effect Effect `json:"effect"`
+
+	// Conditions are the tests a request must satisfy for this rule to match
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// Options describes the configuration of an access control policy.  This type is typically
+// unmarshalled from external configuration.
+type Options struct {
+	// Rules is the ordered list of rules.  The first rule matching a request determines whether it is allowed.
+	Rules []Rule `json:"rules,omitempty"`
+
+	// Default is the effect for requests that match no rule.  If unset, such requests are denied.
+	Default Effect `json:"default,omitempty"`
+
+	// DeniedStatusCode is the HTTP status code returned for rejected requests.  If unset, http.StatusForbidden is used.
+	DeniedStatusCode int `json:"deniedStatusCode,omitempty"`
+}
+
+func (o *Options) rules() []Rule {
+	if o != nil {
+		return o.Rules
+	}
+
+	return nil
+}
+
+func (o *Options) defaultEffect() Effect {
+	if o != nil && len(o.Default) > 0 {
+		return o.Default
+	}
+
+	return Deny
+}
+
+func (o *Options) deniedStatusCode() int {
+	if o != nil && o.DeniedStatusCode > 0 {
+		return o.DeniedStatusCode
+	}
+
+	return http.StatusForbidden
+}
+
+// enabled tests if these options define any policy.  A default effect alone does not constitute a policy.
+func (o *Options) enabled() bool {
+	return len(o.rules()) > 0
+}
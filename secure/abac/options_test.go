@@ -0,0 +1,37 @@
+package abac
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, o := range []*Options{nil, new(Options)} {
+			assert.Empty(o.rules())
+			assert.Equal(Deny, o.defaultEffect())
+			assert.Equal(http.StatusForbidden, o.deniedStatusCode())
+			assert.False(o.enabled())
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o      = Options{
+				Rules:            []Rule{{Name: "all", Effect: Allow}},
+				Default:          Allow,
+				DeniedStatusCode: http.StatusUnauthorized,
+			}
+		)
+
+		assert.Equal([]Rule{{Name: "all", Effect: Allow}}, o.rules())
+		assert.Equal(Allow, o.defaultEffect())
+		assert.Equal(http.StatusUnauthorized, o.deniedStatusCode())
+		assert.True(o.enabled())
+		assert.False((&Options{Default: Deny}).enabled())
+	})
+}
@@ -0,0 +1,335 @@
+package abac
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/go-kit/kit/log/level"
+)
+
+// ErrorDenied is the error returned by Authorize for requests the policy denies
+var ErrorDenied = errors.New("Request denied by access control policy")
+
+// Decision is the result of evaluating a request against a Policy
+type Decision struct {
+	// Allowed indicates whether the request is permitted
+	Allowed bool
+
+	// Rule is the name of the rule that decided the request.  This is empty if no rule matched.
+	Rule string
+
+	// Reason is a human readable explanation of this decision
+	Reason string
+}
+
+// compiledCondition is a Condition validated against the available attributes
+type compiledCondition struct {
+	attribute  string
+	operator   Operator
+	values     []string
+	references []string
+	patterns   []*regexp.Regexp
+	paths      []xhttp.PathPattern
+}
+
+// expected produces the set of values an attribute is compared against, including the values
+// of any referenced attributes
+func (cc compiledCondition) expected(resolve func(string) []string) map[string]bool {
+	expected := make(map[string]bool, len(cc.values))
+	for _, v := range cc.values {
+		expected[v] = true
+	}
+
+	for _, name := range cc.references {
+		for _, v := range resolve(name) {
+			expected[v] = true
+		}
+	}
+
+	return expected
+}
+
+func (cc compiledCondition) satisfied(resolve func(string) []string) bool {
+	actual := resolve(cc.attribute)
+	switch cc.operator {
+	case Present:
+		return len(actual) > 0
+
+	case Absent:
+		return len(actual) == 0
+
+	case Matches:
+		for _, v := range actual {
+			for _, p := range cc.patterns {
+				if p.MatchString(v) {
+					return true
+				}
+			}
+		}
+
+		return false
+
+	case PathMatches:
+		for _, v := range actual {
+			for _, pp := range cc.paths {
+				if pp.Matches(v) {
+					return true
+				}
+			}
+		}
+
+		return false
+
+	case NotIn:
+		expected := cc.expected(resolve)
+		for _, v := range actual {
+			if expected[v] {
+				return false
+			}
+		}
+
+		return true
+
+	default:
+		expected := cc.expected(resolve)
+		for _, v := range actual {
+			if expected[v] {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// compiledRule is a Rule with its conditions compiled
+type compiledRule struct {
+	name       string
+	effect     Effect
+	conditions []compiledCondition
+}
+
+// Policy is an attribute based access control policy
+type Policy struct {
+	attributes       Attributes
+	rules            []compiledRule
+	defaultEffect    Effect
+	deniedStatusCode int
+}
+
+// validEffect tests if an Effect is one of the known constants
+func validEffect(e Effect) bool {
+	return e == Allow || e == Deny
+}
+
+// reference returns the attribute name referenced by a condition value, if the value is of the form {name}
+func reference(value string) (string, bool) {
+	if len(value) > 2 && strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+		return value[1 : len(value)-1], true
+	}
+
+	return "", false
+}
+
+// compileCondition validates a single Condition
+func compileCondition(c Condition, a Attributes) (compiledCondition, error) {
+	cc := compiledCondition{
+		attribute: c.Attribute,
+		operator:  c.Operator,
+	}
+
+	if len(cc.operator) == 0 {
+		cc.operator = In
+	}
+
+	if !a.known(cc.attribute) {
+		return cc, fmt.Errorf("Unknown attribute %s", cc.attribute)
+	}
+
+	switch cc.operator {
+	case Present, Absent:
+		if len(c.Values) > 0 {
+			return cc, fmt.Errorf("The %s operator does not accept values", cc.operator)
+		}
+
+	case Matches:
+		if len(c.Values) == 0 {
+			return cc, fmt.Errorf("The %s operator requires values", cc.operator)
+		}
+
+		for _, v := range c.Values {
+			// anchor the expression, so that a pattern such as "admin" cannot match "notadmin"
+			p, err := regexp.Compile("^(?:" + v + ")$")
+			if err != nil {
+				return cc, fmt.Errorf("Invalid pattern %s: %s", v, err)
+			}
+
+			cc.patterns = append(cc.patterns, p)
+		}
+
+	case PathMatches:
+		if len(c.Values) == 0 {
+			return cc, fmt.Errorf("The %s operator requires values", cc.operator)
+		}
+
+		for _, v := range c.Values {
+			pp := xhttp.PathPattern(v)
+			if err := pp.Validate(); err != nil {
+				return cc, err
+			}
+
+			cc.paths = append(cc.paths, pp)
+		}
+
+	case In, NotIn:
+		if len(c.Values) == 0 {
+			return cc, fmt.Errorf("The %s operator requires values", cc.operator)
+		}
+
+		for _, v := range c.Values {
+			if name, ok := reference(v); ok {
+				if !a.known(name) {
+					return cc, fmt.Errorf("Unknown attribute %s", name)
+				}
+
+				cc.references = append(cc.references, name)
+			} else {
+				cc.values = append(cc.values, v)
+			}
+		}
+
+	default:
+		return cc, fmt.Errorf("Unknown operator %s", cc.operator)
+	}
+
+	return cc, nil
+}
+
+// New produces a Policy from a set of options and the attributes available beyond the built-in ones.  If the
+// options define no rules, this function returns a nil Policy, which permits all requests.
+func New(o *Options, a Attributes) (*Policy, error) {
+	if !o.enabled() {
+		return nil, nil
+	}
+
+	p := &Policy{
+		attributes:       a,
+		defaultEffect:    o.defaultEffect(),
+		deniedStatusCode: o.deniedStatusCode(),
+	}
+
+	if !validEffect(p.defaultEffect) {
+		return nil, fmt.Errorf("Invalid default effect %s", p.defaultEffect)
+	}
+
+	for i, r := range o.rules() {
+		cr := compiledRule{
+			name:   r.Name,
+			effect: r.Effect,
+		}
+
+		if len(cr.name) == 0 {
+			cr.name = fmt.Sprintf("rule[%d]", i)
+		}
+
+		if !validEffect(cr.effect) {
+			return nil, fmt.Errorf("Invalid effect %s for rule %s", cr.effect, cr.name)
+		}
+
+		for _, c := range r.Conditions {
+			cc, err := compileCondition(c, a)
+			if err != nil {
+				return nil, fmt.Errorf("Rule %s: %s", cr.name, err)
+			}
+
+			cr.conditions = append(cr.conditions, cc)
+		}
+
+		p.rules = append(p.rules, cr)
+	}
+
+	return p, nil
+}
+
+// Evaluate decides whether a request is allowed by this policy.  Each attribute is resolved at most once.
+// A nil Policy allows all requests.
+func (p *Policy) Evaluate(request *http.Request) Decision {
+	if p == nil {
+		return Decision{Allowed: true, Reason: "no policy"}
+	}
+
+	resolved := make(map[string][]string)
+	resolve := func(name string) []string {
+		values, ok := resolved[name]
+		if !ok {
+			values = p.attributes.resolve(request, name)
+			resolved[name] = values
+		}
+
+		return values
+	}
+
+	for _, r := range p.rules {
+		matched := true
+		for _, c := range r.conditions {
+			if !c.satisfied(resolve) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return Decision{
+				Allowed: r.effect == Allow,
+				Rule:    r.name,
+				Reason:  fmt.Sprintf("%s by rule %s", r.effect, r.name),
+			}
+		}
+	}
+
+	return Decision{
+		Allowed: p.defaultEffect == Allow,
+		Reason:  fmt.Sprintf("%s by default", p.defaultEffect),
+	}
+}
+
+// Authorize is a convenience for Evaluate that returns ErrorDenied if the request is not allowed.  This method
+// can be used as a routeauth.Authenticator.
+func (p *Policy) Authorize(request *http.Request) error {
+	if !p.Evaluate(request).Allowed {
+		return ErrorDenied
+	}
+
+	return nil
+}
+
+// Then is an alice-style decorator that rejects requests which this policy denies.  A nil Policy
+// returns the next handler undecorated.
+func (p *Policy) Then(next http.Handler) http.Handler {
+	if p == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if d := p.Evaluate(request); !d.Allowed {
+			logging.GetLogger(request.Context()).Log(
+				level.Key(), level.InfoValue(),
+				logging.MessageKey(), "request denied by access control policy",
+				"method", request.Method,
+				"path", request.URL.Path,
+				"rule", d.Rule,
+				"reason", d.Reason,
+			)
+
+			xhttp.WriteError(response, p.deniedStatusCode, d.Reason)
+			return
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
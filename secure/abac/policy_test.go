@@ -0,0 +1,292 @@
+package abac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, o := range []*Options{nil, new(Options), {Default: Allow, DeniedStatusCode: 401}} {
+			p, err := New(o, Attributes{})
+			assert.Nil(p)
+			assert.NoError(err)
+		}
+
+		// a nil Policy allows everything
+		var p *Policy
+		assert.Equal(Decision{Allowed: true, Reason: "no policy"}, p.Evaluate(httptest.NewRequest("GET", "/", nil)))
+		assert.NoError(p.Authorize(httptest.NewRequest("GET", "/", nil)))
+	})
+
+	testData := []Options{
+		{Rules: []Rule{{Effect: Allow}}, Default: "maybe"},
+		{Rules: []Rule{{Name: "bad"}}},
+		{Rules: []Rule{{Effect: "permit"}}},
+		{Rules: []Rule{{Effect: Allow, Conditions: []Condition{{Attribute: "nosuch", Values: []string{"x"}}}}}},
+		{Rules: []Rule{{Effect: Allow, Conditions: []Condition{{Attribute: "method"}}}}},
+		{Rules: []Rule{{Effect: Allow, Conditions: []Condition{{Attribute: "method", Operator: NotIn}}}}},
+		{Rules: []Rule{{Effect: Allow, Conditions: []Condition{{Attribute: "method", Values: []string{"{nosuch}"}}}}}},
+		{Rules: []Rule{{Effect: Allow, Conditions: []Condition{{Attribute: "method", Operator: Present, Values: []string{"x"}}}}}},
+		{Rules: []Rule{{Effect: Allow, Conditions: []Condition{{Attribute: "method", Operator: Matches}}}}},
+		{Rules: []Rule{{Effect: Allow, Conditions: []Condition{{Attribute: "method", Operator: Matches, Values: []string{"["}}}}}},
+		{Rules: []Rule{{Effect: Allow, Conditions: []Condition{{Attribute: "method", Operator: "between", Values: []string{"x"}}}}}},
+		{Rules: []Rule{{Effect: Allow, Conditions: []Condition{{Attribute: "path", Operator: PathMatches}}}}},
+		{Rules: []Rule{{Effect: Allow, Conditions: []Condition{{Attribute: "path", Operator: PathMatches, Values: []string{"/api/["}}}}}},
+	}
+
+	for _, o := range testData {
+		t.Run("Invalid", func(t *testing.T) {
+			p, err := New(&o, Attributes{})
+			assert.Nil(t, p)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	var (
+		require = require.New(t)
+
+		p, err = New(
+			&Options{
+				Rules: []Rule{
+					{
+						Name:       "health",
+						Effect:     Allow,
+						Conditions: []Condition{{Attribute: "path", Values: []string{"/health"}}},
+					},
+					{
+						Name:   "foreign-partner",
+						Effect: Deny,
+						Conditions: []Condition{
+							{Attribute: "param.partnerID", Operator: Present},
+							{Attribute: "param.partnerID", Operator: NotIn, Values: []string{"{partner}"}},
+						},
+					},
+					{
+						Name:   "own-device",
+						Effect: Allow,
+						Conditions: []Condition{
+							{Attribute: "method", Values: []string{"GET", "POST"}},
+							{Attribute: "deviceID", Values: []string{"{claim.device}"}},
+						},
+					},
+					{
+						Name:   "admin",
+						Effect: Allow,
+						Conditions: []Condition{
+							{Attribute: "claim.capabilities", Operator: Matches, Values: []string{`^x1:webpa:api:.*:all$`}},
+							{Attribute: "header.X-Debug", Operator: Absent},
+						},
+					},
+				},
+			},
+			Attributes{Claims: claimsFromContext},
+		)
+	)
+
+	require.NoError(err)
+	require.NotNil(p)
+
+	testData := []struct {
+		description string
+		method      string
+		path        string
+		deviceID    string
+		partner     string
+		debug       bool
+		claims      map[string]interface{}
+		expected    Decision
+	}{
+		{"health", "GET", "/health", "", "", false, nil, Decision{true, "health", "allow by rule health"}},
+		{"no claims", "GET", "/api", "", "", false, nil, Decision{false, "", "deny by default"}},
+		{
+			"allowed partner, own device",
+			"GET", "/api", "mac:112233445566", "comcast", false,
+			map[string]interface{}{
+				"device":           "mac:112233445566",
+				"allowedResources": map[string]interface{}{"allowedPartners": []interface{}{"comcast"}},
+			},
+			Decision{true, "own-device", "allow by rule own-device"},
+		},
+		{
+			"foreign partner",
+			"GET", "/api", "mac:112233445566", "sky", false,
+			map[string]interface{}{
+				"device":           "mac:112233445566",
+				"allowedResources": map[string]interface{}{"allowedPartners": []interface{}{"comcast"}},
+			},
+			Decision{false, "foreign-partner", "deny by rule foreign-partner"},
+		},
+		{
+			"other device, wrong method",
+			"DELETE", "/api", "mac:112233445566", "", false,
+			map[string]interface{}{"device": "mac:112233445566"},
+			Decision{false, "", "deny by default"},
+		},
+		{
+			"admin",
+			"DELETE", "/api", "mac:112233445566", "", false,
+			map[string]interface{}{"capabilities": []interface{}{"x1:webpa:api:.*:all"}},
+			Decision{true, "admin", "allow by rule admin"},
+		},
+		{
+			"admin with debug header",
+			"DELETE", "/api", "mac:112233445566", "", true,
+			map[string]interface{}{"capabilities": []interface{}{"x1:webpa:api:.*:all"}},
+			Decision{false, "", "deny by default"},
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.description, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				request = httptest.NewRequest(record.method, record.path, nil)
+			)
+
+			vars := make(map[string]string)
+			if len(record.deviceID) > 0 {
+				vars[DeviceIDParam] = record.deviceID
+			}
+
+			if len(record.partner) > 0 {
+				vars["partnerID"] = record.partner
+			}
+
+			request = mux.SetURLVars(request, vars)
+
+			if record.debug {
+				request.Header.Set("X-Debug", "true")
+			}
+
+			if record.claims != nil {
+				request = withClaims(request, record.claims)
+			}
+
+			assert.Equal(record.expected, p.Evaluate(request))
+			if record.expected.Allowed {
+				assert.NoError(p.Authorize(request))
+			} else {
+				assert.Equal(ErrorDenied, p.Authorize(request))
+			}
+		})
+	}
+}
+
+func TestPolicyMatchesAnchored(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		p, err = New(
+			&Options{
+				Rules: []Rule{
+					{Effect: Allow, Conditions: []Condition{{Attribute: "header.X-Role", Operator: Matches, Values: []string{"admin|ops"}}}},
+				},
+			},
+			Attributes{},
+		)
+	)
+
+	require.NoError(err)
+	for role, allowed := range map[string]bool{"admin": true, "ops": true, "notadmin": false, "admins": false, "ops2": false} {
+		request := httptest.NewRequest("GET", "/", nil)
+		request.Header.Set("X-Role", role)
+		assert.Equal(allowed, p.Evaluate(request).Allowed, role)
+	}
+}
+
+func TestPolicyPathMatches(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		p, err = New(
+			&Options{
+				Rules: []Rule{
+					{Effect: Allow, Conditions: []Condition{{Attribute: "path", Operator: PathMatches, Values: []string{"/health", "/api/*/stat", "/api/v2/device/"}}}},
+				},
+			},
+			Attributes{},
+		)
+	)
+
+	require.NoError(err)
+	for path, allowed := range map[string]bool{
+		"/health":                         true,
+		"/api/v2/device/mac:112233445566": true,
+		"/api/v2/device":                  false,
+		"/api/v3/stat":                    true,
+		"/api/v3/other/stat":              false,
+		"/healthz":                        false,
+		"/health/../admin":                false,
+		"/admin/../health":                true,
+	} {
+		assert.Equal(allowed, p.Evaluate(httptest.NewRequest("GET", path, nil)).Allowed, path)
+	}
+}
+
+func TestPolicyDefaultAllow(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		p, err = New(
+			&Options{
+				Rules: []Rule{
+					{Effect: Deny, Conditions: []Condition{{Attribute: "method", Values: []string{"DELETE"}}}},
+				},
+				Default: Allow,
+			},
+			Attributes{},
+		)
+	)
+
+	require.NoError(err)
+	assert.Equal(Decision{true, "", "allow by default"}, p.Evaluate(httptest.NewRequest("GET", "/", nil)))
+	assert.Equal(Decision{false, "rule[0]", "deny by rule rule[0]"}, p.Evaluate(httptest.NewRequest("DELETE", "/", nil)))
+}
+
+func TestPolicyThen(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.WriteHeader(299)
+		})
+	)
+
+	var nilPolicy *Policy
+	response := httptest.NewRecorder()
+	nilPolicy.Then(next).ServeHTTP(response, httptest.NewRequest("DELETE", "/", nil))
+	assert.Equal(299, response.Code)
+
+	p, err := New(
+		&Options{
+			Rules:            []Rule{{Name: "get", Effect: Allow, Conditions: []Condition{{Attribute: "method", Values: []string{"GET"}}}}},
+			DeniedStatusCode: http.StatusUnauthorized,
+		},
+		Attributes{},
+	)
+
+	require.NoError(err)
+	decorated := p.Then(next)
+
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+
+	response = httptest.NewRecorder()
+	decorated.ServeHTTP(response, httptest.NewRequest("PUT", "/", nil))
+	assert.Equal(http.StatusUnauthorized, response.Code)
+	assert.Contains(response.Body.String(), "deny by default")
+}
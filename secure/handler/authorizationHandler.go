@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/logging/audit"
 	"github.com/Comcast/webpa-common/secure"
 	"github.com/SermoDigital/jose/jws"
 	"github.com/go-kit/kit/log"
@@ -20,6 +22,9 @@ type ContextValues struct {
 	SatClientID string
 	Method      string
 	Path        string
+
+	// Claims are the claims of the caller's JWT.  This will be nil for any other type of token.
+	Claims map[string]interface{}
 }
 
 const (
@@ -46,17 +51,45 @@ func WriteJsonError(response http.ResponseWriter, code int, message string) erro
 	return err
 }
 
+// WriteJsonFailure writes a standard JSON error to the response that includes the reason a request failed
+// authentication or authorization.  The reason is also written to the secure.FailureReasonHeader.
+func WriteJsonFailure(response http.ResponseWriter, code int, message string, reason secure.FailureReason) error {
+	response.Header().Set(ContentTypeHeader, JsonContentType)
+	response.Header().Set(ContentTypeOptionsHeader, NoSniff)
+	response.Header().Set(secure.FailureReasonHeader, string(reason))
+
+	body, err := json.Marshal(struct {
+		Message string               `json:"message"`
+		Reason  secure.FailureReason `json:"reason"`
+	}{message, reason})
+
+	if err != nil {
+		return err
+	}
+
+	response.WriteHeader(code)
+	_, err = response.Write(body)
+	return err
+}
+
 // AuthorizationHandler provides decoration for http.Handler instances and will
 // ensure that requests pass the validator.  Note that secure.Validators is a Validator
 // implementation that allows chaining validators together via logical OR.
+//
+// If an Auditor is set, every denied request is recorded as an audit event with the
+// AuthorizeAction action.
 type AuthorizationHandler struct {
 	HeaderName          string
 	ForbiddenStatusCode int
 	Validator           secure.Validator
 	Logger              log.Logger
+	Auditor             *audit.Logger
 	measures            *secure.JWTValidationMeasures
 }
 
+// AuthorizeAction is the audit action for requests denied by an AuthorizationHandler
+const AuthorizeAction = "authorize"
+
 // headerName returns the authorization header to use, either a.HeaderName
 // or secure.AuthorizationHeader if no header is supplied
 func (a AuthorizationHandler) headerName() string {
@@ -104,10 +137,11 @@ func (a AuthorizationHandler) Decorate(delegate http.Handler) http.Handler {
 		headerValue := request.Header.Get(headerName)
 		if len(headerValue) == 0 {
 			errorLog.Log(logging.MessageKey(), "missing header", "name", headerName)
-			WriteJsonError(response, forbiddenStatusCode, fmt.Sprintf("missing header: %s", headerName))
+			WriteJsonFailure(response, forbiddenStatusCode, fmt.Sprintf("missing header: %s", headerName), secure.ReasonMissingHeader)
+			a.audit(request, request.RemoteAddr, secure.ReasonMissingHeader)
 
 			if a.measures != nil {
-				a.measures.ValidationReason.With("reason", "missing_header").Add(1)
+				a.measures.ValidationReason.With("reason", string(secure.ReasonMissingHeader)).Add(1)
 			}
 			return
 		}
@@ -115,20 +149,22 @@ func (a AuthorizationHandler) Decorate(delegate http.Handler) http.Handler {
 		token, err := secure.ParseAuthorization(headerValue)
 		if err != nil {
 			errorLog.Log(logging.MessageKey(), "invalid authorization header", "name", headerName, "token", headerValue, logging.ErrorKey(), err)
-			WriteJsonError(response, forbiddenStatusCode, fmt.Sprintf("Invalid authorization header [%s]: %s", headerName, err.Error()))
+			WriteJsonFailure(response, forbiddenStatusCode, fmt.Sprintf("Invalid authorization header [%s]: %s", headerName, err.Error()), secure.ReasonInvalidHeader)
+			a.audit(request, request.RemoteAddr, secure.ReasonInvalidHeader)
 
 			if a.measures != nil {
-				a.measures.ValidationReason.With("reason", "invalid_header").Add(1)
+				a.measures.ValidationReason.With("reason", string(secure.ReasonInvalidHeader)).Add(1)
 			}
 			return
 		}
 
 		contextValues := &ContextValues{
-			Method:      request.Method,
-			Path:        request.URL.Path,
-			SatClientID: extractSatClientID(token, logger),
+			Method: request.Method,
+			Path:   request.URL.Path,
 		}
 
+		contextValues.SatClientID, contextValues.Claims = extractClaims(token, logger)
+
 		sharedContext := NewContextWithValue(request.Context(), contextValues)
 
 		valid, err := a.Validator.Validate(sharedContext, token)
@@ -139,8 +175,10 @@ func (a AuthorizationHandler) Decorate(delegate http.Handler) http.Handler {
 			return
 		}
 
+		reason := secure.ReasonFor(err)
 		errorLog.Log(
 			logging.MessageKey(), "request denied",
+			"reason", reason,
 			"validator-response", valid,
 			"validator-error", err,
 			"sat-client-id", contextValues.SatClientID,
@@ -152,21 +190,53 @@ func (a AuthorizationHandler) Decorate(delegate http.Handler) http.Handler {
 			"remoteAddress", request.RemoteAddr,
 		)
 
-		WriteJsonError(response, forbiddenStatusCode, "request denied")
+		WriteJsonFailure(response, forbiddenStatusCode, "request denied", reason)
+
+		actor := contextValues.SatClientID
+		if actor == "N/A" {
+			actor = request.RemoteAddr
+		}
+
+		a.audit(request, actor, reason)
 	})
 }
 
+// audit records a denied request with this handler's Auditor.  The resource is the request's method and path,
+// and the actor is "unknown" if neither a subject nor a remote address is available.
+func (a AuthorizationHandler) audit(request *http.Request, actor string, reason secure.FailureReason) {
+	if a.Auditor == nil {
+		return
+	}
+
+	if len(actor) == 0 {
+		actor = "unknown"
+	}
+
+	if err := a.Auditor.Log(audit.Event{
+		Actor:    actor,
+		Action:   AuthorizeAction,
+		Resource: request.Method + " " + request.URL.Path,
+		Outcome:  audit.Denied,
+		Reason:   string(reason),
+	}); err != nil {
+		logging.Error(a.logger()).Log(logging.MessageKey(), "unable to audit request", "action", AuthorizeAction, logging.ErrorKey(), err)
+	}
+}
+
 //DefineMeasures facilitates clients to define authHandler metrics tools
 func (a *AuthorizationHandler) DefineMeasures(m *secure.JWTValidationMeasures) {
 	a.measures = m
 }
 
-func extractSatClientID(token *secure.Token, logger log.Logger) (satClientID string) {
+// extractClaims returns the subject and the complete set of claims of a JWT.  For any other type of token,
+// the subject is "N/A" and the claims are nil.
+func extractClaims(token *secure.Token, logger log.Logger) (satClientID string, claims map[string]interface{}) {
 	satClientID = "N/A"
 	if token.Type() == secure.Bearer {
 		if jwsObj, errJWSParse := secure.DefaultJWSParser.ParseJWS(token); errJWSParse == nil {
-			if claims, ok := jwsObj.Payload().(jws.Claims); ok {
-				if satClientIDStr, isString := claims.Get("sub").(string); isString {
+			if jwsClaims, ok := jwsObj.Payload().(jws.Claims); ok {
+				claims = jwsClaims
+				if satClientIDStr, isString := jwsClaims.Get("sub").(string); isString {
 					satClientID = satClientIDStr
 				} else {
 					logging.Error(logger).Log(logging.MessageKey(), "JWT Claim value was not of string type")
@@ -10,7 +10,9 @@ import (
 	"testing"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/logging/audit"
 	"github.com/Comcast/webpa-common/secure"
+	"github.com/go-kit/kit/log"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/stretchr/testify/mock"
@@ -123,6 +125,8 @@ func TestAuthorizationHandlerNoAuthorizationHeader(t *testing.T) {
 		jsonError := json.Unmarshal(body, &message)
 		assert.NotEmpty(message)
 		assert.Nil(jsonError)
+		assert.Equal(string(secure.ReasonMissingHeader), message["reason"])
+		assert.Equal(string(secure.ReasonMissingHeader), response.HeaderMap.Get(secure.FailureReasonHeader))
 
 		record.handler.Validator.(*secure.MockValidator).AssertExpectations(t)
 		mockHttpHandler.AssertExpectations(t)
@@ -316,7 +320,138 @@ func TestAuthorizationHandlerFailure(t *testing.T) {
 	}
 }
 
-func TestExtractSatClientID(t *testing.T) {
+func TestAuthorizationHandlerFailureReason(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		logger = logging.NewTestLogger(nil, t)
+
+		testData = []struct {
+			handler            AuthorizationHandler
+			headerName         string
+			expectedStatusCode int
+		}{
+			{
+				handler: AuthorizationHandler{
+					Validator: &secure.MockValidator{},
+				},
+				headerName:         secure.AuthorizationHeader,
+				expectedStatusCode: http.StatusForbidden,
+			},
+			{
+				handler: AuthorizationHandler{
+					Validator:           &secure.MockValidator{},
+					HeaderName:          "X-Custom-Authorization",
+					ForbiddenStatusCode: 512,
+					Logger:              logger,
+				},
+				headerName:         "X-Custom-Authorization",
+				expectedStatusCode: 512,
+			},
+		}
+	)
+
+	for _, record := range testData {
+		t.Logf("%#v", record)
+		mockValidator := record.handler.Validator.(*secure.MockValidator)
+
+		request, _ := http.NewRequest("GET", "http://test.com/foo", nil)
+		request.Header.Set(record.headerName, authorizationValue)
+
+		inputCtxValue := &ContextValues{
+			SatClientID: "N/A",
+			Path:        request.URL.Path,
+			Method:      request.Method,
+		}
+
+		inputCtx := context.WithValue(request.Context(), handlerValuesKey, inputCtxValue)
+
+		token, _ := secure.ParseAuthorization(authorizationValue)
+
+		mockValidator.On("Validate", inputCtx, token).Return(false, fmt.Errorf("validation failed: %w", secure.ErrorTokenRevoked)).Once()
+
+		response := httptest.NewRecorder()
+		mockHttpHandler := &mockHttpHandler{}
+
+		decorated := record.handler.Decorate(mockHttpHandler)
+		assert.NotNil(decorated)
+		decorated.ServeHTTP(response, request)
+		assert.Equal(response.Code, record.expectedStatusCode)
+		assert.Equal(string(secure.ReasonRevoked), response.HeaderMap.Get(secure.FailureReasonHeader))
+
+		message := make(map[string]interface{})
+		assert.NoError(json.Unmarshal(response.Body.Bytes(), &message))
+		assert.Equal("request denied", message["message"])
+		assert.Equal(string(secure.ReasonRevoked), message["reason"])
+
+		mockValidator.AssertExpectations(t)
+		mockHttpHandler.AssertExpectations(t)
+	}
+}
+
+func TestAuthorizationHandlerAudit(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		records []map[interface{}]interface{}
+
+		handler = AuthorizationHandler{
+			Validator: secure.ExactMatchValidator("valid"),
+			Logger:    logging.NewTestLogger(nil, t),
+			Auditor: audit.NewLogger(log.LoggerFunc(func(keyvals ...interface{}) error {
+				record := make(map[interface{}]interface{}, len(keyvals)/2)
+				for i := 0; i < len(keyvals); i += 2 {
+					record[keyvals[i]] = keyvals[i+1]
+				}
+
+				records = append(records, record)
+				return nil
+			})),
+		}
+
+		testData = []struct {
+			authorization  string
+			remoteAddr     string
+			expectedActor  string
+			expectedReason secure.FailureReason
+		}{
+			{"", "127.0.0.1:1234", "127.0.0.1:1234", secure.ReasonMissingHeader},
+			{"Nonsense", "127.0.0.1:1234", "127.0.0.1:1234", secure.ReasonInvalidHeader},
+			{"Basic invalid", "127.0.0.1:1234", "127.0.0.1:1234", secure.ReasonDenied},
+			{"Basic invalid", "", "unknown", secure.ReasonDenied},
+		}
+
+		decorated = handler.Decorate(new(mockHttpHandler))
+	)
+
+	for _, record := range testData {
+		request := httptest.NewRequest("PUT", "http://test.com/foo", nil)
+		request.RemoteAddr = record.remoteAddr
+		if len(record.authorization) > 0 {
+			request.Header.Set(secure.AuthorizationHeader, record.authorization)
+		}
+
+		decorated.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	// successful requests are not audited
+	request := httptest.NewRequest("PUT", "http://test.com/foo", nil)
+	request.Header.Set(secure.AuthorizationHeader, "Basic valid")
+	mockDelegate := new(mockHttpHandler)
+	mockDelegate.On("ServeHTTP", mock.Anything, mock.Anything).Once()
+	handler.Decorate(mockDelegate).ServeHTTP(httptest.NewRecorder(), request)
+	mockDelegate.AssertExpectations(t)
+
+	if assert.Len(records, len(testData)) {
+		for i, record := range testData {
+			assert.Equal(record.expectedActor, records[i][audit.ActorKey])
+			assert.Equal(AuthorizeAction, records[i][audit.ActionKey])
+			assert.Equal("PUT /foo", records[i][audit.ResourceKey])
+			assert.Equal(string(audit.Denied), records[i][audit.OutcomeKey])
+			assert.Equal(string(record.expectedReason), records[i][audit.ReasonKey])
+		}
+	}
+}
+
+func TestExtractClaims(t *testing.T) {
 
 	t.Run("JWT Type", func(t *testing.T) {
 		assert := assert.New(t)
@@ -326,7 +461,10 @@ func TestExtractSatClientID(t *testing.T) {
 			t.FailNow()
 		}
 
-		assert.EqualValues("test-subscriber", extractSatClientID(token, logging.DefaultLogger()))
+		satClientID, claims := extractClaims(token, logging.DefaultLogger())
+		assert.EqualValues("test-subscriber", satClientID)
+		assert.Equal("test-subscriber", claims["sub"])
+		assert.Equal(true, claims["admin"])
 	})
 
 	t.Run("Non-JWT Type", func(t *testing.T) {
@@ -337,7 +475,9 @@ func TestExtractSatClientID(t *testing.T) {
 			t.FailNow()
 		}
 
-		assert.EqualValues("N/A", extractSatClientID(token, logging.DefaultLogger()))
+		satClientID, claims := extractClaims(token, logging.DefaultLogger())
+		assert.EqualValues("N/A", satClientID)
+		assert.Nil(claims)
 	})
 
 }
@@ -13,3 +13,61 @@ func FromContext(ctx context.Context) (*ContextValues, bool) {
 	vals, ofType := ctx.Value(handlerValuesKey).(*ContextValues)
 	return vals, ofType
 }
+
+//SatClientIDFromContext returns the SatClientID of the authenticated caller, if any.  The "N/A" placeholder used
+//for tokens without a subject is not considered a principal.  This function can be used as a fanout.PrincipalFunc.
+func SatClientIDFromContext(ctx context.Context) (string, bool) {
+	if vals, ok := FromContext(ctx); ok && vals != nil && len(vals.SatClientID) > 0 && vals.SatClientID != "N/A" {
+		return vals.SatClientID, true
+	}
+
+	return "", false
+}
+
+//ClaimsFromContext returns the JWT claims of the authenticated caller, if any.  This function can be used as the
+//claims source of an abac.Attributes.
+func ClaimsFromContext(ctx context.Context) (map[string]interface{}, bool) {
+	if vals, ok := FromContext(ctx); ok && vals != nil && vals.Claims != nil {
+		return vals.Claims, true
+	}
+
+	return nil, false
+}
+
+//PartnerIDsFromContext returns the partner ids of the authenticated caller, taken from the allowedResources.allowedPartners
+//claim of its JWT.  Unlike partner ids supplied in headers, these cannot be chosen by the caller.
+func PartnerIDsFromContext(ctx context.Context) ([]string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	resources, ok := claims["allowedResources"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	var partnerIDs []string
+	switch value := resources["allowedPartners"].(type) {
+	case string:
+		if len(value) > 0 {
+			partnerIDs = append(partnerIDs, value)
+		}
+
+	case []string:
+		for _, partnerID := range value {
+			if len(partnerID) > 0 {
+				partnerIDs = append(partnerIDs, partnerID)
+			}
+		}
+
+	case []interface{}:
+		for _, element := range value {
+			if partnerID, ok := element.(string); ok && len(partnerID) > 0 {
+				partnerIDs = append(partnerIDs, partnerID)
+			}
+		}
+	}
+
+	return partnerIDs, len(partnerIDs) > 0
+}
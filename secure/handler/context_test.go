@@ -43,3 +43,82 @@ func TestNewContext(t *testing.T) {
 
 	assert.EqualValues(expectedContext, actualContext)
 }
+
+func TestSatClientIDFromContext(t *testing.T) {
+	testData := []struct {
+		values     *ContextValues
+		expected   string
+		expectedOK bool
+	}{
+		{nil, "", false},
+		{&ContextValues{}, "", false},
+		{&ContextValues{SatClientID: "N/A"}, "", false},
+		{&ContextValues{SatClientID: "test"}, "test", true},
+	}
+
+	for _, record := range testData {
+		assert := assert.New(t)
+		ctx := context.Background()
+		if record.values != nil {
+			ctx = NewContextWithValue(ctx, record.values)
+		}
+
+		actual, ok := SatClientIDFromContext(ctx)
+		assert.Equal(record.expected, actual)
+		assert.Equal(record.expectedOK, ok)
+	}
+}
+
+func TestClaimsFromContext(t *testing.T) {
+	testData := []struct {
+		values     *ContextValues
+		expected   map[string]interface{}
+		expectedOK bool
+	}{
+		{nil, nil, false},
+		{&ContextValues{}, nil, false},
+		{&ContextValues{Claims: map[string]interface{}{"sub": "test"}}, map[string]interface{}{"sub": "test"}, true},
+	}
+
+	for _, record := range testData {
+		assert := assert.New(t)
+		ctx := context.Background()
+		if record.values != nil {
+			ctx = NewContextWithValue(ctx, record.values)
+		}
+
+		actual, ok := ClaimsFromContext(ctx)
+		assert.Equal(record.expected, actual)
+		assert.Equal(record.expectedOK, ok)
+	}
+}
+
+func TestPartnerIDsFromContext(t *testing.T) {
+	testData := []struct {
+		values     *ContextValues
+		expected   []string
+		expectedOK bool
+	}{
+		{nil, nil, false},
+		{&ContextValues{}, nil, false},
+		{&ContextValues{Claims: map[string]interface{}{"sub": "test"}}, nil, false},
+		{&ContextValues{Claims: map[string]interface{}{"allowedResources": "comcast"}}, nil, false},
+		{&ContextValues{Claims: map[string]interface{}{"allowedResources": map[string]interface{}{"allowedPartners": 12}}}, nil, false},
+		{&ContextValues{Claims: map[string]interface{}{"allowedResources": map[string]interface{}{"allowedPartners": ""}}}, nil, false},
+		{&ContextValues{Claims: map[string]interface{}{"allowedResources": map[string]interface{}{"allowedPartners": "comcast"}}}, []string{"comcast"}, true},
+		{&ContextValues{Claims: map[string]interface{}{"allowedResources": map[string]interface{}{"allowedPartners": []string{"comcast", "", "cox"}}}}, []string{"comcast", "cox"}, true},
+		{&ContextValues{Claims: map[string]interface{}{"allowedResources": map[string]interface{}{"allowedPartners": []interface{}{"comcast", 1, "cox"}}}}, []string{"comcast", "cox"}, true},
+	}
+
+	for _, record := range testData {
+		assert := assert.New(t)
+		ctx := context.Background()
+		if record.values != nil {
+			ctx = NewContextWithValue(ctx, record.values)
+		}
+
+		actual, ok := PartnerIDsFromContext(ctx)
+		assert.Equal(record.expected, actual)
+		assert.Equal(record.expectedOK, ok)
+	}
+}
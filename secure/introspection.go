@@ -0,0 +1,350 @@
+package secure
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Comcast/webpa-common/store"
+	"github.com/SermoDigital/jose/jwt"
+	"github.com/rubyist/circuitbreaker"
+)
+
+const (
+	DefaultIntrospectionTimeout          = 5 * time.Second
+	DefaultIntrospectionBreakerThreshold = 5
+
+	// introspectionMaxResponseSize bounds how much of an introspection response is read
+	introspectionMaxResponseSize = 64 * 1024
+)
+
+var (
+	// ErrorTokenInactive is returned when an introspection endpoint reports that a token is not active
+	ErrorTokenInactive = errors.New("The token is not active")
+
+	// ErrorWrongIssuer is returned when an introspection endpoint reports a token from an unexpected issuer
+	ErrorWrongIssuer = errors.New("The token was not issued by the expected issuer")
+
+	// ErrorIntrospectionUnavailable is returned when the introspection endpoint has failed often enough that
+	// its circuit breaker is open.  Tokens are not sent to the endpoint until the breaker allows a retry.
+	ErrorIntrospectionUnavailable = errors.New("The introspection endpoint is unavailable")
+)
+
+// cachedRejections maps the remembered form of each rejection back to its error
+var cachedRejections = map[string]error{
+	ErrorTokenInactive.Error():    ErrorTokenInactive,
+	ErrorWrongIssuer.Error():      ErrorWrongIssuer,
+	jwt.ErrTokenIsExpired.Error(): jwt.ErrTokenIsExpired,
+}
+
+// IntrospectionOptions configures an IntrospectionValidator
+type IntrospectionOptions struct {
+	// Endpoint is the absolute URL of the OAuth2 token introspection endpoint.  This field is required.
+	Endpoint string `json:"endpoint"`
+
+	// ClientID is the client identifier used to authenticate to the endpoint with HTTP Basic authentication.
+	// If unset, no credentials are sent.
+	ClientID string `json:"clientId"`
+
+	// ClientSecret is the client secret sent along with ClientID
+	ClientSecret string `json:"clientSecret"`
+
+	// Issuer is the expected iss of introspected tokens.  If set, active tokens whose introspection reports a
+	// different issuer, or omits iss altogether, are rejected.
+	Issuer string `json:"issuer"`
+
+	// Timeout bounds each introspection request.  If nonpositive, DefaultIntrospectionTimeout is used.
+	Timeout time.Duration `json:"timeout"`
+
+	// BreakerThreshold is the number of consecutive introspection failures that open the circuit breaker.
+	// If nonpositive, DefaultIntrospectionBreakerThreshold is used.
+	BreakerThreshold int64 `json:"breakerThreshold"`
+
+	// Cache configures how long introspection results are remembered.  If nil, the defaults of
+	// ValidationCacheOptions are used.
+	Cache *ValidationCacheOptions `json:"cache"`
+
+	// Transactor sends introspection requests.  If unset, http.DefaultClient.Do is used.
+	Transactor func(*http.Request) (*http.Response, error) `json:"-"`
+}
+
+func (o *IntrospectionOptions) timeout() time.Duration {
+	if o != nil && o.Timeout > 0 {
+		return o.Timeout
+	}
+
+	return DefaultIntrospectionTimeout
+}
+
+func (o *IntrospectionOptions) breakerThreshold() int64 {
+	if o != nil && o.BreakerThreshold > 0 {
+		return o.BreakerThreshold
+	}
+
+	return DefaultIntrospectionBreakerThreshold
+}
+
+func (o *IntrospectionOptions) transactor() func(*http.Request) (*http.Response, error) {
+	if o != nil && o.Transactor != nil {
+		return o.Transactor
+	}
+
+	return http.DefaultClient.Do
+}
+
+// IntrospectionResponse is the subset of an RFC 7662 introspection response used for validation
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// IntrospectionValidator validates bearer tokens by asking an OAuth2 introspection endpoint, as defined by
+// RFC 7662, rather than by verifying them locally.  This supports opaque tokens, which carry no claims, such as
+// those issued by a partner's identity provider.  Any other type of token is rejected.
+//
+// Introspection results, both active and inactive, are remembered for the cache TTL by a hash of the token,
+// though an active token is never remembered beyond its exp.  Errors are never remembered.  Consecutive
+// failures to reach the endpoint open a circuit breaker, after which tokens that are not remembered are
+// rejected with ErrorIntrospectionUnavailable until the endpoint recovers.  A request abandoned because the
+// caller's context was cancelled or reached its deadline says nothing about the endpoint, and so is neither a
+// failure nor a success as far as the circuit breaker is concerned.
+type IntrospectionValidator struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	issuer       string
+	timeout      time.Duration
+	ttl          time.Duration
+	now          func() time.Time
+	transactor   func(*http.Request) (*http.Response, error)
+	breaker      *circuit.Breaker
+	results      store.KV
+}
+
+// NewIntrospectionValidator creates an IntrospectionValidator from a set of options.  An error is returned
+// if the endpoint is not an absolute URL.
+func NewIntrospectionValidator(o IntrospectionOptions) (*IntrospectionValidator, error) {
+	endpoint, err := url.Parse(o.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if !endpoint.IsAbs() || len(endpoint.Host) == 0 {
+		return nil, fmt.Errorf("Invalid introspection endpoint: %s", o.Endpoint)
+	}
+
+	iv := &IntrospectionValidator{
+		endpoint:     endpoint.String(),
+		clientID:     o.ClientID,
+		clientSecret: o.ClientSecret,
+		issuer:       o.Issuer,
+		timeout:      o.timeout(),
+		ttl:          o.Cache.ttl(),
+		now:          o.Cache.now(),
+		transactor:   o.transactor(),
+		breaker:      circuit.NewConsecutiveBreaker(o.breakerThreshold()),
+	}
+
+	iv.results = store.NewMemoryKV(store.MemoryKVOptions{
+		MaxEntries: o.Cache.maxEntries(),
+		Now:        iv.now,
+	})
+
+	return iv, nil
+}
+
+// Tripped tests if this validator's circuit breaker is open
+func (iv *IntrospectionValidator) Tripped() bool {
+	return iv.breaker.Tripped()
+}
+
+func (iv *IntrospectionValidator) Validate(ctx context.Context, token *Token) (bool, error) {
+	if token.Type() != Bearer {
+		return false, nil
+	}
+
+	var (
+		hash     = sha256.Sum256(token.Bytes())
+		cacheKey = string(hash[:])
+	)
+
+	if result, err := iv.results.Get(cacheKey); err == nil {
+		if len(result) > 0 {
+			return false, cachedRejections[string(result)]
+		}
+
+		return true, nil
+	}
+
+	if !iv.breaker.Ready() {
+		return false, ErrorIntrospectionUnavailable
+	}
+
+	response, err := iv.introspect(ctx, token)
+	if err != nil {
+		// only failures of the endpoint itself, including the per-request timeout, count against the breaker
+		if ctx.Err() == nil {
+			iv.breaker.Fail()
+		}
+
+		return false, err
+	}
+
+	iv.breaker.Success()
+
+	// the cached value is empty for an active token, or the rejection for an inactive one
+	ttl, rejection := iv.check(response)
+	if ttl > 0 {
+		var value []byte
+		if rejection != nil {
+			value = []byte(rejection.Error())
+		}
+
+		iv.results.Put(cacheKey, value, ttl)
+	}
+
+	if rejection != nil {
+		return false, rejection
+	}
+
+	return true, nil
+}
+
+// check determines whether an introspection response describes an acceptable token, returning how long
+// the decision may be remembered and the error that rejects the token, if any
+func (iv *IntrospectionValidator) check(response *IntrospectionResponse) (time.Duration, error) {
+	if !response.Active {
+		return iv.ttl, ErrorTokenInactive
+	}
+
+	now := iv.now()
+	if response.Exp > 0 && !now.Before(time.Unix(response.Exp, 0)) {
+		return iv.ttl, jwt.ErrTokenIsExpired
+	}
+
+	if response.Nbf > 0 && now.Before(time.Unix(response.Nbf, 0)) {
+		// a premature token will eventually become valid, so this decision isn't remembered
+		return 0, jwt.ErrTokenNotYetValid
+	}
+
+	if len(iv.issuer) > 0 && response.Iss != iv.issuer {
+		return iv.ttl, ErrorWrongIssuer
+	}
+
+	ttl := iv.ttl
+	if response.Exp > 0 {
+		if untilExp := time.Unix(response.Exp, 0).Sub(now); untilExp < ttl {
+			ttl = untilExp
+		}
+	}
+
+	return ttl, nil
+}
+
+// introspect sends a single introspection request for a token.  Any response other than a 200 with a
+// JSON body is an error.
+func (iv *IntrospectionValidator) introspect(ctx context.Context, token *Token) (*IntrospectionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, iv.timeout)
+	defer cancel()
+
+	form := url.Values{
+		"token":           []string{token.Value()},
+		"token_type_hint": []string{"access_token"},
+	}
+
+	request, err := http.NewRequest(http.MethodPost, iv.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if len(iv.clientID) > 0 {
+		request.SetBasicAuth(url.QueryEscape(iv.clientID), url.QueryEscape(iv.clientSecret))
+	}
+
+	response, err := iv.transactor(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Introspection endpoint returned status %d", response.StatusCode)
+	}
+
+	var introspection IntrospectionResponse
+	if err := json.NewDecoder(io.LimitReader(response.Body, introspectionMaxResponseSize)).Decode(&introspection); err != nil {
+		return nil, err
+	}
+
+	return &introspection, nil
+}
+
+// IssuerValidator selects the Validator for each token by its issuer, so that tokens from different identity
+// providers can be validated differently, e.g. locally verifying JWTs from one issuer while introspecting
+// tokens from another.  A bearer token that is a JWS is routed by its iss claim.  A bearer token that cannot be
+// parsed as a JWS is opaque, and so has no issuer that can be known without asking its identity provider.
+type IssuerValidator struct {
+	// Issuers maps each iss claim to the Validator for tokens from that issuer
+	Issuers map[string]Validator
+
+	// Default validates JWS bearer tokens whose issuer is missing or not in Issuers, along with any
+	// token that is not a bearer token.  If nil, those tokens are rejected.
+	Default Validator
+
+	// Opaque validates bearer tokens that are not JWS, typically with an IntrospectionValidator.
+	// If nil, opaque tokens are rejected.
+	Opaque Validator
+}
+
+// validatorFor selects the Validator for a token, which may be nil
+func (iv IssuerValidator) validatorFor(token *Token) Validator {
+	if token.Type() != Bearer {
+		return iv.Default
+	}
+
+	jwsToken, err := DefaultJWSParser.ParseJWS(token)
+	if err != nil {
+		return iv.Opaque
+	}
+
+	if jwtToken, ok := jwsToken.(jwt.JWT); ok {
+		if issuer, ok := jwtToken.Claims().Issuer(); ok {
+			if v, ok := iv.Issuers[issuer]; ok {
+				return v
+			}
+		}
+	}
+
+	return iv.Default
+}
+
+func (iv IssuerValidator) Validate(ctx context.Context, token *Token) (bool, error) {
+	if v := iv.validatorFor(token); v != nil {
+		return v.Validate(ctx, token)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,413 @@
+package secure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/SermoDigital/jose/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// introspectionServer is an httptest server that answers introspection requests from a map of tokens
+type introspectionServer struct {
+	*httptest.Server
+	requests  int32
+	status    int32
+	responses map[string]IntrospectionResponse
+}
+
+func newIntrospectionServer(t *testing.T, responses map[string]IntrospectionResponse) *introspectionServer {
+	is := &introspectionServer{
+		status:    http.StatusOK,
+		responses: responses,
+	}
+
+	is.Server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&is.requests, 1)
+		assert.Equal(t, http.MethodPost, request.Method)
+		assert.Equal(t, "application/x-www-form-urlencoded", request.Header.Get("Content-Type"))
+
+		clientID, clientSecret, ok := request.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client", clientID)
+		assert.Equal(t, "secret", clientSecret)
+		assert.Equal(t, "access_token", request.PostFormValue("token_type_hint"))
+
+		if status := int(atomic.LoadInt32(&is.status)); status != http.StatusOK {
+			response.WriteHeader(status)
+			return
+		}
+
+		response.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(response).Encode(is.responses[request.PostFormValue("token")])
+	}))
+
+	return is
+}
+
+func (is *introspectionServer) Requests() int {
+	return int(atomic.LoadInt32(&is.requests))
+}
+
+func (is *introspectionServer) SetStatus(status int) {
+	atomic.StoreInt32(&is.status, int32(status))
+}
+
+func TestIntrospectionOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		for _, o := range []*IntrospectionOptions{nil, new(IntrospectionOptions)} {
+			assert := assert.New(t)
+			assert.Equal(DefaultIntrospectionTimeout, o.timeout())
+			assert.Equal(int64(DefaultIntrospectionBreakerThreshold), o.breakerThreshold())
+			assert.NotNil(o.transactor())
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o      = IntrospectionOptions{
+				Timeout:          time.Second,
+				BreakerThreshold: 2,
+				Transactor:       func(*http.Request) (*http.Response, error) { return nil, errors.New("expected") },
+			}
+		)
+
+		assert.Equal(time.Second, o.timeout())
+		assert.Equal(int64(2), o.breakerThreshold())
+		_, err := o.transactor()(nil)
+		assert.Error(err)
+	})
+}
+
+func TestNewIntrospectionValidator(t *testing.T) {
+	assert := assert.New(t)
+	for _, endpoint := range []string{"", "/introspect", "%%invalid"} {
+		iv, err := NewIntrospectionValidator(IntrospectionOptions{Endpoint: endpoint})
+		assert.Nil(iv)
+		assert.Error(err, endpoint)
+	}
+
+	iv, err := NewIntrospectionValidator(IntrospectionOptions{Endpoint: "https://idp.example.com/introspect"})
+	assert.NotNil(iv)
+	assert.NoError(err)
+	assert.False(iv.Tripped())
+}
+
+func testIntrospectionValidatorDecisions(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		current = time.Now()
+		server  = newIntrospectionServer(t, map[string]IntrospectionResponse{
+			"active":    {Active: true, Iss: "partner", Exp: current.Add(time.Hour).Unix()},
+			"noIssuer":  {Active: true},
+			"inactive":  {Active: false},
+			"expired":   {Active: true, Exp: current.Add(-time.Minute).Unix()},
+			"premature": {Active: true, Nbf: current.Add(time.Minute).Unix()},
+			"wrong":     {Active: true, Iss: "someone else"},
+		})
+	)
+
+	defer server.Close()
+
+	iv, err := NewIntrospectionValidator(IntrospectionOptions{
+		Endpoint:     server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Issuer:       "partner",
+		Cache: &ValidationCacheOptions{
+			TTL: time.Minute,
+			Now: func() time.Time { return current },
+		},
+	})
+
+	require.NoError(err)
+
+	testData := []struct {
+		value         string
+		expectedValid bool
+		expectedError error
+	}{
+		{"active", true, nil},
+		{"noIssuer", false, ErrorWrongIssuer},
+		{"inactive", false, ErrorTokenInactive},
+		{"expired", false, jwt.ErrTokenIsExpired},
+		{"premature", false, jwt.ErrTokenNotYetValid},
+		{"wrong", false, ErrorWrongIssuer},
+	}
+
+	for _, record := range testData {
+		valid, err := iv.Validate(context.Background(), &Token{tokenType: Bearer, value: record.value})
+		assert.Equal(record.expectedValid, valid, record.value)
+		assert.Equal(record.expectedError, err, record.value)
+	}
+
+	assert.Equal(len(testData), server.Requests())
+
+	// every decision except the premature token is remembered
+	for _, record := range testData {
+		valid, err := iv.Validate(context.Background(), &Token{tokenType: Bearer, value: record.value})
+		assert.Equal(record.expectedValid, valid, record.value)
+		assert.Equal(record.expectedError, err, record.value)
+	}
+
+	assert.Equal(len(testData)+1, server.Requests())
+
+	// cached decisions expire
+	current = current.Add(2 * time.Minute)
+	valid, err := iv.Validate(context.Background(), &Token{tokenType: Bearer, value: "active"})
+	assert.True(valid)
+	assert.NoError(err)
+	assert.Equal(len(testData)+2, server.Requests())
+
+	// only bearer tokens are introspected
+	valid, err = iv.Validate(context.Background(), &Token{tokenType: Basic, value: "active"})
+	assert.False(valid)
+	assert.NoError(err)
+	assert.Equal(len(testData)+2, server.Requests())
+}
+
+func testIntrospectionValidatorExpirationTTL(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		current = time.Now()
+		server  = newIntrospectionServer(t, map[string]IntrospectionResponse{
+			"active": {Active: true, Exp: current.Add(10 * time.Second).Unix()},
+		})
+	)
+
+	defer server.Close()
+
+	iv, err := NewIntrospectionValidator(IntrospectionOptions{
+		Endpoint:     server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Cache: &ValidationCacheOptions{
+			TTL: time.Hour,
+			Now: func() time.Time { return current },
+		},
+	})
+
+	require.NoError(err)
+
+	valid, err := iv.Validate(context.Background(), &Token{tokenType: Bearer, value: "active"})
+	assert.True(valid)
+	assert.NoError(err)
+
+	// the token's exp bounds the cache TTL
+	current = current.Add(time.Minute)
+	valid, err = iv.Validate(context.Background(), &Token{tokenType: Bearer, value: "active"})
+	assert.False(valid)
+	assert.Equal(jwt.ErrTokenIsExpired, err)
+	assert.Equal(2, server.Requests())
+}
+
+func testIntrospectionValidatorBreaker(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server = newIntrospectionServer(t, map[string]IntrospectionResponse{
+			"active": {Active: true},
+		})
+	)
+
+	defer server.Close()
+
+	iv, err := NewIntrospectionValidator(IntrospectionOptions{
+		Endpoint:         server.URL,
+		ClientID:         "client",
+		ClientSecret:     "secret",
+		BreakerThreshold: 2,
+	})
+
+	require.NoError(err)
+
+	server.SetStatus(http.StatusServiceUnavailable)
+	for i := 0; i < 2; i++ {
+		valid, err := iv.Validate(context.Background(), &Token{tokenType: Bearer, value: "active"})
+		assert.False(valid)
+		assert.Error(err)
+		assert.NotEqual(ErrorIntrospectionUnavailable, err)
+	}
+
+	assert.True(iv.Tripped())
+
+	// once tripped, the endpoint is not contacted
+	server.SetStatus(http.StatusOK)
+	valid, err := iv.Validate(context.Background(), &Token{tokenType: Bearer, value: "active"})
+	assert.False(valid)
+	assert.Equal(ErrorIntrospectionUnavailable, err)
+	assert.Equal(2, server.Requests())
+}
+
+func testIntrospectionValidatorBreakerCallerContext(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		blocking = func(request *http.Request) (*http.Response, error) {
+			<-request.Context().Done()
+			return nil, request.Context().Err()
+		}
+	)
+
+	iv, err := NewIntrospectionValidator(IntrospectionOptions{
+		Endpoint:         "http://introspection.example.com",
+		BreakerThreshold: 2,
+		Transactor:       blocking,
+	})
+
+	require.NoError(err)
+
+	// requests abandoned by the caller do not count against the endpoint
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		valid, err := iv.Validate(ctx, &Token{tokenType: Bearer, value: "active"})
+		cancel()
+
+		assert.False(valid)
+		assert.Equal(context.DeadlineExceeded, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		valid, err := iv.Validate(ctx, &Token{tokenType: Bearer, value: "active"})
+		assert.False(valid)
+		assert.Equal(context.Canceled, err)
+	}
+
+	assert.False(iv.Tripped())
+
+	// the validator's own timeout is a failure of the endpoint
+	iv, err = NewIntrospectionValidator(IntrospectionOptions{
+		Endpoint:         "http://introspection.example.com",
+		BreakerThreshold: 2,
+		Timeout:          time.Millisecond,
+		Transactor:       blocking,
+	})
+
+	require.NoError(err)
+	for i := 0; i < 2; i++ {
+		valid, err := iv.Validate(context.Background(), &Token{tokenType: Bearer, value: "active"})
+		assert.False(valid)
+		assert.Equal(context.DeadlineExceeded, err)
+	}
+
+	assert.True(iv.Tripped())
+}
+
+func testIntrospectionValidatorBadResponse(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.Write([]byte("this is not JSON"))
+		}))
+	)
+
+	defer server.Close()
+
+	iv, err := NewIntrospectionValidator(IntrospectionOptions{Endpoint: server.URL})
+	require.NoError(err)
+
+	valid, err := iv.Validate(context.Background(), &Token{tokenType: Bearer, value: "active"})
+	assert.False(valid)
+	assert.Error(err)
+}
+
+func TestIntrospectionValidator(t *testing.T) {
+	t.Run("Decisions", testIntrospectionValidatorDecisions)
+	t.Run("ExpirationTTL", testIntrospectionValidatorExpirationTTL)
+	t.Run("Breaker", testIntrospectionValidatorBreaker)
+	t.Run("BreakerCallerContext", testIntrospectionValidatorBreakerCallerContext)
+	t.Run("BadResponse", testIntrospectionValidatorBadResponse)
+}
+
+func TestIssuerValidator(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		ctx     = context.Background()
+
+		partner      = new(MockValidator)
+		defaultValid = new(MockValidator)
+		opaque       = new(MockValidator)
+
+		iv = IssuerValidator{
+			Issuers: map[string]Validator{"partner": partner},
+			Default: defaultValid,
+			Opaque:  opaque,
+		}
+	)
+
+	newJWT := func(issuer string) *Token {
+		claims := jws.Claims{}
+		claims.SetSubject("test")
+		if len(issuer) > 0 {
+			claims.SetIssuer(issuer)
+		}
+
+		compact, err := jws.NewJWT(claims, crypto.SigningMethodHS256).Serialize([]byte("key"))
+		require.NoError(err)
+		return &Token{tokenType: Bearer, value: string(compact)}
+	}
+
+	var (
+		partnerToken = newJWT("partner")
+		otherToken   = newJWT("other")
+		noIssuer     = newJWT("")
+		opaqueToken  = &Token{tokenType: Bearer, value: "opaque"}
+		basicToken   = &Token{tokenType: Basic, value: "dXNlcjpwYXNz"}
+	)
+
+	partner.On("Validate", ctx, partnerToken).Return(true, nil).Once()
+	defaultValid.On("Validate", ctx, otherToken).Return(false, nil).Once()
+	defaultValid.On("Validate", ctx, noIssuer).Return(true, nil).Once()
+	defaultValid.On("Validate", ctx, basicToken).Return(true, nil).Once()
+	opaque.On("Validate", ctx, opaqueToken).Return(false, ErrorTokenInactive).Once()
+
+	valid, err := iv.Validate(ctx, partnerToken)
+	assert.True(valid)
+	assert.NoError(err)
+
+	valid, err = iv.Validate(ctx, otherToken)
+	assert.False(valid)
+	assert.NoError(err)
+
+	valid, err = iv.Validate(ctx, noIssuer)
+	assert.True(valid)
+	assert.NoError(err)
+
+	valid, err = iv.Validate(ctx, basicToken)
+	assert.True(valid)
+	assert.NoError(err)
+
+	valid, err = iv.Validate(ctx, opaqueToken)
+	assert.False(valid)
+	assert.Equal(ErrorTokenInactive, err)
+
+	// missing validators reject tokens
+	valid, err = IssuerValidator{}.Validate(ctx, opaqueToken)
+	assert.False(valid)
+	assert.NoError(err)
+
+	mock.AssertExpectationsForObjects(t, partner, defaultValid, opaque)
+}
@@ -0,0 +1,6 @@
+/*
+Package ipfilter provides network-based access control for HTTP servers.  Requests are allowed or denied
+based on CIDR lists matched against the client IP address, which is resolved with xhttp.ClientIPResolver
+so that forwarding headers are only honored from trusted proxies.
+*/
+package ipfilter
@@ -0,0 +1,101 @@
+package ipfilter
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/go-kit/kit/log/level"
+)
+
+// Filter is a network-based access control policy
+type Filter struct {
+	allow            xhttp.Networks
+	deny             xhttp.Networks
+	resolver         *xhttp.ClientIPResolver
+	deniedStatusCode int
+}
+
+// New produces a Filter from a set of options.  If the options define no allow or deny rules,
+// this function returns a nil Filter, which permits all requests.
+func New(o *Options) (*Filter, error) {
+	if !o.enabled() {
+		return nil, nil
+	}
+
+	var (
+		f   = &Filter{deniedStatusCode: o.deniedStatusCode()}
+		err error
+	)
+
+	if f.allow, err = xhttp.ParseNetworks(o.allow()); err != nil {
+		return nil, err
+	}
+
+	if f.deny, err = xhttp.ParseNetworks(o.deny()); err != nil {
+		return nil, err
+	}
+
+	if f.resolver, err = NewClientIPResolver(o); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// NewClientIPResolver creates the xhttp.ClientIPResolver described by the given options' TrustedProxies and
+// ForwardingHeader.  Servers use this to resolve client IPs even when no filter is configured.
+func NewClientIPResolver(o *Options) (*xhttp.ClientIPResolver, error) {
+	return xhttp.NewClientIPResolver(o.forwardingHeader(), o.trustedProxies())
+}
+
+// Allowed tests if the given client IP is permitted by this filter.  A nil Filter allows all addresses.
+func (f *Filter) Allowed(ip net.IP) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.deny.Contains(ip) {
+		return false
+	}
+
+	return len(f.allow) == 0 || f.allow.Contains(ip)
+}
+
+// Then is an alice-style decorator that rejects requests from disallowed clients.  The client IP is resolved
+// with xhttp.ClientIP using this filter's trusted proxies, so allowed requests carry the client IP in their context.
+// Requests whose client IP cannot be resolved are rejected.  A nil Filter returns the next handler undecorated.
+func (f *Filter) Then(next http.Handler) http.Handler {
+	if f == nil {
+		return next
+	}
+
+	return xhttp.ClientIP(f.resolver)(
+		http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			ip, ok := xhttp.GetClientIP(request.Context())
+			if !ok {
+				logging.GetLogger(request.Context()).Log(
+					level.Key(), level.ErrorValue(),
+					logging.MessageKey(), "unable to determine client IP",
+					"remoteAddr", request.RemoteAddr,
+				)
+
+				response.WriteHeader(f.deniedStatusCode)
+				return
+			}
+
+			if !f.Allowed(ip) {
+				logging.GetLogger(request.Context()).Log(
+					level.Key(), level.InfoValue(),
+					logging.MessageKey(), "request denied by IP filter",
+				)
+
+				response.WriteHeader(f.deniedStatusCode)
+				return
+			}
+
+			next.ServeHTTP(response, request)
+		}),
+	)
+}
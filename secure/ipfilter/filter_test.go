@@ -0,0 +1,167 @@
+package ipfilter
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		f, err := New(nil)
+		assert.Nil(f)
+		assert.NoError(err)
+
+		f, err = New(&Options{TrustedProxies: []string{"10.0.0.1"}})
+		assert.Nil(f)
+		assert.NoError(err)
+
+		// a nil Filter allows everything
+		assert.True(f.Allowed(net.ParseIP("1.2.3.4")))
+	})
+
+	t.Run("InvalidAllow", func(t *testing.T) {
+		f, err := New(&Options{Allow: []string{"bad"}})
+		assert.Nil(t, f)
+		assert.Error(t, err)
+	})
+
+	t.Run("InvalidDeny", func(t *testing.T) {
+		f, err := New(&Options{Deny: []string{"bad"}})
+		assert.Nil(t, f)
+		assert.Error(t, err)
+	})
+
+	t.Run("InvalidTrustedProxies", func(t *testing.T) {
+		f, err := New(&Options{Deny: []string{"10.0.0.0/8"}, TrustedProxies: []string{"bad"}})
+		assert.Nil(t, f)
+		assert.Error(t, err)
+	})
+	t.Run("InvalidForwardingHeader", func(t *testing.T) {
+		f, err := New(&Options{Deny: []string{"10.0.0.0/8"}, ForwardingHeader: "X-Real-IP"})
+		assert.Nil(t, f)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewClientIPResolver(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	request.RemoteAddr = "172.16.0.1:1234"
+	request.Header.Set(xhttp.ForwardedHeader, "for=5.6.7.8")
+	request.Header.Set(xhttp.ForwardedForHeader, "1.2.3.4")
+
+	// X-Forwarded-For is honored by default
+	r, err := NewClientIPResolver(&Options{TrustedProxies: []string{"172.16.0.1"}})
+	require.NoError(err)
+	ip, err := r.Resolve(request)
+	require.NoError(err)
+	assert.Equal("1.2.3.4", ip.String())
+
+	r, err = NewClientIPResolver(&Options{TrustedProxies: []string{"172.16.0.1"}, ForwardingHeader: xhttp.ForwardedHeader})
+	require.NoError(err)
+	ip, err = r.Resolve(request)
+	require.NoError(err)
+	assert.Equal("5.6.7.8", ip.String())
+}
+
+func TestFilterAllowed(t *testing.T) {
+	var (
+		require = require.New(t)
+		f, err  = New(&Options{
+			Allow: []string{"10.0.0.0/8", "fd00::/8"},
+			Deny:  []string{"10.1.0.0/16"},
+		})
+
+		testData = []struct {
+			ip       string
+			expected bool
+		}{
+			{"10.0.0.1", true},
+			{"10.255.1.1", true},
+			{"10.1.2.3", false},
+			{"192.168.1.1", false},
+			{"fd00::1", true},
+			{"fe80::1", false},
+		}
+	)
+
+	require.NoError(err)
+	require.NotNil(f)
+	for _, record := range testData {
+		t.Run(record.ip, func(t *testing.T) {
+			assert.Equal(t, record.expected, f.Allowed(net.ParseIP(record.ip)))
+		})
+	}
+}
+
+func TestFilterThen(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		var (
+			f    *Filter
+			next = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+		)
+
+		assert.NotNil(t, f.Then(next))
+	})
+
+	var (
+		require = require.New(t)
+		f, err  = New(&Options{
+			Allow:            []string{"10.0.0.0/8"},
+			TrustedProxies:   []string{"172.16.0.1"},
+			DeniedStatusCode: 404,
+		})
+
+		testData = []struct {
+			remoteAddr         string
+			forwardedFor       string
+			expectedStatusCode int
+		}{
+			{"10.0.0.1:1234", "", 299},
+			{"192.168.1.1:1234", "", 404},
+			{"192.168.1.1:1234", "10.0.0.1", 404},
+			{"172.16.0.1:1234", "10.0.0.1", 299},
+			{"172.16.0.1:1234", "192.168.1.1", 404},
+			{"172.16.0.1:1234", "garbage", 404},
+		}
+	)
+
+	require.NoError(err)
+	require.NotNil(f)
+
+	handler := f.Then(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ip, ok := xhttp.GetClientIP(request.Context())
+		assert.True(t, ok)
+		assert.True(t, f.Allowed(ip))
+		response.WriteHeader(299)
+	}))
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				request  = httptest.NewRequest("GET", "/", nil)
+				response = httptest.NewRecorder()
+			)
+
+			request.RemoteAddr = record.remoteAddr
+			if len(record.forwardedFor) > 0 {
+				request.Header.Set(xhttp.ForwardedForHeader, record.forwardedFor)
+			}
+
+			handler.ServeHTTP(response, request)
+			assert.Equal(t, record.expectedStatusCode, response.Code)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package ipfilter
+
+import (
+	"net/http"
+
+	"github.com/Comcast/webpa-common/xhttp"
+)
+
+// Options describes the configuration of an IP filter.  Each list entry is either a CIDR, e.g. "10.0.0.0/8",
+// or a single IP address, e.g. "192.168.1.1", which is treated as a host CIDR.  This type is typically
+// unmarshalled from external configuration.
+type Options struct {
+	// Allow is the set of networks from which requests are permitted.  If empty, all networks are permitted
+	// unless denied.
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny is the set of networks from which requests are rejected.  Deny takes precedence over Allow.
+	Deny []string `json:"deny,omitempty"`
+
+	// TrustedProxies is the set of networks whose ForwardingHeader is honored when resolving the client IP.
+	// If empty, forwarding headers are ignored and the client IP is always the peer's address.
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+
+	// ForwardingHeader is the one header, either "Forwarded" or "X-Forwarded-For", that is honored from trusted
+	// proxies.  It must be the header those proxies write, since clients can send either.  If unset,
+	// X-Forwarded-For is used.
+	ForwardingHeader string `json:"forwardingHeader,omitempty"`
+
+	// DeniedStatusCode is the HTTP status code returned for rejected requests.  If unset, http.StatusForbidden is used.
+	DeniedStatusCode int `json:"deniedStatusCode,omitempty"`
+}
+
+func (o *Options) allow() []string {
+	if o != nil {
+		return o.Allow
+	}
+
+	return nil
+}
+
+func (o *Options) deny() []string {
+	if o != nil {
+		return o.Deny
+	}
+
+	return nil
+}
+
+func (o *Options) trustedProxies() []string {
+	if o != nil {
+		return o.TrustedProxies
+	}
+
+	return nil
+}
+
+func (o *Options) forwardingHeader() string {
+	if o != nil && len(o.ForwardingHeader) > 0 {
+		return o.ForwardingHeader
+	}
+
+	return xhttp.ForwardedForHeader
+}
+
+func (o *Options) deniedStatusCode() int {
+	if o != nil && o.DeniedStatusCode > 0 {
+		return o.DeniedStatusCode
+	}
+
+	return http.StatusForbidden
+}
+
+// enabled tests if these options define any access rules.  Trusted proxies alone do not constitute rules.
+func (o *Options) enabled() bool {
+	return len(o.allow()) > 0 || len(o.deny()) > 0
+}
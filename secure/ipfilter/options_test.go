@@ -0,0 +1,50 @@
+package ipfilter
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testOptionsDefault(t *testing.T, o *Options) {
+	assert := assert.New(t)
+
+	assert.Empty(o.allow())
+	assert.Empty(o.deny())
+	assert.Empty(o.trustedProxies())
+	assert.Equal(xhttp.ForwardedForHeader, o.forwardingHeader())
+	assert.Equal(http.StatusForbidden, o.deniedStatusCode())
+	assert.False(o.enabled())
+}
+
+func testOptionsCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		o      = Options{
+			Allow:            []string{"10.0.0.0/8"},
+			Deny:             []string{"10.1.0.0/16"},
+			TrustedProxies:   []string{"172.16.0.1"},
+			ForwardingHeader: xhttp.ForwardedHeader,
+			DeniedStatusCode: 404,
+		}
+	)
+
+	assert.Equal([]string{"10.0.0.0/8"}, o.allow())
+	assert.Equal([]string{"10.1.0.0/16"}, o.deny())
+	assert.Equal([]string{"172.16.0.1"}, o.trustedProxies())
+	assert.Equal(xhttp.ForwardedHeader, o.forwardingHeader())
+	assert.Equal(404, o.deniedStatusCode())
+	assert.True(o.enabled())
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testOptionsDefault(t, nil)
+		testOptionsDefault(t, new(Options))
+		testOptionsDefault(t, &Options{TrustedProxies: nil})
+	})
+
+	t.Run("Custom", testOptionsCustom)
+}
@@ -2,6 +2,9 @@ package key
 
 import (
 	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -187,32 +190,33 @@ func (cache *multiCache) UpdateKeys() (count int, errors []error) {
 // method returns a non-nil function that will spawn a goroutine to update
 // the cache in the background.  Otherwise, this method returns nil.
 func NewUpdater(updateInterval time.Duration, resolver Resolver) (updater concurrent.Runnable) {
+	return NewUpdaterWithLogger(updateInterval, resolver, nil)
+}
+
+// NewUpdaterWithLogger is like NewUpdater, except that errors encountered while updating keys
+// are logged to the given logger.  If logger is nil, logging.DefaultLogger() is used.
+func NewUpdaterWithLogger(updateInterval time.Duration, resolver Resolver, logger log.Logger) (updater concurrent.Runnable) {
 	if updateInterval < 1 {
 		return
 	}
 
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
 	if keyCache, ok := resolver.(Cache); ok {
-		updater = concurrent.RunnableFunc(func(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
-			waitGroup.Add(1)
-
-			go func() {
-				defer waitGroup.Done()
-
-				ticker := time.NewTicker(updateInterval)
-				defer ticker.Stop()
-
-				for {
-					select {
-					case <-shutdown:
-						return
-					case <-ticker.C:
-						keyCache.UpdateKeys()
-					}
+		updater = concurrent.NewScheduler(
+			concurrent.ScheduleOptions{
+				Name:   "key.updater",
+				Logger: logger,
+				Period: updateInterval,
+			},
+			func() {
+				if _, errors := keyCache.UpdateKeys(); len(errors) > 0 {
+					logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to update keys", "errors", errors)
 				}
-			}()
-
-			return nil
-		})
+			},
+		)
 	}
 
 	return
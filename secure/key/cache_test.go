@@ -3,6 +3,7 @@ package key
 import (
 	"errors"
 	"fmt"
+	"github.com/go-kit/kit/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"sync"
@@ -451,3 +452,51 @@ func TestNewUpdater(t *testing.T) {
 		waitGroup.Wait()
 	}
 }
+
+func TestNewUpdaterWithLogger(t *testing.T) {
+	assert := assert.New(t)
+
+	keyCache := &MockCache{}
+	keyCache.On("UpdateKeys").Return(0, []error{errors.New("expected")})
+
+	logged := make(chan []interface{}, 10)
+	logger := log.LoggerFunc(func(keyvals ...interface{}) error {
+		select {
+		case logged <- keyvals:
+		default:
+		}
+
+		return nil
+	})
+
+	if updater := NewUpdaterWithLogger(100*time.Millisecond, keyCache, logger); assert.NotNil(updater) {
+		waitGroup := &sync.WaitGroup{}
+		shutdown := make(chan struct{})
+		updater.Run(waitGroup, shutdown)
+
+		// the update errors must be reported to the supplied logger
+		timeout := time.After(5 * time.Second)
+		for found := false; !found; {
+			select {
+			case keyvals := <-logged:
+				found = containsValue(keyvals, "unable to update keys")
+			case <-timeout:
+				assert.Fail("the update errors were not logged")
+				found = true
+			}
+		}
+
+		close(shutdown)
+		waitGroup.Wait()
+	}
+}
+
+func containsValue(keyvals []interface{}, value interface{}) bool {
+	for _, v := range keyvals {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
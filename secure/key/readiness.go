@@ -0,0 +1,12 @@
+package key
+
+import "github.com/Comcast/webpa-common/health"
+
+// ReadinessCheck produces a health.Checker that attempts to resolve the given keyID.  When r is a Cache,
+// this has the effect of warming the cache, so that readiness is not reported until the key is available.
+func ReadinessCheck(r Resolver, keyID string) health.Checker {
+	return health.CheckerFunc(func() error {
+		_, err := r.ResolveKey(keyID)
+		return err
+	})
+}
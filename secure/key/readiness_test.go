@@ -0,0 +1,33 @@
+package key
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadinessCheck(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedError = errors.New("expected")
+		resolver      = new(MockResolver)
+		pair          = new(MockPair)
+
+		check = ReadinessCheck(resolver, "test")
+	)
+
+	require.NotNil(check)
+
+	resolver.On("ResolveKey", "test").Return(nil, expectedError).Once()
+	assert.Equal(expectedError, check.Check())
+
+	resolver.On("ResolveKey", "test").Return(pair, nil).Once()
+	assert.NoError(check.Check())
+
+	resolver.AssertExpectations(t)
+	pair.AssertExpectations(t)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/Comcast/webpa-common/concurrent"
 	"github.com/Comcast/webpa-common/resource"
 	"github.com/Comcast/webpa-common/types"
+	"github.com/go-kit/kit/log"
 	"time"
 )
 
@@ -43,6 +44,10 @@ type ResolverFactory struct {
 
 	// Parser is a custom key parser.  If omitted, DefaultParser is used.
 	Parser Parser `json:"-"`
+
+	// Logger is used by updaters to report errors while refreshing keys.  If omitted,
+	// logging.DefaultLogger() is used.
+	Logger log.Logger `json:"-"`
 }
 
 func (factory *ResolverFactory) parser() Parser {
@@ -102,5 +107,5 @@ func (factory *ResolverFactory) NewResolver() (Resolver, error) {
 // for the given resolver.  This method delegates to the NewUpdater function, and may
 // return a nil Runnable if no updates are necessary.
 func (factory *ResolverFactory) NewUpdater(resolver Resolver) concurrent.Runnable {
-	return NewUpdater(time.Duration(factory.UpdateInterval), resolver)
+	return NewUpdaterWithLogger(time.Duration(factory.UpdateInterval), resolver, factory.Logger)
 }
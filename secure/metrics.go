@@ -4,16 +4,18 @@ import (
 	"github.com/Comcast/webpa-common/xmetrics"
 	"github.com/go-kit/kit/metrics"
 	gokitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/go-kit/kit/metrics/provider"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-//Names for our metrics
+// Names for our metrics
 const (
 	JWTValidationReasonCounter = "jwt_validation_reason"
 	NBFHistogram               = "jwt_from_nbf_seconds"
 	EXPHistogram               = "jwt_from_exp_seconds"
 )
 
-//Metrics returns the Metrics relevant to this package
+// Metrics returns the Metrics relevant to this package
 func Metrics() []xmetrics.Metric {
 	return []xmetrics.Metric{
 		xmetrics.Metric{
@@ -37,18 +39,34 @@ func Metrics() []xmetrics.Metric {
 	}
 }
 
-//JWTValidationMeasures describes the defined metrics that will be used by clients
+// JWTValidationMeasures describes the defined metrics that will be used by clients
 type JWTValidationMeasures struct {
 	NBFHistogram     *gokitprometheus.Histogram
 	ExpHistogram     *gokitprometheus.Histogram
 	ValidationReason metrics.Counter
 }
 
-//NewJWTValidationMeasures realizes desired metrics
-func NewJWTValidationMeasures(r xmetrics.Registry) *JWTValidationMeasures {
+// NewJWTValidationMeasures realizes desired metrics using the given provider, which is typically an
+// xmetrics.Registry.  If p is nil, the metrics are discarded.  The histograms are prometheus histograms,
+// so they are only reported when p is an xmetrics.PrometheusProvider; otherwise they are discarded.
+func NewJWTValidationMeasures(p provider.Provider) *JWTValidationMeasures {
+	if p == nil {
+		p = provider.NewDiscardProvider()
+	}
+
 	return &JWTValidationMeasures{
-		NBFHistogram:     gokitprometheus.NewHistogram(r.NewHistogramVec(NBFHistogram)),
-		ExpHistogram:     gokitprometheus.NewHistogram(r.NewHistogramVec(EXPHistogram)),
-		ValidationReason: r.NewCounter(JWTValidationReasonCounter),
+		NBFHistogram:     newHistogram(p, NBFHistogram),
+		ExpHistogram:     newHistogram(p, EXPHistogram),
+		ValidationReason: p.NewCounter(JWTValidationReasonCounter),
+	}
+}
+
+// newHistogram creates a prometheus histogram with the given provider.  If the provider is not an
+// xmetrics.PrometheusProvider, the histogram is not registered anywhere.
+func newHistogram(p provider.Provider, name string) *gokitprometheus.Histogram {
+	if pp, ok := p.(xmetrics.PrometheusProvider); ok {
+		return gokitprometheus.NewHistogram(pp.NewHistogramVec(name))
 	}
+
+	return gokitprometheus.NewHistogram(prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name}, []string{}))
 }
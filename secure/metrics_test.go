@@ -15,3 +15,13 @@ func TestSimpleRun(t *testing.T) {
 	assert := assert.New(t)
 	assert.NotNil(newTestJWTValidationMeasure())
 }
+
+func TestNewJWTValidationMeasuresNilProvider(t *testing.T) {
+	assert := assert.New(t)
+	m := NewJWTValidationMeasures(nil)
+	if assert.NotNil(m) {
+		m.NBFHistogram.Observe(1.0)
+		m.ExpHistogram.Observe(1.0)
+		m.ValidationReason.With("reason", "test").Add(1.0)
+	}
+}
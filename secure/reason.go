@@ -0,0 +1,99 @@
+package secure
+
+import (
+	"crypto/rsa"
+	"errors"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/SermoDigital/jose/jwt"
+)
+
+// FailureReasonHeader is the HTTP response header which carries the FailureReason for a rejected request
+const FailureReasonHeader = "X-Webpa-Auth-Failure"
+
+// FailureReason is a standardized reason why a request failed authentication or authorization.  Reasons are
+// used as the reason label of the JWTValidationReasonCounter and are returned to clients in FailureReasonHeader,
+// so that authorization problems can be diagnosed without access to server logs.
+type FailureReason string
+
+const (
+	// ReasonMissingHeader indicates that the request had no authorization header
+	ReasonMissingHeader FailureReason = "missing_header"
+
+	// ReasonInvalidHeader indicates that the authorization header could not be parsed
+	ReasonInvalidHeader FailureReason = "invalid_header"
+
+	// ReasonExpired indicates that the token has expired
+	ReasonExpired FailureReason = "expired_token"
+
+	// ReasonPremature indicates that the token is not yet valid
+	ReasonPremature FailureReason = "premature_token"
+
+	// ReasonBadSignature indicates that the token was malformed or its signature could not be verified
+	ReasonBadSignature FailureReason = "invalid_signature"
+
+	// ReasonMissingCapability indicates that the token did not carry the capabilities required for the request
+	ReasonMissingCapability FailureReason = "missing_capability"
+
+	// ReasonWrongAudience indicates that the token was not issued for this service
+	ReasonWrongAudience FailureReason = "wrong_audience"
+
+	// ReasonRevoked indicates that the token has been revoked
+	ReasonRevoked FailureReason = "revoked"
+
+	// ReasonReplayed indicates that a single-use token has already been used
+	ReasonReplayed FailureReason = "replayed"
+
+	// ReasonDenied is the reason for any other failure, including a validator that rejects a token without an error
+	ReasonDenied FailureReason = "denied"
+)
+
+// ErrorMissingCapability is returned when a token carries no capabilities
+var ErrorMissingCapability = errors.New("The token has no capabilities")
+
+// badSignatureErrors are the errors which indicate a malformed token or a signature that could not be verified
+var badSignatureErrors = []error{
+	ErrorNoProtectedHeader, ErrorNoSigningMethod, ErrorInvalidToken,
+	rsa.ErrVerification, crypto.ErrECDSAVerification, crypto.ErrSignatureInvalid,
+	jws.ErrNotCompact, jws.ErrIsNotJWT, jws.ErrCannotValidate, jws.ErrDidNotValidate,
+}
+
+// ReasonFor classifies a validation error into a FailureReason.  Wrapped errors are classified by the errors they
+// wrap.  Errors which are not recognized, including a nil error from a Validator that simply rejected a token,
+// are classified as ReasonDenied.
+func ReasonFor(err error) FailureReason {
+	switch {
+	case err == nil:
+		return ReasonDenied
+
+	case errors.Is(err, ErrorMissingAuthorization):
+		return ReasonMissingHeader
+
+	case errors.Is(err, jwt.ErrTokenIsExpired):
+		return ReasonExpired
+
+	case errors.Is(err, jwt.ErrTokenNotYetValid):
+		return ReasonPremature
+
+	case errors.Is(err, jwt.ErrInvalidAUDClaim):
+		return ReasonWrongAudience
+
+	case errors.Is(err, ErrorTokenRevoked):
+		return ReasonRevoked
+
+	case errors.Is(err, ErrorTokenReplayed):
+		return ReasonReplayed
+
+	case errors.Is(err, ErrorMissingCapability):
+		return ReasonMissingCapability
+	}
+
+	for _, target := range badSignatureErrors {
+		if errors.Is(err, target) {
+			return ReasonBadSignature
+		}
+	}
+
+	return ReasonDenied
+}
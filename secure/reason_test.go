@@ -0,0 +1,46 @@
+package secure
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/SermoDigital/jose/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReasonFor(t *testing.T) {
+	testData := []struct {
+		err      error
+		expected FailureReason
+	}{
+		{nil, ReasonDenied},
+		{errors.New("unrecognized"), ReasonDenied},
+		{ErrorMissingAuthorization, ReasonMissingHeader},
+		{jwt.ErrTokenIsExpired, ReasonExpired},
+		{jwt.ErrTokenNotYetValid, ReasonPremature},
+		{jwt.ErrInvalidAUDClaim, ReasonWrongAudience},
+		{ErrorTokenRevoked, ReasonRevoked},
+		{ErrorTokenReplayed, ReasonReplayed},
+		{ErrorMissingCapability, ReasonMissingCapability},
+		{ErrorNoProtectedHeader, ReasonBadSignature},
+		{ErrorNoSigningMethod, ReasonBadSignature},
+		{ErrorInvalidToken, ReasonBadSignature},
+		{rsa.ErrVerification, ReasonBadSignature},
+		{crypto.ErrECDSAVerification, ReasonBadSignature},
+		{crypto.ErrSignatureInvalid, ReasonBadSignature},
+		{jws.ErrNotCompact, ReasonBadSignature},
+		{fmt.Errorf("wrapped: %w", ErrorTokenRevoked), ReasonRevoked},
+		{fmt.Errorf("wrapped: %w", jwt.ErrTokenIsExpired), ReasonExpired},
+		{fmt.Errorf("wrapped: %w", rsa.ErrVerification), ReasonBadSignature},
+	}
+
+	for _, record := range testData {
+		t.Run(fmt.Sprintf("%s/%v", record.expected, record.err), func(t *testing.T) {
+			assert.Equal(t, record.expected, ReasonFor(record.err))
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package secure
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/store"
+	"github.com/SermoDigital/jose/jwt"
+)
+
+const (
+	// DefaultNonceKeyPrefix is the prefix for the store.KV keys that record used nonces
+	DefaultNonceKeyPrefix = "nonce/"
+
+	// DefaultNonceTTL is how long a nonce is remembered when its lifetime is unknown, e.g. a JWT with no exp claim
+	DefaultNonceTTL = time.Hour
+
+	// DefaultNonceMaxEntries is the maximum number of nonces held by the in-memory store
+	DefaultNonceMaxEntries = 100000
+)
+
+var (
+	// ErrorReplayedNonce is returned when a nonce has already been used
+	ErrorReplayedNonce = errors.New("The nonce has already been used")
+
+	// ErrorTokenReplayed is returned when a token's jti has already been used
+	ErrorTokenReplayed = errors.New("The token has already been used")
+)
+
+// NonceCacheOptions configures a NonceCache
+type NonceCacheOptions struct {
+	// KV is the store which records used nonces.  Using a shared store, such as one created with store.NewConsulKV,
+	// detects replays across all instances of a service.  If unset, an in-memory store is used.
+	KV store.KV
+
+	// KeyPrefix is prepended to each nonce in the KV.  If unset, DefaultNonceKeyPrefix is used.
+	KeyPrefix string
+
+	// MaxEntries is the maximum number of nonces held by the in-memory store.  When this limit is reached,
+	// the least recently used nonces are forgotten early, and can be replayed.  If nonpositive,
+	// DefaultNonceMaxEntries is used.  This field is ignored when KV is set.
+	MaxEntries int
+
+	// DefaultTTL is how long a nonce with no known lifetime is remembered.  If nonpositive, DefaultNonceTTL is used.
+	DefaultTTL time.Duration
+
+	// MaxTTL, if positive, caps how long any nonce is remembered.  This bounds the size of the store when tokens
+	// are long-lived, at the cost of allowing replays once the cap has passed.
+	MaxTTL time.Duration
+
+	// Now is the source of the current time.  If unset, time.Now is used.
+	Now func() time.Time
+}
+
+func (o *NonceCacheOptions) now() func() time.Time {
+	if o != nil && o.Now != nil {
+		return o.Now
+	}
+
+	return time.Now
+}
+
+func (o *NonceCacheOptions) kv() store.KV {
+	if o != nil && o.KV != nil {
+		return o.KV
+	}
+
+	maxEntries := DefaultNonceMaxEntries
+	if o != nil && o.MaxEntries > 0 {
+		maxEntries = o.MaxEntries
+	}
+
+	return store.NewMemoryKV(store.MemoryKVOptions{MaxEntries: maxEntries, Now: o.now()})
+}
+
+func (o *NonceCacheOptions) keyPrefix() string {
+	if o != nil && len(o.KeyPrefix) > 0 {
+		return o.KeyPrefix
+	}
+
+	return DefaultNonceKeyPrefix
+}
+
+func (o *NonceCacheOptions) defaultTTL() time.Duration {
+	if o != nil && o.DefaultTTL > 0 {
+		return o.DefaultTTL
+	}
+
+	return DefaultNonceTTL
+}
+
+func (o *NonceCacheOptions) maxTTL() time.Duration {
+	if o != nil && o.MaxTTL > 0 {
+		return o.MaxTTL
+	}
+
+	return 0
+}
+
+// NonceCache remembers nonces, such as JWT jti claims, for as long as the credential carrying them is valid,
+// so that each can be used only once.  Nonces are stored under a SHA-256 hash, so that arbitrary nonces are safe
+// to use as keys in any store and the store never holds the nonces themselves.
+//
+// Checking and recording a nonce is atomic within a process, and only uses of the same nonce wait on each other.
+// With a shared store, two instances which receive the same nonce at nearly the same moment may both accept it,
+// as store.KV offers no compare-and-set.
+type NonceCache struct {
+	locks      concurrent.KeyedMutex
+	kv         store.KV
+	keyPrefix  string
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+	now        func() time.Time
+}
+
+// NewNonceCache creates a NonceCache from a set of options.  A nil options produces an in-memory cache with defaults.
+func NewNonceCache(o *NonceCacheOptions) *NonceCache {
+	return &NonceCache{
+		kv:         o.kv(),
+		keyPrefix:  o.keyPrefix(),
+		defaultTTL: o.defaultTTL(),
+		maxTTL:     o.maxTTL(),
+		now:        o.now(),
+	}
+}
+
+// Use records a nonce as used for the given ttl, which should be the remaining lifetime of the credential
+// carrying the nonce.  If ttl is nonpositive, the cache's default TTL is used.  If the nonce has already
+// been used, ErrorReplayedNonce is returned.  Any error from the store is returned as is, in which case
+// the caller should reject the credential.
+func (nc *NonceCache) Use(nonce string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = nc.defaultTTL
+	}
+
+	if nc.maxTTL > 0 && ttl > nc.maxTTL {
+		ttl = nc.maxTTL
+	}
+
+	var (
+		hash = sha256.Sum256([]byte(nonce))
+		key  = nc.keyPrefix + hex.EncodeToString(hash[:])
+	)
+
+	nc.locks.Lock(key)
+	defer nc.locks.Unlock(key)
+
+	switch _, err := nc.kv.Get(key); err {
+	case nil:
+		return ErrorReplayedNonce
+
+	case store.ErrNotFound:
+		return nc.kv.Put(key, []byte(nc.now().UTC().Format(time.RFC3339)), ttl)
+
+	default:
+		return err
+	}
+}
+
+// SingleUseClaim produces a ReplayValidator.SingleUse strategy which selects tokens whose claim with the given
+// name is the boolean true
+func SingleUseClaim(name string) func(jwt.Claims) bool {
+	return func(claims jwt.Claims) bool {
+		value, ok := claims.Get(name).(bool)
+		return ok && value
+	}
+}
+
+// ReplayValidator decorates a Validator so that each single-use bearer token can be used only once.  A token's
+// jti is remembered until the token expires, so that the size of the cache tracks the number of live tokens.
+// Tokens without a jti claim are not tracked.
+//
+// This is intended for single-use tokens, such as those minted for a single request.  As with RevokingValidator,
+// when combined with a CachingValidator, the CachingValidator should be the one decorated.
+type ReplayValidator struct {
+	Validator Validator
+	Nonces    *NonceCache
+
+	// SingleUse selects the tokens which may be used only once.  Many issuers put a jti in every token, including
+	// tokens that are meant to be reused until they expire, so replay protection is opt-in:  if SingleUse is nil,
+	// no tokens are tracked.  See SingleUseClaim.
+	SingleUse func(jwt.Claims) bool
+
+	// ExpLeeway is the leeway allowed past a token's exp claim by the decorated Validator.  A jti is remembered
+	// for this much longer than its token's expiry.
+	ExpLeeway time.Duration
+}
+
+func (rv ReplayValidator) Validate(ctx context.Context, token *Token) (bool, error) {
+	valid, err := rv.Validator.Validate(ctx, token)
+	if !valid || err != nil || token.Type() != Bearer || rv.SingleUse == nil {
+		return valid, err
+	}
+
+	jwsToken, err := DefaultJWSParser.ParseJWS(token)
+	if err != nil {
+		return false, err
+	}
+
+	jwtToken, ok := jwsToken.(jwt.JWT)
+	if !ok {
+		return true, nil
+	}
+
+	claims := jwtToken.Claims()
+	if !rv.SingleUse(claims) {
+		return true, nil
+	}
+
+	jti, ok := claims.JWTID()
+	if !ok || len(jti) == 0 {
+		return true, nil
+	}
+
+	var ttl time.Duration
+	if exp, ok := claims.Expiration(); ok {
+		if ttl = exp.Add(rv.ExpLeeway).Sub(rv.Nonces.now()); ttl < time.Second {
+			// the decorated Validator accepted a token at the very end of its life
+			ttl = time.Second
+		}
+	}
+
+	switch err := rv.Nonces.Use(jti, ttl); err {
+	case nil:
+		return true, nil
+
+	case ErrorReplayedNonce:
+		return false, ErrorTokenReplayed
+
+	default:
+		return false, err
+	}
+}
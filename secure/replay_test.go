@@ -0,0 +1,245 @@
+package secure
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/store"
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/SermoDigital/jose/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingKV is a store.KV whose Get always fails
+type failingKV struct {
+	store.KV
+	err error
+}
+
+func (f failingKV) Get(string) ([]byte, error) {
+	return nil, f.err
+}
+
+func TestNonceCacheOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		for _, o := range []*NonceCacheOptions{nil, new(NonceCacheOptions)} {
+			assert := assert.New(t)
+			assert.NotNil(o.now())
+			assert.NotNil(o.kv())
+			assert.Equal(DefaultNonceKeyPrefix, o.keyPrefix())
+			assert.Equal(DefaultNonceTTL, o.defaultTTL())
+			assert.Zero(o.maxTTL())
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			kv     = store.NewMemoryKV(store.MemoryKVOptions{})
+			o      = NonceCacheOptions{
+				KV:         kv,
+				KeyPrefix:  "custom/",
+				DefaultTTL: time.Minute,
+				MaxTTL:     time.Hour,
+			}
+		)
+
+		assert.Equal(kv, o.kv())
+		assert.Equal("custom/", o.keyPrefix())
+		assert.Equal(time.Minute, o.defaultTTL())
+		assert.Equal(time.Hour, o.maxTTL())
+	})
+}
+
+func TestNonceCache(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Now()
+		kv      = store.NewMemoryKV(store.MemoryKVOptions{Now: func() time.Time { return now }})
+		nc      = NewNonceCache(&NonceCacheOptions{
+			KV:         kv,
+			DefaultTTL: time.Minute,
+			MaxTTL:     time.Hour,
+			Now:        func() time.Time { return now },
+		})
+	)
+
+	require.NoError(nc.Use("first", 0))
+	assert.Equal(ErrorReplayedNonce, nc.Use("first", 0))
+
+	// nonces are stored under their hash
+	hash := sha256.Sum256([]byte("first"))
+	_, err := kv.Get(DefaultNonceKeyPrefix + hex.EncodeToString(hash[:]))
+	assert.NoError(err)
+
+	_, err = kv.Get(DefaultNonceKeyPrefix + "first")
+	assert.Equal(store.ErrNotFound, err)
+
+	require.NoError(nc.Use("second", 24*time.Hour))
+
+	// the default TTL applies to "first", while the max TTL applies to "second"
+	now = now.Add(2 * time.Minute)
+	assert.NoError(nc.Use("first", 0))
+	assert.Equal(ErrorReplayedNonce, nc.Use("second", 0))
+
+	now = now.Add(time.Hour)
+	assert.NoError(nc.Use("second", 0))
+
+	expectedError := errors.New("expected")
+	nc = NewNonceCache(&NonceCacheOptions{KV: failingKV{KV: kv, err: expectedError}})
+	assert.Equal(expectedError, nc.Use("third", 0))
+}
+
+func TestNonceCacheConcurrentUse(t *testing.T) {
+	const goroutines = 20
+
+	var (
+		assert = assert.New(t)
+		nc     = NewNonceCache(nil)
+
+		waitGroup sync.WaitGroup
+		accepted  int32
+	)
+
+	waitGroup.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func(i int) {
+			defer waitGroup.Done()
+
+			// every goroutine uses the shared nonce, along with a nonce of its own
+			if nc.Use("shared", 0) == nil {
+				atomic.AddInt32(&accepted, 1)
+			}
+
+			assert.NoError(nc.Use(strconv.Itoa(i), 0))
+		}(i)
+	}
+
+	waitGroup.Wait()
+	assert.Equal(int32(1), accepted)
+}
+
+func TestSingleUseClaim(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		singleUse = SingleUseClaim("single_use")
+	)
+
+	assert.True(singleUse(jwt.Claims{"single_use": true}))
+	assert.False(singleUse(jwt.Claims{"single_use": false}))
+	assert.False(singleUse(jwt.Claims{"single_use": "true"}))
+	assert.False(singleUse(jwt.Claims{}))
+}
+
+func TestReplayValidator(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		ctx     = context.Background()
+		now     = time.Now()
+
+		validator = new(MockValidator)
+		rv        = ReplayValidator{
+			Validator: validator,
+			Nonces:    NewNonceCache(&NonceCacheOptions{Now: func() time.Time { return now }}),
+			SingleUse: SingleUseClaim("single_use"),
+			ExpLeeway: time.Minute,
+		}
+	)
+
+	pair, err := privateKeyResolver.ResolveKey("")
+	require.NoError(err)
+
+	newToken := func(jti string, exp time.Time) *Token {
+		claims := jws.Claims{"valid": true, "single_use": jti != "reusable"}
+		if len(jti) > 0 {
+			claims.SetJWTID(jti)
+		}
+
+		if !exp.IsZero() {
+			claims.SetExpiration(exp)
+		}
+
+		serialized, err := jws.NewJWT(claims, crypto.SigningMethodRS256).Serialize(pair.Private())
+		require.NoError(err)
+
+		return &Token{tokenType: Bearer, value: string(serialized)}
+	}
+
+	var (
+		single        = newToken("single", now.Add(time.Minute))
+		noExpiration  = newToken("noExpiration", time.Time{})
+		noJTI         = newToken("", now.Add(time.Minute))
+		reusable      = newToken("reusable", now.Add(time.Minute))
+		basic         = &Token{tokenType: Basic, value: "dXNlcjpwYXNzd29yZA=="}
+		rejected      = newToken("rejected", now.Add(time.Minute))
+		expectedError = errors.New("expected")
+	)
+
+	validator.On("Validate", ctx, single).Return(true, error(nil)).Times(3)
+	validator.On("Validate", ctx, noExpiration).Return(true, error(nil)).Twice()
+	validator.On("Validate", ctx, noJTI).Return(true, error(nil)).Twice()
+	validator.On("Validate", ctx, reusable).Return(true, error(nil)).Times(3)
+	validator.On("Validate", ctx, basic).Return(true, error(nil)).Twice()
+	validator.On("Validate", ctx, rejected).Return(false, expectedError).Twice()
+
+	valid, err := rv.Validate(ctx, single)
+	assert.True(valid)
+	assert.NoError(err)
+
+	valid, err = rv.Validate(ctx, single)
+	assert.False(valid)
+	assert.Equal(ErrorTokenReplayed, err)
+
+	valid, err = rv.Validate(ctx, noExpiration)
+	assert.True(valid)
+	assert.NoError(err)
+
+	valid, err = rv.Validate(ctx, noExpiration)
+	assert.False(valid)
+	assert.Equal(ErrorTokenReplayed, err)
+
+	for i := 0; i < 2; i++ {
+		valid, err = rv.Validate(ctx, noJTI)
+		assert.True(valid)
+		assert.NoError(err)
+
+		// only single-use tokens are tracked, even if others have a jti
+		valid, err = rv.Validate(ctx, reusable)
+		assert.True(valid)
+		assert.NoError(err)
+
+		valid, err = rv.Validate(ctx, basic)
+		assert.True(valid)
+		assert.NoError(err)
+
+		// rejected tokens never consume their jti
+		valid, err = rv.Validate(ctx, rejected)
+		assert.False(valid)
+		assert.Equal(expectedError, err)
+	}
+
+	// the jti is remembered until the token expires, plus the leeway
+	now = now.Add(time.Minute + 30*time.Second)
+	valid, err = rv.Validate(ctx, single)
+	assert.False(valid)
+	assert.Equal(ErrorTokenReplayed, err)
+
+	// without a SingleUse strategy, replay protection is off
+	rv.SingleUse = nil
+	valid, err = rv.Validate(ctx, reusable)
+	assert.True(valid)
+	assert.NoError(err)
+
+	validator.AssertExpectations(t)
+}
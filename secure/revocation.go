@@ -0,0 +1,53 @@
+package secure
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SermoDigital/jose/jwt"
+)
+
+// ErrorTokenRevoked is returned when a token's jti or subject has been revoked
+var ErrorTokenRevoked = errors.New("The token has been revoked")
+
+// Revoker describes the behavior of a type which knows which JWTs have been revoked before their natural
+// expiry.  *revocation.Cache implements this interface.
+type Revoker interface {
+	Revoked(jti, subject string) bool
+}
+
+// RevokingValidator decorates a Validator so that bearer tokens whose jti or sub claim have been revoked
+// are rejected, even if the decorated Validator accepts them.
+//
+// Revocations are checked after the decorated Validator.  When combined with a CachingValidator, the
+// CachingValidator should be the one decorated, so that cached decisions cannot bypass revocations.
+type RevokingValidator struct {
+	Validator Validator
+	Revoker   Revoker
+}
+
+func (rv RevokingValidator) Validate(ctx context.Context, token *Token) (bool, error) {
+	valid, err := rv.Validator.Validate(ctx, token)
+	if !valid || err != nil || token.Type() != Bearer {
+		return valid, err
+	}
+
+	jwsToken, err := DefaultJWSParser.ParseJWS(token)
+	if err != nil {
+		return false, err
+	}
+
+	if jwtToken, ok := jwsToken.(jwt.JWT); ok {
+		var (
+			claims     = jwtToken.Claims()
+			jti, _     = claims.JWTID()
+			subject, _ = claims.Subject()
+		)
+
+		if rv.Revoker.Revoked(jti, subject) {
+			return false, ErrorTokenRevoked
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,151 @@
+package revocation
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	DefaultPollInterval = 30 * time.Second
+	DefaultMaxEntries   = 100000
+)
+
+// Options configures a revocation Cache
+type Options struct {
+	// PollInterval is how often the Source is consulted for revocations.  If nonpositive, DefaultPollInterval is used.
+	PollInterval time.Duration `json:"pollInterval,omitempty"`
+
+	// MaxEntries bounds the number of revocations, jtis and subjects combined, held in memory.  A fetched list
+	// that exceeds this limit is rejected in its entirety, since silently ignoring some revocations would accept
+	// revoked tokens.  If nonpositive, DefaultMaxEntries is used.
+	MaxEntries int `json:"maxEntries,omitempty"`
+
+	// Logger is the go-kit logger for update output.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger `json:"-"`
+}
+
+func (o *Options) pollInterval() time.Duration {
+	if o != nil && o.PollInterval > 0 {
+		return o.PollInterval
+	}
+
+	return DefaultPollInterval
+}
+
+func (o *Options) maxEntries() int {
+	if o != nil && o.MaxEntries > 0 {
+		return o.MaxEntries
+	}
+
+	return DefaultMaxEntries
+}
+
+func (o *Options) logger() log.Logger {
+	if o != nil && o.Logger != nil {
+		return o.Logger
+	}
+
+	return logging.DefaultLogger()
+}
+
+// ErrTooManyRevocations is returned by Cache.Update when the Source returns more revocations than the
+// configured maximum
+var ErrTooManyRevocations = errors.New("too many revocations")
+
+// revocations is an immutable snapshot of revoked jtis and subjects
+type revocations struct {
+	jtis     map[string]bool
+	subjects map[string]bool
+}
+
+// Cache holds the most recently fetched revocations in memory.  Until the first successful update, no tokens
+// are revoked.  If an update fails, including when the Source returns more revocations than the configured
+// maximum, the previous revocations remain in effect.
+type Cache struct {
+	source       Source
+	pollInterval time.Duration
+	maxEntries   int
+	logger       log.Logger
+
+	current atomic.Value
+}
+
+// New creates a revocation Cache for the given Source.  The returned Cache is empty.  Use Update
+// or the Runnable returned by Updater to fetch revocations.
+func New(source Source, o *Options) *Cache {
+	if source == nil {
+		panic("A revocation Source is required")
+	}
+
+	c := &Cache{
+		source:       source,
+		pollInterval: o.pollInterval(),
+		maxEntries:   o.maxEntries(),
+		logger:       o.logger(),
+	}
+
+	c.current.Store(revocations{})
+	return c
+}
+
+// Update fetches the current revocations from the Source, replacing those held by this Cache
+func (c *Cache) Update() error {
+	list, err := c.source.Fetch()
+	if err != nil {
+		c.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to fetch revocations", logging.ErrorKey(), err)
+		return err
+	}
+
+	if count := len(list.JTIs) + len(list.Subjects); count > c.maxEntries {
+		c.logger.Log(
+			level.Key(), level.ErrorValue(),
+			logging.MessageKey(), "too many revocations, keeping the previous revocations",
+			"maxEntries", c.maxEntries,
+			"count", count,
+			logging.ErrorKey(), ErrTooManyRevocations,
+		)
+
+		return ErrTooManyRevocations
+	}
+
+	r := revocations{
+		jtis:     make(map[string]bool, len(list.JTIs)),
+		subjects: make(map[string]bool, len(list.Subjects)),
+	}
+
+	for _, subject := range list.Subjects {
+		r.subjects[subject] = true
+	}
+
+	for _, jti := range list.JTIs {
+		r.jtis[jti] = true
+	}
+
+	c.current.Store(r)
+	return nil
+}
+
+// Revoked tests if a token with the given jti or subject has been revoked.  Empty values are never revoked.
+func (c *Cache) Revoked(jti, subject string) bool {
+	r := c.current.Load().(revocations)
+	return (len(jti) > 0 && r.jtis[jti]) || (len(subject) > 0 && r.subjects[subject])
+}
+
+// Updater returns a Runnable which fetches revocations immediately and then on the configured poll interval
+func (c *Cache) Updater() concurrent.Runnable {
+	return concurrent.NewScheduler(
+		concurrent.ScheduleOptions{
+			Name:       "revocation.updater",
+			Logger:     c.logger,
+			Period:     c.pollInterval,
+			RunOnStart: true,
+		},
+		func() { c.Update() },
+	)
+}
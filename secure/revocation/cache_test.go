@@ -0,0 +1,141 @@
+package revocation
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, o := range []*Options{nil, new(Options)} {
+			assert.Equal(DefaultPollInterval, o.pollInterval())
+			assert.Equal(DefaultMaxEntries, o.maxEntries())
+			assert.NotNil(o.logger())
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			logger = logging.NewTestLogger(nil, t)
+			o      = Options{PollInterval: time.Minute, MaxEntries: 12, Logger: logger}
+		)
+
+		assert.Equal(time.Minute, o.pollInterval())
+		assert.Equal(12, o.maxEntries())
+		assert.Equal(logger, o.logger())
+	})
+}
+
+func TestNew(t *testing.T) {
+	assert.Panics(t, func() { New(nil, nil) })
+}
+
+func TestCache(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		list          = List{JTIs: []string{"jti1", "jti2"}, Subjects: []string{"subject1"}}
+		expectedError = errors.New("expected")
+		fetchError    error
+
+		c = New(
+			SourceFunc(func() (List, error) { return list, fetchError }),
+			&Options{Logger: logging.NewTestLogger(nil, t)},
+		)
+	)
+
+	assert.False(c.Revoked("jti1", ""))
+	assert.False(c.Revoked("", ""))
+
+	assert.NoError(c.Update())
+	assert.True(c.Revoked("jti1", ""))
+	assert.True(c.Revoked("jti2", "subject2"))
+	assert.True(c.Revoked("jti3", "subject1"))
+	assert.False(c.Revoked("jti3", "subject2"))
+	assert.False(c.Revoked("", ""))
+
+	// a failed update keeps the previous revocations
+	fetchError = expectedError
+	assert.Equal(expectedError, c.Update())
+	assert.True(c.Revoked("jti1", ""))
+
+	fetchError = nil
+	list = List{JTIs: []string{"jti3"}}
+	assert.NoError(c.Update())
+	assert.False(c.Revoked("jti1", ""))
+	assert.True(c.Revoked("jti3", ""))
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		list   = List{JTIs: []string{"jti1"}, Subjects: []string{"subject1"}}
+		c      = New(
+			SourceFunc(func() (List, error) {
+				return list, nil
+			}),
+			&Options{MaxEntries: 2, Logger: logging.NewTestLogger(nil, t)},
+		)
+	)
+
+	assert.NoError(c.Update())
+	assert.True(c.Revoked("", "subject1"))
+	assert.True(c.Revoked("jti1", ""))
+
+	// a list over the limit is rejected in its entirety, leaving the previous revocations in effect
+	list = List{JTIs: []string{"jti2", "jti3"}, Subjects: []string{"subject2"}}
+	assert.Equal(ErrTooManyRevocations, c.Update())
+	assert.True(c.Revoked("", "subject1"))
+	assert.True(c.Revoked("jti1", ""))
+	assert.False(c.Revoked("", "subject2"))
+	assert.False(c.Revoked("jti2", ""))
+}
+
+func TestCacheUpdater(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		fetches = make(chan struct{}, 10)
+		count   int32
+
+		c = New(
+			SourceFunc(func() (List, error) {
+				atomic.AddInt32(&count, 1)
+				select {
+				case fetches <- struct{}{}:
+				default:
+				}
+
+				return List{JTIs: []string{"jti"}}, nil
+			}),
+			&Options{PollInterval: 10 * time.Millisecond},
+		)
+	)
+
+	waitGroup, shutdown, err := concurrent.Execute(c.Updater())
+	require.NoError(err)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-fetches:
+		case <-time.After(5 * time.Second):
+			assert.Fail("The updater did not fetch revocations")
+		}
+	}
+
+	close(shutdown)
+	waitGroup.Wait()
+
+	assert.True(atomic.LoadInt32(&count) >= 2)
+	assert.True(c.Revoked("jti", ""))
+}
@@ -0,0 +1,6 @@
+/*
+Package revocation maintains lists of revoked JWTs, identified either by their jti claim or by their subject.
+Revocations are periodically fetched from a Source, such as a polled URL or a store.KV backed by Consul, and
+held in memory so that checking a token during validation never blocks on the network.
+*/
+package revocation
@@ -0,0 +1,125 @@
+package revocation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Comcast/webpa-common/store"
+)
+
+const (
+	// DefaultURLTimeout is the timeout for requests made by a URL source that was not given an http.Client
+	DefaultURLTimeout = 10 * time.Second
+
+	// JTIPrefix is the key prefix under which a KV source stores revoked jti claims
+	JTIPrefix = "jti/"
+
+	// SubjectPrefix is the key prefix under which a KV source stores revoked subjects
+	SubjectPrefix = "sub/"
+)
+
+// List is a set of revocations.  This type is also the JSON format served by a URL source.
+type List struct {
+	// JTIs are the revoked token identifiers
+	JTIs []string `json:"jti,omitempty"`
+
+	// Subjects are the revoked token subjects.  Any token issued to one of these subjects is revoked.
+	Subjects []string `json:"sub,omitempty"`
+}
+
+// Source is a strategy for obtaining the current revocations
+type Source interface {
+	Fetch() (List, error)
+}
+
+// SourceFunc is a function type that implements Source
+type SourceFunc func() (List, error)
+
+func (sf SourceFunc) Fetch() (List, error) {
+	return sf()
+}
+
+// urlSource is a Source which polls an HTTP endpoint that returns a JSON List
+type urlSource struct {
+	url    string
+	client *http.Client
+}
+
+// NewURLSource produces a Source that issues a GET to the given URL, which must return a JSON List.
+// If client is nil, an http.Client with a DefaultURLTimeout timeout is used, so that an unresponsive
+// endpoint cannot stall updates indefinitely.
+func NewURLSource(url string, client *http.Client) Source {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultURLTimeout}
+	}
+
+	return &urlSource{url: url, client: client}
+}
+
+func (us *urlSource) Fetch() (List, error) {
+	response, err := us.client.Get(us.url)
+	if err != nil {
+		return List{}, err
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return List{}, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return List{}, fmt.Errorf("Unexpected status code from %s: %d", us.url, response.StatusCode)
+	}
+
+	var list List
+	err = json.Unmarshal(body, &list)
+	return list, err
+}
+
+// kvSource is a Source backed by a store.KV
+type kvSource struct {
+	kv store.KV
+}
+
+// NewKVSource produces a Source that lists revocations from a store.KV.  Each revoked jti is a key
+// under JTIPrefix, and each revoked subject is a key under SubjectPrefix.  Values are ignored.  Entries may
+// carry an expiration, typically the expiration of the revoked token, after which the revocation is dropped.
+//
+// With store.NewConsulKV, this allows revocations to be managed through Consul.
+func NewKVSource(kv store.KV) Source {
+	if kv == nil {
+		panic("A store.KV is required")
+	}
+
+	return &kvSource{kv: kv}
+}
+
+func (ks *kvSource) list(prefix string) ([]string, error) {
+	entries, err := ks.kv.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if value := strings.TrimPrefix(e.Key, prefix); len(value) > 0 {
+			values = append(values, value)
+		}
+	}
+
+	return values, nil
+}
+
+func (ks *kvSource) Fetch() (list List, err error) {
+	if list.JTIs, err = ks.list(JTIPrefix); err != nil {
+		return
+	}
+
+	list.Subjects, err = ks.list(SubjectPrefix)
+	return
+}
@@ -0,0 +1,82 @@
+package revocation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceFunc(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedList  = List{JTIs: []string{"jti"}}
+		expectedError = errors.New("expected")
+	)
+
+	list, err := SourceFunc(func() (List, error) { return expectedList, expectedError }).Fetch()
+	assert.Equal(expectedList, list)
+	assert.Equal(expectedError, err)
+}
+
+func TestURLSource(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		statusCode = http.StatusOK
+		body       = `{"jti": ["jti1", "jti2"], "sub": ["subject1"]}`
+
+		server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.WriteHeader(statusCode)
+			response.Write([]byte(body))
+		}))
+	)
+
+	defer server.Close()
+	source := NewURLSource(server.URL, nil)
+	require.NotNil(source)
+
+	list, err := source.Fetch()
+	assert.NoError(err)
+	assert.Equal(List{JTIs: []string{"jti1", "jti2"}, Subjects: []string{"subject1"}}, list)
+
+	body = "this is not JSON"
+	_, err = source.Fetch()
+	assert.Error(err)
+
+	statusCode, body = http.StatusInternalServerError, "{}"
+	_, err = source.Fetch()
+	assert.Error(err)
+
+	_, err = NewURLSource("http://[invalid", server.Client()).Fetch()
+	assert.Error(err)
+
+	client := source.(*urlSource).client
+	assert.NotEqual(http.DefaultClient, client)
+	assert.Equal(DefaultURLTimeout, client.Timeout)
+}
+
+func TestKVSource(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		kv      = store.NewMemoryKV(store.MemoryKVOptions{})
+	)
+
+	assert.Panics(func() { NewKVSource(nil) })
+
+	require.NoError(kv.Put(JTIPrefix+"jti1", nil, 0))
+	require.NoError(kv.Put(JTIPrefix+"jti2", nil, time.Hour))
+	require.NoError(kv.Put(SubjectPrefix+"subject1", []byte("ignored"), 0))
+	require.NoError(kv.Put("unrelated", nil, 0))
+
+	list, err := NewKVSource(kv).Fetch()
+	assert.NoError(err)
+	assert.Equal(List{JTIs: []string{"jti1", "jti2"}, Subjects: []string{"subject1"}}, list)
+}
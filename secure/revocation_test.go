@@ -0,0 +1,83 @@
+package secure
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRevoker map[string]bool
+
+func (tr testRevoker) Revoked(jti, subject string) bool {
+	return tr[jti] || tr[subject]
+}
+
+func TestRevokingValidator(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		ctx     = context.Background()
+
+		validator = new(MockValidator)
+		rv        = RevokingValidator{
+			Validator: validator,
+			Revoker:   testRevoker{"revoked-jti": true, "revoked-subject": true},
+		}
+	)
+
+	pair, err := privateKeyResolver.ResolveKey("")
+	require.NoError(err)
+
+	newToken := func(jti, subject string) *Token {
+		claims := jws.Claims{"valid": true}
+		claims.SetJWTID(jti)
+		claims.SetSubject(subject)
+
+		serialized, err := jws.NewJWT(claims, crypto.SigningMethodRS256).Serialize(pair.Private())
+		require.NoError(err)
+
+		return &Token{tokenType: Bearer, value: string(serialized)}
+	}
+
+	var (
+		good           = newToken("good-jti", "good-subject")
+		revokedJTI     = newToken("revoked-jti", "good-subject")
+		revokedSubject = newToken("good-jti", "revoked-subject")
+		basic          = &Token{tokenType: Basic, value: "dXNlcjpwYXNzd29yZA=="}
+		rejected       = newToken("rejected", "rejected")
+		expectedError  = errors.New("expected")
+	)
+
+	validator.On("Validate", ctx, good).Return(true, error(nil)).Once()
+	validator.On("Validate", ctx, revokedJTI).Return(true, error(nil)).Once()
+	validator.On("Validate", ctx, revokedSubject).Return(true, error(nil)).Once()
+	validator.On("Validate", ctx, basic).Return(true, error(nil)).Once()
+	validator.On("Validate", ctx, rejected).Return(false, expectedError).Once()
+
+	valid, err := rv.Validate(ctx, good)
+	assert.True(valid)
+	assert.NoError(err)
+
+	valid, err = rv.Validate(ctx, revokedJTI)
+	assert.False(valid)
+	assert.Equal(ErrorTokenRevoked, err)
+
+	valid, err = rv.Validate(ctx, revokedSubject)
+	assert.False(valid)
+	assert.Equal(ErrorTokenRevoked, err)
+
+	valid, err = rv.Validate(ctx, basic)
+	assert.True(valid)
+	assert.NoError(err)
+
+	valid, err = rv.Validate(ctx, rejected)
+	assert.False(valid)
+	assert.Equal(expectedError, err)
+
+	validator.AssertExpectations(t)
+}
@@ -0,0 +1,54 @@
+package secure
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Comcast/webpa-common/secure/routeauth"
+)
+
+var (
+	ErrorMissingAuthorization = errors.New("Missing authorization")
+	ErrorInvalidToken         = errors.New("Invalid token")
+)
+
+// NewRouteAuthenticator adapts a Validator into a routeauth.Authenticator for tokens of the given type.
+// Requests without an Authorization header of that type fail authentication.  Typically, this is used
+// to supply the Basic and Bearer schemes for a routeauth.Policy:
+//
+//	routeauth.Authenticators{
+//	    routeauth.Basic:  secure.NewRouteAuthenticator(basicValidator, secure.Basic),
+//	    routeauth.Bearer: secure.NewRouteAuthenticator(jwtValidator, secure.Bearer),
+//	}
+func NewRouteAuthenticator(v Validator, tokenType TokenType) routeauth.Authenticator {
+	if v == nil {
+		panic("A Validator is required")
+	}
+
+	return func(request *http.Request) error {
+		token, err := NewToken(request)
+		if err != nil {
+			return err
+		}
+
+		if token == nil {
+			return ErrorMissingAuthorization
+		}
+
+		if token.Type() != tokenType {
+			return fmt.Errorf("Expected a %s token", tokenType)
+		}
+
+		valid, err := v.Validate(request.Context(), token)
+		if err != nil {
+			return err
+		}
+
+		if !valid {
+			return ErrorInvalidToken
+		}
+
+		return nil
+	}
+}
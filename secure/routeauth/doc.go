@@ -0,0 +1,9 @@
+/*
+Package routeauth provides per-route authentication policies for HTTP servers.  A policy maps URL path
+patterns and methods onto the authentication schemes a request must satisfy, which allows a single listener
+to serve unauthenticated endpoints such as health and metrics alongside authenticated APIs.
+
+Token-based schemes are supplied by the caller as Authenticators, e.g. via secure.NewRouteAuthenticator.
+The none and mTLS schemes are built in.
+*/
+package routeauth
@@ -0,0 +1,93 @@
+package routeauth
+
+import (
+	"net/http"
+)
+
+// Scheme is an authentication scheme that a route may require
+type Scheme string
+
+const (
+	// None requires no authentication
+	None Scheme = "none"
+
+	// Basic requires a valid Basic Authorization header
+	Basic Scheme = "basic"
+
+	// Bearer requires a valid Bearer Authorization header, typically a JWT
+	Bearer Scheme = "bearer"
+
+	// MTLS requires a client certificate verified during the TLS handshake.  The server's tls.Config must
+	// request client certificates, e.g. with tls.VerifyClientCertIfGiven, for this scheme to be satisfiable.
+	MTLS Scheme = "mtls"
+)
+
+// DefaultRealm is the realm of WWW-Authenticate challenges when none is configured
+const DefaultRealm = "webpa"
+
+// Rule describes the authentication required for the requests matching a path pattern and, optionally, a set of methods.
+type Rule struct {
+	// Path is the pattern matched against request paths.  See xhttp.PathPattern for the syntax.
+	Path string `json:"path"`
+
+	// Methods restricts this rule to requests with these HTTP methods.  If empty, the rule applies to all methods.
+	Methods []string `json:"methods,omitempty"`
+
+	// Schemes are the acceptable authentication schemes for matching requests.  A request is allowed if it satisfies
+	// any one of these schemes.  At least one scheme is required.
+	Schemes []Scheme `json:"schemes"`
+}
+
+// Options describes the configuration of a route authentication policy.  This type is typically
+// unmarshalled from external configuration.
+type Options struct {
+	// Rules is the ordered list of route rules.  The first rule matching a request determines its required schemes.
+	Rules []Rule `json:"rules,omitempty"`
+
+	// Default are the acceptable schemes for requests that match no rule.  If empty, such requests are rejected.
+	Default []Scheme `json:"default,omitempty"`
+
+	// DeniedStatusCode is the HTTP status code returned for rejected requests.  If unset, http.StatusForbidden is used.
+	DeniedStatusCode int `json:"deniedStatusCode,omitempty"`
+
+	// Realm is the realm of the WWW-Authenticate challenges sent with rejected requests for routes that accept
+	// the Basic or Bearer schemes.  If unset, DefaultRealm is used.
+	Realm string `json:"realm,omitempty"`
+}
+
+func (o *Options) rules() []Rule {
+	if o != nil {
+		return o.Rules
+	}
+
+	return nil
+}
+
+func (o *Options) defaultSchemes() []Scheme {
+	if o != nil {
+		return o.Default
+	}
+
+	return nil
+}
+
+func (o *Options) deniedStatusCode() int {
+	if o != nil && o.DeniedStatusCode > 0 {
+		return o.DeniedStatusCode
+	}
+
+	return http.StatusForbidden
+}
+
+func (o *Options) realm() string {
+	if o != nil && len(o.Realm) > 0 {
+		return o.Realm
+	}
+
+	return DefaultRealm
+}
+
+// enabled tests if these options define any policy
+func (o *Options) enabled() bool {
+	return len(o.rules()) > 0 || len(o.defaultSchemes()) > 0
+}
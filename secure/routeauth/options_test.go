@@ -0,0 +1,40 @@
+package routeauth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, o := range []*Options{nil, new(Options)} {
+			assert.Empty(o.rules())
+			assert.Empty(o.defaultSchemes())
+			assert.Equal(http.StatusForbidden, o.deniedStatusCode())
+			assert.Equal(DefaultRealm, o.realm())
+			assert.False(o.enabled())
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o      = Options{
+				Rules:            []Rule{{Path: "/health", Schemes: []Scheme{None}}},
+				Default:          []Scheme{Bearer},
+				DeniedStatusCode: http.StatusUnauthorized,
+				Realm:            "test",
+			}
+		)
+
+		assert.Equal([]Rule{{Path: "/health", Schemes: []Scheme{None}}}, o.rules())
+		assert.Equal([]Scheme{Bearer}, o.defaultSchemes())
+		assert.Equal(http.StatusUnauthorized, o.deniedStatusCode())
+		assert.Equal("test", o.realm())
+		assert.True(o.enabled())
+		assert.True((&Options{Default: []Scheme{None}}).enabled())
+	})
+}
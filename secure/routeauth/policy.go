@@ -0,0 +1,218 @@
+package routeauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/go-kit/kit/log/level"
+)
+
+var (
+	ErrorNoMatchingRule           = errors.New("No authentication rule matches the request")
+	ErrorMissingClientCertificate = errors.New("No verified client certificate")
+)
+
+// Authenticator verifies that a request satisfies a single authentication scheme.  A nil error
+// indicates the request is authenticated.
+type Authenticator func(*http.Request) error
+
+// Authenticators maps schemes onto the Authenticator for each scheme
+type Authenticators map[Scheme]Authenticator
+
+// authenticateNone is the built-in Authenticator for the None scheme
+func authenticateNone(*http.Request) error {
+	return nil
+}
+
+// authenticateMTLS is the built-in Authenticator for the MTLS scheme
+func authenticateMTLS(request *http.Request) error {
+	if request.TLS == nil || len(request.TLS.VerifiedChains) == 0 {
+		return ErrorMissingClientCertificate
+	}
+
+	return nil
+}
+
+// compiledRule is a Rule with its schemes bound to Authenticators
+type compiledRule struct {
+	pattern        xhttp.PathPattern
+	methods        map[string]bool
+	schemes        []Scheme
+	authenticators []Authenticator
+}
+
+func (cr compiledRule) matches(request *http.Request) bool {
+	if len(cr.methods) > 0 && !cr.methods[request.Method] {
+		return false
+	}
+
+	return cr.pattern.Matches(request.URL.Path)
+}
+
+// Policy is a per-route authentication policy
+type Policy struct {
+	rules            []compiledRule
+	defaultRule      compiledRule
+	deniedStatusCode int
+	realm            string
+}
+
+// bind produces the Authenticators for a list of schemes.  The None and MTLS schemes are built in, though
+// the given Authenticators may override them.
+func bind(schemes []Scheme, a Authenticators) ([]Authenticator, error) {
+	authenticators := make([]Authenticator, 0, len(schemes))
+	for _, s := range schemes {
+		if authenticator, ok := a[s]; ok && authenticator != nil {
+			authenticators = append(authenticators, authenticator)
+			continue
+		}
+
+		switch s {
+		case None:
+			authenticators = append(authenticators, authenticateNone)
+
+		case MTLS:
+			authenticators = append(authenticators, authenticateMTLS)
+
+		default:
+			return nil, fmt.Errorf("No authenticator for scheme %s", s)
+		}
+	}
+
+	return authenticators, nil
+}
+
+// New produces a Policy from a set of options and the Authenticators for the token-based schemes.  If the
+// options define no rules and no default, this function returns a nil Policy, which permits all requests.
+func New(o *Options, a Authenticators) (*Policy, error) {
+	if !o.enabled() {
+		return nil, nil
+	}
+
+	p := &Policy{
+		deniedStatusCode: o.deniedStatusCode(),
+		realm:            o.realm(),
+	}
+
+	for _, r := range o.rules() {
+		if err := xhttp.PathPattern(r.Path).Validate(); err != nil {
+			return nil, err
+		}
+
+		if len(r.Schemes) == 0 {
+			return nil, fmt.Errorf("No schemes for rule path %s", r.Path)
+		}
+
+		authenticators, err := bind(r.Schemes, a)
+		if err != nil {
+			return nil, err
+		}
+
+		cr := compiledRule{
+			pattern:        xhttp.PathPattern(r.Path),
+			schemes:        r.Schemes,
+			authenticators: authenticators,
+		}
+
+		if len(r.Methods) > 0 {
+			cr.methods = make(map[string]bool, len(r.Methods))
+			for _, m := range r.Methods {
+				cr.methods[strings.ToUpper(m)] = true
+			}
+		}
+
+		p.rules = append(p.rules, cr)
+	}
+
+	var err error
+	p.defaultRule.schemes = o.defaultSchemes()
+	if p.defaultRule.authenticators, err = bind(p.defaultRule.schemes, a); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// match returns the first rule matching the given request, or the default rule if no rule matches
+func (p *Policy) match(request *http.Request) compiledRule {
+	for _, r := range p.rules {
+		if r.matches(request) {
+			return r
+		}
+	}
+
+	return p.defaultRule
+}
+
+// authenticate checks a request against the given rule
+func authenticate(r compiledRule, request *http.Request) error {
+	err := ErrorNoMatchingRule
+	for _, authenticator := range r.authenticators {
+		if err = authenticator(request); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// Authenticate checks the given request against this policy.  A nil error indicates the request is allowed.
+// Otherwise, the error from the last acceptable scheme is returned.  A nil Policy allows all requests.
+func (p *Policy) Authenticate(request *http.Request) error {
+	if p == nil {
+		return nil
+	}
+
+	return authenticate(p.match(request), request)
+}
+
+// challenges produces the WWW-Authenticate values for the token-based schemes of a rule, so that clients
+// can tell which credentials the route accepts
+func (p *Policy) challenges(r compiledRule) []string {
+	var challenges []string
+	for _, s := range r.schemes {
+		switch s {
+		case Basic:
+			challenges = append(challenges, fmt.Sprintf(`Basic realm=%q`, p.realm))
+
+		case Bearer:
+			challenges = append(challenges, fmt.Sprintf(`Bearer realm=%q`, p.realm))
+		}
+	}
+
+	return challenges
+}
+
+// Then is an alice-style decorator that rejects requests which do not satisfy this policy.  A nil Policy
+// returns the next handler undecorated.
+func (p *Policy) Then(next http.Handler) http.Handler {
+	if p == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		r := p.match(request)
+		if err := authenticate(r, request); err != nil {
+			logging.GetLogger(request.Context()).Log(
+				level.Key(), level.InfoValue(),
+				logging.MessageKey(), "request denied by route authentication policy",
+				"method", request.Method,
+				"path", request.URL.Path,
+				logging.ErrorKey(), err,
+			)
+
+			for _, challenge := range p.challenges(r) {
+				response.Header().Add("WWW-Authenticate", challenge)
+			}
+
+			response.WriteHeader(p.deniedStatusCode)
+			return
+		}
+
+		next.ServeHTTP(response, request)
+	})
+}
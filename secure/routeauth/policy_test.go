@@ -0,0 +1,163 @@
+package routeauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errInvalidToken = errors.New("invalid token")
+
+// testAuthenticator accepts requests whose Authorization header is the given value
+func testAuthenticator(expected string) Authenticator {
+	return func(request *http.Request) error {
+		if request.Header.Get("Authorization") != expected {
+			return errInvalidToken
+		}
+
+		return nil
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, o := range []*Options{nil, new(Options), {DeniedStatusCode: 401}} {
+			p, err := New(o, nil)
+			assert.Nil(p)
+			assert.NoError(err)
+		}
+
+		// a nil Policy allows everything
+		var p *Policy
+		assert.NoError(p.Authenticate(httptest.NewRequest("GET", "/", nil)))
+	})
+
+	testData := []Options{
+		{Rules: []Rule{{Schemes: []Scheme{None}}}},
+		{Rules: []Rule{{Path: "/api/[", Schemes: []Scheme{None}}}},
+		{Rules: []Rule{{Path: "/api"}}},
+		{Rules: []Rule{{Path: "/api", Schemes: []Scheme{Bearer}}}},
+		{Rules: []Rule{{Path: "/api", Schemes: []Scheme{"nosuch"}}}},
+		{Default: []Scheme{Basic}},
+	}
+
+	for _, o := range testData {
+		t.Run("Invalid", func(t *testing.T) {
+			p, err := New(&o, Authenticators{Bearer: nil})
+			assert.Nil(t, p)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	var (
+		require = require.New(t)
+
+		p, err = New(
+			&Options{
+				Rules: []Rule{
+					{Path: "/health", Schemes: []Scheme{None}},
+					{Path: "/metrics", Methods: []string{"get"}, Schemes: []Scheme{None}},
+					{Path: "/api/*/admin", Schemes: []Scheme{MTLS}},
+					{Path: "/api/", Schemes: []Scheme{Bearer, Basic}},
+				},
+				DeniedStatusCode: http.StatusUnauthorized,
+				Realm:            "test",
+			},
+			Authenticators{
+				Bearer: testAuthenticator("Bearer valid"),
+				Basic:  testAuthenticator("Basic valid"),
+			},
+		)
+
+		verified = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{new(x509.Certificate)}}}
+	)
+
+	require.NoError(err)
+	require.NotNil(p)
+
+	testData := []struct {
+		method        string
+		path          string
+		authorization string
+		tls           *tls.ConnectionState
+		expected      int
+		challenges    []string
+	}{
+		{"GET", "/health", "", nil, 299, nil},
+		{"GET", "/metrics", "", nil, 299, nil},
+		{"POST", "/metrics", "", nil, http.StatusUnauthorized, nil},
+		{"GET", "/api/v2/admin", "Bearer valid", nil, http.StatusUnauthorized, nil},
+		{"GET", "/api/v2/admin", "", &tls.ConnectionState{}, http.StatusUnauthorized, nil},
+		{"GET", "/api/v2/admin", "", verified, 299, nil},
+		{"GET", "/api/v2/device", "", nil, http.StatusUnauthorized, []string{`Bearer realm="test"`, `Basic realm="test"`}},
+		{"GET", "/api/v2/device", "Bearer invalid", nil, http.StatusUnauthorized, []string{`Bearer realm="test"`, `Basic realm="test"`}},
+		{"GET", "/api/v2/device", "Bearer valid", nil, 299, nil},
+		{"GET", "/api/v2/device", "Basic valid", nil, 299, nil},
+		{"GET", "/health/../api/v2/device", "", nil, http.StatusUnauthorized, []string{`Bearer realm="test"`, `Basic realm="test"`}},
+		{"GET", "/other", "Bearer valid", nil, http.StatusUnauthorized, nil},
+	}
+
+	handler := p.Then(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.WriteHeader(299)
+	}))
+
+	for _, record := range testData {
+		t.Run(record.method+" "+record.path, func(t *testing.T) {
+			var (
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest(record.method, record.path, nil)
+			)
+
+			request.TLS = record.tls
+			if len(record.authorization) > 0 {
+				request.Header.Set("Authorization", record.authorization)
+			}
+
+			handler.ServeHTTP(response, request)
+			assert.Equal(t, record.expected, response.Code)
+			assert.Equal(t, record.challenges, response.Header()["Www-Authenticate"])
+		})
+	}
+}
+
+func TestPolicyDefault(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	p, err := New(&Options{Default: []Scheme{Bearer}}, Authenticators{Bearer: testAuthenticator("Bearer valid")})
+	require.NoError(err)
+	require.NotNil(p)
+
+	request := httptest.NewRequest("GET", "/anything", nil)
+	assert.Equal(errInvalidToken, p.Authenticate(request))
+
+	request.Header.Set("Authorization", "Bearer valid")
+	assert.NoError(p.Authenticate(request))
+
+	// without a default, unmatched requests are rejected
+	p, err = New(&Options{Rules: []Rule{{Path: "/health", Schemes: []Scheme{None}}}}, nil)
+	require.NoError(err)
+	assert.Equal(ErrorNoMatchingRule, p.Authenticate(request))
+}
+
+func TestPolicyThenNil(t *testing.T) {
+	var (
+		p        *Policy
+		next     = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+		response = httptest.NewRecorder()
+	)
+
+	p.Then(next).ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, response.Code)
+}
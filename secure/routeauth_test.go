@@ -0,0 +1,48 @@
+package secure
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewRouteAuthenticator(t *testing.T) {
+	t.Run("NilValidator", func(t *testing.T) {
+		assert.Panics(t, func() { NewRouteAuthenticator(nil, Bearer) })
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			expectedError = errors.New("expected")
+			validator     = new(MockValidator)
+			authenticate  = NewRouteAuthenticator(validator, Bearer)
+
+			newRequest = func(authorization string) *http.Request {
+				request := httptest.NewRequest("GET", "/", nil)
+				if len(authorization) > 0 {
+					request.Header.Set(AuthorizationHeader, authorization)
+				}
+
+				return request
+			}
+		)
+
+		validator.On("Validate", mock.Anything, &Token{tokenType: Bearer, value: "valid"}).Return(true, nil).Once()
+		validator.On("Validate", mock.Anything, &Token{tokenType: Bearer, value: "invalid"}).Return(false, nil).Once()
+		validator.On("Validate", mock.Anything, &Token{tokenType: Bearer, value: "error"}).Return(false, expectedError).Once()
+
+		assert.Equal(ErrorMissingAuthorization, authenticate(newRequest("")))
+		assert.Error(authenticate(newRequest("Unsupported value")))
+		assert.Error(authenticate(newRequest("Basic dXNlcjpwYXNzd29yZA==")))
+		assert.NoError(authenticate(newRequest("Bearer valid")))
+		assert.Equal(ErrorInvalidToken, authenticate(newRequest("Bearer invalid")))
+		assert.Equal(expectedError, authenticate(newRequest("Bearer error")))
+
+		validator.AssertExpectations(t)
+	})
+}
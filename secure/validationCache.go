@@ -0,0 +1,181 @@
+package secure
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync/atomic"
+	"time"
+
+	"github.com/Comcast/webpa-common/secure/key"
+	"github.com/Comcast/webpa-common/store"
+	"github.com/SermoDigital/jose/jwt"
+)
+
+const (
+	DefaultValidationCacheTTL        = 10 * time.Second
+	DefaultValidationCacheMaxEntries = 10000
+)
+
+// ValidationCacheOptions configures a CachingValidator
+type ValidationCacheOptions struct {
+	// TTL is the maximum length of time a successful validation is remembered.  If nonpositive,
+	// DefaultValidationCacheTTL is used.
+	TTL time.Duration `json:"ttl"`
+
+	// MaxEntries is the maximum number of tokens remembered.  When this limit is reached, the least
+	// recently used token is forgotten.  If nonpositive, DefaultValidationCacheMaxEntries is used.
+	MaxEntries int `json:"maxEntries"`
+
+	// Now is the source of the current time.  If unset, time.Now is used.
+	Now func() time.Time `json:"-"`
+}
+
+func (o *ValidationCacheOptions) ttl() time.Duration {
+	if o != nil && o.TTL > 0 {
+		return o.TTL
+	}
+
+	return DefaultValidationCacheTTL
+}
+
+func (o *ValidationCacheOptions) maxEntries() int {
+	if o != nil && o.MaxEntries > 0 {
+		return o.MaxEntries
+	}
+
+	return DefaultValidationCacheMaxEntries
+}
+
+func (o *ValidationCacheOptions) now() func() time.Time {
+	if o != nil && o.Now != nil {
+		return o.Now
+	}
+
+	return time.Now
+}
+
+// CachingValidator is a Validator that remembers successful validations for a short time, which avoids
+// verifying the same token's signature over and over on hot paths.  Tokens are remembered by a hash of
+// their type and value, never by the raw credentials.  Failed validations are never cached.
+//
+// A bearer token that carries an exp claim is never remembered beyond its expiration.  Since cached decisions
+// do not consult the context, the decorated Validator should not depend on the request context.
+//
+// Cache hits bypass the decorated Validator, so they are counted as successful validations in the
+// JWTValidationReasonCounter established with DefineMeasures.
+type CachingValidator struct {
+	validator  Validator
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	measures   *JWTValidationMeasures
+
+	// decisions holds the store.KV of remembered tokens, replaced wholesale on invalidation
+	decisions atomic.Value
+}
+
+// NewCachingValidator decorates a Validator with a cache of successful validations
+func NewCachingValidator(v Validator, o *ValidationCacheOptions) *CachingValidator {
+	if v == nil {
+		panic("A Validator is required")
+	}
+
+	cv := &CachingValidator{
+		validator:  v,
+		ttl:        o.ttl(),
+		maxEntries: o.maxEntries(),
+		now:        o.now(),
+	}
+
+	cv.Invalidate()
+	return cv
+}
+
+// Invalidate forgets all remembered validations.  Validations in progress when this method is called
+// are not remembered.
+func (cv *CachingValidator) Invalidate() {
+	cv.decisions.Store(
+		store.NewMemoryKV(store.MemoryKVOptions{
+			MaxEntries: cv.maxEntries,
+			Now:        cv.now,
+		}),
+	)
+}
+
+// ttlFor determines how long a successfully validated token may be remembered, which is never
+// longer than the token's expiration
+func (cv *CachingValidator) ttlFor(token *Token) time.Duration {
+	ttl := cv.ttl
+	if token.Type() != Bearer {
+		return ttl
+	}
+
+	jwsToken, err := DefaultJWSParser.ParseJWS(token)
+	if err != nil {
+		return ttl
+	}
+
+	if jwtToken, ok := jwsToken.(jwt.JWT); ok {
+		if exp, ok := jwtToken.Claims().Expiration(); ok {
+			if untilExp := exp.Sub(cv.now()); untilExp < ttl {
+				ttl = untilExp
+			}
+		}
+	}
+
+	return ttl
+}
+
+func (cv *CachingValidator) Validate(ctx context.Context, token *Token) (bool, error) {
+	var (
+		decisions = cv.decisions.Load().(store.KV)
+		hash      = sha256.Sum256([]byte(token.String()))
+		cacheKey  = string(hash[:])
+	)
+
+	if _, err := decisions.Get(cacheKey); err == nil {
+		if cv.measures != nil {
+			cv.measures.ValidationReason.With("reason", "ok").Add(1)
+		}
+
+		return true, nil
+	}
+
+	valid, err := cv.validator.Validate(ctx, token)
+	if valid && err == nil {
+		if ttl := cv.ttlFor(token); ttl > 0 {
+			decisions.Put(cacheKey, nil, ttl)
+		}
+	}
+
+	return valid, err
+}
+
+// DefineMeasures establishes the metrics used to count cache hits.  This should be the same
+// JWTValidationMeasures used by the decorated Validator, so that every validation is counted once.
+func (cv *CachingValidator) DefineMeasures(m *JWTValidationMeasures) {
+	cv.measures = m
+}
+
+// InvalidateOnUpdate decorates a key.Cache so that this validator forgets its remembered validations
+// whenever at least one key is successfully refreshed.  This ensures that a rotated key is never
+// bypassed by a decision made with its predecessor.  The returned key.Cache should be used in place
+// of the original, for example with key.NewUpdater.
+func (cv *CachingValidator) InvalidateOnUpdate(c key.Cache) key.Cache {
+	return &invalidatingCache{Cache: c, validator: cv}
+}
+
+// invalidatingCache is a key.Cache which invalidates a CachingValidator after keys are updated
+type invalidatingCache struct {
+	key.Cache
+	validator *CachingValidator
+}
+
+func (ic *invalidatingCache) UpdateKeys() (int, []error) {
+	count, errs := ic.Cache.UpdateKeys()
+	if count > len(errs) {
+		ic.validator.Invalidate()
+	}
+
+	return count, errs
+}
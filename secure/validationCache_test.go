@@ -0,0 +1,221 @@
+package secure
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/secure/key"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationCacheOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		for _, o := range []*ValidationCacheOptions{nil, new(ValidationCacheOptions)} {
+			assert := assert.New(t)
+			assert.Equal(DefaultValidationCacheTTL, o.ttl())
+			assert.Equal(DefaultValidationCacheMaxEntries, o.maxEntries())
+			assert.NotNil(o.now())
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			expected = time.Now()
+			o        = ValidationCacheOptions{
+				TTL:        time.Minute,
+				MaxEntries: 12,
+				Now:        func() time.Time { return expected },
+			}
+		)
+
+		assert.Equal(time.Minute, o.ttl())
+		assert.Equal(12, o.maxEntries())
+		assert.Equal(expected, o.now()())
+	})
+}
+
+func TestNewCachingValidator(t *testing.T) {
+	assert.Panics(t, func() { NewCachingValidator(nil, nil) })
+}
+
+func TestCachingValidator(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		current = time.Now()
+		ctx     = context.Background()
+
+		validator = new(MockValidator)
+		cv        = NewCachingValidator(validator, &ValidationCacheOptions{
+			TTL:        time.Minute,
+			MaxEntries: 2,
+			Now:        func() time.Time { return current },
+		})
+
+		first  = &Token{tokenType: Basic, value: "first"}
+		second = &Token{tokenType: Basic, value: "second"}
+		third  = &Token{tokenType: Basic, value: "third"}
+		failed = &Token{tokenType: Basic, value: "failed"}
+		broken = &Token{tokenType: Basic, value: "broken"}
+	)
+
+	require.NotNil(cv)
+	validator.On("Validate", ctx, first).Return(true, error(nil)).Times(3)
+	validator.On("Validate", ctx, second).Return(true, error(nil)).Once()
+	validator.On("Validate", ctx, third).Return(true, error(nil)).Once()
+	validator.On("Validate", ctx, failed).Return(false, error(nil)).Twice()
+	validator.On("Validate", ctx, broken).Return(true, errors.New("expected")).Twice()
+
+	for i := 0; i < 2; i++ {
+		valid, err := cv.Validate(ctx, first)
+		assert.True(valid)
+		assert.NoError(err)
+
+		valid, err = cv.Validate(ctx, failed)
+		assert.False(valid)
+		assert.NoError(err)
+
+		_, err = cv.Validate(ctx, broken)
+		assert.Error(err)
+	}
+
+	// the cached decision expires
+	current = current.Add(time.Minute)
+	valid, err := cv.Validate(ctx, first)
+	assert.True(valid)
+	assert.NoError(err)
+
+	// first is the least recently used once second and third are remembered
+	for _, token := range []*Token{second, third, second, third} {
+		valid, err := cv.Validate(ctx, token)
+		assert.True(valid)
+		assert.NoError(err)
+	}
+
+	valid, err = cv.Validate(ctx, first)
+	assert.True(valid)
+	assert.NoError(err)
+
+	validator.AssertExpectations(t)
+}
+
+func TestCachingValidatorMeasures(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		provider = xmetricstest.NewProvider(nil, Metrics)
+		ctx      = context.Background()
+
+		validator = new(MockValidator)
+		cv        = NewCachingValidator(validator, nil)
+		token     = &Token{tokenType: Basic, value: "token"}
+	)
+
+	cv.DefineMeasures(NewJWTValidationMeasures(provider))
+	validator.On("Validate", ctx, token).Return(true, error(nil)).Once()
+
+	// the miss is counted by the decorated validator, so only the hits are counted here
+	for i := 0; i < 3; i++ {
+		valid, err := cv.Validate(ctx, token)
+		assert.True(valid)
+		assert.NoError(err)
+	}
+
+	provider.Assert(t, JWTValidationReasonCounter, "reason", "ok")(xmetricstest.Value(2.0))
+	validator.AssertExpectations(t)
+}
+
+func TestCachingValidatorExpiration(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		ctx     = context.Background()
+
+		validator = new(MockValidator)
+		cv        = NewCachingValidator(validator, &ValidationCacheOptions{TTL: time.Hour})
+	)
+
+	pair, err := privateKeyResolver.ResolveKey("")
+	require.NoError(err)
+
+	newToken := func(exp time.Time) *Token {
+		claims := jws.Claims{"valid": true}
+		claims.SetExpiration(exp)
+
+		serialized, err := jws.NewJWT(claims, crypto.SigningMethodRS256).Serialize(pair.Private())
+		require.NoError(err)
+
+		return &Token{tokenType: Bearer, value: string(serialized)}
+	}
+
+	var (
+		expiring = newToken(time.Now().Add(time.Minute))
+		expired  = newToken(time.Now().Add(-time.Minute))
+	)
+
+	ttl := cv.ttlFor(expiring)
+	assert.True(ttl > 0)
+	assert.True(ttl <= time.Minute)
+	assert.True(cv.ttlFor(expired) <= 0)
+	assert.Equal(time.Hour, cv.ttlFor(&Token{tokenType: Bearer, value: "this is not a JWT"}))
+	assert.Equal(time.Hour, cv.ttlFor(&Token{tokenType: Basic, value: "dXNlcjpwYXNzd29yZA=="}))
+
+	// an expired token is never remembered
+	validator.On("Validate", ctx, expired).Return(true, error(nil)).Twice()
+	for i := 0; i < 2; i++ {
+		valid, err := cv.Validate(ctx, expired)
+		assert.True(valid)
+		assert.NoError(err)
+	}
+
+	validator.AssertExpectations(t)
+}
+
+func TestCachingValidatorInvalidateOnUpdate(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		ctx    = context.Background()
+		token  = &Token{tokenType: Basic, value: "dXNlcjpwYXNzd29yZA=="}
+
+		validator = new(MockValidator)
+		cache     = new(key.MockCache)
+		cv        = NewCachingValidator(validator, nil)
+		decorated = cv.InvalidateOnUpdate(cache)
+	)
+
+	validator.On("Validate", ctx, token).Return(true, error(nil)).Twice()
+	cache.On("UpdateKeys").Return(1, []error{errors.New("expected")}).Once()
+	cache.On("UpdateKeys").Return(2, []error{errors.New("expected")}).Once()
+
+	valid, err := cv.Validate(ctx, token)
+	assert.True(valid)
+	assert.NoError(err)
+
+	// every key update failed, so the decision is still remembered
+	count, errs := decorated.UpdateKeys()
+	assert.Equal(1, count)
+	assert.Len(errs, 1)
+
+	valid, err = cv.Validate(ctx, token)
+	assert.True(valid)
+	assert.NoError(err)
+
+	// at least one key was refreshed, so the decision is forgotten
+	count, errs = decorated.UpdateKeys()
+	assert.Equal(2, count)
+	assert.Len(errs, 1)
+
+	valid, err = cv.Validate(ctx, token)
+	assert.True(valid)
+	assert.NoError(err)
+
+	validator.AssertExpectations(t)
+	cache.AssertExpectations(t)
+}
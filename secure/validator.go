@@ -139,19 +139,13 @@ func (v JWSValidator) Validate(ctx context.Context, token *Token) (valid bool, e
 
 	if nil != err {
 		if v.measures != nil {
-
-			//capture specific cases of interest, default to global (invalid_signature) reason
-			switch err {
-			case jwt.ErrTokenIsExpired:
-				v.measures.ValidationReason.With("reason", "expired_token").Add(1)
-				break
-			case jwt.ErrTokenNotYetValid:
-				v.measures.ValidationReason.With("reason", "premature_token").Add(1)
-				break
-
-			default:
-				v.measures.ValidationReason.With("reason", "invalid_signature").Add(1)
+			// signature and claim failures which are not otherwise classified are reported as bad signatures
+			reason := ReasonFor(err)
+			if reason == ReasonDenied {
+				reason = ReasonBadSignature
 			}
+
+			v.measures.ValidationReason.With("reason", string(reason)).Add(1)
 		}
 		return
 	}
@@ -186,6 +180,11 @@ func (v JWSValidator) Validate(ctx context.Context, token *Token) (valid bool, e
 	}
 
 	// This fail
+	err = ErrorMissingCapability
+	if v.measures != nil {
+		v.measures.ValidationReason.With("reason", string(ReasonMissingCapability)).Add(1)
+	}
+
 	return
 }
 
@@ -519,6 +519,48 @@ func TestJWSValidatorVerify(t *testing.T) {
 	}
 }
 
+func TestJWSValidatorMissingCapability(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, claims := range []jws.Claims{{}, {"capabilities": []interface{}{}}} {
+		t.Logf("%v", claims)
+		token := &Token{tokenType: Bearer, value: "does not matter"}
+
+		mockPair := &key.MockPair{}
+		expectedPublicKey := interface{}(123)
+		mockPair.On("Public").Return(expectedPublicKey).Once()
+
+		mockResolver := &key.MockResolver{}
+		mockResolver.On("ResolveKey", mock.AnythingOfType("string")).Return(mockPair, nil).Once()
+
+		expectedSigningMethod := jws.GetSigningMethod("RS256")
+		assert.NotNil(expectedSigningMethod)
+
+		mockJWS := &mockJWS{}
+		mockJWS.On("Protected").Return(jose.Protected{"alg": "RS256"}).Once()
+		mockJWS.On("Verify", expectedPublicKey, expectedSigningMethod).Return(nil).Once()
+		mockJWS.On("Payload").Return(claims).Once()
+
+		mockJWSParser := &mockJWSParser{}
+		mockJWSParser.On("ParseJWS", token).Return(mockJWS, nil).Once()
+
+		validator := &JWSValidator{
+			Resolver: mockResolver,
+			Parser:   mockJWSParser,
+		}
+
+		valid, err := validator.Validate(context.Background(), token)
+		assert.False(valid)
+		assert.Equal(ErrorMissingCapability, err)
+		assert.Equal(ReasonMissingCapability, ReasonFor(err))
+
+		mockPair.AssertExpectations(t)
+		mockResolver.AssertExpectations(t)
+		mockJWS.AssertExpectations(t)
+		mockJWSParser.AssertExpectations(t)
+	}
+}
+
 func TestJWSValidatorValidate(t *testing.T) {
 	assert := assert.New(t)
 
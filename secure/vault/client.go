@@ -0,0 +1,313 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	// TokenHeader is the HTTP header carrying the Vault token
+	TokenHeader = "X-Vault-Token"
+
+	// NamespaceHeader is the HTTP header carrying the Vault Enterprise namespace
+	NamespaceHeader = "X-Vault-Namespace"
+)
+
+var (
+	ErrorNoAddress        = errors.New("A Vault address is required")
+	ErrorNoAuthentication = errors.New("Either a Vault token or Kubernetes authentication is required")
+	ErrorAmbiguousAuth    = errors.New("Only one of a Vault token or Kubernetes authentication may be configured")
+	ErrorNoKubernetesRole = errors.New("A role is required for Kubernetes authentication")
+	ErrorNoSecret         = errors.New("Vault returned no secret")
+)
+
+// ResponseError is returned when Vault responds with a non-2xx status code
+type ResponseError struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (re *ResponseError) Error() string {
+	return fmt.Sprintf("Vault responded with status %d: %s", re.StatusCode, strings.Join(re.Errors, "; "))
+}
+
+// Secret is the portion of a Vault response describing a secret and its lease
+type Secret struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+}
+
+// TTL returns the lease duration as a time.Duration
+func (s *Secret) TTL() time.Duration {
+	return time.Duration(s.LeaseDuration) * time.Second
+}
+
+// auth is the authentication portion of a Vault response
+type auth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// response is the complete body of a Vault response
+type response struct {
+	Secret
+	Auth   *auth    `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// tokenLease describes the lease on the client's own token
+type tokenLease struct {
+	ttl       time.Duration
+	renewable bool
+}
+
+// Client is a minimal Vault HTTP API client.  A Client is safe for concurrent use.
+type Client struct {
+	address    string
+	namespace  string
+	httpClient *http.Client
+	static     string
+	kubernetes *KubernetesOptions
+	secretTTL  time.Duration
+	logger     log.Logger
+
+	lock  sync.RWMutex
+	token string
+	lease tokenLease
+}
+
+// NewClient validates the given Options and creates a Client.  No requests are made until the client is used.
+func NewClient(o Options) (*Client, error) {
+	if len(o.Address) == 0 {
+		return nil, ErrorNoAddress
+	}
+
+	switch {
+	case len(o.Token) == 0 && o.Kubernetes == nil:
+		return nil, ErrorNoAuthentication
+
+	case len(o.Token) > 0 && o.Kubernetes != nil:
+		return nil, ErrorAmbiguousAuth
+
+	case o.Kubernetes != nil && len(o.Kubernetes.Role) == 0:
+		return nil, ErrorNoKubernetesRole
+	}
+
+	return &Client{
+		address:    strings.TrimRight(o.Address, "/"),
+		namespace:  o.Namespace,
+		httpClient: o.httpClient(),
+		static:     o.Token,
+		kubernetes: o.Kubernetes,
+		secretTTL:  o.secretTTL(),
+		logger:     o.logger(),
+	}, nil
+}
+
+// do executes a single request against the Vault API.  The path is relative to /v1/.
+func (c *Client) do(ctx context.Context, method, path, token string, body interface{}) (*response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	request, err := http.NewRequest(method, c.address+"/v1/"+strings.TrimLeft(path, "/"), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	request = request.WithContext(ctx)
+	if len(token) > 0 {
+		request.Header.Set(TokenHeader, token)
+	}
+
+	if len(c.namespace) > 0 {
+		request.Header.Set(NamespaceHeader, c.namespace)
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResponse, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer httpResponse.Body.Close()
+	contents, err := ioutil.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r := new(response)
+	if len(contents) > 0 {
+		if err := json.Unmarshal(contents, r); err != nil && httpResponse.StatusCode < 300 {
+			return nil, err
+		}
+	}
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		return nil, &ResponseError{StatusCode: httpResponse.StatusCode, Errors: r.Errors}
+	}
+
+	return r, nil
+}
+
+// currentToken returns the token to use for requests, logging in first if necessary
+func (c *Client) currentToken(ctx context.Context) (string, error) {
+	c.lock.RLock()
+	token := c.token
+	c.lock.RUnlock()
+
+	if len(token) > 0 {
+		return token, nil
+	}
+
+	if err := c.Login(ctx); err != nil {
+		return "", err
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.token, nil
+}
+
+// Login obtains a token and its lease.  With Kubernetes authentication, the service account token is exchanged
+// for a new Vault token.  With a static token, the token is looked up to determine its lease.
+func (c *Client) Login(ctx context.Context) error {
+	var (
+		token string
+		lease tokenLease
+	)
+
+	if c.kubernetes != nil {
+		jwt, err := ioutil.ReadFile(c.kubernetes.jwtFile())
+		if err != nil {
+			return err
+		}
+
+		r, err := c.do(
+			ctx,
+			"POST",
+			fmt.Sprintf("auth/%s/login", c.kubernetes.mount()),
+			"",
+			map[string]string{"role": c.kubernetes.Role, "jwt": strings.TrimSpace(string(jwt))},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if r.Auth == nil || len(r.Auth.ClientToken) == 0 {
+			return ErrorNoSecret
+		}
+
+		token = r.Auth.ClientToken
+		lease = tokenLease{ttl: time.Duration(r.Auth.LeaseDuration) * time.Second, renewable: r.Auth.Renewable}
+	} else {
+		r, err := c.do(ctx, "GET", "auth/token/lookup-self", c.static, nil)
+		if err != nil {
+			return err
+		}
+
+		token = c.static
+		if ttl, ok := r.Data["ttl"].(float64); ok {
+			lease.ttl = time.Duration(ttl) * time.Second
+		}
+
+		lease.renewable, _ = r.Data["renewable"].(bool)
+	}
+
+	c.lock.Lock()
+	c.token = token
+	c.lease = lease
+	c.lock.Unlock()
+	return nil
+}
+
+// currentLease returns the lease on the current token.  A zero TTL indicates a token that does not expire.
+func (c *Client) currentLease() tokenLease {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lease
+}
+
+// RenewToken extends the lease on the client's token
+func (c *Client) RenewToken(ctx context.Context) error {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	r, err := c.do(ctx, "POST", "auth/token/renew-self", token, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+
+	if r.Auth == nil {
+		return ErrorNoSecret
+	}
+
+	c.lock.Lock()
+	c.lease = tokenLease{ttl: time.Duration(r.Auth.LeaseDuration) * time.Second, renewable: r.Auth.Renewable}
+	c.lock.Unlock()
+	return nil
+}
+
+// Read reads the secret at the given path, e.g. "secret/data/webpa/jwt".  Key/value version 2 responses are
+// unwrapped, so that Data always holds the secret's own fields.
+func (c *Client) Read(ctx context.Context, path string) (*Secret, error) {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := c.do(ctx, "GET", path, token, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Data == nil {
+		return nil, ErrorNoSecret
+	}
+
+	if inner, ok := r.Data["data"].(map[string]interface{}); ok {
+		if _, ok := r.Data["metadata"]; ok {
+			r.Data = inner
+		}
+	}
+
+	return &r.Secret, nil
+}
+
+// RenewLease extends the lease on a secret, returning the renewed lease.  The returned Secret has no Data.
+func (c *Client) RenewLease(ctx context.Context, leaseID string) (*Secret, error) {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := c.do(ctx, "PUT", "sys/leases/renew", token, map[string]string{"lease_id": leaseID})
+	if err != nil {
+		return nil, err
+	}
+
+	return &r.Secret, nil
+}
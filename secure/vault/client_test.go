@@ -0,0 +1,204 @@
+package vault
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient(t *testing.T) {
+	testData := []struct {
+		options  Options
+		expected error
+	}{
+		{Options{}, ErrorNoAddress},
+		{Options{Address: "http://localhost"}, ErrorNoAuthentication},
+		{Options{Address: "http://localhost", Token: "t", Kubernetes: &KubernetesOptions{Role: "r"}}, ErrorAmbiguousAuth},
+		{Options{Address: "http://localhost", Kubernetes: &KubernetesOptions{}}, ErrorNoKubernetesRole},
+		{Options{Address: "http://localhost", Token: "t"}, nil},
+		{Options{Address: "http://localhost", Kubernetes: &KubernetesOptions{Role: "r"}}, nil},
+	}
+
+	for i, record := range testData {
+		client, err := NewClient(record.options)
+		assert.Equal(t, record.expected, err, "#%d", i)
+		assert.Equal(t, record.expected == nil, client != nil, "#%d", i)
+	}
+}
+
+func testClientTokenLogin(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	vault.respond("GET /v1/auth/token/lookup-self", 0, map[string]interface{}{
+		"data": map[string]interface{}{"ttl": 3600, "renewable": true},
+	})
+
+	client, err := NewClient(Options{Address: vault.URL + "/", Token: "static", Namespace: "webpa"})
+	require.NoError(err)
+	require.NoError(client.Login(context.Background()))
+
+	request, _ := vault.lastRequest("GET /v1/auth/token/lookup-self")
+	require.NotNil(request)
+	assert.Equal("static", request.Header.Get(TokenHeader))
+	assert.Equal("webpa", request.Header.Get(NamespaceHeader))
+	assert.Equal(tokenLease{ttl: time.Hour, renewable: true}, client.currentLease())
+}
+
+func testClientKubernetesLogin(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	jwtFile, err := ioutil.TempFile("", "jwt")
+	require.NoError(err)
+	defer os.Remove(jwtFile.Name())
+	jwtFile.WriteString("service-account-token\n")
+	jwtFile.Close()
+
+	vault.respond("POST /v1/auth/k8s/login", 0, map[string]interface{}{
+		"auth": map[string]interface{}{"client_token": "issued", "lease_duration": 60, "renewable": false},
+	})
+
+	client, err := NewClient(Options{
+		Address:    vault.URL,
+		Kubernetes: &KubernetesOptions{Role: "webpa", Mount: "k8s", JWTFile: jwtFile.Name()},
+	})
+
+	require.NoError(err)
+	token, err := client.currentToken(context.Background())
+	require.NoError(err)
+	assert.Equal("issued", token)
+	assert.Equal(tokenLease{ttl: time.Minute}, client.currentLease())
+
+	request, body := vault.lastRequest("POST /v1/auth/k8s/login")
+	require.NotNil(request)
+	assert.Empty(request.Header.Get(TokenHeader))
+	assert.Equal(map[string]interface{}{"role": "webpa", "jwt": "service-account-token"}, body)
+
+	// the token is reused
+	_, err = client.currentToken(context.Background())
+	require.NoError(err)
+	assert.Equal(1, vault.count("POST /v1/auth/k8s/login"))
+}
+
+func testClientKubernetesLoginError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	client, err := NewClient(Options{
+		Address:    vault.URL,
+		Kubernetes: &KubernetesOptions{Role: "webpa", JWTFile: "/nosuch/file"},
+	})
+
+	require.NoError(err)
+	assert.Error(client.Login(context.Background()))
+	_, err = client.Read(context.Background(), "secret/webpa")
+	assert.Error(err)
+}
+
+func testClientRead(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	vault.respond("GET /v1/auth/token/lookup-self", 0, map[string]interface{}{"data": map[string]interface{}{}})
+	vault.respond("GET /v1/secret/v1", 0, map[string]interface{}{
+		"lease_duration": 120,
+		"data":           map[string]interface{}{"value": "v1"},
+	})
+
+	vault.respond("GET /v1/secret/data/v2", 0, map[string]interface{}{
+		"data": map[string]interface{}{
+			"data":     map[string]interface{}{"value": "v2"},
+			"metadata": map[string]interface{}{"version": 1},
+		},
+	})
+
+	vault.respond("GET /v1/secret/empty", 0, map[string]interface{}{})
+	vault.respond("GET /v1/secret/denied", http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+
+	client, err := NewClient(Options{Address: vault.URL, Token: "static"})
+	require.NoError(err)
+
+	secret, err := client.Read(context.Background(), "secret/v1")
+	require.NoError(err)
+	assert.Equal(map[string]interface{}{"value": "v1"}, secret.Data)
+	assert.Equal(2*time.Minute, secret.TTL())
+
+	secret, err = client.Read(context.Background(), "/secret/data/v2")
+	require.NoError(err)
+	assert.Equal(map[string]interface{}{"value": "v2"}, secret.Data)
+
+	_, err = client.Read(context.Background(), "secret/empty")
+	assert.Equal(ErrorNoSecret, err)
+
+	_, err = client.Read(context.Background(), "secret/denied")
+	require.Error(err)
+	responseError, ok := err.(*ResponseError)
+	require.True(ok)
+	assert.Equal(http.StatusForbidden, responseError.StatusCode)
+	assert.Equal([]string{"permission denied"}, responseError.Errors)
+	assert.Contains(responseError.Error(), "permission denied")
+
+	_, err = client.Read(context.Background(), "secret/nosuch")
+	assert.Error(err)
+}
+
+func testClientRenew(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	vault.respond("GET /v1/auth/token/lookup-self", 0, map[string]interface{}{"data": map[string]interface{}{"ttl": 60, "renewable": true}})
+	vault.respond("POST /v1/auth/token/renew-self", 0, map[string]interface{}{
+		"auth": map[string]interface{}{"client_token": "static", "lease_duration": 120, "renewable": true},
+	})
+
+	vault.respond("PUT /v1/sys/leases/renew", 0, map[string]interface{}{"lease_id": "database/creds/1", "lease_duration": 300, "renewable": true})
+
+	client, err := NewClient(Options{Address: vault.URL, Token: "static"})
+	require.NoError(err)
+
+	require.NoError(client.RenewToken(context.Background()))
+	assert.Equal(tokenLease{ttl: 2 * time.Minute, renewable: true}, client.currentLease())
+
+	renewed, err := client.RenewLease(context.Background(), "database/creds/1")
+	require.NoError(err)
+	assert.Equal(5*time.Minute, renewed.TTL())
+	assert.True(renewed.Renewable)
+
+	_, body := vault.lastRequest("PUT /v1/sys/leases/renew")
+	assert.Equal(map[string]interface{}{"lease_id": "database/creds/1"}, body)
+}
+
+func TestClient(t *testing.T) {
+	t.Run("TokenLogin", testClientTokenLogin)
+	t.Run("KubernetesLogin", testClientKubernetesLogin)
+	t.Run("KubernetesLoginError", testClientKubernetesLoginError)
+	t.Run("Read", testClientRead)
+	t.Run("Renew", testClientRenew)
+}
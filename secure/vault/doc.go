@@ -0,0 +1,9 @@
+/*
+Package vault integrates HashiCorp Vault as a source of key and secret material.  A Client authenticates with
+either a static token or the Kubernetes auth method and reads secrets over Vault's HTTP API.  A Provider caches
+those secrets and keeps their leases, and the client's token, renewed via a concurrent.Supervisor task.
+
+Secrets from a Provider can back JWT verification keys, through NewKeyResolver, basic auth credentials, through
+Provider.BasicAuth, and webhook HMAC secrets, through Provider.Value.
+*/
+package vault
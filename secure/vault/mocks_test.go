@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// fakeVault is an httptest server that answers Vault API requests with canned responses
+type fakeVault struct {
+	*httptest.Server
+
+	lock      sync.Mutex
+	responses map[string]interface{}
+	status    map[string]int
+	requests  map[string][]*http.Request
+	bodies    map[string][]map[string]interface{}
+}
+
+func newFakeVault() *fakeVault {
+	fv := &fakeVault{
+		responses: make(map[string]interface{}),
+		status:    make(map[string]int),
+		requests:  make(map[string][]*http.Request),
+		bodies:    make(map[string][]map[string]interface{}),
+	}
+
+	fv.Server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		fv.lock.Lock()
+		defer fv.lock.Unlock()
+
+		key := request.Method + " " + request.URL.Path
+		var body map[string]interface{}
+		json.NewDecoder(request.Body).Decode(&body)
+		fv.requests[key] = append(fv.requests[key], request)
+		fv.bodies[key] = append(fv.bodies[key], body)
+
+		value, ok := fv.responses[key]
+		if !ok {
+			response.WriteHeader(http.StatusNotFound)
+			response.Write([]byte(`{"errors": ["not found"]}`))
+			return
+		}
+
+		if status, ok := fv.status[key]; ok {
+			response.WriteHeader(status)
+		}
+
+		json.NewEncoder(response).Encode(value)
+	}))
+
+	return fv
+}
+
+func (fv *fakeVault) respond(key string, status int, value interface{}) {
+	fv.lock.Lock()
+	defer fv.lock.Unlock()
+	fv.responses[key] = value
+	if status > 0 {
+		fv.status[key] = status
+	} else {
+		delete(fv.status, key)
+	}
+}
+
+func (fv *fakeVault) count(key string) int {
+	fv.lock.Lock()
+	defer fv.lock.Unlock()
+	return len(fv.requests[key])
+}
+
+func (fv *fakeVault) lastRequest(key string) (*http.Request, map[string]interface{}) {
+	fv.lock.Lock()
+	defer fv.lock.Unlock()
+	if n := len(fv.requests[key]); n > 0 {
+		return fv.requests[key][n-1], fv.bodies[key][n-1]
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,109 @@
+package vault
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	// DefaultKubernetesMount is the mount path of the Kubernetes auth method when none is configured
+	DefaultKubernetesMount = "kubernetes"
+
+	// DefaultKubernetesJWTFile is the service account token file mounted into Kubernetes pods
+	DefaultKubernetesJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	// DefaultTimeout is the timeout for requests to Vault when none is configured
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultSecretTTL is how long a secret without a lease is cached when no SecretTTL is configured
+	DefaultSecretTTL = 5 * time.Minute
+)
+
+// KubernetesOptions configures the Kubernetes auth method, which exchanges a pod's service account token
+// for a Vault token
+type KubernetesOptions struct {
+	// Role is the Vault role to log in as.  This field is required.
+	Role string `json:"role"`
+
+	// Mount is the path at which the Kubernetes auth method is mounted.  If unset, DefaultKubernetesMount is used.
+	Mount string `json:"mount,omitempty"`
+
+	// JWTFile is the file containing the service account token.  If unset, DefaultKubernetesJWTFile is used.
+	JWTFile string `json:"jwtFile,omitempty"`
+}
+
+func (k *KubernetesOptions) mount() string {
+	if k != nil && len(k.Mount) > 0 {
+		return k.Mount
+	}
+
+	return DefaultKubernetesMount
+}
+
+func (k *KubernetesOptions) jwtFile() string {
+	if k != nil && len(k.JWTFile) > 0 {
+		return k.JWTFile
+	}
+
+	return DefaultKubernetesJWTFile
+}
+
+// Options configures a Vault Client.  Exactly one of Token or Kubernetes must be supplied.
+type Options struct {
+	// Address is the base URL of the Vault server, e.g. https://vault.example.com:8200.  This field is required.
+	Address string `json:"address"`
+
+	// Namespace is the optional Vault Enterprise namespace
+	Namespace string `json:"namespace,omitempty"`
+
+	// Token is a static Vault token
+	Token string `json:"token,omitempty"`
+
+	// Kubernetes enables the Kubernetes auth method
+	Kubernetes *KubernetesOptions `json:"kubernetes,omitempty"`
+
+	// Timeout is the timeout for each request to Vault.  If unset, DefaultTimeout is used.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// SecretTTL is how long a secret without a lease, such as a KV secret, is cached before it is read
+	// again.  This bounds how long a rotated secret goes unnoticed.  If unset, DefaultSecretTTL is used.
+	SecretTTL time.Duration `json:"secretTTL,omitempty"`
+
+	// HTTPClient is the client used to make requests.  If unset, a client with Timeout is created.
+	HTTPClient *http.Client `json:"-"`
+
+	// Logger is the go-kit logger.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger `json:"-"`
+}
+
+func (o *Options) httpClient() *http.Client {
+	if o.HTTPClient != nil {
+		return o.HTTPClient
+	}
+
+	timeout := o.Timeout
+	if timeout < 1 {
+		timeout = DefaultTimeout
+	}
+
+	return &http.Client{Timeout: timeout}
+}
+
+func (o *Options) secretTTL() time.Duration {
+	if o.SecretTTL > 0 {
+		return o.SecretTTL
+	}
+
+	return DefaultSecretTTL
+}
+
+func (o *Options) logger() log.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+
+	return logging.DefaultLogger()
+}
@@ -0,0 +1,50 @@
+package vault
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKubernetesOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, k := range []*KubernetesOptions{nil, new(KubernetesOptions)} {
+		assert.Equal(DefaultKubernetesMount, k.mount())
+		assert.Equal(DefaultKubernetesJWTFile, k.jwtFile())
+	}
+
+	k := &KubernetesOptions{Mount: "k8s", JWTFile: "/tmp/token"}
+	assert.Equal("k8s", k.mount())
+	assert.Equal("/tmp/token", k.jwtFile())
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o      Options
+		)
+
+		assert.Equal(DefaultTimeout, o.httpClient().Timeout)
+		assert.Equal(DefaultSecretTTL, o.secretTTL())
+		assert.NotNil(o.logger())
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			client = new(http.Client)
+			logger = logging.NewTestLogger(nil, t)
+			o      = Options{HTTPClient: client, Logger: logger}
+		)
+
+		assert.Equal(client, o.httpClient())
+		assert.Equal(logger, o.logger())
+		assert.Equal(DefaultTimeout*2, (&Options{Timeout: DefaultTimeout * 2}).httpClient().Timeout)
+		assert.Equal(time.Hour, (&Options{SecretTTL: time.Hour}).secretTTL())
+	})
+}
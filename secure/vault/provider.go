@@ -0,0 +1,279 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// RenewalTaskName is the name of the supervised task that renews leases
+	RenewalTaskName = "vault-renewal"
+
+	// DefaultRenewalInterval is how often the renewal task wakes up when nothing is due sooner
+	DefaultRenewalInterval = time.Minute
+)
+
+// FieldError is returned when a secret does not contain a requested field, or the field is not a string
+type FieldError struct {
+	Path  string
+	Field string
+}
+
+func (fe *FieldError) Error() string {
+	return fmt.Sprintf("Vault secret %s has no string field %s", fe.Path, fe.Field)
+}
+
+// cachedSecret is a Secret along with the time it is next due for renewal.  A secret without a lease is
+// due when its SecretTTL elapses, at which point it is evicted so that the next access reads it afresh.
+type cachedSecret struct {
+	secret *Secret
+	due    time.Time
+}
+
+// Provider caches secrets read from Vault.  Leases on cached secrets, and on the client's token, are
+// renewed at half their duration by a task run under a concurrent.Supervisor.  A secret whose lease cannot
+// be renewed, or a secret without a lease whose SecretTTL has elapsed, is evicted, so that the next access
+// reads it afresh.
+type Provider struct {
+	client *Client
+	logger log.Logger
+	now    func() time.Time
+
+	lock     sync.Mutex
+	secrets  map[string]*cachedSecret
+	tokenDue time.Time
+}
+
+// NewProvider creates a Provider that reads secrets through the given Client
+func NewProvider(client *Client) *Provider {
+	return &Provider{
+		client:  client,
+		logger:  client.logger,
+		now:     time.Now,
+		secrets: make(map[string]*cachedSecret),
+	}
+}
+
+// dueTime computes when a lease of the given duration, obtained now, should be renewed
+func (p *Provider) dueTime(ttl time.Duration) time.Time {
+	if ttl < 1 {
+		return time.Time{}
+	}
+
+	return p.now().Add(ttl / 2)
+}
+
+// secretDue computes when a newly read secret is due.  Leased secrets are due for renewal at half their lease
+// duration, while secrets without a lease are due for eviction after the client's SecretTTL.
+func (p *Provider) secretDue(secret *Secret) time.Time {
+	if secret.TTL() > 0 {
+		return p.dueTime(secret.TTL())
+	}
+
+	return p.now().Add(p.client.secretTTL)
+}
+
+// earliest returns the earlier of two times, where a zero time means never
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+
+	return a
+}
+
+// Secret returns the secret at the given path, reading it from Vault if it is not cached
+func (p *Provider) Secret(path string) (*Secret, error) {
+	p.lock.Lock()
+	cached, ok := p.secrets[path]
+	p.lock.Unlock()
+
+	if ok {
+		return cached.secret, nil
+	}
+
+	secret, err := p.client.Read(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	p.secrets[path] = &cachedSecret{secret: secret, due: p.secretDue(secret)}
+	p.lock.Unlock()
+
+	return secret, nil
+}
+
+// Value returns a single string field of the secret at the given path.  This is suitable for
+// secrets such as webhook HMAC secrets.
+func (p *Provider) Value(path, field string) (string, error) {
+	secret, err := p.Secret(path)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secret.Data[field].(string)
+	if !ok {
+		return "", &FieldError{Path: path, Field: field}
+	}
+
+	return value, nil
+}
+
+// BasicAuth returns the base64 encoding of the username and password fields of the secret at the given path.
+// The result is the value of a Basic Authorization header, without the scheme, suitable for secure.ExactMatchValidator.
+func (p *Provider) BasicAuth(path string) (string, error) {
+	username, err := p.Value(path, "username")
+	if err != nil {
+		return "", err
+	}
+
+	password, err := p.Value(path, "password")
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password)), nil
+}
+
+// renewToken renews the client's token, or logs in again if the token cannot be renewed
+func (p *Provider) renewToken(ctx context.Context) error {
+	if p.client.currentLease().renewable {
+		if err := p.client.RenewToken(ctx); err != nil {
+			return err
+		}
+	} else if err := p.client.Login(ctx); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	p.tokenDue = p.dueTime(p.client.currentLease().ttl)
+	p.lock.Unlock()
+	return nil
+}
+
+// renewSecrets renews each cached secret that is due, evicting those that cannot be renewed.  The returned
+// time is the earliest time at which anything, including the token, is next due.  Leases are renewed without
+// holding the lock, so that readers are never blocked on requests to Vault.
+func (p *Provider) renewSecrets(ctx context.Context) time.Time {
+	p.lock.Lock()
+	var (
+		now  = p.now()
+		next = p.tokenDue
+		due  = make(map[string]*cachedSecret)
+	)
+
+	for path, cached := range p.secrets {
+		if now.Before(cached.due) {
+			next = earliest(next, cached.due)
+			continue
+		}
+
+		if !cached.secret.Renewable || len(cached.secret.LeaseID) == 0 {
+			p.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "evicting expiring secret", "path", path)
+			delete(p.secrets, path)
+			continue
+		}
+
+		due[path] = cached
+	}
+
+	p.lock.Unlock()
+
+	for path, cached := range due {
+		renewed, err := p.client.RenewLease(ctx, cached.secret.LeaseID)
+
+		p.lock.Lock()
+		if p.secrets[path] != cached {
+			// the secret was replaced or evicted while its lease was being renewed
+			p.lock.Unlock()
+			continue
+		}
+
+		if err != nil {
+			p.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to renew lease", "path", path, logging.ErrorKey(), err)
+			delete(p.secrets, path)
+			p.lock.Unlock()
+			continue
+		}
+
+		// callers may hold the cached Secret, so a renewal replaces it rather than modifying it
+		secret := *cached.secret
+		secret.LeaseDuration = renewed.LeaseDuration
+		secret.Renewable = renewed.Renewable
+		updated := &cachedSecret{secret: &secret, due: p.secretDue(&secret)}
+		p.secrets[path] = updated
+		next = earliest(next, updated.due)
+		p.lock.Unlock()
+	}
+
+	return next
+}
+
+// renew performs one renewal pass, returning how long to wait before the next pass
+func (p *Provider) renew(ctx context.Context) (time.Duration, error) {
+	p.lock.Lock()
+	tokenDue := p.tokenDue
+	p.lock.Unlock()
+
+	if !tokenDue.IsZero() && !p.now().Before(tokenDue) {
+		if err := p.renewToken(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	next := p.renewSecrets(ctx)
+	if next.IsZero() {
+		return DefaultRenewalInterval, nil
+	}
+
+	wait := next.Sub(p.now())
+	if wait > DefaultRenewalInterval {
+		wait = DefaultRenewalInterval
+	}
+
+	return wait, nil
+}
+
+// task is the concurrent.Task that keeps the token and secret leases renewed.  It logs in when started, so
+// a restart after a failure obtains a fresh token.
+func (p *Provider) task(after func(time.Duration) <-chan time.Time) concurrent.Task {
+	return func(shutdown <-chan struct{}) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		if err := p.client.Login(ctx); err != nil {
+			return err
+		}
+
+		p.lock.Lock()
+		p.tokenDue = p.dueTime(p.client.currentLease().ttl)
+		p.lock.Unlock()
+
+		for {
+			wait, err := p.renew(ctx)
+			if err != nil {
+				return err
+			}
+
+			select {
+			case <-shutdown:
+				return nil
+			case <-after(wait):
+			}
+		}
+	}
+}
+
+// Supervise adds this Provider's renewal task to a Supervisor under RenewalTaskName
+func (p *Provider) Supervise(s *concurrent.Supervisor, policy concurrent.RestartPolicy) {
+	s.Add(RenewalTaskName, policy, p.task(time.After))
+}
@@ -0,0 +1,301 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestProvider creates a Provider for a fake Vault using a static token, with a controllable clock
+func newTestProvider(t *testing.T, vault *fakeVault, tokenTTL int, renewable bool) (*Provider, *time.Time) {
+	vault.respond("GET /v1/auth/token/lookup-self", 0, map[string]interface{}{
+		"data": map[string]interface{}{"ttl": tokenTTL, "renewable": renewable},
+	})
+
+	client, err := NewClient(Options{Address: vault.URL, Token: "static", Logger: logging.NewTestLogger(nil, t)})
+	require.NoError(t, err)
+
+	var (
+		provider = NewProvider(client)
+		now      = time.Now()
+	)
+
+	provider.now = func() time.Time { return now }
+	return provider, &now
+}
+
+func testProviderValue(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	provider, _ := newTestProvider(t, vault, 0, false)
+	vault.respond("GET /v1/secret/webhook", 0, map[string]interface{}{
+		"data": map[string]interface{}{"secret": "hmac", "number": 1},
+	})
+
+	value, err := provider.Value("secret/webhook", "secret")
+	require.NoError(err)
+	assert.Equal("hmac", value)
+
+	// the secret is cached
+	value, err = provider.Value("secret/webhook", "secret")
+	require.NoError(err)
+	assert.Equal("hmac", value)
+	assert.Equal(1, vault.count("GET /v1/secret/webhook"))
+
+	for _, field := range []string{"number", "nosuch"} {
+		_, err = provider.Value("secret/webhook", field)
+		assert.Equal(&FieldError{Path: "secret/webhook", Field: field}, err)
+		assert.Contains(err.Error(), field)
+	}
+
+	_, err = provider.Value("secret/nosuch", "secret")
+	assert.Error(err)
+}
+
+func testProviderBasicAuth(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	provider, _ := newTestProvider(t, vault, 0, false)
+	vault.respond("GET /v1/secret/basic", 0, map[string]interface{}{
+		"data": map[string]interface{}{"username": "user", "password": "pass"},
+	})
+
+	vault.respond("GET /v1/secret/nopassword", 0, map[string]interface{}{
+		"data": map[string]interface{}{"username": "user"},
+	})
+
+	token, err := provider.BasicAuth("secret/basic")
+	require.NoError(err)
+	assert.Equal(base64.StdEncoding.EncodeToString([]byte("user:pass")), token)
+
+	_, err = provider.BasicAuth("secret/nopassword")
+	assert.Error(err)
+
+	_, err = provider.BasicAuth("secret/nosuch")
+	assert.Error(err)
+}
+
+func testProviderRenew(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	provider, now := newTestProvider(t, vault, 60, true)
+	vault.respond("POST /v1/auth/token/renew-self", 0, map[string]interface{}{
+		"auth": map[string]interface{}{"client_token": "static", "lease_duration": 60, "renewable": true},
+	})
+
+	vault.respond("GET /v1/database/creds/renewable", 0, map[string]interface{}{
+		"lease_id": "renewable", "lease_duration": 20, "renewable": true, "data": map[string]interface{}{"password": "1"},
+	})
+
+	vault.respond("GET /v1/database/creds/fixed", 0, map[string]interface{}{
+		"lease_id": "fixed", "lease_duration": 20, "renewable": false, "data": map[string]interface{}{"password": "2"},
+	})
+
+	vault.respond("GET /v1/secret/forever", 0, map[string]interface{}{
+		"data": map[string]interface{}{"password": "3"},
+	})
+
+	vault.respond("PUT /v1/sys/leases/renew", 0, map[string]interface{}{"lease_id": "renewable", "lease_duration": 40, "renewable": true})
+
+	require.NoError(provider.client.Login(context.Background()))
+	provider.tokenDue = provider.dueTime(provider.client.currentLease().ttl)
+	for _, path := range []string{"database/creds/renewable", "database/creds/fixed", "secret/forever"} {
+		_, err := provider.Secret(path)
+		require.NoError(err)
+	}
+
+	// nothing is due yet, so the wait is until the secret leases are half expired
+	wait, err := provider.renew(context.Background())
+	require.NoError(err)
+	assert.Equal(10*time.Second, wait)
+	assert.Zero(vault.count("PUT /v1/sys/leases/renew"))
+
+	*now = now.Add(10 * time.Second)
+	wait, err = provider.renew(context.Background())
+	require.NoError(err)
+	assert.Equal(1, vault.count("PUT /v1/sys/leases/renew"))
+	assert.Equal(20*time.Second, wait)
+
+	provider.lock.Lock()
+	assert.Len(provider.secrets, 2)
+	assert.Contains(provider.secrets, "database/creds/renewable")
+	assert.Contains(provider.secrets, "secret/forever")
+	provider.lock.Unlock()
+
+	// the token is now due
+	*now = now.Add(20 * time.Second)
+	vault.respond("PUT /v1/sys/leases/renew", 500, map[string]interface{}{"errors": []string{"expected"}})
+	wait, err = provider.renew(context.Background())
+	require.NoError(err)
+	assert.Equal(1, vault.count("POST /v1/auth/token/renew-self"))
+	assert.Equal(30*time.Second, wait)
+
+	// the secret that failed renewal is evicted
+	provider.lock.Lock()
+	assert.Len(provider.secrets, 1)
+	provider.lock.Unlock()
+
+	*now = now.Add(30 * time.Second)
+	vault.respond("POST /v1/auth/token/renew-self", 403, map[string]interface{}{"errors": []string{"expected"}})
+	_, err = provider.renew(context.Background())
+	assert.Error(err)
+}
+
+func testProviderSecretTTL(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	provider, now := newTestProvider(t, vault, 0, false)
+	vault.respond("GET /v1/secret/webhook", 0, map[string]interface{}{
+		"data": map[string]interface{}{"secret": "original"},
+	})
+
+	value, err := provider.Value("secret/webhook", "secret")
+	require.NoError(err)
+	assert.Equal("original", value)
+
+	wait, err := provider.renew(context.Background())
+	require.NoError(err)
+	assert.Equal(DefaultRenewalInterval, wait)
+
+	// a secret without a lease is evicted once the secret TTL elapses, so a rotation is eventually seen
+	vault.respond("GET /v1/secret/webhook", 0, map[string]interface{}{
+		"data": map[string]interface{}{"secret": "rotated"},
+	})
+
+	*now = now.Add(DefaultSecretTTL)
+	_, err = provider.renew(context.Background())
+	require.NoError(err)
+
+	value, err = provider.Value("secret/webhook", "secret")
+	require.NoError(err)
+	assert.Equal("rotated", value)
+	assert.Equal(2, vault.count("GET /v1/secret/webhook"))
+}
+
+func testProviderRenewNothing(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	provider, _ := newTestProvider(t, vault, 0, false)
+	wait, err := provider.renew(context.Background())
+	require.NoError(err)
+	assert.Equal(DefaultRenewalInterval, wait)
+}
+
+func testProviderTask(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+
+		after    = make(chan time.Time)
+		waits    = make(chan time.Duration, 10)
+		shutdown = make(chan struct{})
+		result   = make(chan error, 1)
+	)
+
+	defer vault.Close()
+	provider, _ := newTestProvider(t, vault, 7200, false)
+	task := provider.task(func(d time.Duration) <-chan time.Time {
+		waits <- d
+		return after
+	})
+
+	go func() {
+		result <- task(shutdown)
+	}()
+
+	select {
+	case wait := <-waits:
+		assert.Equal(DefaultRenewalInterval, wait)
+	case <-time.After(5 * time.Second):
+		require.Fail("the task did not wait")
+	}
+
+	assert.Equal(1, vault.count("GET /v1/auth/token/lookup-self"))
+	after <- time.Now()
+	<-waits
+
+	close(shutdown)
+	select {
+	case err := <-result:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		require.Fail("the task did not exit")
+	}
+}
+
+func testProviderTaskLoginError(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		vault    = newFakeVault()
+		shutdown = make(chan struct{})
+	)
+
+	defer vault.Close()
+	provider, _ := newTestProvider(t, vault, 0, false)
+	vault.respond("GET /v1/auth/token/lookup-self", 403, map[string]interface{}{"errors": []string{"expected"}})
+	assert.Error(provider.task(time.After)(shutdown))
+}
+
+func testProviderSupervise(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		vault  = newFakeVault()
+
+		supervisor = concurrent.NewSupervisor(concurrent.SupervisorOptions{Logger: logging.NewTestLogger(nil, t)})
+		waitGroup  = new(sync.WaitGroup)
+		shutdown   = make(chan struct{})
+	)
+
+	defer vault.Close()
+	provider, _ := newTestProvider(t, vault, 0, false)
+	provider.Supervise(supervisor, concurrent.RestartPolicy{})
+	assert.NoError(supervisor.Run(waitGroup, shutdown))
+
+	close(shutdown)
+	waitGroup.Wait()
+	assert.Zero(supervisor.Restarts(RenewalTaskName))
+}
+
+func TestProvider(t *testing.T) {
+	t.Run("Value", testProviderValue)
+	t.Run("BasicAuth", testProviderBasicAuth)
+	t.Run("Renew", testProviderRenew)
+	t.Run("SecretTTL", testProviderSecretTTL)
+	t.Run("RenewNothing", testProviderRenewNothing)
+	t.Run("Task", testProviderTask)
+	t.Run("TaskLoginError", testProviderTaskLoginError)
+	t.Run("Supervise", testProviderSupervise)
+}
@@ -0,0 +1,63 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Comcast/webpa-common/secure/key"
+)
+
+// ErrorInvalidKeyID is returned when a key identifier contains characters that could alter the Vault path
+// it is substituted into.  Key identifiers usually come from untrusted tokens, so they are never used unchecked.
+var ErrorInvalidKeyID = errors.New("Invalid key identifier")
+
+// validKeyID matches the key identifiers that are safe to substitute into a Vault path.  Identifiers may not begin
+// with a dot, which rules out the relative path segments "." and "..".
+var validKeyID = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// keyResolver is a key.Resolver backed by PEM-encoded keys stored in Vault
+type keyResolver struct {
+	provider *Provider
+	path     string
+	field    string
+	purpose  key.Purpose
+	parser   key.Parser
+}
+
+func (kr *keyResolver) String() string {
+	return fmt.Sprintf("vault.keyResolver{path: %s, field: %s, purpose: %v}", kr.path, kr.field, kr.purpose)
+}
+
+func (kr *keyResolver) ResolveKey(keyId string) (key.Pair, error) {
+	if !validKeyID.MatchString(keyId) {
+		return nil, ErrorInvalidKeyID
+	}
+
+	path := strings.Replace(kr.path, "{"+key.KeyIdParameterName+"}", keyId, -1)
+	data, err := kr.provider.Value(path, kr.field)
+	if err != nil {
+		return nil, err
+	}
+
+	return kr.parser.ParseKey(kr.purpose, []byte(data))
+}
+
+// NewKeyResolver creates a key.Resolver that reads PEM-encoded keys from a field of a Vault secret, e.g. for JWT
+// verification.  The path may contain a {keyId} parameter, which is replaced with the requested key identifier.
+// Key identifiers may contain only letters, digits, dots, underscores, and hyphens, and may not begin with a dot.
+// If parser is nil, key.DefaultParser is used.  Keys are cached, and refreshed, by the Provider.
+func NewKeyResolver(p *Provider, path, field string, purpose key.Purpose, parser key.Parser) key.Resolver {
+	if parser == nil {
+		parser = key.DefaultParser
+	}
+
+	return &keyResolver{
+		provider: p,
+		path:     path,
+		field:    field,
+		purpose:  purpose,
+		parser:   parser,
+	}
+}
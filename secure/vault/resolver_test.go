@@ -0,0 +1,60 @@
+package vault
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/Comcast/webpa-common/secure/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyResolver(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		vault   = newFakeVault()
+	)
+
+	defer vault.Close()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(err)
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(err)
+
+	provider, _ := newTestProvider(t, vault, 0, false)
+	vault.respond("GET /v1/secret/data/jwt/current", 0, map[string]interface{}{
+		"data": map[string]interface{}{
+			"data":     map[string]interface{}{"public": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))},
+			"metadata": map[string]interface{}{},
+		},
+	})
+
+	vault.respond("GET /v1/secret/data/jwt/invalid", 0, map[string]interface{}{
+		"data": map[string]interface{}{"public": "not a key"},
+	})
+
+	resolver := NewKeyResolver(provider, "secret/data/jwt/{keyId}", "public", key.PurposeVerify, nil)
+	assert.Contains(resolver.(*keyResolver).String(), "secret/data/jwt/{keyId}")
+
+	pair, err := resolver.ResolveKey("current")
+	require.NoError(err)
+	require.NotNil(pair)
+	assert.Equal(key.PurposeVerify, pair.Purpose())
+	assert.Equal(&privateKey.PublicKey, pair.Public())
+
+	_, err = resolver.ResolveKey("invalid")
+	assert.Error(err)
+
+	_, err = resolver.ResolveKey("nosuch")
+	assert.Error(err)
+
+	for _, keyID := range []string{"", ".", "..", "../../sys/raw", "jwt/current", ".hidden", "current?version=1"} {
+		_, err = resolver.ResolveKey(keyID)
+		assert.Equal(ErrorInvalidKeyID, err, keyID)
+	}
+}
@@ -0,0 +1,9 @@
+/*
+Package semaphore provides counting semaphores with weighted, context-aware acquisition.  A semaphore
+can operate in fair mode, where waiters are granted units in strict FIFO order, or in the default
+unfair mode, which favors throughput by granting units to any waiter whose request fits.
+
+Semaphores are used to bound concurrency, e.g. the number of concurrent fanout requests or the number
+of messages queued to a device.
+*/
+package semaphore
@@ -0,0 +1,248 @@
+package semaphore
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+var (
+	// ErrTooManyUnits is returned when a request is for more units than a semaphore has in total
+	ErrTooManyUnits = errors.New("The requested number of units exceeds the size of the semaphore")
+
+	// ErrInvalidUnits is returned when a request is for a nonpositive number of units
+	ErrInvalidUnits = errors.New("The number of units must be positive")
+)
+
+// Interface represents a counting semaphore.  Each acquisition obtains one or more units, and must be
+// balanced by a release of the same number of units.
+type Interface interface {
+	// Acquire obtains a single unit, blocking until one is available or the context is canceled.
+	// If the context is canceled first, no units are obtained and the context's error is returned.
+	Acquire(context.Context) error
+
+	// AcquireN obtains n units at once, blocking until they are all available or the context is canceled.
+	// Either all n units are obtained or none are.
+	AcquireN(context.Context, int64) error
+
+	// TryAcquire obtains a single unit only if one is immediately available
+	TryAcquire() bool
+
+	// TryAcquireN obtains n units only if they are all immediately available
+	TryAcquireN(int64) bool
+
+	// Release returns a single unit
+	Release()
+
+	// ReleaseN returns n units.  Releasing more units than are held panics.
+	ReleaseN(int64)
+}
+
+// Option is a configuration option for a semaphore
+type Option func(*semaphore)
+
+// WithFair configures a semaphore to grant units in strict FIFO order.  In fair mode, a large request
+// at the head of the queue blocks subsequent smaller requests, even when those would fit.  This prevents
+// large requests from starving.
+func WithFair() Option {
+	return func(s *semaphore) {
+		s.fair = true
+	}
+}
+
+// WithHeldGauge configures a gauge that tracks the number of units currently held
+func WithHeldGauge(g xmetrics.Setter) Option {
+	return func(s *semaphore) {
+		if g != nil {
+			s.held = g
+		} else {
+			s.held = discard.NewGauge()
+		}
+	}
+}
+
+// WithWaitersGauge configures a gauge that tracks the number of goroutines blocked waiting for units
+func WithWaitersGauge(g xmetrics.Setter) Option {
+	return func(s *semaphore) {
+		if g != nil {
+			s.waitersGauge = g
+		} else {
+			s.waitersGauge = discard.NewGauge()
+		}
+	}
+}
+
+// New constructs a semaphore with the given total number of units.  This function panics if size is nonpositive.
+func New(size int64, options ...Option) Interface {
+	if size < 1 {
+		panic("the size of a semaphore must be positive")
+	}
+
+	s := &semaphore{
+		size:         size,
+		held:         discard.NewGauge(),
+		waitersGauge: discard.NewGauge(),
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	s.held.Set(0.0)
+	s.waitersGauge.Set(0.0)
+	return s
+}
+
+// waiter is a blocked request for units
+type waiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// semaphore is the internal Interface implementation
+type semaphore struct {
+	size int64
+	fair bool
+
+	held         xmetrics.Setter
+	waitersGauge xmetrics.Setter
+
+	lock    sync.Mutex
+	current int64
+	waiters list.List
+}
+
+func (s *semaphore) Acquire(ctx context.Context) error {
+	return s.AcquireN(ctx, 1)
+}
+
+func (s *semaphore) AcquireN(ctx context.Context, n int64) error {
+	if n < 1 {
+		return ErrInvalidUnits
+	} else if n > s.size {
+		return ErrTooManyUnits
+	}
+
+	s.lock.Lock()
+	if s.canAcquire(n) {
+		s.acquire(n)
+		s.lock.Unlock()
+		return nil
+	}
+
+	w := &waiter{n: n, ready: make(chan struct{})}
+	element := s.waiters.PushBack(w)
+	s.waitersGauge.Set(float64(s.waiters.Len()))
+	s.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+
+	case <-ctx.Done():
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		select {
+		case <-w.ready:
+			// the units were granted concurrently with the cancellation, so give them back
+			s.current -= n
+			s.held.Set(float64(s.current))
+		default:
+			isFront := s.waiters.Front() == element
+			s.waiters.Remove(element)
+			s.waitersGauge.Set(float64(s.waiters.Len()))
+
+			// in fair mode, removing the head waiter can unblock the waiters behind it
+			if !isFront || !s.fair {
+				return ctx.Err()
+			}
+		}
+
+		s.notifyWaiters()
+		return ctx.Err()
+	}
+}
+
+func (s *semaphore) TryAcquire() bool {
+	return s.TryAcquireN(1)
+}
+
+func (s *semaphore) TryAcquireN(n int64) bool {
+	if n < 1 || n > s.size {
+		return false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.canAcquire(n) {
+		s.acquire(n)
+		return true
+	}
+
+	return false
+}
+
+func (s *semaphore) Release() {
+	s.ReleaseN(1)
+}
+
+func (s *semaphore) ReleaseN(n int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if n < 1 || n > s.current {
+		panic("semaphore: released more units than are held")
+	}
+
+	s.current -= n
+	s.held.Set(float64(s.current))
+	s.notifyWaiters()
+}
+
+// canAcquire tests if n units can be obtained without waiting.  In fair mode, an acquisition cannot
+// jump ahead of goroutines that are already waiting.  This method must be invoked under the lock.
+func (s *semaphore) canAcquire(n int64) bool {
+	if s.fair && s.waiters.Len() > 0 {
+		return false
+	}
+
+	return s.size-s.current >= n
+}
+
+// acquire obtains units.  This method must be invoked under the lock.
+func (s *semaphore) acquire(n int64) {
+	s.current += n
+	s.held.Set(float64(s.current))
+}
+
+// notifyWaiters grants units to as many waiters as possible.  In fair mode, granting stops at the first
+// waiter that doesn't fit.  Otherwise, every waiter that fits is granted units in queue order.
+// This method must be invoked under the lock.
+func (s *semaphore) notifyWaiters() {
+	for element := s.waiters.Front(); element != nil; {
+		w := element.Value.(*waiter)
+		next := element.Next()
+
+		if s.size-s.current < w.n {
+			if s.fair {
+				break
+			}
+
+			element = next
+			continue
+		}
+
+		s.acquire(w.n)
+		s.waiters.Remove(element)
+		close(w.ready)
+		element = next
+	}
+
+	s.waitersGauge.Set(float64(s.waiters.Len()))
+}
@@ -0,0 +1,221 @@
+package semaphore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// acquireAsync starts a goroutine that acquires n units, returning a channel that receives the result
+func acquireAsync(ctx context.Context, s Interface, n int64) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- s.AcquireN(ctx, n)
+	}()
+
+	return result
+}
+
+// waitForWaiters blocks until the semaphore has the given number of waiters
+func waitForWaiters(t *testing.T, s Interface, expected int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		impl := s.(*semaphore)
+		impl.lock.Lock()
+		count := impl.waiters.Len()
+		impl.lock.Unlock()
+
+		if count == expected {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("The semaphore did not reach %d waiters", expected)
+}
+
+func assertAcquired(t *testing.T, result <-chan error) {
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The acquisition did not complete")
+	}
+}
+
+func assertBlocked(t *testing.T, result <-chan error) {
+	select {
+	case err := <-result:
+		t.Fatalf("The acquisition should have blocked, but returned %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestNewInvalidSize(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { New(0) })
+	assert.Panics(func() { New(-1) })
+}
+
+func TestInvalidUnits(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		s      = New(2)
+	)
+
+	assert.Equal(ErrInvalidUnits, s.AcquireN(context.Background(), 0))
+	assert.Equal(ErrTooManyUnits, s.AcquireN(context.Background(), 3))
+	assert.False(s.TryAcquireN(0))
+	assert.False(s.TryAcquireN(3))
+	assert.Panics(func() { s.Release() })
+	assert.Panics(func() { s.ReleaseN(0) })
+}
+
+func TestAcquireRelease(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		held    = generic.NewGauge("held")
+		waiters = generic.NewGauge("waiters")
+		s       = New(3, WithHeldGauge(held), WithWaitersGauge(waiters))
+	)
+
+	require.NotNil(s)
+	assert.Zero(held.Value())
+	assert.Zero(waiters.Value())
+
+	assert.NoError(s.Acquire(context.Background()))
+	assert.True(s.TryAcquireN(2))
+	assert.Equal(3.0, held.Value())
+	assert.False(s.TryAcquire())
+
+	result := acquireAsync(context.Background(), s, 2)
+	waitForWaiters(t, s, 1)
+	assert.Equal(1.0, waiters.Value())
+
+	s.Release()
+	assertBlocked(t, result)
+
+	s.Release()
+	assertAcquired(t, result)
+	assert.Equal(3.0, held.Value())
+	assert.Zero(waiters.Value())
+
+	s.ReleaseN(3)
+	assert.Zero(held.Value())
+	assert.Panics(func() { s.Release() })
+}
+
+func TestNilGauges(t *testing.T) {
+	s := New(1, WithHeldGauge(nil), WithWaitersGauge(nil))
+	assert.True(t, s.TryAcquire())
+	s.Release()
+}
+
+func TestUnfairAllowsSmallerRequests(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		s      = New(3)
+	)
+
+	assert.True(s.TryAcquireN(2))
+
+	large := acquireAsync(context.Background(), s, 3)
+	waitForWaiters(t, s, 1)
+
+	// a smaller request fits, and in unfair mode it may jump ahead of the large waiter
+	assert.True(s.TryAcquire())
+	s.Release()
+
+	small := acquireAsync(context.Background(), s, 1)
+	assertAcquired(t, small)
+
+	s.ReleaseN(3)
+	assertAcquired(t, large)
+}
+
+func TestFairIsFIFO(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		s      = New(3, WithFair())
+	)
+
+	assert.True(s.TryAcquireN(2))
+
+	large := acquireAsync(context.Background(), s, 3)
+	waitForWaiters(t, s, 1)
+
+	// the large waiter blocks the smaller request, even though it would fit
+	assert.False(s.TryAcquire())
+	small := acquireAsync(context.Background(), s, 1)
+	waitForWaiters(t, s, 2)
+	assertBlocked(t, small)
+
+	s.ReleaseN(2)
+	assertAcquired(t, large)
+	assertBlocked(t, small)
+
+	s.ReleaseN(3)
+	assertAcquired(t, small)
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	for _, fair := range []bool{false, true} {
+		t.Logf("fair: %v", fair)
+
+		var (
+			assert  = assert.New(t)
+			options []Option
+		)
+
+		if fair {
+			options = append(options, WithFair())
+		}
+
+		var (
+			waiters     = generic.NewGauge("waiters")
+			s           = New(2, append(options, WithWaitersGauge(waiters))...)
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+
+		assert.True(s.TryAcquire())
+
+		large := acquireAsync(ctx, s, 2)
+		waitForWaiters(t, s, 1)
+		small := acquireAsync(context.Background(), s, 1)
+
+		if fair {
+			waitForWaiters(t, s, 2)
+			assertBlocked(t, small)
+		} else {
+			assertAcquired(t, small)
+			s.Release()
+		}
+
+		cancel()
+		select {
+		case err := <-large:
+			assert.Equal(context.Canceled, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("The canceled acquisition did not return")
+		}
+
+		if fair {
+			// canceling the head of the queue unblocks the waiters behind it
+			assertAcquired(t, small)
+			s.Release()
+		}
+
+		waitForWaiters(t, s, 0)
+		assert.Zero(waiters.Value())
+
+		s.Release()
+		assert.True(s.TryAcquireN(2))
+	}
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// Component is a named piece of server infrastructure whose startup and shutdown are managed by a Lifecycle,
+// e.g. metrics, service discovery, a device manager, or a listener.
+type Component struct {
+	// Name uniquely identifies this component within a Lifecycle
+	Name string
+
+	// DependsOn holds the names of the components that must be started before this one
+	DependsOn []string
+
+	// Start brings this component up.  This function is optional.
+	Start func() error
+
+	// Stop shuts this component down.  This function is optional.
+	Stop func() error
+}
+
+// LifecycleErrors aggregates the errors that occur while starting or stopping components
+type LifecycleErrors []error
+
+func (le LifecycleErrors) Error() string {
+	var output bytes.Buffer
+	output.WriteString("Lifecycle errors: [")
+	for i, err := range le {
+		if i > 0 {
+			output.WriteString(", ")
+		}
+
+		output.WriteString(err.Error())
+	}
+
+	output.WriteRune(']')
+	return output.String()
+}
+
+// Unwrap exposes the aggregated errors, so that errors.Is and errors.As can match any of them
+func (le LifecycleErrors) Unwrap() []error {
+	return le
+}
+
+// Lifecycle starts registered components in dependency order and stops them in reverse order.
+// The zero value is ready to use, and logs to the default logger.
+type Lifecycle struct {
+	Logger log.Logger
+
+	lock       sync.Mutex
+	components []Component
+	started    bool
+	running    []Component
+}
+
+func (l *Lifecycle) logger() log.Logger {
+	if l.Logger != nil {
+		return l.Logger
+	}
+
+	return logging.DefaultLogger()
+}
+
+// Register adds a component to this lifecycle.  An error is returned if the component has no name
+// or if a component with the same name has already been registered.
+func (l *Lifecycle) Register(c Component) error {
+	if len(c.Name) == 0 {
+		return fmt.Errorf("A component name is required")
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, existing := range l.components {
+		if existing.Name == c.Name {
+			return fmt.Errorf("Component %s is already registered", c.Name)
+		}
+	}
+
+	l.components = append(l.components, c)
+	return nil
+}
+
+// order sorts the registered components so that every component follows its dependencies.  Registration order
+// is preserved where dependencies allow.  Missing dependencies and cycles are reported as errors.
+func (l *Lifecycle) order() ([]Component, error) {
+	var (
+		byName  = make(map[string]Component, len(l.components))
+		visited = make(map[string]bool, len(l.components))
+		active  = make(map[string]bool, len(l.components))
+		ordered = make([]Component, 0, len(l.components))
+		errs    LifecycleErrors
+		visit   func(Component)
+	)
+
+	for _, c := range l.components {
+		byName[c.Name] = c
+	}
+
+	visit = func(c Component) {
+		if visited[c.Name] {
+			return
+		}
+
+		if active[c.Name] {
+			errs = append(errs, fmt.Errorf("Component %s has a circular dependency", c.Name))
+			return
+		}
+
+		active[c.Name] = true
+		for _, name := range c.DependsOn {
+			if dependency, ok := byName[name]; ok {
+				visit(dependency)
+			} else {
+				errs = append(errs, fmt.Errorf("Component %s depends on unregistered component %s", c.Name, name))
+			}
+		}
+
+		active[c.Name] = false
+		visited[c.Name] = true
+		ordered = append(ordered, c)
+	}
+
+	for _, c := range l.components {
+		visit(c)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return ordered, nil
+}
+
+// Start starts each registered component in dependency order.  If any component fails to start, startup halts
+// immediately, every component already started is stopped in reverse order, and all errors are returned together
+// as LifecycleErrors.  Dependency errors, such as cycles, are detected before any component is started.
+func (l *Lifecycle) Start() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.started {
+		return fmt.Errorf("Lifecycle has already been started")
+	}
+
+	ordered, err := l.order()
+	if err != nil {
+		return err
+	}
+
+	l.started = true
+	logger := l.logger()
+	for _, c := range ordered {
+		if c.Start != nil {
+			logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "starting component", "component", c.Name)
+			if err := c.Start(); err != nil {
+				logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "component failed to start", "component", c.Name, logging.ErrorKey(), err)
+				errs := LifecycleErrors{fmt.Errorf("Component %s failed to start: %w", c.Name, err)}
+				if stopErr := l.stop(); stopErr != nil {
+					errs = append(errs, stopErr.(LifecycleErrors)...)
+				}
+
+				return errs
+			}
+		}
+
+		l.running = append(l.running, c)
+	}
+
+	return nil
+}
+
+// stop is the internal, unlocked version of Stop
+func (l *Lifecycle) stop() error {
+	var (
+		logger = l.logger()
+		errs   LifecycleErrors
+	)
+
+	for i := len(l.running) - 1; i >= 0; i-- {
+		c := l.running[i]
+		if c.Stop != nil {
+			logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "stopping component", "component", c.Name)
+			if err := c.Stop(); err != nil {
+				logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "component failed to stop", "component", c.Name, logging.ErrorKey(), err)
+				errs = append(errs, fmt.Errorf("Component %s failed to stop: %w", c.Name, err))
+			}
+		}
+	}
+
+	l.started = false
+	l.running = nil
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Stop stops every started component in the reverse order in which they were started.  Every component is
+// given a chance to stop, and any errors are returned together as LifecycleErrors.  This method is idempotent.
+// Once stopped, a Lifecycle may be started again.
+func (l *Lifecycle) Stop() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.stop()
+}
+
+// Run allows a Lifecycle to be used as a concurrent.Runnable.  Components are started immediately, and are
+// stopped when the shutdown channel is closed.  WebPA.Prepare runs the Lifecycle injected into WebPA this way.
+func (l *Lifecycle) Run(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
+	if err := l.Start(); err != nil {
+		return err
+	}
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		<-shutdown
+		l.Stop()
+	}()
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingComponent produces a Component that records start and stop events
+func recordingComponent(events *[]string, name string, startErr, stopErr error, dependsOn ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func() error {
+			*events = append(*events, "start "+name)
+			return startErr
+		},
+		Stop: func() error {
+			*events = append(*events, "stop "+name)
+			return stopErr
+		},
+	}
+}
+
+func TestLifecycleErrors(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("Lifecycle errors: []", LifecycleErrors{}.Error())
+	assert.Equal("Lifecycle errors: [one, two]", LifecycleErrors{errors.New("one"), errors.New("two")}.Error())
+}
+
+func testLifecycleRegister(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		l      Lifecycle
+	)
+
+	assert.Error(l.Register(Component{}))
+	assert.NoError(l.Register(Component{Name: "test"}))
+	assert.Error(l.Register(Component{Name: "test"}))
+}
+
+func testLifecycleStartStop(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		events []string
+		l      = Lifecycle{Logger: logging.NewTestLogger(nil, t)}
+	)
+
+	require.NoError(l.Register(recordingComponent(&events, "listener", nil, nil, "manager", "metrics")))
+	require.NoError(l.Register(recordingComponent(&events, "manager", nil, nil, "discovery")))
+	require.NoError(l.Register(recordingComponent(&events, "metrics", nil, nil)))
+	require.NoError(l.Register(recordingComponent(&events, "discovery", nil, nil, "metrics")))
+	require.NoError(l.Register(Component{Name: "noop", DependsOn: []string{"metrics"}}))
+
+	require.NoError(l.Start())
+	assert.Equal([]string{"start metrics", "start discovery", "start manager", "start listener"}, events)
+	assert.Error(l.Start())
+
+	events = nil
+	assert.NoError(l.Stop())
+	assert.Equal([]string{"stop listener", "stop manager", "stop discovery", "stop metrics"}, events)
+
+	events = nil
+	assert.NoError(l.Stop())
+	assert.Empty(events)
+
+	// a stopped lifecycle can be started again
+	require.NoError(l.Start())
+	assert.Equal([]string{"start metrics", "start discovery", "start manager", "start listener"}, events)
+	assert.NoError(l.Stop())
+}
+
+func testLifecycleStartTwiceNoComponents(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		l      = Lifecycle{Logger: logging.NewTestLogger(nil, t)}
+	)
+
+	// the guard must not depend on whether any components were started
+	assert.NoError(l.Start())
+	assert.Error(l.Start())
+	assert.NoError(l.Stop())
+	assert.NoError(l.Start())
+}
+
+func testLifecycleStartFailure(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		startError = errors.New("start")
+		stopError  = errors.New("stop")
+
+		events []string
+		l      = Lifecycle{Logger: logging.NewTestLogger(nil, t)}
+	)
+
+	require.NoError(l.Register(recordingComponent(&events, "first", nil, stopError)))
+	require.NoError(l.Register(recordingComponent(&events, "second", nil, nil, "first")))
+	require.NoError(l.Register(recordingComponent(&events, "third", startError, nil, "second")))
+	require.NoError(l.Register(recordingComponent(&events, "fourth", nil, nil, "third")))
+
+	err := l.Start()
+	require.Error(err)
+	require.IsType(LifecycleErrors{}, err)
+	assert.Len(err.(LifecycleErrors), 2)
+	assert.True(errors.Is(err, startError))
+	assert.True(errors.Is(err, stopError))
+	assert.Equal([]string{"start first", "start second", "start third", "stop second", "stop first"}, events)
+}
+
+func testLifecycleDependencyErrors(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		events []string
+		l      Lifecycle
+	)
+
+	require.NoError(l.Register(recordingComponent(&events, "first", nil, nil, "second")))
+	require.NoError(l.Register(recordingComponent(&events, "second", nil, nil, "first")))
+	require.NoError(l.Register(recordingComponent(&events, "third", nil, nil, "missing")))
+
+	err := l.Start()
+	require.Error(err)
+	assert.Len(err.(LifecycleErrors), 2)
+	assert.Empty(events)
+}
+
+func testLifecycleRun(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		events    []string
+		l         = Lifecycle{Logger: logging.NewTestLogger(nil, t)}
+		waitGroup = new(sync.WaitGroup)
+		shutdown  = make(chan struct{})
+	)
+
+	require.NoError(l.Register(recordingComponent(&events, "test", nil, nil)))
+	require.NoError(l.Run(waitGroup, shutdown))
+	close(shutdown)
+	waitGroup.Wait()
+
+	assert.Equal([]string{"start test", "stop test"}, events)
+}
+
+func TestLifecycle(t *testing.T) {
+	t.Run("Register", testLifecycleRegister)
+	t.Run("StartStop", testLifecycleStartStop)
+	t.Run("StartTwiceNoComponents", testLifecycleStartTwiceNoComponents)
+	t.Run("StartFailure", testLifecycleStartFailure)
+	t.Run("DependencyErrors", testLifecycleDependencyErrors)
+	t.Run("Run", testLifecycleRun)
+}
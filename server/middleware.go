@@ -0,0 +1,326 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/health"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/logging/logginghttp"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/justinas/alice"
+)
+
+const (
+	// RecoverMiddleware is the name of the builtin middleware that converts handler panics into 500 responses
+	RecoverMiddleware = "recover"
+
+	// LoggerMiddleware is the name of the builtin middleware that populates a request logger into the context
+	LoggerMiddleware = "logger"
+
+	// AccessLogMiddleware is the name of the builtin middleware that logs each request once it has been served
+	AccessLogMiddleware = "accesslog"
+
+	// GzipMiddleware is the name of the builtin middleware that compresses responses for clients that accept gzip
+	GzipMiddleware = "gzip"
+
+	// DeadlineMiddleware is the name of the builtin middleware that rejects requests whose client-supplied
+	// deadline has passed and applies the deadline to the request context.  See xhttp.Deadline.
+	DeadlineMiddleware = "deadline"
+)
+
+// MiddlewareFactory creates an Alice-style constructor for one named element of a server's middleware pipeline.
+// The logger is the server's base logger.
+type MiddlewareFactory func(logger log.Logger) (alice.Constructor, error)
+
+var middlewareFactories = struct {
+	lock      sync.RWMutex
+	factories map[string]MiddlewareFactory
+}{
+	factories: map[string]MiddlewareFactory{
+		RecoverMiddleware:   func(log.Logger) (alice.Constructor, error) { return Recover, nil },
+		LoggerMiddleware:    func(l log.Logger) (alice.Constructor, error) { return logginghttp.PopulateLogger(l), nil },
+		AccessLogMiddleware: func(l log.Logger) (alice.Constructor, error) { return AccessLog(l), nil },
+		GzipMiddleware:      func(log.Logger) (alice.Constructor, error) { return Gzip, nil },
+		DeadlineMiddleware:  func(log.Logger) (alice.Constructor, error) { return xhttp.Deadline(), nil },
+	},
+}
+
+// RegisterMiddleware makes a MiddlewareFactory available under the given name, so that it can be referenced
+// in the Middleware configuration of a WebPA server.  Registering a name that already exists, including one
+// of the builtins, replaces the existing factory.
+//
+// Middleware that needs application-specific configuration or credentials, such as authentication or rate
+// limiting, is not builtin.  Applications register it under a name of their choosing, conventionally "auth"
+// and "ratelimit", before calling WebPA.Prepare.  See the example for a routeauth.Policy and a concurrency limit.
+//
+// If name is empty or factory is nil, this function panics.
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	if len(name) == 0 {
+		panic("A middleware name is required")
+	}
+
+	if factory == nil {
+		panic("A middleware factory is required")
+	}
+
+	middlewareFactories.lock.Lock()
+	middlewareFactories.factories[name] = factory
+	middlewareFactories.lock.Unlock()
+}
+
+// NewMiddlewareChain assembles an Alice chain from an ordered list of middleware names.  The first name is the
+// outermost decorator.  An error is returned if any name has not been registered or if any factory fails.
+func NewMiddlewareChain(logger log.Logger, names []string) (alice.Chain, error) {
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	middlewareFactories.lock.RLock()
+	defer middlewareFactories.lock.RUnlock()
+
+	constructors := make([]alice.Constructor, 0, len(names))
+	for _, name := range names {
+		factory, ok := middlewareFactories.factories[name]
+		if !ok {
+			return alice.Chain{}, fmt.Errorf("No middleware registered with name %s", name)
+		}
+
+		constructor, err := factory(logger)
+		if err != nil {
+			return alice.Chain{}, err
+		}
+
+		constructors = append(constructors, constructor)
+	}
+
+	return alice.New(constructors...), nil
+}
+
+// committedWriter tracks whether the final response header has been committed, i.e. whether it is too late
+// to write an error status.  Hijacker, Flusher, and the other optional interfaces are forwarded by the embedded
+// health.ResponseWriter.
+type committedWriter struct {
+	*health.ResponseWriter
+	committed bool
+}
+
+func (cw *committedWriter) WriteHeader(statusCode int) {
+	if !xhttp.IsInformational(statusCode) {
+		cw.committed = true
+	}
+
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (cw *committedWriter) Write(p []byte) (int, error) {
+	cw.committed = true
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *committedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.committed = true
+	return cw.ResponseWriter.Hijack()
+}
+
+// Recover is an Alice-style constructor that recovers from panics in the decorated handler.  The panic is
+// logged with the request's contextual logger, and an http.StatusInternalServerError is written if the
+// handler had not yet committed a response.
+//
+// A panic with http.ErrAbortHandler is not recovered, since that is how handlers deliberately abort
+// a response, and net/http handles it specially.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		wrapped := &committedWriter{ResponseWriter: health.Wrap(response)}
+		defer func() {
+			if r := recover(); r != nil {
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+
+				logging.GetLogger(request.Context()).Log(
+					level.Key(), level.ErrorValue(),
+					logging.MessageKey(), "handler panicked",
+					logging.ErrorKey(), r,
+				)
+
+				if !wrapped.committed {
+					response.WriteHeader(http.StatusInternalServerError)
+				}
+			}
+		}()
+
+		next.ServeHTTP(wrapped, request)
+	})
+}
+
+// AccessLog returns an Alice-style constructor that logs each request after it has been served,
+// including the response status code and the time taken.
+func AccessLog(logger log.Logger) alice.Constructor {
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			var (
+				start   = time.Now()
+				wrapped = health.Wrap(response)
+			)
+
+			next.ServeHTTP(wrapped, request)
+
+			statusCode := wrapped.StatusCode()
+			if statusCode == 0 {
+				statusCode = http.StatusOK
+			}
+
+			logger.Log(
+				level.Key(), level.InfoValue(),
+				logging.MessageKey(), "served request",
+				logginghttp.RequestMethodKey(), request.Method,
+				logginghttp.RequestURIKey(), request.RequestURI,
+				logginghttp.RemoteAddrKey(), request.RemoteAddr,
+				"statusCode", statusCode,
+				"duration", time.Since(start),
+			)
+		})
+	}
+}
+
+// acceptsGzip parses an Accept-Encoding header, honoring qvalues, and tests if it allows a gzip response.
+// An explicit gzip coding takes precedence over the "*" wildcard.
+func acceptsGzip(acceptEncoding string) bool {
+	var (
+		gzipQ     = -1.0
+		wildcardQ = -1.0
+	)
+
+	for _, element := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(element, ";")
+		coding := strings.ToLower(strings.TrimSpace(parts[0]))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) > 2 && strings.EqualFold(param[:2], "q=") {
+				var err error
+				if q, err = strconv.ParseFloat(param[2:], 64); err != nil {
+					q = 0.0
+				}
+			}
+		}
+
+		if coding == "gzip" {
+			gzipQ = q
+		} else {
+			wildcardQ = q
+		}
+	}
+
+	if gzipQ >= 0.0 {
+		return gzipQ > 0.0
+	}
+
+	return wildcardQ > 0.0
+}
+
+// gzipResponseWriter routes the response body through a gzip.Writer.  Whether to compress is decided when the
+// final response header is written, so that responses which cannot have a body, and responses which the
+// handler has already encoded, are passed through as is.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	writer      *gzip.Writer
+	wroteHeader bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(statusCode int) {
+	if g.wroteHeader || xhttp.IsInformational(statusCode) {
+		g.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	g.wroteHeader = true
+	header := g.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if statusCode != http.StatusNoContent && statusCode != http.StatusNotModified &&
+		statusCode != http.StatusSwitchingProtocols && len(header.Get("Content-Encoding")) == 0 {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		g.writer = gzip.NewWriter(g.ResponseWriter)
+	}
+
+	g.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+
+	if g.writer != nil {
+		return g.writer.Write(p)
+	}
+
+	return g.ResponseWriter.Write(p)
+}
+
+// Flush flushes any compressed data, then flushes the wrapped ResponseWriter if it implements http.Flusher
+func (g *gzipResponseWriter) Flush() {
+	if g.writer != nil {
+		g.writer.Flush()
+	}
+
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack delegates to the wrapped ResponseWriter, returning an error if the delegate does not implement
+// http.Hijacker.  This allows websocket upgrades through the gzip middleware.
+func (g *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := g.ResponseWriter.(http.Hijacker); ok {
+		g.wroteHeader = true
+		return hijacker.Hijack()
+	}
+
+	return nil, nil, errors.New("Wrapped response does not implement http.Hijacker")
+}
+
+// close completes the compressed stream, if any
+func (g *gzipResponseWriter) close() error {
+	if g.writer != nil {
+		return g.writer.Close()
+	}
+
+	return nil
+}
+
+// Gzip is an Alice-style constructor that gzip-compresses responses for requests that accept that encoding.
+// HEAD requests, and responses which have no body, are never compressed.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodHead || !acceptsGzip(request.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		writer := &gzipResponseWriter{ResponseWriter: response}
+		defer writer.close()
+
+		next.ServeHTTP(writer, request)
+	})
+}
@@ -0,0 +1,402 @@
+package server
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/secure/routeauth"
+	"github.com/Comcast/webpa-common/semaphore"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/go-kit/kit/log"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRegisterMiddlewarePanics(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() {
+		RegisterMiddleware("", func(log.Logger) (alice.Constructor, error) { return nil, nil })
+	})
+
+	assert.Panics(func() {
+		RegisterMiddleware("test", nil)
+	})
+}
+
+func testRegisterMiddlewareCustom(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		order []string
+		trace = func(name string) alice.Constructor {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					order = append(order, name)
+					next.ServeHTTP(response, request)
+				})
+			}
+		}
+	)
+
+	RegisterMiddleware("testFirst", func(log.Logger) (alice.Constructor, error) { return trace("testFirst"), nil })
+	RegisterMiddleware("testSecond", func(log.Logger) (alice.Constructor, error) { return trace("testSecond"), nil })
+
+	chain, err := NewMiddlewareChain(logging.NewTestLogger(nil, t), []string{"testSecond", RecoverMiddleware, "testFirst"})
+	require.NoError(err)
+
+	response := httptest.NewRecorder()
+	chain.ThenFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.WriteHeader(299)
+	}).ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(299, response.Code)
+	assert.Equal([]string{"testSecond", "testFirst"}, order)
+}
+
+func ExampleRegisterMiddleware() {
+	// authentication is driven by a routeauth policy, typically unmarshalled from configuration
+	policy, err := routeauth.New(
+		&routeauth.Options{
+			Rules:   []routeauth.Rule{{Path: "/api/v2/device/", Schemes: []routeauth.Scheme{routeauth.Bearer}}},
+			Default: []routeauth.Scheme{routeauth.None},
+		},
+		routeauth.Authenticators{
+			routeauth.Bearer: func(request *http.Request) error {
+				if request.Header.Get("Authorization") != "Bearer valid" {
+					return errors.New("invalid token")
+				}
+
+				return nil
+			},
+		},
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	RegisterMiddleware("auth", func(log.Logger) (alice.Constructor, error) {
+		return policy.Then, nil
+	})
+
+	// requests beyond the limit are shed rather than queued
+	RegisterMiddleware("ratelimit", func(log.Logger) (alice.Constructor, error) {
+		limit := semaphore.New(100)
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				if !limit.TryAcquire() {
+					xhttp.WriteErrorf(response, http.StatusTooManyRequests, "Too many requests")
+					return
+				}
+
+				defer limit.Release()
+				next.ServeHTTP(response, request)
+			})
+		}, nil
+	})
+
+	// the same names are used in the Middleware configuration of a WebPA server
+	chain, err := NewMiddlewareChain(nil, []string{RecoverMiddleware, "auth", "ratelimit"})
+	if err != nil {
+		panic(err)
+	}
+
+	handler := chain.ThenFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.WriteHeader(http.StatusOK)
+	})
+
+	for _, authorization := range []string{"", "Bearer valid"} {
+		var (
+			response = httptest.NewRecorder()
+			request  = httptest.NewRequest("GET", "/api/v2/device/mac:112233445566/stat", nil)
+		)
+
+		if len(authorization) > 0 {
+			request.Header.Set("Authorization", authorization)
+		}
+
+		handler.ServeHTTP(response, request)
+		fmt.Println(response.Code)
+	}
+
+	// Output:
+	// 403
+	// 200
+}
+
+func TestRegisterMiddleware(t *testing.T) {
+	t.Run("Panics", testRegisterMiddlewarePanics)
+	t.Run("Custom", testRegisterMiddlewareCustom)
+}
+
+func testNewMiddlewareChainBuiltins(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	chain, err := NewMiddlewareChain(nil, []string{RecoverMiddleware, LoggerMiddleware, AccessLogMiddleware, GzipMiddleware, DeadlineMiddleware})
+	require.NoError(err)
+
+	response := httptest.NewRecorder()
+	chain.ThenFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.WriteHeader(201)
+	}).ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(201, response.Code)
+}
+
+func testNewMiddlewareChainUnknown(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewMiddlewareChain(nil, []string{RecoverMiddleware, "nosuch"})
+	assert.Error(err)
+}
+
+func testNewMiddlewareChainFactoryError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+	)
+
+	RegisterMiddleware("testError", func(log.Logger) (alice.Constructor, error) { return nil, expectedError })
+	_, err := NewMiddlewareChain(nil, []string{"testError"})
+	assert.Equal(expectedError, err)
+}
+
+func TestNewMiddlewareChain(t *testing.T) {
+	t.Run("Builtins", testNewMiddlewareChainBuiltins)
+	t.Run("Unknown", testNewMiddlewareChainUnknown)
+	t.Run("FactoryError", testNewMiddlewareChainFactoryError)
+}
+
+func testRecoverPanic(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	request = request.WithContext(logging.WithLogger(request.Context(), logging.NewTestLogger(nil, t)))
+	Recover(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("expected")
+	})).ServeHTTP(response, request)
+
+	assert.Equal(http.StatusInternalServerError, response.Code)
+}
+
+func testRecoverCommitted(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	request = request.WithContext(logging.WithLogger(request.Context(), logging.NewTestLogger(nil, t)))
+	Recover(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.Write([]byte("partial"))
+		panic("expected")
+	})).ServeHTTP(response, request)
+
+	// the status already sent is not overwritten
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("partial", response.Body.String())
+}
+
+func testRecoverAbortHandler(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	assert.PanicsWithValue(http.ErrAbortHandler, func() {
+		Recover(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic(http.ErrAbortHandler)
+		})).ServeHTTP(response, request)
+	})
+}
+
+func testRecoverHijack(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	Recover(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		hijacker, ok := response.(http.Hijacker)
+		if assert.True(ok) {
+			hijacker.Hijack()
+		}
+
+		panic("expected")
+	})).ServeHTTP(response, request)
+
+	assert.True(response.hijacked)
+	assert.False(response.Flushed)
+}
+
+func TestRecover(t *testing.T) {
+	t.Run("Panic", testRecoverPanic)
+	t.Run("Committed", testRecoverCommitted)
+	t.Run("AbortHandler", testRecoverAbortHandler)
+	t.Run("Hijack", testRecoverHijack)
+}
+
+func TestAccessLog(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		output []interface{}
+		logger = log.LoggerFunc(func(keyvals ...interface{}) error { output = keyvals; return nil })
+
+		response = httptest.NewRecorder()
+	)
+
+	AccessLog(logger)(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.WriteHeader(404)
+	})).ServeHTTP(response, httptest.NewRequest("GET", "/test", nil))
+
+	assert.Equal(404, response.Code)
+	assert.Contains(output, "statusCode")
+	assert.Contains(output, 404)
+}
+
+func TestGzip(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		handler = Gzip(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.Write([]byte("hello, world"))
+		}))
+	)
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Empty(response.HeaderMap.Get("Content-Encoding"))
+	assert.Equal("hello, world", response.Body.String())
+
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set("Accept-Encoding", "deflate, gzip")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	assert.Equal("gzip", response.HeaderMap.Get("Content-Encoding"))
+
+	reader, err := gzip.NewReader(response.Body)
+	require.NoError(err)
+	body, err := ioutil.ReadAll(reader)
+	require.NoError(err)
+	assert.Equal("hello, world", string(body))
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	testData := []struct {
+		acceptEncoding string
+		expected       bool
+	}{
+		{"", false},
+		{"deflate", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"deflate, gzip", true},
+		{"gzip;q=0.5", true},
+		{"gzip; q=0", false},
+		{"gzip;q=0.0, deflate", false},
+		{"gzip;q=invalid", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"*, gzip;q=0", false},
+		{"gzip;q=1, *;q=0", true},
+		{"x-gzip", false},
+	}
+
+	for _, record := range testData {
+		assert.Equal(t, record.expected, acceptsGzip(record.acceptEncoding), record.acceptEncoding)
+	}
+}
+
+func TestGzipNoBody(t *testing.T) {
+	for _, statusCode := range []int{http.StatusNoContent, http.StatusNotModified} {
+		var (
+			assert   = assert.New(t)
+			request  = httptest.NewRequest("GET", "/", nil)
+			response = httptest.NewRecorder()
+		)
+
+		request.Header.Set("Accept-Encoding", "gzip")
+		Gzip(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.WriteHeader(statusCode)
+		})).ServeHTTP(response, request)
+
+		assert.Equal(statusCode, response.Code)
+		assert.Empty(response.HeaderMap.Get("Content-Encoding"))
+		assert.Zero(response.Body.Len())
+	}
+}
+
+func TestGzipHead(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		request  = httptest.NewRequest("HEAD", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Accept-Encoding", "gzip")
+	Gzip(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.Header().Set("Content-Length", "12")
+	})).ServeHTTP(response, request)
+
+	assert.Empty(response.HeaderMap.Get("Content-Encoding"))
+	assert.Equal("12", response.HeaderMap.Get("Content-Length"))
+}
+
+func TestGzipAlreadyEncoded(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		request  = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set("Accept-Encoding", "gzip, br")
+	Gzip(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		response.Header().Set("Content-Encoding", "br")
+		response.Write([]byte("compressed"))
+	})).ServeHTTP(response, request)
+
+	assert.Equal("br", response.HeaderMap.Get("Content-Encoding"))
+	assert.Equal("compressed", response.Body.String())
+}
+
+func TestGzipFlushAndHijack(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		request  = httptest.NewRequest("GET", "/", nil)
+		response = &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	)
+
+	request.Header.Set("Accept-Encoding", "gzip")
+	Gzip(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		flusher, ok := response.(http.Flusher)
+		require.True(ok)
+		response.Write([]byte("hello"))
+		flusher.Flush()
+		assert.True(response.(*gzipResponseWriter).ResponseWriter.(*hijackRecorder).Flushed)
+
+		hijacker, ok := response.(http.Hijacker)
+		require.True(ok)
+		_, _, err := hijacker.Hijack()
+		assert.NoError(err)
+	})).ServeHTTP(response, request)
+
+	assert.True(response.hijacked)
+}
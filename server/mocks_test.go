@@ -1,8 +1,10 @@
 package server
 
 import (
+	"bufio"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"time"
 
 	"github.com/stretchr/testify/mock"
@@ -100,3 +102,14 @@ func (m *mockConn) SetReadDeadline(t time.Time) error {
 func (m *mockConn) SetWriteDeadline(t time.Time) error {
 	return m.Called(t).Error(0)
 }
+
+// hijackRecorder is an httptest.ResponseRecorder that also implements http.Hijacker
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (hr *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hr.hijacked = true
+	return nil, nil, nil
+}
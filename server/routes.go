@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/go-kit/kit/log"
+	"github.com/justinas/alice"
+)
+
+// Route describes overrides for the requests whose URL path matches a pattern.  This allows a single server
+// to host, for example, both a long-poll route and strict, short-deadline APIs.  Typically, this struct has
+// its values injected via Viper.
+type Route struct {
+	// Path is the pattern matched against request paths.  See xhttp.PathPattern for the syntax.
+	Path string
+
+	// Timeout, if positive, is enforced for matching requests with xhttp.EnforceTimeout.  A route timeout cannot
+	// extend a request beyond the server's WriteTimeout.
+	Timeout time.Duration
+
+	// MaxBodySize, if positive, is the maximum size in bytes of a matching request's body.  Requests which declare
+	// a larger Content-Length are rejected with a 413 status, and reading beyond the limit fails.
+	MaxBodySize int64
+
+	// Middleware is the ordered list of named middleware applied only to matching requests, inside the server's
+	// own Middleware.  This is how per-route requirements such as authorization are declared.
+	Middleware []string
+}
+
+// MaxBodySize returns an Alice-style constructor that limits request bodies to the given number of bytes.
+// If limit is nonpositive, the returned constructor simply returns the next http.Handler undecorated.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit < 1 {
+			return next
+		}
+
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			if request.ContentLength > limit {
+				xhttp.WriteErrorf(response, http.StatusRequestEntityTooLarge, "The request body exceeds %d bytes", limit)
+				return
+			}
+
+			request.Body = http.MaxBytesReader(response, request.Body, limit)
+			next.ServeHTTP(response, request)
+		})
+	}
+}
+
+// compiledRoute is a Route with its decoration chain assembled
+type compiledRoute struct {
+	route Route
+	chain alice.Chain
+}
+
+// NewRoutes produces an Alice-style constructor that applies the overrides of the first Route matching each
+// request.  Requests which match no Route are passed to the next http.Handler unmodified.  An error is returned
+// if any pattern is malformed or any route's middleware cannot be created.
+func NewRoutes(logger log.Logger, routes []Route) (alice.Constructor, error) {
+	compiled := make([]compiledRoute, 0, len(routes))
+	for _, r := range routes {
+		if err := xhttp.PathPattern(r.Path).Validate(); err != nil {
+			return nil, err
+		}
+
+		chain, err := NewMiddlewareChain(logger, r.Middleware)
+		if err != nil {
+			return nil, err
+		}
+
+		compiled = append(compiled, compiledRoute{
+			route: r,
+			chain: alice.New(xhttp.EnforceTimeout(r.Timeout, nil), MaxBodySize(r.MaxBodySize)).Extend(chain),
+		})
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(compiled) == 0 {
+			return next
+		}
+
+		var (
+			patterns = make([]xhttp.PathPattern, len(compiled))
+			handlers = make([]http.Handler, len(compiled))
+		)
+
+		for i, c := range compiled {
+			patterns[i] = xhttp.PathPattern(c.route.Path)
+			handlers[i] = c.chain.Then(next)
+		}
+
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			for i, pp := range patterns {
+				if pp.Matches(request.URL.Path) {
+					handlers[i].ServeHTTP(response, request)
+					return
+				}
+			}
+
+			next.ServeHTTP(response, request)
+		})
+	}, nil
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	var (
+		readError error
+		handler   = MaxBodySize(5)(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			_, readError = ioutil.ReadAll(request.Body)
+			response.WriteHeader(299)
+		}))
+	)
+
+	t.Run("Undecorated", func(t *testing.T) {
+		next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+		assert.NotNil(t, MaxBodySize(0)(next))
+	})
+
+	t.Run("Small", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+		)
+
+		handler.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("abc")))
+		assert.Equal(299, response.Code)
+		assert.NoError(readError)
+	})
+
+	t.Run("ContentLength", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+		)
+
+		handler.ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("abcdefghij")))
+		assert.Equal(http.StatusRequestEntityTooLarge, response.Code)
+	})
+
+	t.Run("Streamed", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+			request  = httptest.NewRequest("POST", "/", strings.NewReader("abcdefghij"))
+		)
+
+		request.ContentLength = -1
+		handler.ServeHTTP(response, request)
+		assert.Equal(299, response.Code)
+		assert.Error(readError)
+	})
+}
+
+func TestNewRoutes(t *testing.T) {
+	t.Run("MissingPath", func(t *testing.T) {
+		constructor, err := NewRoutes(nil, []Route{{}})
+		assert.Nil(t, constructor)
+		assert.Error(t, err)
+	})
+
+	t.Run("InvalidPath", func(t *testing.T) {
+		constructor, err := NewRoutes(nil, []Route{{Path: "/api/["}})
+		assert.Nil(t, constructor)
+		assert.Error(t, err)
+	})
+
+	t.Run("UnknownMiddleware", func(t *testing.T) {
+		constructor, err := NewRoutes(nil, []Route{{Path: "/api", Middleware: []string{"nosuch"}}})
+		assert.Nil(t, constructor)
+		assert.Error(t, err)
+	})
+
+	t.Run("NoRoutes", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		constructor, err := NewRoutes(nil, nil)
+		require.NoError(err)
+		require.NotNil(constructor)
+
+		response := httptest.NewRecorder()
+		constructor(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.WriteHeader(299)
+		})).ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+		assert.Equal(299, response.Code)
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		RegisterMiddleware("testRouteAuth", func(log.Logger) (alice.Constructor, error) {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					if request.Header.Get("Authorization") != "secret" {
+						response.WriteHeader(http.StatusForbidden)
+						return
+					}
+
+					next.ServeHTTP(response, request)
+				})
+			}, nil
+		})
+
+		constructor, err := NewRoutes(logging.NewTestLogger(nil, t), []Route{
+			{Path: "/api/fast/", Timeout: 50 * time.Millisecond},
+			{Path: "/api/secure", Middleware: []string{"testRouteAuth"}},
+			{Path: "/api/*", MaxBodySize: 5},
+		})
+
+		require.NoError(err)
+		require.NotNil(constructor)
+
+		handler := constructor(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			if _, err := ioutil.ReadAll(request.Body); err != nil {
+				response.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			if request.URL.Query().Get("slow") == "true" {
+				select {
+				case <-request.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+
+				return
+			}
+
+			if _, ok := request.Context().Deadline(); ok {
+				response.WriteHeader(298)
+				return
+			}
+
+			response.WriteHeader(299)
+		}))
+
+		serve := func(request *http.Request) int {
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+			return response.Code
+		}
+
+		// a route without a MaxBodySize does not limit request bodies
+		assert.Equal(298, serve(httptest.NewRequest("POST", "/api/fast/call", strings.NewReader("a long request body"))))
+		assert.Equal(http.StatusGatewayTimeout, serve(httptest.NewRequest("GET", "/api/fast/call?slow=true", nil)))
+
+		assert.Equal(http.StatusForbidden, serve(httptest.NewRequest("GET", "/api/secure", nil)))
+		authorized := httptest.NewRequest("GET", "/api/secure", nil)
+		authorized.Header.Set("Authorization", "secret")
+		assert.Equal(299, serve(authorized))
+
+		assert.Equal(299, serve(httptest.NewRequest("POST", "/api/limited", strings.NewReader("abc"))))
+		assert.Equal(http.StatusRequestEntityTooLarge, serve(httptest.NewRequest("POST", "/api/limited", strings.NewReader("a long request body"))))
+
+		// unmatched requests are not decorated
+		assert.Equal(299, serve(httptest.NewRequest("POST", "/other", strings.NewReader("a long request body"))))
+	})
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path"
+
+	"github.com/Comcast/webpa-common/secure/ipfilter"
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/Comcast/webpa-common/xviper"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// validateAddress checks that a server address is syntactically a host and port.  The port may be
+// a service name, e.g. "http", which is resolved locally.
+func validateAddress(errs *xviper.ValidationErrors, name, address string) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		errs.Addf("%s: Invalid address %s: %s", name, address, err)
+		return
+	}
+
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		errs.Addf("%s: Invalid port in address %s: %s", name, address, err)
+	}
+}
+
+// validateFile checks that a configured file exists and is not a directory
+func validateFile(errs *xviper.ValidationErrors, name, description, file string) bool {
+	info, err := os.Stat(file)
+	if err != nil {
+		errs.Addf("%s: Invalid %s: %s", name, description, err)
+		return false
+	}
+
+	if info.IsDir() {
+		errs.Addf("%s: Invalid %s: %s is a directory", name, description, file)
+		return false
+	}
+
+	return true
+}
+
+// validateTLS checks that the certificate and key are either both unset or both refer to a matching key pair
+func validateTLS(errs *xviper.ValidationErrors, name, certificateFile, keyFile string) {
+	switch {
+	case len(certificateFile) == 0 && len(keyFile) == 0:
+		return
+
+	case len(certificateFile) == 0:
+		errs.Addf("%s: A certificateFile is required when a keyFile is configured", name)
+
+	case len(keyFile) == 0:
+		errs.Addf("%s: A keyFile is required when a certificateFile is configured", name)
+
+	default:
+		certificateOK := validateFile(errs, name, "certificateFile", certificateFile)
+		keyOK := validateFile(errs, name, "keyFile", keyFile)
+		if certificateOK && keyOK {
+			if _, err := tls.LoadX509KeyPair(certificateFile, keyFile); err != nil {
+				errs.Addf("%s: Invalid certificate and key: %s", name, err)
+			}
+		}
+	}
+}
+
+// validateBasic checks the configuration of a server created from a Basic.  Servers with no address are
+// not started, and so are not checked.
+func validateBasic(errs *xviper.ValidationErrors, name string, b *Basic) {
+	if len(b.Address) == 0 {
+		return
+	}
+
+	validateAddress(errs, name, b.Address)
+	validateTLS(errs, name, b.CertificateFile, b.KeyFile)
+	if len(b.ClientCACertFile) > 0 {
+		validateFile(errs, name, "clientCACertFile", b.ClientCACertFile)
+	}
+
+	if _, err := ipfilter.New(&b.IPFilter); err != nil {
+		errs.Addf("%s: Invalid IP filter: %s", name, err)
+	}
+
+	if b.MaxConnections < 0 {
+		errs.Addf("%s: Invalid maxConnections: %d", name, b.MaxConnections)
+	}
+}
+
+// validateMetric checks a configured metric definition, including histogram buckets, which would otherwise
+// cause a panic when the metrics registry is created
+func validateMetric(errs *xviper.ValidationErrors, m xmetrics.Metric) {
+	if len(m.Name) == 0 {
+		errs.Addf("metric: A name is required for a metric")
+		return
+	}
+
+	switch m.Type {
+	case xmetrics.CounterType, xmetrics.GaugeType, xmetrics.SummaryType:
+		if len(m.Buckets) > 0 {
+			errs.Addf("metric %s: Buckets are only allowed for histograms", m.Name)
+		}
+
+	case xmetrics.HistogramType:
+		for i := 1; i < len(m.Buckets); i++ {
+			if m.Buckets[i] <= m.Buckets[i-1] {
+				errs.Addf("metric %s: Histogram buckets must be in strictly increasing order", m.Name)
+				break
+			}
+		}
+
+	default:
+		errs.Addf("metric %s: Unsupported metric type: %s", m.Name, m.Type)
+	}
+}
+
+// validateMiddleware checks that each middleware name has been registered, without creating any middleware
+func validateMiddleware(errs *xviper.ValidationErrors, name string, names []string) {
+	middlewareFactories.lock.RLock()
+	defer middlewareFactories.lock.RUnlock()
+
+	for _, n := range names {
+		if _, ok := middlewareFactories.factories[n]; !ok {
+			errs.Addf("%s: No middleware registered with name %s", name, n)
+		}
+	}
+}
+
+// Validate checks this WebPA configuration without creating any servers, listeners, or metrics.  All problems
+// are returned at once as an xviper.ValidationErrors.  Checks include address syntax, the existence and validity
+// of TLS files, IP filters, middleware and route definitions, and metric definitions such as histogram buckets.
+func (w *WebPA) Validate() error {
+	var errs xviper.ValidationErrors
+	if len(w.Primary.Address) == 0 {
+		errs.Add(ErrorNoPrimaryAddress)
+	}
+
+	validateBasic(&errs, "primary", &w.Primary)
+	validateBasic(&errs, "alternate", &w.Alternate)
+	validateBasic(&errs, "pprof", &w.Pprof)
+
+	if len(w.Health.Address) > 0 {
+		validateAddress(&errs, "health", w.Health.Address)
+		validateTLS(&errs, "health", w.Health.CertificateFile, w.Health.KeyFile)
+	}
+
+	if len(w.Metric.Address) > 0 {
+		validateAddress(&errs, "metric", w.Metric.Address)
+		validateTLS(&errs, "metric", w.Metric.CertificateFile, w.Metric.KeyFile)
+	}
+
+	for _, m := range w.Metric.MetricsOptions.Metrics {
+		validateMetric(&errs, m)
+	}
+
+	validateMiddleware(&errs, "middleware", w.Middleware)
+	for _, r := range w.Routes {
+		if len(r.Path) == 0 {
+			errs.Addf("route: A route path is required")
+			continue
+		}
+
+		if _, err := path.Match(r.Path, ""); err != nil {
+			errs.Addf("route %s: Invalid route path: %s", r.Path, err)
+		}
+
+		validateMiddleware(&errs, fmt.Sprintf("route %s", r.Path), r.Middleware)
+	}
+
+	return errs.Err()
+}
+
+/*
+Validate reads configuration exactly as Initialize does, but rather than creating a logger, metrics, or any
+servers, it returns every configuration problem at once.  This supports a dry run, e.g. a -validate command line
+flag, in a WebPA server.  Each supplied validator is run against the same Viper instance, which allows other
+configuration sections, such as service discovery via servicecfg.Validate, to be checked as well.
+
+Errors reading or unmarshaling configuration are returned immediately, since nothing else can be checked.
+*/
+func Validate(applicationName string, arguments []string, f *pflag.FlagSet, v *viper.Viper, validators ...func(*viper.Viper) error) error {
+	if err := Configure(applicationName, arguments, f, v); err != nil {
+		return err
+	}
+
+	if err := xviper.ReadInLayers(v, xviper.LayerOptions{}); err != nil {
+		return err
+	}
+
+	webPA := &WebPA{
+		ApplicationName: applicationName,
+	}
+
+	if err := xviper.Unmarshal(v, "", webPA); err != nil {
+		return err
+	}
+
+	var errs xviper.ValidationErrors
+	errs.Add(webPA.Validate())
+	for _, validator := range validators {
+		errs.Add(validator(v))
+	}
+
+	return errs.Err()
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Comcast/webpa-common/secure/ipfilter"
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/Comcast/webpa-common/xviper"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testWebPAValidateValid(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		webPA = WebPA{
+			Primary: Basic{
+				Address:          "localhost:8080",
+				CertificateFile:  "cert.pem",
+				KeyFile:          "key.pem",
+				ClientCACertFile: "client_ca.pem",
+			},
+			Alternate: Basic{Address: ":http"},
+			Health:    Health{Address: ":9001"},
+			Metric: Metric{
+				Address: ":9002",
+				MetricsOptions: xmetrics.Options{
+					Metrics: []xmetrics.Metric{
+						{Name: "counter", Type: xmetrics.CounterType},
+						{Name: "histogram", Type: xmetrics.HistogramType, Buckets: []float64{0.1, 1, 10}},
+					},
+				},
+			},
+			Middleware: []string{RecoverMiddleware, GzipMiddleware},
+			Routes: []Route{
+				{Path: "/api/", Middleware: []string{DeadlineMiddleware}},
+			},
+		}
+	)
+
+	assert.NoError(webPA.Validate())
+}
+
+func testWebPAValidateInvalid(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		webPA = WebPA{
+			Alternate: Basic{
+				Address:          "localhost",
+				CertificateFile:  "nosuch.pem",
+				KeyFile:          "key.pem",
+				ClientCACertFile: "nosuch_ca.pem",
+				IPFilter:         ipfilter.Options{Allow: []string{"not a cidr"}},
+				MaxConnections:   -1,
+			},
+			Pprof:  Basic{Address: ":notaport", KeyFile: "key.pem"},
+			Health: Health{Address: ":9001", CertificateFile: "cert.pem", KeyFile: "cert.pem"},
+			Metric: Metric{
+				Address: ":9002",
+				MetricsOptions: xmetrics.Options{
+					Metrics: []xmetrics.Metric{
+						{Type: xmetrics.CounterType},
+						{Name: "counter", Type: xmetrics.CounterType, Buckets: []float64{1}},
+						{Name: "histogram", Type: xmetrics.HistogramType, Buckets: []float64{10, 1}},
+						{Name: "unknown", Type: "unknown"},
+					},
+				},
+			},
+			Middleware: []string{"nosuch"},
+			Routes: []Route{
+				{},
+				{Path: "[", Middleware: []string{"nosuch"}},
+			},
+		}
+	)
+
+	err := webPA.Validate()
+	require.Error(err)
+
+	errs, ok := err.(xviper.ValidationErrors)
+	require.True(ok)
+	assert.Equal(ErrorNoPrimaryAddress, errs[0])
+
+	// every problem is reported, not just the first
+	assert.Len(errs, 17)
+}
+
+func TestWebPAValidate(t *testing.T) {
+	t.Run("Valid", testWebPAValidateValid)
+	t.Run("Invalid", testWebPAValidateInvalid)
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("Example", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		// the example configuration refers to TLS files that do not exist
+		err := Validate("example", nil, nil, viper.New())
+		require.Error(err)
+
+		errs, ok := err.(xviper.ValidationErrors)
+		require.True(ok)
+		assert.Len(errs, 3)
+	})
+
+	t.Run("Validators", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			require       = require.New(t)
+			expectedError = errors.New("expected")
+		)
+
+		err := Validate("example", nil, nil, viper.New(), func(v *viper.Viper) error {
+			assert.NotNil(v)
+			return expectedError
+		})
+
+		require.Error(err)
+
+		errs, ok := err.(xviper.ValidationErrors)
+		require.True(ok)
+		assert.Len(errs, 4)
+		assert.Equal(expectedError, errs[3])
+	})
+
+	t.Run("ReadInConfigError", func(t *testing.T) {
+		assert.Error(t, Validate("nosuch", nil, nil, viper.New()))
+	})
+}
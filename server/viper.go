@@ -6,6 +6,7 @@ import (
 
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/Comcast/webpa-common/xviper"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/spf13/pflag"
@@ -148,8 +149,8 @@ func Configure(applicationName string, arguments []string, f *pflag.FlagSet, v *
 
 /*
 Initialize handles the bootstrapping of the server code for a WebPA node.  It configures Viper,
-reads configuration, and unmarshals the appropriate objects.  This function is typically all that's
-needed to fully instantiate a WebPA server.  Typical usage:
+reads layered configuration via xviper.ReadInLayers, and unmarshals the appropriate objects.  This function
+is typically all that's needed to fully instantiate a WebPA server.  Typical usage:
 
     var (
       f = pflag.NewFlagSet()
@@ -186,7 +187,7 @@ func Initialize(applicationName string, arguments []string, f *pflag.FlagSet, v
 		return
 	}
 
-	if err = v.ReadInConfig(); err != nil {
+	if err = xviper.ReadInLayers(v, xviper.LayerOptions{}); err != nil {
 		return
 	}
 
@@ -194,13 +195,14 @@ func Initialize(applicationName string, arguments []string, f *pflag.FlagSet, v
 		ApplicationName: applicationName,
 	}
 
-	err = v.Unmarshal(webPA)
+	err = xviper.Unmarshal(v, "", webPA)
 	if err != nil {
 		return
 	}
 
 	logger = logging.New(webPA.Log)
 	logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "initialized Viper environment", "configurationFile", v.ConfigFileUsed())
+	webPA.Banner = NewBanner(webPA, v)
 
 	if len(webPA.Metric.MetricsOptions.Namespace) == 0 {
 		webPA.Metric.MetricsOptions.Namespace = applicationName
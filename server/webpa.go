@@ -15,6 +15,8 @@ import (
 	"github.com/Comcast/webpa-common/concurrent"
 	"github.com/Comcast/webpa-common/health"
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/secure/ipfilter"
+	"github.com/Comcast/webpa-common/service"
 	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/Comcast/webpa-common/xlistener"
 	"github.com/Comcast/webpa-common/xmetrics"
@@ -116,6 +118,13 @@ type Basic struct {
 	ReadHeaderTimeout time.Duration
 	ReadTimeout       time.Duration
 	WriteTimeout      time.Duration
+
+	// Socket holds low-level listener socket options, such as SO_REUSEPORT
+	Socket xhttp.SocketOptions
+
+	// IPFilter holds the CIDR allow and deny lists for this server.  If no lists are configured,
+	// requests are not filtered.
+	IPFilter ipfilter.Options
 }
 
 func (b *Basic) maxConnections() int {
@@ -171,12 +180,36 @@ func (b *Basic) Certificate() (certificateFile, keyFile string) {
 	return b.CertificateFile, b.KeyFile
 }
 
+// decorate applies this server's IP filter, if configured, to the given handler.  Whether or not filtering is
+// configured, the client IP of each request is resolved using IPFilter.TrustedProxies and IPFilter.ForwardingHeader and made available via
+// xhttp.GetClientIP.  A nil handler is treated as http.DefaultServeMux, which matches the behavior of http.Server.
+func (b *Basic) decorate(handler http.Handler) (http.Handler, error) {
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+
+	filter, err := ipfilter.New(&b.IPFilter)
+	if err != nil {
+		return nil, err
+	} else if filter != nil {
+		return filter.Then(handler), nil
+	}
+
+	resolver, err := ipfilter.NewClientIPResolver(&b.IPFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	return xhttp.ClientIP(resolver)(handler), nil
+}
+
 // NewListener creates a decorated TCPListener appropriate for this server's configuration.
 func (b *Basic) NewListener(logger log.Logger, activeConnections metrics.Gauge, rejectedCounter xmetrics.Adder) (net.Listener, error) {
 	return xlistener.New(xlistener.Options{
 		Logger:         logger,
 		Address:        b.Address,
 		MaxConnections: b.maxConnections(),
+		Socket:         b.Socket,
 		Active:         activeConnections,
 		Rejected:       rejectedCounter,
 	})
@@ -301,6 +334,10 @@ type Health struct {
 	LogConnectionState bool
 	LogInterval        time.Duration
 	Options            []string
+
+	// ExposeConfig controls whether the health server serves the WebPA's Banner at ConfigPath.  The Banner
+	// describes the application's configuration, so this is disabled by default.
+	ExposeConfig bool
 }
 
 func (h *Health) Certificate() (certificateFile, keyFile string) {
@@ -329,9 +366,19 @@ func (h *Health) NewHealth(logger log.Logger, options ...health.Option) *health.
 // is nil, then h.NewHealth is used to create a Health instance.  Otherwise, the health parameter
 // is returned as is.
 //
+// In addition to /health, the returned server exposes /health/live and /health/ready, which are suitable
+// for liveness and readiness probes.  When prepared as part of a WebPA with a Banner and ExposeConfig
+// is set, the health server also exposes ConfigPath.  /health/ready evaluates the same checks as /health, which are registered via
+// health.AddCheck, health.AddScheduledCheck, or health.AddReadinessCheck.
+//
 // If the Address option is not supplied, the health module is considered to be disabled.  In that
 // case, this method simply returns the health parameter as the monitor and a nil server instance.
 func (h *Health) New(logger log.Logger, chain alice.Chain, health *health.Health) (*health.Health, *http.Server) {
+	return h.newServer(logger, chain, health, nil)
+}
+
+// newServer creates the health server, additionally exposing the given handlers keyed by path
+func (h *Health) newServer(logger log.Logger, chain alice.Chain, health *health.Health, handlers map[string]http.Handler) (*health.Health, *http.Server) {
 	if len(h.Address) == 0 {
 		// health is disabled
 		return nil, nil
@@ -347,6 +394,11 @@ func (h *Health) New(logger log.Logger, chain alice.Chain, health *health.Health
 
 	mux := http.NewServeMux()
 	mux.Handle("/health", chain.Then(health))
+	mux.Handle("/health/live", chain.ThenFunc(health.ServeLive))
+	mux.Handle("/health/ready", chain.ThenFunc(health.ServeReady))
+	for path, handler := range handlers {
+		mux.Handle(path, chain.Then(handler))
+	}
 
 	server := &http.Server{
 		Addr:              h.Address,
@@ -406,6 +458,38 @@ type WebPA struct {
 
 	// Log is the logging configuration for this application.
 	Log *logging.Options
+
+	// Middleware is the ordered list of named middleware applied to the primary and alternate handler,
+	// e.g. ["recover", "accesslog", "auth", "ratelimit", "gzip"].  The first name is the outermost decorator.
+	// Custom middleware, including authentication and rate limiting, is made available via RegisterMiddleware.
+	Middleware []string
+
+	// Routes are per-route overrides of timeouts, body limits, and middleware for the primary and alternate
+	// handler.  The first Route whose Path matches a request is applied, inside the Middleware pipeline.
+	Routes []Route
+
+	// Ports, if supplied, records the addresses actually bound by the primary and alternate servers under the
+	// names "primary" and "alternate".  When a server listens on port 0, this allows service discovery
+	// registrations to advertise the port chosen by the operating system.  This field is injected by code
+	// rather than configuration.
+	Ports *service.Ports
+
+	// Banner, if supplied, describes the effective configuration of this application.  It is logged when
+	// the servers start and, if Health.ExposeConfig is set, served as JSON at ConfigPath on the health server.  Initialize creates a Banner
+	// using DefaultRedactor, which may be replaced with one created by NewBanner to customize redaction.
+	// This field is injected by code rather than configuration.
+	Banner *Banner
+
+	// TraceID, if supplied, returns the identifier of the trace a request belongs to, e.g. a closure around
+	// xotel.TraceID.  The trace ID is attached as an exemplar to request duration observations.  If unset,
+	// no exemplars are recorded.  This field is injected by code rather than configuration.
+	TraceID func(*http.Request) string
+
+	// Lifecycle, if supplied, manages the startup and shutdown of the application's other components,
+	// e.g. service discovery or a device manager.  The Runnable returned by Prepare starts these components
+	// once every server is listening, so that service registrations can use the bound Ports, and stops them
+	// in reverse order on shutdown.  This field is injected by code rather than configuration.
+	Lifecycle *Lifecycle
 }
 
 // build returns the injected build string if available, DefaultBuild otherwise
@@ -444,6 +528,24 @@ func (w *WebPA) flavor() string {
 	return DefaultFlavor
 }
 
+// healthHandlers returns the additional handlers exposed by the health server
+func (w *WebPA) healthHandlers() map[string]http.Handler {
+	if w.Banner != nil && w.Health.ExposeConfig {
+		return map[string]http.Handler{ConfigPath: w.Banner}
+	}
+
+	return nil
+}
+
+// listened records the bound address of a server's listener, if this WebPA has Ports
+func (w *WebPA) listened(name string, l net.Listener) error {
+	if w.Ports != nil {
+		return w.Ports.Set(name, l.Addr())
+	}
+
+	return nil
+}
+
 // Prepare gets a WebPA server ready for execution.  This method does not return errors, but the returned
 // Runnable may return an error.  The supplied logger will usually come from the New function, but the
 // WebPA.Log object can be used to create a different logger if desired.
@@ -454,10 +556,15 @@ func (w *WebPA) flavor() string {
 //
 // The caller may also pass a gatherer type. If it is not provided, the default provided by prometheus is used.
 //
-// The supplied http.Handler is used for the primary server.  If the alternate server has an address,
-// it will also be used for that server.  The health server uses an internally create handler, while pprof and metrics
+// The supplied http.Handler is used for the primary server, decorated with xhttp.RequestID so that every request
+// carries a request ID in its context, context logger, and response.  The configured Middleware pipeline is also
+// applied to the supplied handler, followed by any Routes overrides, and an unknown middleware name or malformed
+// route causes the returned Runnable to fail.
+// The primary, alternate, and pprof servers each resolve client IPs and apply their own IPFilter, if configured.
+// If the alternate server has an address, it will also be used for that server.  The health server uses an internally create handler, while pprof and metrics
 // servers use http.DefaultServeMux.  The health Monitor created from configuration is returned so that other
-// infrastructure can make use of it.
+// infrastructure can make use of it.  If a Lifecycle is injected, its components are started after the servers
+// and stopped when the returned Runnable is shut down.
 func (w *WebPA) Prepare(logger log.Logger, health *health.Health, registry xmetrics.Registry, primaryHandler http.Handler) (health.Monitor, concurrent.Runnable) {
 	// allow the health instance to be non-nil, in which case it will be used in favor of
 	// the WebPA-configured instance.
@@ -474,24 +581,54 @@ func (w *WebPA) Prepare(logger log.Logger, health *health.Health, registry xmetr
 		rejectedCounter   = registry.NewCounter("rejected_connections")
 		maxProcs          = registry.NewGauge("maximum_processors")
 
-		healthHandler, healthServer = w.Health.New(logger, alice.New(staticHeaders), health)
+		healthHandler, healthServer = w.Health.newServer(logger, alice.New(staticHeaders), health, w.healthHandlers())
 		infoLog                     = logging.Info(logger)
 	)
 
 	return healthHandler, concurrent.RunnableFunc(func(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
+		if w.Banner != nil {
+			w.Banner.StartTime = time.Now().UTC()
+			w.Banner.Log(logger)
+		}
+
 		if healthHandler != nil && healthServer != nil {
 			infoLog.Log(logging.MessageKey(), "starting server", "name", w.Health.Name, "address", w.Health.Address)
 			ListenAndServe(logger, &w.Health, healthServer)
 			healthHandler.Run(waitGroup, shutdown)
 		}
 
-		if pprofServer := w.Pprof.New(logger, nil); pprofServer != nil {
+		pprofHandler, err := w.Pprof.decorate(nil)
+		if err != nil {
+			return err
+		}
+
+		if pprofServer := w.Pprof.New(logger, pprofHandler); pprofServer != nil {
 			infoLog.Log(logging.MessageKey(), "starting server", "name", w.Pprof.Name, "address", w.Pprof.Address)
 			ListenAndServe(logger, &w.Pprof, pprofServer)
 		}
 
-		primaryHandler = staticHeaders(w.decorateWithBasicMetrics(registry, primaryHandler))
-		if primaryServer := w.Primary.New(logger, primaryHandler); primaryServer != nil {
+		middleware, err := NewMiddlewareChain(logger, w.Middleware)
+		if err != nil {
+			return err
+		}
+
+		routes, err := NewRoutes(logger, w.Routes)
+		if err != nil {
+			return err
+		}
+
+		primaryHandler = staticHeaders(xhttp.RequestID(nil)(w.decorateWithBasicMetrics(registry, middleware.Then(routes(primaryHandler)))))
+		primaryFiltered, err := w.Primary.decorate(primaryHandler)
+		if err != nil {
+			return err
+		}
+
+		alternateFiltered, err := w.Alternate.decorate(primaryHandler)
+		if err != nil {
+			return err
+		}
+
+		if primaryServer := w.Primary.New(logger, primaryFiltered); primaryServer != nil {
 			listener, err := w.Primary.NewListener(
 				logger,
 				activeConnections.With("server", "primary"),
@@ -503,13 +640,18 @@ func (w *WebPA) Prepare(logger log.Logger, health *health.Health, registry xmetr
 				return err
 			}
 
-			infoLog.Log(logging.MessageKey(), "starting server", "name", w.Primary.Name, "address", w.Primary.Address)
+			if err := w.listened("primary", listener); err != nil {
+				listener.Close()
+				return err
+			}
+
+			infoLog.Log(logging.MessageKey(), "starting server", "name", w.Primary.Name, "address", listener.Addr().String())
 			Serve(logger, &w.Primary, listener, primaryServer)
 		} else {
 			return ErrorNoPrimaryAddress
 		}
 
-		if alternateServer := w.Alternate.New(logger, primaryHandler); alternateServer != nil {
+		if alternateServer := w.Alternate.New(logger, alternateFiltered); alternateServer != nil {
 			listener, err := w.Alternate.NewListener(
 				logger,
 				activeConnections.With("server", "alternate"),
@@ -521,7 +663,12 @@ func (w *WebPA) Prepare(logger log.Logger, health *health.Health, registry xmetr
 				return err
 			}
 
-			infoLog.Log(logging.MessageKey(), "starting server", "name", w.Alternate.Name, "address", w.Alternate.Address)
+			if err := w.listened("alternate", listener); err != nil {
+				listener.Close()
+				return err
+			}
+
+			infoLog.Log(logging.MessageKey(), "starting server", "name", w.Alternate.Name, "address", listener.Addr().String())
 			Serve(logger, &w.Alternate, listener, alternateServer)
 		}
 
@@ -529,7 +676,20 @@ func (w *WebPA) Prepare(logger log.Logger, health *health.Health, registry xmetr
 			infoLog.Log(logging.MessageKey(), "starting server", "name", w.Metric.Name, "address", w.Metric.Address)
 			ListenAndServe(logger, &w.Metric, metricsServer)
 		}
-		
+
+		// a registry configured with an emitter only pushes metrics once it is run
+		if runnable, ok := registry.(concurrent.Runnable); ok {
+			if err := runnable.Run(waitGroup, shutdown); err != nil {
+				return err
+			}
+		}
+
+		if w.Lifecycle != nil {
+			if err := w.Lifecycle.Run(waitGroup, shutdown); err != nil {
+				return err
+			}
+		}
+
 		// Output, to metrics, the maximum number of CPUs available to this process
 		maxProcs.Set(float64(runtime.GOMAXPROCS(0)))
 
@@ -537,6 +697,22 @@ func (w *WebPA) Prepare(logger log.Logger, health *health.Health, registry xmetr
 	})
 }
 
+// instrumentHandlerDuration is like promhttp.InstrumentHandlerDuration, except that the request's trace ID,
+// if any, is attached to each observation as an exemplar when the Observer supports exemplars.  If traceID
+// is nil, no exemplars are attached.
+func instrumentHandlerDuration(o stdprometheus.Observer, traceID func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(response, request)
+
+		if traceID != nil {
+			xmetrics.ObserveWithTraceID(o, time.Since(start).Seconds(), traceID(request))
+		} else {
+			o.Observe(time.Since(start).Seconds())
+		}
+	})
+}
+
 //decorateWithBasicMetrics wraps a WebPA server handler with basic instrumentation metrics
 func (w *WebPA) decorateWithBasicMetrics(p xmetrics.PrometheusProvider, next http.Handler) http.Handler {
 	var (
@@ -554,7 +730,7 @@ func (w *WebPA) decorateWithBasicMetrics(p xmetrics.PrometheusProvider, next htt
 
 	return promhttp.InstrumentHandlerInFlight(inFlight,
 		promhttp.InstrumentHandlerCounter(requestCounter,
-			promhttp.InstrumentHandlerDuration(requestDuration,
+			instrumentHandlerDuration(requestDuration.WithLabelValues(), w.TraceID,
 				promhttp.InstrumentHandlerResponseSize(responseSizeVec,
 					promhttp.InstrumentHandlerRequestSize(requestSize,
 						promhttp.InstrumentHandlerTimeToWriteHeader(timeToWriteHeader, next))),
@@ -2,15 +2,21 @@ package server
 
 import (
 	"errors"
+	"fmt"
 	//	"github.com/Comcast/webpa-common/health"
 	"crypto/tls"
 	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/Comcast/webpa-common/secure/ipfilter"
+	"github.com/Comcast/webpa-common/service"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/Comcast/webpa-common/xmetrics"
 	"github.com/justinas/alice"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -315,6 +321,34 @@ func TestWebPANoPrimaryAddress(t *testing.T) {
 	handler.AssertExpectations(t)
 }
 
+func TestWebPAInvalidRoute(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		handler = new(mockHandler)
+
+		webPA = WebPA{
+			Primary: Basic{Name: "test", Address: ":0"},
+			Routes:  []Route{{Path: "/api/["}},
+		}
+
+		_, logger   = newTestLogger()
+		_, runnable = webPA.Prepare(logger, nil, xmetrics.MustNewRegistry(nil), handler)
+	)
+
+	require.NotNil(runnable)
+
+	var (
+		waitGroup = new(sync.WaitGroup)
+		shutdown  = make(chan struct{})
+	)
+
+	defer close(shutdown)
+	assert.Error(runnable.Run(waitGroup, shutdown))
+	waitGroup.Wait()
+	handler.AssertExpectations(t)
+}
+
 func TestWebPA(t *testing.T) {
 	var (
 		assert  = assert.New(t)
@@ -353,6 +387,9 @@ func TestWebPA(t *testing.T) {
 				Name:    "test.metrics",
 				Address: ":0",
 			},
+
+			Ports:     new(service.Ports),
+			Lifecycle: new(Lifecycle),
 		}
 
 		_, logger         = newTestLogger()
@@ -362,6 +399,21 @@ func TestWebPA(t *testing.T) {
 	assert.NotNil(monitor)
 	require.NotNil(runnable)
 
+	var events []string
+	require.NoError(webPA.Lifecycle.Register(Component{
+		Name: "discovery",
+		Start: func() error {
+			// components start once the servers have bound their ports
+			_, ok := webPA.Ports.Port("primary")
+			events = append(events, fmt.Sprintf("start discovery %t", ok))
+			return nil
+		},
+		Stop: func() error {
+			events = append(events, "stop discovery")
+			return nil
+		},
+	}))
+
 	var (
 		waitGroup = new(sync.WaitGroup)
 		shutdown  = make(chan struct{})
@@ -371,6 +423,60 @@ func TestWebPA(t *testing.T) {
 	close(shutdown)
 	waitGroup.Wait() // the http.Server instances will still be running after this returns
 	handler.AssertExpectations(t)
+	assert.Equal([]string{"start discovery true", "stop discovery"}, events)
+
+	for _, name := range []string{"primary", "alternate"} {
+		port, ok := webPA.Ports.Port(name)
+		assert.True(ok)
+		assert.True(port > 0)
+	}
+}
+
+type testExemplarObserver struct {
+	values    []float64
+	exemplars []stdprometheus.Labels
+}
+
+func (o *testExemplarObserver) Observe(value float64) {
+	o.values = append(o.values, value)
+	o.exemplars = append(o.exemplars, nil)
+}
+
+func (o *testExemplarObserver) ObserveWithExemplar(value float64, exemplar stdprometheus.Labels) {
+	o.values = append(o.values, value)
+	o.exemplars = append(o.exemplars, exemplar)
+}
+
+func TestInstrumentHandlerDuration(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		observer = new(testExemplarObserver)
+		traceID  = func(request *http.Request) string { return request.Header.Get("X-Test-Trace") }
+		handler  = instrumentHandlerDuration(observer, traceID, http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.WriteHeader(http.StatusAccepted)
+		}))
+
+		traced = httptest.NewRequest("GET", "/", nil)
+	)
+
+	traced.Header.Set("X-Test-Trace", "0af7651916cd43dd8448eb211c80319c")
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusAccepted, response.Code)
+
+	handler.ServeHTTP(httptest.NewRecorder(), traced)
+
+	require.Len(observer.values, 2)
+	assert.Nil(observer.exemplars[0])
+	assert.Equal(stdprometheus.Labels{xmetrics.TraceIDLabel: "0af7651916cd43dd8448eb211c80319c"}, observer.exemplars[1])
+
+	// without a trace ID function, no exemplars are recorded
+	observer = new(testExemplarObserver)
+	instrumentHandlerDuration(observer, nil, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(httptest.NewRecorder(), traced)
+	require.Len(observer.values, 1)
+	assert.Nil(observer.exemplars[0])
 }
 
 func TestBasicNewWithClientCACert(t *testing.T) {
@@ -437,3 +543,87 @@ func TestBasicNewWithClientCACert(t *testing.T) {
 		}
 	}
 }
+
+func TestBasicDecorate(t *testing.T) {
+	t.Run("Unfiltered", func(t *testing.T) {
+		var (
+			require = require.New(t)
+			handler = new(mockHandler)
+			basic   = Basic{}
+		)
+
+		decorated, err := basic.decorate(handler)
+		require.NoError(err)
+		require.NotNil(decorated)
+
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = "192.168.1.1:1234"
+		response := httptest.NewRecorder()
+		handler.On("ServeHTTP", response, mock.MatchedBy(func(r *http.Request) bool {
+			ip, ok := xhttp.GetClientIP(r.Context())
+			return ok && ip.String() == "192.168.1.1"
+		})).Once()
+
+		decorated.ServeHTTP(response, request)
+		handler.AssertExpectations(t)
+	})
+
+	t.Run("InvalidTrustedProxies", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			basic  = Basic{IPFilter: ipfilter.Options{TrustedProxies: []string{"not a network"}}}
+		)
+
+		decorated, err := basic.decorate(new(mockHandler))
+		assert.Error(err)
+		assert.Nil(decorated)
+	})
+
+	t.Run("InvalidNetwork", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			basic  = Basic{IPFilter: ipfilter.Options{Allow: []string{"not a network"}}}
+		)
+
+		decorated, err := basic.decorate(new(mockHandler))
+		assert.Error(err)
+		assert.Nil(decorated)
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			handler = new(mockHandler)
+			basic   = Basic{IPFilter: ipfilter.Options{Allow: []string{"10.0.0.0/8"}}}
+		)
+
+		decorated, err := basic.decorate(handler)
+		require.NoError(err)
+		require.NotNil(decorated)
+
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = "192.168.1.1:1234"
+		response := httptest.NewRecorder()
+		decorated.ServeHTTP(response, request)
+		assert.Equal(http.StatusForbidden, response.Code)
+
+		request = httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = "10.1.2.3:1234"
+		response = httptest.NewRecorder()
+		handler.On("ServeHTTP", response, mock.AnythingOfType("*http.Request")).Once()
+		decorated.ServeHTTP(response, request)
+		handler.AssertExpectations(t)
+	})
+
+	t.Run("DefaultServeMux", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			basic  = Basic{IPFilter: ipfilter.Options{Deny: []string{"10.0.0.0/8"}}}
+		)
+
+		decorated, err := basic.decorate(nil)
+		assert.NoError(err)
+		assert.NotNil(decorated)
+	})
+}
@@ -50,6 +50,18 @@ func newInstancerKey(w Watch) string {
 	)
 }
 
+// newPendingKey produces the Registrars key for a registration whose port is resolved from a listener
+// when it registers.  This key is never a valid instance, so it matches only duplicate registrations
+// for the same address and listener until the port is known.
+func newPendingKey(registrationScheme, address, listener string) string {
+	return fmt.Sprintf(
+		"%s://%s{listener=%s}",
+		registrationScheme,
+		address,
+		listener,
+	)
+}
+
 func defaultClientFactory(client *api.Client) (gokitconsul.Client, ttlUpdater) {
 	return gokitconsul.NewClient(client), client.Agent()
 }
@@ -98,11 +110,34 @@ func newInstancers(l log.Logger, c gokitconsul.Client, co Options) (i service.In
 }
 
 func newRegistrars(l log.Logger, registrationScheme string, c gokitconsul.Client, u ttlUpdater, co Options) (r service.Registrars, closer func() error, err error) {
-	var consulRegistrar sd.Registrar
+	var (
+		consulRegistrar sd.Registrar
+		resolver        = co.portResolver()
+	)
+
 	for _, registration := range co.registrations() {
+		// each registrar retains its registration, so each needs a distinct copy
+		registration := registration
+
+		// a registration without a port advertises the port bound by a listener, which may not be
+		// known until the server starts
+		resolvePort := registration.Port == 0 && resolver != nil
+		if resolvePort {
+			if port, ok := resolver.Port(co.listener()); ok {
+				registration.Port = port
+				resolvePort = false
+			}
+		}
+
+		// pending registrations are keyed by their listener, as the instance isn't known until the port is resolved
 		instance := service.FormatInstance(registrationScheme, registration.Address, registration.Port)
-		if r.Has(instance) {
-			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping duplicate registration", "instance", instance)
+		key := instance
+		if resolvePort {
+			key = newPendingKey(registrationScheme, registration.Address, co.listener())
+		}
+
+		if r.Has(key) {
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping duplicate registration", "instance", key)
 			continue
 		}
 
@@ -110,12 +145,23 @@ func newRegistrars(l log.Logger, registrationScheme string, c gokitconsul.Client
 			ensureIDs(&registration)
 		}
 
-		consulRegistrar, err = NewRegistrar(c, u, &registration, log.With(l, "id", registration.ID, "instance", instance))
+		consulRegistrar, err = NewRegistrar(c, u, &registration, log.With(l, "id", registration.ID, "instance", key))
 		if err != nil {
 			return
 		}
 
-		r.Add(instance, consulRegistrar)
+		if resolvePort {
+			consulRegistrar = &portRegistrar{
+				logger:       log.With(l, "id", registration.ID, "listener", co.listener()),
+				scheme:       registrationScheme,
+				listener:     co.listener(),
+				resolver:     resolver,
+				registration: &registration,
+				registrar:    consulRegistrar,
+			}
+		}
+
+		r.Add(key, consulRegistrar)
 	}
 
 	return
@@ -140,11 +186,17 @@ func NewEnvironment(l log.Logger, registrationScheme string, co Options, eo ...s
 		return nil, err
 	}
 
+	// only watches need weights, so registrations use the undecorated client
+	watchClient := c
+	if weights := co.weights(); weights != nil {
+		watchClient = &weightClient{Client: c, scheme: registrationScheme, weights: weights}
+	}
+
 	return service.NewEnvironment(
 		append(
 			eo,
 			service.WithRegistrars(r),
-			service.WithInstancers(newInstancers(l, c, co)),
+			service.WithInstancers(newInstancers(l, watchClient, co)),
 			service.WithCloser(closer),
 		)...,
 	), nil
@@ -1,9 +1,11 @@
 package consul
 
 import (
+	"net"
 	"testing"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/service"
 	"github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -132,8 +134,90 @@ func testNewEnvironmentFull(t *testing.T) {
 	ttlUpdater.AssertExpectations(t)
 }
 
+func testNewEnvironmentResolvePort(t *testing.T) {
+	defer resetClientFactory()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger        = logging.NewTestLogger(nil, t)
+		clientFactory = prepareMockClientFactory()
+		client        = new(mockClient)
+		ttlUpdater    = new(mockTTLUpdater)
+		ports         = new(service.Ports)
+
+		co = Options{
+			Client: &api.Config{
+				Address: "localhost:8500",
+				Scheme:  "https",
+			},
+			Registrations: []api.AgentServiceRegistration{
+				api.AgentServiceRegistration{
+					ID:      "service1",
+					Address: "grubly.com",
+				},
+				api.AgentServiceRegistration{
+					ID:      "service2",
+					Address: "fixed.grubly.com",
+					Port:    1111,
+				},
+			},
+			Listener:     "alternate",
+			PortResolver: ports,
+		}
+	)
+
+	clientFactory.On("NewClient", mock.MatchedBy(func(*api.Client) bool { return true })).Return(client, ttlUpdater).Once()
+
+	client.On("Register",
+		mock.MatchedBy(func(r *api.AgentServiceRegistration) bool {
+			return r.Address == "fixed.grubly.com" && r.Port == 1111
+		}),
+	).Return(error(nil)).Twice()
+
+	client.On("Register",
+		mock.MatchedBy(func(r *api.AgentServiceRegistration) bool {
+			return r.Address == "grubly.com" && r.Port == 34021
+		}),
+	).Return(error(nil)).Once()
+
+	client.On("Deregister", mock.MatchedBy(func(*api.AgentServiceRegistration) bool { return true })).Return(error(nil))
+
+	e, err := NewEnvironment(logger, "http", co)
+	require.NoError(err)
+	require.NotNil(e)
+	assert.True(e.IsRegistered("http://fixed.grubly.com:1111"))
+	assert.False(e.IsRegistered("http://grubly.com:0"))
+
+	// the listener hasn't bound its port yet, so only the fixed registration occurs
+	e.Register()
+	assert.False(e.IsRegistered("http://grubly.com:34021"))
+	e.Deregister()
+
+	require.NoError(ports.Set("alternate", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 34021}))
+	e.Register()
+	assert.True(e.IsRegistered("http://grubly.com:34021"))
+	e.Deregister()
+
+	assert.NoError(e.Close())
+
+	// once the port is bound, new environments advertise it directly
+	clientFactory.On("NewClient", mock.MatchedBy(func(*api.Client) bool { return true })).Return(client, ttlUpdater).Once()
+	e, err = NewEnvironment(logger, "http", Options{Registrations: co.Registrations[:1], Listener: "alternate", PortResolver: ports})
+	require.NoError(err)
+	require.NotNil(e)
+	assert.True(e.IsRegistered("http://grubly.com:34021"))
+	assert.NoError(e.Close())
+
+	clientFactory.AssertExpectations(t)
+	client.AssertExpectations(t)
+	ttlUpdater.AssertExpectations(t)
+}
+
 func TestNewEnvironment(t *testing.T) {
 	t.Run("Empty", testNewEnvironmentEmpty)
 	t.Run("ClientError", testNewEnvironmentClientError)
 	t.Run("Full", testNewEnvironmentFull)
+	t.Run("ResolvePort", testNewEnvironmentResolvePort)
 }
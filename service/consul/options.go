@@ -1,9 +1,13 @@
 package consul
 
 import (
+	"github.com/Comcast/webpa-common/service"
 	"github.com/hashicorp/consul/api"
 )
 
+// DefaultListener is the name of the listener whose bound port is used for registrations without a port
+const DefaultListener = "primary"
+
 type Watch struct {
 	Service     string   `json:"service,omitempty"`
 	Tags        []string `json:"tags,omitempty"`
@@ -15,6 +19,19 @@ type Options struct {
 	DisableGenerateID bool                           `json:"disableGenerateID"`
 	Registrations     []api.AgentServiceRegistration `json:"registrations,omitempty"`
 	Watches           []Watch                        `json:"watches,omitempty"`
+
+	// Listener is the name of the server listener whose bound port is advertised by registrations that
+	// do not specify a port.  If unset, DefaultListener is used.
+	Listener string `json:"listener,omitempty"`
+
+	// PortResolver supplies the bound ports of listeners, typically a *service.Ports shared with the server.
+	// This field is injected by code rather than configuration.  If nil, registrations are used as configured.
+	PortResolver service.PortResolver `json:"-"`
+
+	// Weights receives the weights of discovered instances, as parsed by ParseWeight.  This field is injected by
+	// code rather than configuration, and is typically shared with service.NewWeightedAccessorFactory.  If nil,
+	// weights are not discovered.
+	Weights *service.Weights `json:"-"`
 }
 
 func (o *Options) config() *api.Config {
@@ -48,3 +65,27 @@ func (o *Options) watches() []Watch {
 
 	return nil
 }
+
+func (o *Options) listener() string {
+	if o != nil && len(o.Listener) > 0 {
+		return o.Listener
+	}
+
+	return DefaultListener
+}
+
+func (o *Options) portResolver() service.PortResolver {
+	if o != nil {
+		return o.PortResolver
+	}
+
+	return nil
+}
+
+func (o *Options) weights() *service.Weights {
+	if o != nil {
+		return o.Weights
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package consul
 import (
 	"testing"
 
+	"github.com/Comcast/webpa-common/service"
 	"github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,6 +16,8 @@ func testOptionsDefault(t *testing.T, o *Options) {
 	assert.False(o.disableGenerateID())
 	assert.Len(o.registrations(), 0)
 	assert.Len(o.watches(), 0)
+	assert.Equal(DefaultListener, o.listener())
+	assert.Nil(o.portResolver())
 }
 
 func testOptionsCustom(t *testing.T) {
@@ -22,6 +25,8 @@ func testOptionsCustom(t *testing.T) {
 		assert  = assert.New(t)
 		require = require.New(t)
 
+		ports = new(service.Ports)
+
 		o = Options{
 			Client: &api.Config{
 				Address: "somewhere.com",
@@ -44,6 +49,9 @@ func testOptionsCustom(t *testing.T) {
 					PassingOnly: true,
 				},
 			},
+
+			Listener:     "alternate",
+			PortResolver: ports,
 		}
 	)
 
@@ -74,6 +82,9 @@ func testOptionsCustom(t *testing.T) {
 		},
 		o.watches(),
 	)
+
+	assert.Equal("alternate", o.listener())
+	assert.Equal(ports, o.portResolver())
 }
 
 func TestOptions(t *testing.T) {
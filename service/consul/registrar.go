@@ -5,7 +5,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Comcast/webpa-common/concurrent"
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/service"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/go-kit/kit/sd"
@@ -49,6 +51,8 @@ type ttlCheck struct {
 	failFormat func(time.Time) string
 }
 
+// updatePeriodically runs a concurrent.Scheduler that updates the TTL to passing on each tick of the interval.
+// Once shutdown is closed and the scheduler has stopped, the TTL is updated to critical.
 func (tc ttlCheck) updatePeriodically(updater ttlUpdater, shutdown <-chan struct{}) {
 	ticker, stop := tickerFactory(tc.interval)
 	defer stop()
@@ -64,10 +68,18 @@ func (tc ttlCheck) updatePeriodically(updater ttlUpdater, shutdown <-chan struct
 	// this avoids filling up the server's logs with what are almost certainly just duplicate errors over and over.
 	successiveErrorCount := 0
 
-	for {
-		select {
-		case t := <-ticker:
-			if err := updater.UpdateTTL(tc.checkID, tc.passFormat(t), "pass"); err != nil {
+	waitGroup := new(sync.WaitGroup)
+	concurrent.NewScheduler(
+		concurrent.ScheduleOptions{
+			Name:   tc.checkID,
+			Logger: tc.logger,
+			Period: tc.interval,
+
+			// the ticker drives every run, so that the updates keep the cadence of the TTL
+			After: func(time.Duration) <-chan time.Time { return ticker },
+		},
+		func() {
+			if err := updater.UpdateTTL(tc.checkID, tc.passFormat(time.Now()), "pass"); err != nil {
 				successiveErrorCount++
 				if successiveErrorCount == 1 {
 					tc.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "error while updating TTL to passing", logging.ErrorKey(), err)
@@ -76,12 +88,11 @@ func (tc ttlCheck) updatePeriodically(updater ttlUpdater, shutdown <-chan struct
 				tc.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "update TTL success", "previousErrorCount", successiveErrorCount)
 				successiveErrorCount = 0
 			}
+		},
+	).Run(waitGroup, shutdown)
 
-		case <-shutdown:
-			tc.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "TTL updater shutdown")
-			return
-		}
-	}
+	waitGroup.Wait()
+	tc.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "TTL updater shutdown")
 }
 
 // appendTTLCheck conditionally creates a ttlCheck for the given agent check if and only if the agent check is configured with a TTL.
@@ -197,3 +208,46 @@ func (tr *ttlRegistrar) Deregister() {
 	tr.shutdown = nil
 	tr.registrar.Deregister()
 }
+
+// portRegistrar is an sd.Registrar that sets the port of a registration from a listener's bound port
+// when Register is called.  This supports servers which listen on port 0.  If the listener has not
+// bound its port, the registration is skipped.  A portRegistrar is a service.PendingRegistrar, so that
+// its environment recognizes the resolved instance as registered.
+type portRegistrar struct {
+	logger       log.Logger
+	scheme       string
+	listener     string
+	resolver     service.PortResolver
+	registration *api.AgentServiceRegistration
+	registrar    sd.Registrar
+
+	lock     sync.RWMutex
+	instance string
+}
+
+func (pr *portRegistrar) Register() {
+	port, ok := pr.resolver.Port(pr.listener)
+	if !ok {
+		pr.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to resolve bound port, skipping registration")
+		return
+	}
+
+	pr.logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "resolved bound port", "port", port)
+
+	pr.lock.Lock()
+	pr.registration.Port = port
+	pr.instance = service.FormatInstance(pr.scheme, pr.registration.Address, port)
+	pr.lock.Unlock()
+
+	pr.registrar.Register()
+}
+
+func (pr *portRegistrar) Instance() (string, bool) {
+	pr.lock.RLock()
+	defer pr.lock.RUnlock()
+	return pr.instance, len(pr.instance) > 0
+}
+
+func (pr *portRegistrar) Deregister() {
+	pr.registrar.Deregister()
+}
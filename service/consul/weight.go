@@ -0,0 +1,88 @@
+package consul
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Comcast/webpa-common/service"
+	gokitconsul "github.com/go-kit/kit/sd/consul"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// WeightMetaKey is the service metadata key that holds an instance's weight
+	WeightMetaKey = "weight"
+
+	// WeightTagPrefix prefixes a service tag that holds an instance's weight, e.g. "weight=25".  Metadata takes
+	// precedence over tags.
+	WeightTagPrefix = "weight="
+)
+
+// ParseWeight extracts the weight of a discovered instance from its service metadata or tags.  Weights are
+// clamped via service.ClampWeight.  If the instance carries no valid weight, this function returns false.
+func ParseWeight(entry *api.ServiceEntry) (int, bool) {
+	if entry == nil || entry.Service == nil {
+		return 0, false
+	}
+
+	if value, ok := entry.Service.Meta[WeightMetaKey]; ok {
+		if weight, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return service.ClampWeight(weight), true
+		}
+	}
+
+	for _, tag := range entry.Service.Tags {
+		if strings.HasPrefix(tag, WeightTagPrefix) {
+			if weight, err := strconv.Atoi(strings.TrimSpace(tag[len(WeightTagPrefix):])); err == nil {
+				return service.ClampWeight(weight), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// weightClient decorates a go-kit consul Client so that the weights of discovered instances are recorded.
+// Instances are normalized exactly as instancer events are, so that weights are keyed by the same strings
+// that Accessors receive.  Each result replaces the weights previously reported for the same query.
+type weightClient struct {
+	gokitconsul.Client
+	scheme  string
+	weights *service.Weights
+}
+
+func (wc *weightClient) Service(s, tag string, passingOnly bool, queryOpts *api.QueryOptions) ([]*api.ServiceEntry, *api.QueryMeta, error) {
+	entries, meta, err := wc.Client.Service(s, tag, passingOnly, queryOpts)
+	if err != nil {
+		return entries, meta, err
+	}
+
+	weights := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		if entry == nil || entry.Service == nil {
+			continue
+		}
+
+		address := entry.Service.Address
+		if len(address) == 0 && entry.Node != nil {
+			address = entry.Node.Address
+		}
+
+		instance, err := service.NormalizeInstance(wc.scheme, fmt.Sprintf("%s:%d", address, entry.Service.Port))
+		if err != nil {
+			continue
+		}
+
+		weight, ok := ParseWeight(entry)
+		if !ok {
+			weight = service.DefaultWeight
+		}
+
+		weights[instance] = weight
+	}
+
+	// each watch is a separate source, so that instances which leave one watch are pruned from the store
+	wc.weights.Update(fmt.Sprintf("%s/%s/%t", s, tag, passingOnly), weights)
+	return entries, meta, err
+}
@@ -0,0 +1,98 @@
+package consul
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Comcast/webpa-common/service"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWeight(t *testing.T) {
+	testData := []struct {
+		entry          *api.ServiceEntry
+		expectedWeight int
+		expectedOK     bool
+	}{
+		{nil, 0, false},
+		{&api.ServiceEntry{}, 0, false},
+		{&api.ServiceEntry{Service: &api.AgentService{}}, 0, false},
+		{&api.ServiceEntry{Service: &api.AgentService{Tags: []string{"foo", "weight=25"}}}, 25, true},
+		{&api.ServiceEntry{Service: &api.AgentService{Tags: []string{"weight=abc", "weight=300"}}}, service.DefaultWeight, true},
+		{&api.ServiceEntry{Service: &api.AgentService{Tags: []string{"weight=abc"}}}, 0, false},
+		{&api.ServiceEntry{Service: &api.AgentService{Meta: map[string]string{"weight": " 10 "}, Tags: []string{"weight=25"}}}, 10, true},
+		{&api.ServiceEntry{Service: &api.AgentService{Meta: map[string]string{"weight": "-1"}}}, service.MinWeight, true},
+		{&api.ServiceEntry{Service: &api.AgentService{Meta: map[string]string{"weight": "x"}, Tags: []string{"weight=25"}}}, 25, true},
+	}
+
+	for i, record := range testData {
+		weight, ok := ParseWeight(record.entry)
+		assert.Equal(t, record.expectedWeight, weight, "#%d", i)
+		assert.Equal(t, record.expectedOK, ok, "#%d", i)
+	}
+}
+
+func testWeightClientService(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		client  = new(mockClient)
+		weights = service.NewWeights()
+		wc      = &weightClient{Client: client, scheme: "http", weights: weights}
+		entries = []*api.ServiceEntry{
+			{Node: &api.Node{Address: "node1.webpa.net"}, Service: &api.AgentService{Port: 8080, Tags: []string{"weight=25"}}},
+			{Node: &api.Node{Address: "ignored"}, Service: &api.AgentService{Address: "node2.webpa.net", Port: 80, Meta: map[string]string{"weight": "50"}}},
+			{Node: &api.Node{Address: "node3.webpa.net"}, Service: &api.AgentService{Port: 8080}},
+			{Node: &api.Node{Address: "node4.webpa.net"}},
+			nil,
+		}
+	)
+
+	weights.Set("http://node3.webpa.net:8080", 10)
+	client.On("Service", "test", "tag", true, (*api.QueryOptions)(nil)).Return(entries, new(api.QueryMeta), nil).Once()
+
+	actual, meta, err := wc.Service("test", "tag", true, nil)
+	require.NoError(err)
+	assert.Equal(entries, actual)
+	assert.NotNil(meta)
+
+	assert.Equal(25, weights.Get("http://node1.webpa.net:8080"))
+	assert.Equal(50, weights.Get("http://node2.webpa.net"))
+
+	// instances without weights revert to the default
+	assert.Equal(service.DefaultWeight, weights.Get("http://node3.webpa.net:8080"))
+
+	// instances that are no longer discovered are pruned
+	client.On("Service", "test", "tag", true, (*api.QueryOptions)(nil)).Return(entries[1:], new(api.QueryMeta), nil).Once()
+	_, _, err = wc.Service("test", "tag", true, nil)
+	require.NoError(err)
+	assert.Equal(service.DefaultWeight, weights.Get("http://node1.webpa.net:8080"))
+	assert.Equal(50, weights.Get("http://node2.webpa.net"))
+
+	client.AssertExpectations(t)
+}
+
+func testWeightClientServiceError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		client        = new(mockClient)
+		weights       = service.NewWeights()
+		wc            = &weightClient{Client: client, scheme: "http", weights: weights}
+		expectedError = errors.New("expected")
+	)
+
+	client.On("Service", "test", "", false, (*api.QueryOptions)(nil)).Return(nil, nil, expectedError).Once()
+	_, _, err := wc.Service("test", "", false, nil)
+	assert.Equal(expectedError, err)
+	assert.Zero(weights.Version())
+	client.AssertExpectations(t)
+}
+
+func TestWeightClient(t *testing.T) {
+	t.Run("Service", testWeightClientService)
+	t.Run("ServiceError", testWeightClientServiceError)
+}
@@ -10,8 +10,10 @@ const (
 	InstanceCount       = "sd_instance_count"
 	LastErrorTimestamp  = "sd_last_error_timestamp"
 	LastUpdateTimestamp = "sd_last_update_timestamp"
+	CrossZoneCount      = "sd_cross_zone_count"
 
 	ServiceLabel = "service"
+	ZoneLabel    = "zone"
 )
 
 // Metrics is the service discovery module function for metrics
@@ -47,5 +49,11 @@ func Metrics() []xmetrics.Metric {
 			Help:       "The last time the service discovery backend sent updated instances for a given service",
 			LabelNames: []string{ServiceLabel},
 		},
+		{
+			Name:       CrossZoneCount,
+			Type:       "counter",
+			Help:       "The total count of instance selections routed outside of the local zone",
+			LabelNames: []string{ZoneLabel},
+		},
 	}
 }
@@ -24,4 +24,5 @@ func TestMetrics(t *testing.T) {
 	assert.NotNil(r.NewGauge(InstanceCount))
 	assert.NotNil(r.NewGauge(LastErrorTimestamp))
 	assert.NotNil(r.NewGauge(LastUpdateTimestamp))
+	assert.NotNil(r.NewCounter(CrossZoneCount))
 }
@@ -0,0 +1,124 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/ring"
+	"github.com/Comcast/webpa-common/xhttp"
+)
+
+// DefaultAuditSize is the number of discovery events retained by an AuditListener when no size is supplied
+const DefaultAuditSize = 100
+
+// AuditEntry records a single service discovery event, expressed as the change it made to the set of instances
+type AuditEntry struct {
+	// Timestamp is when the event was received
+	Timestamp time.Time `json:"timestamp"`
+
+	// Source is the key of the sd.Instancer that produced the event
+	Source string `json:"source"`
+
+	// EventCount is the sequence number of the event for its source
+	EventCount int `json:"eventCount"`
+
+	// Added are the instances present in this event that were not present in the previous event for the same source
+	Added []string `json:"added,omitempty"`
+
+	// Removed are the instances present in the previous event for the same source that are not present in this event
+	Removed []string `json:"removed,omitempty"`
+
+	// Instances is the total number of instances after this event
+	Instances int `json:"instances"`
+
+	// Error is the text of any service discovery error.  An error does not change the recorded instances.
+	Error string `json:"error,omitempty"`
+
+	// Stopped indicates that the monitor for the source has exited
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+// AuditListener is a Listener that keeps an in-memory trail of the most recent service discovery events.
+// This type also implements http.Handler, which dumps the trail as JSON so that operators can see exactly
+// when and why routing changed.
+type AuditListener struct {
+	lock      sync.Mutex
+	entries   *ring.Ring[AuditEntry]
+	instances map[string]map[string]bool
+}
+
+// NewAuditListener creates an AuditListener that retains the given number of events.  If size is nonpositive,
+// DefaultAuditSize is used.
+func NewAuditListener(size int) *AuditListener {
+	if size < 1 {
+		size = DefaultAuditSize
+	}
+
+	return &AuditListener{
+		entries:   ring.New[AuditEntry](size),
+		instances: make(map[string]map[string]bool),
+	}
+}
+
+// diff returns the sorted instances in current that are not in previous
+func diff(current, previous map[string]bool) (output []string) {
+	for instance := range current {
+		if !previous[instance] {
+			output = append(output, instance)
+		}
+	}
+
+	sort.Strings(output)
+	return
+}
+
+func (al *AuditListener) MonitorEvent(e Event) {
+	entry := AuditEntry{
+		Timestamp:  time.Now().UTC(),
+		Source:     e.Key,
+		EventCount: e.EventCount,
+		Stopped:    e.Stopped,
+	}
+
+	al.lock.Lock()
+	defer al.lock.Unlock()
+
+	previous := al.instances[e.Key]
+	if e.Err != nil {
+		entry.Error = e.Err.Error()
+	} else if !e.Stopped {
+		current := make(map[string]bool, len(e.Instances))
+		for _, instance := range e.Instances {
+			current[instance] = true
+		}
+
+		entry.Added = diff(current, previous)
+		entry.Removed = diff(previous, current)
+		al.instances[e.Key] = current
+	}
+
+	entry.Instances = len(al.instances[e.Key])
+	al.entries.Add(entry)
+}
+
+// Entries returns a copy of the retained audit entries, oldest first
+func (al *AuditListener) Entries() []AuditEntry {
+	al.lock.Lock()
+	defer al.lock.Unlock()
+	return al.entries.Values()
+}
+
+// ServeHTTP writes the audit trail as a JSON array, oldest event first
+func (al *AuditListener) ServeHTTP(response http.ResponseWriter, _ *http.Request) {
+	data, err := json.Marshal(al.Entries())
+	if err != nil {
+		xhttp.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(data)
+}
@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewAuditListenerDefault(t *testing.T) {
+	assert := assert.New(t)
+	for _, size := range []int{-1, 0} {
+		al := NewAuditListener(size)
+		assert.Equal(DefaultAuditSize, al.entries.Cap())
+		assert.Empty(al.Entries())
+	}
+}
+
+func testAuditListenerEvents(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+
+		al = NewAuditListener(10)
+	)
+
+	al.MonitorEvent(Event{Key: "test", EventCount: 1, Instances: []string{"instance2", "instance1"}})
+	al.MonitorEvent(Event{Key: "another", EventCount: 1, Instances: []string{"instance3"}})
+	al.MonitorEvent(Event{Key: "test", EventCount: 2, Instances: []string{"instance2", "instance4"}})
+	al.MonitorEvent(Event{Key: "test", EventCount: 3, Err: expectedError})
+	al.MonitorEvent(Event{Key: "test", EventCount: 4})
+	al.MonitorEvent(Event{Key: "another", EventCount: 2, Stopped: true})
+
+	entries := al.Entries()
+	require.Len(entries, 6)
+	for _, e := range entries {
+		assert.False(e.Timestamp.IsZero())
+	}
+
+	assert.Equal("test", entries[0].Source)
+	assert.Equal(1, entries[0].EventCount)
+	assert.Equal([]string{"instance1", "instance2"}, entries[0].Added)
+	assert.Empty(entries[0].Removed)
+	assert.Equal(2, entries[0].Instances)
+
+	assert.Equal("another", entries[1].Source)
+	assert.Equal([]string{"instance3"}, entries[1].Added)
+	assert.Equal(1, entries[1].Instances)
+
+	assert.Equal([]string{"instance4"}, entries[2].Added)
+	assert.Equal([]string{"instance1"}, entries[2].Removed)
+	assert.Equal(2, entries[2].Instances)
+
+	// errors do not change the recorded instances
+	assert.Equal(expectedError.Error(), entries[3].Error)
+	assert.Empty(entries[3].Added)
+	assert.Empty(entries[3].Removed)
+	assert.Equal(2, entries[3].Instances)
+
+	assert.Empty(entries[4].Added)
+	assert.Equal([]string{"instance2", "instance4"}, entries[4].Removed)
+	assert.Equal(0, entries[4].Instances)
+
+	assert.True(entries[5].Stopped)
+	assert.Empty(entries[5].Removed)
+	assert.Equal(1, entries[5].Instances)
+}
+
+func testAuditListenerWraparound(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		al     = NewAuditListener(3)
+		counts = func() (output []int) {
+			for _, e := range al.Entries() {
+				output = append(output, e.EventCount)
+			}
+
+			return
+		}
+	)
+
+	for i := 1; i <= 3; i++ {
+		al.MonitorEvent(Event{Key: "test", EventCount: i})
+	}
+
+	assert.Equal([]int{1, 2, 3}, counts())
+
+	al.MonitorEvent(Event{Key: "test", EventCount: 4})
+	assert.Equal([]int{2, 3, 4}, counts())
+}
+
+func testAuditListenerServeHTTP(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		al       = NewAuditListener(10)
+		response = httptest.NewRecorder()
+	)
+
+	al.MonitorEvent(Event{Key: "test", EventCount: 1, Instances: []string{"instance1"}})
+	al.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.HeaderMap.Get("Content-Type"))
+
+	var entries []AuditEntry
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &entries))
+	require.Len(entries, 1)
+	assert.Equal("test", entries[0].Source)
+	assert.Equal([]string{"instance1"}, entries[0].Added)
+}
+
+func TestAuditListener(t *testing.T) {
+	t.Run("Default", testNewAuditListenerDefault)
+	t.Run("Events", testAuditListenerEvents)
+	t.Run("Wraparound", testAuditListenerWraparound)
+	t.Run("ServeHTTP", testAuditListenerServeHTTP)
+}
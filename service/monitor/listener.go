@@ -4,6 +4,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/Comcast/webpa-common/capacitor"
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/service"
 	"github.com/go-kit/kit/log"
@@ -88,7 +89,13 @@ func NewMetricsListener(p provider.Provider) Listener {
 // An UpdatableAccessor may directly be used to receive events by passing Update as the next closure:
 //    ua := new(UpdatableAccessor)
 //    l := NewAccessorListener(f, ua.Update)
-func NewAccessorListener(f service.AccessorFactory, next func(service.Accessor, error)) Listener {
+//
+// If any capacitor options are supplied, events are coalesced through a capacitor so that a storm of service
+// discovery events results in a single accessor rebuild from the most recent event, e.g.:
+//    l := NewAccessorListener(f, ua.Update, capacitor.WithDelay(time.Second), capacitor.WithMaxWait(10*time.Second))
+// In that case, the next closure is invoked on the capacitor's goroutine.  An event indicating that monitoring
+// has stopped is never delayed.
+func NewAccessorListener(f service.AccessorFactory, next func(service.Accessor, error), options ...capacitor.Option) Listener {
 	if next == nil {
 		panic("A next closure is required to receive Accessors")
 	}
@@ -97,7 +104,7 @@ func NewAccessorListener(f service.AccessorFactory, next func(service.Accessor,
 		f = service.DefaultAccessorFactory
 	}
 
-	return ListenerFunc(func(e Event) {
+	rebuild := func(e Event) {
 		switch {
 		case e.Err != nil:
 			next(nil, e.Err)
@@ -108,6 +115,23 @@ func NewAccessorListener(f service.AccessorFactory, next func(service.Accessor,
 		default:
 			next(service.EmptyAccessor(), nil)
 		}
+	}
+
+	if len(options) == 0 {
+		return ListenerFunc(rebuild)
+	}
+
+	// only the most recent event matters, since each rebuild replaces the previous accessor
+	c := capacitor.New(
+		func(v interface{}) { rebuild(v.(Event)) },
+		append(options, capacitor.WithMerge(capacitor.LastWins))...,
+	)
+
+	return ListenerFunc(func(e Event) {
+		c.Submit(e)
+		if e.Stopped {
+			c.Discharge()
+		}
 	})
 }
 
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Comcast/webpa-common/capacitor"
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/service"
 	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
@@ -217,6 +218,76 @@ func TestNewAccessorListener(t *testing.T) {
 			testNewAccessorListenerEmpty(t, f)
 		})
 	})
+
+	t.Run("Coalesced", testNewAccessorListenerCoalesced)
+	t.Run("CoalescedStopped", testNewAccessorListenerCoalescedStopped)
+}
+
+func testNewAccessorListenerCoalesced(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		updates = make(chan service.Accessor, 10)
+
+		l = NewAccessorListener(
+			nil,
+			func(a service.Accessor, err error) {
+				assert.NoError(err)
+				updates <- a
+			},
+			capacitor.WithDelay(50*time.Millisecond),
+		)
+	)
+
+	require.NotNil(l)
+	l.MonitorEvent(Event{Instances: []string{"instance1"}})
+	l.MonitorEvent(Event{Instances: []string{"instance2"}})
+	l.MonitorEvent(Event{Instances: []string{"instance3"}})
+
+	// the storm results in a single rebuild from the most recent event
+	select {
+	case a := <-updates:
+		i, err := a.Get([]byte("asdfasdfasdfsdf"))
+		assert.Equal("instance3", i)
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The accessor was not rebuilt")
+	}
+
+	select {
+	case <-updates:
+		assert.Fail("The events were not coalesced")
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func testNewAccessorListenerCoalescedStopped(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		updates = make(chan service.Accessor, 10)
+
+		l = NewAccessorListener(
+			nil,
+			func(a service.Accessor, err error) {
+				updates <- a
+			},
+			capacitor.WithDelay(time.Hour),
+		)
+	)
+
+	require.NotNil(l)
+	l.MonitorEvent(Event{Instances: []string{"instance1"}})
+	l.MonitorEvent(Event{Stopped: true})
+
+	// a stopped event is dispatched immediately, despite the delay
+	select {
+	case a := <-updates:
+		_, err := a.Get([]byte("asdfasdfasdfsdf"))
+		assert.Error(err)
+	default:
+		assert.Fail("The stopped event was delayed")
+	}
 }
 
 func testNewRegistrarListenerNilRegistrar(t *testing.T) {
@@ -0,0 +1,56 @@
+package monitor
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	errNoDiscoveryEvents = errors.New("No service discovery events received")
+	errMonitorStopped    = errors.New("Service discovery monitor stopped")
+)
+
+// ReadinessListener is a Listener that tracks whether service discovery is connected.  This type
+// also implements health.Checker, and so can be registered as a readiness check.
+//
+// Readiness is reported once at least one event has been received, and only while the most recent event
+// for every key was neither an error nor a stop notification.
+type ReadinessListener struct {
+	lock   sync.RWMutex
+	errors map[string]error
+}
+
+func (rl *ReadinessListener) MonitorEvent(e Event) {
+	var err error
+	if e.Err != nil {
+		err = e.Err
+	} else if e.Stopped {
+		err = errMonitorStopped
+	}
+
+	rl.lock.Lock()
+	if rl.errors == nil {
+		rl.errors = make(map[string]error)
+	}
+
+	rl.errors[e.Key] = err
+	rl.lock.Unlock()
+}
+
+// Check returns nil if service discovery is connected, or the most recent error for any key otherwise
+func (rl *ReadinessListener) Check() error {
+	rl.lock.RLock()
+	defer rl.lock.RUnlock()
+
+	if len(rl.errors) == 0 {
+		return errNoDiscoveryEvents
+	}
+
+	for _, err := range rl.errors {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Comcast/webpa-common/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessListener(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+
+		rl = new(ReadinessListener)
+	)
+
+	var _ health.Checker = rl
+
+	assert.Equal(errNoDiscoveryEvents, rl.Check())
+
+	rl.MonitorEvent(Event{Key: "test", Instances: []string{"instance1"}})
+	assert.NoError(rl.Check())
+
+	rl.MonitorEvent(Event{Key: "another", Err: expectedError})
+	assert.Equal(expectedError, rl.Check())
+
+	rl.MonitorEvent(Event{Key: "another", Instances: []string{"instance2"}})
+	assert.NoError(rl.Check())
+
+	rl.MonitorEvent(Event{Key: "test", Stopped: true})
+	assert.Equal(errMonitorStopped, rl.Check())
+}
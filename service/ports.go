@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// PortResolver supplies the TCP port actually bound by a named listener.  This allows service registration
+// to advertise the real port of a server configured to listen on port 0, where the operating system chooses
+// the port, without duplicating the port in configuration.
+type PortResolver interface {
+	// Port returns the bound port of the given listener.  If the listener has not started listening,
+	// this method returns false.
+	Port(listener string) (int, bool)
+}
+
+// Ports is a PortResolver that records the addresses of listeners as they are bound.  The zero value
+// is ready to use, and a Ports is safe for concurrent use.
+type Ports struct {
+	lock  sync.RWMutex
+	ports map[string]int
+}
+
+// Set records the bound address of a named listener, typically the result of net.Listener.Addr().
+// An error is returned if the address does not contain a port.
+func (p *Ports) Set(listener string, a net.Addr) error {
+	var port int
+	if tcp, ok := a.(*net.TCPAddr); ok {
+		port = tcp.Port
+	} else {
+		_, value, err := net.SplitHostPort(a.String())
+		if err != nil {
+			return err
+		}
+
+		if port, err = strconv.Atoi(value); err != nil {
+			return err
+		}
+	}
+
+	if port < 1 {
+		return fmt.Errorf("The address %s for listener %s has no port", a, listener)
+	}
+
+	p.lock.Lock()
+	if p.ports == nil {
+		p.ports = make(map[string]int)
+	}
+
+	p.ports[listener] = port
+	p.lock.Unlock()
+	return nil
+}
+
+func (p *Ports) Port(listener string) (int, bool) {
+	p.lock.RLock()
+	port, ok := p.ports[listener]
+	p.lock.RUnlock()
+	return port, ok
+}
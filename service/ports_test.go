@@ -0,0 +1,37 @@
+package service
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPorts(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		ports  Ports
+	)
+
+	port, ok := ports.Port("primary")
+	assert.Zero(port)
+	assert.False(ok)
+
+	assert.NoError(ports.Set("primary", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 34021}))
+	assert.NoError(ports.Set("alternate", &net.UnixAddr{Name: "[::1]:8090", Net: "unix"}))
+	assert.Error(ports.Set("bad", &net.UnixAddr{Name: "/var/run/test.sock", Net: "unix"}))
+	assert.Error(ports.Set("bad", &net.UnixAddr{Name: "localhost:http", Net: "unix"}))
+	assert.Error(ports.Set("bad", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}))
+
+	port, ok = ports.Port("primary")
+	assert.Equal(34021, port)
+	assert.True(ok)
+
+	port, ok = ports.Port("alternate")
+	assert.Equal(8090, port)
+	assert.True(ok)
+
+	port, ok = ports.Port("bad")
+	assert.Zero(port)
+	assert.False(ok)
+}
@@ -4,6 +4,17 @@ import (
 	"github.com/go-kit/kit/sd"
 )
 
+// PendingRegistrar is an sd.Registrar whose instance is not known until it registers, e.g. because it
+// advertises the port bound by a server listening on port 0.  Registrars matches a PendingRegistrar
+// by its resolved instance rather than by its key.
+type PendingRegistrar interface {
+	sd.Registrar
+
+	// Instance returns the instance this registrar advertises.  If the instance has not yet been
+	// resolved, this method returns false.
+	Instance() (string, bool)
+}
+
 // Registrars is a aggregate sd.Registrar that allows allows composite registration and deregistration.
 // Keys in this map type will be service advertisements or instances, e.g. "host.com:8080" or "https://foobar.com"
 type Registrars map[string]sd.Registrar
@@ -20,9 +31,22 @@ func (r Registrars) Deregister() {
 	}
 }
 
+// Has tests if the given key refers to a registrar.  Any PendingRegistrar whose resolved instance
+// is the same as the key also matches.
 func (r Registrars) Has(key string) bool {
-	_, ok := r[key]
-	return ok
+	if _, ok := r[key]; ok {
+		return true
+	}
+
+	for _, v := range r {
+		if pr, ok := v.(PendingRegistrar); ok {
+			if instance, resolved := pr.Instance(); resolved && instance == key {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
 func (r Registrars) Len() int {
@@ -25,6 +25,32 @@ func testRegistrars(t *testing.T, r Registrars, expectedInitialLen int) {
 	child.AssertExpectations(t)
 }
 
+type testPendingRegistrar struct {
+	MockRegistrar
+	instance string
+}
+
+func (tpr *testPendingRegistrar) Instance() (string, bool) {
+	return tpr.instance, len(tpr.instance) > 0
+}
+
+func testRegistrarsPending(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		pending = new(testPendingRegistrar)
+		r       Registrars
+	)
+
+	r.Add("pending", pending)
+	assert.True(r.Has("pending"))
+	assert.False(r.Has("http://localhost:8080"))
+
+	pending.instance = "http://localhost:8080"
+	assert.True(r.Has("pending"))
+	assert.True(r.Has("http://localhost:8080"))
+	assert.False(r.Has("http://localhost:9090"))
+}
+
 func TestRegistrars(t *testing.T) {
 	t.Run("Nil", func(t *testing.T) {
 		testRegistrars(t, nil, 0)
@@ -33,4 +59,6 @@ func TestRegistrars(t *testing.T) {
 	t.Run("Empty", func(t *testing.T) {
 		testRegistrars(t, Registrars{}, 0)
 	})
+
+	t.Run("Pending", testRegistrarsPending)
 }
@@ -17,6 +17,13 @@ var (
 )
 
 func NewEnvironment(l log.Logger, u xviper.Unmarshaler) (service.Environment, error) {
+	return NewEnvironmentWithPorts(l, u, nil)
+}
+
+// NewEnvironmentWithPorts is like NewEnvironment, but supplies a PortResolver to backends that support
+// registering the ports actually bound by the server's listeners.  Typically, p is the same *service.Ports
+// given to the server.  A nil PortResolver means registrations are used exactly as configured.
+func NewEnvironmentWithPorts(l log.Logger, u xviper.Unmarshaler, p service.PortResolver) (service.Environment, error) {
 	if l == nil {
 		l = logging.DefaultLogger()
 	}
@@ -26,9 +33,18 @@ func NewEnvironment(l log.Logger, u xviper.Unmarshaler) (service.Environment, er
 		return nil, err
 	}
 
+	var weights *service.Weights
+	if o.weighted() {
+		weights = service.NewWeights()
+	}
+
 	eo := []service.Option{
 		service.WithAccessorFactory(
-			service.NewConsistentAccessorFactory(o.vnodeCount()),
+			service.NewZoneAccessorFactory(
+				o.Zone,
+				nil,
+				service.NewWeightedAccessorFactory(o.vnodeCount(), weights),
+			),
 		),
 		service.WithDefaultScheme(o.defaultScheme()),
 	}
@@ -56,7 +72,16 @@ func NewEnvironment(l log.Logger, u xviper.Unmarshaler) (service.Environment, er
 
 	if o.Consul != nil {
 		l.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "using consul for service discovery")
-		return consulEnvironmentFactory(l, o.DefaultScheme, *o.Consul, eo...)
+		co := *o.Consul
+		if co.PortResolver == nil {
+			co.PortResolver = p
+		}
+
+		if co.Weights == nil {
+			co.Weights = weights
+		}
+
+		return consulEnvironmentFactory(l, o.DefaultScheme, co, eo...)
 	}
 
 	return nil, nil
@@ -222,10 +222,153 @@ func testNewEnvironmentConsul(t *testing.T) {
 	assert.NoError(actualEnvironment.Close())
 }
 
+func testNewEnvironmentConsulWithPorts(t *testing.T) {
+	defer resetEnvironmentFactories()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger              = logging.NewTestLogger(nil, t)
+		ports               = new(service.Ports)
+		expectedEnvironment = service.NewEnvironment()
+
+		v             = viper.New()
+		configuration = strings.NewReader(`
+			{
+				"consul": {
+					"listener": "alternate",
+					"registrations": [
+						{
+							"name": "test",
+							"address": "foobar.com"
+						}
+					]
+				}
+			}
+		`)
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(configuration))
+
+	consulEnvironmentFactory = func(l log.Logger, registrationScheme string, co consul.Options, eo ...service.Option) (service.Environment, error) {
+		assert.Equal(logger, l)
+		assert.Equal(
+			consul.Options{
+				Registrations: []api.AgentServiceRegistration{
+					api.AgentServiceRegistration{
+						Name:    "test",
+						Address: "foobar.com",
+					},
+				},
+				Listener:     "alternate",
+				PortResolver: ports,
+			},
+			co,
+		)
+
+		return expectedEnvironment, nil
+	}
+
+	actualEnvironment, err := NewEnvironmentWithPorts(logger, v, ports)
+	require.NoError(err)
+	assert.Equal(expectedEnvironment, actualEnvironment)
+	assert.NoError(actualEnvironment.Close())
+}
+
+func testNewEnvironmentConsulWeighted(t *testing.T) {
+	defer resetEnvironmentFactories()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger              = logging.NewTestLogger(nil, t)
+		expectedEnvironment = service.NewEnvironment()
+		instances           = []string{"https://node1.webpa.net", "https://node2.webpa.net"}
+
+		v             = viper.New()
+		configuration = strings.NewReader(`
+			{
+				"weighted": true,
+				"consul": {
+					"watches": [
+						{
+							"service": "test"
+						}
+					]
+				}
+			}
+		`)
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(configuration))
+
+	consulEnvironmentFactory = func(l log.Logger, registrationScheme string, co consul.Options, eo ...service.Option) (service.Environment, error) {
+		require.NotNil(co.Weights)
+
+		// the accessors honor the weights given to the consul backend
+		co.Weights.Set(instances[0], service.MinWeight)
+		a := service.NewEnvironment(eo...).AccessorFactory()(instances)
+		for _, key := range []string{"a", "b", "c", "d"} {
+			instance, err := a.Get([]byte(key))
+			assert.NoError(err)
+			assert.Equal(instances[1], instance)
+		}
+
+		return expectedEnvironment, nil
+	}
+
+	actualEnvironment, err := NewEnvironment(logger, v)
+	require.NoError(err)
+	assert.Equal(expectedEnvironment, actualEnvironment)
+	assert.NoError(actualEnvironment.Close())
+}
+
+func testNewEnvironmentFixedWithZone(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger = logging.NewTestLogger(nil, t)
+		v      = viper.New()
+
+		configuration = strings.NewReader(`
+			{
+				"fixed": ["https://node1.east.webpa.net", "https://node2.west.webpa.net"],
+				"zone": {
+					"local": "west"
+				}
+			}
+		`)
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(configuration))
+
+	e, err := NewEnvironment(logger, v)
+	require.NoError(err)
+	require.NotNil(e)
+
+	a := e.AccessorFactory()([]string{"https://node1.east.webpa.net", "https://node2.west.webpa.net"})
+	for _, key := range []string{"a", "b", "c", "d"} {
+		instance, err := a.Get([]byte(key))
+		assert.NoError(err)
+		assert.Equal("https://node2.west.webpa.net", instance)
+	}
+
+	assert.NoError(e.Close())
+}
+
 func TestNewEnvironment(t *testing.T) {
 	t.Run("Empty", testNewEnvironmentEmpty)
 	t.Run("UnmarshalError", testNewEnvironmentUnmarshalError)
 	t.Run("Fixed", testNewEnvironmentFixed)
+	t.Run("FixedWithZone", testNewEnvironmentFixedWithZone)
 	t.Run("Zookeeper", testNewEnvironmentZookeeper)
 	t.Run("Consul", testNewEnvironmentConsul)
+	t.Run("ConsulWithPorts", testNewEnvironmentConsulWithPorts)
+	t.Run("ConsulWeighted", testNewEnvironmentConsulWeighted)
 }
@@ -12,6 +12,13 @@ type Options struct {
 	DisableFilter bool   `json:"disableFilter"`
 	DefaultScheme string `json:"defaultScheme"`
 
+	// Zone enables zone-aware instance selection.  See service.NewZoneAccessorFactory.
+	Zone *service.ZoneOptions `json:"zone,omitempty"`
+
+	// Weighted enables weighted consistent hashing, using the weights of instances discovered through
+	// consul metadata or tags.  See service.NewWeightedAccessorFactory and consul.ParseWeight.
+	Weighted bool `json:"weighted"`
+
 	Fixed     []string        `json:"fixed,omitempty"`
 	Zookeeper *zk.Options     `json:"zookeeper,omitempty"`
 	Consul    *consul.Options `json:"consul,omitempty"`
@@ -25,6 +32,14 @@ func (o *Options) vnodeCount() int {
 	return service.DefaultVnodeCount
 }
 
+func (o *Options) weighted() bool {
+	if o != nil {
+		return o.Weighted
+	}
+
+	return false
+}
+
 func (o *Options) disableFilter() bool {
 	if o != nil {
 		return o.DisableFilter
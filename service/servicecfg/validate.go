@@ -0,0 +1,105 @@
+package servicecfg
+
+import (
+	"strings"
+
+	"github.com/Comcast/webpa-common/service"
+	"github.com/Comcast/webpa-common/xviper"
+)
+
+// Validate unmarshals the service discovery Options and cross-checks them without connecting to any
+// backend.  All problems are returned at once as an xviper.ValidationErrors.  An empty configuration,
+// which disables service discovery, is valid.
+func Validate(u xviper.Unmarshaler) error {
+	o := new(Options)
+	if err := u.Unmarshal(&o); err != nil {
+		return err
+	}
+
+	return o.Validate()
+}
+
+// validPort tests if a configured port is either unset or a legal TCP port
+func validPort(port int) bool {
+	return port >= 0 && port <= 65535
+}
+
+// Validate checks these Options for syntactic and semantic problems, returning all of them as an
+// xviper.ValidationErrors.  A nil Options is valid.
+func (o *Options) Validate() error {
+	if o == nil {
+		return nil
+	}
+
+	var errs xviper.ValidationErrors
+	if o.VnodeCount < 0 {
+		errs.Addf("Invalid vnodeCount: %d", o.VnodeCount)
+	}
+
+	var backends []string
+	if len(o.Fixed) > 0 {
+		backends = append(backends, "fixed")
+	}
+
+	if o.Zookeeper != nil {
+		backends = append(backends, "zookeeper")
+	}
+
+	if o.Consul != nil {
+		backends = append(backends, "consul")
+	}
+
+	if len(backends) > 1 {
+		errs.Addf("Only one service discovery backend may be configured, found: %s", strings.Join(backends, ", "))
+	}
+
+	for _, instance := range o.Fixed {
+		if _, err := service.NormalizeInstance(o.DefaultScheme, instance); err != nil {
+			errs.Addf("Invalid fixed instance [%s]: %s", instance, err)
+		}
+	}
+
+	if o.Zone != nil {
+		if o.Zone.HostLabel != nil && *o.Zone.HostLabel < 0 {
+			errs.Addf("Invalid zone hostLabel: %d", *o.Zone.HostLabel)
+		}
+
+		if o.Zone.MinLocalInstances < 0 {
+			errs.Addf("Invalid zone minLocalInstances: %d", o.Zone.MinLocalInstances)
+		}
+	}
+
+	if o.Zookeeper != nil {
+		for i, r := range o.Zookeeper.Registrations {
+			if !validPort(r.Port) {
+				errs.Addf("Invalid port for zookeeper registration %d: %d", i, r.Port)
+			}
+		}
+
+		for i, w := range o.Zookeeper.Watches {
+			if len(strings.TrimSpace(w)) == 0 {
+				errs.Addf("Blank zookeeper watch %d", i)
+			}
+		}
+	}
+
+	if o.Consul != nil {
+		for i, r := range o.Consul.Registrations {
+			if len(r.Name) == 0 {
+				errs.Addf("A name is required for consul registration %d", i)
+			}
+
+			if !validPort(r.Port) {
+				errs.Addf("Invalid port for consul registration %d: %d", i, r.Port)
+			}
+		}
+
+		for i, w := range o.Consul.Watches {
+			if len(w.Service) == 0 {
+				errs.Addf("A service is required for consul watch %d", i)
+			}
+		}
+	}
+
+	return errs.Err()
+}
@@ -0,0 +1,87 @@
+package servicecfg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/webpa-common/xviper"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testValidateUnmarshalError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected unmarshal error")
+	)
+
+	assert.Equal(expectedError, Validate(xviper.InvalidUnmarshaler{Err: expectedError}))
+}
+
+func testValidateValid(t *testing.T) {
+	testData := []string{
+		`{}`,
+		`{"fixed": ["instance1.com:1234", "https://instance2.net"]}`,
+		`{"zookeeper": {"client": {"connection": "localhost:2181"}, "registrations": [{"port": 8080}], "watches": ["/xmidt/talaria"]}}`,
+		`{"consul": {"registrations": [{"name": "talaria", "port": 8080}], "watches": [{"service": "talaria"}]}}`,
+	}
+
+	for _, configuration := range testData {
+		t.Run(configuration, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+				v       = viper.New()
+			)
+
+			v.SetConfigType("json")
+			require.NoError(v.ReadConfig(strings.NewReader(configuration)))
+			assert.NoError(Validate(v))
+		})
+	}
+}
+
+func testValidateInvalid(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		v       = viper.New()
+
+		configuration = strings.NewReader(`
+			{
+				"vnodeCount": -1,
+				"zone": {"local": "east", "hostLabel": -1, "minLocalInstances": -1},
+				"fixed": ["instance1.com:1234", "instance2.net:notaport", " "],
+				"consul": {
+					"registrations": [{"name": "talaria", "port": 8080}, {"port": 70000}],
+					"watches": [{"service": "talaria"}, {"tags": ["stage=prod"]}]
+				}
+			}
+		`)
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(configuration))
+
+	err := Validate(v)
+	require.Error(err)
+
+	errs, ok := err.(xviper.ValidationErrors)
+	require.True(ok)
+
+	// every problem is reported, not just the first
+	assert.Len(errs, 9)
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("UnmarshalError", testValidateUnmarshalError)
+	t.Run("Valid", testValidateValid)
+	t.Run("Invalid", testValidateInvalid)
+}
+
+func TestOptionsValidateNil(t *testing.T) {
+	var o *Options
+	assert.NoError(t, o.Validate())
+}
@@ -0,0 +1,26 @@
+/*
+Package servicetest provides a fake service discovery backend for integration tests.  An Instancer is a
+go-kit sd.Instancer whose events are driven by the test rather than by Consul or Zookeeper, and a Registrar
+is an sd.Registrar that can register an instance with an Instancer.  Both can be passed anywhere the service
+and service/monitor packages accept their go-kit counterparts.
+
+Event sequences can be applied directly or scripted:
+
+	instancer := servicetest.NewInstancer("http://host1:8080", "http://host2:8080")
+	m, _ := monitor.New(
+		monitor.WithInstancers(service.Instancers{"test": instancer}),
+		monitor.WithListeners(monitor.NewAccessorListener(nil, accessor.Update)),
+	)
+
+	defer m.Stop()
+	instancer.Play(
+		servicetest.Deregister("http://host1:8080"),
+		servicetest.Flap("http://host2:8080", 3),
+		servicetest.Fail(errors.New("discovery unavailable")),
+		servicetest.Register("http://host1:8080", "http://host3:8080"),
+	)
+
+As with go-kit's own instancers, events are delivered synchronously to each registered channel, so a
+subscriber must keep consuming its channel for as long as it is registered.
+*/
+package servicetest
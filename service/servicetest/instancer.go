@@ -0,0 +1,212 @@
+package servicetest
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// Instancer is a fake sd.Instancer whose state is driven by test code.  The state is either a set of instances
+// or a service discovery error.  Each change to the state is sent as an sd.Event to every registered channel,
+// and a newly registered channel immediately receives the current state.  As with go-kit's instancers, an
+// update that does not change the state sends no events.
+//
+// Once stopped, an Instancer ignores all updates.
+type Instancer struct {
+	lock       sync.Mutex
+	instances  []string
+	err        error
+	stopped    bool
+	events     int
+	registered map[chan<- sd.Event]bool
+}
+
+var _ sd.Instancer = (*Instancer)(nil)
+
+// NewInstancer creates an Instancer whose initial state is the given set of instances
+func NewInstancer(instances ...string) *Instancer {
+	return &Instancer{
+		instances:  normalize(instances),
+		registered: make(map[chan<- sd.Event]bool),
+	}
+}
+
+// normalize produces a sorted, deduplicated copy of a set of instances
+func normalize(instances []string) []string {
+	set := make(map[string]bool, len(instances))
+	normalized := make([]string, 0, len(instances))
+	for _, i := range instances {
+		if !set[i] {
+			set[i] = true
+			normalized = append(normalized, i)
+		}
+	}
+
+	sort.Strings(normalized)
+	return normalized
+}
+
+// event produces the sd.Event for the current state.  This method must be invoked under the lock.
+func (i *Instancer) event() sd.Event {
+	if i.err != nil {
+		return sd.Event{Err: i.err}
+	}
+
+	return sd.Event{Instances: append([]string{}, i.instances...)}
+}
+
+// update changes the state and broadcasts it.  This method must be invoked under the lock.
+func (i *Instancer) update(instances []string, err error) {
+	if i.stopped {
+		return
+	}
+
+	if err == nil && i.err == nil && equal(i.instances, instances) {
+		return
+	}
+
+	i.instances, i.err = instances, err
+	for ch := range i.registered {
+		i.events++
+		ch <- i.event()
+	}
+}
+
+// equal tests if two normalized sets of instances are the same
+func equal(left, right []string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+
+	for j := range left {
+		if left[j] != right[j] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Register subscribes a channel to this Instancer's events.  The channel is sent the current state.
+func (i *Instancer) Register(ch chan<- sd.Event) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.registered[ch] = true
+	i.events++
+	ch <- i.event()
+}
+
+// Deregister unsubscribes a channel
+func (i *Instancer) Deregister(ch chan<- sd.Event) {
+	i.lock.Lock()
+	delete(i.registered, ch)
+	i.lock.Unlock()
+}
+
+// Stop causes this Instancer to ignore all subsequent updates
+func (i *Instancer) Stop() {
+	i.lock.Lock()
+	i.stopped = true
+	i.lock.Unlock()
+}
+
+// Stopped tests if Stop has been called
+func (i *Instancer) Stopped() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.stopped
+}
+
+// Registered returns the number of channels currently subscribed to this Instancer
+func (i *Instancer) Registered() int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return len(i.registered)
+}
+
+// Events returns the total number of events this Instancer has sent, across all channels
+func (i *Instancer) Events() int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.events
+}
+
+// Instances returns the current set of instances, in sorted order.  While in an error state, this is the set
+// of instances prior to the error.
+func (i *Instancer) Instances() []string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return append([]string{}, i.instances...)
+}
+
+// Err returns the current service discovery error, if any
+func (i *Instancer) Err() error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.err
+}
+
+// Set replaces the current instances, clearing any error
+func (i *Instancer) Set(instances ...string) {
+	i.lock.Lock()
+	i.update(normalize(instances), nil)
+	i.lock.Unlock()
+}
+
+// Add adds instances to the current set, clearing any error
+func (i *Instancer) Add(instances ...string) {
+	i.lock.Lock()
+	i.update(normalize(append(append([]string{}, i.instances...), instances...)), nil)
+	i.lock.Unlock()
+}
+
+// Remove removes instances from the current set, clearing any error
+func (i *Instancer) Remove(instances ...string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	removed := make(map[string]bool, len(instances))
+	for _, r := range instances {
+		removed[r] = true
+	}
+
+	remaining := make([]string, 0, len(i.instances))
+	for _, existing := range i.instances {
+		if !removed[existing] {
+			remaining = append(remaining, existing)
+		}
+	}
+
+	i.update(remaining, nil)
+}
+
+// Fail puts this Instancer into an error state, which sends an event carrying err.  The current instances are
+// retained, so that a subsequent Add or Remove is relative to the instances prior to the failure.  If err is nil,
+// this method does nothing.
+func (i *Instancer) Fail(err error) {
+	if err == nil {
+		return
+	}
+
+	i.lock.Lock()
+	i.update(i.instances, err)
+	i.lock.Unlock()
+}
+
+// Flap removes and then restores an instance the given number of times, as happens when a service's health
+// check oscillates.  Each flap sends two events.
+func (i *Instancer) Flap(instance string, times int) {
+	for j := 0; j < times; j++ {
+		i.Remove(instance)
+		i.Add(instance)
+	}
+}
+
+// Play applies a sequence of steps to this Instancer, in order
+func (i *Instancer) Play(steps ...Step) {
+	for _, s := range steps {
+		s(i)
+	}
+}
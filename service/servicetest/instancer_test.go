@@ -0,0 +1,125 @@
+package servicetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+)
+
+func testInstancerInitialState(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		instancer = NewInstancer("b", "a", "b")
+		events    = make(chan sd.Event, 1)
+	)
+
+	assert.Equal([]string{"a", "b"}, instancer.Instances())
+	assert.NoError(instancer.Err())
+	assert.Zero(instancer.Registered())
+	assert.Zero(instancer.Events())
+
+	instancer.Register(events)
+	assert.Equal(1, instancer.Registered())
+	assert.Equal(sd.Event{Instances: []string{"a", "b"}}, <-events)
+	assert.Equal(1, instancer.Events())
+
+	instancer.Deregister(events)
+	assert.Zero(instancer.Registered())
+	instancer.Add("c")
+	assert.Empty(events)
+	assert.Equal(1, instancer.Events())
+}
+
+func testInstancerUpdates(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		instancer     = NewInstancer()
+		first         = make(chan sd.Event, 10)
+		second        = make(chan sd.Event, 10)
+		expectedError = errors.New("expected")
+	)
+
+	instancer.Register(first)
+	instancer.Register(second)
+	instancer.Add("b", "a")
+	instancer.Add("a")
+	instancer.Remove("b", "nosuch")
+	instancer.Fail(nil)
+	instancer.Fail(expectedError)
+	instancer.Add("c")
+	instancer.Set("d")
+	instancer.Set("d")
+
+	assert.Equal([]string{"d"}, instancer.Instances())
+	assert.NoError(instancer.Err())
+
+	expected := []sd.Event{
+		{Instances: []string{}},
+		{Instances: []string{"a", "b"}},
+		{Instances: []string{"a"}},
+		{Err: expectedError},
+		{Instances: []string{"a", "c"}},
+		{Instances: []string{"d"}},
+	}
+
+	for _, events := range []chan sd.Event{first, second} {
+		close(events)
+		var actual []sd.Event
+		for e := range events {
+			actual = append(actual, e)
+		}
+
+		assert.Equal(expected, actual)
+	}
+
+	assert.Equal(12, instancer.Events())
+}
+
+func testInstancerFlap(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		instancer = NewInstancer("a", "b")
+		events    = make(chan sd.Event, 10)
+	)
+
+	instancer.Register(events)
+	<-events
+
+	instancer.Flap("a", 2)
+	assert.Equal([]string{"a", "b"}, instancer.Instances())
+	assert.Len(events, 4)
+	for i := 0; i < 2; i++ {
+		assert.Equal(sd.Event{Instances: []string{"b"}}, <-events)
+		assert.Equal(sd.Event{Instances: []string{"a", "b"}}, <-events)
+	}
+}
+
+func testInstancerStop(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		instancer = NewInstancer("a")
+		events    = make(chan sd.Event, 10)
+	)
+
+	instancer.Register(events)
+	<-events
+
+	assert.False(instancer.Stopped())
+	instancer.Stop()
+	assert.True(instancer.Stopped())
+
+	instancer.Add("b")
+	instancer.Fail(errors.New("ignored"))
+	assert.Empty(events)
+	assert.Equal([]string{"a"}, instancer.Instances())
+	assert.NoError(instancer.Err())
+}
+
+func TestInstancer(t *testing.T) {
+	t.Run("InitialState", testInstancerInitialState)
+	t.Run("Updates", testInstancerUpdates)
+	t.Run("Flap", testInstancerFlap)
+	t.Run("Stop", testInstancerStop)
+}
@@ -0,0 +1,74 @@
+package servicetest
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// Registrar is a fake sd.Registrar that records its registration state.  If bound to an Instancer, registering
+// adds the instance to that Instancer and deregistering removes it, as a real discovery backend would.
+type Registrar struct {
+	lock        sync.Mutex
+	instancer   *Instancer
+	instance    string
+	registered  bool
+	registers   int
+	deregisters int
+}
+
+var _ sd.Registrar = (*Registrar)(nil)
+
+// NewRegistrar creates a Registrar for the given instance.  The Instancer may be nil, in which case
+// registration state is recorded but no events are sent.
+func NewRegistrar(i *Instancer, instance string) *Registrar {
+	return &Registrar{
+		instancer: i,
+		instance:  instance,
+	}
+}
+
+// Register marks this Registrar as registered and adds its instance to any bound Instancer
+func (r *Registrar) Register() {
+	r.lock.Lock()
+	r.registered = true
+	r.registers++
+	r.lock.Unlock()
+
+	if r.instancer != nil {
+		r.instancer.Add(r.instance)
+	}
+}
+
+// Deregister marks this Registrar as deregistered and removes its instance from any bound Instancer
+func (r *Registrar) Deregister() {
+	r.lock.Lock()
+	r.registered = false
+	r.deregisters++
+	r.lock.Unlock()
+
+	if r.instancer != nil {
+		r.instancer.Remove(r.instance)
+	}
+}
+
+// IsRegistered tests if this Registrar is currently registered
+func (r *Registrar) IsRegistered() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.registered
+}
+
+// Registers returns the number of times Register has been called
+func (r *Registrar) Registers() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.registers
+}
+
+// Deregisters returns the number of times Deregister has been called
+func (r *Registrar) Deregisters() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.deregisters
+}
@@ -0,0 +1,49 @@
+package servicetest
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRegistrarUnbound(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		registrar = NewRegistrar(nil, "a")
+	)
+
+	assert.False(registrar.IsRegistered())
+	registrar.Register()
+	assert.True(registrar.IsRegistered())
+	registrar.Register()
+	registrar.Deregister()
+	assert.False(registrar.IsRegistered())
+	assert.Equal(2, registrar.Registers())
+	assert.Equal(1, registrar.Deregisters())
+}
+
+func testRegistrarBound(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		instancer = NewInstancer("b")
+		registrar = NewRegistrar(instancer, "a")
+		events    = make(chan sd.Event, 10)
+	)
+
+	instancer.Register(events)
+	<-events
+
+	registrar.Register()
+	assert.Equal([]string{"a", "b"}, instancer.Instances())
+	assert.Equal(sd.Event{Instances: []string{"a", "b"}}, <-events)
+
+	registrar.Deregister()
+	assert.Equal([]string{"b"}, instancer.Instances())
+	assert.Equal(sd.Event{Instances: []string{"b"}}, <-events)
+}
+
+func TestRegistrar(t *testing.T) {
+	t.Run("Unbound", testRegistrarUnbound)
+	t.Run("Bound", testRegistrarBound)
+}
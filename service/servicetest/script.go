@@ -0,0 +1,51 @@
+package servicetest
+
+import (
+	"time"
+)
+
+// Step is a single scripted change to an Instancer
+type Step func(*Instancer)
+
+// Register is a Step that adds instances, as when services register with the discovery backend
+func Register(instances ...string) Step {
+	return func(i *Instancer) {
+		i.Add(instances...)
+	}
+}
+
+// Deregister is a Step that removes instances, as when services deregister or fail their health checks
+func Deregister(instances ...string) Step {
+	return func(i *Instancer) {
+		i.Remove(instances...)
+	}
+}
+
+// Set is a Step that replaces all instances
+func Set(instances ...string) Step {
+	return func(i *Instancer) {
+		i.Set(instances...)
+	}
+}
+
+// Flap is a Step that removes and restores an instance the given number of times
+func Flap(instance string, times int) Step {
+	return func(i *Instancer) {
+		i.Flap(instance, times)
+	}
+}
+
+// Fail is a Step that sends a service discovery error
+func Fail(err error) Step {
+	return func(i *Instancer) {
+		i.Fail(err)
+	}
+}
+
+// Pause is a Step that waits for the given duration before the next step, which gives asynchronous
+// subscribers such as a monitor time to react
+func Pause(d time.Duration) Step {
+	return func(*Instancer) {
+		time.Sleep(d)
+	}
+}
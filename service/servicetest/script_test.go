@@ -0,0 +1,112 @@
+package servicetest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/service"
+	"github.com/Comcast/webpa-common/service/monitor"
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPlaySteps(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		instancer     = NewInstancer("a")
+		events        = make(chan sd.Event, 10)
+		expectedError = errors.New("expected")
+	)
+
+	instancer.Register(events)
+	<-events
+
+	start := time.Now()
+	instancer.Play(
+		Register("b", "c"),
+		Deregister("a"),
+		Flap("b", 1),
+		Pause(10*time.Millisecond),
+		Fail(expectedError),
+		Set("d"),
+	)
+
+	assert.True(time.Since(start) >= 10*time.Millisecond)
+	close(events)
+
+	var actual []sd.Event
+	for e := range events {
+		actual = append(actual, e)
+	}
+
+	assert.Equal(
+		[]sd.Event{
+			{Instances: []string{"a", "b", "c"}},
+			{Instances: []string{"b", "c"}},
+			{Instances: []string{"c"}},
+			{Instances: []string{"b", "c"}},
+			{Err: expectedError},
+			{Instances: []string{"d"}},
+		},
+		actual,
+	)
+}
+
+// waitForInstance polls an accessor until it returns the expected instance or error
+func waitForInstance(t *testing.T, accessor service.Accessor, expectedInstance string, expectError bool) {
+	for attempt := 0; attempt < 200; attempt++ {
+		instance, err := accessor.Get([]byte("mac:112233445566"))
+		if expectError == (err != nil) && instance == expectedInstance {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	require.Fail(t, "The accessor did not reach the expected state", "instance: %s, error: %t", expectedInstance, expectError)
+}
+
+func testPlayMonitor(t *testing.T) {
+	var (
+		require = require.New(t)
+
+		instancer = NewInstancer("http://host1:8080")
+		registrar = NewRegistrar(nil, "http://self:8080")
+		accessor  = new(service.UpdatableAccessor)
+	)
+
+	m, err := monitor.New(
+		monitor.WithLogger(logging.DefaultLogger()),
+		monitor.WithInstancers(service.Instancers{"test": instancer}),
+		monitor.WithListeners(
+			monitor.NewAccessorListener(nil, accessor.Update),
+			monitor.NewRegistrarListener(logging.DefaultLogger(), registrar, false),
+		),
+	)
+
+	require.NoError(err)
+	defer m.Stop()
+
+	waitForInstance(t, accessor, "http://host1:8080", false)
+	require.True(registrar.IsRegistered())
+
+	instancer.Play(Set("http://host2:8080"), Flap("http://host2:8080", 3))
+	waitForInstance(t, accessor, "http://host2:8080", false)
+
+	instancer.Play(Fail(errors.New("discovery unavailable")))
+	waitForInstance(t, accessor, "", true)
+	require.False(registrar.IsRegistered())
+
+	instancer.Play(Register("http://host3:8080"), Deregister("http://host2:8080"))
+	waitForInstance(t, accessor, "http://host3:8080", false)
+	require.True(registrar.IsRegistered())
+	require.Equal(2, registrar.Registers())
+}
+
+func TestPlay(t *testing.T) {
+	t.Run("Steps", testPlaySteps)
+	t.Run("Monitor", testPlayMonitor)
+}
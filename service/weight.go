@@ -0,0 +1,247 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/spaolacci/murmur3"
+)
+
+const (
+	// DefaultWeight is the weight of an instance that carries no weight information.  Weights are percentages of
+	// DefaultWeight, so an instance with weight 25 receives roughly a quarter of the keys of a fully weighted instance.
+	DefaultWeight = 100
+
+	// MinWeight is the smallest allowed weight.  An instance with this weight receives no keys.
+	MinWeight = 0
+)
+
+// Weights is a concurrency-safe store of instance weights, shared between a service discovery backend that
+// discovers weights and the Accessors that honor them.  Instances without a weight have DefaultWeight.
+//
+// Each change to a weight increments the version of this store, which allows weighted Accessors to rebuild
+// themselves even when the set of instances is unchanged.
+type Weights struct {
+	lock    sync.RWMutex
+	weights map[string]int
+	sources map[string]map[string]bool
+	version uint64
+}
+
+// NewWeights creates an empty Weights store
+func NewWeights() *Weights {
+	return &Weights{
+		weights: make(map[string]int),
+		sources: make(map[string]map[string]bool),
+	}
+}
+
+// ClampWeight constrains a weight to the range MinWeight to DefaultWeight, inclusive
+func ClampWeight(weight int) int {
+	switch {
+	case weight < MinWeight:
+		return MinWeight
+	case weight > DefaultWeight:
+		return DefaultWeight
+	default:
+		return weight
+	}
+}
+
+// Set assigns a weight to an instance.  The weight is clamped via ClampWeight.  An instance set to DefaultWeight
+// is removed from this store.
+func (w *Weights) Set(instance string, weight int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.set(instance, weight)
+}
+
+// Update replaces the weights reported by a single source, such as one service discovery watch.  Instances the
+// source previously reported but no longer does are removed from this store, unless another source still reports
+// them, so that the store does not grow without bound as instances come and go.
+func (w *Weights) Update(source string, weights map[string]int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	reported := make(map[string]bool, len(weights))
+	for instance, weight := range weights {
+		reported[instance] = true
+		w.set(instance, weight)
+	}
+
+	previous := w.sources[source]
+	if len(reported) > 0 {
+		w.sources[source] = reported
+	} else {
+		delete(w.sources, source)
+	}
+
+	for instance := range previous {
+		if !reported[instance] && !w.reportedElsewhere(source, instance) {
+			w.set(instance, DefaultWeight)
+		}
+	}
+}
+
+// reportedElsewhere tests if any source other than the given one reports an instance.  This method must be
+// called under the write lock.
+func (w *Weights) reportedElsewhere(source, instance string) bool {
+	for s, instances := range w.sources {
+		if s != source && instances[instance] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// set assigns a clamped weight to an instance.  This method must be called under the write lock.
+func (w *Weights) set(instance string, weight int) {
+	weight = ClampWeight(weight)
+	current, ok := w.weights[instance]
+	if weight == DefaultWeight {
+		if ok {
+			delete(w.weights, instance)
+			w.version++
+		}
+
+		return
+	}
+
+	if !ok || current != weight {
+		w.weights[instance] = weight
+		w.version++
+	}
+}
+
+// Get returns the weight of an instance
+func (w *Weights) Get(instance string) int {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if weight, ok := w.weights[instance]; ok {
+		return weight
+	}
+
+	return DefaultWeight
+}
+
+// Version returns a value that changes each time any weight changes
+func (w *Weights) Version() uint64 {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.version
+}
+
+// weightedVnode is a single point on a weighted hash ring
+type weightedVnode struct {
+	token    uint64
+	instance string
+}
+
+// weightedRing is an immutable consistent hash ring in which each instance has a number of vnodes
+// proportional to its weight
+type weightedRing []weightedVnode
+
+// newWeightedRing builds a ring.  The tokens for an instance are computed exactly as the default consistent
+// hashing Accessor computes them, and a weighted instance takes the first tokens in that sequence.  As an
+// instance's weight increases, it only gains vnodes, so keys only move onto the instance as it ramps up.
+func newWeightedRing(vnodeCount int, instances []string, weights *Weights) weightedRing {
+	var ring weightedRing
+	for _, instance := range instances {
+		count := vnodeCount
+		if weights != nil {
+			if weight := weights.Get(instance); weight < DefaultWeight {
+				count = vnodeCount * weight / DefaultWeight
+				if count < 1 && weight > MinWeight {
+					count = 1
+				}
+			}
+		}
+
+		for i := 0; i < count; i++ {
+			ring = append(ring, weightedVnode{
+				token:    murmur3.Sum64([]byte(strconv.Itoa(i) + "=" + instance)),
+				instance: instance,
+			})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool { return ring[i].token < ring[j].token })
+	return ring
+}
+
+func (wr weightedRing) Get(key []byte) (string, error) {
+	if len(wr) == 0 {
+		return "", errNoInstances
+	}
+
+	token := murmur3.Sum64(key)
+	index := sort.Search(len(wr), func(i int) bool { return wr[i].token >= token })
+	if index == len(wr) {
+		index = 0
+	}
+
+	return wr[index].instance, nil
+}
+
+// weightedAccessor is an Accessor which rebuilds its ring whenever the shared Weights change
+type weightedAccessor struct {
+	vnodeCount int
+	instances  []string
+	weights    *Weights
+
+	lock    sync.RWMutex
+	version uint64
+	ring    weightedRing
+}
+
+func (wa *weightedAccessor) Get(key []byte) (string, error) {
+	version := wa.weights.Version()
+
+	wa.lock.RLock()
+	ring, current := wa.ring, wa.version == version
+	wa.lock.RUnlock()
+
+	if !current {
+		ring = newWeightedRing(wa.vnodeCount, wa.instances, wa.weights)
+		wa.lock.Lock()
+		wa.ring, wa.version = ring, version
+		wa.lock.Unlock()
+	}
+
+	return ring.Get(key)
+}
+
+// NewWeightedAccessorFactory produces a factory of consistent hashing Accessors that honor instance weights.
+// Each instance is given a number of vnodes proportional to its weight, so that new instances can be ramped
+// gradually into the hash ring rather than receiving their full share of keys at once.  Fully weighted instances
+// hash exactly as they would with NewConsistentAccessorFactory.
+//
+// Weights are read from the given store, which a service discovery backend updates.  Created Accessors pick up
+// weight changes even if the set of instances does not change.  If weights is nil, the returned factory is the same
+// as NewConsistentAccessorFactory.  If vnodeCount is nonpositive, DefaultVnodeCount is used.
+func NewWeightedAccessorFactory(vnodeCount int, weights *Weights) AccessorFactory {
+	if weights == nil {
+		return NewConsistentAccessorFactory(vnodeCount)
+	}
+
+	if vnodeCount < 1 {
+		vnodeCount = DefaultVnodeCount
+	}
+
+	return func(instances []string) Accessor {
+		if len(instances) == 0 {
+			return emptyAccessor{}
+		}
+
+		return &weightedAccessor{
+			vnodeCount: vnodeCount,
+			instances:  append([]string{}, instances...),
+			weights:    weights,
+			// no store ever reaches this version, which forces a build on first use
+			version: ^uint64(0),
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClampWeight(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(MinWeight, ClampWeight(-1))
+	assert.Equal(MinWeight, ClampWeight(MinWeight))
+	assert.Equal(50, ClampWeight(50))
+	assert.Equal(DefaultWeight, ClampWeight(DefaultWeight))
+	assert.Equal(DefaultWeight, ClampWeight(DefaultWeight+1))
+}
+
+func TestWeights(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		weights = NewWeights()
+	)
+
+	assert.Equal(DefaultWeight, weights.Get("http://node1.webpa.net"))
+	assert.Zero(weights.Version())
+
+	weights.Set("http://node1.webpa.net", 25)
+	assert.Equal(25, weights.Get("http://node1.webpa.net"))
+	assert.Equal(uint64(1), weights.Version())
+
+	// setting the same weight is not a change
+	weights.Set("http://node1.webpa.net", 25)
+	assert.Equal(uint64(1), weights.Version())
+
+	weights.Set("http://node1.webpa.net", -10)
+	assert.Equal(MinWeight, weights.Get("http://node1.webpa.net"))
+	assert.Equal(uint64(2), weights.Version())
+
+	weights.Set("http://node1.webpa.net", 1000)
+	assert.Equal(DefaultWeight, weights.Get("http://node1.webpa.net"))
+	assert.Equal(uint64(3), weights.Version())
+
+	weights.Set("http://node2.webpa.net", DefaultWeight)
+	assert.Equal(uint64(3), weights.Version())
+}
+
+func TestWeightsUpdate(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		weights = NewWeights()
+	)
+
+	weights.Update("first", map[string]int{"http://node1.webpa.net": 25, "http://node2.webpa.net": 50, "http://node3.webpa.net": DefaultWeight})
+	weights.Update("second", map[string]int{"http://node2.webpa.net": 50})
+	assert.Equal(25, weights.Get("http://node1.webpa.net"))
+	assert.Equal(50, weights.Get("http://node2.webpa.net"))
+	assert.Equal(DefaultWeight, weights.Get("http://node3.webpa.net"))
+	version := weights.Version()
+
+	// instances that leave a source are pruned, unless another source still reports them
+	weights.Update("first", map[string]int{"http://node3.webpa.net": 10})
+	assert.Equal(DefaultWeight, weights.Get("http://node1.webpa.net"))
+	assert.Equal(50, weights.Get("http://node2.webpa.net"))
+	assert.Equal(10, weights.Get("http://node3.webpa.net"))
+	assert.True(weights.Version() > version)
+
+	weights.Update("second", nil)
+	assert.Equal(DefaultWeight, weights.Get("http://node2.webpa.net"))
+
+	weights.Update("first", nil)
+	assert.Equal(DefaultWeight, weights.Get("http://node3.webpa.net"))
+	assert.Empty(weights.weights)
+	assert.Empty(weights.sources)
+}
+
+// weightedKeys generates keys for distribution tests
+func weightedKeys(count int) [][]byte {
+	keys := make([][]byte, count)
+	for i := 0; i < count; i++ {
+		keys[i] = []byte(fmt.Sprintf("mac:%012x", i))
+	}
+
+	return keys
+}
+
+func testNewWeightedAccessorFactoryNoWeights(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		instances = []string{"http://node1.webpa.net", "http://node2.webpa.net", "http://node3.webpa.net"}
+
+		expected = DefaultAccessorFactory(instances)
+		actual   = NewWeightedAccessorFactory(0, nil)(instances)
+		weighted = NewWeightedAccessorFactory(0, NewWeights())(instances)
+	)
+
+	// fully weighted instances hash exactly as the default accessor does
+	for _, key := range weightedKeys(1000) {
+		expectedInstance, err := expected.Get(key)
+		require.NoError(err)
+
+		actualInstance, err := actual.Get(key)
+		require.NoError(err)
+		assert.Equal(expectedInstance, actualInstance)
+
+		weightedInstance, err := weighted.Get(key)
+		require.NoError(err)
+		assert.Equal(expectedInstance, weightedInstance)
+	}
+}
+
+func testNewWeightedAccessorFactoryEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	accessor := NewWeightedAccessorFactory(0, NewWeights())(nil)
+	_, err := accessor.Get([]byte("test"))
+	assert.Equal(errNoInstances, err)
+}
+
+func testNewWeightedAccessorFactoryRamp(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		weights   = NewWeights()
+		instances = []string{"http://node1.webpa.net", "http://node2.webpa.net", "http://new.webpa.net"}
+		accessor  = NewWeightedAccessorFactory(100, weights)(instances)
+		keys      = weightedKeys(3000)
+	)
+
+	distribution := func() map[string]string {
+		assignments := make(map[string]string, len(keys))
+		for _, key := range keys {
+			instance, err := accessor.Get(key)
+			require.NoError(err)
+			assignments[string(key)] = instance
+		}
+
+		return assignments
+	}
+
+	count := func(assignments map[string]string, instance string) (total int) {
+		for _, i := range assignments {
+			if i == instance {
+				total++
+			}
+		}
+
+		return
+	}
+
+	weights.Set("http://new.webpa.net", MinWeight)
+	none := distribution()
+	assert.Zero(count(none, "http://new.webpa.net"))
+
+	// the accessor picks up weight changes without being recreated
+	weights.Set("http://new.webpa.net", 25)
+	partial := distribution()
+	partialCount := count(partial, "http://new.webpa.net")
+	assert.True(partialCount > 0 && partialCount < len(keys)/5, "partial count: %d", partialCount)
+
+	weights.Set("http://new.webpa.net", DefaultWeight)
+	full := distribution()
+	assert.True(count(full, "http://new.webpa.net") > partialCount)
+
+	// as the instance ramps up, keys only move onto it
+	for key, instance := range partial {
+		if instance == "http://new.webpa.net" {
+			assert.Equal("http://new.webpa.net", full[key])
+		} else if full[key] != "http://new.webpa.net" {
+			assert.Equal(instance, full[key])
+		}
+
+		if none[key] != instance {
+			assert.Equal("http://new.webpa.net", instance)
+		}
+	}
+
+	// an instance with any positive weight has at least one vnode
+	weights.Set("http://new.webpa.net", 1)
+	weights.Set("http://node1.webpa.net", MinWeight)
+	weights.Set("http://node2.webpa.net", MinWeight)
+	instance, err := accessor.Get([]byte("test"))
+	require.NoError(err)
+	assert.Equal("http://new.webpa.net", instance)
+
+	weights.Set("http://new.webpa.net", MinWeight)
+	_, err = accessor.Get([]byte("test"))
+	assert.Equal(errNoInstances, err)
+}
+
+func TestNewWeightedAccessorFactory(t *testing.T) {
+	t.Run("NoWeights", testNewWeightedAccessorFactoryNoWeights)
+	t.Run("Empty", testNewWeightedAccessorFactoryEmpty)
+	t.Run("Ramp", testNewWeightedAccessorFactoryRamp)
+}
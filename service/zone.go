@@ -0,0 +1,160 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// DefaultZoneHostLabel is the DNS label of an instance's hostname that names its zone when no label is configured
+	DefaultZoneHostLabel = 1
+
+	// DefaultMinLocalInstances is the local capacity threshold used when no threshold is configured
+	DefaultMinLocalInstances = 1
+)
+
+// ZoneOptions configures zone-aware instance selection.  This type is typically unmarshalled from configuration.
+type ZoneOptions struct {
+	// Local is the zone in which the calling process runs.  If unset, zone awareness is disabled.
+	Local string `json:"local,omitempty"`
+
+	// HostLabel is the zero-based index of the DNS label in an instance's hostname that names the instance's zone.
+	// For example, a HostLabel of 1 places "https://node1.east.webpa.net:8080" into the zone "east".  If unset,
+	// DefaultZoneHostLabel is used.  This is a pointer so that the first label, 0, can be configured.
+	HostLabel *int `json:"hostLabel,omitempty"`
+
+	// MinLocalInstances is the local capacity threshold.  When fewer instances than this are available in the
+	// local zone, instances in all zones are used.  If unset, DefaultMinLocalInstances is used.
+	MinLocalInstances int `json:"minLocalInstances,omitempty"`
+
+	// ZoneOf is an optional, custom strategy for determining the zone of an instance.  If set, HostLabel is ignored.
+	ZoneOf func(string) string `json:"-"`
+}
+
+func (o *ZoneOptions) local() string {
+	if o != nil {
+		return o.Local
+	}
+
+	return ""
+}
+
+func (o *ZoneOptions) hostLabel() int {
+	if o != nil && o.HostLabel != nil {
+		return *o.HostLabel
+	}
+
+	return DefaultZoneHostLabel
+}
+
+func (o *ZoneOptions) minLocalInstances() int {
+	if o != nil && o.MinLocalInstances > 0 {
+		return o.MinLocalInstances
+	}
+
+	return DefaultMinLocalInstances
+}
+
+func (o *ZoneOptions) zoneOf() func(string) string {
+	if o != nil && o.ZoneOf != nil {
+		return o.ZoneOf
+	}
+
+	return HostLabelZone(o.hostLabel())
+}
+
+// HostLabelZone produces a strategy that uses the given DNS label of an instance's hostname as the zone.
+// Instances that cannot be parsed or have too few labels are in the empty zone.
+func HostLabelZone(label int) func(string) string {
+	return func(instance string) string {
+		u, err := url.Parse(instance)
+		if err != nil {
+			return ""
+		}
+
+		labels := strings.Split(u.Hostname(), ".")
+		if label < 0 || label >= len(labels) {
+			return ""
+		}
+
+		return labels[label]
+	}
+}
+
+// zoneAccessor is an Accessor that measures the selection of instances outside the local zone
+type zoneAccessor struct {
+	accessor  Accessor
+	local     string
+	zones     map[string]string
+	crossZone metrics.Counter
+}
+
+func (za *zoneAccessor) Get(key []byte) (string, error) {
+	instance, err := za.accessor.Get(key)
+	if err == nil {
+		if zone := za.zones[instance]; zone != za.local {
+			za.crossZone.With(ZoneLabel, zone).Add(1.0)
+		}
+	}
+
+	return instance, err
+}
+
+// NewZoneAccessorFactory decorates an AccessorFactory so that the created Accessors prefer instances in the
+// local zone.  Accessors hash only over local instances as long as there are at least MinLocalInstances of them.
+// Otherwise, accessors spill over and hash across instances in all zones.
+//
+// Each Get that selects an instance outside the local zone increments the crossZone counter, labeled with
+// the selected instance's zone.  The crossZone counter is typically created from the CrossZoneCount metric.
+// If crossZone is nil, cross-zone routing is not measured and the created Accessors are not decorated.
+//
+// The zone of each instance is determined once, when an Accessor is created, rather than on each Get.
+//
+// If no local zone is configured, next is returned undecorated.  If next is nil, DefaultAccessorFactory is used.
+func NewZoneAccessorFactory(o *ZoneOptions, crossZone metrics.Counter, next AccessorFactory) AccessorFactory {
+	if next == nil {
+		next = DefaultAccessorFactory
+	}
+
+	local := o.local()
+	if len(local) == 0 {
+		return next
+	}
+
+	var (
+		zoneOf            = o.zoneOf()
+		minLocalInstances = o.minLocalInstances()
+	)
+
+	return func(instances []string) Accessor {
+		var (
+			zones          = make(map[string]string, len(instances))
+			localInstances []string
+		)
+
+		for _, i := range instances {
+			zone := zoneOf(i)
+			zones[i] = zone
+			if zone == local {
+				localInstances = append(localInstances, i)
+			}
+		}
+
+		if len(localInstances) >= minLocalInstances {
+			instances = localInstances
+		}
+
+		if crossZone == nil {
+			return next(instances)
+		}
+
+		return &zoneAccessor{
+			accessor:  next(instances),
+			local:     local,
+			zones:     zones,
+			crossZone: crossZone,
+		}
+	}
+}
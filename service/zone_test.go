@@ -0,0 +1,233 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testZoneOptionsDefault(t *testing.T, o *ZoneOptions) {
+	assert := assert.New(t)
+
+	assert.Empty(o.local())
+	assert.Equal(DefaultZoneHostLabel, o.hostLabel())
+	assert.Equal(DefaultMinLocalInstances, o.minLocalInstances())
+	assert.Equal("east", o.zoneOf()("https://node1.east.webpa.net:8080"))
+}
+
+func testZoneOptionsCustom(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		hostLabel = 2
+		o         = ZoneOptions{
+			Local:             "west",
+			HostLabel:         &hostLabel,
+			MinLocalInstances: 3,
+		}
+	)
+
+	assert.Equal("west", o.local())
+	assert.Equal(2, o.hostLabel())
+	assert.Equal(3, o.minLocalInstances())
+	assert.Equal("webpa", o.zoneOf()("https://node1.east.webpa.net:8080"))
+
+	hostLabel = 0
+	assert.Equal(0, o.hostLabel())
+	assert.Equal("node1", o.zoneOf()("https://node1.east.webpa.net:8080"))
+
+	o.ZoneOf = func(string) string { return "custom" }
+	assert.Equal("custom", o.zoneOf()("https://node1.east.webpa.net:8080"))
+}
+
+func TestZoneOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testZoneOptionsDefault(t, nil)
+		testZoneOptionsDefault(t, new(ZoneOptions))
+	})
+
+	t.Run("Custom", testZoneOptionsCustom)
+}
+
+func TestHostLabelZone(t *testing.T) {
+	testData := []struct {
+		label    int
+		instance string
+		expected string
+	}{
+		{0, "https://node1.east.webpa.net", "node1"},
+		{1, "https://node1.east.webpa.net", "east"},
+		{1, "http://node1.east.webpa.net:8080", "east"},
+		{1, "https://localhost:8080", ""},
+		{5, "https://node1.east.webpa.net", ""},
+		{-1, "https://node1.east.webpa.net", ""},
+		{1, "%%%", ""},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, record.expected, HostLabelZone(record.label)(record.instance))
+		})
+	}
+}
+
+func testNewZoneAccessorFactoryDisabled(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	af := NewZoneAccessorFactory(nil, nil, nil)
+	require.NotNil(af)
+
+	a := af([]string{"https://node1.east.webpa.net"})
+	_, ok := a.(*zoneAccessor)
+	assert.False(ok)
+
+	instance, err := a.Get([]byte("key"))
+	assert.NoError(err)
+	assert.Equal("https://node1.east.webpa.net", instance)
+}
+
+func testNewZoneAccessorFactoryLocal(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		p         = xmetricstest.NewProvider(nil, Metrics)
+		crossZone = p.NewCounter(CrossZoneCount)
+
+		af = NewZoneAccessorFactory(
+			&ZoneOptions{Local: "west", MinLocalInstances: 2},
+			crossZone,
+			nil,
+		)
+	)
+
+	require.NotNil(af)
+	a := af([]string{
+		"https://node1.east.webpa.net",
+		"https://node2.west.webpa.net",
+		"https://node3.west.webpa.net",
+		"https://node4.east.webpa.net",
+	})
+
+	for i := 0; i < 100; i++ {
+		instance, err := a.Get([]byte(strconv.Itoa(i)))
+		assert.NoError(err)
+		assert.Contains([]string{"https://node2.west.webpa.net", "https://node3.west.webpa.net"}, instance)
+	}
+
+	p.Assert(t, CrossZoneCount, ZoneLabel, "east")(xmetricstest.Value(0.0))
+}
+
+func testNewZoneAccessorFactorySpillover(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		p         = xmetricstest.NewProvider(nil, Metrics)
+		crossZone = p.NewCounter(CrossZoneCount)
+
+		af = NewZoneAccessorFactory(
+			&ZoneOptions{Local: "west", MinLocalInstances: 2},
+			crossZone,
+			nil,
+		)
+
+		instances = []string{
+			"https://node1.east.webpa.net",
+			"https://node2.west.webpa.net",
+			"https://node3.east.webpa.net",
+		}
+	)
+
+	require.NotNil(af)
+	a := af(instances)
+
+	remote := 0
+	for i := 0; i < 100; i++ {
+		instance, err := a.Get([]byte(strconv.Itoa(i)))
+		assert.NoError(err)
+		assert.Contains(instances, instance)
+		if instance != "https://node2.west.webpa.net" {
+			remote++
+		}
+	}
+
+	assert.True(remote > 0)
+	p.Assert(t, CrossZoneCount, ZoneLabel, "east")(xmetricstest.Value(float64(remote)))
+}
+
+func testNewZoneAccessorFactoryEmpty(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		af     = NewZoneAccessorFactory(&ZoneOptions{Local: "west"}, nil, nil)
+	)
+
+	instance, err := af(nil).Get([]byte("key"))
+	assert.Empty(instance)
+	assert.Error(err)
+}
+
+func testNewZoneAccessorFactoryZoneOnce(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		p         = xmetricstest.NewProvider(nil, Metrics)
+		crossZone = p.NewCounter(CrossZoneCount)
+		calls     = 0
+
+		af = NewZoneAccessorFactory(
+			&ZoneOptions{
+				Local: "west",
+				ZoneOf: func(instance string) string {
+					calls++
+					return HostLabelZone(1)(instance)
+				},
+			},
+			crossZone,
+			nil,
+		)
+	)
+
+	require.NotNil(af)
+	a := af([]string{"https://node1.east.webpa.net"})
+	assert.Equal(1, calls)
+
+	for i := 0; i < 10; i++ {
+		instance, err := a.Get([]byte(strconv.Itoa(i)))
+		assert.NoError(err)
+		assert.Equal("https://node1.east.webpa.net", instance)
+	}
+
+	assert.Equal(1, calls)
+	p.Assert(t, CrossZoneCount, ZoneLabel, "east")(xmetricstest.Value(10.0))
+}
+
+func testNewZoneAccessorFactoryNoCounter(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		af     = NewZoneAccessorFactory(&ZoneOptions{Local: "west"}, nil, nil)
+	)
+
+	a := af([]string{"https://node1.east.webpa.net", "https://node2.west.webpa.net"})
+	_, ok := a.(*zoneAccessor)
+	assert.False(ok)
+
+	instance, err := a.Get([]byte("key"))
+	assert.NoError(err)
+	assert.Equal("https://node2.west.webpa.net", instance)
+}
+
+func TestNewZoneAccessorFactory(t *testing.T) {
+	t.Run("Disabled", testNewZoneAccessorFactoryDisabled)
+	t.Run("Local", testNewZoneAccessorFactoryLocal)
+	t.Run("Spillover", testNewZoneAccessorFactorySpillover)
+	t.Run("Empty", testNewZoneAccessorFactoryEmpty)
+	t.Run("ZoneOnce", testNewZoneAccessorFactoryZoneOnce)
+	t.Run("NoCounter", testNewZoneAccessorFactoryNoCounter)
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ConsulKV is the subset of the Consul KV API used by a consul-backed KV.  *api.KV implements this interface.
+type ConsulKV interface {
+	Get(key string, q *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error)
+	Put(p *api.KVPair, q *api.WriteOptions) (*api.WriteMeta, error)
+	Delete(key string, w *api.WriteOptions) (*api.WriteMeta, error)
+	DeleteCAS(p *api.KVPair, q *api.WriteOptions) (bool, *api.WriteMeta, error)
+	List(prefix string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error)
+}
+
+// ConsulKVOptions configures a consul-backed KV
+type ConsulKVOptions struct {
+	// Prefix is prepended to every key.  A trailing "/" is added if necessary.  If unset, keys are stored
+	// at the root of the Consul KV store.
+	Prefix string
+
+	// Now is the source of the current time.  If unset, time.Now is used.
+	Now func() time.Time
+}
+
+// consulKV is a KV backed by Consul.  Consul has no per-key expiration, so each entry's expiration is
+// stored in the pair's flags as nanoseconds since the epoch.  Expired pairs are treated as missing and are
+// removed as they are encountered.  Use NewReaper to remove expired pairs that are never read again.
+type consulKV struct {
+	kv     ConsulKV
+	prefix string
+	now    func() time.Time
+}
+
+// NewConsulKV produces a KV backed by Consul.  If kv is nil, this function panics.
+func NewConsulKV(kv ConsulKV, o ConsulKVOptions) KV {
+	if kv == nil {
+		panic("A Consul KV is required")
+	}
+
+	c := &consulKV{
+		kv:     kv,
+		prefix: o.Prefix,
+		now:    o.Now,
+	}
+
+	if len(c.prefix) > 0 && !strings.HasSuffix(c.prefix, "/") {
+		c.prefix += "/"
+	}
+
+	if c.now == nil {
+		c.now = time.Now
+	}
+
+	return c
+}
+
+// pairExpires returns the expiration time stored in a pair's flags
+func pairExpires(p *api.KVPair) time.Time {
+	if p.Flags == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(p.Flags))
+}
+
+// removeExpired deletes an expired pair, ignoring any error since the pair is treated as missing regardless.
+// A check-and-set delete is used so that a concurrent Put of the same key is not lost.
+func (c *consulKV) removeExpired(p *api.KVPair) {
+	c.kv.DeleteCAS(p, nil)
+}
+
+func (c *consulKV) Get(key string) ([]byte, error) {
+	pair, _, err := c.kv.Get(c.prefix+key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if pair == nil {
+		return nil, ErrNotFound
+	}
+
+	if expired(pairExpires(pair), c.now()) {
+		c.removeExpired(pair)
+		return nil, ErrNotFound
+	}
+
+	return pair.Value, nil
+}
+
+func (c *consulKV) Put(key string, value []byte, ttl time.Duration) error {
+	pair := &api.KVPair{
+		Key:   c.prefix + key,
+		Value: value,
+	}
+
+	if expires := expiry(c.now(), ttl); !expires.IsZero() {
+		pair.Flags = uint64(expires.UnixNano())
+	}
+
+	_, err := c.kv.Put(pair, nil)
+	return err
+}
+
+func (c *consulKV) Delete(key string) error {
+	_, err := c.kv.Delete(c.prefix+key, nil)
+	return err
+}
+
+func (c *consulKV) List(prefix string) ([]Entry, error) {
+	pairs, _, err := c.kv.List(c.prefix+prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		now     = c.now()
+		entries []Entry
+	)
+
+	for _, pair := range pairs {
+		expires := pairExpires(pair)
+		if expired(expires, now) {
+			c.removeExpired(pair)
+			continue
+		}
+
+		entries = append(entries, Entry{
+			Key:     strings.TrimPrefix(pair.Key, c.prefix),
+			Value:   pair.Value,
+			Expires: expires,
+		})
+	}
+
+	// consul returns pairs in key order, so no sorting is necessary
+	return entries, nil
+}
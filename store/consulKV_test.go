@@ -0,0 +1,192 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConsulKV(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { NewConsulKV(nil, ConsulKVOptions{}) })
+
+	kv := NewConsulKV(new(mockConsulKV), ConsulKVOptions{Prefix: "dedup"})
+	assert.Equal("dedup/", kv.(*consulKV).prefix)
+
+	kv = NewConsulKV(new(mockConsulKV), ConsulKVOptions{Prefix: "dedup/"})
+	assert.Equal("dedup/", kv.(*consulKV).prefix)
+
+	kv = NewConsulKV(new(mockConsulKV), ConsulKVOptions{})
+	assert.Empty(kv.(*consulKV).prefix)
+	assert.NotNil(kv.(*consulKV).now)
+}
+
+func TestConsulKVGet(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		now      = time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+		consul   = new(mockConsulKV)
+		kv       = NewConsulKV(consul, ConsulKVOptions{Prefix: "test", Now: func() time.Time { return now }})
+		expected = errors.New("expected")
+
+		current = &api.KVPair{Key: "test/current", Value: []byte("current"), Flags: uint64(now.Add(time.Second).UnixNano())}
+		forever = &api.KVPair{Key: "test/forever", Value: []byte("forever")}
+		stale   = &api.KVPair{Key: "test/stale", Value: []byte("stale"), Flags: uint64(now.UnixNano())}
+	)
+
+	consul.On("Get", "test/current", (*api.QueryOptions)(nil)).Return(current, new(api.QueryMeta), error(nil)).Once()
+	consul.On("Get", "test/forever", (*api.QueryOptions)(nil)).Return(forever, new(api.QueryMeta), error(nil)).Once()
+	consul.On("Get", "test/stale", (*api.QueryOptions)(nil)).Return(stale, new(api.QueryMeta), error(nil)).Once()
+	consul.On("DeleteCAS", stale, (*api.WriteOptions)(nil)).Return(true, new(api.WriteMeta), error(nil)).Once()
+	consul.On("Get", "test/missing", (*api.QueryOptions)(nil)).Return(nil, new(api.QueryMeta), error(nil)).Once()
+	consul.On("Get", "test/error", (*api.QueryOptions)(nil)).Return(nil, nil, expected).Once()
+
+	value, err := kv.Get("current")
+	assert.Equal([]byte("current"), value)
+	assert.NoError(err)
+
+	value, err = kv.Get("forever")
+	assert.Equal([]byte("forever"), value)
+	assert.NoError(err)
+
+	value, err = kv.Get("stale")
+	assert.Nil(value)
+	assert.Equal(ErrNotFound, err)
+
+	value, err = kv.Get("missing")
+	assert.Nil(value)
+	assert.Equal(ErrNotFound, err)
+
+	value, err = kv.Get("error")
+	assert.Nil(value)
+	assert.Equal(expected, err)
+
+	consul.AssertExpectations(t)
+}
+
+func TestConsulKVPut(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		now      = time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+		consul   = new(mockConsulKV)
+		kv       = NewConsulKV(consul, ConsulKVOptions{Now: func() time.Time { return now }})
+		expected = errors.New("expected")
+	)
+
+	consul.On("Put", &api.KVPair{Key: "ttl", Value: []byte("1"), Flags: uint64(now.Add(time.Hour).UnixNano())}, (*api.WriteOptions)(nil)).
+		Return(new(api.WriteMeta), error(nil)).Once()
+	consul.On("Put", &api.KVPair{Key: "forever", Value: []byte("2")}, (*api.WriteOptions)(nil)).
+		Return(new(api.WriteMeta), error(nil)).Once()
+	consul.On("Put", &api.KVPair{Key: "error", Value: []byte("3")}, (*api.WriteOptions)(nil)).
+		Return(nil, expected).Once()
+
+	assert.NoError(kv.Put("ttl", []byte("1"), time.Hour))
+	assert.NoError(kv.Put("forever", []byte("2"), 0))
+	assert.Equal(expected, kv.Put("error", []byte("3"), -1))
+
+	consul.AssertExpectations(t)
+}
+
+func TestConsulKVDelete(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		consul   = new(mockConsulKV)
+		kv       = NewConsulKV(consul, ConsulKVOptions{Prefix: "test"})
+		expected = errors.New("expected")
+	)
+
+	consul.On("Delete", "test/key", (*api.WriteOptions)(nil)).Return(new(api.WriteMeta), error(nil)).Once()
+	consul.On("Delete", "test/error", (*api.WriteOptions)(nil)).Return(nil, expected).Once()
+
+	assert.NoError(kv.Delete("key"))
+	assert.Equal(expected, kv.Delete("error"))
+
+	consul.AssertExpectations(t)
+}
+
+func TestConsulKVList(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		now      = time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+		consul   = new(mockConsulKV)
+		kv       = NewConsulKV(consul, ConsulKVOptions{Prefix: "test", Now: func() time.Time { return now }})
+		expected = errors.New("expected")
+
+		stale = &api.KVPair{Key: "test/a/stale", Value: []byte("stale"), Flags: uint64(now.Add(-time.Second).UnixNano())}
+	)
+
+	consul.On("List", "test/a/", (*api.QueryOptions)(nil)).Return(
+		api.KVPairs{
+			{Key: "test/a/current", Value: []byte("current"), Flags: uint64(now.Add(time.Minute).UnixNano())},
+			{Key: "test/a/forever", Value: []byte("forever")},
+			stale,
+		},
+		new(api.QueryMeta),
+		error(nil),
+	).Once()
+
+	consul.On("DeleteCAS", stale, (*api.WriteOptions)(nil)).Return(false, nil, expected).Once()
+	consul.On("List", "test/error", (*api.QueryOptions)(nil)).Return(nil, nil, expected).Once()
+
+	entries, err := kv.List("a/")
+	require.NoError(err)
+	require.Len(entries, 2)
+	assert.Equal("a/current", entries[0].Key)
+	assert.Equal([]byte("current"), entries[0].Value)
+	assert.True(now.Add(time.Minute).Equal(entries[0].Expires))
+	assert.Equal(Entry{Key: "a/forever", Value: []byte("forever")}, entries[1])
+
+	entries, err = kv.List("error")
+	assert.Empty(entries)
+	assert.Equal(expected, err)
+
+	consul.AssertExpectations(t)
+}
+
+func TestConsulKVReaper(t *testing.T) {
+	var (
+		now      = time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+		consul   = new(mockConsulKV)
+		kv       = NewConsulKV(consul, ConsulKVOptions{Prefix: "test", Now: func() time.Time { return now }})
+		expected = errors.New("expected")
+		reaped   = make(chan struct{}, 2)
+
+		abandoned = &api.KVPair{Key: "test/abandoned", Value: []byte("abandoned"), Flags: uint64(now.Add(-time.Hour).UnixNano())}
+		after     = make(chan time.Time)
+
+		reaper = NewReaper(kv, concurrent.ScheduleOptions{
+			Logger:     logging.NewTestLogger(nil, t),
+			RunOnStart: true,
+			After:      func(time.Duration) <-chan time.Time { return after },
+		})
+
+		waitGroup = new(sync.WaitGroup)
+		shutdown  = make(chan struct{})
+	)
+
+	consul.On("List", "test/", (*api.QueryOptions)(nil)).Return(api.KVPairs{abandoned}, new(api.QueryMeta), error(nil)).Once()
+	consul.On("DeleteCAS", abandoned, (*api.WriteOptions)(nil)).Return(true, nil, nil).Once().
+		Run(func(mock.Arguments) { reaped <- struct{}{} })
+
+	// a failed reap is logged, and reaping continues on the next period
+	consul.On("List", "test/", (*api.QueryOptions)(nil)).Return(nil, nil, expected).Once().
+		Run(func(mock.Arguments) { reaped <- struct{}{} })
+
+	reaper.Run(waitGroup, shutdown)
+	<-reaped
+	after <- now
+	<-reaped
+
+	close(shutdown)
+	waitGroup.Wait()
+	consul.AssertExpectations(t)
+}
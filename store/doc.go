@@ -1,5 +1,8 @@
 /*
 Package store implements some additional atomic value storage on top of sync/atomic.
 In particular, this means transparent caching of arbitrary values.
+
+This package also provides KV, a small key-value store whose entries can expire, with
+in-memory and Consul implementations.
 */
 package store
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// DefaultReapInterval is how often a reaper removes expired entries when no period is configured
+	DefaultReapInterval = 5 * time.Minute
+)
+
+// ErrNotFound is returned by KV.Get when a key does not exist or has expired
+var ErrNotFound = errors.New("No such key")
+
+// Entry is a single key and value held in a KV
+type Entry struct {
+	Key   string
+	Value []byte
+
+	// Expires is when this entry expires.  The zero time means the entry never expires.
+	Expires time.Time
+}
+
+// KV is a key-value store whose entries can expire.  It is intended for small, shared state such as idempotency
+// caches, deduplication windows, and registrations.  Implementations are safe for concurrent use.
+type KV interface {
+	// Get returns the value of a key.  If the key does not exist or has expired, ErrNotFound is returned.
+	Get(key string) ([]byte, error)
+
+	// Put creates or replaces a key.  A nonpositive ttl means the key never expires.
+	Put(key string, value []byte, ttl time.Duration) error
+
+	// Delete removes a key.  Deleting a nonexistent key is not an error.
+	Delete(key string) error
+
+	// List returns the unexpired entries whose keys begin with the given prefix, sorted by key.
+	// An empty prefix lists every entry.
+	List(prefix string) ([]Entry, error)
+}
+
+// expiry computes the expiration time for a ttl relative to now, using the zero time for nonpositive ttls
+func expiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl > 0 {
+		return now.Add(ttl)
+	}
+
+	return time.Time{}
+}
+
+// expired tests if an expiration time has passed
+func expired(expires, now time.Time) bool {
+	return !expires.IsZero() && !now.Before(expires)
+}
+
+// NewReaper produces a concurrent.Scheduler that periodically removes the expired entries from a KV.  KV
+// implementations only remove expired entries as they are encountered, so entries that are never read again,
+// such as abandoned keys in Consul, would otherwise accumulate.  If o.Period is unset, DefaultReapInterval is used,
+// and if o.Name is unset, "store.reaper" is used.  The returned Scheduler must be Run to begin reaping.
+func NewReaper(kv KV, o concurrent.ScheduleOptions) *concurrent.Scheduler {
+	if o.Period < 1 {
+		o.Period = DefaultReapInterval
+	}
+
+	if len(o.Name) == 0 {
+		o.Name = "store.reaper"
+	}
+
+	if o.Logger == nil {
+		o.Logger = logging.DefaultLogger()
+	}
+
+	logger := o.Logger
+	return concurrent.NewScheduler(o, func() {
+		// listing every entry removes any that have expired
+		if _, err := kv.List(""); err != nil {
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to reap expired entries", logging.ErrorKey(), err)
+		}
+	})
+}
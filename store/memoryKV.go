@@ -0,0 +1,128 @@
+package store
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryKVOptions configures an in-memory KV
+type MemoryKVOptions struct {
+	// MaxEntries is the maximum number of entries held.  When a new key would exceed this limit, the least
+	// recently used entry is evicted.  If nonpositive, the number of entries is unbounded.
+	MaxEntries int
+
+	// Now is the source of the current time.  If unset, time.Now is used.
+	Now func() time.Time
+}
+
+// memoryKV is a KV held in process memory, with least recently used eviction
+type memoryKV struct {
+	maxEntries int
+	now        func() time.Time
+
+	lock    sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// NewMemoryKV produces a KV that holds entries in process memory.  Expired entries are removed as they are
+// encountered, or evicted when space is needed.
+func NewMemoryKV(o MemoryKVOptions) KV {
+	m := &memoryKV{
+		maxEntries: o.MaxEntries,
+		now:        o.Now,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+
+	if m.now == nil {
+		m.now = time.Now
+	}
+
+	return m
+}
+
+func (m *memoryKV) remove(e *list.Element) {
+	m.order.Remove(e)
+	delete(m.entries, e.Value.(*Entry).Key)
+}
+
+func (m *memoryKV) Get(key string) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	entry := e.Value.(*Entry)
+	if expired(entry.Expires, m.now()) {
+		m.remove(e)
+		return nil, ErrNotFound
+	}
+
+	m.order.MoveToFront(e)
+	return append([]byte{}, entry.Value...), nil
+}
+
+func (m *memoryKV) Put(key string, value []byte, ttl time.Duration) error {
+	entry := &Entry{
+		Key:     key,
+		Value:   append([]byte{}, value...),
+		Expires: expiry(m.now(), ttl),
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		e.Value = entry
+		m.order.MoveToFront(e)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(entry)
+	if m.maxEntries > 0 && m.order.Len() > m.maxEntries {
+		m.remove(m.order.Back())
+	}
+
+	return nil
+}
+
+func (m *memoryKV) Delete(key string) error {
+	m.lock.Lock()
+	if e, ok := m.entries[key]; ok {
+		m.remove(e)
+	}
+
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *memoryKV) List(prefix string) ([]Entry, error) {
+	m.lock.Lock()
+	var (
+		now     = m.now()
+		entries []Entry
+	)
+
+	for key, e := range m.entries {
+		entry := e.Value.(*Entry)
+		if expired(entry.Expires, now) {
+			m.remove(e)
+			continue
+		}
+
+		if strings.HasPrefix(key, prefix) {
+			entries = append(entries, Entry{Key: key, Value: append([]byte{}, entry.Value...), Expires: entry.Expires})
+		}
+	}
+
+	m.lock.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
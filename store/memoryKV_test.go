@@ -0,0 +1,99 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryKV(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now = time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+		kv  = NewMemoryKV(MemoryKVOptions{Now: func() time.Time { return now }})
+	)
+
+	value, err := kv.Get("missing")
+	assert.Nil(value)
+	assert.Equal(ErrNotFound, err)
+
+	require.NoError(kv.Put("a/1", []byte("one"), time.Minute))
+	require.NoError(kv.Put("a/2", []byte("two"), 0))
+	require.NoError(kv.Put("b/1", []byte("three"), 2*time.Minute))
+
+	value, err = kv.Get("a/1")
+	assert.Equal([]byte("one"), value)
+	assert.NoError(err)
+
+	// returned values are copies
+	value[0] = 'X'
+	value, err = kv.Get("a/1")
+	assert.Equal([]byte("one"), value)
+	assert.NoError(err)
+
+	entries, err := kv.List("a/")
+	require.NoError(err)
+	assert.Equal(
+		[]Entry{
+			{Key: "a/1", Value: []byte("one"), Expires: now.Add(time.Minute)},
+			{Key: "a/2", Value: []byte("two")},
+		},
+		entries,
+	)
+
+	now = now.Add(time.Minute)
+	value, err = kv.Get("a/1")
+	assert.Nil(value)
+	assert.Equal(ErrNotFound, err)
+
+	entries, err = kv.List("")
+	require.NoError(err)
+	assert.Equal(
+		[]Entry{
+			{Key: "a/2", Value: []byte("two")},
+			{Key: "b/1", Value: []byte("three"), Expires: now.Add(time.Minute)},
+		},
+		entries,
+	)
+
+	require.NoError(kv.Put("a/2", []byte("replaced"), 0))
+	value, err = kv.Get("a/2")
+	assert.Equal([]byte("replaced"), value)
+	assert.NoError(err)
+
+	assert.NoError(kv.Delete("a/2"))
+	assert.NoError(kv.Delete("a/2"))
+	value, err = kv.Get("a/2")
+	assert.Nil(value)
+	assert.Equal(ErrNotFound, err)
+}
+
+func TestMemoryKVEviction(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		kv      = NewMemoryKV(MemoryKVOptions{MaxEntries: 2})
+	)
+
+	require.NoError(kv.Put("first", []byte("1"), 0))
+	require.NoError(kv.Put("second", []byte("2"), 0))
+
+	// using the first key makes the second key the least recently used
+	_, err := kv.Get("first")
+	require.NoError(err)
+
+	require.NoError(kv.Put("third", []byte("3"), time.Hour))
+
+	_, err = kv.Get("second")
+	assert.Equal(ErrNotFound, err)
+
+	entries, err := kv.List("")
+	require.NoError(err)
+	require.Len(entries, 2)
+	assert.Equal("first", entries[0].Key)
+	assert.Equal("third", entries[1].Key)
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockConsulKV struct {
+	mock.Mock
+}
+
+func (m *mockConsulKV) Get(key string, q *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error) {
+	var (
+		arguments = m.Called(key, q)
+		first, _  = arguments.Get(0).(*api.KVPair)
+		second, _ = arguments.Get(1).(*api.QueryMeta)
+	)
+
+	return first, second, arguments.Error(2)
+}
+
+func (m *mockConsulKV) Put(p *api.KVPair, q *api.WriteOptions) (*api.WriteMeta, error) {
+	arguments := m.Called(p, q)
+	first, _ := arguments.Get(0).(*api.WriteMeta)
+	return first, arguments.Error(1)
+}
+
+func (m *mockConsulKV) Delete(key string, w *api.WriteOptions) (*api.WriteMeta, error) {
+	arguments := m.Called(key, w)
+	first, _ := arguments.Get(0).(*api.WriteMeta)
+	return first, arguments.Error(1)
+}
+
+func (m *mockConsulKV) DeleteCAS(p *api.KVPair, q *api.WriteOptions) (bool, *api.WriteMeta, error) {
+	arguments := m.Called(p, q)
+	second, _ := arguments.Get(1).(*api.WriteMeta)
+	return arguments.Bool(0), second, arguments.Error(2)
+}
+
+func (m *mockConsulKV) List(prefix string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error) {
+	var (
+		arguments = m.Called(prefix, q)
+		first, _  = arguments.Get(0).(api.KVPairs)
+		second, _ = arguments.Get(1).(*api.QueryMeta)
+	)
+
+	return first, second, arguments.Error(2)
+}
@@ -59,3 +59,8 @@ func (se *spanError) WithSpans(spans ...Span) interface{} {
 func (se *spanError) Err() error {
 	return se.err
 }
+
+// Unwrap exposes the causal error, so that errors.Is and errors.As can match it
+func (se *spanError) Unwrap() error {
+	return se.err
+}
@@ -43,6 +43,7 @@ func TestSpanError(t *testing.T) {
 		t.Logf("%#v", record)
 
 		assert.Equal(record.expectedError, record.spanError.Err())
+		assert.Equal(record.expectedError, errors.Unwrap(record.spanError))
 		assert.Equal(record.expectedErrorString, record.spanError.Error())
 		assert.Equal(record.expectedSpans, record.spanError.Spans())
 
@@ -3,6 +3,7 @@ package aws
 import (
 	"github.com/Comcast/webpa-common/xmetrics"
 	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/provider"
 )
 
 const (
@@ -48,23 +49,27 @@ func Metrics() []xmetrics.Metric {
 }
 
 // ApplyMetricsData is used for setting the counter values on the AWSMetrics
-// when stored and accessing for later use
-func ApplyMetricsData(registry xmetrics.Registry) (m AWSMetrics) {
+// when stored and accessing for later use.  Any go-kit provider may be used, such as
+// an xmetrics.Registry.  If p is nil, the metrics are discarded.
+func ApplyMetricsData(p provider.Provider) (m AWSMetrics) {
+	if p == nil {
+		p = provider.NewDiscardProvider()
+	}
+
 	for _, metric := range Metrics() {
 		switch metric.Name {
 		case SNSNotificationReceived:
-			m.SNSNotificationReceived = registry.NewCounter(metric.Name)
+			m.SNSNotificationReceived = p.NewCounter(metric.Name)
 		case SNSNotificationSent:
-			m.SNSNotificationSent = registry.NewCounter(metric.Name)
+			m.SNSNotificationSent = p.NewCounter(metric.Name)
 			m.SNSNotificationSent.Add(0.0)
 		case SNSSubscribeAttempt:
-			m.SNSSubscribeAttempt = registry.NewCounter(metric.Name)
+			m.SNSSubscribeAttempt = p.NewCounter(metric.Name)
 		case SNSSubscribed:
-			m.SNSSubscribed = registry.NewGauge(metric.Name)
+			m.SNSSubscribed = p.NewGauge(metric.Name)
 			m.SNSSubscribed.Add(0.0)
 		}
 	}
-	
+
 	return
 }
-
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// DefaultConsulPrefix is the KV prefix under which webhook registrations are stored when no prefix is configured
+const DefaultConsulPrefix = "webhooks/"
+
+// ConsulKV is the subset of the Consul KV API used to store webhooks.  *api.KV implements this interface.
+type ConsulKV interface {
+	Put(p *api.KVPair, q *api.WriteOptions) (*api.WriteMeta, error)
+	Delete(key string, w *api.WriteOptions) (*api.WriteMeta, error)
+	List(prefix string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error)
+}
+
+// consulStore is a Store backed by the Consul KV store.  Each registration is stored as JSON
+// under the prefix, keyed by its escaped ID.
+type consulStore struct {
+	kv     ConsulKV
+	prefix string
+}
+
+// NewConsulStore returns a Store backed by Consul KV.  If prefix is empty, DefaultConsulPrefix is used.
+// If kv is nil, this function panics.
+func NewConsulStore(kv ConsulKV, prefix string) Store {
+	if kv == nil {
+		panic("A Consul KV is required")
+	}
+
+	if len(prefix) == 0 {
+		prefix = DefaultConsulPrefix
+	} else if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return &consulStore{
+		kv:     kv,
+		prefix: prefix,
+	}
+}
+
+func (cs *consulStore) key(id string) string {
+	return cs.prefix + url.QueryEscape(id)
+}
+
+func (cs *consulStore) Put(w W) error {
+	value, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+
+	_, err = cs.kv.Put(&api.KVPair{Key: cs.key(w.ID()), Value: value}, nil)
+	return err
+}
+
+func (cs *consulStore) Delete(id string) error {
+	_, err := cs.kv.Delete(cs.key(id), nil)
+	return err
+}
+
+func (cs *consulStore) List() ([]W, error) {
+	pairs, _, err := cs.kv.List(cs.prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := make([]W, 0, len(pairs))
+	for _, pair := range pairs {
+		var w W
+		if err := json.Unmarshal(pair.Value, &w); err != nil {
+			return nil, err
+		}
+
+		hooks = append(hooks, w)
+	}
+
+	return hooks, nil
+}
@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewConsulStoreNil(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() {
+		NewConsulStore(nil, "")
+	})
+}
+
+func testNewConsulStorePrefix(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(DefaultConsulPrefix, NewConsulStore(new(mockConsulKV), "").(*consulStore).prefix)
+	assert.Equal("custom/", NewConsulStore(new(mockConsulKV), "custom").(*consulStore).prefix)
+	assert.Equal("custom/", NewConsulStore(new(mockConsulKV), "custom/").(*consulStore).prefix)
+}
+
+func TestNewConsulStore(t *testing.T) {
+	t.Run("Nil", testNewConsulStoreNil)
+	t.Run("Prefix", testNewConsulStorePrefix)
+}
+
+func testConsulStorePut(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		kv      = new(mockConsulKV)
+		store   = NewConsulStore(kv, "")
+		w       = testStoreW("http://foo.com/bar?x=1", time.Now().Add(time.Hour))
+		actual  *api.KVPair
+	)
+
+	kv.On("Put", mock.AnythingOfType("*api.KVPair"), (*api.WriteOptions)(nil)).
+		Return(new(api.WriteMeta), nil).
+		Run(func(arguments mock.Arguments) { actual = arguments.Get(0).(*api.KVPair) }).
+		Once()
+
+	assert.NoError(store.Put(w))
+	require.NotNil(actual)
+	assert.Equal("webhooks/http%3A%2F%2Ffoo.com%2Fbar%3Fx%3D1", actual.Key)
+
+	var stored W
+	require.NoError(json.Unmarshal(actual.Value, &stored))
+	assert.Equal(w.ID(), stored.ID())
+	kv.AssertExpectations(t)
+}
+
+func testConsulStoreDelete(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		kv            = new(mockConsulKV)
+		store         = NewConsulStore(kv, "test")
+		expectedError = errors.New("expected")
+	)
+
+	kv.On("Delete", "test/http%3A%2F%2Ffoo.com", (*api.WriteOptions)(nil)).Return(new(api.WriteMeta), nil).Once()
+	kv.On("Delete", "test/http%3A%2F%2Fbar.com", (*api.WriteOptions)(nil)).Return(nil, expectedError).Once()
+
+	assert.NoError(store.Delete("http://foo.com"))
+	assert.Equal(expectedError, store.Delete("http://bar.com"))
+	kv.AssertExpectations(t)
+}
+
+func testConsulStoreList(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		kv      = new(mockConsulKV)
+		store   = NewConsulStore(kv, "")
+		w       = testStoreW("http://foo.com", time.Now().Add(time.Hour))
+	)
+
+	value, err := json.Marshal(w)
+	require.NoError(err)
+
+	kv.On("List", DefaultConsulPrefix, (*api.QueryOptions)(nil)).
+		Return(api.KVPairs{{Key: "webhooks/http%3A%2F%2Ffoo.com", Value: value}}, new(api.QueryMeta), nil).
+		Once()
+
+	hooks, err := store.List()
+	require.NoError(err)
+	require.Len(hooks, 1)
+	assert.Equal("http://foo.com", hooks[0].ID())
+	kv.AssertExpectations(t)
+}
+
+func testConsulStoreListError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		kv            = new(mockConsulKV)
+		store         = NewConsulStore(kv, "")
+		expectedError = errors.New("expected")
+	)
+
+	kv.On("List", DefaultConsulPrefix, (*api.QueryOptions)(nil)).Return(nil, nil, expectedError).Once()
+	hooks, err := store.List()
+	assert.Empty(hooks)
+	assert.Equal(expectedError, err)
+
+	kv.On("List", DefaultConsulPrefix, (*api.QueryOptions)(nil)).
+		Return(api.KVPairs{{Key: "webhooks/bad", Value: []byte("this is not JSON")}}, nil, nil).
+		Once()
+
+	hooks, err = store.List()
+	assert.Empty(hooks)
+	assert.Error(err)
+	kv.AssertExpectations(t)
+}
+
+func TestConsulStore(t *testing.T) {
+	t.Run("Put", testConsulStorePut)
+	t.Run("Delete", testConsulStoreDelete)
+	t.Run("List", testConsulStoreList)
+	t.Run("ListError", testConsulStoreListError)
+}
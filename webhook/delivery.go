@@ -0,0 +1,570 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	DefaultDeliveryWorkers        = 10
+	DefaultDeliveryQueueSize      = 100
+	DefaultDeliveryRetries        = 3
+	DefaultDeliveryInitialBackoff = time.Second
+	DefaultDeliveryMaxBackoff     = 30 * time.Second
+	DefaultDeliveryCutoffPeriod   = time.Minute
+	DefaultDeliveryTimeout        = 30 * time.Second
+	DefaultDeliveryIdleTimeout    = 5 * time.Minute
+
+	// deliveryDrainLimit bounds how much of a response body is read, so that connections can be reused
+	// without letting a receiver trickle an unbounded body into a worker
+	deliveryDrainLimit = 64 * 1024
+)
+
+var (
+	ErrDeliveryQueueFull = errors.New("The delivery queue for this webhook is full")
+	ErrDeliveryCutoff    = errors.New("Delivery to this webhook has been cut off due to sustained failures")
+	ErrDelivererStopped  = errors.New("The deliverer has been stopped")
+)
+
+// DeliveryError is the error reported for a delivery that completed with a non-2xx status code
+type DeliveryError struct {
+	StatusCode int
+}
+
+func (de DeliveryError) Error() string {
+	return fmt.Sprintf("Webhook responded with status code %d", de.StatusCode)
+}
+
+// Temporary indicates whether the status code is worth retrying
+func (de DeliveryError) Temporary() bool {
+	return de.StatusCode >= 500 || de.StatusCode == http.StatusTooManyRequests
+}
+
+// Message is a single outbound payload destined for a webhook
+type Message struct {
+	// ContentType is the content type of the body.  If unset, the hook's configured content type is used.
+	ContentType string
+
+	// Body is the payload delivered to the hook
+	Body []byte
+
+	// WRP indicates that Body is a WRP message encoded as described by ContentType.  WRP messages are
+	// transcoded into the hook's preferred event content type, if it has one.
+	WRP bool
+}
+
+// negotiate produces the message actually delivered to a hook.  If the message is WRP and the hook prefers
+// a different WRP format, the body is transcoded.  Otherwise, the message is returned unchanged.
+func negotiate(w W, m Message) (Message, error) {
+	if !m.WRP || len(w.Config.EventContentType) == 0 {
+		return m, nil
+	}
+
+	target, err := wrp.FormatFromContentType(w.Config.EventContentType)
+	if err != nil {
+		return m, err
+	}
+
+	source, err := wrp.FormatFromContentType(m.ContentType, wrp.Msgpack)
+	if err != nil {
+		return m, err
+	}
+
+	if source == target {
+		return m, nil
+	}
+
+	var body []byte
+	if _, err := wrp.TranscodeMessage(wrp.NewEncoderBytes(&body, target), wrp.NewDecoderBytes(m.Body, source)); err != nil {
+		return m, err
+	}
+
+	return Message{ContentType: target.ContentType(), Body: body, WRP: true}, nil
+}
+
+// DeadLetterFunc is invoked with a message that could not be delivered, along with the reason why
+type DeadLetterFunc func(W, Message, error)
+
+// DeliveryOptions configures a Deliverer
+type DeliveryOptions struct {
+	// Logger is the go-kit logger to use.  Defaults to logging.DefaultLogger() if unset.
+	Logger log.Logger
+
+	// Do is the HTTP transactor.  If unset, http.DefaultClient.Do is used.
+	Do func(*http.Request) (*http.Response, error)
+
+	// Timeout bounds each HTTP transaction, including reading the response.  If not positive,
+	// DefaultDeliveryTimeout is used.
+	Timeout time.Duration
+
+	// IdleTimeout is how long a hook's queue may sit empty before its state and goroutine are released.
+	// A hook that is cut off is retained until its cutoff period ends.  If not positive,
+	// DefaultDeliveryIdleTimeout is used.
+	IdleTimeout time.Duration
+
+	// Workers is the maximum number of concurrent HTTP transactions across all hooks.
+	// If not positive, DefaultDeliveryWorkers is used.
+	Workers int
+
+	// QueueSize is the maximum number of messages buffered for any one hook.
+	// If not positive, DefaultDeliveryQueueSize is used.
+	QueueSize int
+
+	// Retries is the number of times a failed delivery is retried.  If negative, no retries are performed.
+	// If zero, DefaultDeliveryRetries is used.
+	Retries int
+
+	// InitialBackoff is the wait before the first retry.  Each subsequent retry doubles the wait, up to MaxBackoff.
+	// If unset, DefaultDeliveryInitialBackoff is used.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between retries.  If unset, DefaultDeliveryMaxBackoff is used.
+	MaxBackoff time.Duration
+
+	// CutoffThreshold is the number of consecutive failed deliveries after which a hook is cut off.
+	// If not positive, hooks are never cut off.
+	CutoffThreshold int
+
+	// CutoffPeriod is how long a hook remains cut off.  If unset, DefaultDeliveryCutoffPeriod is used.
+	CutoffPeriod time.Duration
+
+	// DeadLetter receives every message that could not be delivered.  This function is optional.
+	DeadLetter DeadLetterFunc
+
+	// Sleep is used to wait out backoff durations.  If unset, time.Sleep is used.
+	Sleep func(time.Duration)
+
+	// Now is the source of the current time.  If unset, time.Now is used.
+	Now func() time.Time
+}
+
+// envelope is a queued message along with the hook as it was when the message was sent
+type envelope struct {
+	w W
+	m Message
+}
+
+// destination is the delivery state for a single hook
+type destination struct {
+	id          string
+	queue       chan envelope
+	failures    int
+	cutoffUntil time.Time
+}
+
+// failureNotification is the body POSTed to a hook's failure URL.  It mirrors the layout of W, but deliberately
+// omits the hook's secrets since the failure URL need not be the same party as the hook's receiver.
+type failureNotification struct {
+	Config struct {
+		URL              string `json:"url"`
+		ContentType      string `json:"content_type"`
+		EventContentType string `json:"event_content_type,omitempty"`
+	} `json:"config"`
+
+	FailureURL string   `json:"failure_url"`
+	Events     []string `json:"events"`
+
+	Matcher struct {
+		DeviceId []string `json:"device_id"`
+	} `json:"matcher,omitempty"`
+
+	Duration time.Duration `json:"duration"`
+	Until    time.Time     `json:"until"`
+}
+
+// newFailureNotification produces the redacted form of a hook sent to its failure URL
+func newFailureNotification(w W) failureNotification {
+	var fn failureNotification
+	fn.Config.URL = w.Config.URL
+	fn.Config.ContentType = w.Config.ContentType
+	fn.Config.EventContentType = w.Config.EventContentType
+	fn.FailureURL = w.FailureURL
+	fn.Events = w.Events
+	fn.Matcher.DeviceId = w.Matcher.DeviceId
+	fn.Duration = w.Duration
+	fn.Until = w.Until
+	return fn
+}
+
+// Deliverer sends messages to webhooks using a bounded pool of workers.  Each hook has its own queue, so that
+// a slow or failing hook does not starve the others.  Failed deliveries are retried with exponential backoff,
+// and hooks that fail persistently are cut off for a period of time.
+type Deliverer struct {
+	logger          log.Logger
+	do              func(*http.Request) (*http.Response, error)
+	timeout         time.Duration
+	idleTimeout     time.Duration
+	queueSize       int
+	retries         int
+	initialBackoff  time.Duration
+	maxBackoff      time.Duration
+	cutoffThreshold int
+	cutoffPeriod    time.Duration
+	deadLetter      DeadLetterFunc
+	sleep           func(time.Duration)
+	now             func() time.Time
+
+	workers chan struct{}
+
+	// ctx is the parent of every HTTP transaction.  It is cancelled once all queued messages have been
+	// dispatched, which abandons any outstanding failure notifications.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock         sync.Mutex
+	stopped      bool
+	destinations map[string]*destination
+	waitGroup    sync.WaitGroup
+	notifiers    sync.WaitGroup
+}
+
+// NewDeliverer constructs a Deliverer from a set of options.  The returned Deliverer is ready to accept messages.
+func NewDeliverer(o DeliveryOptions) *Deliverer {
+	d := &Deliverer{
+		logger:          o.Logger,
+		do:              o.Do,
+		timeout:         o.Timeout,
+		idleTimeout:     o.IdleTimeout,
+		queueSize:       o.QueueSize,
+		retries:         o.Retries,
+		initialBackoff:  o.InitialBackoff,
+		maxBackoff:      o.MaxBackoff,
+		cutoffThreshold: o.CutoffThreshold,
+		cutoffPeriod:    o.CutoffPeriod,
+		deadLetter:      o.DeadLetter,
+		sleep:           o.Sleep,
+		now:             o.Now,
+		destinations:    make(map[string]*destination),
+	}
+
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	if d.logger == nil {
+		d.logger = logging.DefaultLogger()
+	}
+
+	if d.do == nil {
+		d.do = http.DefaultClient.Do
+	}
+
+	if d.timeout < 1 {
+		d.timeout = DefaultDeliveryTimeout
+	}
+
+	if d.idleTimeout < 1 {
+		d.idleTimeout = DefaultDeliveryIdleTimeout
+	}
+
+	workers := o.Workers
+	if workers < 1 {
+		workers = DefaultDeliveryWorkers
+	}
+
+	d.workers = make(chan struct{}, workers)
+
+	if d.queueSize < 1 {
+		d.queueSize = DefaultDeliveryQueueSize
+	}
+
+	if d.retries == 0 {
+		d.retries = DefaultDeliveryRetries
+	} else if d.retries < 0 {
+		d.retries = 0
+	}
+
+	if d.initialBackoff < 1 {
+		d.initialBackoff = DefaultDeliveryInitialBackoff
+	}
+
+	if d.maxBackoff < 1 {
+		d.maxBackoff = DefaultDeliveryMaxBackoff
+	}
+
+	if d.cutoffPeriod < 1 {
+		d.cutoffPeriod = DefaultDeliveryCutoffPeriod
+	}
+
+	if d.deadLetter == nil {
+		d.deadLetter = func(W, Message, error) {}
+	}
+
+	if d.sleep == nil {
+		d.sleep = time.Sleep
+	}
+
+	if d.now == nil {
+		d.now = time.Now
+	}
+
+	return d
+}
+
+// Send enqueues a message for delivery to the given hook.  This method does not block.  An error is returned,
+// and the dead letter function is not invoked, if the message cannot be queued.
+func (d *Deliverer) Send(w W, m Message) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.stopped {
+		return ErrDelivererStopped
+	}
+
+	dest, ok := d.destinations[w.ID()]
+	if !ok {
+		dest = &destination{id: w.ID(), queue: make(chan envelope, d.queueSize)}
+		d.destinations[w.ID()] = dest
+		d.waitGroup.Add(1)
+		go d.dispatch(dest)
+	}
+
+	if d.now().Before(dest.cutoffUntil) {
+		return ErrDeliveryCutoff
+	}
+
+	select {
+	case dest.queue <- envelope{w, m}:
+		return nil
+	default:
+		return ErrDeliveryQueueFull
+	}
+}
+
+// Stop halts delivery.  Messages already queued are delivered, subject to retries, before this method returns.
+// Since each HTTP transaction is bounded by the configured Timeout, an unresponsive hook cannot block this method
+// indefinitely.
+// Failure notifications still outstanding at that point are cancelled.  This method is idempotent.
+func (d *Deliverer) Stop() {
+	d.lock.Lock()
+	if !d.stopped {
+		d.stopped = true
+		for _, dest := range d.destinations {
+			close(dest.queue)
+		}
+	}
+
+	d.lock.Unlock()
+	d.waitGroup.Wait()
+	d.cancel()
+	d.notifiers.Wait()
+}
+
+// reap releases a destination whose queue is empty, so that a stream of short-lived hooks does not accumulate
+// goroutines.  A destination that has messages queued or is still cut off is retained, as is every destination
+// once the Deliverer is stopping, since Stop closes their queues.  This method returns true if the destination
+// was released.
+func (d *Deliverer) reap(dest *destination) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.stopped || len(dest.queue) > 0 || d.now().Before(dest.cutoffUntil) {
+		return false
+	}
+
+	delete(d.destinations, dest.id)
+	return true
+}
+
+// dispatch drains the queue for a single hook.  Messages for the same hook are delivered in order.  Once the
+// queue has been idle for the idle timeout, the destination is reaped and this goroutine exits.
+func (d *Deliverer) dispatch(dest *destination) {
+	defer d.waitGroup.Done()
+
+	idle := time.NewTimer(d.idleTimeout)
+	defer idle.Stop()
+
+	for {
+		var e envelope
+		select {
+		case next, ok := <-dest.queue:
+			if !ok {
+				return
+			}
+
+			e = next
+
+		case <-idle.C:
+			if d.reap(dest) {
+				return
+			}
+
+			idle.Reset(d.idleTimeout)
+			continue
+		}
+
+		d.send(dest, e)
+
+		if !idle.Stop() {
+			select {
+			case <-idle.C:
+			default:
+			}
+		}
+
+		idle.Reset(d.idleTimeout)
+	}
+}
+
+// send delivers a single dequeued message, updating the destination's failure and cutoff state
+func (d *Deliverer) send(dest *destination, e envelope) {
+	w, m := e.w, e.m
+	d.lock.Lock()
+	cutoff := d.now().Before(dest.cutoffUntil)
+	d.lock.Unlock()
+
+	if cutoff {
+		d.deadLetter(w, m, ErrDeliveryCutoff)
+		return
+	}
+
+	err := d.deliver(w, m)
+
+	d.lock.Lock()
+	if err == nil {
+		dest.failures = 0
+	} else {
+		dest.failures++
+		if d.cutoffThreshold > 0 && dest.failures >= d.cutoffThreshold {
+			dest.failures = 0
+			dest.cutoffUntil = d.now().Add(d.cutoffPeriod)
+			cutoff = true
+		}
+	}
+
+	d.lock.Unlock()
+
+	if err != nil {
+		d.deadLetter(w, m, err)
+	}
+
+	if cutoff {
+		d.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "cutting off webhook", "url", w.Config.URL, "period", d.cutoffPeriod)
+		if len(w.FailureURL) > 0 {
+			d.notifiers.Add(1)
+			go d.notifyFailure(w)
+		}
+	}
+}
+
+// deliver performs one delivery, including any retries.  A message that cannot be transcoded for the hook
+// is not retried.  A worker is only held for the duration of each HTTP transaction, never while backing off,
+// so that a few failing hooks cannot starve deliveries to the others.
+func (d *Deliverer) deliver(w W, m Message) error {
+	m, err := negotiate(w, m)
+	if err != nil {
+		d.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to transcode webhook event", "url", w.Config.URL, logging.ErrorKey(), err)
+		return err
+	}
+
+	backoff := d.initialBackoff
+	err = d.transact(w, m)
+	for r := 0; err != nil && r < d.retries && isRetryable(err); r++ {
+		d.sleep(backoff)
+		d.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "retrying webhook delivery", "url", w.Config.URL, logging.ErrorKey(), err, "retry", r+1)
+
+		if backoff *= 2; backoff > d.maxBackoff {
+			backoff = d.maxBackoff
+		}
+
+		err = d.transact(w, m)
+	}
+
+	if err != nil {
+		d.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "webhook delivery failed", "url", w.Config.URL, logging.ErrorKey(), err)
+	}
+
+	return err
+}
+
+// isRetryable determines if a delivery error is worth retrying.  Transport errors are always retried,
+// while responses are retried only for server errors and throttling.
+func isRetryable(err error) bool {
+	if de, ok := err.(DeliveryError); ok {
+		return de.Temporary()
+	}
+
+	return true
+}
+
+// transact performs a single HTTP transaction against a hook, using one of the workers
+func (d *Deliverer) transact(w W, m Message) error {
+	d.workers <- struct{}{}
+	defer func() { <-d.workers }()
+
+	request, err := http.NewRequest("POST", w.Config.URL, bytes.NewReader(m.Body))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(d.ctx, d.timeout)
+	defer cancel()
+	request = request.WithContext(ctx)
+
+	contentType := m.ContentType
+	if len(contentType) == 0 {
+		contentType = w.Config.ContentType
+	}
+
+	if len(contentType) > 0 {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	for _, signature := range Signatures(w, m.Body) {
+		request.Header.Add(SignatureHeader, signature)
+	}
+
+	response, err := d.do(request)
+	if err != nil {
+		return err
+	}
+
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, deliveryDrainLimit))
+	response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return DeliveryError{StatusCode: response.StatusCode}
+	}
+
+	return nil
+}
+
+// notifyFailure lets a hook's failure URL know that the hook has been cut off.  This is best effort, and is
+// abandoned if the Deliverer is stopped first.  The hook's secrets are never sent.
+func (d *Deliverer) notifyFailure(w W) {
+	defer d.notifiers.Done()
+
+	body, err := json.Marshal(newFailureNotification(w))
+	if err != nil {
+		d.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to marshal failure notification", "url", w.FailureURL, logging.ErrorKey(), err)
+		return
+	}
+
+	request, err := http.NewRequest("POST", w.FailureURL, bytes.NewReader(body))
+	if err != nil {
+		d.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "invalid failure URL", "url", w.FailureURL, logging.ErrorKey(), err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(d.ctx, d.timeout)
+	defer cancel()
+
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	if response, err := d.do(request); err != nil {
+		d.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to notify failure URL", "url", w.FailureURL, logging.ErrorKey(), err)
+	} else {
+		io.Copy(ioutil.Discard, io.LimitReader(response.Body, deliveryDrainLimit))
+		response.Body.Close()
+	}
+}
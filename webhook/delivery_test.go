@@ -0,0 +1,564 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTransactor records requests and replays a fixed sequence of outcomes
+type testTransactor struct {
+	lock     sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	outcomes []interface{}
+}
+
+func (tt *testTransactor) Do(request *http.Request) (*http.Response, error) {
+	tt.lock.Lock()
+	defer tt.lock.Unlock()
+
+	body, _ := ioutil.ReadAll(request.Body)
+	tt.requests = append(tt.requests, request)
+	tt.bodies = append(tt.bodies, string(body))
+
+	var outcome interface{} = http.StatusOK
+	if len(tt.outcomes) > 0 {
+		outcome, tt.outcomes = tt.outcomes[0], tt.outcomes[1:]
+	}
+
+	if err, ok := outcome.(error); ok {
+		return nil, err
+	}
+
+	return &http.Response{StatusCode: outcome.(int), Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+}
+
+func (tt *testTransactor) count() int {
+	tt.lock.Lock()
+	defer tt.lock.Unlock()
+	return len(tt.requests)
+}
+
+type deadLetter struct {
+	w   W
+	m   Message
+	err error
+}
+
+func testDeliveryW(url string) W {
+	var w W
+	w.Config.URL = url
+	w.Config.ContentType = "application/json"
+	return w
+}
+
+func TestDeliveryError(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(DeliveryError{StatusCode: 503}.Temporary())
+	assert.True(DeliveryError{StatusCode: 429}.Temporary())
+	assert.False(DeliveryError{StatusCode: 400}.Temporary())
+	assert.Contains(DeliveryError{StatusCode: 400}.Error(), "400")
+}
+
+func testDelivererSuccess(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		transactor = new(testTransactor)
+		deliverer  = NewDeliverer(DeliveryOptions{
+			Logger: logging.NewTestLogger(nil, t),
+			Do:     transactor.Do,
+		})
+
+		w = testDeliveryW("http://example.com/hook")
+	)
+
+	w.Config.Secret = "secret"
+	w.Config.PreviousSecret = "previous"
+	assert.NoError(deliverer.Send(w, Message{Body: []byte("hello")}))
+	assert.NoError(deliverer.Send(w, Message{ContentType: "text/plain", Body: []byte("world")}))
+	deliverer.Stop()
+
+	require.Equal(2, transactor.count())
+	assert.Equal([]string{"hello", "world"}, transactor.bodies)
+	assert.Equal("application/json", transactor.requests[0].Header.Get("Content-Type"))
+	assert.Equal("text/plain", transactor.requests[1].Header.Get("Content-Type"))
+	assert.Equal(
+		[]string{Signature("secret", []byte("hello")), Signature("previous", []byte("hello"))},
+		transactor.requests[0].Header[SignatureHeader],
+	)
+
+	assert.Equal(ErrDelivererStopped, deliverer.Send(w, Message{}))
+	deliverer.Stop()
+}
+
+func testDelivererRetries(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		transactor = &testTransactor{outcomes: []interface{}{errors.New("expected"), 503, 429, 200}}
+
+		sleeps    []time.Duration
+		deliverer = NewDeliverer(DeliveryOptions{
+			Logger:         logging.NewTestLogger(nil, t),
+			Do:             transactor.Do,
+			Retries:        5,
+			InitialBackoff: time.Second,
+			MaxBackoff:     3 * time.Second,
+			Sleep:          func(d time.Duration) { sleeps = append(sleeps, d) },
+		})
+	)
+
+	assert.NoError(deliverer.Send(testDeliveryW("http://example.com/hook"), Message{Body: []byte("hello")}))
+	deliverer.Stop()
+
+	assert.Equal(4, transactor.count())
+	assert.Equal([]time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, sleeps)
+	assert.Equal([]string{"hello", "hello", "hello", "hello"}, transactor.bodies)
+}
+
+func testDelivererDeadLetter(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		transactor = &testTransactor{outcomes: []interface{}{400, 500, 500, 500}}
+
+		deadLetters []deadLetter
+		deliverer   = NewDeliverer(DeliveryOptions{
+			Logger:     logging.NewTestLogger(nil, t),
+			Do:         transactor.Do,
+			Retries:    2,
+			Sleep:      func(time.Duration) {},
+			DeadLetter: func(w W, m Message, err error) { deadLetters = append(deadLetters, deadLetter{w, m, err}) },
+		})
+
+		w = testDeliveryW("http://example.com/hook")
+	)
+
+	assert.NoError(deliverer.Send(w, Message{Body: []byte("poison")}))
+	assert.NoError(deliverer.Send(w, Message{Body: []byte("failing")}))
+	deliverer.Stop()
+
+	// the 400 is not retried, while the 500s are
+	assert.Equal(4, transactor.count())
+	require.Len(deadLetters, 2)
+	assert.Equal("poison", string(deadLetters[0].m.Body))
+	assert.Equal(DeliveryError{StatusCode: 400}, deadLetters[0].err)
+	assert.Equal("failing", string(deadLetters[1].m.Body))
+	assert.Equal(DeliveryError{StatusCode: 500}, deadLetters[1].err)
+}
+
+func testDelivererCutoff(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		transactor = &testTransactor{outcomes: []interface{}{500, 500}}
+
+		now         = time.Now()
+		deadLetters []deadLetter
+		deliverer   = NewDeliverer(DeliveryOptions{
+			Logger:          logging.NewTestLogger(nil, t),
+			Do:              transactor.Do,
+			Retries:         -1,
+			QueueSize:       5,
+			CutoffThreshold: 2,
+			CutoffPeriod:    time.Minute,
+			Now:             func() time.Time { return now },
+			DeadLetter:      func(w W, m Message, err error) { deadLetters = append(deadLetters, deadLetter{w, m, err}) },
+		})
+
+		w = testDeliveryW("http://example.com/hook")
+	)
+
+	w.FailureURL = "http://example.com/failure"
+	w.Config.Secret = "current-secret"
+	w.Config.PreviousSecret = "previous-secret"
+	for _, body := range []string{"one", "two", "three"} {
+		assert.NoError(deliverer.Send(w, Message{Body: []byte(body)}))
+	}
+
+	deliverer.Stop()
+
+	// two failed deliveries, then the failure notification
+	require.Equal(3, transactor.count())
+	assert.Equal("http://example.com/failure", transactor.requests[2].URL.String())
+	assert.Contains(transactor.bodies[2], "http://example.com/hook")
+	assert.NotContains(transactor.bodies[2], "current-secret")
+	assert.NotContains(transactor.bodies[2], "previous-secret")
+
+	require.Len(deadLetters, 3)
+	assert.Equal("three", string(deadLetters[2].m.Body))
+	assert.Equal(ErrDeliveryCutoff, deadLetters[2].err)
+}
+
+func testDelivererSendCutoff(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		transactor = &testTransactor{outcomes: []interface{}{500}}
+
+		now       = time.Now()
+		nowLock   sync.Mutex
+		deliverer = NewDeliverer(DeliveryOptions{
+			Logger:          logging.NewTestLogger(nil, t),
+			Do:              transactor.Do,
+			Retries:         -1,
+			CutoffThreshold: 1,
+			CutoffPeriod:    time.Minute,
+			Now: func() time.Time {
+				nowLock.Lock()
+				defer nowLock.Unlock()
+				return now
+			},
+		})
+
+		w = testDeliveryW("http://example.com/hook")
+	)
+
+	defer deliverer.Stop()
+	assert.NoError(deliverer.Send(w, Message{Body: []byte("one")}))
+	for repeat := 0; repeat < 100 && deliverer.Send(w, Message{}) != ErrDeliveryCutoff; repeat++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(ErrDeliveryCutoff, deliverer.Send(w, Message{}))
+
+	nowLock.Lock()
+	now = now.Add(2 * time.Minute)
+	nowLock.Unlock()
+	assert.NoError(deliverer.Send(w, Message{}))
+}
+
+func testDelivererQueueFull(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		block   = make(chan struct{})
+		started = make(chan struct{}, 10)
+
+		deliverer = NewDeliverer(DeliveryOptions{
+			Logger: logging.NewTestLogger(nil, t),
+			Do: func(*http.Request) (*http.Response, error) {
+				started <- struct{}{}
+				<-block
+				return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			},
+			QueueSize: 1,
+		})
+
+		w = testDeliveryW("http://example.com/hook")
+	)
+
+	assert.NoError(deliverer.Send(w, Message{}))
+	<-started
+	assert.NoError(deliverer.Send(w, Message{}))
+	assert.Equal(ErrDeliveryQueueFull, deliverer.Send(w, Message{}))
+
+	// other hooks are unaffected
+	assert.NoError(deliverer.Send(testDeliveryW("http://other.com/hook"), Message{}))
+
+	close(block)
+	deliverer.Stop()
+}
+
+func testDelivererBackoffReleasesWorker(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		sleeping  = make(chan struct{})
+		release   = make(chan struct{})
+		delivered = make(chan string, 1)
+
+		deliverer = NewDeliverer(DeliveryOptions{
+			Logger:  logging.NewTestLogger(nil, t),
+			Workers: 1,
+			Retries: 1,
+			Do: func(request *http.Request) (*http.Response, error) {
+				if request.URL.Path == "/failing" {
+					return &http.Response{StatusCode: 503, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+				}
+
+				delivered <- request.URL.Path
+				return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			},
+			Sleep: func(time.Duration) {
+				sleeping <- struct{}{}
+				<-release
+			},
+		})
+	)
+
+	assert.NoError(deliverer.Send(testDeliveryW("http://example.com/failing"), Message{}))
+	<-sleeping
+
+	// the only worker must be available to other hooks while the failing hook backs off
+	assert.NoError(deliverer.Send(testDeliveryW("http://example.com/healthy"), Message{}))
+	select {
+	case path := <-delivered:
+		assert.Equal("/healthy", path)
+	case <-time.After(5 * time.Second):
+		assert.Fail("A backoff should not hold a worker")
+	}
+
+	close(release)
+	deliverer.Stop()
+}
+
+func testDelivererStopCancelsNotification(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		notifying = make(chan struct{})
+		cancelled = make(chan struct{})
+
+		deliverer = NewDeliverer(DeliveryOptions{
+			Logger:          logging.NewTestLogger(nil, t),
+			Retries:         -1,
+			CutoffThreshold: 1,
+			Do: func(request *http.Request) (*http.Response, error) {
+				if request.URL.Path == "/failure" {
+					close(notifying)
+					<-request.Context().Done()
+					close(cancelled)
+					return nil, request.Context().Err()
+				}
+
+				return &http.Response{StatusCode: 500, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			},
+		})
+
+		w = testDeliveryW("http://example.com/hook")
+	)
+
+	w.FailureURL = "http://example.com/failure"
+	assert.NoError(deliverer.Send(w, Message{}))
+	<-notifying
+
+	deliverer.Stop()
+	select {
+	case <-cancelled:
+	default:
+		assert.Fail("Stop should cancel outstanding failure notifications")
+	}
+}
+
+func testDelivererTranscode(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		transactor = new(testTransactor)
+
+		deadLetters []deadLetter
+		deliverer   = NewDeliverer(DeliveryOptions{
+			Logger:     logging.NewTestLogger(nil, t),
+			Do:         transactor.Do,
+			DeadLetter: func(w W, m Message, err error) { deadLetters = append(deadLetters, deadLetter{w, m, err}) },
+		})
+
+		event = &wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      "mac:112233445566",
+			Destination: "event:device-status",
+			Payload:     []byte("payload"),
+		}
+
+		msgpackEvent = wrp.MustEncode(event, wrp.Msgpack)
+		jsonEvent    = wrp.MustEncode(event, wrp.JSON)
+
+		jsonHook    = testDeliveryW("http://example.com/json")
+		msgpackHook = testDeliveryW("http://example.com/msgpack")
+		defaultHook = testDeliveryW("http://example.com/default")
+	)
+
+	jsonHook.Config.Secret = "secret"
+	jsonHook.Config.EventContentType = "application/json"
+	msgpackHook.Config.EventContentType = wrp.Msgpack.ContentType()
+
+	assert.NoError(deliverer.Send(jsonHook, Message{ContentType: wrp.Msgpack.ContentType(), Body: msgpackEvent, WRP: true}))
+	assert.NoError(deliverer.Send(jsonHook, Message{ContentType: "text/plain", Body: []byte("not wrp")}))
+	assert.NoError(deliverer.Send(jsonHook, Message{ContentType: wrp.Msgpack.ContentType(), Body: []byte("bad wrp"), WRP: true}))
+	assert.NoError(deliverer.Send(msgpackHook, Message{ContentType: wrp.Msgpack.ContentType(), Body: msgpackEvent, WRP: true}))
+	assert.NoError(deliverer.Send(defaultHook, Message{ContentType: wrp.Msgpack.ContentType(), Body: msgpackEvent, WRP: true}))
+	deliverer.Stop()
+
+	require.Equal(4, transactor.count())
+	paths := make(map[string]bool)
+	for i, r := range transactor.requests {
+		if r.URL.Path == "/json" {
+			if transactor.bodies[i] == "not wrp" {
+				assert.Equal("text/plain", r.Header.Get("Content-Type"))
+				continue
+			}
+
+			assert.Equal(wrp.JSON.ContentType(), r.Header.Get("Content-Type"))
+			assert.JSONEq(string(jsonEvent), transactor.bodies[i])
+
+			// the signature covers the transcoded body
+			assert.Equal([]string{Signature("secret", []byte(transactor.bodies[i]))}, r.Header[SignatureHeader])
+		} else {
+			assert.Equal(wrp.Msgpack.ContentType(), r.Header.Get("Content-Type"))
+			assert.Equal(string(msgpackEvent), transactor.bodies[i])
+		}
+
+		paths[r.URL.Path] = true
+	}
+
+	assert.Len(paths, 3)
+	require.Len(deadLetters, 1)
+	assert.Equal("bad wrp", string(deadLetters[0].m.Body))
+	assert.Error(deadLetters[0].err)
+}
+
+// endlessReader is a response body that never ends
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'x'
+	}
+
+	return len(p), nil
+}
+
+func testDelivererTimeout(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		deadLetters = make(chan deadLetter, 1)
+
+		deliverer = NewDeliverer(DeliveryOptions{
+			Logger:  logging.NewTestLogger(nil, t),
+			Retries: -1,
+			Timeout: 50 * time.Millisecond,
+			Do: func(request *http.Request) (*http.Response, error) {
+				// a hook that never responds
+				<-request.Context().Done()
+				return nil, request.Context().Err()
+			},
+			DeadLetter: func(w W, m Message, err error) {
+				deadLetters <- deadLetter{w, m, err}
+			},
+		})
+	)
+
+	require.NoError(deliverer.Send(testDeliveryW("http://example.com/hook"), Message{}))
+
+	select {
+	case dl := <-deadLetters:
+		assert.Equal(context.DeadlineExceeded, dl.err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The delivery should have timed out")
+	}
+
+	deliverer.Stop()
+}
+
+func testDelivererDrainLimit(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		transactor = func(request *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(endlessReader{})}, nil
+		}
+
+		deliverer = NewDeliverer(DeliveryOptions{
+			Logger: logging.NewTestLogger(nil, t),
+			Do:     transactor,
+			DeadLetter: func(w W, m Message, err error) {
+				assert.Fail("The delivery should succeed", err.Error())
+			},
+		})
+	)
+
+	require.NoError(deliverer.Send(testDeliveryW("http://example.com/hook"), Message{}))
+
+	done := make(chan struct{})
+	go func() {
+		deliverer.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail("An endless response body should not block delivery")
+	}
+}
+
+func testDelivererReapsIdle(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		require    = require.New(t)
+		transactor = new(testTransactor)
+		deliverer  = NewDeliverer(DeliveryOptions{
+			Logger:      logging.NewTestLogger(nil, t),
+			Do:          transactor.Do,
+			IdleTimeout: 20 * time.Millisecond,
+		})
+
+		w = testDeliveryW("http://example.com/hook")
+	)
+
+	defer deliverer.Stop()
+	require.NoError(deliverer.Send(w, Message{Body: []byte("first")}))
+
+	destinations := func() int {
+		deliverer.lock.Lock()
+		defer deliverer.lock.Unlock()
+		return len(deliverer.destinations)
+	}
+
+	assert.Eventually(func() bool { return transactor.count() == 1 && destinations() == 0 }, 5*time.Second, 5*time.Millisecond)
+
+	// a reaped hook can still be sent to
+	require.NoError(deliverer.Send(w, Message{Body: []byte("second")}))
+	assert.Eventually(func() bool { return transactor.count() == 2 }, 5*time.Second, 5*time.Millisecond)
+}
+
+func testDelivererKeepsCutoff(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		deliverer = NewDeliverer(DeliveryOptions{
+			Logger:          logging.NewTestLogger(nil, t),
+			Retries:         -1,
+			CutoffThreshold: 1,
+			CutoffPeriod:    time.Hour,
+			IdleTimeout:     10 * time.Millisecond,
+			Do: func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: 500, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			},
+		})
+
+		w = testDeliveryW("http://example.com/hook")
+	)
+
+	defer deliverer.Stop()
+	assert.NoError(deliverer.Send(w, Message{}))
+	assert.Eventually(func() bool { return deliverer.Send(w, Message{}) == ErrDeliveryCutoff }, 5*time.Second, 5*time.Millisecond)
+
+	// a hook that is cut off is not reaped, so that its cutoff remains in effect
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(ErrDeliveryCutoff, deliverer.Send(w, Message{}))
+}
+
+func TestDeliverer(t *testing.T) {
+	t.Run("Success", testDelivererSuccess)
+	t.Run("Transcode", testDelivererTranscode)
+	t.Run("Retries", testDelivererRetries)
+	t.Run("DeadLetter", testDelivererDeadLetter)
+	t.Run("Cutoff", testDelivererCutoff)
+	t.Run("SendCutoff", testDelivererSendCutoff)
+	t.Run("QueueFull", testDelivererQueueFull)
+	t.Run("BackoffReleasesWorker", testDelivererBackoffReleasesWorker)
+	t.Run("StopCancelsNotification", testDelivererStopCancelsNotification)
+	t.Run("Timeout", testDelivererTimeout)
+	t.Run("DrainLimit", testDelivererDrainLimit)
+	t.Run("ReapsIdle", testDelivererReapsIdle)
+	t.Run("KeepsCutoff", testDelivererKeepsCutoff)
+}
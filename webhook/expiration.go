@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"time"
+)
+
+// ExpirationEventType describes what happened to a registration with respect to its expiry
+type ExpirationEventType int
+
+const (
+	// RegistrationRenewed indicates that an existing registration was updated with a later expiry.  Updates that
+	// leave the expiry unchanged, such as secret rotations, are not renewals.
+	RegistrationRenewed ExpirationEventType = iota
+
+	// RegistrationExpiring indicates that a registration has passed its expiry and is within the grace period.
+	// This event is dispatched each time the undertaker runs until the registration is either renewed or expired.
+	RegistrationExpiring
+
+	// RegistrationExpired indicates that a registration has been removed because it expired
+	RegistrationExpired
+)
+
+func (t ExpirationEventType) String() string {
+	switch t {
+	case RegistrationRenewed:
+		return "renewed"
+	case RegistrationExpiring:
+		return "expiring"
+	case RegistrationExpired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+// ExpirationEvent carries information about a change in a registration's lifetime
+type ExpirationEvent struct {
+	// Type is the kind of event
+	Type ExpirationEventType
+
+	// Hook is the registration in question
+	Hook W
+
+	// Remaining is the time left before the registration is removed.  For RegistrationRenewed events, this is
+	// the time left until the new expiry.  For RegistrationExpiring events, this is the time left in the
+	// grace period.  For RegistrationExpired events, this is always zero.
+	Remaining time.Duration
+}
+
+// ExpirationListener is a sink for ExpirationEvents.  Listeners are invoked synchronously by the goroutine
+// that manages the registration list, and so should not block.
+type ExpirationListener func(ExpirationEvent)
+
+// partners returns the metric label values for a hook's partners.  Partner ids are supplied by clients, so only
+// those configured as metric partners are used as label values.  All others share the OtherPartner value, which
+// keeps the cardinality of the PartnerLabel bounded.
+func (m *monitor) partners(w W) []string {
+	if len(w.PartnerIDs) == 0 {
+		return []string{NoPartner}
+	}
+
+	var (
+		labels = make([]string, 0, len(w.PartnerIDs))
+		other  bool
+	)
+
+	for _, p := range w.PartnerIDs {
+		if m.metricPartners[p] {
+			labels = append(labels, p)
+		} else if !other {
+			other = true
+			labels = append(labels, OtherPartner)
+		}
+	}
+
+	return labels
+}
+
+func (m *monitor) dispatchExpiration(e ExpirationEvent) {
+	for _, l := range m.expirationListeners {
+		l(e)
+	}
+}
+
+// snapshot returns a copy of the current list
+func (m *monitor) snapshot() []W {
+	items := make([]W, 0, m.list.Len())
+	for i := 0; i < m.list.Len(); i++ {
+		items = append(items, *m.list.Get(i))
+	}
+
+	return items
+}
+
+// update applies a set of registrations to the list, recording renewals and time to expiry
+func (m *monitor) update(update []W, now time.Time) {
+	existing := make(map[string]time.Time, m.list.Len())
+	for _, w := range m.snapshot() {
+		existing[w.ID()] = w.Until
+	}
+
+	m.list.Update(update)
+
+	for _, w := range update {
+		if !w.Until.After(now) {
+			// expired hooks are never added to the list
+			continue
+		}
+
+		until, ok := existing[w.ID()]
+		if ok && !w.Until.After(until) {
+			// neither a new registration nor a renewal
+			continue
+		}
+
+		existing[w.ID()] = w.Until
+		remaining := w.Until.Sub(now)
+		if m.metrics.TimeToExpiry != nil {
+			m.metrics.TimeToExpiry.Observe(remaining.Seconds())
+		}
+
+		if ok {
+			if m.metrics.Renewed != nil {
+				for _, p := range m.partners(w) {
+					m.metrics.Renewed.With(PartnerLabel, p).Add(1.0)
+				}
+			}
+
+			m.dispatchExpiration(ExpirationEvent{Type: RegistrationRenewed, Hook: w, Remaining: remaining})
+		}
+	}
+}
+
+// undertake runs the undertaker against the list, dispatching warnings for registrations within
+// the grace period and recording registrations that were removed
+func (m *monitor) undertake(now time.Time) {
+	before := m.snapshot()
+	m.list.Filter(m.undertaker)
+
+	remaining := make(map[string]bool, m.list.Len())
+	for _, w := range m.snapshot() {
+		remaining[w.ID()] = true
+		if !w.Until.After(now) {
+			m.dispatchExpiration(ExpirationEvent{Type: RegistrationExpiring, Hook: w, Remaining: w.Until.Add(m.gracePeriod).Sub(now)})
+		}
+	}
+
+	for _, w := range before {
+		if remaining[w.ID()] {
+			continue
+		}
+
+		if m.metrics.Expired != nil {
+			for _, p := range m.partners(w) {
+				m.metrics.Expired.With(PartnerLabel, p).Add(1.0)
+			}
+		}
+
+		m.dispatchExpiration(ExpirationEvent{Type: RegistrationExpired, Hook: w})
+	}
+
+	if m.metrics.ListSize != nil {
+		m.metrics.ListSize.Set(float64(m.list.Len()))
+	}
+}
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpirationEventType(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("renewed", RegistrationRenewed.String())
+	assert.Equal("expiring", RegistrationExpiring.String())
+	assert.Equal("expired", RegistrationExpired.String())
+	assert.Equal("unknown", ExpirationEventType(-1).String())
+}
+
+func testExpirationMonitor(p xmetricstest.Provider, gracePeriod time.Duration, events *[]ExpirationEvent) *monitor {
+	f := &Factory{GracePeriod: gracePeriod}
+	return &monitor{
+		list:           NewList(nil),
+		undertaker:     f.Prune,
+		gracePeriod:    gracePeriod,
+		metricPartners: map[string]bool{"comcast": true},
+		expirationListeners: []ExpirationListener{
+			func(e ExpirationEvent) { *events = append(*events, e) },
+		},
+		metrics: WebhookMetrics{
+			ListSize:     p.NewGauge(ListSize),
+			Expired:      p.NewCounter(ExpiredCount),
+			Renewed:      p.NewCounter(RenewedCount),
+			TimeToExpiry: p.NewHistogram(TimeToExpiry, 0),
+		},
+	}
+}
+
+func testExpirationW(url string, until time.Time, partnerIDs ...string) W {
+	w := testStoreW(url, until)
+	w.PartnerIDs = partnerIDs
+	return w
+}
+
+func TestMonitorUpdateRenewed(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Now()
+		events  []ExpirationEvent
+
+		p = xmetricstest.NewProvider(nil, Metrics).
+			Expect(RenewedCount, PartnerLabel, "comcast")(xmetricstest.Value(1.0)).
+			Expect(RenewedCount, PartnerLabel, NoPartner)(xmetricstest.Value(0.0)).
+			Expect(RenewedCount, PartnerLabel, OtherPartner)(xmetricstest.Value(0.0))
+
+		m = testExpirationMonitor(p, 0, &events)
+	)
+
+	m.update([]W{testExpirationW("http://a.com", now.Add(time.Hour), "comcast")}, now)
+	assert.Equal(1, m.list.Len())
+	assert.Empty(events)
+
+	m.update([]W{testExpirationW("http://a.com", now.Add(2*time.Hour), "comcast")}, now)
+	assert.Equal(1, m.list.Len())
+	require.Len(events, 1)
+	assert.Equal(RegistrationRenewed, events[0].Type)
+	assert.Equal("http://a.com", events[0].Hook.ID())
+	assert.Equal(2*time.Hour, events[0].Remaining)
+
+	// an update that does not extend the expiry, such as a secret rotation, is not a renewal
+	m.update([]W{testExpirationW("http://a.com", now.Add(2*time.Hour), "comcast")}, now)
+	assert.Equal(1, m.list.Len())
+	assert.Len(events, 1)
+
+	p.AssertExpectations(t)
+}
+
+func TestMonitorUndertake(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Now()
+		events  []ExpirationEvent
+
+		p = xmetricstest.NewProvider(nil, Metrics).
+			Expect(ExpiredCount, PartnerLabel, "comcast")(xmetricstest.Value(1.0)).
+			Expect(ExpiredCount, PartnerLabel, OtherPartner)(xmetricstest.Value(1.0)).
+			Expect(ListSize)(xmetricstest.Value(2.0))
+
+		m = testExpirationMonitor(p, 10*time.Minute, &events)
+	)
+
+	m.list.Update([]W{
+		testExpirationW("http://active.com", now.Add(time.Hour)),
+		testExpirationW("http://grace.com", now.Add(time.Second)),
+		testExpirationW("http://expired.com", now.Add(time.Second), "comcast", "sky", "unknown"),
+	})
+
+	require.Equal(3, m.list.Len())
+
+	// hooks in the list are not added if already expired, so shift them after the fact
+	m.list.Filter(func(items []W) []W {
+		for i := range items {
+			switch items[i].ID() {
+			case "http://grace.com":
+				items[i].Until = now.Add(-time.Minute)
+			case "http://expired.com":
+				items[i].Until = now.Add(-time.Hour)
+			}
+		}
+
+		return items
+	})
+
+	m.undertake(now)
+	assert.Equal(2, m.list.Len())
+	require.Len(events, 2)
+
+	assert.Equal(RegistrationExpiring, events[0].Type)
+	assert.Equal("http://grace.com", events[0].Hook.ID())
+	assert.True(events[0].Remaining > 8*time.Minute && events[0].Remaining <= 9*time.Minute)
+
+	assert.Equal(RegistrationExpired, events[1].Type)
+	assert.Equal("http://expired.com", events[1].Hook.ID())
+	assert.Zero(events[1].Remaining)
+
+	p.AssertExpectations(t)
+}
+
+func TestFactoryPruneGracePeriod(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		now    = time.Now()
+		items  = []W{
+			testStoreW("http://active.com", now.Add(time.Hour)),
+			testStoreW("http://grace.com", now.Add(-time.Minute)),
+			testStoreW("http://expired.com", now.Add(-time.Hour)),
+		}
+	)
+
+	assert.Len((&Factory{}).Prune(items), 1)
+	assert.Len((&Factory{GracePeriod: 10 * time.Minute}).Prune(items), 2)
+}
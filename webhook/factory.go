@@ -1,12 +1,18 @@
 package webhook
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/logging/audit"
 	AWS "github.com/Comcast/webpa-common/webhook/aws"
+	"github.com/Comcast/webpa-common/webhook/pubsub"
 	"github.com/Comcast/webpa-common/xhttp"
-	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics/provider"
 	"github.com/spf13/viper"
 )
 
@@ -37,6 +43,35 @@ type Factory struct {
 
 	// StartConfig is the contains the data need to obtain the current system's listeners
 	Start *StartConfig `json:"start"`
+
+	// Store is an optional durable store for registrations.  When set, the registry is seeded from
+	// this store, every update is written through to it, and expired hooks are pruned from it.
+	Store Store `json:"-"`
+
+	// Validation is the policy applied to registration requests.  If unset, the default policy is used.
+	Validation *ValidationOptions `json:"validation"`
+
+	// GracePeriod is how long a registration is retained after it expires.  During this period, the hook
+	// still receives traffic and ExpirationListeners are warned each time the undertaker runs.
+	GracePeriod time.Duration `json:"gracePeriod"`
+
+	// ExpirationListeners are notified when registrations are renewed, enter the grace period, or expire
+	ExpirationListeners []ExpirationListener `json:"-"`
+
+	// MetricPartners are the partner ids reported individually by the partner label of webhook metrics.
+	// Metrics for any other partner are reported under OtherPartner.
+	MetricPartners []string `json:"metricPartners"`
+
+	// Broker propagates registrations across nodes.  If unset, the AWS SNS Notifier is used.
+	Broker pubsub.Broker `json:"-"`
+
+	// Auditor records registration and secret rotation requests as audit events.  If unset, no audit
+	// events are recorded.
+	Auditor *audit.Logger `json:"-"`
+
+	// Logger is the go-kit logger used for errors that cannot be returned to a caller, such as Store failures.
+	// If unset, logging.DefaultLogger() is used.
+	Logger log.Logger `json:"-"`
 }
 
 // NewFactory creates a Factory from a Viper environment.  This function always returns
@@ -67,6 +102,12 @@ func NewFactory(v *viper.Viper) (f *Factory, err error) {
 	}
 
 	f.undertaker = f.Prune
+
+	// a configured pubsub broker replaces SNS, so that deployments outside AWS need no AWS configuration
+	if f.Broker, err = pubsub.New(v); err != nil || f.Broker != nil {
+		return
+	}
+
 	f.Notifier, err = AWS.NewNotifier(v)
 
 	return
@@ -79,7 +120,7 @@ func (f *Factory) SetList(ul UpdatableList) {
 
 func (f *Factory) Prune(items []W) (list []W) {
 	for i := 0; i < len(items); i++ {
-		if items[i].Until.After(time.Now()) {
+		if items[i].Until.Add(f.GracePeriod).After(time.Now()) {
 			list = append(list, items[i])
 		}
 	}
@@ -88,25 +129,67 @@ func (f *Factory) Prune(items []W) (list []W) {
 }
 
 // NewRegistryAndHandler returns a List instance for accessing webhooks and an HTTP handler
-// which can receive updates from external systems.
-func (f *Factory) NewRegistryAndHandler(registry xmetrics.Registry) (Registry, http.Handler) {
+// which can receive updates from external systems.  Metrics are created with the given provider,
+// which is typically the xmetrics.Registry of the enclosing application.
+func (f *Factory) NewRegistryAndHandler(p provider.Provider) (Registry, http.Handler) {
 	tick := f.Tick
 	if tick == nil {
 		tick = time.Tick
 	}
 
+	logger := f.Logger
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	metrics := ApplyMetricsData(p)
+
+	var initial []W
+	if f.Store != nil {
+		// a failure to read the store is not fatal: the registry simply starts out empty
+		var err error
+		if initial, err = f.Store.List(); err != nil {
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to load webhooks from store", logging.ErrorKey(), err)
+			metrics.StoreErrors.With(OperationLabel, ListOperation).Add(1.0)
+		}
+	}
+
+	metricPartners := make(map[string]bool, len(f.MetricPartners))
+	for _, p := range f.MetricPartners {
+		metricPartners[p] = true
+	}
+
 	monitor := &monitor{
-		list:             NewList(nil),
-		undertaker:       f.undertaker,
-		changes:          make(chan []W, 10),
-		undertakerTicker: tick(f.UndertakerInterval),
+		list:                NewList(initial),
+		undertaker:          f.undertaker,
+		changes:             make(chan []W, 10),
+		undertakerTicker:    tick(f.UndertakerInterval),
+		store:               f.Store,
+		storeOps:            make(chan storeOp, storeQueueSize),
+		logger:              logger,
+		validation:          f.Validation,
+		gracePeriod:         f.GracePeriod,
+		expirationListeners: f.ExpirationListeners,
+		metricPartners:      metricPartners,
+		broker:              f.Broker,
+		auditor:             f.Auditor,
 	}
 	f.m = monitor
 	f.m.Notifier = f.Notifier
-	f.m.metrics = ApplyMetricsData(registry)
+	f.m.metrics = metrics
 
 	reg := NewRegistry(f.m)
 
+	if f.Broker != nil {
+		// there is no way to report this error without changing this method's signature,
+		// and a Broker only fails to subscribe when misused
+		f.Broker.Subscribe(func(message []byte) { monitor.receive(message) })
+	}
+
+	if f.Store != nil {
+		go monitor.writeStore()
+	}
+
 	go monitor.listen()
 	return reg, monitor
 }
@@ -125,25 +208,92 @@ type monitor struct {
 	changes          chan []W
 	undertakerTicker <-chan time.Time
 	AWS.Notifier
-	externalUpdate   func([]W)
-	metrics          WebhookMetrics
+	externalUpdate      func([]W)
+	metrics             WebhookMetrics
+	store               Store
+	storeOps            chan storeOp
+	logger              log.Logger
+	validation          *ValidationOptions
+	gracePeriod         time.Duration
+	expirationListeners []ExpirationListener
+	metricPartners      map[string]bool
+	broker              pubsub.Broker
+	auditor             *audit.Logger
 }
 
 func (m *monitor) listen() {
 	for {
 		select {
 		case update := <-m.changes:
-			m.list.Update(update)
+			m.update(update, time.Now())
+
+			if m.store != nil {
+				m.persist(PutOperation, func() error {
+					for _, w := range update {
+						if err := m.store.Put(w); err != nil {
+							return err
+						}
+					}
+
+					return nil
+				})
+			}
 
 			if m.externalUpdate != nil {
 				m.externalUpdate(update)
 			}
 		case <-m.undertakerTicker:
-			m.list.Filter(m.undertaker)
+			now := time.Now()
+			m.undertake(now)
+
+			if m.store != nil {
+				cutoff := now.Add(-m.gracePeriod)
+				m.persist(PruneOperation, func() error {
+					_, err := PruneStore(m.store, cutoff)
+					return err
+				})
+			}
 		}
 	}
 }
 
+// storeQueueSize is the number of Store operations that may be waiting for the store writer
+const storeQueueSize = 100
+
+// errStoreQueueFull indicates that a Store operation was dropped because the store writer has fallen behind
+var errStoreQueueFull = errors.New("The webhook store queue is full")
+
+// storeOp is a single Store operation executed by the store writer
+type storeOp struct {
+	operation string
+	run       func() error
+}
+
+// persist queues a Store operation, so that Store I/O never blocks the listener.  If the queue is full,
+// the operation is dropped and reported as a failure.
+func (m *monitor) persist(operation string, run func() error) {
+	select {
+	case m.storeOps <- storeOp{operation, run}:
+	default:
+		m.storeFailed(operation, errStoreQueueFull)
+	}
+}
+
+// writeStore executes queued Store operations in order
+func (m *monitor) writeStore() {
+	for op := range m.storeOps {
+		if err := op.run(); err != nil {
+			m.storeFailed(op.operation, err)
+		}
+	}
+}
+
+// storeFailed logs and counts a failed Store operation
+func (m *monitor) storeFailed(operation string, err error) {
+	m.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "webhook store operation failed", OperationLabel, operation, logging.ErrorKey(), err)
+	m.metrics.StoreErrors.With(OperationLabel, operation).Add(1.0)
+}
+
 // sendNewHooks handles delivery of []W to monitor.changes
 func (m *monitor) sendNewHooks(newHooks []W) {
 	select {
@@ -161,17 +311,34 @@ func (m *monitor) ServeHTTP(response http.ResponseWriter, request *http.Request)
 		return
 	}
 
+	if err := m.receive(message); err != nil {
+		xhttp.WriteError(response, http.StatusBadRequest, "Notification Message JSON unmarshall failed")
+	}
+}
+
+// receive handles a registration propagated from another node, whether via SNS or a pubsub.Broker
+func (m *monitor) receive(message []byte) error {
 	// transform message to W
 	w, err := NewW(message, "")
 	if nil != err {
 		w, err = doOldHookConvert(message)
 	}
 	if nil != err {
-		xhttp.WriteError(response, http.StatusBadRequest, "Notification Message JSON unmarshall failed")
 		m.metrics.NotificationUnmarshallFailed.Add(1.0)
-		return
+		return err
 	}
 	m.sendNewHooks([]W{*w})
 	
 	m.metrics.ListSize.Set( float64(m.list.Len()) )
+	return nil
+}
+
+// publish propagates a registration to all nodes, including this one
+func (m *monitor) publish(message []byte) error {
+	if m.broker != nil {
+		return m.broker.Publish(message)
+	}
+
+	m.PublishMessage(string(message))
+	return nil
 }
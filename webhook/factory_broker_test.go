@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/webhook/pubsub"
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBroker is an in-process pubsub.Broker that delivers published messages synchronously
+type testBroker struct {
+	lock      sync.Mutex
+	handler   pubsub.Handler
+	published []string
+}
+
+func (tb *testBroker) Publish(message []byte) error {
+	tb.lock.Lock()
+	tb.published = append(tb.published, string(message))
+	handler := tb.handler
+	tb.lock.Unlock()
+
+	if handler != nil {
+		handler(message)
+	}
+
+	return nil
+}
+
+func (tb *testBroker) Subscribe(h pubsub.Handler) error {
+	tb.lock.Lock()
+	tb.handler = h
+	tb.lock.Unlock()
+	return nil
+}
+
+func (tb *testBroker) Close() error {
+	return nil
+}
+
+func TestFactoryBroker(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		broker  = new(testBroker)
+	)
+
+	metricsRegistry, err := xmetrics.NewRegistry(&xmetrics.Options{}, Metrics)
+	require.NoError(err)
+
+	factory := &Factory{
+		Tick:   func(time.Duration) <-chan time.Time { return nil },
+		Broker: broker,
+	}
+
+	factory.undertaker = factory.Prune
+	registry, _ := factory.NewRegistryAndHandler(metricsRegistry)
+
+	updated := make(chan []W, 1)
+	factory.SetExternalUpdate(func(hooks []W) { updated <- hooks })
+
+	response := httptest.NewRecorder()
+	registry.UpdateRegistry(
+		response,
+		httptest.NewRequest("POST", "/hook", strings.NewReader(`{"config": {"url": "https://example.com/hook"}, "events": ["iot"]}`)),
+	)
+
+	assert.Equal(http.StatusOK, response.Code)
+
+	select {
+	case hooks := <-updated:
+		require.Len(hooks, 1)
+		assert.Equal("https://example.com/hook", hooks[0].ID())
+	case <-time.After(5 * time.Second):
+		require.Fail("The registration was not propagated")
+	}
+
+	broker.lock.Lock()
+	assert.Len(broker.published, 1)
+	broker.lock.Unlock()
+}
@@ -4,7 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strconv"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/logging/audit"
+	"github.com/Comcast/webpa-common/secure/handler"
+)
+
+// The audit actions for requests handled by a Registry
+const (
+	RegisterAction     = "webhook.register"
+	RotateSecretAction = "webhook.rotateSecret"
 )
 
 type Registry struct {
@@ -26,17 +38,93 @@ func jsonResponse(rw http.ResponseWriter, code int, msg string) {
 	rw.Write([]byte(fmt.Sprintf(`{"message":"%s"}`, msg)))
 }
 
-// get is an api call to return all the registered listeners
-func (r *Registry) GetRegistry(rw http.ResponseWriter, req *http.Request) {
-	var items = []*W{}
+// audit records a request as an audit event, if the Registry has an Auditor.  The actor is the authenticated
+// client, or the request's remote address for unauthenticated requests.  Failures to record the event are logged.
+func (r *Registry) audit(req *http.Request, action, resource string, outcome audit.Outcome, reason string) {
+	if r.m.auditor == nil {
+		return
+	}
+
+	actor, ok := handler.SatClientIDFromContext(req.Context())
+	if !ok {
+		actor = req.RemoteAddr
+	}
+
+	if len(actor) == 0 {
+		actor = "unknown"
+	}
+
+	if len(resource) == 0 {
+		resource = req.URL.Path
+	}
+
+	if err := r.m.auditor.Log(audit.Event{
+		Actor:    actor,
+		Action:   action,
+		Resource: resource,
+		Outcome:  outcome,
+		Reason:   reason,
+	}); err != nil {
+		logger := r.m.logger
+		if logger == nil {
+			logger = logging.DefaultLogger()
+		}
+
+		logging.Error(logger).Log(logging.MessageKey(), "unable to audit request", "action", action, logging.ErrorKey(), err)
+	}
+}
+
+// owner describes the client making a request, in the same terms a hook records its registering client
+func owner(req *http.Request) (clientID, address string) {
+	clientID, _ = handler.SatClientIDFromContext(req.Context())
+	address, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		address = req.RemoteAddr
+	}
+
+	return
+}
+
+// authorized checks that a request comes from the client that registered the given hook.  Hooks registered by an
+// authenticated client belong to that client.  Otherwise, hooks belong to the address that registered them.  A hook
+// that records neither can be modified by no one.
+func authorized(req *http.Request, w *W) bool {
+	clientID, address := owner(req)
+	if len(w.RegisteredBy) > 0 {
+		return w.RegisteredBy == clientID
+	}
+
+	return len(w.Address) > 0 && w.Address == address
+}
+
+// find returns a copy of the registered hook with the given ID, or nil if there is no such hook
+func (r *Registry) find(id string) *W {
 	for i := 0; i < r.m.list.Len(); i++ {
-		items = append(items, r.m.list.Get(i))
+		if candidate := r.m.list.Get(i); candidate.ID() == id {
+			found := *candidate
+			return &found
+		}
 	}
 
+	return nil
+}
+
+// get is an api call to return the registered listeners.  Query parameters can scope the listing
+// by partner, event type, and expiration window, and can paginate the results.  The total number of
+// matching listeners is returned in the TotalCountHeader.
+func (r *Registry) GetRegistry(rw http.ResponseWriter, req *http.Request) {
+	o, err := ParseListOptions(req.URL.Query())
+	if err != nil {
+		jsonResponse(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, total := r.List(o)
 	if msg, err := json.Marshal(items); err != nil {
 		jsonResponse(rw, http.StatusInternalServerError, err.Error())
 	} else {
 		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set(TotalCountHeader, strconv.Itoa(total))
 		rw.Write(msg)
 	}
 }
@@ -48,17 +136,38 @@ func (r *Registry) UpdateRegistry(rw http.ResponseWriter, req *http.Request) {
 
 	w, err := NewW(payload, req.RemoteAddr)
 	if err != nil {
+		r.audit(req, RegisterAction, "", audit.Denied, err.Error())
 		jsonResponse(rw, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	// the registering client is always taken from the request, never from the payload
+	w.RegisteredBy, w.Address = owner(req)
+	if err := r.m.validation.Validate(w); err != nil {
+		r.audit(req, RegisterAction, w.ID(), audit.Denied, err.Error())
+		writeValidationErrors(rw, err.(ValidationErrors))
+		return
+	}
+
+	if existing := r.find(w.ID()); existing != nil && !authorized(req, existing) {
+		r.audit(req, RegisterAction, w.ID(), audit.Denied, "Not the registering client")
+		jsonResponse(rw, http.StatusForbidden, "Not the registering client")
+		return
+	}
+
 	s, err := json.Marshal(w)
 	if err != nil {
+		r.audit(req, RegisterAction, w.ID(), audit.Failure, err.Error())
 		jsonResponse(rw, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	r.m.Notifier.PublishMessage(string(s))
+	if err := r.m.publish(s); err != nil {
+		r.audit(req, RegisterAction, w.ID(), audit.Failure, err.Error())
+		jsonResponse(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
 
+	r.audit(req, RegisterAction, w.ID(), audit.Success, "")
 	jsonResponse(rw, http.StatusOK, "Success")
 }
@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging/audit"
+	"github.com/Comcast/webpa-common/secure/handler"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAuditor produces an audit.Logger that appends each record to a slice as a map
+func newTestAuditor(records *[]map[interface{}]interface{}) *audit.Logger {
+	return audit.NewLogger(log.LoggerFunc(func(keyvals ...interface{}) error {
+		record := make(map[interface{}]interface{}, len(keyvals)/2)
+		for i := 0; i < len(keyvals); i += 2 {
+			record[keyvals[i]] = keyvals[i+1]
+		}
+
+		*records = append(*records, record)
+		return nil
+	}))
+}
+
+func TestRegistryAudit(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		notifier = new(mockNotifier)
+		records  []map[interface{}]interface{}
+
+		existing = testStoreW("https://example.com/existing", time.Now().Add(time.Hour))
+		registry = Registry{m: &monitor{
+			list:     NewList(nil),
+			Notifier: notifier,
+			auditor:  newTestAuditor(&records),
+		}}
+
+		testData = []struct {
+			handler          func(http.ResponseWriter, *http.Request)
+			body             string
+			expectedAction   string
+			expectedResource string
+			expectedOutcome  audit.Outcome
+		}{
+			{registry.UpdateRegistry, `{"config": {"url": "https://example.com/hook"}, "events": ["iot"]}`, RegisterAction, "https://example.com/hook", audit.Success},
+			{registry.UpdateRegistry, `{"config": {"url": "ftp://example.com/hook"}, "events": ["iot"]}`, RegisterAction, "ftp://example.com/hook", audit.Denied},
+			{registry.UpdateRegistry, "this is not JSON", RegisterAction, "/hook", audit.Denied},
+			{registry.UpdateRegistry, `{"config": {"url": "https://example.com/existing"}, "events": ["iot"], "registered_from_address": "10.1.1.1"}`, RegisterAction, "https://example.com/existing", audit.Success},
+			{registry.RotateSecret, `{"url": "https://example.com/existing"}`, RotateSecretAction, "https://example.com/existing", audit.Success},
+			{registry.RotateSecret, `{"url": "https://nosuch.com"}`, RotateSecretAction, "https://nosuch.com", audit.Failure},
+			{registry.RotateSecret, "this is not JSON", RotateSecretAction, "/hook", audit.Denied},
+		}
+	)
+
+	existing.Address = "10.1.1.1"
+	registry.m.list.Update([]W{existing})
+	notifier.On("PublishMessage", mock.AnythingOfType("string")).Times(3)
+	for _, record := range testData {
+		request := httptest.NewRequest("POST", "/hook", strings.NewReader(record.body))
+		request.RemoteAddr = "10.1.1.1:5678"
+		record.handler(httptest.NewRecorder(), request)
+	}
+
+	if assert.Len(records, len(testData)) {
+		for i, record := range testData {
+			assert.Equal("10.1.1.1:5678", records[i][audit.ActorKey])
+			assert.Equal(record.expectedAction, records[i][audit.ActionKey])
+			assert.Equal(record.expectedResource, records[i][audit.ResourceKey])
+			assert.Equal(string(record.expectedOutcome), records[i][audit.OutcomeKey])
+			if record.expectedOutcome == audit.Success {
+				assert.Empty(records[i][audit.ReasonKey])
+			} else {
+				assert.NotEmpty(records[i][audit.ReasonKey])
+			}
+		}
+	}
+
+	notifier.AssertExpectations(t)
+}
+
+func TestRegistryAuditActor(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		records  []map[interface{}]interface{}
+		logged   []interface{}
+		registry = Registry{m: &monitor{
+			list:    NewList(nil),
+			auditor: newTestAuditor(&records),
+			logger: log.LoggerFunc(func(keyvals ...interface{}) error {
+				logged = append(logged, keyvals...)
+				return nil
+			}),
+		}}
+
+		request = httptest.NewRequest("POST", "/hook", strings.NewReader("this is not JSON"))
+	)
+
+	request = request.WithContext(
+		handler.NewContextWithValue(request.Context(), &handler.ContextValues{SatClientID: "authenticated-client"}),
+	)
+
+	registry.UpdateRegistry(httptest.NewRecorder(), request)
+	require.Len(records, 1)
+	assert.Equal("authenticated-client", records[0][audit.ActorKey])
+
+	// events that fail validation are logged rather than silently dropped
+	registry.audit(request, "", "", audit.Success, "")
+	assert.Len(records, 1)
+	assert.Contains(logged, "unable to audit request")
+}
+
+func TestRegistryUpdateForbidden(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		notifier = new(mockNotifier)
+		existing = testStoreW("https://example.com/existing", time.Now().Add(time.Hour))
+		registry = Registry{m: &monitor{list: NewList(nil), Notifier: notifier}}
+		response = httptest.NewRecorder()
+
+		// the payload cannot claim to come from the registering client
+		request = httptest.NewRequest(
+			"POST",
+			"/hook",
+			strings.NewReader(`{"config": {"url": "https://example.com/existing"}, "events": ["iot"], "registered_from_address": "10.1.1.1"}`),
+		)
+	)
+
+	existing.Address = "10.1.1.1"
+	registry.m.list.Update([]W{existing})
+
+	registry.UpdateRegistry(response, request)
+	assert.Equal(http.StatusForbidden, response.Code)
+	notifier.AssertExpectations(t)
+}
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	// TotalCountHeader is the response header carrying the number of registrations that matched a listing
+	// request, before pagination was applied
+	TotalCountHeader = "X-Total-Count"
+
+	partnerIDParameter     = "partner_id"
+	eventParameter         = "event"
+	expiresAfterParameter  = "expires_after"
+	expiresBeforeParameter = "expires_before"
+	offsetParameter        = "offset"
+	limitParameter         = "limit"
+)
+
+// ListOptions scopes a listing of registrations.  The zero value selects every registration.
+type ListOptions struct {
+	// PartnerID selects only hooks registered for this partner
+	PartnerID string
+
+	// Event selects only hooks with an event pattern that matches this event type
+	Event string
+
+	// ExpiresAfter selects only hooks that expire after this time
+	ExpiresAfter time.Time
+
+	// ExpiresBefore selects only hooks that expire before this time
+	ExpiresBefore time.Time
+
+	// Offset is the number of matching hooks to skip
+	Offset int
+
+	// Limit is the maximum number of hooks to return.  If not positive, all matching hooks after Offset are returned.
+	Limit int
+}
+
+// ParseListOptions produces ListOptions from URL query parameters.  Times are expected in RFC3339 format.
+func ParseListOptions(values url.Values) (o ListOptions, err error) {
+	o.PartnerID = values.Get(partnerIDParameter)
+	o.Event = values.Get(eventParameter)
+
+	if o.ExpiresAfter, err = parseTimeParameter(values, expiresAfterParameter); err != nil {
+		return
+	}
+
+	if o.ExpiresBefore, err = parseTimeParameter(values, expiresBeforeParameter); err != nil {
+		return
+	}
+
+	if o.Offset, err = parseIntParameter(values, offsetParameter); err != nil {
+		return
+	}
+
+	o.Limit, err = parseIntParameter(values, limitParameter)
+	return
+}
+
+func parseTimeParameter(values url.Values, name string) (time.Time, error) {
+	value := values.Get(name)
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid %s parameter: %s", name, err)
+	}
+
+	return t, nil
+}
+
+func parseIntParameter(values url.Values, name string) (int, error) {
+	value := values.Get(name)
+	if len(value) == 0 {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("Invalid %s parameter: %s", name, value)
+	}
+
+	return i, nil
+}
+
+// eventMatcher is implemented by Lists which precompile the event patterns of their hooks
+type eventMatcher interface {
+	matchesEvent(index int, event string) bool
+}
+
+// matches tests whether the hook at the given index of a List satisfies the filtering criteria of these options
+func (o ListOptions) matches(l List, index int) bool {
+	w := l.Get(index)
+	if len(o.PartnerID) > 0 && !containsString(w.PartnerIDs, o.PartnerID) {
+		return false
+	}
+
+	if !o.ExpiresAfter.IsZero() && !w.Until.After(o.ExpiresAfter) {
+		return false
+	}
+
+	if !o.ExpiresBefore.IsZero() && !w.Until.Before(o.ExpiresBefore) {
+		return false
+	}
+
+	if len(o.Event) > 0 {
+		if em, ok := l.(eventMatcher); ok {
+			return em.matchesEvent(index, o.Event)
+		}
+
+		for _, pattern := range w.Events {
+			if matched, err := regexp.MatchString(pattern, o.Event); err == nil && matched {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+func containsString(values []string, candidate string) bool {
+	for _, v := range values {
+		if v == candidate {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Select returns the page of hooks in a List that satisfy the given options, along with the
+// total number of matching hooks before pagination.  The returned hooks are copies with their secrets
+// redacted, as listings may be served to clients other than the ones that registered the hooks.
+func Select(l List, o ListOptions) (page []W, total int) {
+	page = []W{}
+	for i := 0; i < l.Len(); i++ {
+		if !o.matches(l, i) {
+			continue
+		}
+
+		if total >= o.Offset && (o.Limit < 1 || len(page) < o.Limit) {
+			page = append(page, l.Get(i).Redacted())
+		}
+
+		total++
+	}
+
+	return
+}
+
+// List returns the registered hooks that satisfy the given options, along with the total number
+// of matching hooks before pagination
+func (r *Registry) List(o ListOptions) ([]W, int) {
+	return Select(r.m.list, o)
+}
@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testListingHooks(now time.Time) []W {
+	hooks := make([]W, 4)
+
+	hooks[0].Config.URL = "http://a.com"
+	hooks[0].Events = []string{"iot"}
+	hooks[0].PartnerIDs = []string{"comcast"}
+	hooks[0].Until = now.Add(time.Minute)
+	hooks[0].Config.Secret = "current"
+	hooks[0].Config.PreviousSecret = "previous"
+
+	hooks[1].Config.URL = "http://b.com"
+	hooks[1].Events = []string{"device-status/.*"}
+	hooks[1].PartnerIDs = []string{"comcast", "sky"}
+	hooks[1].Until = now.Add(time.Hour)
+
+	hooks[2].Config.URL = "http://c.com"
+	hooks[2].Events = []string{".*"}
+	hooks[2].Until = now.Add(2 * time.Hour)
+
+	hooks[3].Config.URL = "http://d.com"
+	hooks[3].Events = []string{"iot", "transaction-status"}
+	hooks[3].PartnerIDs = []string{"sky"}
+	hooks[3].Until = now.Add(3 * time.Hour)
+
+	return hooks
+}
+
+func TestParseListOptions(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	o, err := ParseListOptions(url.Values{})
+	require.NoError(err)
+	assert.Equal(ListOptions{}, o)
+
+	o, err = ParseListOptions(url.Values{
+		"partner_id":     {"comcast"},
+		"event":          {"iot"},
+		"expires_after":  {"2018-01-01T00:00:00Z"},
+		"expires_before": {"2018-02-01T00:00:00Z"},
+		"offset":         {"10"},
+		"limit":          {"5"},
+	})
+
+	require.NoError(err)
+	assert.Equal("comcast", o.PartnerID)
+	assert.Equal("iot", o.Event)
+	assert.Equal(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), o.ExpiresAfter)
+	assert.Equal(time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC), o.ExpiresBefore)
+	assert.Equal(10, o.Offset)
+	assert.Equal(5, o.Limit)
+
+	for _, bad := range []url.Values{
+		{"expires_after": {"yesterday"}},
+		{"expires_before": {"tomorrow"}},
+		{"offset": {"-1"}},
+		{"limit": {"lots"}},
+	} {
+		_, err := ParseListOptions(bad)
+		assert.Error(err, "%v", bad)
+	}
+}
+
+// sliceList is a List that does not precompile event patterns
+type sliceList []W
+
+func (sl sliceList) Len() int     { return len(sl) }
+func (sl sliceList) Get(i int) *W { return &sl[i] }
+
+func TestSelect(t *testing.T) {
+	var (
+		now = time.Now()
+
+		testData = []struct {
+			options       ListOptions
+			expectedURLs  []string
+			expectedTotal int
+		}{
+			{ListOptions{}, []string{"http://a.com", "http://b.com", "http://c.com", "http://d.com"}, 4},
+			{ListOptions{PartnerID: "comcast"}, []string{"http://a.com", "http://b.com"}, 2},
+			{ListOptions{PartnerID: "sky"}, []string{"http://b.com", "http://d.com"}, 2},
+			{ListOptions{PartnerID: "nosuch"}, []string{}, 0},
+			{ListOptions{Event: "iot"}, []string{"http://a.com", "http://c.com", "http://d.com"}, 3},
+			{ListOptions{Event: "device-status/online"}, []string{"http://b.com", "http://c.com"}, 2},
+			{ListOptions{ExpiresAfter: now.Add(30 * time.Minute)}, []string{"http://b.com", "http://c.com", "http://d.com"}, 3},
+			{ListOptions{ExpiresBefore: now.Add(90 * time.Minute)}, []string{"http://a.com", "http://b.com"}, 2},
+			{
+				ListOptions{ExpiresAfter: now.Add(30 * time.Minute), ExpiresBefore: now.Add(150 * time.Minute)},
+				[]string{"http://b.com", "http://c.com"},
+				2,
+			},
+			{ListOptions{PartnerID: "sky", Event: "transaction-status"}, []string{"http://d.com"}, 1},
+			{ListOptions{Limit: 2}, []string{"http://a.com", "http://b.com"}, 4},
+			{ListOptions{Offset: 1, Limit: 2}, []string{"http://b.com", "http://c.com"}, 4},
+			{ListOptions{Offset: 3, Limit: 2}, []string{"http://d.com"}, 4},
+			{ListOptions{Offset: 10}, []string{}, 4},
+			{ListOptions{Event: "iot", Offset: 1}, []string{"http://c.com", "http://d.com"}, 3},
+		}
+	)
+
+	for name, list := range map[string]List{"Compiled": NewList(testListingHooks(now)), "Uncompiled": sliceList(testListingHooks(now))} {
+		t.Run(name, func(t *testing.T) {
+			for i, record := range testData {
+				t.Logf("%d: %#v", i, record.options)
+
+				var (
+					assert      = assert.New(t)
+					page, total = Select(list, record.options)
+					actualURLs  = []string{}
+				)
+
+				for _, w := range page {
+					actualURLs = append(actualURLs, w.ID())
+					assert.Empty(w.Config.Secret)
+					assert.Empty(w.Config.PreviousSecret)
+				}
+
+				assert.Equal(record.expectedURLs, actualURLs)
+				assert.Equal(record.expectedTotal, total)
+			}
+		})
+	}
+}
+
+func TestGetRegistry(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		registry = Registry{m: &monitor{list: NewList(testListingHooks(time.Now()))}}
+	)
+
+	response := httptest.NewRecorder()
+	registry.GetRegistry(response, httptest.NewRequest("GET", "/hooks?partner_id=comcast&limit=1", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("2", response.HeaderMap.Get(TotalCountHeader))
+
+	var hooks []W
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &hooks))
+	require.Len(hooks, 1)
+	assert.Equal("http://a.com", hooks[0].ID())
+	assert.NotContains(response.Body.String(), "secret")
+
+	// redaction applies only to the listing, not to the registered hook
+	assert.Equal("current", registry.m.list.Get(0).Config.Secret)
+
+	response = httptest.NewRecorder()
+	registry.GetRegistry(response, httptest.NewRequest("GET", "/hooks", nil))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("4", response.HeaderMap.Get(TotalCountHeader))
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &hooks))
+	assert.Len(hooks, 4)
+
+	response = httptest.NewRecorder()
+	registry.GetRegistry(response, httptest.NewRequest("GET", "/hooks?limit=x", nil))
+	assert.Equal(http.StatusBadRequest, response.Code)
+}
@@ -3,16 +3,42 @@ package webhook
 import (
 	"github.com/Comcast/webpa-common/xmetrics"
 	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/provider"
 )
 
 const (
 	ListSize                     = "webhook_list_size_value"
 	NotificationUnmarshallFailed = "notification_unmarshall_failed_count"
+	ExpiredCount                 = "webhook_expired_count"
+	RenewedCount                 = "webhook_renewed_count"
+	TimeToExpiry                 = "webhook_time_to_expiry_seconds"
+	StoreErrorCount              = "webhook_store_error_count"
+
+	// PartnerLabel is the label for the partner associated with a webhook metric
+	PartnerLabel = "partner"
+
+	// NoPartner is the PartnerLabel value used for hooks that have no partner ids
+	NoPartner = "none"
+
+	// OtherPartner is the PartnerLabel value used for partner ids that are not configured as metric partners
+	OtherPartner = "other"
+
+	// OperationLabel is the label for the Store operation associated with a webhook metric
+	OperationLabel = "operation"
+
+	// ListOperation, PutOperation, and PruneOperation are the values of OperationLabel
+	ListOperation  = "list"
+	PutOperation   = "put"
+	PruneOperation = "prune"
 )
 
 type WebhookMetrics struct {
 	ListSize                     metrics.Gauge
 	NotificationUnmarshallFailed metrics.Counter
+	Expired                      metrics.Counter
+	Renewed                      metrics.Counter
+	TimeToExpiry                 metrics.Histogram
+	StoreErrors                  metrics.Counter
 }
 
 // Metrics returns the defined metrics as a list
@@ -28,23 +54,59 @@ func Metrics() []xmetrics.Metric {
 			Help: "Count of the number notification messages that failed to unmarshall",
 			Type: "counter",
 		},
+		xmetrics.Metric{
+			Name:       ExpiredCount,
+			Help:       "Count of the registrations that have expired, by partner",
+			Type:       "counter",
+			LabelNames: []string{PartnerLabel},
+		},
+		xmetrics.Metric{
+			Name:       RenewedCount,
+			Help:       "Count of the registrations that have been renewed, by partner",
+			Type:       "counter",
+			LabelNames: []string{PartnerLabel},
+		},
+		xmetrics.Metric{
+			Name:    TimeToExpiry,
+			Help:    "The time remaining before expiry when a registration is created or renewed",
+			Type:    "histogram",
+			Buckets: []float64{60, 300, 900, 3600, 21600, 86400, 604800},
+		},
+		xmetrics.Metric{
+			Name:       StoreErrorCount,
+			Help:       "Count of the webhook Store operations that failed, by operation",
+			Type:       "counter",
+			LabelNames: []string{OperationLabel},
+		},
 	}
 }
 
 // ApplyMetricsData is used for setting the counter values on the WebhookMetrics
-// when stored and accessing for later use
-func ApplyMetricsData(registry xmetrics.Registry) (m WebhookMetrics) {
+// when stored and accessing for later use.  Any go-kit provider may be used, such as
+// an xmetrics.Registry.  If p is nil, the metrics are discarded.
+func ApplyMetricsData(p provider.Provider) (m WebhookMetrics) {
+	if p == nil {
+		p = provider.NewDiscardProvider()
+	}
+
 	for _, metric := range Metrics() {
 		switch metric.Name {
 		case ListSize:
-			m.ListSize = registry.NewGauge(metric.Name)
+			m.ListSize = p.NewGauge(metric.Name)
 			m.ListSize.Add(0.0)
 		case NotificationUnmarshallFailed:
-			m.NotificationUnmarshallFailed = registry.NewCounter(metric.Name)
+			m.NotificationUnmarshallFailed = p.NewCounter(metric.Name)
 			m.NotificationUnmarshallFailed.Add(0.0)
+		case ExpiredCount:
+			m.Expired = p.NewCounter(metric.Name)
+		case RenewedCount:
+			m.Renewed = p.NewCounter(metric.Name)
+		case TimeToExpiry:
+			m.TimeToExpiry = p.NewHistogram(metric.Name, 0)
+		case StoreErrorCount:
+			m.StoreErrors = p.NewCounter(metric.Name)
 		}
 	}
-	
+
 	return
 }
-
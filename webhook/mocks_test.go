@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	AWS "github.com/Comcast/webpa-common/webhook/aws"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockConsulKV struct {
+	mock.Mock
+}
+
+func (m *mockConsulKV) Put(p *api.KVPair, q *api.WriteOptions) (*api.WriteMeta, error) {
+	arguments := m.Called(p, q)
+	first, _ := arguments.Get(0).(*api.WriteMeta)
+	return first, arguments.Error(1)
+}
+
+func (m *mockConsulKV) Delete(key string, w *api.WriteOptions) (*api.WriteMeta, error) {
+	arguments := m.Called(key, w)
+	first, _ := arguments.Get(0).(*api.WriteMeta)
+	return first, arguments.Error(1)
+}
+
+func (m *mockConsulKV) List(prefix string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error) {
+	var (
+		arguments = m.Called(prefix, q)
+		first, _  = arguments.Get(0).(api.KVPairs)
+		second, _ = arguments.Get(1).(*api.QueryMeta)
+	)
+
+	return first, second, arguments.Error(2)
+}
+
+// mockNotifier mocks only the PublishMessage method of AWS.Notifier.  Calling any other method panics.
+type mockNotifier struct {
+	AWS.Notifier
+	mock.Mock
+}
+
+func (m *mockNotifier) PublishMessage(message string) {
+	m.Called(message)
+}
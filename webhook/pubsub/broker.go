@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// PubSubKey is the Viper subkey used to configure a Broker
+	PubSubKey = "pubsub"
+
+	// NATSType is the configured type for a NATS Broker
+	NATSType = "nats"
+
+	// KafkaType is the configured type for a Kafka Broker
+	KafkaType = "kafka"
+)
+
+var (
+	ErrNoHandler         = errors.New("A message handler is required")
+	ErrBrokerClosed      = errors.New("The broker has been closed")
+	ErrAlreadySubscribed = errors.New("The broker already has a subscriber")
+)
+
+// Handler receives each message published to a Broker's topic, including messages published by this node
+type Handler func([]byte)
+
+// Broker propagates messages to every node in a cluster.  Implementations are expected to deliver each published
+// message to the subscriber on every node, which is how webhook registrations are shared.
+type Broker interface {
+	// Publish sends a message to every subscriber of the topic
+	Publish([]byte) error
+
+	// Subscribe registers the handler for messages on the topic.  A Broker supports only one subscriber.
+	Subscribe(Handler) error
+
+	// Close releases any resources associated with this Broker
+	Close() error
+}
+
+// Config is the external configuration for a Broker
+type Config struct {
+	// Type is the kind of Broker, e.g. NATSType or KafkaType
+	Type string `json:"type"`
+
+	// NATS is the NATS configuration, used when Type is NATSType
+	NATS NATSConfig `json:"nats"`
+
+	// Kafka is the Kafka configuration, used when Type is KafkaType
+	Kafka KafkaConfig `json:"kafka"`
+}
+
+// New creates a Broker from a Viper environment.  If v is nil or does not contain the PubSubKey,
+// this function returns a nil Broker and a nil error, in which case the caller should fall back to
+// some other means of propagation such as AWS SNS.
+func New(v *viper.Viper) (Broker, error) {
+	if v == nil || v.Sub(PubSubKey) == nil {
+		return nil, nil
+	}
+
+	var c Config
+	if err := v.Sub(PubSubKey).Unmarshal(&c); err != nil {
+		return nil, err
+	}
+
+	return c.NewBroker()
+}
+
+// NewBroker creates the Broker described by this configuration
+func (c Config) NewBroker() (Broker, error) {
+	switch c.Type {
+	case NATSType:
+		return c.NATS.NewBroker()
+	case KafkaType:
+		return c.Kafka.NewBroker()
+	default:
+		return nil, fmt.Errorf("Unsupported pubsub type: %s", c.Type)
+	}
+}
@@ -0,0 +1,35 @@
+package pubsub
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+
+	broker, err := New(nil)
+	assert.Nil(broker)
+	assert.NoError(err)
+
+	v := viper.New()
+	broker, err = New(v)
+	assert.Nil(broker)
+	assert.NoError(err)
+
+	v.SetConfigType("json")
+	assert.NoError(v.ReadConfig(strings.NewReader(`{"pubsub": {"type": "nosuch"}}`)))
+	broker, err = New(v)
+	assert.Nil(broker)
+	assert.Error(err)
+
+	v = viper.New()
+	v.SetConfigType("json")
+	assert.NoError(v.ReadConfig(strings.NewReader(`{"pubsub": {"type": "kafka", "kafka": {"topic": "test"}}}`)))
+	broker, err = New(v)
+	assert.Nil(broker)
+	assert.Error(err)
+}
@@ -0,0 +1,167 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// DefaultKafkaTopic is the topic used when none is configured
+const DefaultKafkaTopic = "webhooks"
+
+// KafkaConfig is the configuration for a Kafka Broker
+type KafkaConfig struct {
+	// Brokers is the list of Kafka broker addresses.  This field is required.
+	Brokers []string `json:"brokers"`
+
+	// Topic is the Kafka topic that messages are published to.  If unset, DefaultKafkaTopic is used.
+	Topic string `json:"topic"`
+}
+
+// NewBroker connects to Kafka and returns a Broker.  Every node consumes every partition of the topic
+// from the newest offset, so that each message is seen by all nodes rather than load balanced across them.
+func (c KafkaConfig) NewBroker() (Broker, error) {
+	if len(c.Brokers) == 0 {
+		return nil, errors.New("At least one Kafka broker is required")
+	}
+
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(c.Brokers, config)
+	if err != nil {
+		return nil, err
+	}
+
+	consumer, err := sarama.NewConsumer(c.Brokers, config)
+	if err != nil {
+		producer.Close()
+		return nil, err
+	}
+
+	return NewKafkaBroker(producer, consumer, c.Topic), nil
+}
+
+// KafkaProducer is the subset of sarama.SyncProducer used by a Kafka Broker
+type KafkaProducer interface {
+	SendMessage(*sarama.ProducerMessage) (int32, int64, error)
+	Close() error
+}
+
+// KafkaConsumer is the subset of sarama.Consumer used by a Kafka Broker
+type KafkaConsumer interface {
+	Partitions(topic string) ([]int32, error)
+	ConsumePartition(topic string, partition int32, offset int64) (sarama.PartitionConsumer, error)
+	Close() error
+}
+
+type kafkaBroker struct {
+	producer KafkaProducer
+	consumer KafkaConsumer
+	topic    string
+
+	lock       sync.Mutex
+	partitions []sarama.PartitionConsumer
+	subscribed bool
+	closed     bool
+	waitGroup  sync.WaitGroup
+}
+
+// NewKafkaBroker returns a Broker that uses an existing producer and consumer.  If topic is empty,
+// DefaultKafkaTopic is used.  Closing the returned Broker closes both the producer and the consumer.
+func NewKafkaBroker(producer KafkaProducer, consumer KafkaConsumer, topic string) Broker {
+	if len(topic) == 0 {
+		topic = DefaultKafkaTopic
+	}
+
+	return &kafkaBroker{
+		producer: producer,
+		consumer: consumer,
+		topic:    topic,
+	}
+}
+
+func (kb *kafkaBroker) Publish(message []byte) error {
+	kb.lock.Lock()
+	closed := kb.closed
+	kb.lock.Unlock()
+
+	if closed {
+		return ErrBrokerClosed
+	}
+
+	_, _, err := kb.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: kb.topic,
+		Value: sarama.ByteEncoder(message),
+	})
+
+	return err
+}
+
+func (kb *kafkaBroker) Subscribe(h Handler) error {
+	if h == nil {
+		return ErrNoHandler
+	}
+
+	kb.lock.Lock()
+	defer kb.lock.Unlock()
+
+	if kb.closed {
+		return ErrBrokerClosed
+	} else if kb.subscribed {
+		return ErrAlreadySubscribed
+	}
+
+	partitions, err := kb.consumer.Partitions(kb.topic)
+	if err != nil {
+		return err
+	}
+
+	for _, partition := range partitions {
+		pc, err := kb.consumer.ConsumePartition(kb.topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			for _, started := range kb.partitions {
+				started.AsyncClose()
+			}
+
+			kb.partitions = nil
+			return err
+		}
+
+		kb.partitions = append(kb.partitions, pc)
+		kb.waitGroup.Add(1)
+		go func(pc sarama.PartitionConsumer) {
+			defer kb.waitGroup.Done()
+			for m := range pc.Messages() {
+				h(m.Value)
+			}
+		}(pc)
+	}
+
+	kb.subscribed = true
+	return nil
+}
+
+func (kb *kafkaBroker) Close() error {
+	kb.lock.Lock()
+	if kb.closed {
+		kb.lock.Unlock()
+		return nil
+	}
+
+	kb.closed = true
+	for _, pc := range kb.partitions {
+		pc.AsyncClose()
+	}
+
+	kb.lock.Unlock()
+	kb.waitGroup.Wait()
+
+	consumerErr := kb.consumer.Close()
+	if err := kb.producer.Close(); err != nil {
+		return err
+	}
+
+	return consumerErr
+}
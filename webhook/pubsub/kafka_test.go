@@ -0,0 +1,131 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafkaConfigNoBrokers(t *testing.T) {
+	assert := assert.New(t)
+	broker, err := KafkaConfig{}.NewBroker()
+	assert.Nil(broker)
+	assert.Error(err)
+}
+
+func TestNewKafkaBroker(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(DefaultKafkaTopic, NewKafkaBroker(new(mockKafkaProducer), new(mockKafkaConsumer), "").(*kafkaBroker).topic)
+	assert.Equal("custom", NewKafkaBroker(new(mockKafkaProducer), new(mockKafkaConsumer), "custom").(*kafkaBroker).topic)
+}
+
+func testKafkaBrokerPublish(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		producer = new(mockKafkaProducer)
+		consumer = new(mockKafkaConsumer)
+		broker   = NewKafkaBroker(producer, consumer, "test")
+		sent     *sarama.ProducerMessage
+	)
+
+	producer.On("SendMessage", mock.AnythingOfType("*sarama.ProducerMessage")).
+		Return(0, 1, nil).
+		Run(func(arguments mock.Arguments) { sent = arguments.Get(0).(*sarama.ProducerMessage) }).
+		Once()
+
+	assert.NoError(broker.Publish([]byte("hello")))
+	require.NotNil(sent)
+	assert.Equal("test", sent.Topic)
+	assert.Equal(sarama.ByteEncoder("hello"), sent.Value)
+
+	producer.On("Close").Return(nil).Once()
+	consumer.On("Close").Return(nil).Once()
+	assert.NoError(broker.Close())
+	assert.NoError(broker.Close())
+	assert.Equal(ErrBrokerClosed, broker.Publish([]byte("closed")))
+
+	producer.AssertExpectations(t)
+	consumer.AssertExpectations(t)
+}
+
+func testKafkaBrokerSubscribe(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		producer = new(mockKafkaProducer)
+		consumer = new(mockKafkaConsumer)
+		broker   = NewKafkaBroker(producer, consumer, "test")
+
+		partition0 = newTestPartitionConsumer()
+		partition1 = newTestPartitionConsumer()
+		received   = make(chan string, 2)
+	)
+
+	assert.Equal(ErrNoHandler, broker.Subscribe(nil))
+
+	consumer.On("Partitions", "test").Return([]int32{0, 1}, nil).Once()
+	consumer.On("ConsumePartition", "test", int32(0), sarama.OffsetNewest).Return(partition0, nil).Once()
+	consumer.On("ConsumePartition", "test", int32(1), sarama.OffsetNewest).Return(partition1, nil).Once()
+
+	assert.NoError(broker.Subscribe(func(m []byte) { received <- string(m) }))
+	assert.Equal(ErrAlreadySubscribed, broker.Subscribe(func([]byte) {}))
+
+	partition0.messages <- &sarama.ConsumerMessage{Value: []byte("zero")}
+	partition1.messages <- &sarama.ConsumerMessage{Value: []byte("one")}
+
+	var messages []string
+	for len(messages) < 2 {
+		select {
+		case m := <-received:
+			messages = append(messages, m)
+		case <-time.After(5 * time.Second):
+			assert.Fail("Messages were not received")
+			return
+		}
+	}
+
+	assert.ElementsMatch([]string{"zero", "one"}, messages)
+
+	producer.On("Close").Return(nil).Once()
+	consumer.On("Close").Return(nil).Once()
+	assert.NoError(broker.Close())
+
+	<-partition0.closed
+	<-partition1.closed
+	producer.AssertExpectations(t)
+	consumer.AssertExpectations(t)
+}
+
+func testKafkaBrokerSubscribeError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		producer      = new(mockKafkaProducer)
+		consumer      = new(mockKafkaConsumer)
+		broker        = NewKafkaBroker(producer, consumer, "test")
+		partition0    = newTestPartitionConsumer()
+		expectedError = errors.New("expected")
+	)
+
+	consumer.On("Partitions", "test").Return(nil, expectedError).Once()
+	assert.Equal(expectedError, broker.Subscribe(func([]byte) {}))
+
+	consumer.On("Partitions", "test").Return([]int32{0, 1}, nil).Once()
+	consumer.On("ConsumePartition", "test", int32(0), sarama.OffsetNewest).Return(partition0, nil).Once()
+	consumer.On("ConsumePartition", "test", int32(1), sarama.OffsetNewest).Return(nil, expectedError).Once()
+	assert.Equal(expectedError, broker.Subscribe(func([]byte) {}))
+
+	// the partition consumer that was started must have been closed
+	<-partition0.closed
+	consumer.AssertExpectations(t)
+}
+
+func TestKafkaBroker(t *testing.T) {
+	t.Run("Publish", testKafkaBrokerPublish)
+	t.Run("Subscribe", testKafkaBrokerSubscribe)
+	t.Run("SubscribeError", testKafkaBrokerSubscribeError)
+}
@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/nats-io/go-nats"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockNATSConn struct {
+	mock.Mock
+}
+
+func (m *mockNATSConn) Publish(subject string, data []byte) error {
+	return m.Called(subject, data).Error(0)
+}
+
+func (m *mockNATSConn) Subscribe(subject string, handler nats.MsgHandler) (*nats.Subscription, error) {
+	arguments := m.Called(subject, handler)
+	first, _ := arguments.Get(0).(*nats.Subscription)
+	return first, arguments.Error(1)
+}
+
+func (m *mockNATSConn) Close() {
+	m.Called()
+}
+
+type mockKafkaProducer struct {
+	mock.Mock
+}
+
+func (m *mockKafkaProducer) SendMessage(message *sarama.ProducerMessage) (int32, int64, error) {
+	arguments := m.Called(message)
+	return int32(arguments.Int(0)), int64(arguments.Int(1)), arguments.Error(2)
+}
+
+func (m *mockKafkaProducer) Close() error {
+	return m.Called().Error(0)
+}
+
+type mockKafkaConsumer struct {
+	mock.Mock
+}
+
+func (m *mockKafkaConsumer) Partitions(topic string) ([]int32, error) {
+	arguments := m.Called(topic)
+	first, _ := arguments.Get(0).([]int32)
+	return first, arguments.Error(1)
+}
+
+func (m *mockKafkaConsumer) ConsumePartition(topic string, partition int32, offset int64) (sarama.PartitionConsumer, error) {
+	arguments := m.Called(topic, partition, offset)
+	first, _ := arguments.Get(0).(sarama.PartitionConsumer)
+	return first, arguments.Error(1)
+}
+
+func (m *mockKafkaConsumer) Close() error {
+	return m.Called().Error(0)
+}
+
+// testPartitionConsumer is a sarama.PartitionConsumer backed by a channel under the control of a test
+type testPartitionConsumer struct {
+	sarama.PartitionConsumer
+	messages chan *sarama.ConsumerMessage
+	closed   chan struct{}
+}
+
+func newTestPartitionConsumer() *testPartitionConsumer {
+	return &testPartitionConsumer{
+		messages: make(chan *sarama.ConsumerMessage, 10),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (pc *testPartitionConsumer) Messages() <-chan *sarama.ConsumerMessage {
+	return pc.messages
+}
+
+func (pc *testPartitionConsumer) AsyncClose() {
+	close(pc.closed)
+	close(pc.messages)
+}
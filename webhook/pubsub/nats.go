@@ -0,0 +1,119 @@
+package pubsub
+
+import (
+	"sync"
+
+	"github.com/nats-io/go-nats"
+)
+
+// DefaultNATSSubject is the subject used when none is configured
+const DefaultNATSSubject = "webhooks"
+
+// NATSConfig is the configuration for a NATS Broker
+type NATSConfig struct {
+	// URL is the NATS server URL.  If unset, nats.DefaultURL is used.
+	URL string `json:"url"`
+
+	// Subject is the NATS subject that messages are published to.  If unset, DefaultNATSSubject is used.
+	Subject string `json:"subject"`
+}
+
+// NewBroker connects to NATS and returns a Broker using that connection
+func (c NATSConfig) NewBroker() (Broker, error) {
+	url := c.URL
+	if len(url) == 0 {
+		url = nats.DefaultURL
+	}
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNATSBroker(conn, c.Subject), nil
+}
+
+// NATSConn is the subset of *nats.Conn used by a NATS Broker
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+	Subscribe(subject string, handler nats.MsgHandler) (*nats.Subscription, error)
+	Close()
+}
+
+type natsBroker struct {
+	conn    NATSConn
+	subject string
+
+	lock         sync.Mutex
+	subscription *nats.Subscription
+	subscribed   bool
+	closed       bool
+}
+
+// NewNATSBroker returns a Broker that uses an existing NATS connection.  If subject is empty,
+// DefaultNATSSubject is used.  Closing the returned Broker also closes the connection.
+func NewNATSBroker(conn NATSConn, subject string) Broker {
+	if len(subject) == 0 {
+		subject = DefaultNATSSubject
+	}
+
+	return &natsBroker{
+		conn:    conn,
+		subject: subject,
+	}
+}
+
+func (nb *natsBroker) Publish(message []byte) error {
+	nb.lock.Lock()
+	closed := nb.closed
+	nb.lock.Unlock()
+
+	if closed {
+		return ErrBrokerClosed
+	}
+
+	return nb.conn.Publish(nb.subject, message)
+}
+
+func (nb *natsBroker) Subscribe(h Handler) error {
+	if h == nil {
+		return ErrNoHandler
+	}
+
+	nb.lock.Lock()
+	defer nb.lock.Unlock()
+
+	if nb.closed {
+		return ErrBrokerClosed
+	} else if nb.subscribed {
+		return ErrAlreadySubscribed
+	}
+
+	subscription, err := nb.conn.Subscribe(nb.subject, func(m *nats.Msg) { h(m.Data) })
+	if err != nil {
+		return err
+	}
+
+	nb.subscription = subscription
+	nb.subscribed = true
+	return nil
+}
+
+func (nb *natsBroker) Close() error {
+	nb.lock.Lock()
+	defer nb.lock.Unlock()
+
+	if nb.closed {
+		return nil
+	}
+
+	nb.closed = true
+
+	var err error
+	if nb.subscription != nil {
+		err = nb.subscription.Unsubscribe()
+	}
+
+	nb.conn.Close()
+	return err
+}
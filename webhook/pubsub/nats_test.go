@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nats-io/go-nats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNATSBroker(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(DefaultNATSSubject, NewNATSBroker(new(mockNATSConn), "").(*natsBroker).subject)
+	assert.Equal("custom", NewNATSBroker(new(mockNATSConn), "custom").(*natsBroker).subject)
+}
+
+func testNATSBrokerPublish(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		conn          = new(mockNATSConn)
+		broker        = NewNATSBroker(conn, "test")
+		expectedError = errors.New("expected")
+	)
+
+	conn.On("Publish", "test", []byte("hello")).Return(nil).Once()
+	conn.On("Publish", "test", []byte("fail")).Return(expectedError).Once()
+	conn.On("Close").Once()
+
+	assert.NoError(broker.Publish([]byte("hello")))
+	assert.Equal(expectedError, broker.Publish([]byte("fail")))
+	assert.NoError(broker.Close())
+	assert.NoError(broker.Close())
+	assert.Equal(ErrBrokerClosed, broker.Publish([]byte("closed")))
+
+	conn.AssertExpectations(t)
+}
+
+func testNATSBrokerSubscribe(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		conn     = new(mockNATSConn)
+		broker   = NewNATSBroker(conn, "test")
+		handler  nats.MsgHandler
+		received [][]byte
+	)
+
+	assert.Equal(ErrNoHandler, broker.Subscribe(nil))
+
+	conn.On("Subscribe", "test", mock.AnythingOfType("nats.MsgHandler")).
+		Return(nil, nil).
+		Run(func(arguments mock.Arguments) { handler = arguments.Get(1).(nats.MsgHandler) }).
+		Once()
+
+	assert.NoError(broker.Subscribe(func(m []byte) { received = append(received, m) }))
+	require.NotNil(handler)
+	assert.Equal(ErrAlreadySubscribed, broker.Subscribe(func([]byte) {}))
+
+	handler(&nats.Msg{Data: []byte("hello")})
+	assert.Equal([][]byte{[]byte("hello")}, received)
+
+	conn.On("Close").Once()
+	assert.NoError(broker.Close())
+	assert.Equal(ErrBrokerClosed, broker.Subscribe(func([]byte) {}))
+	conn.AssertExpectations(t)
+}
+
+func testNATSBrokerSubscribeError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		conn          = new(mockNATSConn)
+		broker        = NewNATSBroker(conn, "test")
+		expectedError = errors.New("expected")
+	)
+
+	conn.On("Subscribe", "test", mock.AnythingOfType("nats.MsgHandler")).Return(nil, expectedError).Once()
+	assert.Equal(expectedError, broker.Subscribe(func([]byte) {}))
+	conn.AssertExpectations(t)
+}
+
+func TestNATSBroker(t *testing.T) {
+	t.Run("Publish", testNATSBrokerPublish)
+	t.Run("Subscribe", testNATSBrokerSubscribe)
+	t.Run("SubscribeError", testNATSBrokerSubscribeError)
+}
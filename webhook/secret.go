@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Comcast/webpa-common/logging/audit"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA1 of the message body when a hook has a secret.  During a
+	// rotation, this header appears twice:  once for the current secret and once for the previous secret.
+	SignatureHeader = "X-Webpa-Signature"
+
+	// signaturePrefix identifies the hash algorithm in a signature value
+	signaturePrefix = "sha1="
+
+	// generatedSecretLength is the number of random bytes in a secret created by NewSecret
+	generatedSecretLength = 32
+)
+
+// Signature computes the value of the SignatureHeader for a given secret and body
+func Signature(secret string, body []byte) string {
+	h := hmac.New(sha1.New, []byte(secret))
+	h.Write(body)
+	return signaturePrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// Signatures computes every signature that should accompany a message delivered to the given hook.
+// The signature for the current secret always comes first.  If the hook has no secrets, this function
+// returns an empty slice.
+func Signatures(w W, body []byte) []string {
+	var signatures []string
+	if len(w.Config.Secret) > 0 {
+		signatures = append(signatures, Signature(w.Config.Secret, body))
+	}
+
+	if len(w.Config.PreviousSecret) > 0 {
+		signatures = append(signatures, Signature(w.Config.PreviousSecret, body))
+	}
+
+	return signatures
+}
+
+// VerifySignature checks a signature against both the current and previous secrets of a hook.  This allows
+// a receiver to accept messages signed with either key while a rotation is in progress.  A hook with no secrets
+// never verifies a signature.
+func VerifySignature(w W, body []byte, signature string) bool {
+	for _, expected := range Signatures(w, body) {
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewSecret generates a random secret suitable for signing webhook messages
+func NewSecret() (string, error) {
+	raw := make([]byte, generatedSecretLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// RotateSecret makes newSecret the current secret for this hook.  The existing secret is retained
+// as the previous secret, so that messages are signed with both until the previous secret is cleared.
+func (w *W) RotateSecret(newSecret string) {
+	w.Config.PreviousSecret = w.Config.Secret
+	w.Config.Secret = newSecret
+}
+
+// Redacted returns a copy of this hook with its current and previous secrets removed.  Use this
+// whenever a hook is exposed to anyone other than the client that registered it.
+func (w W) Redacted() W {
+	w.Config.Secret = ""
+	w.Config.PreviousSecret = ""
+	return w
+}
+
+// rotateSecretRequest is the body of a secret rotation request
+type rotateSecretRequest struct {
+	// URL identifies the hook to rotate
+	URL string `json:"url"`
+
+	// Secret is the new secret.  If empty, a secret is generated.
+	Secret string `json:"secret,omitempty"`
+
+	// DropPrevious clears the previous secret instead of rotating, which completes a rotation
+	DropPrevious bool `json:"drop_previous,omitempty"`
+}
+
+// RotateSecret is an api call that rotates the secret of a registered listener.  Only the client that registered
+// the listener may rotate its secret.  The response contains the new secret, which is generated if the request
+// does not supply one.  Messages are signed with both the new and old
+// secrets until a subsequent request with drop_previous set completes the rotation.
+func (r *Registry) RotateSecret(rw http.ResponseWriter, req *http.Request) {
+	payload, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		r.audit(req, RotateSecretAction, "", audit.Denied, err.Error())
+		jsonResponse(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var rotate rotateSecretRequest
+	if err := json.Unmarshal(payload, &rotate); err != nil {
+		r.audit(req, RotateSecretAction, "", audit.Denied, err.Error())
+		jsonResponse(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w := r.find(rotate.URL)
+	if w == nil {
+		r.audit(req, RotateSecretAction, rotate.URL, audit.Failure, "No such webhook")
+		jsonResponse(rw, http.StatusNotFound, "No such webhook")
+		return
+	}
+
+	if !authorized(req, w) {
+		r.audit(req, RotateSecretAction, w.ID(), audit.Denied, "Not the registering client")
+		jsonResponse(rw, http.StatusForbidden, "Not the registering client")
+		return
+	}
+
+	if rotate.DropPrevious {
+		w.Config.PreviousSecret = ""
+	} else {
+		if len(rotate.Secret) == 0 {
+			if rotate.Secret, err = NewSecret(); err != nil {
+				r.audit(req, RotateSecretAction, w.ID(), audit.Failure, err.Error())
+				jsonResponse(rw, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		w.RotateSecret(rotate.Secret)
+	}
+
+	s, err := json.Marshal(w)
+	if err != nil {
+		r.audit(req, RotateSecretAction, w.ID(), audit.Failure, err.Error())
+		jsonResponse(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := r.m.publish(s); err != nil {
+		r.audit(req, RotateSecretAction, w.ID(), audit.Failure, err.Error())
+		jsonResponse(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	r.audit(req, RotateSecretAction, w.ID(), audit.Success, "")
+	body, _ := json.Marshal(map[string]string{"message": "Success", "secret": w.Config.Secret})
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
@@ -0,0 +1,226 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/secure/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("sha1=5112055c05f944f85755efc5cd8970e194e9f45b", Signature("secret", []byte("hello")))
+	assert.NotEqual(Signature("secret", []byte("hello")), Signature("other", []byte("hello")))
+}
+
+func TestSignatures(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		body   = []byte("hello")
+		w      W
+	)
+
+	assert.Empty(Signatures(w, body))
+
+	w.Config.Secret = "current"
+	assert.Equal([]string{Signature("current", body)}, Signatures(w, body))
+
+	w.Config.PreviousSecret = "previous"
+	assert.Equal([]string{Signature("current", body), Signature("previous", body)}, Signatures(w, body))
+}
+
+func TestVerifySignature(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		body   = []byte("hello")
+		w      W
+	)
+
+	assert.False(VerifySignature(w, body, ""))
+	assert.False(VerifySignature(w, body, Signature("", body)))
+
+	w.Config.Secret = "old"
+	assert.True(VerifySignature(w, body, Signature("old", body)))
+
+	w.RotateSecret("new")
+	assert.Equal("new", w.Config.Secret)
+	assert.Equal("old", w.Config.PreviousSecret)
+	assert.True(VerifySignature(w, body, Signature("new", body)))
+	assert.True(VerifySignature(w, body, Signature("old", body)))
+	assert.False(VerifySignature(w, body, Signature("other", body)))
+	assert.False(VerifySignature(w, []byte("tampered"), Signature("new", body)))
+}
+
+func TestRedacted(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w      W
+	)
+
+	w.Config.URL = "http://example.com"
+	w.Config.Secret = "current"
+	w.Config.PreviousSecret = "previous"
+
+	redacted := w.Redacted()
+	assert.Equal("http://example.com", redacted.ID())
+	assert.Empty(redacted.Config.Secret)
+	assert.Empty(redacted.Config.PreviousSecret)
+	assert.Equal("current", w.Config.Secret)
+	assert.Equal("previous", w.Config.PreviousSecret)
+}
+
+func TestNewSecret(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	first, err := NewSecret()
+	require.NoError(err)
+	second, err := NewSecret()
+	require.NoError(err)
+
+	assert.NotEmpty(first)
+	assert.NotEqual(first, second)
+}
+
+func testRegistryRotateSecret(t *testing.T, body string, expectedSecret, expectedPrevious string) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		notifier = new(mockNotifier)
+
+		existing = testStoreW("http://example.com/hook", time.Now().Add(time.Hour))
+		registry = Registry{m: &monitor{list: NewList(nil), Notifier: notifier}}
+
+		published string
+		response  = httptest.NewRecorder()
+	)
+
+	existing.Config.Secret = "old"
+	existing.Config.PreviousSecret = "older"
+	existing.Address = "192.0.2.1"
+	registry.m.list.Update([]W{existing})
+
+	notifier.On("PublishMessage", mock.AnythingOfType("string")).
+		Run(func(arguments mock.Arguments) { published = arguments.String(0) }).
+		Once()
+
+	registry.RotateSecret(response, httptest.NewRequest("POST", "/hook/secret", strings.NewReader(body)))
+	assert.Equal(http.StatusOK, response.Code)
+
+	var result map[string]string
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &result))
+
+	var w W
+	require.NoError(json.Unmarshal([]byte(published), &w))
+	assert.Equal("http://example.com/hook", w.ID())
+	assert.Equal(result["secret"], w.Config.Secret)
+	assert.Equal(expectedPrevious, w.Config.PreviousSecret)
+
+	if len(expectedSecret) > 0 {
+		assert.Equal(expectedSecret, w.Config.Secret)
+	} else {
+		assert.NotEmpty(w.Config.Secret)
+		assert.NotEqual("old", w.Config.Secret)
+	}
+
+	// the registered hook is untouched until the notification arrives
+	assert.Equal("old", registry.m.list.Get(0).Config.Secret)
+	notifier.AssertExpectations(t)
+}
+
+func testRegistryRotateSecretNotFound(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		registry = Registry{m: &monitor{list: NewList(nil), Notifier: new(mockNotifier)}}
+		response = httptest.NewRecorder()
+	)
+
+	registry.RotateSecret(response, httptest.NewRequest("POST", "/hook/secret", strings.NewReader(`{"url": "http://nosuch.com"}`)))
+	assert.Equal(http.StatusNotFound, response.Code)
+}
+
+func testRegistryRotateSecretBadRequest(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		registry = Registry{m: &monitor{list: NewList(nil), Notifier: new(mockNotifier)}}
+		response = httptest.NewRecorder()
+	)
+
+	registry.RotateSecret(response, httptest.NewRequest("POST", "/hook/secret", strings.NewReader("this is not JSON")))
+	assert.Equal(http.StatusBadRequest, response.Code)
+}
+
+func testRegistryRotateSecretForbidden(t *testing.T) {
+	testData := []struct {
+		address      string
+		registeredBy string
+		clientID     string
+		expectedCode int
+	}{
+		{"", "", "", http.StatusForbidden},
+		{"10.1.1.1", "", "", http.StatusForbidden},
+		{"192.0.2.1", "", "", http.StatusOK},
+		{"192.0.2.1", "owner", "", http.StatusForbidden},
+		{"192.0.2.1", "owner", "intruder", http.StatusForbidden},
+		{"10.1.1.1", "owner", "owner", http.StatusOK},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert   = assert.New(t)
+				notifier = new(mockNotifier)
+				existing = testStoreW("http://example.com/hook", time.Now().Add(time.Hour))
+				registry = Registry{m: &monitor{list: NewList(nil), Notifier: notifier}}
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("POST", "/hook/secret", strings.NewReader(`{"url": "http://example.com/hook"}`))
+			)
+
+			existing.Address = record.address
+			existing.RegisteredBy = record.registeredBy
+			registry.m.list.Update([]W{existing})
+
+			if len(record.clientID) > 0 {
+				request = request.WithContext(
+					handler.NewContextWithValue(request.Context(), &handler.ContextValues{SatClientID: record.clientID}),
+				)
+			}
+
+			if record.expectedCode == http.StatusOK {
+				notifier.On("PublishMessage", mock.AnythingOfType("string")).Once()
+			}
+
+			registry.RotateSecret(response, request)
+			assert.Equal(record.expectedCode, response.Code)
+			notifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRegistryRotateSecret(t *testing.T) {
+	t.Run("Supplied", func(t *testing.T) {
+		testRegistryRotateSecret(t, `{"url": "http://example.com/hook", "secret": "new"}`, "new", "old")
+	})
+
+	t.Run("Generated", func(t *testing.T) {
+		testRegistryRotateSecret(t, `{"url": "http://example.com/hook"}`, "", "old")
+	})
+
+	t.Run("DropPrevious", func(t *testing.T) {
+		testRegistryRotateSecret(t, `{"url": "http://example.com/hook", "drop_previous": true}`, "old", "")
+	})
+
+	t.Run("NotFound", testRegistryRotateSecretNotFound)
+	t.Run("BadRequest", testRegistryRotateSecretBadRequest)
+	t.Run("Forbidden", testRegistryRotateSecretForbidden)
+}
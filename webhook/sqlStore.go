@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultSQLTable is the table used to store webhook registrations when no table is configured
+const DefaultSQLTable = "webhooks"
+
+// SQLStoreOptions configures a database/sql webhook Store.  The table is expected to have
+// an id column with a unique constraint and a registration column large enough to hold a JSON document:
+//
+//	CREATE TABLE webhooks (id VARCHAR(2048) PRIMARY KEY, registration TEXT NOT NULL)
+type SQLStoreOptions struct {
+	// Table is the name of the table holding registrations.  If unset, DefaultSQLTable is used.
+	Table string
+
+	// Placeholder produces the bind parameter for the 1-based ordinal position within a statement.
+	// If unset, "?" is used for all parameters, which suits MySQL and SQLite.  PostgreSQL clients
+	// should use DollarPlaceholder.
+	Placeholder func(int) string
+}
+
+// DollarPlaceholder produces PostgreSQL-style bind parameters, e.g. $1, $2
+func DollarPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func questionPlaceholder(int) string {
+	return "?"
+}
+
+// sqlStore is a Store backed by a database/sql database
+type sqlStore struct {
+	db *sql.DB
+
+	insertStatement string
+	deleteStatement string
+	listStatement   string
+}
+
+// NewSQLStore returns a Store backed by the given database.  If db is nil, this function panics.
+func NewSQLStore(db *sql.DB, o SQLStoreOptions) Store {
+	if db == nil {
+		panic("A database is required")
+	}
+
+	table := o.Table
+	if len(table) == 0 {
+		table = DefaultSQLTable
+	}
+
+	placeholder := o.Placeholder
+	if placeholder == nil {
+		placeholder = questionPlaceholder
+	}
+
+	return &sqlStore{
+		db:              db,
+		insertStatement: fmt.Sprintf("INSERT INTO %s (id, registration) VALUES (%s, %s)", table, placeholder(1), placeholder(2)),
+		deleteStatement: fmt.Sprintf("DELETE FROM %s WHERE id = %s", table, placeholder(1)),
+		listStatement:   fmt.Sprintf("SELECT registration FROM %s", table),
+	}
+}
+
+// Put replaces any existing registration in a single transaction, which avoids relying on
+// vendor-specific upsert syntax.
+func (ss *sqlStore) Put(w W) error {
+	registration, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ss.deleteStatement, w.ID()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(ss.insertStatement, w.ID(), string(registration)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ss *sqlStore) Delete(id string) error {
+	_, err := ss.db.Exec(ss.deleteStatement, id)
+	return err
+}
+
+func (ss *sqlStore) List() ([]W, error) {
+	rows, err := ss.db.Query(ss.listStatement)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var hooks []W
+	for rows.Next() {
+		var registration string
+		if err := rows.Scan(&registration); err != nil {
+			return nil, err
+		}
+
+		var w W
+		if err := json.Unmarshal([]byte(registration), &w); err != nil {
+			return nil, err
+		}
+
+		hooks = append(hooks, w)
+	}
+
+	return hooks, rows.Err()
+}
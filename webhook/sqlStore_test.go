@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDriver is a minimal database/sql driver that understands only the statements issued by sqlStore
+type testDriver struct {
+	lock       sync.Mutex
+	rows       map[string]string
+	statements []string
+}
+
+func (d *testDriver) Open(string) (driver.Conn, error) { return testConn{d}, nil }
+
+type testConn struct{ d *testDriver }
+
+func (c testConn) Prepare(query string) (driver.Stmt, error) { return testStmt{c.d, query}, nil }
+func (c testConn) Close() error                              { return nil }
+func (c testConn) Begin() (driver.Tx, error)                 { return testTx{}, nil }
+
+type testTx struct{}
+
+func (testTx) Commit() error   { return nil }
+func (testTx) Rollback() error { return nil }
+
+type testStmt struct {
+	d     *testDriver
+	query string
+}
+
+func (s testStmt) Close() error  { return nil }
+func (s testStmt) NumInput() int { return -1 }
+
+func (s testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.lock.Lock()
+	defer s.d.lock.Unlock()
+
+	s.d.statements = append(s.d.statements, s.query)
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		s.d.rows[args[0].(string)] = args[1].(string)
+	case strings.HasPrefix(s.query, "DELETE"):
+		delete(s.d.rows, args[0].(string))
+	default:
+		return nil, errors.New("unsupported statement")
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (s testStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.lock.Lock()
+	defer s.d.lock.Unlock()
+
+	s.d.statements = append(s.d.statements, s.query)
+	ids := make([]string, 0, len(s.d.rows))
+	for id := range s.d.rows {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	rows := &testRows{}
+	for _, id := range ids {
+		rows.values = append(rows.values, s.d.rows[id])
+	}
+
+	return rows, nil
+}
+
+type testRows struct {
+	values []string
+	next   int
+}
+
+func (r *testRows) Columns() []string { return []string{"registration"} }
+func (r *testRows) Close() error      { return nil }
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+
+	dest[0] = r.values[r.next]
+	r.next++
+	return nil
+}
+
+var testDrivers = struct {
+	lock  sync.Mutex
+	count int
+}{}
+
+func newTestDB(t *testing.T) (*testDriver, *sql.DB) {
+	testDrivers.lock.Lock()
+	testDrivers.count++
+	name := fmt.Sprintf("webhooktest%d", testDrivers.count)
+	testDrivers.lock.Unlock()
+
+	d := &testDriver{rows: make(map[string]string)}
+	sql.Register(name, d)
+
+	db, err := sql.Open(name, "")
+	require.NoError(t, err)
+	return d, db
+}
+
+func TestNewSQLStore(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() {
+		NewSQLStore(nil, SQLStoreOptions{})
+	})
+
+	_, db := newTestDB(t)
+	defer db.Close()
+
+	store := NewSQLStore(db, SQLStoreOptions{}).(*sqlStore)
+	assert.Equal("INSERT INTO webhooks (id, registration) VALUES (?, ?)", store.insertStatement)
+	assert.Equal("DELETE FROM webhooks WHERE id = ?", store.deleteStatement)
+	assert.Equal("SELECT registration FROM webhooks", store.listStatement)
+
+	store = NewSQLStore(db, SQLStoreOptions{Table: "hooks", Placeholder: DollarPlaceholder}).(*sqlStore)
+	assert.Equal("INSERT INTO hooks (id, registration) VALUES ($1, $2)", store.insertStatement)
+	assert.Equal("DELETE FROM hooks WHERE id = $1", store.deleteStatement)
+	assert.Equal("SELECT registration FROM hooks", store.listStatement)
+}
+
+func TestSQLStore(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Now()
+
+		d, db = newTestDB(t)
+		store = NewSQLStore(db, SQLStoreOptions{})
+	)
+
+	defer db.Close()
+
+	hooks, err := store.List()
+	require.NoError(err)
+	assert.Empty(hooks)
+
+	assert.NoError(store.Put(testStoreW("http://b.com", now)))
+	assert.NoError(store.Put(testStoreW("http://a.com", now)))
+	assert.NoError(store.Put(testStoreW("http://a.com", now.Add(time.Hour))))
+
+	hooks, err = store.List()
+	require.NoError(err)
+	require.Len(hooks, 2)
+	assert.Equal("http://a.com", hooks[0].ID())
+	assert.True(now.Add(time.Hour).Equal(hooks[0].Until))
+	assert.Equal("http://b.com", hooks[1].ID())
+
+	assert.NoError(store.Delete("http://b.com"))
+	hooks, err = store.List()
+	require.NoError(err)
+	require.Len(hooks, 1)
+	assert.Equal("http://a.com", hooks[0].ID())
+
+	d.lock.Lock()
+	assert.Contains(d.statements, "DELETE FROM webhooks WHERE id = ?")
+	assert.Contains(d.statements, "INSERT INTO webhooks (id, registration) VALUES (?, ?)")
+	d.lock.Unlock()
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store is durable storage for webhook registrations, so that registrations survive restarts
+// without relying on a cross-node notifier such as SNS.  Registrations are keyed by W.ID().
+type Store interface {
+	// Put creates or replaces the given registration
+	Put(W) error
+
+	// Delete removes the registration with the given ID.  Deleting a nonexistent registration is not an error.
+	Delete(id string) error
+
+	// List returns all stored registrations
+	List() ([]W, error)
+}
+
+// memoryStore is the in-memory Store implementation
+type memoryStore struct {
+	lock  sync.RWMutex
+	hooks map[string]W
+}
+
+// NewMemoryStore returns a Store that holds registrations in process memory.  This Store is not durable,
+// and is primarily useful for testing and for single-node deployments.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		hooks: make(map[string]W),
+	}
+}
+
+func (ms *memoryStore) Put(w W) error {
+	ms.lock.Lock()
+	ms.hooks[w.ID()] = w
+	ms.lock.Unlock()
+	return nil
+}
+
+func (ms *memoryStore) Delete(id string) error {
+	ms.lock.Lock()
+	delete(ms.hooks, id)
+	ms.lock.Unlock()
+	return nil
+}
+
+func (ms *memoryStore) List() ([]W, error) {
+	ms.lock.RLock()
+	hooks := make([]W, 0, len(ms.hooks))
+	for _, w := range ms.hooks {
+		hooks = append(hooks, w)
+	}
+
+	ms.lock.RUnlock()
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID() < hooks[j].ID() })
+	return hooks, nil
+}
+
+// PruneStore deletes every registration in the given Store that has expired as of now.
+// The count of deleted registrations is returned along with the first error encountered.
+func PruneStore(s Store, now time.Time) (int, error) {
+	hooks, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, w := range hooks {
+		if !w.Until.After(now) {
+			if err := s.Delete(w.ID()); err != nil {
+				return count, err
+			}
+
+			count++
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStoreW(url string, until time.Time) W {
+	var w W
+	w.Config.URL = url
+	w.Events = []string{".*"}
+	w.Until = until
+	return w
+}
+
+func TestMemoryStore(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Now()
+		store   = NewMemoryStore()
+	)
+
+	hooks, err := store.List()
+	require.NoError(err)
+	assert.Empty(hooks)
+
+	assert.NoError(store.Put(testStoreW("http://b.com", now)))
+	assert.NoError(store.Put(testStoreW("http://a.com", now)))
+	assert.NoError(store.Put(testStoreW("http://a.com", now.Add(time.Hour))))
+
+	hooks, err = store.List()
+	require.NoError(err)
+	require.Len(hooks, 2)
+	assert.Equal("http://a.com", hooks[0].ID())
+	assert.Equal(now.Add(time.Hour), hooks[0].Until)
+	assert.Equal("http://b.com", hooks[1].ID())
+
+	assert.NoError(store.Delete("http://b.com"))
+	assert.NoError(store.Delete("http://nosuch.com"))
+
+	hooks, err = store.List()
+	require.NoError(err)
+	require.Len(hooks, 1)
+	assert.Equal("http://a.com", hooks[0].ID())
+}
+
+func TestPruneStore(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Now()
+		store   = NewMemoryStore()
+	)
+
+	store.Put(testStoreW("http://expired.com", now.Add(-time.Minute)))
+	store.Put(testStoreW("http://now.com", now))
+	store.Put(testStoreW("http://active.com", now.Add(time.Minute)))
+
+	count, err := PruneStore(store, now)
+	assert.Equal(2, count)
+	assert.NoError(err)
+
+	hooks, err := store.List()
+	require.NoError(err)
+	require.Len(hooks, 1)
+	assert.Equal("http://active.com", hooks[0].ID())
+}
+
+func TestFactoryStore(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Now()
+		store   = NewMemoryStore()
+	)
+
+	store.Put(testStoreW("http://existing.com", now.Add(time.Hour)))
+
+	metricsRegistry, err := xmetrics.NewRegistry(&xmetrics.Options{}, Metrics)
+	require.NoError(err)
+
+	factory := &Factory{
+		Tick:  func(time.Duration) <-chan time.Time { return nil },
+		Store: store,
+	}
+
+	factory.undertaker = factory.Prune
+	factory.NewRegistryAndHandler(metricsRegistry)
+	require.Equal(1, factory.m.list.Len())
+	assert.Equal("http://existing.com", factory.m.list.Get(0).ID())
+
+	updated := make(chan struct{})
+	factory.SetExternalUpdate(func([]W) { close(updated) })
+	factory.m.sendNewHooks([]W{testStoreW("http://new.com", now.Add(time.Hour))})
+
+	select {
+	case <-updated:
+	case <-time.After(5 * time.Second):
+		require.Fail("The update was not processed")
+	}
+
+	// the store is written asynchronously
+	var hooks []W
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		hooks, err = store.List()
+		require.NoError(err)
+		if len(hooks) == 2 {
+			break
+		}
+	}
+
+	require.Len(hooks, 2)
+	assert.Equal("http://existing.com", hooks[0].ID())
+	assert.Equal("http://new.com", hooks[1].ID())
+}
+
+// failingStore is a Store whose every operation fails
+type failingStore struct {
+	err error
+}
+
+func (fs failingStore) Put(W) error         { return fs.err }
+func (fs failingStore) Delete(string) error { return fs.err }
+func (fs failingStore) List() ([]W, error)  { return nil, fs.err }
+
+// quietT records assertion failures without failing the test, which allows polling for metric values
+type quietT struct {
+	failed bool
+}
+
+func (q *quietT) Errorf(string, ...interface{}) {
+	q.failed = true
+}
+
+func TestFactoryStoreErrors(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Now()
+		p       = xmetricstest.NewProvider(nil, Metrics)
+		tick    = make(chan time.Time)
+
+		factory = &Factory{
+			Tick:   func(time.Duration) <-chan time.Time { return tick },
+			Store:  failingStore{errors.New("expected")},
+			Logger: logging.NewTestLogger(nil, t),
+		}
+	)
+
+	factory.undertaker = factory.Prune
+	factory.NewRegistryAndHandler(p)
+	assert.Zero(factory.m.list.Len())
+	p.Assert(t, StoreErrorCount, OperationLabel, ListOperation)(xmetricstest.Value(1.0))
+
+	factory.m.sendNewHooks([]W{testStoreW("http://new.com", now.Add(time.Hour))})
+	tick <- now
+
+	// failures are counted by the store writer, after the listener has moved on
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		var q quietT
+		p.Assert(&q, StoreErrorCount, OperationLabel, PutOperation)(xmetricstest.Value(1.0))
+		p.Assert(&q, StoreErrorCount, OperationLabel, PruneOperation)(xmetricstest.Value(1.0))
+		if !q.failed {
+			break
+		}
+	}
+
+	p.Assert(t, StoreErrorCount, OperationLabel, PutOperation)(xmetricstest.Value(1.0))
+	p.Assert(t, StoreErrorCount, OperationLabel, PruneOperation)(xmetricstest.Value(1.0))
+	require.Equal(1, factory.m.list.Len())
+}
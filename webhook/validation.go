@@ -0,0 +1,325 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+// privateNetworks are the loopback, link-local, and private address ranges that registrations
+// may not target when RejectPrivateAddresses is set
+var privateNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"127.0.0.0/8",
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"169.254.0.0/16",
+		"::1/128",
+		"fc00::/7",
+		"fe80::/10",
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}()
+
+// ValidationOptions configures the policy applied to webhook registrations.  A nil ValidationOptions
+// applies the default policy:  URLs must be absolute http or https URLs, and every event and device id pattern
+// must be a valid regular expression.
+type ValidationOptions struct {
+	// RequireHTTPS rejects registrations whose URL or failure URL does not use https
+	RequireHTTPS bool `json:"requireHTTPS"`
+
+	// RejectPrivateAddresses rejects URLs that target localhost or loopback, link-local, RFC1918, or
+	// unspecified addresses, including IPv4 addresses written in decimal, octal, or hexadecimal form.
+	// Hostnames are not resolved, since DNS answers can change after registration.
+	RejectPrivateAddresses bool `json:"rejectPrivateAddresses"`
+
+	// MaxDuration is the maximum amount of time a registration may remain active.  If unset, there is no cap.
+	MaxDuration time.Duration `json:"maxDuration"`
+
+	// Now is an optional source of the current time, used when checking MaxDuration.  If unset, time.Now is used.
+	Now func() time.Time `json:"-"`
+}
+
+func (o *ValidationOptions) requireHTTPS() bool {
+	if o != nil {
+		return o.RequireHTTPS
+	}
+
+	return false
+}
+
+func (o *ValidationOptions) rejectPrivateAddresses() bool {
+	if o != nil {
+		return o.RejectPrivateAddresses
+	}
+
+	return false
+}
+
+func (o *ValidationOptions) maxDuration() time.Duration {
+	if o != nil {
+		return o.MaxDuration
+	}
+
+	return 0
+}
+
+func (o *ValidationOptions) now() time.Time {
+	if o != nil && o.Now != nil {
+		return o.Now()
+	}
+
+	return time.Now()
+}
+
+// ValidationError describes a single problem with one field of a webhook registration
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (ve ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", ve.Field, ve.Message)
+}
+
+// ValidationErrors is the set of problems found with a webhook registration
+type ValidationErrors []ValidationError
+
+func (ve ValidationErrors) Error() string {
+	var output bytes.Buffer
+	output.WriteString("Invalid webhook registration: [")
+	for i, e := range ve {
+		if i > 0 {
+			output.WriteString(", ")
+		}
+
+		output.WriteString(e.Error())
+	}
+
+	output.WriteRune(']')
+	return output.String()
+}
+
+// MarshalJSON produces the structured body written with a 400 response
+func (ve ValidationErrors) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Message string            `json:"message"`
+		Errors  []ValidationError `json:"errors"`
+	}{
+		Message: "Invalid webhook registration",
+		Errors:  []ValidationError(ve),
+	})
+}
+
+// Validate checks a normalized copy of the given registration against this policy.  Normalization trims
+// whitespace and lowercases the scheme and host of URLs.  If the registration is valid, the normalized copy
+// is stored into w.  Slices are never modified in place, so registrations that share them are unaffected.
+//
+// If the registration is invalid, w is left unchanged and the returned error is always a ValidationErrors
+// describing every problem found.
+func (o *ValidationOptions) Validate(w *W) error {
+	var (
+		errs       ValidationErrors
+		normalized = *w
+	)
+
+	normalized.Config.URL = o.validateURL(&errs, "config.url", w.Config.URL, true)
+	normalized.FailureURL = o.validateURL(&errs, "failure_url", w.FailureURL, false)
+
+	if len(w.Events) == 0 {
+		errs = append(errs, ValidationError{"events", "at least one event is required"})
+	}
+
+	normalized.Events = make([]string, len(w.Events))
+	for i, event := range w.Events {
+		normalized.Events[i] = strings.TrimSpace(event)
+		validatePattern(&errs, fmt.Sprintf("events[%d]", i), normalized.Events[i])
+	}
+
+	if w.Matcher.DeviceId != nil {
+		normalized.Matcher.DeviceId = make([]string, len(w.Matcher.DeviceId))
+	}
+
+	for i, deviceID := range w.Matcher.DeviceId {
+		normalized.Matcher.DeviceId[i] = strings.TrimSpace(deviceID)
+		validatePattern(&errs, fmt.Sprintf("matcher.device_id[%d]", i), normalized.Matcher.DeviceId[i])
+	}
+
+	if normalized.Config.EventContentType = strings.TrimSpace(w.Config.EventContentType); len(normalized.Config.EventContentType) > 0 {
+		if _, err := wrp.FormatFromContentType(normalized.Config.EventContentType); err != nil {
+			errs = append(errs, ValidationError{"config.event_content_type", "must be a WRP content type"})
+		}
+	}
+
+	if maxDuration := o.maxDuration(); maxDuration > 0 {
+		if w.Duration > maxDuration {
+			errs = append(errs, ValidationError{"duration", fmt.Sprintf("cannot exceed %s", maxDuration)})
+		}
+
+		if !w.Until.IsZero() && w.Until.Sub(o.now()) > maxDuration {
+			errs = append(errs, ValidationError{"until", fmt.Sprintf("cannot be more than %s in the future", maxDuration)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	*w = normalized
+	return nil
+}
+
+// validateURL checks and normalizes a single URL field, returning the normalized value
+func (o *ValidationOptions) validateURL(errs *ValidationErrors, field, value string, required bool) string {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		if required {
+			*errs = append(*errs, ValidationError{field, "a URL is required"})
+		}
+
+		return value
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		*errs = append(*errs, ValidationError{field, err.Error()})
+		return value
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https":
+		*errs = append(*errs, ValidationError{field, "the URL scheme must be http or https"})
+	case o.requireHTTPS() && u.Scheme != "https":
+		*errs = append(*errs, ValidationError{field, "the URL scheme must be https"})
+	}
+
+	host := u.Hostname()
+	if len(host) == 0 {
+		*errs = append(*errs, ValidationError{field, "the URL must have a host"})
+	} else if o.rejectPrivateAddresses() && isPrivateHost(host) {
+		*errs = append(*errs, ValidationError{field, "the URL cannot target a loopback or private address"})
+	}
+
+	return u.String()
+}
+
+// parseNumericIPv4 parses the numeric IPv4 forms accepted by inet_aton, which most resolvers honor even though
+// net.ParseIP does not:  one to four parts separated by dots, each in decimal, octal with a leading 0, or
+// hexadecimal with a leading 0x.  The last part fills all remaining bytes, so that "2130706433", "0x7f.1",
+// and "0177.0.0.1" are all 127.0.0.1.  A nil IP is returned if host is not in one of these forms.
+func parseNumericIPv4(host string) net.IP {
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil
+	}
+
+	var value uint64
+	for i, part := range parts {
+		if len(part) == 0 || strings.ContainsAny(part, "_+-") {
+			return nil
+		}
+
+		base := 10
+		switch {
+		case len(part) > 2 && (part[:2] == "0x" || part[:2] == "0X"):
+			base = 16
+			part = part[2:]
+		case len(part) > 1 && part[0] == '0':
+			base = 8
+			part = part[1:]
+		}
+
+		n, err := strconv.ParseUint(part, base, 32)
+		if err != nil {
+			return nil
+		}
+
+		// each part except the last is a single byte, and the last part fills the rest of the address
+		bits := uint(8)
+		if i == len(parts)-1 {
+			bits = uint(8 * (4 - i))
+		}
+
+		if n >= 1<<bits {
+			return nil
+		}
+
+		value = value<<bits | n
+	}
+
+	return net.IPv4(byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+}
+
+// isPrivateHost checks whether a host is localhost or an IP address in a loopback or private range.
+// The host is normalized first, so that a trailing dot or a numeric IPv4 form does not hide the address.
+// Hostnames are not resolved, since DNS answers can change after registration.
+func isPrivateHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ip = parseNumericIPv4(host)
+	}
+
+	if ip == nil {
+		return false
+	}
+
+	if ip.IsUnspecified() {
+		return true
+	}
+
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validatePattern(errs *ValidationErrors, field, pattern string) {
+	if len(pattern) == 0 {
+		*errs = append(*errs, ValidationError{field, "a pattern is required"})
+	} else if _, err := regexp.Compile(pattern); err != nil {
+		*errs = append(*errs, ValidationError{field, err.Error()})
+	}
+}
+
+// writeValidationErrors writes the structured 400 response for a registration that failed validation
+func writeValidationErrors(rw http.ResponseWriter, errs ValidationErrors) {
+	body, err := json.Marshal(errs)
+	if err != nil {
+		jsonResponse(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusBadRequest)
+	rw.Write(body)
+}
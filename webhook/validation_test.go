@@ -0,0 +1,236 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testValidationW(url string) *W {
+	w := new(W)
+	w.Config.URL = url
+	w.Events = []string{"iot"}
+	w.Matcher.DeviceId = []string{".*"}
+	return w
+}
+
+func testValidationOptionsValid(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w      = testValidationW("  HTTPS://Example.COM/callback?A=B  ")
+	)
+
+	w.FailureURL = "http://Failure.example.com"
+	w.Events = []string{" iot ", "device-status/.*"}
+	w.Config.EventContentType = " application/json "
+
+	assert.NoError((*ValidationOptions)(nil).Validate(w))
+	assert.Equal("application/json", w.Config.EventContentType)
+	assert.Equal("https://example.com/callback?A=B", w.Config.URL)
+	assert.Equal("http://failure.example.com", w.FailureURL)
+	assert.Equal([]string{"iot", "device-status/.*"}, w.Events)
+}
+
+func testValidationOptionsInvalid(t *testing.T) {
+	testData := []struct {
+		options ValidationOptions
+		w       *W
+		field   string
+	}{
+		{ValidationOptions{}, testValidationW(""), "config.url"},
+		{ValidationOptions{}, testValidationW("ftp://example.com"), "config.url"},
+		{ValidationOptions{}, testValidationW("http://"), "config.url"},
+		{ValidationOptions{}, testValidationW("http://%zz"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://localhost:8080/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://127.0.0.1/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://10.1.2.3/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://172.20.0.1/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://192.168.1.1/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://[::1]:8080/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://0.0.0.0/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://2130706433/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://0177.0.0.1/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://0x7f.1/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://10.0x10203/foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://localhost./foo"), "config.url"},
+		{ValidationOptions{RejectPrivateAddresses: true}, testValidationW("http://[::ffff:127.0.0.1]/foo"), "config.url"},
+		{ValidationOptions{RequireHTTPS: true}, testValidationW("http://example.com"), "config.url"},
+		{
+			ValidationOptions{RequireHTTPS: true},
+			func() *W { w := testValidationW("https://example.com"); w.FailureURL = "http://example.com"; return w }(),
+			"failure_url",
+		},
+		{
+			ValidationOptions{},
+			func() *W { w := testValidationW("https://example.com"); w.Events = nil; return w }(),
+			"events",
+		},
+		{
+			ValidationOptions{},
+			func() *W {
+				w := testValidationW("https://example.com")
+				w.Events = []string{"iot", "(unclosed"}
+				return w
+			}(),
+			"events[1]",
+		},
+		{
+			ValidationOptions{},
+			func() *W {
+				w := testValidationW("https://example.com")
+				w.Matcher.DeviceId = []string{"[bad"}
+				return w
+			}(),
+			"matcher.device_id[0]",
+		},
+		{
+			ValidationOptions{},
+			func() *W {
+				w := testValidationW("https://example.com")
+				w.Config.EventContentType = "text/plain"
+				return w
+			}(),
+			"config.event_content_type",
+		},
+		{
+			ValidationOptions{MaxDuration: time.Minute},
+			func() *W { w := testValidationW("https://example.com"); w.Duration = time.Hour; return w }(),
+			"duration",
+		},
+		{
+			ValidationOptions{MaxDuration: time.Minute},
+			func() *W { w := testValidationW("https://example.com"); w.Until = time.Now().Add(time.Hour); return w }(),
+			"until",
+		},
+	}
+
+	for i, record := range testData {
+		t.Logf("%d: %#v", i, record)
+
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			err     = record.options.Validate(record.w)
+		)
+
+		require.Error(err)
+		errs, ok := err.(ValidationErrors)
+		require.True(ok)
+		require.Len(errs, 1)
+		assert.Equal(record.field, errs[0].Field)
+		assert.NotEmpty(errs[0].Message)
+		assert.Contains(errs.Error(), record.field)
+	}
+}
+
+func testValidationOptionsPrivateAddresses(t *testing.T) {
+	assert := assert.New(t)
+
+	// private addresses are only rejected when configured
+	for _, options := range []*ValidationOptions{nil, {}} {
+		assert.NoError(options.Validate(testValidationW("http://localhost:8080/foo")))
+		assert.NoError(options.Validate(testValidationW("http://10.1.2.3/foo")))
+	}
+
+	options := &ValidationOptions{RejectPrivateAddresses: true}
+	for _, public := range []string{"http://example.com", "http://8.8.8.8", "http://134744072", "http://1.2.3.4.5", "http://0x1g.2", "http://99999999999"} {
+		assert.NoError(options.Validate(testValidationW(public)), public)
+	}
+}
+
+func testValidationOptionsInvalidUnchanged(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		events = []string{" iot ", "("}
+		w      = testValidationW(" http://Example.com ")
+	)
+
+	w.Events = events
+	assert.Error((*ValidationOptions)(nil).Validate(w))
+	assert.Equal(" http://Example.com ", w.Config.URL)
+	assert.Equal([]string{" iot ", "("}, w.Events)
+}
+
+func testValidationOptionsSharedSlices(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		events  = []string{" iot "}
+		w       = testValidationW("http://example.com")
+	)
+
+	w.Events = events
+	require.NoError((*ValidationOptions)(nil).Validate(w))
+	assert.Equal([]string{"iot"}, w.Events)
+	assert.Equal([]string{" iot "}, events)
+}
+
+func testValidationOptionsMaxDuration(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		now     = time.Now()
+		options = &ValidationOptions{
+			MaxDuration: time.Hour,
+			Now:         func() time.Time { return now },
+		}
+
+		w = testValidationW("https://example.com")
+	)
+
+	w.Duration = time.Hour
+	w.Until = now.Add(time.Hour)
+	assert.NoError(options.Validate(w))
+}
+
+func testValidationOptionsMultipleErrors(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		w       = testValidationW("http://localhost")
+	)
+
+	w.Events = []string{"("}
+	err := (&ValidationOptions{RequireHTTPS: true, RejectPrivateAddresses: true}).Validate(w)
+	require.Error(err)
+	assert.Len(err.(ValidationErrors), 3)
+}
+
+func TestValidationOptions(t *testing.T) {
+	t.Run("Valid", testValidationOptionsValid)
+	t.Run("Invalid", testValidationOptionsInvalid)
+	t.Run("PrivateAddresses", testValidationOptionsPrivateAddresses)
+	t.Run("InvalidUnchanged", testValidationOptionsInvalidUnchanged)
+	t.Run("SharedSlices", testValidationOptionsSharedSlices)
+	t.Run("MaxDuration", testValidationOptionsMaxDuration)
+	t.Run("MultipleErrors", testValidationOptionsMultipleErrors)
+}
+
+func TestUpdateRegistryValidation(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		registry = Registry{m: &monitor{validation: &ValidationOptions{RequireHTTPS: true}}}
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/hook", strings.NewReader(`{"config": {"url": "http://example.com"}, "events": ["iot"]}`))
+	)
+
+	registry.UpdateRegistry(response, request)
+	assert.Equal(http.StatusBadRequest, response.Code)
+	assert.Equal("application/json", response.HeaderMap.Get("Content-Type"))
+
+	var body struct {
+		Message string            `json:"message"`
+		Errors  []ValidationError `json:"errors"`
+	}
+
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &body))
+	assert.NotEmpty(body.Message)
+	require.Len(body.Errors, 1)
+	assert.Equal("config.url", body.Errors[0].Field)
+}
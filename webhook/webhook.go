@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net"
+	"regexp"
 	"sync/atomic"
 	"time"
 )
@@ -31,9 +32,19 @@ type W struct {
 		// The content-type to set the messages to (unless specified by WRP).
 		ContentType string `json:"content_type"`
 
+		// The preferred encoding of WRP events, e.g. "application/json" or "application/msgpack".
+		// Events are transcoded into this format before delivery.
+		// Optional, set to "" to deliver events in the format they were sent.
+		EventContentType string `json:"event_content_type,omitempty"`
+
 		// The secret to use for the SHA1 HMAC.
 		// Optional, set to "" to disable behavior.
 		Secret string `json:"secret,omitempty"`
+
+		// The secret that was in use before the most recent rotation.  While set, messages are
+		// signed with both secrets so that receivers can switch over without a delivery gap.
+		// Optional, set to "" once the receiver has moved to the current secret.
+		PreviousSecret string `json:"previous_secret,omitempty"`
 	} `json:"config"`
 
 	// The URL to notify when we cut off a client due to overflow.
@@ -57,6 +68,14 @@ type W struct {
 
 	// The address that performed the registration
 	Address string `json:"registered_from_address"`
+
+	// The authenticated client that performed the registration, if the registration request was authenticated.
+	// When set, only this client may update the hook or rotate its secret.
+	RegisteredBy string `json:"registered_by,omitempty"`
+
+	// The partners on whose behalf this hook was registered.
+	// Optional, an empty list means the hook is not scoped to any partner.
+	PartnerIDs []string `json:"partner_ids,omitempty"`
 }
 
 func NewW(jsonString []byte, ip string) (w *W, err error) {
@@ -145,21 +164,59 @@ type updatableList struct {
 	value atomic.Value
 }
 
+// listSnapshot is an immutable state of an updatableList.  The event patterns of each hook are compiled
+// once, when the snapshot is created, rather than each time the list is searched.
+type listSnapshot struct {
+	items  []W
+	events [][]*regexp.Regexp
+}
+
+func (ul *updatableList) load() (listSnapshot, bool) {
+	snapshot, ok := ul.value.Load().(listSnapshot)
+	return snapshot, ok
+}
+
 func (ul *updatableList) set(list []W) {
-	ul.value.Store(list)
+	previous, _ := ul.load()
+	compiled := make(map[string]*regexp.Regexp)
+	for _, events := range previous.events {
+		for _, e := range events {
+			compiled[e.String()] = e
+		}
+	}
+
+	snapshot := listSnapshot{items: list, events: make([][]*regexp.Regexp, len(list))}
+	for i, w := range list {
+		for _, pattern := range w.Events {
+			e, ok := compiled[pattern]
+			if !ok {
+				var err error
+				if e, err = regexp.Compile(pattern); err != nil {
+					// invalid patterns never match, as is the case for unvalidated registrations
+					continue
+				}
+
+				compiled[pattern] = e
+			}
+
+			snapshot.events[i] = append(snapshot.events[i], e)
+		}
+	}
+
+	ul.value.Store(snapshot)
 }
 
 func (ul *updatableList) Len() int {
-	if list, ok := ul.value.Load().([]W); ok {
-		return len(list)
+	if snapshot, ok := ul.load(); ok {
+		return len(snapshot.items)
 	}
 
 	return 0
 }
 
 func (ul *updatableList) Get(index int) *W {
-	if list, ok := ul.value.Load().([]W); ok {
-		return &list[index]
+	if snapshot, ok := ul.load(); ok {
+		return &snapshot.items[index]
 	}
 
 	// TODO: design choice.  may want to panic here, to mimic
@@ -168,48 +225,61 @@ func (ul *updatableList) Get(index int) *W {
 	return nil
 }
 
+// matchesEvent tests the precompiled event patterns of the hook at the given index
+func (ul *updatableList) matchesEvent(index int, event string) bool {
+	if snapshot, ok := ul.load(); ok && index < len(snapshot.events) {
+		for _, e := range snapshot.events[index] {
+			if e.MatchString(event) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Update adds new hooks to this list and replaces existing hooks with the same ID.  A replaced hook
+// keeps the Address and RegisteredBy of its original registration, so that an update can never change
+// which client owns a hook.
 func (ul *updatableList) Update(newItems []W) {
 	for _, newItem := range newItems {
-		found := false
-		var items []*W
-		for i := 0; i < ul.Len(); i++ {
-			items = append(items, ul.Get(i))
+		// we want to add items that will expire in the future
+		if !newItem.Until.After(time.Now()) {
+			continue
 		}
 
-		// we want to add items that will expire in the future
-		if newItem.Until.After(time.Now()) {
-			for i := 0; i < len(items) && !found; i++ {
-				if items[i].ID() == newItem.ID() {
-					found = true
-
-					items[i].Matcher = newItem.Matcher
-					items[i].Events = newItem.Events
-					items[i].Config.ContentType = newItem.Config.ContentType
-					items[i].Config.Secret = newItem.Config.Secret
-					items[i].Until = newItem.Until
-				}
-			}
+		found := false
+		var items []W
+		if snapshot, ok := ul.load(); ok {
+			// never modify the current snapshot, as it may be in use by other goroutines
+			items = make([]W, len(snapshot.items), len(snapshot.items)+1)
+			copy(items, snapshot.items)
+		}
 
-			// add item
-			if !found {
-				items = append(items, &newItem)
-			}
+		for i := 0; i < len(items) && !found; i++ {
+			if items[i].ID() == newItem.ID() {
+				found = true
 
-			var itemsCopy []W
-			for _, i := range items {
-				itemsCopy = append(itemsCopy, *i)
+				newItem.Address = items[i].Address
+				newItem.RegisteredBy = items[i].RegisteredBy
+				items[i] = newItem
 			}
+		}
 
-			// store items
-			ul.set(itemsCopy)
+		// add item
+		if !found {
+			items = append(items, newItem)
 		}
+
+		// store items
+		ul.set(items)
 	}
 }
 
 func (ul *updatableList) Filter(filter func([]W) []W) {
-	if list, ok := ul.value.Load().([]W); ok {
-		copyOf := make([]W, len(list))
-		for i, w := range list {
+	if snapshot, ok := ul.load(); ok {
+		copyOf := make([]W, len(snapshot.items))
+		for i, w := range snapshot.items {
 			copyOf[i] = w
 		}
 
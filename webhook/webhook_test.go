@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testListUpdateReplaces(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		list     = NewList(nil)
+		existing W
+	)
+
+	existing.Config.URL = "http://example.com"
+	existing.Config.ContentType = "application/json"
+	existing.Events = []string{"iot"}
+	existing.Until = time.Now().Add(time.Hour)
+	existing.Address = "127.0.0.1"
+	existing.RegisteredBy = "original-client"
+	list.Update([]W{existing})
+
+	before := list.Get(0)
+
+	updated := existing
+	updated.Config.EventContentType = "application/msgpack"
+	updated.FailureURL = "http://example.com/failure"
+	updated.Events = []string{"device-status/.*"}
+	updated.Address = "10.0.0.1"
+	updated.RegisteredBy = "another-client"
+	list.Update([]W{updated})
+
+	require.Equal(1, list.Len())
+	actual := list.Get(0)
+	assert.Equal("application/msgpack", actual.Config.EventContentType)
+	assert.Equal("http://example.com/failure", actual.FailureURL)
+	assert.Equal([]string{"device-status/.*"}, actual.Events)
+	assert.Equal("127.0.0.1", actual.Address)
+	assert.Equal("original-client", actual.RegisteredBy)
+
+	// the previous snapshot must not be modified
+	assert.Empty(before.Config.EventContentType)
+	assert.Empty(before.FailureURL)
+	assert.Equal([]string{"iot"}, before.Events)
+}
+
+func testListUpdateExpired(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		list   = NewList(nil)
+		w      W
+	)
+
+	w.Config.URL = "http://example.com"
+	w.Until = time.Now().Add(-time.Minute)
+	list.Update([]W{w})
+	assert.Zero(list.Len())
+}
+
+func TestListUpdate(t *testing.T) {
+	t.Run("Replaces", testListUpdateReplaces)
+	t.Run("Expired", testListUpdateExpired)
+}
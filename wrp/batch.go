@@ -0,0 +1,71 @@
+package wrp
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNilBatchMessage is returned when a batch to be encoded contains a nil message
+var ErrNilBatchMessage = errors.New("A WRP batch cannot contain nil messages")
+
+// checkBatch verifies that each message in a batch can be encoded
+func checkBatch(batch []*Message) error {
+	for _, m := range batch {
+		if m == nil {
+			return ErrNilBatchMessage
+		}
+	}
+
+	return nil
+}
+
+// EncodeBatch writes a batch of messages to output as a single container in the given format.  For Msgpack, the
+// container is a length-prefixed msgpack array, and for JSON it is a JSON array.  Custom formats encode the batch
+// however their codec encodes a slice.  The entire batch is written with a single codec pass, which is more
+// efficient than encoding each message separately when shipping events to consumers such as Kafka or webhooks.
+//
+// A nil or empty batch is encoded as an empty container.
+func EncodeBatch(output io.Writer, f Format, batch []*Message) error {
+	if err := checkBatch(batch); err != nil {
+		return err
+	}
+
+	if batch == nil {
+		batch = []*Message{}
+	}
+
+	return NewEncoder(output, f).Encode(batch)
+}
+
+// EncodeBatchBytes is like EncodeBatch, except that the container is written to a byte slice
+func EncodeBatchBytes(output *[]byte, f Format, batch []*Message) error {
+	if err := checkBatch(batch); err != nil {
+		return err
+	}
+
+	if batch == nil {
+		batch = []*Message{}
+	}
+
+	return NewEncoderBytes(output, f).Encode(batch)
+}
+
+// DecodeBatch reads a batch of messages written by EncodeBatch or EncodeBatchBytes
+func DecodeBatch(input io.Reader, f Format) ([]*Message, error) {
+	var batch []*Message
+	if err := NewDecoder(input, f).Decode(&batch); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// DecodeBatchBytes is like DecodeBatch, except that the container is read from a byte slice
+func DecodeBatchBytes(input []byte, f Format) ([]*Message, error) {
+	var batch []*Message
+	if err := NewDecoderBytes(input, f).Decode(&batch); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
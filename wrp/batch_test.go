@@ -0,0 +1,138 @@
+package wrp
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBatch() []*Message {
+	return []*Message{
+		{
+			Type:        SimpleEventMessageType,
+			Source:      "mac:112233445566",
+			Destination: "event:device-status/mac:112233445566/online",
+			ContentType: "application/json",
+			Metadata:    map[string]string{"hw-model": "foo"},
+			Payload:     []byte(`{"id": "mac:112233445566"}`),
+		},
+		{
+			Type:        SimpleEventMessageType,
+			Source:      "mac:112233445566",
+			Destination: "event:device-status/mac:112233445566/offline",
+			Payload:     []byte{0x00, 0x06, 0xFF, 0xF0},
+		},
+		{
+			Type:            SimpleRequestResponseMessageType,
+			Source:          "dns:talaria.example.com",
+			Destination:     "mac:112233445566",
+			TransactionUUID: "1234",
+		},
+	}
+}
+
+func testEncodeBatchRoundTrip(t *testing.T, f Format) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		batch   = testBatch()
+	)
+
+	t.Run("Writer", func(t *testing.T) {
+		var output bytes.Buffer
+		require.NoError(EncodeBatch(&output, f, batch))
+
+		decoded, err := DecodeBatch(&output, f)
+		require.NoError(err)
+		assert.Equal(batch, decoded)
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		var output []byte
+		require.NoError(EncodeBatchBytes(&output, f, batch))
+
+		decoded, err := DecodeBatchBytes(output, f)
+		require.NoError(err)
+		assert.Equal(batch, decoded)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		for _, empty := range [][]*Message{nil, {}} {
+			var output []byte
+			require.NoError(EncodeBatchBytes(&output, f, empty))
+
+			decoded, err := DecodeBatchBytes(output, f)
+			require.NoError(err)
+			assert.Empty(decoded)
+		}
+	})
+}
+
+func testEncodeBatchContainer(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		batch   = testBatch()
+	)
+
+	t.Run("Msgpack", func(t *testing.T) {
+		var output []byte
+		require.NoError(EncodeBatchBytes(&output, Msgpack, batch))
+		require.NotEmpty(output)
+
+		// a msgpack fixarray header carries the element count in its low nibble
+		assert.Equal(byte(0x90|len(batch)), output[0])
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var (
+			output  []byte
+			generic []map[string]interface{}
+		)
+
+		require.NoError(EncodeBatchBytes(&output, JSON, batch))
+		require.NoError(json.Unmarshal(output, &generic))
+		require.Len(generic, len(batch))
+		assert.Equal("mac:112233445566", generic[0]["source"])
+	})
+}
+
+func testEncodeBatchNilMessage(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		batch  = append(testBatch(), nil)
+		output []byte
+	)
+
+	assert.Equal(ErrNilBatchMessage, EncodeBatch(new(bytes.Buffer), Msgpack, batch))
+	assert.Equal(ErrNilBatchMessage, EncodeBatchBytes(&output, Msgpack, batch))
+	assert.Empty(output)
+}
+
+func testDecodeBatchInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	decoded, err := DecodeBatch(strings.NewReader(`{"this": "is not an array"}`), JSON)
+	assert.Empty(decoded)
+	assert.Error(err)
+
+	decoded, err = DecodeBatchBytes([]byte(`[{"source": `), JSON)
+	assert.Empty(decoded)
+	assert.Error(err)
+}
+
+func TestBatch(t *testing.T) {
+	for _, f := range []Format{Msgpack, JSON} {
+		t.Run(f.String(), func(t *testing.T) {
+			testEncodeBatchRoundTrip(t, f)
+		})
+	}
+
+	t.Run("Container", testEncodeBatchContainer)
+	t.Run("NilMessage", testEncodeBatchNilMessage)
+	t.Run("DecodeInvalid", testDecodeBatchInvalid)
+}
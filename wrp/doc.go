@@ -77,5 +77,15 @@ Some common uses of this package include:
 		return buffer.Bytes(), nil
 	}
 
+(5) Shipping a batch of events as a single container:
+
+	var buffer bytes.Buffer
+	if err := EncodeBatch(&buffer, Msgpack, events); err != nil {
+		// deal with the error
+	}
+
+	// the consumer decodes the entire batch at once
+	events, err := DecodeBatch(&buffer, Msgpack)
+
 */
 package wrp
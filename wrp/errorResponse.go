@@ -0,0 +1,65 @@
+package wrp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorPayloadContentType is the content type of the payload of error responses built by NewErrorResponse
+const ErrorPayloadContentType = "application/json"
+
+// ErrorPayload is the payload of a WRP error response.  Its JSON form matches the error bodies written
+// by xhttp.WriteError, so that a client sees the same schema whether an error arrives over HTTP or WRP.
+type ErrorPayload struct {
+	// Code is the status of the error response, which is always the same as the message's Status
+	Code int `json:"code"`
+
+	// Message describes the error
+	Message string `json:"message"`
+}
+
+// StatusCoder is implemented by errors which carry a status code.  xhttp.Error implements this interface.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// NewErrorResponse builds a WRP response reporting that the given request could not be processed.  The response
+// has the request's type and transaction UUID, its source and destination are swapped, and its payload is
+// an ErrorPayload.  The status should be an HTTP status code, as with every other WRP status.
+func NewErrorResponse(request *Message, status int, text string) *Message {
+	var (
+		code       = int64(status)
+		payload, _ = json.Marshal(ErrorPayload{Code: status, Message: text})
+	)
+
+	response := &Message{
+		Type:            request.Type,
+		Source:          request.Destination,
+		Destination:     request.Source,
+		TransactionUUID: request.TransactionUUID,
+		Path:            request.Path,
+		Status:          &code,
+		ContentType:     ErrorPayloadContentType,
+		Payload:         payload,
+	}
+
+	if len(request.PartnerIDs) > 0 {
+		response.PartnerIDs = append([]string(nil), request.PartnerIDs...)
+	}
+
+	return response
+}
+
+// NewErrorResponseFor is like NewErrorResponse, but takes the status and text from an error.  If the error, or any
+// error it wraps, implements StatusCoder, its status code is used.  Otherwise, the status is
+// http.StatusInternalServerError.
+func NewErrorResponseFor(request *Message, err error) *Message {
+	status := http.StatusInternalServerError
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		status = sc.StatusCode()
+	}
+
+	return NewErrorResponse(request, status, err.Error())
+}
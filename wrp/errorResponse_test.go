@@ -0,0 +1,106 @@
+package wrp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStatusError int
+
+func (tse testStatusError) Error() string {
+	return "status error"
+}
+
+func (tse testStatusError) StatusCode() int {
+	return int(tse)
+}
+
+func TestNewErrorResponse(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		request = &Message{
+			Type:            SimpleRequestResponseMessageType,
+			Source:          "dns:talaria.example.com",
+			Destination:     "mac:112233445566/config",
+			TransactionUUID: "test-transaction",
+			Path:            "/config",
+			PartnerIDs:      []string{"comcast"},
+			Metadata:        map[string]string{"key": "value"},
+			Payload:         []byte("request payload"),
+		}
+
+		response = NewErrorResponse(request, http.StatusServiceUnavailable, "device is busy")
+	)
+
+	require.NotNil(response)
+	assert.Equal(SimpleRequestResponseMessageType, response.Type)
+	assert.Equal("mac:112233445566/config", response.Source)
+	assert.Equal("dns:talaria.example.com", response.Destination)
+	assert.Equal("test-transaction", response.TransactionUUID)
+	assert.Equal("/config", response.Path)
+	assert.Equal([]string{"comcast"}, response.PartnerIDs)
+	assert.Empty(response.Metadata)
+	require.NotNil(response.Status)
+	assert.Equal(int64(http.StatusServiceUnavailable), *response.Status)
+	assert.Equal(ErrorPayloadContentType, response.ContentType)
+
+	var payload ErrorPayload
+	require.NoError(json.Unmarshal(response.Payload, &payload))
+	assert.Equal(ErrorPayload{Code: http.StatusServiceUnavailable, Message: "device is busy"}, payload)
+
+	// the response must not share state with the request
+	response.PartnerIDs[0] = "changed"
+	assert.Equal([]string{"comcast"}, request.PartnerIDs)
+
+	// the response is a valid WRP message in every format
+	for _, format := range AllFormats() {
+		var (
+			encoded []byte
+			decoded Message
+		)
+
+		require.NoError(NewEncoderBytes(&encoded, format).Encode(response))
+		require.NoError(NewDecoderBytes(encoded, format).Decode(&decoded))
+		assert.Equal(response.TransactionUUID, decoded.TransactionUUID)
+		assert.Equal(response.Status, decoded.Status)
+		assert.Equal(response.ContentType, decoded.ContentType)
+		assert.Equal(response.Payload, decoded.Payload)
+	}
+}
+
+func TestNewErrorResponseFor(t *testing.T) {
+	testData := []struct {
+		err            error
+		expectedStatus int
+	}{
+		{errors.New("unrecognized"), http.StatusInternalServerError},
+		{testStatusError(http.StatusNotFound), http.StatusNotFound},
+		{fmt.Errorf("wrapped: %w", testStatusError(http.StatusServiceUnavailable)), http.StatusServiceUnavailable},
+	}
+
+	for _, record := range testData {
+		t.Run(record.err.Error(), func(t *testing.T) {
+			var (
+				assert   = assert.New(t)
+				require  = require.New(t)
+				response = NewErrorResponseFor(&Message{Type: CreateMessageType, Source: "src", Destination: "dest"}, record.err)
+				payload  ErrorPayload
+			)
+
+			require.NotNil(response.Status)
+			assert.Equal(int64(record.expectedStatus), *response.Status)
+			assert.Equal(CreateMessageType, response.Type)
+			require.NoError(json.Unmarshal(response.Payload, &payload))
+			assert.Equal(record.err.Error(), payload.Message)
+			assert.Equal(record.expectedStatus, payload.Code)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"strings"
 
 	"github.com/ugorji/go/codec"
@@ -23,9 +24,14 @@ const (
 	lastFormat
 )
 
-// AllFormats returns a distinct slice of all supported formats.
+// AllFormats returns a distinct slice of all supported formats, including any registered custom formats.
 func AllFormats() []Format {
-	return []Format{Msgpack, JSON}
+	all := []Format{Msgpack, JSON}
+	for i := range customFormats() {
+		all = append(all, customFormatAt(i))
+	}
+
+	return all
 }
 
 var (
@@ -48,8 +54,26 @@ var (
 	}
 )
 
+// Name returns the name of this format.  For builtin formats, this is the same as String().  For custom
+// formats, this is the name supplied to RegisterFormat.
+func (f Format) Name() string {
+	if cf, ok := f.custom(); ok {
+		return cf.name
+	}
+
+	return f.String()
+}
+
 // ContentType returns the MIME type associated with this format
 func (f Format) ContentType() string {
+	if cf, ok := f.custom(); ok {
+		return cf.contentType
+	}
+
+	return f.builtinContentType()
+}
+
+func (f Format) builtinContentType() string {
 	switch f {
 	case Msgpack:
 		return "application/msgpack"
@@ -62,7 +86,8 @@ func (f Format) ContentType() string {
 
 // FormatFromContentType examines the Content-Type value and returns
 // the appropriate Format.  This function returns an error if the given
-// Content-Type did not map to a WRP format.
+// Content-Type did not map to a WRP format.  Registered custom formats are matched
+// on their exact media type, ignoring any parameters, before the builtin formats are considered.
 //
 // The optional fallback is used if contentType is the empty string.  Only
 // the first fallback value is used.  The rest are ignored.  This approach allows
@@ -78,6 +103,14 @@ func FormatFromContentType(contentType string, fallback ...Format) (Format, erro
 		return Format(-1), errors.New("Missing content type")
 	}
 
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		for i, cf := range customFormats() {
+			if cf.contentType == mediaType {
+				return customFormatAt(i), nil
+			}
+		}
+	}
+
 	if strings.Contains(contentType, "json") {
 		return JSON, nil
 	} else if strings.Contains(contentType, "msgpack") {
@@ -114,9 +147,10 @@ type Encoder interface {
 	ResetBytes(*[]byte)
 }
 
-// encoderDecorator wraps a ugorji Encoder and implements the wrp.Encoder interface.
+// encoderDecorator wraps an Encoder, either a ugorji Encoder or one produced by a FormatCodec,
+// so that EncodeListener is honored.
 type encoderDecorator struct {
-	*codec.Encoder
+	Encoder
 }
 
 // Encode checks to see if value implements EncoderTo and if it does, uses the
@@ -142,6 +176,10 @@ type Decoder interface {
 // NewEncoder produces a ugorji Encoder using the appropriate WRP configuration
 // for the given format
 func NewEncoder(output io.Writer, f Format) Encoder {
+	if cf, ok := f.custom(); ok {
+		return &encoderDecorator{cf.codec.NewEncoder(output)}
+	}
+
 	return &encoderDecorator{
 		codec.NewEncoder(output, f.handle()),
 	}
@@ -150,6 +188,10 @@ func NewEncoder(output io.Writer, f Format) Encoder {
 // NewEncoderBytes produces a ugorji Encoder using the appropriate WRP configuration
 // for the given format
 func NewEncoderBytes(output *[]byte, f Format) Encoder {
+	if cf, ok := f.custom(); ok {
+		return &encoderDecorator{cf.codec.NewEncoderBytes(output)}
+	}
+
 	return &encoderDecorator{
 		codec.NewEncoderBytes(output, f.handle()),
 	}
@@ -158,12 +200,20 @@ func NewEncoderBytes(output *[]byte, f Format) Encoder {
 // NewDecoder produces a ugorji Decoder using the appropriate WRP configuration
 // for the given format
 func NewDecoder(input io.Reader, f Format) Decoder {
+	if cf, ok := f.custom(); ok {
+		return cf.codec.NewDecoder(input)
+	}
+
 	return codec.NewDecoder(input, f.handle())
 }
 
 // NewDecoderBytes produces a ugorji Decoder using the appropriate WRP configuration
 // for the given format
 func NewDecoderBytes(input []byte, f Format) Decoder {
+	if cf, ok := f.custom(); ok {
+		return cf.codec.NewDecoderBytes(input)
+	}
+
 	return codec.NewDecoderBytes(input, f.handle())
 }
 
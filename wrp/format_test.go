@@ -206,6 +206,8 @@ func testFormatString(t *testing.T) {
 	assert.NotEmpty(Msgpack.String())
 	assert.NotEmpty(Format(-1).String())
 	assert.NotEqual(JSON.String(), Msgpack.String())
+	assert.Equal(JSON.String(), JSON.Name())
+	assert.Equal(Msgpack.String(), Msgpack.Name())
 }
 
 func testFormatHandle(t *testing.T) {
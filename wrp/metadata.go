@@ -0,0 +1,87 @@
+package wrp
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrMetadataTooLarge is returned when a message's metadata exceeds its size budget, even after trimming
+var ErrMetadataTooLarge = errors.New("WRP metadata exceeds the maximum size")
+
+// MetadataSize returns the aggregate size, in bytes, of a WRP metadata map.  This is the sum of the lengths of
+// each key and value, which approximates the contribution of the metadata to an encoded message.
+func MetadataSize(metadata map[string]string) int {
+	size := 0
+	for k, v := range metadata {
+		size += len(k) + len(v)
+	}
+
+	return size
+}
+
+// trimCandidates returns the keys in metadata matched by a trimmable entry.  An entry ending with '*' matches
+// all keys with that prefix, in sorted order so that trimming is deterministic.
+func trimCandidates(metadata map[string]string, entry string) []string {
+	if !strings.HasSuffix(entry, "*") {
+		if _, ok := metadata[entry]; ok {
+			return []string{entry}
+		}
+
+		return nil
+	}
+
+	var (
+		prefix     = entry[:len(entry)-1]
+		candidates []string
+	)
+
+	for k := range metadata {
+		if strings.HasPrefix(k, prefix) {
+			candidates = append(candidates, k)
+		}
+	}
+
+	sort.Strings(candidates)
+	return candidates
+}
+
+// TrimMetadata enforces a maximum aggregate size on a WRP metadata map, as computed by MetadataSize.  If the
+// metadata is over budget, the trimmable keys are deleted in the order given until the metadata fits.  A trimmable
+// entry ending with '*' matches every key with that prefix.  Keys that are not trimmable are never removed.
+//
+// The keys that were deleted are returned, in the order they were deleted.  If the metadata is still over budget
+// after all trimmable keys have been deleted, ErrMetadataTooLarge is returned.  A nonpositive maxSize means
+// the metadata is not limited.
+func TrimMetadata(metadata map[string]string, maxSize int, trimmable []string) ([]string, error) {
+	if maxSize < 1 {
+		return nil, nil
+	}
+
+	var (
+		size    = MetadataSize(metadata)
+		trimmed []string
+	)
+
+	for _, entry := range trimmable {
+		if size <= maxSize {
+			break
+		}
+
+		for _, k := range trimCandidates(metadata, entry) {
+			if size <= maxSize {
+				break
+			}
+
+			size -= len(k) + len(metadata[k])
+			delete(metadata, k)
+			trimmed = append(trimmed, k)
+		}
+	}
+
+	if size > maxSize {
+		return trimmed, ErrMetadataTooLarge
+	}
+
+	return trimmed, nil
+}
@@ -0,0 +1,107 @@
+package wrp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testMetadataSize(t *testing.T) {
+	assert := assert.New(t)
+	assert.Zero(MetadataSize(nil))
+	assert.Zero(MetadataSize(map[string]string{}))
+	assert.Equal(3, MetadataSize(map[string]string{"abc": ""}))
+	assert.Equal(13, MetadataSize(map[string]string{"abc": "de", "fghij": "klm"}))
+}
+
+func testTrimMetadataUnlimited(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		metadata = map[string]string{"key": "value"}
+	)
+
+	for _, maxSize := range []int{-1, 0} {
+		trimmed, err := TrimMetadata(metadata, maxSize, []string{"key"})
+		assert.Empty(trimmed)
+		assert.NoError(err)
+		assert.Equal(map[string]string{"key": "value"}, metadata)
+	}
+}
+
+func testTrimMetadataWithinBudget(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		metadata = map[string]string{"key": "value"}
+	)
+
+	trimmed, err := TrimMetadata(metadata, 8, []string{"key"})
+	assert.Empty(trimmed)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"key": "value"}, metadata)
+}
+
+func testTrimMetadataTrimmed(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		metadata = map[string]string{
+			"/hw-model":        "abc",
+			"/boot-time":       "1234567890",
+			"/trace/first":     "12345",
+			"/trace/second":    "12345",
+			"/last-reconnect":  "something",
+			"/fw-name":         "xyz",
+			"/not-trimmable-1": "",
+		}
+
+		originalSize = MetadataSize(metadata)
+	)
+
+	// trimming stops as soon as the metadata fits, even partway through a prefix
+	trimmed, err := TrimMetadata(
+		metadata,
+		originalSize-len("/boot-time1234567890")-len("/trace/first12345"),
+		[]string{"/nosuch", "/boot-time", "/trace/*", "/last-reconnect"},
+	)
+
+	assert.Equal([]string{"/boot-time", "/trace/first"}, trimmed)
+	assert.NoError(err)
+	assert.Equal(
+		map[string]string{
+			"/hw-model":        "abc",
+			"/trace/second":    "12345",
+			"/last-reconnect":  "something",
+			"/fw-name":         "xyz",
+			"/not-trimmable-1": "",
+		},
+		metadata,
+	)
+}
+
+func testTrimMetadataTooLarge(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		metadata = map[string]string{
+			"/hw-model":   "abc",
+			"/boot-time":  "1234567890",
+			"/trace/one":  "1",
+			"/trace/two":  "2",
+			"/trace/tree": "3",
+		}
+	)
+
+	trimmed, err := TrimMetadata(metadata, 5, []string{"/trace/*", "/boot-time"})
+	assert.Equal([]string{"/trace/one", "/trace/tree", "/trace/two", "/boot-time"}, trimmed)
+	assert.Equal(ErrMetadataTooLarge, err)
+	assert.Equal(map[string]string{"/hw-model": "abc"}, metadata)
+}
+
+func TestMetadataSize(t *testing.T) {
+	testMetadataSize(t)
+}
+
+func TestTrimMetadata(t *testing.T) {
+	t.Run("Unlimited", testTrimMetadataUnlimited)
+	t.Run("WithinBudget", testTrimMetadataWithinBudget)
+	t.Run("Trimmed", testTrimMetadataTrimmed)
+	t.Run("TooLarge", testTrimMetadataTooLarge)
+}
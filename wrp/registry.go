@@ -0,0 +1,152 @@
+package wrp
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"strings"
+	"sync"
+
+	"github.com/ugorji/go/codec"
+)
+
+var (
+	// ErrFormatExists indicates that a custom format's name or content type is already in use
+	ErrFormatExists = errors.New("A WRP format with that name or content type already exists")
+
+	// ErrInvalidFormat indicates that a custom format was registered without a name, content type, or codec
+	ErrInvalidFormat = errors.New("A WRP format requires a name, a content type, and a codec")
+)
+
+// FormatCodec creates encoders and decoders for a custom WRP format.  Encoders produced by a FormatCodec
+// are decorated so that EncodeListener is honored, just as with the builtin formats.
+type FormatCodec interface {
+	NewEncoder(io.Writer) Encoder
+	NewEncoderBytes(*[]byte) Encoder
+	NewDecoder(io.Reader) Decoder
+	NewDecoderBytes([]byte) Decoder
+}
+
+// handleCodec is a FormatCodec backed by a ugorji codec.Handle
+type handleCodec struct {
+	handle codec.Handle
+}
+
+// NewHandleCodec adapts a ugorji codec.Handle, such as a codec.CborHandle, into a FormatCodec.
+// For WRP structs to encode correctly, the handle's TypeInfos should use the "wrp" struct tag:
+//
+//   handle := &codec.CborHandle{
+//     BasicHandle: codec.BasicHandle{
+//       TypeInfos: codec.NewTypeInfos([]string{"wrp"}),
+//     },
+//   }
+func NewHandleCodec(h codec.Handle) FormatCodec {
+	if h == nil {
+		panic("A codec.Handle is required")
+	}
+
+	return handleCodec{h}
+}
+
+func (hc handleCodec) NewEncoder(output io.Writer) Encoder {
+	return codec.NewEncoder(output, hc.handle)
+}
+
+func (hc handleCodec) NewEncoderBytes(output *[]byte) Encoder {
+	return codec.NewEncoderBytes(output, hc.handle)
+}
+
+func (hc handleCodec) NewDecoder(input io.Reader) Decoder {
+	return codec.NewDecoder(input, hc.handle)
+}
+
+func (hc handleCodec) NewDecoderBytes(input []byte) Decoder {
+	return codec.NewDecoderBytes(input, hc.handle)
+}
+
+// customFormat is a registered, application-supplied WRP format
+type customFormat struct {
+	name        string
+	contentType string
+	codec       FormatCodec
+}
+
+// formats holds the custom formats, indexed as described by customFormatAt
+var formats struct {
+	lock   sync.RWMutex
+	custom []customFormat
+}
+
+// RegisterFormat adds a custom WRP format, identified by a name and a MIME content type.  The returned Format
+// can be used anywhere a builtin Format can, and FormatFromContentType will recognize the content type, which
+// allows HTTP and device code to negotiate the custom format.
+//
+// Formats are typically registered once, during application initialization.
+func RegisterFormat(name, contentType string, c FormatCodec) (Format, error) {
+	if len(name) == 0 || len(contentType) == 0 || c == nil {
+		return Format(-1), ErrInvalidFormat
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Format(-1), err
+	}
+
+	formats.lock.Lock()
+	defer formats.lock.Unlock()
+
+	for f := Format(0); f < lastFormat; f++ {
+		if strings.EqualFold(f.String(), name) || f.builtinContentType() == mediaType {
+			return Format(-1), ErrFormatExists
+		}
+	}
+
+	for _, existing := range formats.custom {
+		if strings.EqualFold(existing.name, name) || existing.contentType == mediaType {
+			return Format(-1), ErrFormatExists
+		}
+	}
+
+	formats.custom = append(formats.custom, customFormat{name: name, contentType: mediaType, codec: c})
+	return customFormatAt(len(formats.custom) - 1), nil
+}
+
+// FormatFromName returns the Format, builtin or custom, with the given name.  Names are not case sensitive.
+func FormatFromName(name string) (Format, bool) {
+	for _, f := range AllFormats() {
+		if strings.EqualFold(f.Name(), name) {
+			return f, true
+		}
+	}
+
+	return Format(-1), false
+}
+
+// customFormatAt returns the Format for the custom format at the given index.  Custom formats start after
+// lastFormat, so that the generated String method never reports a custom format as lastFormat.
+func customFormatAt(i int) Format {
+	return lastFormat + 1 + Format(i)
+}
+
+// custom returns the registered custom format for this Format, if any
+func (f Format) custom() (customFormat, bool) {
+	if f <= lastFormat {
+		return customFormat{}, false
+	}
+
+	formats.lock.RLock()
+	defer formats.lock.RUnlock()
+
+	if i := int(f - lastFormat - 1); i < len(formats.custom) {
+		return formats.custom[i], true
+	}
+
+	return customFormat{}, false
+}
+
+// customFormats returns a snapshot of the registered custom formats
+func customFormats() []customFormat {
+	formats.lock.RLock()
+	defer formats.lock.RUnlock()
+	return append([]customFormat{}, formats.custom...)
+}
@@ -0,0 +1,151 @@
+package wrp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+func newTestCborCodec() FormatCodec {
+	return NewHandleCodec(&codec.CborHandle{
+		BasicHandle: codec.BasicHandle{
+			TypeInfos: codec.NewTypeInfos([]string{"wrp"}),
+		},
+	})
+}
+
+// withFreshFormats runs a test against an empty set of custom formats, restoring the previously
+// registered formats afterward.  This keeps registrations from leaking between tests and between runs.
+func withFreshFormats(test func()) {
+	formats.lock.Lock()
+	saved := formats.custom
+	formats.custom = nil
+	formats.lock.Unlock()
+
+	defer func() {
+		formats.lock.Lock()
+		formats.custom = saved
+		formats.lock.Unlock()
+	}()
+
+	test()
+}
+
+func TestNewHandleCodec(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { NewHandleCodec(nil) })
+	assert.NotNil(newTestCborCodec())
+}
+
+func TestRegisterFormatInvalid(t *testing.T) {
+	withFreshFormats(func() { testRegisterFormatInvalid(t) })
+}
+
+func testRegisterFormatInvalid(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		c      = newTestCborCodec()
+	)
+
+	for _, record := range []struct {
+		name        string
+		contentType string
+		codec       FormatCodec
+		expectedErr error
+	}{
+		{"", "application/x-test-invalid", c, ErrInvalidFormat},
+		{"TestInvalid", "", c, ErrInvalidFormat},
+		{"TestInvalid", "application/x-test-invalid", nil, ErrInvalidFormat},
+		{"json", "application/x-test-invalid", c, ErrFormatExists},
+		{"TestInvalid", "application/msgpack; charset=binary", c, ErrFormatExists},
+	} {
+		f, err := RegisterFormat(record.name, record.contentType, record.codec)
+		assert.Equal(Format(-1), f)
+		assert.Equal(record.expectedErr, err)
+	}
+
+	f, err := RegisterFormat("TestInvalid", "this is not a media type;", c)
+	assert.Equal(Format(-1), f)
+	assert.Error(err)
+}
+
+func TestRegisterFormat(t *testing.T) {
+	withFreshFormats(func() { testRegisterFormat(t) })
+}
+
+func testRegisterFormat(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		original = Message{
+			Type:        SimpleRequestResponseMessageType,
+			Source:      "dns:talaria.xmidt.comcast.net",
+			Destination: "mac:112233445566/service",
+			Payload:     []byte("hi!"),
+		}
+	)
+
+	cbor, err := RegisterFormat("TestCBOR", "application/cbor", newTestCborCodec())
+	require.NoError(err)
+	assert.True(cbor > lastFormat)
+	assert.Equal("TestCBOR", cbor.Name())
+	assert.Equal("application/cbor", cbor.ContentType())
+	assert.Contains(AllFormats(), cbor)
+
+	_, err = RegisterFormat("testcbor", "application/x-cbor", newTestCborCodec())
+	assert.Equal(ErrFormatExists, err)
+
+	_, err = RegisterFormat("TestCBOR2", "application/cbor", newTestCborCodec())
+	assert.Equal(ErrFormatExists, err)
+
+	actual, ok := FormatFromName("testcbor")
+	assert.True(ok)
+	assert.Equal(cbor, actual)
+
+	actual, err = FormatFromContentType("application/cbor; charset=binary")
+	assert.NoError(err)
+	assert.Equal(cbor, actual)
+
+	var (
+		output  bytes.Buffer
+		encoded []byte
+		decoded Message
+	)
+
+	require.NoError(NewEncoder(&output, cbor).Encode(&original))
+	require.NoError(NewEncoderBytes(&encoded, cbor).Encode(&original))
+	assert.Equal(output.Bytes(), encoded)
+
+	require.NoError(NewDecoder(&output, cbor).Decode(&decoded))
+	assert.Equal(original, decoded)
+
+	decoded = Message{}
+	require.NoError(NewDecoderBytes(encoded, cbor).Decode(&decoded))
+	assert.Equal(original, decoded)
+
+	transcoded := MustEncode(&original, Msgpack)
+	output.Reset()
+	_, err = TranscodeMessage(NewEncoder(&output, cbor), NewDecoderBytes(transcoded, Msgpack))
+	require.NoError(err)
+	assert.Equal(encoded, output.Bytes())
+}
+
+func TestFormatFromName(t *testing.T) {
+	assert := assert.New(t)
+
+	f, ok := FormatFromName("JSON")
+	assert.True(ok)
+	assert.Equal(JSON, f)
+
+	f, ok = FormatFromName("msgpack")
+	assert.True(ok)
+	assert.Equal(Msgpack, f)
+
+	f, ok = FormatFromName("nosuch")
+	assert.False(ok)
+	assert.Equal(Format(-1), f)
+}
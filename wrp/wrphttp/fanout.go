@@ -1,6 +1,8 @@
 package wrphttp
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/url"
 	"time"
@@ -11,6 +13,7 @@ import (
 	"github.com/Comcast/webpa-common/tracing"
 	"github.com/Comcast/webpa-common/transport/transporthttp"
 	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
 	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
@@ -60,6 +63,10 @@ type FanoutOptions struct {
 
 	// Middleware is the extra Middleware to append, which can (and often is) empty
 	Middleware []endpoint.Middleware `json:"-"`
+
+	// WRPErrors controls how a failed fanout of a transactional message is reported.  If true, the endpoint
+	// returns a WRP error response, as produced by ErrorResponses, rather than an error.
+	WRPErrors bool `json:"wrpErrors"`
 }
 
 func (f *FanoutOptions) logger() log.Logger {
@@ -162,6 +169,47 @@ func (f *FanoutOptions) middleware() []endpoint.Middleware {
 	return nil
 }
 
+func (f *FanoutOptions) wrpErrors() bool {
+	return f != nil && f.WRPErrors
+}
+
+// errorStatus determines the WRP status for an error returned by a fanout.  The errors from the fanout
+// are usually wrapped, e.g. in a tracing.SpanError, so the causes are examined as well.
+func errorStatus(err error) int {
+	var sc wrp.StatusCoder
+	switch {
+	case errors.As(err, &sc):
+		return sc.StatusCode()
+
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return http.StatusGatewayTimeout
+
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ErrorResponses is an endpoint.Middleware which reports the failure of a transactional WRP request as a WRP error
+// response, built with wrp.NewErrorResponse, instead of as an error.  The response's status is taken from the error.
+// Errors for any other request are returned as is.
+func ErrorResponses(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, value interface{}) (interface{}, error) {
+		response, err := next(ctx, value)
+		if err == nil {
+			return response, nil
+		}
+
+		request, ok := value.(wrpendpoint.Request)
+		if !ok || !request.Message().IsTransactionPart() {
+			return nil, err
+		}
+
+		return wrpendpoint.WrapAsResponse(
+			wrp.NewErrorResponse(request.Message(), errorStatus(err), err.Error()),
+		), nil
+	}
+}
+
 // NewFanoutEndpoint uses the supplied options to produce a go-kit HTTP server endpoint which
 // fans out to the HTTP endpoints specified in the options.  The endpoint returned from this
 // can be used to build one or more go-kit transport/http.Server objects.
@@ -218,6 +266,11 @@ func NewFanoutEndpoint(o *FanoutOptions) (endpoint.Endpoint, error) {
 		)
 	)
 
+	if o.wrpErrors() {
+		// outermost, so that errors from every other middleware are reported as WRP responses too
+		middlewareChain = append([]endpoint.Middleware{ErrorResponses}, middlewareChain...)
+	}
+
 	return endpoint.Chain(
 			middlewareChain[0],
 			middlewareChain[1:]...,
@@ -2,6 +2,7 @@ package wrphttp
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -9,8 +10,10 @@ import (
 	"time"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/tracing"
 	"github.com/Comcast/webpa-common/wrp"
 	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -41,6 +44,7 @@ func testFanoutOptionsDefaults(t *testing.T, o *FanoutOptions) {
 	assert.Equal(DefaultMaxClients, o.maxClients())
 	assert.Equal(DefaultConcurrency, o.concurrency())
 	assert.Empty(o.middleware())
+	assert.False(o.wrpErrors())
 }
 
 func testFanoutOptionsConfigured(t *testing.T) {
@@ -63,6 +67,7 @@ func testFanoutOptionsConfigured(t *testing.T) {
 			ClientTimeout: 37 * time.Second,
 			MaxClients:    38734,
 			Concurrency:   3249,
+			WRPErrors:     true,
 			Middleware: []endpoint.Middleware{
 				func(e endpoint.Endpoint) endpoint.Endpoint {
 					middlewareCalled = true
@@ -92,6 +97,7 @@ func testFanoutOptionsConfigured(t *testing.T) {
 	assert.Equal(37*time.Second, o.clientTimeout())
 	assert.Equal(int64(38734), o.maxClients())
 	assert.Equal(3249, o.concurrency())
+	assert.True(o.wrpErrors())
 
 	middleware := o.middleware()
 	require.Len(middleware, 1)
@@ -188,7 +194,127 @@ func testNewFanoutEndpointBadURL(t *testing.T) {
 	assert.Error(err)
 }
 
+func testNewFanoutEndpointWRPErrors(t *testing.T) {
+	var (
+		require = require.New(t)
+		assert  = assert.New(t)
+		logger  = logging.NewTestLogger(nil, t)
+
+		request = &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "test",
+			Destination:     "mac:123412341234",
+			TransactionUUID: "test-transaction",
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(http.StatusNotFound)
+		}))
+
+		o = &FanoutOptions{
+			Endpoints: []string{server.URL},
+			WRPErrors: true,
+		}
+	)
+
+	defer server.Close()
+	fanoutEndpoint, err := NewFanoutEndpoint(o)
+	require.NotNil(fanoutEndpoint)
+	require.NoError(err)
+
+	result, err := fanoutEndpoint(
+		context.Background(),
+		wrpendpoint.WrapAsRequest(logger, request),
+	)
+
+	require.NoError(err)
+	require.NotNil(result)
+
+	response := result.(wrpendpoint.Response).Message()
+	assert.Equal("mac:123412341234", response.Source)
+	assert.Equal("test", response.Destination)
+	assert.Equal("test-transaction", response.TransactionUUID)
+	require.NotNil(response.Status)
+	assert.Equal(int64(http.StatusNotFound), *response.Status)
+}
+
 func TestNewFanoutEndpoint(t *testing.T) {
 	t.Run("SendReceive", testNewFanoutEndpointSendReceive)
 	t.Run("BadURL", testNewFanoutEndpointBadURL)
+	t.Run("WRPErrors", testNewFanoutEndpointWRPErrors)
+}
+
+func TestErrorResponses(t *testing.T) {
+	var (
+		logger = logging.NewTestLogger(nil, t)
+
+		transaction = &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "test",
+			Destination:     "mac:123412341234",
+			TransactionUUID: "test-transaction",
+		}
+
+		event = &wrp.Message{
+			Type:        wrp.SimpleEventMessageType,
+			Source:      "test",
+			Destination: "mac:123412341234",
+		}
+
+		testData = []struct {
+			description    string
+			request        *wrp.Message
+			err            error
+			expectedStatus int
+		}{
+			{"StatusCoder", transaction, &xhttp.Error{Code: http.StatusTooManyRequests}, http.StatusTooManyRequests},
+			{"SpanError", transaction, tracing.NewSpanError(&xhttp.Error{Code: http.StatusNotFound}), http.StatusNotFound},
+			{"Timeout", transaction, tracing.NewSpanError(context.DeadlineExceeded), http.StatusGatewayTimeout},
+			{"Other", transaction, errors.New("expected"), http.StatusInternalServerError},
+			{"Event", event, errors.New("expected"), 0},
+		}
+	)
+
+	for _, record := range testData {
+		t.Run(record.description, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				errorResponses = ErrorResponses(func(context.Context, interface{}) (interface{}, error) {
+					return nil, record.err
+				})
+			)
+
+			result, err := errorResponses(context.Background(), wrpendpoint.WrapAsRequest(logger, record.request))
+			if record.expectedStatus == 0 {
+				assert.Nil(result)
+				assert.Equal(record.err, err)
+				return
+			}
+
+			require.NoError(err)
+			require.NotNil(result)
+
+			response := result.(wrpendpoint.Response).Message()
+			assert.Equal(record.request.TransactionUUID, response.TransactionUUID)
+			require.NotNil(response.Status)
+			assert.Equal(int64(record.expectedStatus), *response.Status)
+		})
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			expected = wrpendpoint.WrapAsResponse(transaction)
+
+			errorResponses = ErrorResponses(func(context.Context, interface{}) (interface{}, error) {
+				return expected, nil
+			})
+		)
+
+		result, err := errorResponses(context.Background(), wrpendpoint.WrapAsRequest(logger, transaction))
+		assert.Equal(expected, result)
+		assert.NoError(err)
+	})
 }
@@ -0,0 +1,14 @@
+/*
+Package wrpsign provides detached signatures for WRP messages.  A Signer computes a signature over a message
+and carries it, along with the identifier of the signing key, in the message's metadata.  A Verifier at any later
+hop resolves the corresponding public key and checks that the message has not changed.
+
+Keys are obtained through the secure/key package.  A Signer's resolver must produce key pairs with RSA private
+keys, e.g. a resolver configured with key.PurposeSign, while a Verifier's resolver only needs the public keys.
+
+The signature covers a canonical encoding of the message type, source, destination, transaction UUID, content
+type, partner ids, and payload, along with the identifier of the signing key.  A message cannot be redirected
+to another destination or attributed to another partner without invalidating its signature.  Metadata, other
+than the signature itself, and headers are not signed, so intermediate hops remain free to annotate messages.
+*/
+package wrpsign
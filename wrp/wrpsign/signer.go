@@ -0,0 +1,121 @@
+package wrpsign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"strconv"
+
+	"github.com/Comcast/webpa-common/secure/key"
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+const (
+	// SignatureKey is the metadata key for the base64-encoded message signature
+	SignatureKey = "/wrp-signature"
+
+	// KeyIDKey is the metadata key for the identifier of the key that produced the signature
+	KeyIDKey = "/wrp-signature-kid"
+
+	// AlgorithmKey is the metadata key for the signature algorithm
+	AlgorithmKey = "/wrp-signature-alg"
+
+	// RS256 is the only supported signature algorithm:  RSASSA-PKCS1-v1_5 using SHA-256
+	RS256 = "RS256"
+)
+
+var (
+	ErrorNoPrivateKey     = errors.New("The signing key has no RSA private key")
+	ErrorNoPublicKey      = errors.New("The verifying key has no RSA public key")
+	ErrorMissingKeyID     = errors.New("A key identifier is required")
+	ErrorMissingSignature = errors.New("The message has no payload signature")
+)
+
+// signatureVersion prefixes the canonical encoding of a message, so that the encoding can change without
+// signatures made under one encoding verifying under another
+const signatureVersion = "wrpsign-v1"
+
+// writeField appends a length-prefixed field to a canonical encoding.  The length prefix ensures that
+// no two distinct sequences of fields produce the same encoding.
+func writeField(h hash.Hash, field []byte) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(field)))
+	h.Write(length[:])
+	h.Write(field)
+}
+
+// digest produces the hash that is actually signed.  This is the hash of a canonical encoding of the message's
+// routing fields, the identifier of the signing key, and the payload.  Covering the key identifier prevents a
+// signature from being replayed under a different key.
+func digest(m *wrp.Message, keyID string) []byte {
+	h := sha256.New()
+	writeField(h, []byte(signatureVersion))
+	writeField(h, []byte(RS256))
+	writeField(h, []byte(keyID))
+	writeField(h, []byte(strconv.FormatInt(int64(m.Type), 10)))
+	writeField(h, []byte(m.Source))
+	writeField(h, []byte(m.Destination))
+	writeField(h, []byte(m.TransactionUUID))
+	writeField(h, []byte(m.ContentType))
+
+	writeField(h, []byte(strconv.Itoa(len(m.PartnerIDs))))
+	for _, partnerID := range m.PartnerIDs {
+		writeField(h, []byte(partnerID))
+	}
+
+	writeField(h, m.Payload)
+	return h.Sum(nil)
+}
+
+// Signer produces detached payload signatures for WRP messages
+type Signer struct {
+	keyID    string
+	resolver key.Resolver
+}
+
+// NewSigner creates a Signer which signs with the key that the given resolver returns for keyID.
+// The key is resolved on each signature, so any caching or key rotation is up to the resolver.
+func NewSigner(keyID string, resolver key.Resolver) (*Signer, error) {
+	if len(keyID) == 0 {
+		return nil, ErrorMissingKeyID
+	}
+
+	if resolver == nil {
+		return nil, errors.New("A key resolver is required")
+	}
+
+	return &Signer{keyID: keyID, resolver: resolver}, nil
+}
+
+// Sign computes the signature of the given message and stores it in the message's metadata, replacing any
+// existing signature.  The metadata map is created if necessary.
+func (s *Signer) Sign(m *wrp.Message) error {
+	pair, err := s.resolver.ResolveKey(s.keyID)
+	if err != nil {
+		return err
+	}
+
+	privateKey, ok := pair.Private().(*rsa.PrivateKey)
+	if !ok {
+		return ErrorNoPrivateKey
+	}
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest(m, s.keyID))
+	if err != nil {
+		return err
+	}
+
+	if m.Metadata == nil {
+		m.Metadata = make(map[string]string, 3)
+	}
+
+	m.Metadata[SignatureKey] = base64.RawURLEncoding.EncodeToString(signature)
+	m.Metadata[KeyIDKey] = s.keyID
+	m.Metadata[AlgorithmKey] = RS256
+	return nil
+}
@@ -0,0 +1,131 @@
+package wrpsign
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Comcast/webpa-common/secure/key"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testKeyOnce sync.Once
+	testKey     *rsa.PrivateKey
+	testKeyErr  error
+)
+
+// loadTestPair produces a key Pair for the given purpose, parsed from the PEM encoding of a key generated once
+// for all tests.  Signing pairs have the private key, while verifying pairs have only the public key.
+func loadTestPair(t *testing.T, purpose key.Purpose) key.Pair {
+	testKeyOnce.Do(func() {
+		testKey, testKeyErr = rsa.GenerateKey(rand.Reader, 2048)
+	})
+
+	require.NoError(t, testKeyErr)
+
+	var block *pem.Block
+	if purpose.RequiresPrivateKey() {
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(testKey)}
+	} else {
+		data, err := x509.MarshalPKIXPublicKey(&testKey.PublicKey)
+		require.NoError(t, err)
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: data}
+	}
+
+	pair, err := key.DefaultParser.ParseKey(purpose, pem.EncodeToMemory(block))
+	require.NoError(t, err)
+	return pair
+}
+
+func testNewSignerMissingKeyID(t *testing.T) {
+	assert := assert.New(t)
+	s, err := NewSigner("", new(key.MockResolver))
+	assert.Nil(s)
+	assert.Equal(ErrorMissingKeyID, err)
+}
+
+func testNewSignerMissingResolver(t *testing.T) {
+	assert := assert.New(t)
+	s, err := NewSigner("test", nil)
+	assert.Nil(s)
+	assert.Error(err)
+}
+
+func TestNewSigner(t *testing.T) {
+	t.Run("MissingKeyID", testNewSignerMissingKeyID)
+	t.Run("MissingResolver", testNewSignerMissingResolver)
+}
+
+func testSignerSign(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		resolver = new(key.MockResolver)
+		message  = &wrp.Message{Type: wrp.SimpleEventMessageType, Payload: []byte("payload")}
+	)
+
+	resolver.On("ResolveKey", "testkey").Return(loadTestPair(t, key.PurposeSign), nil).Once()
+	s, err := NewSigner("testkey", resolver)
+	require.NoError(err)
+	require.NotNil(s)
+
+	require.NoError(s.Sign(message))
+	assert.True(Signed(message))
+	assert.Equal("testkey", message.Metadata[KeyIDKey])
+	assert.Equal(RS256, message.Metadata[AlgorithmKey])
+
+	signature, err := base64.RawURLEncoding.DecodeString(message.Metadata[SignatureKey])
+	assert.NoError(err)
+	assert.NotEmpty(signature)
+
+	resolver.AssertExpectations(t)
+}
+
+func testSignerResolveError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		resolver      = new(key.MockResolver)
+		message       = &wrp.Message{Type: wrp.SimpleEventMessageType, Payload: []byte("payload")}
+	)
+
+	resolver.On("ResolveKey", "testkey").Return(nil, expectedError).Once()
+	s, err := NewSigner("testkey", resolver)
+	require.NoError(err)
+
+	assert.Equal(expectedError, s.Sign(message))
+	assert.False(Signed(message))
+	resolver.AssertExpectations(t)
+}
+
+func testSignerNoPrivateKey(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		resolver = new(key.MockResolver)
+		message  = &wrp.Message{Type: wrp.SimpleEventMessageType, Payload: []byte("payload")}
+	)
+
+	resolver.On("ResolveKey", "testkey").Return(loadTestPair(t, key.PurposeVerify), nil).Once()
+	s, err := NewSigner("testkey", resolver)
+	require.NoError(err)
+
+	assert.Equal(ErrorNoPrivateKey, s.Sign(message))
+	assert.False(Signed(message))
+	resolver.AssertExpectations(t)
+}
+
+func TestSigner(t *testing.T) {
+	t.Run("Sign", testSignerSign)
+	t.Run("ResolveError", testSignerResolveError)
+	t.Run("NoPrivateKey", testSignerNoPrivateKey)
+}
@@ -0,0 +1,69 @@
+package wrpsign
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/Comcast/webpa-common/secure/key"
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+// Verifier checks detached message signatures produced by a Signer
+type Verifier struct {
+	resolver key.Resolver
+}
+
+// NewVerifier creates a Verifier which resolves verification keys by the key identifier carried in each message
+func NewVerifier(resolver key.Resolver) (*Verifier, error) {
+	if resolver == nil {
+		return nil, errors.New("A key resolver is required")
+	}
+
+	return &Verifier{resolver: resolver}, nil
+}
+
+// Signed tests if the given message carries a signature.  A message can be signed without
+// the signature being valid.
+func Signed(m *wrp.Message) bool {
+	_, ok := m.Metadata[SignatureKey]
+	return ok
+}
+
+// Verify checks the signature of the given message.  A nil error indicates that the payload and routing fields
+// are exactly what the holder of the signing key signed.  Unsigned messages produce ErrorMissingSignature, which allows
+// callers to decide whether signatures are required.
+func (v *Verifier) Verify(m *wrp.Message) error {
+	encoded, ok := m.Metadata[SignatureKey]
+	if !ok {
+		return ErrorMissingSignature
+	}
+
+	keyID := m.Metadata[KeyIDKey]
+	if len(keyID) == 0 {
+		return ErrorMissingKeyID
+	}
+
+	if algorithm := m.Metadata[AlgorithmKey]; algorithm != RS256 {
+		return fmt.Errorf("Unsupported signature algorithm: %s", algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("Badly formatted signature: %s", err)
+	}
+
+	pair, err := v.resolver.ResolveKey(keyID)
+	if err != nil {
+		return err
+	}
+
+	publicKey, ok := pair.Public().(*rsa.PublicKey)
+	if !ok {
+		return ErrorNoPublicKey
+	}
+
+	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest(m, keyID), signature)
+}
@@ -0,0 +1,139 @@
+package wrpsign
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Comcast/webpa-common/secure/key"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSignedMessage produces a message signed with the test key
+func newSignedMessage(t *testing.T) *wrp.Message {
+	resolver := new(key.MockResolver)
+	resolver.On("ResolveKey", "testkey").Return(loadTestPair(t, key.PurposeSign), nil)
+
+	s, err := NewSigner("testkey", resolver)
+	require.NoError(t, err)
+
+	message := &wrp.Message{
+		Type:        wrp.SimpleEventMessageType,
+		Source:      "dns:talaria",
+		Destination: "event:device-status",
+		PartnerIDs:  []string{"comcast", ",sky"},
+		Payload:     []byte("payload"),
+		Metadata:    map[string]string{"/boot-time": "1234"},
+	}
+
+	require.NoError(t, s.Sign(message))
+	return message
+}
+
+func TestNewVerifier(t *testing.T) {
+	assert := assert.New(t)
+	v, err := NewVerifier(nil)
+	assert.Nil(v)
+	assert.Error(err)
+}
+
+func testVerifierValid(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		resolver = new(key.MockResolver)
+		message  = newSignedMessage(t)
+	)
+
+	resolver.On("ResolveKey", "testkey").Return(loadTestPair(t, key.PurposeVerify), nil).Once()
+	v, err := NewVerifier(resolver)
+	require.NoError(err)
+	require.NotNil(v)
+
+	// metadata and headers are not signed, so they can change between hops
+	message.Metadata["/hop"] = "scytale"
+	message.Headers = append(message.Headers, "X-Hop: scytale")
+
+	assert.NoError(v.Verify(message))
+	assert.Equal("1234", message.Metadata["/boot-time"])
+	resolver.AssertExpectations(t)
+}
+
+func testVerifierInvalid(t *testing.T) {
+	testData := []struct {
+		name   string
+		modify func(*wrp.Message)
+	}{
+		{"MissingSignature", func(m *wrp.Message) { delete(m.Metadata, SignatureKey) }},
+		{"MissingKeyID", func(m *wrp.Message) { delete(m.Metadata, KeyIDKey) }},
+		{"UnsupportedAlgorithm", func(m *wrp.Message) { m.Metadata[AlgorithmKey] = "HS256" }},
+		{"BadlyFormattedSignature", func(m *wrp.Message) { m.Metadata[SignatureKey] = "!!!" }},
+		{"ModifiedPayload", func(m *wrp.Message) { m.Payload = []byte("modified") }},
+		{"ModifiedType", func(m *wrp.Message) { m.Type = wrp.SimpleRequestResponseMessageType }},
+		{"ModifiedSource", func(m *wrp.Message) { m.Source = "dns:scytale" }},
+		{"ModifiedDestination", func(m *wrp.Message) { m.Destination = "mac:112233445566" }},
+		{"ModifiedTransactionUUID", func(m *wrp.Message) { m.TransactionUUID = "modified" }},
+		{"ModifiedContentType", func(m *wrp.Message) { m.ContentType = "text/plain" }},
+		{"ModifiedPartnerIDs", func(m *wrp.Message) { m.PartnerIDs = append(m.PartnerIDs, "other") }},
+		{"ShiftedPartnerIDs", func(m *wrp.Message) { m.PartnerIDs = []string{"comcast,", "sky"} }},
+		{"ModifiedKeyID", func(m *wrp.Message) { m.Metadata[KeyIDKey] = "otherkey" }},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert   = assert.New(t)
+				require  = require.New(t)
+				resolver = new(key.MockResolver)
+				message  = newSignedMessage(t)
+			)
+
+			resolver.On("ResolveKey", "testkey").Return(loadTestPair(t, key.PurposeVerify), nil).Maybe()
+			resolver.On("ResolveKey", "otherkey").Return(loadTestPair(t, key.PurposeVerify), nil).Maybe()
+			v, err := NewVerifier(resolver)
+			require.NoError(err)
+
+			record.modify(message)
+			assert.Error(v.Verify(message))
+		})
+	}
+}
+
+func testVerifierUnsigned(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		message = &wrp.Message{Type: wrp.SimpleEventMessageType, Payload: []byte("payload")}
+	)
+
+	v, err := NewVerifier(new(key.MockResolver))
+	require.NoError(err)
+
+	assert.False(Signed(message))
+	assert.Equal(ErrorMissingSignature, v.Verify(message))
+}
+
+func testVerifierResolveError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		expectedError = errors.New("expected")
+		resolver      = new(key.MockResolver)
+		message       = newSignedMessage(t)
+	)
+
+	resolver.On("ResolveKey", "testkey").Return(nil, expectedError).Once()
+	v, err := NewVerifier(resolver)
+	require.NoError(err)
+
+	assert.Equal(expectedError, v.Verify(message))
+	resolver.AssertExpectations(t)
+}
+
+func TestVerifier(t *testing.T) {
+	t.Run("Valid", testVerifierValid)
+	t.Run("Invalid", testVerifierInvalid)
+	t.Run("Unsigned", testVerifierUnsigned)
+	t.Run("ResolveError", testVerifierResolveError)
+}
@@ -0,0 +1,137 @@
+package wrptest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+)
+
+// testingT is the expected behavior for a testing object.  *testing.T implements this interface.
+type testingT interface {
+	Errorf(string, ...interface{})
+}
+
+// EncodeFunc is the signature of an encoder under test
+type EncodeFunc func(*wrp.Message, wrp.Format) ([]byte, error)
+
+// DecodeFunc is the signature of a decoder under test
+type DecodeFunc func([]byte, wrp.Format) (*wrp.Message, error)
+
+// Encode is the reference EncodeFunc, which uses this library's wrp.Encoder
+func Encode(message *wrp.Message, f wrp.Format) ([]byte, error) {
+	var output []byte
+	if err := wrp.NewEncoderBytes(&output, f).Encode(message); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// Decode is the reference DecodeFunc, which uses this library's wrp.Decoder
+func Decode(input []byte, f wrp.Format) (*wrp.Message, error) {
+	message := new(wrp.Message)
+	if err := wrp.NewDecoderBytes(input, f).Decode(message); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// AssertDecodes asserts that the given decoder produces each golden message from each of its golden encodings
+func AssertDecodes(t testingT, decode DecodeFunc) bool {
+	result := true
+	for _, g := range Goldens() {
+		for _, f := range Formats() {
+			actual, err := decode(g.Encoded(f), f)
+			if !assert.NoError(t, err, "golden %s failed to decode from %s", g.Name, f) {
+				result = false
+				continue
+			}
+
+			if !assert.Equal(t, &g.Message, actual, "golden %s decoded incorrectly from %s", g.Name, f) {
+				result = false
+			}
+		}
+	}
+
+	return result
+}
+
+// AssertEncodes asserts that the given encoder produces bytes which the reference decoder decodes into each
+// golden message.  Encodings are compared semantically rather than byte-for-byte, since field order is not
+// part of the WRP spec.
+func AssertEncodes(t testingT, encode EncodeFunc) bool {
+	result := true
+	for _, g := range Goldens() {
+		for _, f := range Formats() {
+			encoded, err := encode(&g.Message, f)
+			if !assert.NoError(t, err, "golden %s failed to encode to %s", g.Name, f) {
+				result = false
+				continue
+			}
+
+			actual, err := Decode(encoded, f)
+			if !assert.NoError(t, err, "golden %s encoded to invalid %s", g.Name, f) {
+				result = false
+				continue
+			}
+
+			if !assert.Equal(t, &g.Message, actual, "golden %s encoded incorrectly to %s", g.Name, f) {
+				result = false
+			}
+		}
+	}
+
+	return result
+}
+
+// AssertRoundTrip asserts that each golden message survives encoding and then decoding with the given functions
+func AssertRoundTrip(t testingT, encode EncodeFunc, decode DecodeFunc) bool {
+	result := true
+	for _, g := range Goldens() {
+		for _, f := range Formats() {
+			encoded, err := encode(&g.Message, f)
+			if !assert.NoError(t, err, "golden %s failed to encode to %s", g.Name, f) {
+				result = false
+				continue
+			}
+
+			actual, err := decode(encoded, f)
+			if !assert.NoError(t, err, "golden %s failed to decode from %s", g.Name, f) {
+				result = false
+				continue
+			}
+
+			if !assert.Equal(t, &g.Message, actual, "golden %s did not survive a round trip through %s", g.Name, f) {
+				result = false
+			}
+		}
+	}
+
+	return result
+}
+
+// WriteFixtures writes each golden encoding to the given directory, creating the directory if necessary.
+// Files are named by golden and format, e.g. event.msgpack and event.json.  This allows implementations in
+// other languages to test against the same goldens.
+func WriteFixtures(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, g := range Goldens() {
+		for _, f := range Formats() {
+			name := filepath.Join(dir, fmt.Sprintf("%s.%s", g.Name, strings.ToLower(f.String())))
+			if err := ioutil.WriteFile(name, g.Encoded(f), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package wrptest
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturingT is a testingT that records failures instead of failing the enclosing test
+type capturingT struct {
+	errors []string
+}
+
+func (c *capturingT) Errorf(format string, arguments ...interface{}) {
+	c.errors = append(c.errors, fmt.Sprintf(format, arguments...))
+}
+
+func testConformanceReference(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(AssertDecodes(t, Decode))
+	assert.True(AssertEncodes(t, Encode))
+	assert.True(AssertRoundTrip(t, Encode, Decode))
+}
+
+func testConformanceEncodeError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		encode = func(*wrp.Message, wrp.Format) ([]byte, error) { return nil, errors.New("expected") }
+		c      = new(capturingT)
+	)
+
+	assert.False(AssertEncodes(c, encode))
+	assert.Len(c.errors, len(Goldens())*len(Formats()))
+
+	c = new(capturingT)
+	assert.False(AssertRoundTrip(c, encode, Decode))
+	assert.Len(c.errors, len(Goldens())*len(Formats()))
+}
+
+func testConformanceDecodeError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		decode = func([]byte, wrp.Format) (*wrp.Message, error) { return nil, errors.New("expected") }
+		c      = new(capturingT)
+	)
+
+	assert.False(AssertDecodes(c, decode))
+	assert.Len(c.errors, len(Goldens())*len(Formats()))
+
+	c = new(capturingT)
+	assert.False(AssertRoundTrip(c, Encode, decode))
+	assert.Len(c.errors, len(Goldens())*len(Formats()))
+}
+
+func testConformanceInvalidEncoding(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		c      = new(capturingT)
+	)
+
+	assert.False(AssertEncodes(c, func(*wrp.Message, wrp.Format) ([]byte, error) { return []byte{0xc1}, nil }))
+	assert.Len(c.errors, len(Goldens())*len(Formats()))
+}
+
+func testConformanceIncorrect(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		// drops the payload, which only some goldens have
+		encode = func(m *wrp.Message, f wrp.Format) ([]byte, error) {
+			clone := *m
+			clone.Payload = nil
+			return Encode(&clone, f)
+		}
+
+		decode = func(data []byte, f wrp.Format) (*wrp.Message, error) {
+			m, err := Decode(data, f)
+			if m != nil {
+				m.Payload = nil
+			}
+
+			return m, err
+		}
+	)
+
+	c := new(capturingT)
+	assert.False(AssertDecodes(c, decode))
+	assert.NotEmpty(c.errors)
+
+	c = new(capturingT)
+	assert.False(AssertEncodes(c, encode))
+	assert.NotEmpty(c.errors)
+
+	c = new(capturingT)
+	assert.False(AssertRoundTrip(c, encode, Decode))
+	assert.NotEmpty(c.errors)
+}
+
+func TestConformance(t *testing.T) {
+	t.Run("Reference", testConformanceReference)
+	t.Run("EncodeError", testConformanceEncodeError)
+	t.Run("DecodeError", testConformanceDecodeError)
+	t.Run("InvalidEncoding", testConformanceInvalidEncoding)
+	t.Run("Incorrect", testConformanceIncorrect)
+}
+
+func TestWriteFixtures(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	base, err := ioutil.TempDir("", "wrptest")
+	require.NoError(err)
+	defer os.RemoveAll(base)
+
+	dir := filepath.Join(base, "fixtures")
+	require.NoError(WriteFixtures(dir))
+
+	for _, g := range Goldens() {
+		msgpack, err := ioutil.ReadFile(filepath.Join(dir, g.Name+".msgpack"))
+		require.NoError(err)
+		assert.Equal(g.Msgpack, msgpack)
+
+		json, err := ioutil.ReadFile(filepath.Join(dir, g.Name+".json"))
+		require.NoError(err)
+		assert.Equal(g.JSON, json)
+	}
+
+	// a file where the directory should be
+	notADirectory := filepath.Join(base, "file")
+	require.NoError(ioutil.WriteFile(notADirectory, []byte("file"), 0644))
+	assert.Error(WriteFixtures(notADirectory))
+}
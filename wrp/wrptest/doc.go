@@ -0,0 +1,16 @@
+/*
+Package wrptest provides a conformance kit for WRP encoders and decoders.  The kit consists of golden
+messages, each with its canonical Msgpack and JSON encodings, along with assertions that check an
+implementation against those goldens.
+
+Go code, such as a downstream repository with its own codec, can run the assertions directly from a test:
+
+	func TestConformance(t *testing.T) {
+		wrptest.AssertDecodes(t, myDecode)
+		wrptest.AssertEncodes(t, myEncode)
+		wrptest.AssertRoundTrip(t, myEncode, myDecode)
+	}
+
+Implementations in other languages can use WriteFixtures to obtain the golden encodings as files.
+*/
+package wrptest
@@ -0,0 +1,198 @@
+package wrptest
+
+import (
+	"encoding/hex"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+// Golden is a reference WRP message together with its canonical encodings.  Every conforming decoder must
+// produce Message from either encoding, and every conforming encoder must produce bytes which decode to Message.
+type Golden struct {
+	// Name is a short, file-safe identifier for this golden
+	Name string
+
+	// Message is the decoded form of this golden
+	Message wrp.Message
+
+	// Msgpack is the canonical Msgpack encoding of Message
+	Msgpack []byte
+
+	// JSON is the canonical JSON encoding of Message
+	JSON []byte
+}
+
+// Encoded returns the golden encoding for the given format, or nil if this golden has no encoding for that format
+func (g Golden) Encoded(f wrp.Format) []byte {
+	switch f {
+	case wrp.Msgpack:
+		return g.Msgpack
+	case wrp.JSON:
+		return g.JSON
+	default:
+		return nil
+	}
+}
+
+// Formats is the set of formats for which goldens have canonical encodings
+func Formats() []wrp.Format {
+	return []wrp.Format{wrp.Msgpack, wrp.JSON}
+}
+
+func mustDecodeHex(v string) []byte {
+	data, err := hex.DecodeString(v)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+// Goldens returns the conformance goldens.  Each call returns new instances, so callers are free to modify
+// the returned values.
+//
+// Each golden message has at most one metadata entry, as the order of map keys is not part of the WRP spec.
+func Goldens() []Golden {
+	return []Golden{
+		{
+			Name: "authorization",
+			Message: wrp.Message{
+				Type:   wrp.AuthorizationStatusMessageType,
+				Status: int64Ptr(200),
+			},
+			Msgpack: mustDecodeHex("82a86d73675f7479706502a6737461747573d100c8"),
+			JSON:    []byte(`{"msg_type":2,"status":200}`),
+		},
+		{
+			Name: "request",
+			Message: wrp.Message{
+				Type:            wrp.SimpleRequestResponseMessageType,
+				Source:          "dns:webpa.comcast.com/v2-device-config",
+				Destination:     "mac:112233445566/config",
+				TransactionUUID: "c07ee5e1-70be-444c-a156-097c767ad8aa",
+				ContentType:     "application/json",
+				Accept:          "application/json",
+				Headers:         []string{"X-Header-1: value1"},
+				Metadata:        map[string]string{"/boot-time": "1542834188"},
+				Spans:           [][]string{{"client", "1542834188", "120"}},
+				IncludeSpans:    boolPtr(true),
+				Payload:         []byte(`{"command":"GET","names":["Device.DeviceInfo.Webpa.Enable"]}`),
+			},
+			Msgpack: mustDecodeHex(
+				"8ba86d73675f7479706503a6736f75726365d926646e733a77656270612e636f6d636173742e636f6d2f76322d646576" +
+					"6963652d636f6e666967a464657374b76d61633a3131323233333434353536362f636f6e666967b07472616e7361" +
+					"6374696f6e5f75756964d92463303765653565312d373062652d343434632d613135362d30393763373637616438" +
+					"6161ac636f6e74656e745f74797065b06170706c69636174696f6e2f6a736f6ea6616363657074b06170706c6963" +
+					"6174696f6e2f6a736f6ea76865616465727391b2582d4865616465722d313a2076616c756531a86d657461646174" +
+					"6181aa2f626f6f742d74696d65aa31353432383334313838a57370616e739193a6636c69656e74aa313534323833" +
+					"34313838a3313230ad696e636c7564655f7370616e73c3a77061796c6f6164c43c7b22636f6d6d616e64223a2247" +
+					"4554222c226e616d6573223a5b224465766963652e446576696365496e666f2e57656270612e456e61626c65225d7d",
+			),
+			JSON: []byte(
+				`{"msg_type":3,"source":"dns:webpa.comcast.com/v2-device-config","dest":"mac:112233445566/config",` +
+					`"transaction_uuid":"c07ee5e1-70be-444c-a156-097c767ad8aa","content_type":"application/json",` +
+					`"accept":"application/json","headers":["X-Header-1: value1"],"metadata":{"/boot-time":"1542834188"},` +
+					`"spans":[["client","1542834188","120"]],"include_spans":true,` +
+					`"payload":"eyJjb21tYW5kIjoiR0VUIiwibmFtZXMiOlsiRGV2aWNlLkRldmljZUluZm8uV2VicGEuRW5hYmxlIl19"}`,
+			),
+		},
+		{
+			Name: "response",
+			Message: wrp.Message{
+				Type:                    wrp.SimpleRequestResponseMessageType,
+				Source:                  "mac:112233445566/config",
+				Destination:             "dns:webpa.comcast.com/v2-device-config",
+				TransactionUUID:         "c07ee5e1-70be-444c-a156-097c767ad8aa",
+				ContentType:             "application/json",
+				Status:                  int64Ptr(200),
+				RequestDeliveryResponse: int64Ptr(0),
+				Payload:                 []byte(`{"statusCode":200}`),
+			},
+			Msgpack: mustDecodeHex(
+				"88a86d73675f7479706503a6736f75726365b76d61633a3131323233333434353536362f636f6e666967a464657374" +
+					"d926646e733a77656270612e636f6d636173742e636f6d2f76322d6465766963652d636f6e666967b07472616e73" +
+					"616374696f6e5f75756964d92463303765653565312d373062652d343434632d613135362d303937633736376164" +
+					"386161ac636f6e74656e745f74797065b06170706c69636174696f6e2f6a736f6ea6737461747573d100c8a37264" +
+					"7200a77061796c6f6164c4127b22737461747573436f6465223a3230307d",
+			),
+			JSON: []byte(
+				`{"msg_type":3,"source":"mac:112233445566/config","dest":"dns:webpa.comcast.com/v2-device-config",` +
+					`"transaction_uuid":"c07ee5e1-70be-444c-a156-097c767ad8aa","content_type":"application/json",` +
+					`"status":200,"rdr":0,"payload":"eyJzdGF0dXNDb2RlIjoyMDB9"}`,
+			),
+		},
+		{
+			Name: "event",
+			Message: wrp.Message{
+				Type:        wrp.SimpleEventMessageType,
+				Source:      "mac:112233445566/lmlite",
+				Destination: "event:device-status/mac:112233445566/online",
+				ContentType: "application/msgpack",
+				Metadata:    map[string]string{"/fw-name": "TG1682_2.1p7s1_PROD_sey"},
+				Payload:     []byte{0x82, 0xa2, 0x69, 0x64, 0x01, 0xa2, 0x6f, 0x6b, 0xc3},
+			},
+			Msgpack: mustDecodeHex(
+				"86a86d73675f7479706504a6736f75726365b76d61633a3131323233333434353536362f6c6d6c697465a464657374" +
+					"d92b6576656e743a6465766963652d7374617475732f6d61633a3131323233333434353536362f6f6e6c696e65ac" +
+					"636f6e74656e745f74797065b36170706c69636174696f6e2f6d73677061636ba86d6574616461746181a82f6677" +
+					"2d6e616d65b75447313638325f322e31703773315f50524f445f736579a77061796c6f6164c40982a2696401a26f" +
+					"6bc3",
+			),
+			JSON: []byte(
+				`{"msg_type":4,"source":"mac:112233445566/lmlite","dest":"event:device-status/mac:112233445566/online",` +
+					`"content_type":"application/msgpack","metadata":{"/fw-name":"TG1682_2.1p7s1_PROD_sey"},` +
+					`"payload":"gqJpZAGib2vD"}`,
+			),
+		},
+		{
+			Name: "create",
+			Message: wrp.Message{
+				Type:            wrp.CreateMessageType,
+				Source:          "dns:server",
+				Destination:     "mac:112233445566/parodus/tags",
+				TransactionUUID: "15bfc8b2-b3c1-4fd6-a5fd-d18b9ea2b5dd",
+				Path:            "/tags",
+				Payload:         []byte(`{"tag":"value"}`),
+			},
+			Msgpack: mustDecodeHex(
+				"86a86d73675f7479706505a6736f75726365aa646e733a736572766572a464657374bd6d61633a3131323233333434" +
+					"353536362f7061726f6475732f74616773b07472616e73616374696f6e5f75756964d92431356266633862322d62" +
+					"3363312d346664362d613566642d643138623965613262356464a470617468a52f74616773a77061796c6f6164c4" +
+					"0f7b22746167223a2276616c7565227d",
+			),
+			JSON: []byte(
+				`{"msg_type":5,"source":"dns:server","dest":"mac:112233445566/parodus/tags",` +
+					`"transaction_uuid":"15bfc8b2-b3c1-4fd6-a5fd-d18b9ea2b5dd","path":"/tags","payload":"eyJ0YWciOiJ2YWx1ZSJ9"}`,
+			),
+		},
+		{
+			Name: "service-registration",
+			Message: wrp.Message{
+				Type:        wrp.ServiceRegistrationMessageType,
+				ServiceName: "lmlite",
+				URL:         "tcp://127.0.0.1:6666",
+			},
+			Msgpack: mustDecodeHex(
+				"83a86d73675f7479706509ac736572766963655f6e616d65a66c6d6c697465a375726cb47463703a2f2f3132372e30" +
+					"2e302e313a36363636",
+			),
+			JSON: []byte(`{"msg_type":9,"service_name":"lmlite","url":"tcp://127.0.0.1:6666"}`),
+		},
+		{
+			Name: "service-alive",
+			Message: wrp.Message{
+				Type: wrp.ServiceAliveMessageType,
+			},
+			Msgpack: mustDecodeHex("81a86d73675f747970650a"),
+			JSON:    []byte(`{"msg_type":10}`),
+		},
+	}
+}
@@ -0,0 +1,50 @@
+package wrptest
+
+import (
+	"testing"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoldenEncoded(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		g      = Golden{Msgpack: []byte("msgpack"), JSON: []byte("json")}
+	)
+
+	assert.Equal([]byte("msgpack"), g.Encoded(wrp.Msgpack))
+	assert.Equal([]byte("json"), g.Encoded(wrp.JSON))
+	assert.Nil(g.Encoded(wrp.Format(-1)))
+}
+
+func TestGoldens(t *testing.T) {
+	names := make(map[string]bool)
+	for _, g := range Goldens() {
+		t.Run(g.Name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			assert.False(names[g.Name], "duplicate golden name")
+			names[g.Name] = true
+			assert.True(len(g.Message.Metadata) < 2, "golden encodings must not depend on map order")
+
+			// the golden bytes must be exactly what this library produces
+			for _, f := range Formats() {
+				encoded, err := Encode(&g.Message, f)
+				require.NoError(err)
+				assert.Equal(string(g.Encoded(f)), string(encoded), "format %s", f)
+			}
+		})
+	}
+
+	t.Run("Copies", func(t *testing.T) {
+		modified := Goldens()
+		modified[0].Message.Source = "modified"
+		modified[0].Msgpack[0] = 0
+		assert.NotEqual(t, modified[0], Goldens()[0])
+	})
+}
@@ -0,0 +1,188 @@
+package xhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// AcceptRangesHeader advertises the range units a resource supports
+	AcceptRangesHeader = "Accept-Ranges"
+
+	// ContentRangeHeader describes the portion of a resource carried in a partial response
+	ContentRangeHeader = "Content-Range"
+
+	// RangeHeader is the request header that asks for part of a resource
+	RangeHeader = "Range"
+
+	// IfRangeHeader makes a Range request conditional on the resource's ETag or Last-Modified time
+	IfRangeHeader = "If-Range"
+
+	// bytesUnit is the only range unit supported
+	bytesUnit = "bytes"
+)
+
+var (
+	ErrRangeNotBytes      = errors.New("Only the bytes range unit is supported")
+	ErrRangeInvalid       = errors.New("Invalid byte range")
+	ErrRangeMultiple      = errors.New("Multiple byte ranges are not supported")
+	ErrRangeUnsatisfiable = errors.New("The byte range cannot be satisfied")
+)
+
+// ByteRange is a single range of bytes within a resource, from First to Last inclusive
+type ByteRange struct {
+	First int64
+	Last  int64
+}
+
+// Length returns the number of bytes in this range
+func (br ByteRange) Length() int64 {
+	return br.Last - br.First + 1
+}
+
+// ContentRange formats this range as a Content-Range header value for a resource of the given size
+func (br ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("%s %d-%d/%d", bytesUnit, br.First, br.Last, size)
+}
+
+// ParseRange parses a Range header value, as defined by RFC 7233, against a resource of the given size.
+// Only a single range in the bytes unit is supported.  Ranges that extend past the end of the resource are
+// truncated, while ranges that begin past the end of the resource are unsatisfiable.
+func ParseRange(value string, size int64) (ByteRange, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, bytesUnit+"=") {
+		return ByteRange{}, ErrRangeNotBytes
+	}
+
+	spec := strings.TrimSpace(value[len(bytesUnit)+1:])
+	if strings.IndexByte(spec, ',') >= 0 {
+		return ByteRange{}, ErrRangeMultiple
+	}
+
+	dash := strings.IndexByte(spec, '-')
+	if dash < 0 {
+		return ByteRange{}, ErrRangeInvalid
+	}
+
+	first, last := strings.TrimSpace(spec[:dash]), strings.TrimSpace(spec[dash+1:])
+	if len(first) == 0 {
+		// a suffix range, e.g. bytes=-500 for the last 500 bytes
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix < 0 {
+			return ByteRange{}, ErrRangeInvalid
+		}
+
+		if suffix == 0 || size == 0 {
+			return ByteRange{}, ErrRangeUnsatisfiable
+		}
+
+		if suffix > size {
+			suffix = size
+		}
+
+		return ByteRange{First: size - suffix, Last: size - 1}, nil
+	}
+
+	var (
+		br  ByteRange
+		err error
+	)
+
+	if br.First, err = strconv.ParseInt(first, 10, 64); err != nil || br.First < 0 {
+		return ByteRange{}, ErrRangeInvalid
+	}
+
+	if len(last) == 0 {
+		br.Last = size - 1
+	} else if br.Last, err = strconv.ParseInt(last, 10, 64); err != nil || br.Last < br.First {
+		return ByteRange{}, ErrRangeInvalid
+	}
+
+	if br.First >= size {
+		return ByteRange{}, ErrRangeUnsatisfiable
+	}
+
+	if br.Last >= size {
+		br.Last = size - 1
+	}
+
+	return br, nil
+}
+
+// ifRangeMatches evaluates an If-Range header value against the validators already set on a response.
+// Only strong ETags and exact Last-Modified times match, as required by RFC 7233.
+func ifRangeMatches(value string, header http.Header) bool {
+	if len(value) == 0 {
+		return true
+	}
+
+	if strings.HasPrefix(value, `"`) {
+		etag := header.Get("ETag")
+		return len(etag) > 0 && etag == value
+	}
+
+	if strings.HasPrefix(value, "W/") {
+		return false
+	}
+
+	ifRange, err := http.ParseTime(value)
+	if err != nil {
+		return false
+	}
+
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	return err == nil && lastModified.Equal(ifRange)
+}
+
+// ServeRange writes content to a response, honoring any single byte range requested with a Range header.
+// The response advertises byte range support with an Accept-Ranges header.  A satisfiable range produces an
+// http.StatusPartialContent response, while an unsatisfiable range produces http.StatusRequestedRangeNotSatisfiable.
+// Requests without a Range header, with an unsupported or malformed Range, or whose If-Range does not match
+// receive the entire content.  HEAD requests receive the same headers as GET requests, but no body.
+//
+// Any Content-Type, ETag, or Last-Modified headers should be set on the response before calling this function.
+// The ETag and Last-Modified headers are used to evaluate If-Range.  Unlike http.ServeContent, this function does
+// not evaluate any other conditional headers and does not sniff the content type.
+func ServeRange(response http.ResponseWriter, request *http.Request, content io.ReadSeeker) {
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		WriteErrorf(response, http.StatusInternalServerError, "Unable to determine content size: %s", err)
+		return
+	}
+
+	var (
+		header = response.Header()
+		code   = http.StatusOK
+		br     = ByteRange{First: 0, Last: size - 1}
+	)
+
+	header.Set(AcceptRangesHeader, bytesUnit)
+	if value := request.Header.Get(RangeHeader); len(value) > 0 && ifRangeMatches(request.Header.Get(IfRangeHeader), header) {
+		switch requested, err := ParseRange(value, size); err {
+		case nil:
+			br = requested
+			code = http.StatusPartialContent
+			header.Set(ContentRangeHeader, br.ContentRange(size))
+
+		case ErrRangeUnsatisfiable:
+			header.Set(ContentRangeHeader, fmt.Sprintf("%s */%d", bytesUnit, size))
+			WriteError(response, http.StatusRequestedRangeNotSatisfiable, err)
+			return
+		}
+	}
+
+	if _, err := content.Seek(br.First, io.SeekStart); err != nil {
+		WriteErrorf(response, http.StatusInternalServerError, "Unable to seek content: %s", err)
+		return
+	}
+
+	header.Set("Content-Length", strconv.FormatInt(br.Length(), 10))
+	response.WriteHeader(code)
+	if request.Method != http.MethodHead {
+		io.CopyN(response, content, br.Length())
+	}
+}
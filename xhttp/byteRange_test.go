@@ -0,0 +1,199 @@
+package xhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingSeeker is an io.ReadSeeker whose seeks fail after a given number of successful calls
+type failingSeeker struct {
+	io.ReadSeeker
+	successes int
+}
+
+func (fs *failingSeeker) Seek(offset int64, whence int) (int64, error) {
+	if fs.successes < 1 {
+		return 0, errors.New("expected")
+	}
+
+	fs.successes--
+	return fs.ReadSeeker.Seek(offset, whence)
+}
+
+func TestByteRange(t *testing.T) {
+	assert := assert.New(t)
+	br := ByteRange{First: 10, Last: 19}
+	assert.Equal(int64(10), br.Length())
+	assert.Equal("bytes 10-19/100", br.ContentRange(100))
+}
+
+func TestParseRange(t *testing.T) {
+	testData := []struct {
+		value         string
+		size          int64
+		expected      ByteRange
+		expectedError error
+	}{
+		{"bytes=0-99", 1000, ByteRange{0, 99}, nil},
+		{" bytes = 10 - 19 ", 1000, ByteRange{}, ErrRangeNotBytes},
+		{"bytes= 10 - 19 ", 1000, ByteRange{10, 19}, nil},
+		{"bytes=500-", 1000, ByteRange{500, 999}, nil},
+		{"bytes=500-5000", 1000, ByteRange{500, 999}, nil},
+		{"bytes=-100", 1000, ByteRange{900, 999}, nil},
+		{"bytes=-5000", 1000, ByteRange{0, 999}, nil},
+		{"bytes=999-999", 1000, ByteRange{999, 999}, nil},
+		{"bytes=1000-", 1000, ByteRange{}, ErrRangeUnsatisfiable},
+		{"bytes=-0", 1000, ByteRange{}, ErrRangeUnsatisfiable},
+		{"bytes=-10", 0, ByteRange{}, ErrRangeUnsatisfiable},
+		{"bytes=0-", 0, ByteRange{}, ErrRangeUnsatisfiable},
+		{"items=0-10", 1000, ByteRange{}, ErrRangeNotBytes},
+		{"bytes=0-10,20-30", 1000, ByteRange{}, ErrRangeMultiple},
+		{"bytes=10", 1000, ByteRange{}, ErrRangeInvalid},
+		{"bytes=20-10", 1000, ByteRange{}, ErrRangeInvalid},
+		{"bytes=a-10", 1000, ByteRange{}, ErrRangeInvalid},
+		{"bytes=0-a", 1000, ByteRange{}, ErrRangeInvalid},
+		{"bytes=-a", 1000, ByteRange{}, ErrRangeInvalid},
+		{"bytes=--10", 1000, ByteRange{}, ErrRangeInvalid},
+	}
+
+	for _, record := range testData {
+		t.Logf("%#v", record)
+		actual, err := ParseRange(record.value, record.size)
+		assert.Equal(t, record.expected, actual)
+		assert.Equal(t, record.expectedError, err)
+	}
+}
+
+func testServeRangeFull(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+	)
+
+	ServeRange(response, httptest.NewRequest("GET", "/", nil), strings.NewReader("0123456789"))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("bytes", response.Header().Get(AcceptRangesHeader))
+	assert.Equal("10", response.Header().Get("Content-Length"))
+	assert.Empty(response.Header().Get(ContentRangeHeader))
+	assert.Equal("0123456789", response.Body.String())
+}
+
+func testServeRangePartial(t *testing.T) {
+	testData := []struct {
+		method       string
+		rangeValue   string
+		contentRange string
+		length       string
+		body         string
+	}{
+		{"GET", "bytes=2-4", "bytes 2-4/10", "3", "234"},
+		{"GET", "bytes=7-", "bytes 7-9/10", "3", "789"},
+		{"GET", "bytes=-2", "bytes 8-9/10", "2", "89"},
+		{"HEAD", "bytes=2-4", "bytes 2-4/10", "3", ""},
+	}
+
+	for _, record := range testData {
+		t.Logf("%#v", record)
+
+		var (
+			assert   = assert.New(t)
+			request  = httptest.NewRequest(record.method, "/", nil)
+			response = httptest.NewRecorder()
+		)
+
+		request.Header.Set(RangeHeader, record.rangeValue)
+		ServeRange(response, request, strings.NewReader("0123456789"))
+		assert.Equal(http.StatusPartialContent, response.Code)
+		assert.Equal(record.contentRange, response.Header().Get(ContentRangeHeader))
+		assert.Equal(record.length, response.Header().Get("Content-Length"))
+		assert.Equal(record.body, response.Body.String())
+	}
+}
+
+func testServeRangeIgnored(t *testing.T) {
+	for _, rangeValue := range []string{"items=0-1", "bytes=0-1,3-4", "bytes=garbage"} {
+		var (
+			assert   = assert.New(t)
+			request  = httptest.NewRequest("GET", "/", nil)
+			response = httptest.NewRecorder()
+		)
+
+		request.Header.Set(RangeHeader, rangeValue)
+		ServeRange(response, request, strings.NewReader("0123456789"))
+		assert.Equal(http.StatusOK, response.Code)
+		assert.Equal("0123456789", response.Body.String())
+	}
+}
+
+func testServeRangeUnsatisfiable(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		request  = httptest.NewRequest("GET", "/", nil)
+		response = httptest.NewRecorder()
+	)
+
+	request.Header.Set(RangeHeader, "bytes=10-")
+	ServeRange(response, request, strings.NewReader("0123456789"))
+	assert.Equal(http.StatusRequestedRangeNotSatisfiable, response.Code)
+	assert.Equal("bytes */10", response.Header().Get(ContentRangeHeader))
+}
+
+func testServeRangeIfRange(t *testing.T) {
+	const lastModified = "Mon, 04 Jun 2018 12:00:00 GMT"
+
+	testData := []struct {
+		ifRange      string
+		expectedCode int
+	}{
+		{`"v1"`, http.StatusPartialContent},
+		{`"v2"`, http.StatusOK},
+		{`W/"v1"`, http.StatusOK},
+		{lastModified, http.StatusPartialContent},
+		{"Mon, 04 Jun 2018 12:00:01 GMT", http.StatusOK},
+		{"not a date", http.StatusOK},
+	}
+
+	for _, record := range testData {
+		t.Logf("%#v", record)
+
+		var (
+			assert   = assert.New(t)
+			request  = httptest.NewRequest("GET", "/", nil)
+			response = httptest.NewRecorder()
+		)
+
+		request.Header.Set(RangeHeader, "bytes=0-1")
+		request.Header.Set(IfRangeHeader, record.ifRange)
+		response.Header().Set("ETag", `"v1"`)
+		response.Header().Set("Last-Modified", lastModified)
+		ServeRange(response, request, strings.NewReader("0123456789"))
+		assert.Equal(record.expectedCode, response.Code)
+	}
+}
+
+func testServeRangeSeekError(t *testing.T) {
+	for successes := 0; successes < 2; successes++ {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+		)
+
+		ServeRange(response, httptest.NewRequest("GET", "/", nil), &failingSeeker{strings.NewReader("0123456789"), successes})
+		assert.Equal(http.StatusInternalServerError, response.Code)
+	}
+}
+
+func TestServeRange(t *testing.T) {
+	t.Run("Full", testServeRangeFull)
+	t.Run("Partial", testServeRangePartial)
+	t.Run("Ignored", testServeRangeIgnored)
+	t.Run("Unsatisfiable", testServeRangeUnsatisfiable)
+	t.Run("IfRange", testServeRangeIfRange)
+	t.Run("SeekError", testServeRangeSeekError)
+}
@@ -0,0 +1,227 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	// ForwardedHeader is the RFC 7239 header that proxies use to convey the chain of client addresses
+	ForwardedHeader = "Forwarded"
+
+	// ForwardedForHeader is the de facto standard header that proxies use to convey the chain of client addresses
+	ForwardedForHeader = "X-Forwarded-For"
+)
+
+// ErrInvalidClientIP indicates that a client IP address could not be determined from a request
+var ErrInvalidClientIP = errors.New("Unable to determine client IP")
+
+type clientIPContextKey struct{}
+
+var clientIPKey interface{} = "clientIP"
+
+// ClientIPKey returns the contextual logging key for a client IP
+func ClientIPKey() interface{} {
+	return clientIPKey
+}
+
+// WithClientIP returns a new context with the given client IP attached
+func WithClientIP(parent context.Context, ip net.IP) context.Context {
+	return context.WithValue(parent, clientIPContextKey{}, ip)
+}
+
+// GetClientIP returns the client IP from the context, if one is present
+func GetClientIP(ctx context.Context) (net.IP, bool) {
+	ip, ok := ctx.Value(clientIPContextKey{}).(net.IP)
+	return ip, ok
+}
+
+// Networks is a set of IP networks
+type Networks []*net.IPNet
+
+// ParseNetworks parses each value as either a CIDR or a single IP address.  A single IP address is
+// converted into a host network, i.e. /32 for IPv4 and /128 for IPv6.
+func ParseNetworks(values []string) (Networks, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	networks := make(Networks, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if strings.IndexByte(v, '/') >= 0 {
+			_, n, err := net.ParseCIDR(v)
+			if err != nil {
+				return nil, err
+			}
+
+			networks = append(networks, n)
+			continue
+		}
+
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid IP address or CIDR: %s", v)
+		}
+
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+	}
+
+	return networks, nil
+}
+
+// Contains tests if any of the networks in this set contains the given IP
+func (ns Networks) Contains(ip net.IP) bool {
+	for _, n := range ns {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIPResolver determines the IP address of the client that originated a request.  Forwarding headers
+// are only honored when they were supplied by a trusted proxy, so untrusted peers cannot spoof their address.
+// A nil ClientIPResolver trusts no proxies.
+type ClientIPResolver struct {
+	forwardingHeader string
+	trustedProxies   Networks
+}
+
+// NewClientIPResolver creates a ClientIPResolver which trusts the given proxies.  Each trusted proxy
+// is either a CIDR or a single IP address.
+//
+// The forwarding header must be either ForwardedHeader or ForwardedForHeader, and is the only header consulted.
+// It must be the header that the trusted proxies actually write.  A proxy that appends to X-Forwarded-For but
+// passes Forwarded through unchanged would otherwise let clients choose their own address with a Forwarded header.
+func NewClientIPResolver(forwardingHeader string, trustedProxies []string) (*ClientIPResolver, error) {
+	forwardingHeader = http.CanonicalHeaderKey(forwardingHeader)
+	if forwardingHeader != ForwardedHeader && forwardingHeader != ForwardedForHeader {
+		return nil, fmt.Errorf("Invalid forwarding header: %q", forwardingHeader)
+	}
+
+	networks, err := ParseNetworks(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClientIPResolver{forwardingHeader: forwardingHeader, trustedProxies: networks}, nil
+}
+
+// Resolve returns the client IP for a request.  If the peer address is a trusted proxy, the chain of addresses
+// from the configured forwarding header is walked from the nearest hop outward.  The first address that is not
+// itself a trusted proxy is the client IP.  ErrInvalidClientIP is returned if the peer address or any examined
+// hop cannot be parsed, e.g. RFC 7239 obfuscated or "unknown" identifiers.
+func (r *ClientIPResolver) Resolve(request *http.Request) (net.IP, error) {
+	ip := parseHost(request.RemoteAddr)
+	if ip == nil {
+		return nil, ErrInvalidClientIP
+	}
+
+	if r == nil || !r.trustedProxies.Contains(ip) {
+		return ip, nil
+	}
+
+	var hops []string
+	if r.forwardingHeader == ForwardedHeader {
+		hops = forwardedHops(request.Header)
+	} else {
+		hops = forwardedForHops(request.Header)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseHost(hops[i])
+		if hop == nil {
+			return nil, ErrInvalidClientIP
+		}
+
+		ip = hop
+		if !r.trustedProxies.Contains(ip) {
+			break
+		}
+	}
+
+	return ip, nil
+}
+
+// parseHost parses an IP address which may carry a port, e.g. "1.2.3.4:8080" or "[::1]:8080".
+// This function returns nil if the value is not an IP address.
+func parseHost(value string) net.IP {
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	} else {
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	}
+
+	return net.ParseIP(value)
+}
+
+// forwardedHops returns the chain of client addresses in the RFC 7239 ForwardedHeader, ordered from the originating
+// client to the nearest proxy.  A Forwarded element without a "for" parameter produces an empty hop, which does not
+// parse as an IP address.
+func forwardedHops(header http.Header) []string {
+	var hops []string
+	for _, value := range header[ForwardedHeader] {
+		for _, element := range strings.Split(value, ",") {
+			var hop string
+			for _, pair := range strings.Split(element, ";") {
+				if i := strings.IndexByte(pair, '='); i >= 0 && strings.EqualFold(strings.TrimSpace(pair[:i]), "for") {
+					hop = strings.Trim(strings.TrimSpace(pair[i+1:]), `"`)
+					break
+				}
+			}
+
+			hops = append(hops, hop)
+		}
+	}
+
+	return hops
+}
+
+// forwardedForHops returns the chain of client addresses in the ForwardedForHeader, ordered from the originating
+// client to the nearest proxy
+func forwardedForHops(header http.Header) []string {
+	var hops []string
+	for _, value := range header[ForwardedForHeader] {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	return hops
+}
+
+// ClientIP returns an Alice-style constructor that resolves the client IP of each request with the given
+// resolver.  The client IP is placed into the request context, where it can be retrieved with GetClientIP,
+// and is added to the context logger under ClientIPKey.  If the client IP cannot be resolved, the request
+// is passed to the next http.Handler without a client IP.
+func ClientIP(resolver *ClientIPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			ip, err := resolver.Resolve(request)
+			if err != nil {
+				next.ServeHTTP(response, request)
+				return
+			}
+
+			ctx := WithClientIP(request.Context(), ip)
+			ctx = logging.WithLogger(
+				ctx,
+				log.With(logging.GetLogger(ctx), clientIPKey, ip.String()),
+			)
+
+			next.ServeHTTP(response, request.WithContext(ctx))
+		})
+	}
+}
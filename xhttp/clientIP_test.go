@@ -0,0 +1,220 @@
+package xhttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIPKey(t *testing.T) {
+	assert.Equal(t, clientIPKey, ClientIPKey())
+}
+
+func TestWithClientIP(t *testing.T) {
+	assert := assert.New(t)
+
+	ip, ok := GetClientIP(context.Background())
+	assert.Nil(ip)
+	assert.False(ok)
+
+	ip, ok = GetClientIP(WithClientIP(context.Background(), net.ParseIP("1.2.3.4")))
+	assert.Equal(net.ParseIP("1.2.3.4"), ip)
+	assert.True(ok)
+}
+
+func TestParseNetworks(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		assert := assert.New(t)
+		networks, err := ParseNetworks(nil)
+		assert.Empty(networks)
+		assert.NoError(err)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		networks, err := ParseNetworks([]string{"10.0.0.0/8", " 192.168.1.1 ", "fd00::/8", "::1"})
+		require.NoError(err)
+		require.Len(networks, 4)
+
+		assert.Equal("10.0.0.0/8", networks[0].String())
+		assert.Equal("192.168.1.1/32", networks[1].String())
+		assert.Equal("fd00::/8", networks[2].String())
+		assert.Equal("::1/128", networks[3].String())
+
+		assert.True(networks.Contains(net.ParseIP("10.20.30.40")))
+		assert.True(networks.Contains(net.ParseIP("192.168.1.1")))
+		assert.False(networks.Contains(net.ParseIP("192.168.1.2")))
+		assert.True(networks.Contains(net.ParseIP("fd12::1")))
+		assert.True(networks.Contains(net.ParseIP("::1")))
+		assert.False(networks.Contains(net.ParseIP("fe80::1")))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert := assert.New(t)
+		for _, v := range []string{"10.0.0.0/33", "not an ip", ""} {
+			networks, err := ParseNetworks([]string{v})
+			assert.Nil(networks)
+			assert.Error(err)
+		}
+	})
+}
+
+func TestNewClientIPResolver(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := NewClientIPResolver(ForwardedForHeader, nil)
+	assert.NotNil(r)
+	assert.NoError(err)
+
+	r, err = NewClientIPResolver("forwarded", nil)
+	assert.NotNil(r)
+	assert.NoError(err)
+
+	r, err = NewClientIPResolver(ForwardedForHeader, []string{"bad"})
+	assert.Nil(r)
+	assert.Error(err)
+
+	for _, invalid := range []string{"", "X-Real-IP"} {
+		r, err = NewClientIPResolver(invalid, []string{"10.0.0.1"})
+		assert.Nil(r)
+		assert.Error(err)
+	}
+}
+
+func TestClientIPResolverResolve(t *testing.T) {
+	var (
+		require = require.New(t)
+
+		forwardedFor, forwardedForErr = NewClientIPResolver(ForwardedForHeader, []string{"172.16.0.0/12"})
+		forwarded, forwardedErr       = NewClientIPResolver(ForwardedHeader, []string{"172.16.0.0/12"})
+
+		testData = []struct {
+			forwarded     bool
+			remoteAddr    string
+			header        http.Header
+			expected      string
+			expectedError error
+		}{
+			{false, "10.0.0.1:1234", nil, "10.0.0.1", nil},
+			{false, "10.0.0.1", nil, "10.0.0.1", nil},
+			{false, "[fd00::1]:1234", nil, "fd00::1", nil},
+			{false, "garbage", nil, "", ErrInvalidClientIP},
+			{false, "10.0.0.1:1234", http.Header{ForwardedForHeader: {"1.2.3.4"}}, "10.0.0.1", nil},
+			{true, "10.0.0.1:1234", http.Header{ForwardedHeader: {"for=1.2.3.4"}}, "10.0.0.1", nil},
+			{false, "172.16.0.1:1234", nil, "172.16.0.1", nil},
+			{false, "172.16.0.1:1234", http.Header{ForwardedForHeader: {"1.2.3.4"}}, "1.2.3.4", nil},
+			{false, "172.16.0.1:1234", http.Header{ForwardedForHeader: {"5.6.7.8, 1.2.3.4, 172.16.0.2"}}, "1.2.3.4", nil},
+			{false, "172.16.0.1:1234", http.Header{ForwardedForHeader: {"5.6.7.8", "1.2.3.4"}}, "1.2.3.4", nil},
+			{false, "172.16.0.1:1234", http.Header{ForwardedForHeader: {"172.16.0.3, 172.16.0.2"}}, "172.16.0.3", nil},
+			{false, "172.16.0.1:1234", http.Header{ForwardedForHeader: {"1.2.3.4, garbage"}}, "", ErrInvalidClientIP},
+			{false, "172.16.0.1:1234", http.Header{ForwardedForHeader: {"garbage, 1.2.3.4"}}, "1.2.3.4", nil},
+			{true, "172.16.0.1:1234", http.Header{ForwardedHeader: {"for=1.2.3.4"}}, "1.2.3.4", nil},
+			{true, "172.16.0.1:1234", http.Header{ForwardedHeader: {`For="[2001:db8:cafe::17]:4711"`}}, "2001:db8:cafe::17", nil},
+			{true, "172.16.0.1:1234", http.Header{ForwardedHeader: {"for=5.6.7.8;proto=https, by=172.16.0.1;for=172.16.0.2"}}, "5.6.7.8", nil},
+			{true, "172.16.0.1:1234", http.Header{ForwardedHeader: {"for=_hidden"}}, "", ErrInvalidClientIP},
+			{true, "172.16.0.1:1234", http.Header{ForwardedHeader: {"proto=https"}}, "", ErrInvalidClientIP},
+			{true, "172.16.0.1:1234", http.Header{ForwardedHeader: {"for=5.6.7.8"}, ForwardedForHeader: {"1.2.3.4"}}, "5.6.7.8", nil},
+
+			// only the configured header is honored, so clients cannot choose their address with the other one
+			{false, "172.16.0.1:1234", http.Header{ForwardedHeader: {"for=5.6.7.8"}, ForwardedForHeader: {"1.2.3.4"}}, "1.2.3.4", nil},
+			{false, "172.16.0.1:1234", http.Header{ForwardedHeader: {"for=5.6.7.8"}}, "172.16.0.1", nil},
+			{true, "172.16.0.1:1234", http.Header{ForwardedForHeader: {"1.2.3.4"}}, "172.16.0.1", nil},
+		}
+	)
+
+	require.NoError(forwardedForErr)
+	require.NoError(forwardedErr)
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			request := httptest.NewRequest("GET", "/", nil)
+			request.RemoteAddr = record.remoteAddr
+			for name, values := range record.header {
+				request.Header[name] = values
+			}
+
+			r := forwardedFor
+			if record.forwarded {
+				r = forwarded
+			}
+
+			ip, err := r.Resolve(request)
+			assert.Equal(record.expectedError, err)
+			if len(record.expected) > 0 {
+				assert.Equal(record.expected, ip.String())
+			} else {
+				assert.Nil(ip)
+			}
+		})
+	}
+
+	t.Run("Nil", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			r       *ClientIPResolver
+			request = httptest.NewRequest("GET", "/", nil)
+		)
+
+		request.RemoteAddr = "172.16.0.1:1234"
+		request.Header.Set(ForwardedForHeader, "1.2.3.4")
+		ip, err := r.Resolve(request)
+		assert.Equal("172.16.0.1", ip.String())
+		assert.NoError(err)
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	t.Run("Resolved", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			r, err   = NewClientIPResolver(ForwardedForHeader, []string{"172.16.0.1"})
+			request  = httptest.NewRequest("GET", "/", nil)
+			response = httptest.NewRecorder()
+			called   = false
+		)
+
+		require.NoError(err)
+		request.RemoteAddr = "172.16.0.1:1234"
+		request.Header.Set(ForwardedForHeader, "1.2.3.4")
+
+		ClientIP(r)(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+			called = true
+			ip, ok := GetClientIP(request.Context())
+			assert.True(ok)
+			assert.Equal("1.2.3.4", ip.String())
+		})).ServeHTTP(response, request)
+
+		assert.True(called)
+	})
+
+	t.Run("Unresolved", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			request  = httptest.NewRequest("GET", "/", nil)
+			response = httptest.NewRecorder()
+			called   = false
+		)
+
+		request.RemoteAddr = "garbage"
+		ClientIP(nil)(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+			called = true
+			_, ok := GetClientIP(request.Context())
+			assert.False(ok)
+		})).ServeHTTP(response, request)
+
+		assert.True(called)
+	})
+}
@@ -0,0 +1,88 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeadlineHeader is the HTTP header used to carry the time budget within which a client needs a response,
+// both inbound and outbound.  Values are the number of milliseconds remaining when the request was sent.
+// A relative budget, rather than an absolute time, keeps deadlines meaningful between hosts whose clocks
+// are skewed.
+const DeadlineHeader = "X-Request-Deadline"
+
+// errNegativeDeadline indicates that a DeadlineHeader held a negative budget
+var errNegativeDeadline = errors.New("The request deadline cannot be negative")
+
+// GetDeadline parses the DeadlineHeader from a set of HTTP headers, returning the absolute deadline relative
+// to the current time.  If the header is absent, this function returns false with no error.
+func GetDeadline(h http.Header) (time.Time, bool, error) {
+	return getDeadline(h, time.Now())
+}
+
+func getDeadline(h http.Header, now time.Time) (time.Time, bool, error) {
+	value := h.Get(DeadlineHeader)
+	if len(value) == 0 {
+		return time.Time{}, false, nil
+	}
+
+	budget, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false, err
+	} else if budget < 0 {
+		return time.Time{}, false, errNegativeDeadline
+	}
+
+	return now.Add(time.Duration(budget) * time.Millisecond), true, nil
+}
+
+// SetDeadline sets the DeadlineHeader on a set of HTTP headers to the budget remaining before the given deadline.
+// A deadline which has already passed is sent as a zero budget.
+func SetDeadline(h http.Header, deadline time.Time) {
+	setDeadline(h, deadline, time.Now())
+}
+
+func setDeadline(h http.Header, deadline, now time.Time) {
+	budget := deadline.Sub(now) / time.Millisecond
+	if budget < 0 {
+		budget = 0
+	}
+
+	h.Set(DeadlineHeader, strconv.FormatInt(int64(budget), 10))
+}
+
+// Deadline returns an Alice-style constructor that enforces client-supplied deadlines.  A request whose
+// DeadlineHeader budget is exhausted is rejected immediately with a 504 status, and a request whose
+// DeadlineHeader cannot be parsed is rejected with a 400 status.  Otherwise, the deadline is applied to the
+// request context, so decorated code and any outbound requests made with that context stop when the client
+// no longer cares about the response.
+//
+// Requests without a DeadlineHeader are passed to the next http.Handler unmodified.
+func Deadline() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			now := time.Now()
+			deadline, ok, err := getDeadline(request.Header, now)
+			if err != nil {
+				WriteErrorf(response, http.StatusBadRequest, "Invalid %s header", DeadlineHeader)
+				return
+			} else if !ok {
+				next.ServeHTTP(response, request)
+				return
+			}
+
+			if !now.Before(deadline) {
+				WriteErrorf(response, http.StatusGatewayTimeout, "The request deadline has passed")
+				return
+			}
+
+			ctx, cancel := context.WithDeadline(request.Context(), deadline)
+			defer cancel()
+
+			next.ServeHTTP(response, request.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package xhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDeadline(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		header   = make(http.Header)
+		now      = time.Date(2018, time.August, 17, 14, 35, 12, 123456789, time.UTC)
+		expected = now.Add(1500 * time.Millisecond)
+	)
+
+	deadline, ok, err := GetDeadline(header)
+	assert.True(deadline.IsZero())
+	assert.False(ok)
+	assert.NoError(err)
+
+	// the budget is relative, so the sender's and receiver's clocks need not agree
+	setDeadline(header, expected.Add(250*time.Microsecond), now)
+	assert.Equal("1500", header.Get(DeadlineHeader))
+
+	later := now.Add(time.Hour)
+	deadline, ok, err = getDeadline(header, later)
+	require.NoError(err)
+	assert.True(ok)
+	assert.True(later.Add(1500 * time.Millisecond).Equal(deadline))
+
+	setDeadline(header, now.Add(-time.Second), now)
+	assert.Equal("0", header.Get(DeadlineHeader))
+
+	deadline, ok, err = getDeadline(header, now)
+	require.NoError(err)
+	assert.True(ok)
+	assert.True(now.Equal(deadline))
+
+	for _, invalid := range []string{"tomorrow", "-1", "1.5"} {
+		header.Set(DeadlineHeader, invalid)
+		deadline, ok, err = GetDeadline(header)
+		assert.True(deadline.IsZero())
+		assert.False(ok)
+		assert.Error(err)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	testData := []struct {
+		name         string
+		deadline     string
+		expectedCode int
+		expectNext   bool
+	}{
+		{"Missing", "", http.StatusOK, true},
+		{"Invalid", "not a time", http.StatusBadRequest, false},
+		{"Negative", "-1000", http.StatusBadRequest, false},
+		{"Expired", "0", http.StatusGatewayTimeout, false},
+		{"Future", "3600000", http.StatusOK, true},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert   = assert.New(t)
+				request  = httptest.NewRequest("GET", "/", nil)
+				response = httptest.NewRecorder()
+
+				nextCalled  = false
+				hasDeadline = false
+				handler     = Deadline()(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					nextCalled = true
+					_, hasDeadline = request.Context().Deadline()
+				}))
+			)
+
+			if len(record.deadline) > 0 {
+				request.Header.Set(DeadlineHeader, record.deadline)
+			}
+
+			handler.ServeHTTP(response, request)
+			assert.Equal(record.expectedCode, response.Code)
+			assert.Equal(record.expectNext, nextCalled)
+			assert.Equal(record.name == "Future", hasDeadline)
+		})
+	}
+}
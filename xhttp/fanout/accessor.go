@@ -0,0 +1,72 @@
+package fanout
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Comcast/webpa-common/service"
+	"github.com/Comcast/webpa-common/xhttp"
+)
+
+// NewShardEndpoints uses a service.Accessor to select the single instance that owns a key, and returns
+// FixedEndpoints containing only that instance.  For a device, the key is the device ID's Bytes().
+//
+// The instance returned by the accessor must be an absolute URL, e.g. "http://talaria-1:6200", as is the case
+// for instances produced by service discovery.
+func NewShardEndpoints(a service.Accessor, key []byte) (FixedEndpoints, error) {
+	instance, err := a.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	if !u.IsAbs() || len(u.Host) == 0 {
+		return nil, fmt.Errorf("Instance %s is not an absolute URL", instance)
+	}
+
+	return FixedEndpoints{u}, nil
+}
+
+// AccessorEndpoints is an Endpoints that routes each fanout to the single instance that owns the request,
+// rather than to every instance.  This is the routing used to reach the instance to which a device is connected.
+type AccessorEndpoints struct {
+	accessor service.Accessor
+	keyFunc  service.KeyFunc
+}
+
+// NewAccessorEndpoints creates an AccessorEndpoints which hashes the key extracted from each request by keyFunc.
+// The device.IDHashParser function is the typical keyFunc, which hashes the device named in the request's headers.
+func NewAccessorEndpoints(a service.Accessor, keyFunc service.KeyFunc) (*AccessorEndpoints, error) {
+	if a == nil {
+		return nil, errors.New("An accessor is required")
+	}
+
+	if keyFunc == nil {
+		return nil, errors.New("A key function is required")
+	}
+
+	return &AccessorEndpoints{
+		accessor: a,
+		keyFunc:  keyFunc,
+	}, nil
+}
+
+func (ae *AccessorEndpoints) NewEndpoints(original *http.Request) ([]*url.URL, error) {
+	key, err := ae.keyFunc(original)
+	if err != nil {
+		return nil, &xhttp.Error{Code: http.StatusBadRequest, Text: err.Error()}
+	}
+
+	fe, err := NewShardEndpoints(ae.accessor, key)
+	if err != nil {
+		return nil, &xhttp.Error{Code: http.StatusServiceUnavailable, Text: fmt.Sprintf("Unable to select an instance: %s", err)}
+	}
+
+	return fe.NewEndpoints(original)
+}
@@ -0,0 +1,114 @@
+package fanout
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/webpa-common/service"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewShardEndpoints(t *testing.T) {
+	accessor := service.MapAccessor{
+		"mac:112233445566": "http://talaria-1:6200",
+		"relative":         "talaria-1:6200",
+		"invalid":          "%%",
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		fe, err := NewShardEndpoints(accessor, []byte("mac:112233445566"))
+		require.NoError(err)
+		require.Len(fe, 1)
+		assert.Equal("http://talaria-1:6200", fe[0].String())
+	})
+
+	for _, key := range []string{"nosuch", "relative", "invalid"} {
+		t.Run(key, func(t *testing.T) {
+			assert := assert.New(t)
+			fe, err := NewShardEndpoints(accessor, []byte(key))
+			assert.Nil(fe)
+			assert.Error(err)
+		})
+	}
+}
+
+func TestNewAccessorEndpoints(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		keyFunc = func(*http.Request) ([]byte, error) { return nil, nil }
+	)
+
+	ae, err := NewAccessorEndpoints(nil, keyFunc)
+	assert.Nil(ae)
+	assert.Error(err)
+
+	ae, err = NewAccessorEndpoints(service.EmptyAccessor(), nil)
+	assert.Nil(ae)
+	assert.Error(err)
+
+	ae, err = NewAccessorEndpoints(service.EmptyAccessor(), keyFunc)
+	assert.NotNil(ae)
+	assert.NoError(err)
+}
+
+func TestAccessorEndpoints(t *testing.T) {
+	keyFunc := func(r *http.Request) ([]byte, error) {
+		if key := r.Header.Get("X-Key"); len(key) > 0 {
+			return []byte(key), nil
+		}
+
+		return nil, errors.New("missing key")
+	}
+
+	ae, err := NewAccessorEndpoints(service.MapAccessor{"mac:112233445566": "https://talaria-2:6200"}, keyFunc)
+	require.NoError(t, err)
+
+	t.Run("Success", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			require  = require.New(t)
+			original = httptest.NewRequest("GET", "/api/v2/device/mac:112233445566/config?name=foo", nil)
+		)
+
+		original.Header.Set("X-Key", "mac:112233445566")
+		endpoints, err := ae.NewEndpoints(original)
+		require.NoError(err)
+		require.Len(endpoints, 1)
+		assert.Equal("https://talaria-2:6200/api/v2/device/mac:112233445566/config?name=foo", endpoints[0].String())
+	})
+
+	t.Run("KeyFuncError", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		endpoints, err := ae.NewEndpoints(httptest.NewRequest("GET", "/", nil))
+		assert.Empty(endpoints)
+		require.IsType(&xhttp.Error{}, err)
+		assert.Equal(http.StatusBadRequest, err.(*xhttp.Error).Code)
+	})
+
+	t.Run("AccessorError", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			require  = require.New(t)
+			original = httptest.NewRequest("GET", "/", nil)
+		)
+
+		original.Header.Set("X-Key", "mac:ffffffffffff")
+		endpoints, err := ae.NewEndpoints(original)
+		assert.Empty(endpoints)
+		require.IsType(&xhttp.Error{}, err)
+		assert.Equal(http.StatusServiceUnavailable, err.(*xhttp.Error).Code)
+	})
+}
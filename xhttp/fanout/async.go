@@ -0,0 +1,333 @@
+package fanout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/semaphore"
+	"github.com/Comcast/webpa-common/store"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// DefaultAsyncResultTTL is how long an asynchronous fanout result is retained when no TTL is supplied
+	DefaultAsyncResultTTL = 10 * time.Minute
+
+	// DefaultAsyncLimit is the maximum number of concurrent background fanouts when no limit is supplied
+	DefaultAsyncLimit = 100
+
+	// DefaultAsyncTimeout is the maximum time a background fanout may run when no timeout is supplied
+	DefaultAsyncTimeout = 2 * time.Minute
+
+	// DefaultAsyncMaxBodySize is the largest response body, in bytes, retained for a background fanout
+	// when no maximum is supplied
+	DefaultAsyncMaxBodySize = 1024 * 1024
+)
+
+var (
+	// ErrAsyncResultNotFound is returned by an AsyncResultStore when no result exists for an identifier
+	ErrAsyncResultNotFound = errors.New("No such asynchronous fanout result")
+
+	// ErrAsyncResultTooLarge is returned when a background fanout's response body exceeds the configured maximum
+	ErrAsyncResultTooLarge = errors.New("Asynchronous fanout result is too large")
+)
+
+// AsyncResult is the outcome of an asynchronous fanout, as made available for polling
+type AsyncResult struct {
+	// Done indicates whether the fanout has completed.  The remaining fields are only set when Done is true.
+	Done bool `json:"done"`
+
+	// StatusCode is the HTTP status code the fanout would have returned synchronously
+	StatusCode int `json:"statusCode,omitempty"`
+
+	// Header holds the HTTP headers the fanout would have returned synchronously
+	Header http.Header `json:"header,omitempty"`
+
+	// Body is the HTTP entity the fanout would have returned synchronously
+	Body []byte `json:"body,omitempty"`
+}
+
+// AsyncResultStore holds the results of asynchronous fanouts until clients poll for them
+type AsyncResultStore interface {
+	// Pending records that a fanout has started and will complete within the given timeout.  The placeholder
+	// must be retained for at least that long, so that it cannot expire while the fanout is still running.
+	Pending(id string, timeout time.Duration) error
+
+	// Put stores the result of a completed fanout
+	Put(id string, result AsyncResult) error
+
+	// Get returns the result for an identifier, or ErrAsyncResultNotFound if there is no such result
+	Get(id string) (AsyncResult, error)
+}
+
+// kvResultStore is an AsyncResultStore backed by a store.KV
+type kvResultStore struct {
+	kv  store.KV
+	ttl time.Duration
+}
+
+// NewAsyncResultStore produces an AsyncResultStore which keeps results as JSON in the given store.KV.  This
+// allows results to be kept in process memory with store.NewMemoryKV, or shared across instances with
+// store.NewConsulKV.  Results expire after the given ttl.  If ttl is nonpositive, DefaultAsyncResultTTL is used.
+// A pending placeholder is retained for its fanout's timeout plus the ttl.
+func NewAsyncResultStore(kv store.KV, ttl time.Duration) AsyncResultStore {
+	if kv == nil {
+		panic("A store.KV is required")
+	}
+
+	if ttl < 1 {
+		ttl = DefaultAsyncResultTTL
+	}
+
+	return &kvResultStore{kv: kv, ttl: ttl}
+}
+
+func (rs *kvResultStore) put(id string, result AsyncResult, ttl time.Duration) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	return rs.kv.Put(id, data, ttl)
+}
+
+func (rs *kvResultStore) Pending(id string, timeout time.Duration) error {
+	return rs.put(id, AsyncResult{}, timeout+rs.ttl)
+}
+
+func (rs *kvResultStore) Put(id string, result AsyncResult) error {
+	return rs.put(id, result, rs.ttl)
+}
+
+func (rs *kvResultStore) Get(id string) (AsyncResult, error) {
+	var result AsyncResult
+	data, err := rs.kv.Get(id)
+	if err == store.ErrNotFound {
+		return result, ErrAsyncResultNotFound
+	} else if err != nil {
+		return result, err
+	}
+
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// WithAsync enables asynchronous fanouts.  When a request carries the xhttp.RespondAsync preference, the handler
+// responds immediately with a 202 whose Location header is produced by location, given the generated identifier
+// of the fanout.  The fanout is then performed in the background, and its outcome is written to the store.
+// ResultHandler can be used to serve the stored results.
+//
+// If store is nil, asynchronous fanouts are disabled and the preference is ignored.  The number of concurrent
+// background fanouts is bounded by WithAsyncLimit, and requests beyond that limit are fanned out synchronously.
+func WithAsync(s AsyncResultStore, location func(id string) string) Option {
+	return func(h *Handler) {
+		h.asyncStore = s
+		h.asyncLocation = location
+	}
+}
+
+// WithAsyncTimeout sets the maximum time a background fanout is allowed to run.  If timeout is nonpositive,
+// DefaultAsyncTimeout is used.
+func WithAsyncTimeout(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.asyncTimeout = timeout
+	}
+}
+
+// WithAsyncMaxBodySize sets the largest response body, in bytes, that is retained for a background fanout.  A
+// fanout whose response exceeds this size is stored as a http.StatusBadGateway with no body.  If maxBodySize is
+// nonpositive, DefaultAsyncMaxBodySize is used.
+func WithAsyncMaxBodySize(maxBodySize int) Option {
+	return func(h *Handler) {
+		h.asyncMaxBodySize = maxBodySize
+	}
+}
+
+func (h *Handler) asyncTimeoutOrDefault() time.Duration {
+	if h.asyncTimeout > 0 {
+		return h.asyncTimeout
+	}
+
+	return DefaultAsyncTimeout
+}
+
+func (h *Handler) asyncMaxBodySizeOrDefault() int {
+	if h.asyncMaxBodySize > 0 {
+		return h.asyncMaxBodySize
+	}
+
+	return DefaultAsyncMaxBodySize
+}
+
+// WithAsyncLimit sets the maximum number of background fanouts that may run at once.  When this many fanouts are
+// running, the xhttp.RespondAsync preference is ignored and requests are fanned out synchronously.  If limit is
+// nonpositive, DefaultAsyncLimit is used.
+func WithAsyncLimit(limit int) Option {
+	return func(h *Handler) {
+		h.asyncLimit = limit
+	}
+}
+
+// newAsyncSlots creates the semaphore which bounds the number of concurrent background fanouts
+func newAsyncSlots(limit int) semaphore.Interface {
+	if limit < 1 {
+		limit = DefaultAsyncLimit
+	}
+
+	return semaphore.New(int64(limit))
+}
+
+// resultWriter is an http.ResponseWriter that captures a fanout response as an AsyncResult.  A body larger
+// than maxBodySize is discarded, and the writer is marked as overflowed.
+type resultWriter struct {
+	result      AsyncResult
+	maxBodySize int
+	overflow    bool
+}
+
+func (rw *resultWriter) Header() http.Header {
+	if rw.result.Header == nil {
+		rw.result.Header = make(http.Header)
+	}
+
+	return rw.result.Header
+}
+
+func (rw *resultWriter) WriteHeader(statusCode int) {
+	if rw.result.StatusCode == 0 && !xhttp.IsInformational(statusCode) {
+		rw.result.StatusCode = statusCode
+	}
+}
+
+func (rw *resultWriter) Write(data []byte) (int, error) {
+	rw.WriteHeader(http.StatusOK)
+	if rw.overflow {
+		return 0, ErrAsyncResultTooLarge
+	}
+
+	if len(rw.result.Body)+len(data) > rw.maxBodySize {
+		rw.overflow = true
+		rw.result.Body = nil
+		return 0, ErrAsyncResultTooLarge
+	}
+
+	rw.result.Body = append(rw.result.Body, data...)
+	return len(data), nil
+}
+
+// asyncResult produces the AsyncResult to store for a completed fanout
+func (rw *resultWriter) asyncResult() AsyncResult {
+	if rw.overflow {
+		return AsyncResult{Done: true, StatusCode: http.StatusBadGateway}
+	}
+
+	result := rw.result
+	result.Done = true
+	return result
+}
+
+// serveAsync starts a background fanout and responds with a 202.  The fanout requests must have been created
+// with a detached context, and the caller must have acquired one of the async slots, which is released when
+// the background fanout completes.  The background fanout is always bounded by the async timeout, so that its
+// pending placeholder cannot expire before the result is stored.
+func (h *Handler) serveAsync(logger log.Logger, response http.ResponseWriter, fanoutCtx context.Context, requests []*http.Request) {
+	var (
+		id      = xhttp.NewRequestID()
+		timeout = h.asyncTimeoutOrDefault()
+	)
+
+	if err := h.asyncStore.Pending(id, timeout); err != nil {
+		h.asyncSlots.Release()
+		logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to store asynchronous fanout", logging.ErrorKey(), err)
+		h.errorEncoder(fanoutCtx, err, response)
+		return
+	}
+
+	go func() {
+		defer h.asyncSlots.Release()
+
+		// each request keeps its own context, which may carry values set by FanoutRequestFuncs
+		deadline := time.Now().Add(timeout)
+		ctx, cancel := context.WithDeadline(fanoutCtx, deadline)
+		defer cancel()
+
+		for i, r := range requests {
+			requestCtx, requestCancel := context.WithDeadline(r.Context(), deadline)
+			defer requestCancel()
+
+			xhttp.SetDeadline(r.Header, deadline)
+			requests[i] = r.WithContext(requestCtx)
+		}
+
+		rw := &resultWriter{maxBodySize: h.asyncMaxBodySizeOrDefault()}
+		h.fanout(ctx, logger, rw, requests)
+		if rw.overflow {
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "asynchronous fanout result discarded", "id", id, logging.ErrorKey(), ErrAsyncResultTooLarge)
+		}
+
+		if err := h.asyncStore.Put(id, rw.asyncResult()); err != nil {
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to store asynchronous fanout result", "id", id, logging.ErrorKey(), err)
+		}
+	}()
+
+	if h.asyncLocation != nil {
+		response.Header().Set("Location", h.asyncLocation(id))
+	}
+
+	response.Header().Set(xhttp.PreferenceAppliedHeader, xhttp.RespondAsync)
+	response.WriteHeader(http.StatusAccepted)
+}
+
+// ResultHandler is an http.Handler that serves the results of asynchronous fanouts.  The result identifier
+// is specified as a gorilla path variable.  While a fanout is running, this handler responds with a 202.
+// Once complete, this handler responds exactly as the fanout would have synchronously.
+type ResultHandler struct {
+	Logger   log.Logger
+	Store    AsyncResultStore
+	Variable string
+}
+
+func (rh *ResultHandler) logger() log.Logger {
+	if rh.Logger != nil {
+		return rh.Logger
+	}
+
+	return logging.DefaultLogger()
+}
+
+func (rh *ResultHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	id, ok := mux.Vars(request)[rh.Variable]
+	if !ok {
+		rh.logger().Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "missing path variable", "variable", rh.Variable)
+		response.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	result, err := rh.Store.Get(id)
+	switch {
+	case err == ErrAsyncResultNotFound:
+		response.WriteHeader(http.StatusNotFound)
+
+	case err != nil:
+		rh.logger().Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to retrieve asynchronous fanout result", "id", id, logging.ErrorKey(), err)
+		response.WriteHeader(http.StatusInternalServerError)
+
+	case !result.Done:
+		response.WriteHeader(http.StatusAccepted)
+
+	default:
+		for name, values := range result.Header {
+			response.Header()[name] = values
+		}
+
+		response.WriteHeader(result.StatusCode)
+		response.Write(result.Body)
+	}
+}
@@ -0,0 +1,419 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/store"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/Comcast/webpa-common/xhttp/xhttptest"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAsyncResultStore struct {
+	mock.Mock
+}
+
+func (m *mockAsyncResultStore) Pending(id string, timeout time.Duration) error {
+	return m.Called(id, timeout).Error(0)
+}
+
+func (m *mockAsyncResultStore) Put(id string, result AsyncResult) error {
+	return m.Called(id, result).Error(0)
+}
+
+func (m *mockAsyncResultStore) Get(id string) (AsyncResult, error) {
+	arguments := m.Called(id)
+	return arguments.Get(0).(AsyncResult), arguments.Error(1)
+}
+
+func TestNewAsyncResultStore(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	assert.Panics(func() {
+		NewAsyncResultStore(nil, 0)
+	})
+
+	rs := NewAsyncResultStore(store.NewMemoryKV(store.MemoryKVOptions{}), 0)
+	require.NotNil(rs)
+
+	_, err := rs.Get("missing")
+	assert.Equal(ErrAsyncResultNotFound, err)
+
+	expected := AsyncResult{
+		Done:       true,
+		StatusCode: 299,
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+		Body:       []byte("result"),
+	}
+
+	require.NoError(rs.Put("test", expected))
+	actual, err := rs.Get("test")
+	require.NoError(err)
+	assert.Equal(expected, actual)
+}
+
+// ttlKV is a store.KV that records the ttl of each Put
+type ttlKV struct {
+	store.KV
+	ttls map[string]time.Duration
+}
+
+func (kv *ttlKV) Put(key string, value []byte, ttl time.Duration) error {
+	kv.ttls[key] = ttl
+	return kv.KV.Put(key, value, ttl)
+}
+
+func TestAsyncResultStorePending(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		kv = &ttlKV{KV: store.NewMemoryKV(store.MemoryKVOptions{}), ttls: make(map[string]time.Duration)}
+		rs = NewAsyncResultStore(kv, time.Minute)
+	)
+
+	// the placeholder must outlive the fanout it stands in for
+	require.NoError(rs.Pending("test", time.Hour))
+	assert.Equal(time.Hour+time.Minute, kv.ttls["test"])
+
+	result, err := rs.Get("test")
+	require.NoError(err)
+	assert.Equal(AsyncResult{}, result)
+
+	require.NoError(rs.Put("test", AsyncResult{Done: true, StatusCode: 200}))
+	assert.Equal(time.Minute, kv.ttls["test"])
+}
+
+func TestResultWriter(t *testing.T) {
+	t.Run("WithinLimit", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			rw     = &resultWriter{maxBodySize: 10}
+		)
+
+		rw.Header().Set("Content-Type", "text/plain")
+		n, err := rw.Write([]byte("12345"))
+		assert.Equal(5, n)
+		assert.NoError(err)
+
+		n, err = rw.Write([]byte("67890"))
+		assert.Equal(5, n)
+		assert.NoError(err)
+
+		assert.False(rw.overflow)
+		assert.Equal(
+			AsyncResult{Done: true, StatusCode: 200, Header: http.Header{"Content-Type": {"text/plain"}}, Body: []byte("1234567890")},
+			rw.asyncResult(),
+		)
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			rw     = &resultWriter{maxBodySize: 10}
+		)
+
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.WriteHeader(201)
+		rw.Write([]byte("12345"))
+
+		n, err := rw.Write([]byte("678901"))
+		assert.Zero(n)
+		assert.Equal(ErrAsyncResultTooLarge, err)
+
+		n, err = rw.Write([]byte("1"))
+		assert.Zero(n)
+		assert.Equal(ErrAsyncResultTooLarge, err)
+
+		assert.True(rw.overflow)
+		assert.Equal(AsyncResult{Done: true, StatusCode: http.StatusBadGateway}, rw.asyncResult())
+	})
+}
+
+func TestAsyncDefaults(t *testing.T) {
+	assert := assert.New(t)
+
+	h := New(generateEndpoints(1))
+	assert.Equal(DefaultAsyncTimeout, h.asyncTimeoutOrDefault())
+	assert.Equal(DefaultAsyncMaxBodySize, h.asyncMaxBodySizeOrDefault())
+
+	h = New(generateEndpoints(1), WithAsyncTimeout(-1), WithAsyncMaxBodySize(-1))
+	assert.Equal(DefaultAsyncTimeout, h.asyncTimeoutOrDefault())
+	assert.Equal(DefaultAsyncMaxBodySize, h.asyncMaxBodySizeOrDefault())
+
+	h = New(generateEndpoints(1), WithAsyncTimeout(time.Second), WithAsyncMaxBodySize(100))
+	assert.Equal(time.Second, h.asyncTimeoutOrDefault())
+	assert.Equal(100, h.asyncMaxBodySizeOrDefault())
+}
+
+func testHandlerAsyncIgnored(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger   = logging.NewTestLogger(nil, t)
+		ctx      = logging.WithLogger(context.Background(), logger)
+		original = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response = httptest.NewRecorder()
+
+		endpoints  = generateEndpoints(1)
+		transactor = new(xhttptest.MockTransactor)
+		handler    = New(endpoints, WithTransactor(transactor.Do), WithAsync(nil, nil))
+	)
+
+	require.NotNil(handler)
+	original.Header.Set(xhttp.PreferHeader, xhttp.RespondAsync)
+	transactor.OnDo(
+		xhttptest.MatchMethod("GET"),
+		xhttptest.MatchURLString(endpoints[0].String()+"/api/v2/something"),
+	).RespondWith(xhttptest.ExpectedResponse{StatusCode: 200, Body: []byte("sync")}).Once()
+
+	handler.ServeHTTP(response, original)
+	assert.Equal(200, response.Code)
+	assert.Equal("sync", response.Body.String())
+	assert.Empty(response.HeaderMap.Get(xhttp.PreferenceAppliedHeader))
+
+	transactor.AssertExpectations(t)
+}
+
+func testHandlerAsyncSuccess(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger        = logging.NewTestLogger(nil, t)
+		ctx, cancel   = context.WithCancel(logging.WithLogger(context.Background(), logger))
+		original      = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response      = httptest.NewRecorder()
+		rs            = NewAsyncResultStore(store.NewMemoryKV(store.MemoryKVOptions{}), time.Minute)
+		blocker       = make(chan time.Time)
+		endpoints     = generateEndpoints(1)
+		transactor    = new(xhttptest.MockTransactor)
+		resultHandler = &ResultHandler{Logger: logger, Store: rs, Variable: "id"}
+		router        = mux.NewRouter()
+
+		handler = New(endpoints,
+			WithTransactor(transactor.Do),
+			WithAsync(rs, func(id string) string { return "/results/" + id }),
+			WithAsyncTimeout(time.Minute),
+		)
+	)
+
+	require.NotNil(handler)
+	router.Handle("/results/{id}", resultHandler)
+	original.Header.Set(xhttp.PreferHeader, "return=minimal, "+xhttp.RespondAsync)
+	transactor.OnDo(
+		xhttptest.MatchMethod("GET"),
+		xhttptest.MatchURLString(endpoints[0].String()+"/api/v2/something"),
+	).RespondWith(xhttptest.ExpectedResponse{
+		StatusCode: 201,
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+		Body:       []byte("async"),
+	}).Once().WaitUntil(blocker)
+
+	handler.ServeHTTP(response, original)
+	assert.Equal(http.StatusAccepted, response.Code)
+	assert.Equal(xhttp.RespondAsync, response.HeaderMap.Get(xhttp.PreferenceAppliedHeader))
+	location := response.HeaderMap.Get("Location")
+	require.NotEmpty(location)
+
+	// the background fanout must not be tied to the original request
+	cancel()
+
+	pending := httptest.NewRecorder()
+	router.ServeHTTP(pending, httptest.NewRequest("GET", location, nil))
+	assert.Equal(http.StatusAccepted, pending.Code)
+
+	close(blocker)
+	var done *httptest.ResponseRecorder
+	for attempt := 0; attempt < 100; attempt++ {
+		done = httptest.NewRecorder()
+		router.ServeHTTP(done, httptest.NewRequest("GET", location, nil))
+		if done.Code != http.StatusAccepted {
+			break
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	assert.Equal(201, done.Code)
+	assert.Equal("text/plain", done.HeaderMap.Get("Content-Type"))
+	assert.Equal("async", done.Body.String())
+
+	transactor.AssertExpectations(t)
+}
+
+func testHandlerAsyncStoreError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		expectedError = errors.New("expected")
+		logger        = logging.NewTestLogger(nil, t)
+		ctx           = logging.WithLogger(context.Background(), logger)
+		original      = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response      = httptest.NewRecorder()
+		rs            = new(mockAsyncResultStore)
+
+		handler = New(generateEndpoints(1),
+			WithAsync(rs, nil),
+			WithErrorEncoder(func(_ context.Context, err error, response http.ResponseWriter) {
+				assert.Equal(expectedError, err)
+				response.WriteHeader(599)
+			}),
+		)
+	)
+
+	require.NotNil(handler)
+	original.Header.Set(xhttp.PreferHeader, xhttp.RespondAsync)
+	rs.On("Pending", mock.AnythingOfType("string"), DefaultAsyncTimeout).Return(expectedError).Once()
+
+	handler.ServeHTTP(response, original)
+	assert.Equal(599, response.Code)
+
+	rs.AssertExpectations(t)
+}
+
+type testAsyncKey struct{}
+
+func testHandlerAsyncLimit(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		// the background fanout may still be logging when this test ends, so a test logger cannot be used
+		ctx        = logging.WithLogger(context.Background(), logging.DefaultLogger())
+		rs         = NewAsyncResultStore(store.NewMemoryKV(store.MemoryKVOptions{}), time.Minute)
+		blocker    = make(chan time.Time)
+		done       = make(chan struct{})
+		asyncCtx   = make(chan context.Context, 1)
+		endpoints  = generateEndpoints(1)
+		transactor = new(xhttptest.MockTransactor)
+
+		handler = New(endpoints,
+			WithTransactor(func(request *http.Request) (*http.Response, error) {
+				if len(request.Header.Get(xhttp.DeadlineHeader)) > 0 {
+					asyncCtx <- request.Context()
+				}
+
+				return transactor.Do(request)
+			}),
+			WithAsync(rs, nil),
+			WithAsyncTimeout(time.Minute),
+			WithAsyncLimit(1),
+			WithFanoutBefore(func(ctx context.Context, _, _ *http.Request, _ []byte) context.Context {
+				return context.WithValue(ctx, testAsyncKey{}, "value")
+			}),
+		)
+	)
+
+	require.NotNil(handler)
+	transactor.OnDo(
+		func(request *http.Request) bool { return len(request.Header.Get(xhttp.DeadlineHeader)) > 0 },
+	).RespondWith(xhttptest.ExpectedResponse{StatusCode: 200, Body: []byte("async")}).Once().WaitUntil(blocker).Run(func(mock.Arguments) {
+		close(done)
+	})
+
+	transactor.OnDo(
+		xhttptest.MatchHeader(xhttp.DeadlineHeader, ""),
+	).RespondWith(xhttptest.ExpectedResponse{StatusCode: 200, Body: []byte("sync")}).Once()
+
+	first := httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+	first.Header.Set(xhttp.PreferHeader, xhttp.RespondAsync)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, first)
+	assert.Equal(http.StatusAccepted, response.Code)
+
+	// while the first fanout is running, the limit has been reached
+	second := httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+	second.Header.Set(xhttp.PreferHeader, xhttp.RespondAsync)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, second)
+	assert.Equal(200, response.Code)
+	assert.Equal("sync", response.Body.String())
+	assert.Empty(response.HeaderMap.Get(xhttp.PreferenceAppliedHeader))
+
+	// the background fanout derives from each fanout request's own context
+	select {
+	case ctx := <-asyncCtx:
+		_, hasDeadline := ctx.Deadline()
+		assert.True(hasDeadline)
+		assert.Equal("value", ctx.Value(testAsyncKey{}))
+	case <-time.After(5 * time.Second):
+		assert.Fail("The background fanout did not start")
+	}
+
+	close(blocker)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail("The background fanout did not complete")
+	}
+
+	transactor.AssertExpectations(t)
+}
+
+func TestHandlerAsync(t *testing.T) {
+	t.Run("Ignored", testHandlerAsyncIgnored)
+	t.Run("Success", testHandlerAsyncSuccess)
+	t.Run("StoreError", testHandlerAsyncStoreError)
+	t.Run("Limit", testHandlerAsyncLimit)
+}
+
+func TestResultHandler(t *testing.T) {
+	var (
+		expectedError = errors.New("expected")
+
+		testData = []struct {
+			result             AsyncResult
+			err                error
+			expectedStatusCode int
+		}{
+			{AsyncResult{}, ErrAsyncResultNotFound, http.StatusNotFound},
+			{AsyncResult{}, expectedError, http.StatusInternalServerError},
+			{AsyncResult{}, nil, http.StatusAccepted},
+			{AsyncResult{Done: true, StatusCode: 504}, nil, 504},
+		}
+	)
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var (
+				assert   = assert.New(t)
+				rs       = new(mockAsyncResultStore)
+				handler  = &ResultHandler{Store: rs, Variable: "id"}
+				response = httptest.NewRecorder()
+				request  = mux.SetURLVars(httptest.NewRequest("GET", "/results/test", nil), map[string]string{"id": "test"})
+			)
+
+			rs.On("Get", "test").Return(record.result, record.err).Once()
+			handler.ServeHTTP(response, request)
+			assert.Equal(record.expectedStatusCode, response.Code)
+			rs.AssertExpectations(t)
+		})
+	}
+
+	t.Run("MissingVariable", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			handler  = &ResultHandler{Store: new(mockAsyncResultStore), Variable: "id"}
+			response = httptest.NewRecorder()
+		)
+
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/results/test", nil))
+		assert.Equal(http.StatusInternalServerError, response.Code)
+	})
+}
@@ -0,0 +1,178 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/xhttp"
+)
+
+const (
+	// DefaultDNSTTL is the length of time a DNSEndpoints caches a successful lookup when no TTL is supplied
+	DefaultDNSTTL = 30 * time.Second
+
+	// DefaultDNSLookupTimeout is the maximum time a DNSEndpoints waits for a single lookup
+	DefaultDNSLookupTimeout = 5 * time.Second
+)
+
+// LookupIPAddrFunc is the signature of a DNS lookup for A and AAAA records.  net.Resolver.LookupIPAddr
+// has this signature.
+type LookupIPAddrFunc func(context.Context, string) ([]net.IPAddr, error)
+
+// DNSEndpoints is an Endpoints that expands a single hostname into one endpoint per IP address at request time.
+// This allows a fanout to reach every instance behind a name, such as a Kubernetes headless service, without
+// any other service discovery infrastructure.
+//
+// Successful lookups are cached for a fixed TTL, as the standard resolver does not expose record TTLs.  Once the
+// TTL expires, a single lookup is made on behalf of all requests, and it is not bound to any one request's context.
+// If that lookup fails, the most recently resolved endpoints continue to be used until a lookup succeeds.
+type DNSEndpoints struct {
+	base          url.URL
+	host          string
+	port          string
+	ttl           time.Duration
+	lookupTimeout time.Duration
+	lookup        LookupIPAddrFunc
+	now           func() time.Time
+
+	lock    sync.Mutex
+	expires time.Time
+	cached  FixedEndpoints
+
+	// refreshing is closed when the lookup in progress completes, and is nil if there is no lookup in progress
+	refreshing chan struct{}
+
+	// lookupError is the error from the most recent lookup, if that lookup failed
+	lookupError error
+}
+
+// NewDNSEndpoints creates a DNSEndpoints from a URL whose host is the name to look up, e.g. "http://talaria:6200".
+// The scheme and port of this URL are used for every expanded endpoint.  If ttl is nonpositive, DefaultDNSTTL is used.
+// If lookup is nil, net.DefaultResolver.LookupIPAddr is used.
+func NewDNSEndpoints(rawURL string, ttl time.Duration, lookup LookupIPAddrFunc) (*DNSEndpoints, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(base.Hostname()) == 0 {
+		return nil, fmt.Errorf("No hostname in DNS endpoints URL %s", rawURL)
+	}
+
+	if ttl < 1 {
+		ttl = DefaultDNSTTL
+	}
+
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupIPAddr
+	}
+
+	return &DNSEndpoints{
+		base:          *base,
+		host:          base.Hostname(),
+		port:          base.Port(),
+		ttl:           ttl,
+		lookupTimeout: DefaultDNSLookupTimeout,
+		lookup:        lookup,
+		now:           time.Now,
+	}, nil
+}
+
+// endpoints returns the cached base URLs, refreshing them from DNS if they have expired.  If the refresh
+// fails, or the given context ends first, any previously resolved URLs are returned.
+func (de *DNSEndpoints) endpoints(ctx context.Context) (FixedEndpoints, error) {
+	de.lock.Lock()
+	if de.now().Before(de.expires) {
+		fe := de.cached
+		de.lock.Unlock()
+		return fe, nil
+	}
+
+	refreshing := de.refreshing
+	if refreshing == nil {
+		refreshing = make(chan struct{})
+		de.refreshing = refreshing
+		go de.refresh(refreshing)
+	}
+
+	de.lock.Unlock()
+
+	select {
+	case <-refreshing:
+	case <-ctx.Done():
+	}
+
+	de.lock.Lock()
+	defer de.lock.Unlock()
+
+	switch {
+	case len(de.cached) > 0:
+		return de.cached, nil
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	default:
+		return nil, de.lookupError
+	}
+}
+
+// refresh looks up the host, replacing the cached base URLs if successful.  The lookup is not bound to
+// any request's context, so that one canceled request cannot fail the lookup for others.
+func (de *DNSEndpoints) refresh(refreshing chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), de.lookupTimeout)
+	fe, err := de.resolve(ctx)
+	cancel()
+
+	de.lock.Lock()
+	if err == nil {
+		de.cached = fe
+		de.expires = de.now().Add(de.ttl)
+	}
+
+	de.lookupError = err
+	de.refreshing = nil
+	de.lock.Unlock()
+
+	close(refreshing)
+}
+
+// resolve looks up the host and produces one base URL for each of its addresses
+func (de *DNSEndpoints) resolve(ctx context.Context) (FixedEndpoints, error) {
+	addrs, err := de.lookup(ctx, de.host)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(addrs) == 0 {
+		return nil, errors.New("No addresses found")
+	}
+
+	fe := make(FixedEndpoints, len(addrs))
+	for i, addr := range addrs {
+		fe[i] = new(url.URL)
+		*fe[i] = de.base
+		if len(de.port) > 0 {
+			fe[i].Host = net.JoinHostPort(addr.IP.String(), de.port)
+		} else if addr.IP.To4() == nil {
+			fe[i].Host = "[" + addr.IP.String() + "]"
+		} else {
+			fe[i].Host = addr.IP.String()
+		}
+	}
+
+	return fe, nil
+}
+
+func (de *DNSEndpoints) NewEndpoints(original *http.Request) ([]*url.URL, error) {
+	fe, err := de.endpoints(original.Context())
+	if err != nil {
+		return nil, &xhttp.Error{Code: http.StatusServiceUnavailable, Text: fmt.Sprintf("Unable to resolve %s: %s", de.host, err)}
+	}
+
+	return fe.NewEndpoints(original)
+}
@@ -0,0 +1,220 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDNSEndpoints(t *testing.T) {
+	t.Run("InvalidURL", func(t *testing.T) {
+		de, err := NewDNSEndpoints("%%", 0, nil)
+		assert.Nil(t, de)
+		assert.Error(t, err)
+	})
+
+	t.Run("NoHostname", func(t *testing.T) {
+		de, err := NewDNSEndpoints("/path", 0, nil)
+		assert.Nil(t, de)
+		assert.Error(t, err)
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		de, err := NewDNSEndpoints("http://talaria:6200", 0, nil)
+		require.NoError(err)
+		require.NotNil(de)
+		assert.Equal("talaria", de.host)
+		assert.Equal("6200", de.port)
+		assert.Equal(DefaultDNSTTL, de.ttl)
+		assert.Equal(DefaultDNSLookupTimeout, de.lookupTimeout)
+		assert.NotNil(de.lookup)
+	})
+}
+
+func TestDNSEndpoints(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now        = time.Now()
+		lookups    int
+		lookupHost string
+		addrs      = []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("fe80::1")}}
+	)
+
+	de, err := NewDNSEndpoints("https://talaria.svc:6200", time.Minute, func(_ context.Context, host string) ([]net.IPAddr, error) {
+		lookups++
+		lookupHost = host
+		return addrs, nil
+	})
+
+	require.NoError(err)
+	de.now = func() time.Time { return now }
+
+	endpoints, err := de.NewEndpoints(httptest.NewRequest("GET", "/api/v2/device?foo=bar", nil))
+	require.NoError(err)
+	require.Len(endpoints, 2)
+	assert.Equal("https://10.0.0.1:6200/api/v2/device?foo=bar", endpoints[0].String())
+	assert.Equal("https://[fe80::1]:6200/api/v2/device?foo=bar", endpoints[1].String())
+	assert.Equal("talaria.svc", lookupHost)
+	assert.Equal(1, lookups)
+
+	// cached until the TTL expires
+	addrs = []net.IPAddr{{IP: net.ParseIP("10.0.0.2")}}
+	endpoints, err = de.NewEndpoints(httptest.NewRequest("GET", "/another", nil))
+	require.NoError(err)
+	require.Len(endpoints, 2)
+	assert.Equal("https://10.0.0.1:6200/another", endpoints[0].String())
+	assert.Equal(1, lookups)
+
+	now = now.Add(time.Minute)
+	endpoints, err = de.NewEndpoints(httptest.NewRequest("GET", "/another", nil))
+	require.NoError(err)
+	require.Len(endpoints, 1)
+	assert.Equal("https://10.0.0.2:6200/another", endpoints[0].String())
+	assert.Equal(2, lookups)
+}
+
+func TestDNSEndpointsNoPort(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	de, err := NewDNSEndpoints("http://talaria", 0, func(context.Context, string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("::1")}}, nil
+	})
+
+	require.NoError(err)
+	endpoints, err := de.NewEndpoints(httptest.NewRequest("GET", "/", nil))
+	require.NoError(err)
+	require.Len(endpoints, 2)
+	assert.Equal("http://10.0.0.1/", endpoints[0].String())
+	assert.Equal("http://[::1]/", endpoints[1].String())
+}
+
+func TestDNSEndpointsLookupFailure(t *testing.T) {
+	testData := []struct {
+		addrs []net.IPAddr
+		err   error
+	}{
+		{nil, errors.New("expected")},
+		{nil, nil},
+	}
+
+	for _, record := range testData {
+		t.Run("", func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			de, err := NewDNSEndpoints("http://talaria", 0, func(context.Context, string) ([]net.IPAddr, error) {
+				return record.addrs, record.err
+			})
+
+			require.NoError(err)
+			endpoints, err := de.NewEndpoints(httptest.NewRequest("GET", "/", nil))
+			assert.Empty(endpoints)
+			require.Error(err)
+
+			httpError, ok := err.(*xhttp.Error)
+			require.True(ok)
+			assert.Equal(http.StatusServiceUnavailable, httpError.Code)
+		})
+	}
+}
+
+func TestDNSEndpointsStale(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now       = time.Now()
+		lookupErr error
+	)
+
+	de, err := NewDNSEndpoints("http://talaria:6200", time.Minute, func(context.Context, string) ([]net.IPAddr, error) {
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+
+		return []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}}, nil
+	})
+
+	require.NoError(err)
+	de.now = func() time.Time { return now }
+
+	endpoints, err := de.NewEndpoints(httptest.NewRequest("GET", "/", nil))
+	require.NoError(err)
+	require.Len(endpoints, 1)
+
+	// once expired, a failed lookup leaves the previous endpoints in use
+	now = now.Add(time.Minute)
+	lookupErr = errors.New("expected")
+	endpoints, err = de.NewEndpoints(httptest.NewRequest("GET", "/", nil))
+	require.NoError(err)
+	require.Len(endpoints, 1)
+	assert.Equal("http://10.0.0.1:6200/", endpoints[0].String())
+}
+
+func TestDNSEndpointsCanceledRequest(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		lookupCtx = make(chan context.Context, 1)
+		release   = make(chan struct{})
+	)
+
+	de, err := NewDNSEndpoints("http://talaria:6200", time.Minute, func(ctx context.Context, _ string) ([]net.IPAddr, error) {
+		lookupCtx <- ctx
+		<-release
+		return []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}}, nil
+	})
+
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := de.NewEndpoints(httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+		result <- err
+	}()
+
+	var actualCtx context.Context
+	select {
+	case actualCtx = <-lookupCtx:
+	case <-time.After(5 * time.Second):
+		require.Fail("No lookup was made")
+	}
+
+	// canceling the request ends its wait, but not the shared lookup
+	cancel()
+	select {
+	case err := <-result:
+		assert.Error(err)
+	case <-time.After(5 * time.Second):
+		require.Fail("The canceled request did not return")
+	}
+
+	assert.NoError(actualCtx.Err())
+	close(release)
+
+	endpoints, err := de.NewEndpoints(httptest.NewRequest("GET", "/", nil))
+	require.NoError(err)
+	require.Len(endpoints, 1)
+}
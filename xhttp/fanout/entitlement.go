@@ -0,0 +1,86 @@
+package fanout
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Comcast/webpa-common/xhttp"
+)
+
+// PrincipalFunc extracts the authenticated principal from a request context, typically one populated by
+// the secure middleware.  If the context carries no principal, this function returns false.
+type PrincipalFunc func(context.Context) (string, bool)
+
+// EntitlementFunc determines whether a principal may reach a given fanout endpoint
+type EntitlementFunc func(principal string, endpoint *url.URL) bool
+
+// NewHostEntitlements returns an EntitlementFunc backed by a configuration map of principals to the hosts
+// each principal may reach.  A host may be given with or without a port, e.g. "talaria.comcast.net" or
+// "talaria.comcast.net:8080".  The host "*" entitles a principal to every endpoint.
+func NewHostEntitlements(entitlements map[string][]string) EntitlementFunc {
+	hosts := make(map[string]map[string]bool, len(entitlements))
+	for principal, allowed := range entitlements {
+		hosts[principal] = make(map[string]bool, len(allowed))
+		for _, h := range allowed {
+			hosts[principal][h] = true
+		}
+	}
+
+	return func(principal string, endpoint *url.URL) bool {
+		allowed := hosts[principal]
+		return allowed["*"] || allowed[endpoint.Host] || allowed[endpoint.Hostname()]
+	}
+}
+
+// EntitledEndpoints is an Endpoints decorator that removes any endpoint the authenticated caller isn't
+// entitled to reach.  This allows tenants to be isolated from each other at the fanout layer.
+//
+// Requests without a principal, and requests whose principal is entitled to none of the endpoints,
+// are rejected with a 403 status.
+type EntitledEndpoints struct {
+	// Endpoints is the decorated strategy which produces the candidate endpoints
+	Endpoints Endpoints
+
+	// Principal is the strategy for extracting principals.  Each function is tried in order, and the
+	// first principal found is used.
+	Principal []PrincipalFunc
+
+	// Entitled is the strategy that decides which endpoints the principal may reach
+	Entitled EntitlementFunc
+}
+
+func (ee *EntitledEndpoints) principal(ctx context.Context) (string, bool) {
+	for _, f := range ee.Principal {
+		if principal, ok := f(ctx); ok {
+			return principal, true
+		}
+	}
+
+	return "", false
+}
+
+func (ee *EntitledEndpoints) NewEndpoints(original *http.Request) ([]*url.URL, error) {
+	principal, ok := ee.principal(original.Context())
+	if !ok {
+		return nil, &xhttp.Error{Code: http.StatusForbidden, Text: "No authenticated principal for fanout"}
+	}
+
+	endpoints, err := ee.Endpoints.NewEndpoints(original)
+	if err != nil {
+		return nil, err
+	}
+
+	entitled := make([]*url.URL, 0, len(endpoints))
+	for _, e := range endpoints {
+		if ee.Entitled(principal, e) {
+			entitled = append(entitled, e)
+		}
+	}
+
+	if len(entitled) == 0 {
+		return nil, &xhttp.Error{Code: http.StatusForbidden, Text: "Principal is not entitled to any fanout endpoints"}
+	}
+
+	return entitled, nil
+}
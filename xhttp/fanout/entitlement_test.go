@@ -0,0 +1,146 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPrincipalKey struct{}
+
+func testPrincipal(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(testPrincipalKey{}).(string)
+	return principal, ok
+}
+
+func newTestEntitledRequest(principal string) *http.Request {
+	request := httptest.NewRequest("GET", "/api", nil)
+	if len(principal) > 0 {
+		request = request.WithContext(context.WithValue(request.Context(), testPrincipalKey{}, principal))
+	}
+
+	return request
+}
+
+func TestNewHostEntitlements(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		entitled = NewHostEntitlements(map[string][]string{
+			"comcast": {"comcast.com", "shared.com:8080"},
+			"admin":   {"*"},
+		})
+	)
+
+	testData := []struct {
+		principal string
+		endpoint  string
+		expected  bool
+	}{
+		{"comcast", "http://comcast.com", true},
+		{"comcast", "http://comcast.com:8080", true},
+		{"comcast", "http://shared.com:8080", true},
+		{"comcast", "http://shared.com:9090", false},
+		{"comcast", "http://cox.com", false},
+		{"admin", "http://cox.com", true},
+		{"unknown", "http://comcast.com", false},
+	}
+
+	for _, record := range testData {
+		endpoint, err := url.Parse(record.endpoint)
+		require.NoError(t, err)
+		assert.Equal(record.expected, entitled(record.principal, endpoint), "%s -> %s", record.principal, record.endpoint)
+	}
+}
+
+func testEntitledEndpointsFilter(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ee = &EntitledEndpoints{
+			Endpoints: MustNewFixedEndpoints("http://comcast1.com", "http://cox.com", "http://comcast2.com"),
+			Principal: []PrincipalFunc{testPrincipal},
+			Entitled: NewHostEntitlements(map[string][]string{
+				"comcast": {"comcast1.com", "comcast2.com"},
+				"admin":   {"*"},
+			}),
+		}
+	)
+
+	testData := []struct {
+		principal string
+		expected  []string
+	}{
+		{"comcast", []string{"http://comcast1.com/api", "http://comcast2.com/api"}},
+		{"admin", []string{"http://comcast1.com/api", "http://cox.com/api", "http://comcast2.com/api"}},
+	}
+
+	for _, record := range testData {
+		urls, err := ee.NewEndpoints(newTestEntitledRequest(record.principal))
+		require.NoError(err)
+
+		actual := make([]string, 0, len(urls))
+		for _, u := range urls {
+			actual = append(actual, u.String())
+		}
+
+		assert.Equal(record.expected, actual)
+	}
+}
+
+func testEntitledEndpointsForbidden(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ee = &EntitledEndpoints{
+			Endpoints: MustNewFixedEndpoints("http://comcast.com"),
+			Principal: []PrincipalFunc{testPrincipal},
+			Entitled:  NewHostEntitlements(map[string][]string{"comcast": {"comcast.com"}}),
+		}
+	)
+
+	for _, principal := range []string{"", "cox"} {
+		urls, err := ee.NewEndpoints(newTestEntitledRequest(principal))
+		assert.Empty(urls)
+		require.Error(err)
+
+		httpError, ok := err.(*xhttp.Error)
+		require.True(ok)
+		assert.Equal(http.StatusForbidden, httpError.StatusCode())
+	}
+}
+
+func testEntitledEndpointsError(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+		endpoints     = new(mockEndpoints)
+		request       = newTestEntitledRequest("comcast")
+
+		ee = &EntitledEndpoints{
+			Endpoints: endpoints,
+			Principal: []PrincipalFunc{testPrincipal},
+			Entitled:  NewHostEntitlements(map[string][]string{"comcast": {"*"}}),
+		}
+	)
+
+	endpoints.On("NewEndpoints", request).Return(nil, expectedError).Once()
+	urls, err := ee.NewEndpoints(request)
+	assert.Empty(urls)
+	assert.Equal(expectedError, err)
+	endpoints.AssertExpectations(t)
+}
+
+func TestEntitledEndpoints(t *testing.T) {
+	t.Run("Filter", testEntitledEndpointsFilter)
+	t.Run("Forbidden", testEntitledEndpointsForbidden)
+	t.Run("Error", testEntitledEndpointsError)
+}
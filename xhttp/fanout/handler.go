@@ -5,10 +5,13 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/semaphore"
 	"github.com/Comcast/webpa-common/tracing"
 	"github.com/Comcast/webpa-common/tracing/tracinghttp"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	gokithttp "github.com/go-kit/kit/transport/http"
@@ -34,6 +37,14 @@ func WithShouldTerminate(terminate ShouldTerminateFunc) Option {
 	}
 }
 
+// WithShouldAccept configures a validation hook for fanout responses.  Responses rejected by the hook are treated
+// as failures and never terminate the fanout.  If accept is nil, all responses are accepted.
+func WithShouldAccept(accept ShouldAcceptFunc) Option {
+	return func(h *Handler) {
+		h.shouldAccept = accept
+	}
+}
+
 // WithErrorEncoder configures a custom error encoder for errors that occur during fanout setup.
 // If encoder is nil, go-kit's DefaultErrorEncoder is used.
 func WithErrorEncoder(encoder gokithttp.ErrorEncoder) Option {
@@ -109,7 +120,18 @@ type Handler struct {
 	before          []FanoutRequestFunc
 	after           []FanoutResponseFunc
 	shouldTerminate ShouldTerminateFunc
+	shouldAccept    ShouldAcceptFunc
 	transactor      func(*http.Request) (*http.Response, error)
+	transformers    []ResponseTransformer
+
+	asyncStore       AsyncResultStore
+	asyncLocation    func(string) string
+	asyncTimeout     time.Duration
+	asyncMaxBodySize int
+	asyncLimit       int
+	asyncSlots       semaphore.Interface
+
+	multipart bool
 }
 
 // New creates a fanout Handler.  The Endpoints strategy is required, and this constructor function will
@@ -133,12 +155,20 @@ func New(e Endpoints, options ...Option) *Handler {
 		o(h)
 	}
 
+	if h.asyncStore != nil {
+		h.asyncSlots = newAsyncSlots(h.asyncLimit)
+	}
+
 	return h
 }
 
 // newFanoutRequests uses the Endpoints strategy and builds (1) HTTP request for each endpoint.  The configured
 // FanoutRequestFunc options are used to build each request.  This method returns an error if no endpoints were returned
 // by the strategy or if an error reading the original request body occurred.
+//
+// Any request ID carried by the fanout context is forwarded to each endpoint via xhttp.RequestIDHeader, and any
+// deadline of the fanout context is forwarded as the remaining budget via xhttp.DeadlineHeader so that endpoints can
+// shed requests that would finish too late.
 func (h *Handler) newFanoutRequests(fanoutCtx context.Context, original *http.Request) ([]*http.Request, error) {
 	body, err := ioutil.ReadAll(original.Body)
 	if err != nil {
@@ -164,6 +194,14 @@ func (h *Handler) newFanoutRequests(fanoutCtx context.Context, original *http.Re
 			Host:       endpoints[i].Host,
 		}
 
+		if requestID, ok := xhttp.GetRequestID(fanoutCtx); ok {
+			fanout.Header.Set(xhttp.RequestIDHeader, requestID)
+		}
+
+		if deadline, ok := fanoutCtx.Deadline(); ok {
+			xhttp.SetDeadline(fanout.Header, deadline)
+		}
+
 		endpointCtx := fanoutCtx
 		for _, rf := range h.before {
 			endpointCtx = rf(endpointCtx, original, fanout, body)
@@ -198,6 +236,14 @@ func (h *Handler) execute(logger log.Logger, spanner tracing.Spanner, results ch
 			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "error closing fanout response body", logging.ErrorKey(), err)
 		}
 
+		if h.shouldAccept != nil {
+			if err = h.shouldAccept(result.Response, result.Body); err != nil {
+				logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "fanout response rejected", "statusCode", result.StatusCode, "url", request.URL, logging.ErrorKey(), err)
+				result.StatusCode = http.StatusBadGateway
+				result.Err = err
+			}
+		}
+
 	case result.Err != nil:
 		if result.Err == context.Canceled || result.Err == context.DeadlineExceeded {
 			result.StatusCode = http.StatusGatewayTimeout
@@ -216,7 +262,8 @@ func (h *Handler) execute(logger log.Logger, spanner tracing.Spanner, results ch
 }
 
 // finish takes a terminating fanout result and writes the appropriate information to the top-level response.  This method
-// is only invoked when a particular fanout response terminates the fanout, i.e. is considered successful.
+// is only invoked when a particular fanout response terminates the fanout, i.e. is considered successful.  Any configured
+// ResponseTransformers are applied to the body before it is copied to the response.
 func (h *Handler) finish(logger log.Logger, response http.ResponseWriter, result Result) {
 	ctx := result.Request.Context()
 	for _, rf := range h.after {
@@ -226,27 +273,27 @@ func (h *Handler) finish(logger log.Logger, response http.ResponseWriter, result
 	}
 
 	response.Header().Set("Content-Type", result.Response.Header.Get("Content-Type"))
+	body, err := h.transform(ctx, response.Header(), result.Body)
+	if err != nil {
+		// the after functions have run, so keep the headers they set, except for those describing the fanout entity
+		logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to transform fanout response", logging.ErrorKey(), err)
+		response.Header().Del("Content-Type")
+		response.Header().Del("Content-Length")
+		h.errorEncoder(ctx, err, response)
+		return
+	}
+
 	response.WriteHeader(result.StatusCode)
-	if count, err := response.Write(result.Body); err != nil {
+	if count, err := response.Write(body); err != nil {
 		logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "error writing response body", logging.ErrorKey(), err)
 	} else {
 		logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "wrote fanout response", "bytes", count)
 	}
 }
 
-func (h *Handler) ServeHTTP(response http.ResponseWriter, original *http.Request) {
-	var (
-		fanoutCtx     = original.Context()
-		logger        = logging.GetLogger(fanoutCtx)
-		requests, err = h.newFanoutRequests(fanoutCtx, original)
-	)
-
-	if err != nil {
-		logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to create fanout", logging.ErrorKey(), err)
-		h.errorEncoder(fanoutCtx, err, response)
-		return
-	}
-
+// fanout executes the fanout requests concurrently, writing the first terminating result or, failing that,
+// the largest status code to the response.
+func (h *Handler) fanout(fanoutCtx context.Context, logger log.Logger, response http.ResponseWriter, requests []*http.Request) {
 	var (
 		spanner = tracing.NewSpanner()
 		results = make(chan Result, len(requests))
@@ -282,3 +329,44 @@ func (h *Handler) ServeHTTP(response http.ResponseWriter, original *http.Request
 
 	response.WriteHeader(statusCode)
 }
+
+// ServeHTTP performs the fanout.  If asynchronous fanouts are enabled with WithAsync and the client prefers
+// xhttp.RespondAsync, the fanout is performed in the background instead.  Otherwise, if multipart responses are
+// enabled with WithMultipart and the client accepts MultipartMediaType, every endpoint's response is returned.
+func (h *Handler) ServeHTTP(response http.ResponseWriter, original *http.Request) {
+	var (
+		fanoutCtx = original.Context()
+		async     = h.asyncStore != nil && xhttp.PrefersAsync(original.Header) && h.asyncSlots.TryAcquire()
+	)
+
+	if async {
+		fanoutCtx = xhttp.Detach(fanoutCtx)
+	}
+
+	var (
+		logger        = logging.GetLogger(fanoutCtx)
+		requests, err = h.newFanoutRequests(fanoutCtx, original)
+	)
+
+	if err != nil {
+		if async {
+			h.asyncSlots.Release()
+		}
+
+		logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to create fanout", logging.ErrorKey(), err)
+		h.errorEncoder(fanoutCtx, err, response)
+		return
+	}
+
+	if async {
+		h.serveAsync(logger, response, fanoutCtx, requests)
+		return
+	}
+
+	if h.multipart && acceptsMultipart(original.Header) {
+		h.serveMultipart(fanoutCtx, logger, response, requests)
+		return
+	}
+
+	h.fanout(fanoutCtx, logger, response, requests)
+}
@@ -334,11 +334,108 @@ func testHandlerTimeout(t *testing.T, endpointCount int) {
 	transactor.AssertExpectations(t)
 }
 
+func testHandlerRequestID(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ctx      = xhttp.WithRequestID(logging.WithLogger(context.Background(), logging.NewTestLogger(nil, t)), "test-request-id")
+		original = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response = httptest.NewRecorder()
+
+		endpoints  = generateEndpoints(1)
+		transactor = new(xhttptest.MockTransactor)
+		handler    = New(endpoints, WithTransactor(transactor.Do))
+	)
+
+	require.NotNil(handler)
+	transactor.OnDo(
+		xhttptest.MatchMethod("GET"),
+		xhttptest.MatchHeader(xhttp.RequestIDHeader, "test-request-id"),
+	).RespondWith(xhttptest.ExpectedResponse{StatusCode: 200}).Once()
+
+	handler.ServeHTTP(response, original)
+	assert.Equal(200, response.Code)
+	transactor.AssertExpectations(t)
+}
+
+func testHandlerShouldAccept(t *testing.T, expectedResponses []xhttptest.ExpectedResponse, expectedStatusCode int, expectedResponseBody string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ctx      = logging.WithLogger(context.Background(), logging.NewTestLogger(nil, t))
+		original = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response = httptest.NewRecorder()
+
+		endpoints  = generateEndpoints(len(expectedResponses))
+		transactor = new(xhttptest.MockTransactor)
+		complete   = make(chan struct{}, len(expectedResponses))
+		handler    = New(endpoints, WithTransactor(transactor.Do), WithShouldAccept(RejectEmptyJSON))
+	)
+
+	require.NotNil(handler)
+	for i, er := range expectedResponses {
+		transactor.OnDo(
+			xhttptest.MatchURLString(endpoints[i].String() + "/api/v2/something"),
+		).RespondWith(er).Once().Run(func(mock.Arguments) { complete <- struct{}{} })
+	}
+
+	handler.ServeHTTP(response, original)
+	assert.Equal(expectedStatusCode, response.Code)
+	assert.Equal(expectedResponseBody, response.Body.String())
+
+	after := time.After(5 * time.Second)
+	for i := 0; i < len(expectedResponses); i++ {
+		select {
+		case <-complete:
+			// passing
+		case <-after:
+			assert.Fail("Not all transactors completed")
+			i = len(expectedResponses)
+		}
+	}
+
+	transactor.AssertExpectations(t)
+}
+
+func testHandlerDeadline(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		deadline    = time.Now().Add(time.Hour)
+		ctx, cancel = context.WithDeadline(logging.WithLogger(context.Background(), logging.NewTestLogger(nil, t)), deadline)
+		original    = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response    = httptest.NewRecorder()
+
+		endpoints  = generateEndpoints(1)
+		transactor = new(xhttptest.MockTransactor)
+		handler    = New(endpoints, WithTransactor(transactor.Do))
+	)
+
+	defer cancel()
+	require.NotNil(handler)
+	transactor.OnDo(
+		xhttptest.MatchMethod("GET"),
+		func(request *http.Request) bool {
+			received, ok, err := xhttp.GetDeadline(request.Header)
+			return err == nil && ok && received.After(deadline.Add(-time.Minute)) && received.Before(deadline.Add(time.Minute))
+		},
+	).RespondWith(xhttptest.ExpectedResponse{StatusCode: 200}).Once()
+
+	handler.ServeHTTP(response, original)
+	assert.Equal(200, response.Code)
+	transactor.AssertExpectations(t)
+}
+
 func TestHandler(t *testing.T) {
 	t.Run("BodyError", testHandlerBodyError)
 	t.Run("NoEndpoints", testHandlerNoEndpoints)
 	t.Run("EndpointsError", testHandlerEndpointsError)
 	t.Run("BadTransactor", testHandlerBadTransactor)
+	t.Run("RequestID", testHandlerRequestID)
+	t.Run("Deadline", testHandlerDeadline)
 
 	t.Run("Fanout", func(t *testing.T) {
 		testData := []struct {
@@ -426,6 +523,40 @@ func TestHandler(t *testing.T) {
 		})
 	})
 
+	t.Run("ShouldAccept", func(t *testing.T) {
+		testData := []struct {
+			responses            []xhttptest.ExpectedResponse
+			expectedStatusCode   int
+			expectedResponseBody string
+		}{
+			{
+				[]xhttptest.ExpectedResponse{
+					{StatusCode: 200, Body: []byte("{}")},
+				},
+				http.StatusBadGateway,
+				"",
+			},
+			{
+				[]xhttptest.ExpectedResponse{
+					{StatusCode: 200, Body: []byte(" [ ] ")}, {StatusCode: 404},
+				},
+				http.StatusBadGateway,
+				"",
+			},
+			{
+				[]xhttptest.ExpectedResponse{
+					{StatusCode: 200}, {StatusCode: 200, Body: []byte(`{"expected": "body"}`)}, {StatusCode: 503},
+				},
+				200,
+				`{"expected": "body"}`,
+			},
+		}
+
+		for _, record := range testData {
+			testHandlerShouldAccept(t, record.responses, record.expectedStatusCode, record.expectedResponseBody)
+		}
+	})
+
 	t.Run("Timeout", func(t *testing.T) {
 		for _, endpointCount := range []int{1, 2, 3, 5} {
 			t.Run(fmt.Sprintf("EndpointCount=%d", endpointCount), func(t *testing.T) {
@@ -449,20 +580,24 @@ func testNewNilOptions(t *testing.T) {
 
 		handler = New(FixedEndpoints{},
 			WithShouldTerminate(nil),
+			WithShouldAccept(nil),
 			WithErrorEncoder(nil),
 			WithTransactor(nil),
 			WithFanoutBefore(),
 			WithClientBefore(),
 			WithFanoutAfter(),
+			WithResponseTransformers(),
 		)
 	)
 
 	require.NotNil(handler)
 	assert.NotNil(handler.shouldTerminate)
+	assert.Nil(handler.shouldAccept)
 	assert.NotNil(handler.errorEncoder)
 	assert.NotNil(handler.transactor)
 	assert.Empty(handler.before)
 	assert.Empty(handler.after)
+	assert.Empty(handler.transformers)
 }
 
 func testNewNoOptions(t *testing.T) {
@@ -475,10 +610,12 @@ func testNewNoOptions(t *testing.T) {
 
 	require.NotNil(handler)
 	assert.NotNil(handler.shouldTerminate)
+	assert.Nil(handler.shouldAccept)
 	assert.NotNil(handler.errorEncoder)
 	assert.NotNil(handler.transactor)
 	assert.Empty(handler.before)
 	assert.Empty(handler.after)
+	assert.Empty(handler.transformers)
 }
 
 func testNewShouldTerminate(t *testing.T) {
@@ -0,0 +1,126 @@
+package fanout
+
+import (
+	"context"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/tracing"
+	"github.com/Comcast/webpa-common/tracing/tracinghttp"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// MultipartMediaType is the media type of an aggregated fanout response
+	MultipartMediaType = "multipart/mixed"
+
+	// EndpointHeader is the part header containing the URL of the fanout request that produced the part
+	EndpointHeader = "X-Fanout-Endpoint"
+
+	// StatusCodeHeader is the part header containing the HTTP status code of the fanout response, or the
+	// inferred status code if the fanout transaction failed
+	StatusCodeHeader = "X-Fanout-Status-Code"
+
+	// ErrorHeader is the part header containing the text of any error from the fanout transaction
+	ErrorHeader = "X-Fanout-Error"
+)
+
+// WithMultipart enables aggregated fanout responses.  When a request's Accept header includes MultipartMediaType,
+// the handler waits for every endpoint instead of the first terminating result, and streams each endpoint's response
+// as it arrives as one part of a multipart/mixed entity.  This is intended for diagnostic tooling that needs every
+// backend's answer.  Other requests are unaffected.
+func WithMultipart(enabled bool) Option {
+	return func(h *Handler) {
+		h.multipart = enabled
+	}
+}
+
+// acceptsMultipart tests if an Accept header includes MultipartMediaType
+func acceptsMultipart(h http.Header) bool {
+	for _, value := range h["Accept"] {
+		for _, accept := range strings.Split(value, ",") {
+			if mediaType, _, err := mime.ParseMediaType(accept); err == nil && mediaType == MultipartMediaType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// writePart writes a single fanout result as a part of a multipart response
+func writePart(w *multipart.Writer, result Result) error {
+	header := make(textproto.MIMEHeader)
+	header.Set(EndpointHeader, result.Request.URL.String())
+	header.Set(StatusCodeHeader, strconv.Itoa(result.StatusCode))
+	if result.Err != nil {
+		header.Set(ErrorHeader, result.Err.Error())
+	}
+
+	// the response headers have already been written, so each part carries its own span
+	tracinghttp.HeadersForSpans("", http.Header(header), result.Span)
+
+	if result.Response != nil {
+		if contentType := result.Response.Header.Get("Content-Type"); len(contentType) > 0 {
+			header.Set("Content-Type", contentType)
+		}
+	}
+
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = part.Write(result.Body)
+	return err
+}
+
+// serveMultipart executes the fanout requests concurrently and streams every result as a part of a multipart/mixed
+// response.  The overall status code is always 200, as each endpoint's status code is carried by its part.
+func (h *Handler) serveMultipart(fanoutCtx context.Context, logger log.Logger, response http.ResponseWriter, requests []*http.Request) {
+	var (
+		spanner = tracing.NewSpanner()
+		results = make(chan Result, len(requests))
+		writer  = multipart.NewWriter(response)
+	)
+
+	flusher, _ := response.(http.Flusher)
+
+	for _, r := range requests {
+		go h.execute(logger, spanner, results, r)
+	}
+
+	response.Header().Set("Content-Type", mime.FormatMediaType(MultipartMediaType, map[string]string{"boundary": writer.Boundary()}))
+	response.WriteHeader(http.StatusOK)
+
+	defer func() {
+		if err := writer.Close(); err != nil {
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "error closing multipart response", logging.ErrorKey(), err)
+		}
+	}()
+
+	for i := 0; i < len(requests); i++ {
+		select {
+		case <-fanoutCtx.Done():
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "multipart fanout operation canceled or timed out", logging.ErrorKey(), fanoutCtx.Err())
+			return
+
+		case r := <-results:
+			logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "fanout operation complete", "statusCode", r.StatusCode, "url", r.Request.URL)
+			if err := writePart(writer, r); err != nil {
+				logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "error writing multipart response", logging.ErrorKey(), err)
+				return
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
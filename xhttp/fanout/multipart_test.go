@@ -0,0 +1,184 @@
+package fanout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptsMultipart(t *testing.T) {
+	testData := []struct {
+		accept   []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{"application/json"}, false},
+		{[]string{"multipart/form-data"}, false},
+		{[]string{"multipart/mixed"}, true},
+		{[]string{"application/json, multipart/mixed;q=0.5"}, true},
+		{[]string{"application/json", "Multipart/Mixed"}, true},
+	}
+
+	for _, record := range testData {
+		header := http.Header{"Accept": record.accept}
+		assert.Equal(t, record.expected, acceptsMultipart(header), "%v", record.accept)
+	}
+}
+
+// multipartTransactor responds successfully for endpoint 0, with a 404 for endpoint 1, and fails endpoint 2
+func multipartTransactor(endpoints FixedEndpoints) func(*http.Request) (*http.Response, error) {
+	return func(request *http.Request) (*http.Response, error) {
+		switch request.URL.Host {
+		case endpoints[0].Host:
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"ok": true}`)),
+			}, nil
+
+		case endpoints[1].Host:
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(new(bytes.Buffer)),
+			}, nil
+
+		default:
+			return nil, errors.New("expected")
+		}
+	}
+}
+
+func testHandlerMultipart(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger    = logging.NewTestLogger(nil, t)
+		ctx       = logging.WithLogger(context.Background(), logger)
+		original  = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response  = httptest.NewRecorder()
+		endpoints = generateEndpoints(3)
+
+		handler = New(endpoints,
+			WithTransactor(multipartTransactor(endpoints)),
+			WithMultipart(true),
+		)
+	)
+
+	original.Header.Set("Accept", MultipartMediaType)
+	handler.ServeHTTP(response, original)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.True(response.Flushed)
+
+	mediaType, params, err := mime.ParseMediaType(response.HeaderMap.Get("Content-Type"))
+	require.NoError(err)
+	assert.Equal(MultipartMediaType, mediaType)
+
+	var (
+		reader = multipart.NewReader(response.Body, params["boundary"])
+		parts  = make(map[string]int)
+	)
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(err)
+		body, err := ioutil.ReadAll(part)
+		require.NoError(err)
+
+		statusCode, err := strconv.Atoi(part.Header.Get(StatusCodeHeader))
+		require.NoError(err)
+
+		endpoint := part.Header.Get(EndpointHeader)
+		parts[endpoint] = statusCode
+
+		switch endpoint {
+		case endpoints[0].String() + "/api/v2/something":
+			assert.Equal("application/json", part.Header.Get("Content-Type"))
+			assert.Equal(`{"ok": true}`, string(body))
+			assert.Empty(part.Header.Get(ErrorHeader))
+
+		case endpoints[1].String() + "/api/v2/something":
+			assert.Empty(body)
+			assert.Empty(part.Header.Get(ErrorHeader))
+
+		case endpoints[2].String() + "/api/v2/something":
+			assert.Empty(body)
+			assert.Equal("expected", part.Header.Get(ErrorHeader))
+		}
+	}
+
+	assert.Equal(
+		map[string]int{
+			endpoints[0].String() + "/api/v2/something": http.StatusOK,
+			endpoints[1].String() + "/api/v2/something": http.StatusNotFound,
+			endpoints[2].String() + "/api/v2/something": http.StatusServiceUnavailable,
+		},
+		parts,
+	)
+}
+
+func testHandlerMultipartNotAccepted(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		logger    = logging.NewTestLogger(nil, t)
+		ctx       = logging.WithLogger(context.Background(), logger)
+		original  = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response  = httptest.NewRecorder()
+		endpoints = generateEndpoints(1)
+
+		handler = New(endpoints,
+			WithTransactor(multipartTransactor(endpoints)),
+			WithMultipart(true),
+		)
+	)
+
+	handler.ServeHTTP(response, original)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.HeaderMap.Get("Content-Type"))
+	assert.Equal(`{"ok": true}`, response.Body.String())
+}
+
+func testHandlerMultipartDisabled(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		logger    = logging.NewTestLogger(nil, t)
+		ctx       = logging.WithLogger(context.Background(), logger)
+		original  = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response  = httptest.NewRecorder()
+		endpoints = generateEndpoints(1)
+
+		handler = New(endpoints,
+			WithTransactor(multipartTransactor(endpoints)),
+		)
+	)
+
+	original.Header.Set("Accept", MultipartMediaType)
+	handler.ServeHTTP(response, original)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.HeaderMap.Get("Content-Type"))
+}
+
+func TestHandlerMultipart(t *testing.T) {
+	t.Run("Multipart", testHandlerMultipart)
+	t.Run("NotAccepted", testHandlerMultipartNotAccepted)
+	t.Run("Disabled", testHandlerMultipartDisabled)
+}
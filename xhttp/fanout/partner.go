@@ -0,0 +1,127 @@
+package fanout
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Comcast/webpa-common/xhttp"
+)
+
+// PartnerIDFunc extracts the partner ID from an original HTTP request.  If the request carries no
+// partner ID, this function returns false.
+type PartnerIDFunc func(*http.Request) (string, bool)
+
+// PartnerIDFromHeader returns a PartnerIDFunc that uses the value of an HTTP header as the partner ID
+func PartnerIDFromHeader(header string) PartnerIDFunc {
+	return func(original *http.Request) (string, bool) {
+		value := original.Header.Get(header)
+		return value, len(value) > 0
+	}
+}
+
+// PartnerIDFromJWT returns a PartnerIDFunc that uses a claim in the request's bearer token as the partner ID.
+// If the claim is an array, the first element is used.
+//
+// The token's signature is NOT verified by this function.  Requests must have already passed through
+// the appropriate authorization handler before fanout.
+func PartnerIDFromJWT(claim string) PartnerIDFunc {
+	return func(original *http.Request) (string, bool) {
+		authorization := original.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "Bearer ") {
+			return "", false
+		}
+
+		parts := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+		if len(parts) != 3 {
+			return "", false
+		}
+
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err != nil {
+			return "", false
+		}
+
+		var claims map[string]interface{}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			return "", false
+		}
+
+		switch value := claims[claim].(type) {
+		case string:
+			return value, len(value) > 0
+
+		case []interface{}:
+			if len(value) > 0 {
+				if first, ok := value[0].(string); ok {
+					return first, len(first) > 0
+				}
+			}
+		}
+
+		return "", false
+	}
+}
+
+// PartnerEndpoints is an Endpoints decorator that selects a set of backends based on the partner ID of
+// the original request.  This allows a single fanout to route traffic for multiple tenants.
+type PartnerEndpoints struct {
+	// PartnerID is the strategy for extracting partner IDs.  Each function is tried in order, and the
+	// first partner ID found is used.
+	PartnerID []PartnerIDFunc
+
+	// Partners maps each partner ID onto the Endpoints for that partner
+	Partners map[string]Endpoints
+
+	// Default is the Endpoints used when a request has no partner ID or has a partner ID not present in Partners.
+	// If nil, such requests are rejected with a 403 status.
+	Default Endpoints
+}
+
+// NewPartnerEndpoints creates a PartnerEndpoints from a configuration map of partner IDs to the
+// URLs for that partner.  Each URL is parsed as with NewFixedEndpoints.
+func NewPartnerEndpoints(partners map[string][]string, defaultEndpoints Endpoints, partnerID ...PartnerIDFunc) (*PartnerEndpoints, error) {
+	pe := &PartnerEndpoints{
+		PartnerID: partnerID,
+		Partners:  make(map[string]Endpoints, len(partners)),
+		Default:   defaultEndpoints,
+	}
+
+	for partner, urls := range partners {
+		fe, err := NewFixedEndpoints(urls...)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid endpoints for partner %s: %s", partner, err)
+		}
+
+		pe.Partners[partner] = fe
+	}
+
+	return pe, nil
+}
+
+func (pe *PartnerEndpoints) partnerID(original *http.Request) (string, bool) {
+	for _, f := range pe.PartnerID {
+		if partner, ok := f(original); ok {
+			return partner, true
+		}
+	}
+
+	return "", false
+}
+
+func (pe *PartnerEndpoints) NewEndpoints(original *http.Request) ([]*url.URL, error) {
+	if partner, ok := pe.partnerID(original); ok {
+		if e, ok := pe.Partners[partner]; ok {
+			return e.NewEndpoints(original)
+		}
+	}
+
+	if pe.Default != nil {
+		return pe.Default.NewEndpoints(original)
+	}
+
+	return nil, &xhttp.Error{Code: http.StatusForbidden, Text: "No fanout endpoints for partner"}
+}
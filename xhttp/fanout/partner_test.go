@@ -0,0 +1,164 @@
+package fanout
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBearer(claims string) string {
+	return "Bearer " +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+}
+
+func TestPartnerIDFromHeader(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		partnerID = PartnerIDFromHeader("X-Partner")
+		request   = httptest.NewRequest("GET", "/", nil)
+	)
+
+	partner, ok := partnerID(request)
+	assert.Empty(partner)
+	assert.False(ok)
+
+	request.Header.Set("X-Partner", "comcast")
+	partner, ok = partnerID(request)
+	assert.Equal("comcast", partner)
+	assert.True(ok)
+}
+
+func TestPartnerIDFromJWT(t *testing.T) {
+	testData := []struct {
+		authorization string
+		expected      string
+		expectedOK    bool
+	}{
+		{"", "", false},
+		{"Basic dXNlcjpwYXNzd29yZA==", "", false},
+		{"Bearer notajwt", "", false},
+		{"Bearer a.!!!.c", "", false},
+		{newTestBearer(`not json`), "", false},
+		{newTestBearer(`{"sub":"test"}`), "", false},
+		{newTestBearer(`{"partner":123}`), "", false},
+		{newTestBearer(`{"partner":[]}`), "", false},
+		{newTestBearer(`{"partner":"comcast"}`), "comcast", true},
+		{newTestBearer(`{"partner":["cox","comcast"]}`), "cox", true},
+	}
+
+	for _, record := range testData {
+		t.Run(record.authorization, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				request = httptest.NewRequest("GET", "/", nil)
+			)
+
+			if len(record.authorization) > 0 {
+				request.Header.Set("Authorization", record.authorization)
+			}
+
+			partner, ok := PartnerIDFromJWT("partner")(request)
+			assert.Equal(record.expected, partner)
+			assert.Equal(record.expectedOK, ok)
+		})
+	}
+}
+
+func testNewPartnerEndpointsInvalid(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		pe, err = NewPartnerEndpoints(map[string][]string{"comcast": {"%%"}}, nil)
+	)
+
+	assert.Nil(pe)
+	assert.Error(err)
+}
+
+func testNewPartnerEndpointsSelect(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		pe, err = NewPartnerEndpoints(
+			map[string][]string{
+				"comcast": {"http://comcast1.com", "http://comcast2.com"},
+				"cox":     {"http://cox.com"},
+			},
+			MustNewFixedEndpoints("http://default.com"),
+			PartnerIDFromHeader("X-Partner"),
+			PartnerIDFromJWT("partner"),
+		)
+	)
+
+	require.NoError(err)
+	require.NotNil(pe)
+
+	testData := []struct {
+		header        string
+		authorization string
+		expected      []string
+	}{
+		{"", "", []string{"http://default.com/api"}},
+		{"unknown", "", []string{"http://default.com/api"}},
+		{"comcast", "", []string{"http://comcast1.com/api", "http://comcast2.com/api"}},
+		{"", newTestBearer(`{"partner":"cox"}`), []string{"http://cox.com/api"}},
+		{"comcast", newTestBearer(`{"partner":"cox"}`), []string{"http://comcast1.com/api", "http://comcast2.com/api"}},
+	}
+
+	for _, record := range testData {
+		request := httptest.NewRequest("GET", "/api", nil)
+		if len(record.header) > 0 {
+			request.Header.Set("X-Partner", record.header)
+		}
+
+		if len(record.authorization) > 0 {
+			request.Header.Set("Authorization", record.authorization)
+		}
+
+		urls, err := pe.NewEndpoints(request)
+		require.NoError(err)
+
+		actual := make([]string, 0, len(urls))
+		for _, u := range urls {
+			actual = append(actual, u.String())
+		}
+
+		assert.Equal(record.expected, actual)
+	}
+}
+
+func testNewPartnerEndpointsNoDefault(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		pe, err = NewPartnerEndpoints(
+			map[string][]string{"comcast": {"http://comcast.com"}},
+			nil,
+			PartnerIDFromHeader("X-Partner"),
+		)
+	)
+
+	require.NoError(err)
+	require.NotNil(pe)
+
+	urls, err := pe.NewEndpoints(httptest.NewRequest("GET", "/", nil))
+	assert.Empty(urls)
+	require.Error(err)
+
+	httpError, ok := err.(*xhttp.Error)
+	require.True(ok)
+	assert.Equal(http.StatusForbidden, httpError.StatusCode())
+}
+
+func TestNewPartnerEndpoints(t *testing.T) {
+	t.Run("Invalid", testNewPartnerEndpointsInvalid)
+	t.Run("Select", testNewPartnerEndpointsSelect)
+	t.Run("NoDefault", testNewPartnerEndpointsNoDefault)
+}
@@ -3,7 +3,6 @@ package fanout
 import (
 	"context"
 	"net/http"
-	"net/textproto"
 
 	"github.com/Comcast/webpa-common/xhttp"
 )
@@ -36,20 +35,11 @@ func OriginalBody(followRedirects bool) FanoutRequestFunc {
 	}
 }
 
-// OriginalHeaders creates a FanoutRequestFunc that copies headers from the original request onto the fanout request
+// OriginalHeaders creates a FanoutRequestFunc that copies headers from the original request onto the fanout request.
+// Hop-by-hop headers, such as Connection, are never copied.  See xhttp.CopyForwardedHeaders.
 func OriginalHeaders(headers ...string) FanoutRequestFunc {
-	canonicalizedHeaders := make([]string, len(headers))
-	for i := 0; i < len(headers); i++ {
-		canonicalizedHeaders[i] = textproto.CanonicalMIMEHeaderKey(headers[i])
-	}
-
 	return func(ctx context.Context, original, fanout *http.Request, _ []byte) context.Context {
-		for _, key := range canonicalizedHeaders {
-			if values := original.Header[key]; len(values) > 0 {
-				fanout.Header[key] = append(fanout.Header[key], values...)
-			}
-		}
-
+		xhttp.CopyForwardedHeaders(fanout.Header, original.Header, headers...)
 		return ctx
 	}
 }
@@ -58,20 +48,11 @@ func OriginalHeaders(headers ...string) FanoutRequestFunc {
 type FanoutResponseFunc func(ctx context.Context, response http.ResponseWriter, result Result) context.Context
 
 // FanoutHeaders copies zero or more headers from the fanout response into the top-level HTTP response.
+// Hop-by-hop headers, such as Connection, are never copied.  See xhttp.CopyForwardedHeaders.
 func FanoutHeaders(headers ...string) FanoutResponseFunc {
-	canonicalizedHeaders := make([]string, len(headers))
-	for i := 0; i < len(headers); i++ {
-		canonicalizedHeaders[i] = textproto.CanonicalMIMEHeaderKey(headers[i])
-	}
-
 	return func(ctx context.Context, response http.ResponseWriter, result Result) context.Context {
 		if result.Response != nil {
-			header := response.Header()
-			for _, key := range canonicalizedHeaders {
-				if values := result.Response.Header[key]; len(values) > 0 {
-					header[key] = append(header[key], values...)
-				}
-			}
+			xhttp.CopyForwardedHeaders(response.Header(), result.Response.Header, headers...)
 		}
 
 		return ctx
@@ -200,6 +200,11 @@ func TestOriginalHeaders(t *testing.T) {
 			[]string{"X-TEST-3", "x-TEsT-1", "x-TesT-2"},
 			http.Header{"X-Test-1": []string{"foo"}, "X-Test-2": []string{"foo", "bar"}},
 		},
+		{
+			http.Header{"Connection": []string{"keep-alive, X-Test-2"}, "Upgrade": []string{"websocket"}, "X-Test-1": []string{" foo\r\n\tbar "}, "X-Test-2": []string{"foo"}},
+			[]string{"Connection", "Upgrade", "X-Test-1", "X-Test-2"},
+			http.Header{"X-Test-1": []string{"foo bar"}},
+		},
 	}
 
 	for i, record := range testData {
@@ -281,6 +286,11 @@ func TestFanoutHeaders(t *testing.T) {
 			[]string{"X-TEST-3", "x-TEsT-1", "x-TesT-2"},
 			http.Header{"X-Test-1": []string{"foo"}, "X-Test-2": []string{"foo", "bar"}},
 		},
+		{
+			&http.Response{Header: http.Header{"Connection": []string{"close"}, "Keep-Alive": []string{"timeout=5"}, "X-Test-1": []string{"foo"}}},
+			[]string{"Connection", "Keep-Alive", "X-Test-1"},
+			http.Header{"X-Test-1": []string{"foo"}},
+		},
 	}
 
 	for i, record := range testData {
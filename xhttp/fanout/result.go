@@ -1,6 +1,8 @@
 package fanout
 
 import (
+	"bytes"
+	"errors"
 	"net/http"
 
 	"github.com/Comcast/webpa-common/tracing"
@@ -15,10 +17,12 @@ type Result struct {
 	// Request is the HTTP request sent to the fanout endpoint.  This will always be non-nil.
 	Request *http.Request
 
-	// Response is the HTTP response returned by the fanout HTTP transaction.  If set, Err will be nil.
+	// Response is the HTTP response returned by the fanout HTTP transaction.  If set, Err will be nil
+	// unless the response was rejected by a ShouldAcceptFunc.
 	Response *http.Response
 
-	// Err is the error returned by the fanout HTTP transaction.  If set, Response will be nil.
+	// Err is the error returned by the fanout HTTP transaction, or the error returned by a ShouldAcceptFunc
+	// that rejected the response.  In the former case, Response will be nil.
 	Err error
 
 	// Body is the HTTP response entity returned by the fanout HTTP transaction.  This can be nil or empty.
@@ -37,3 +41,28 @@ type ShouldTerminateFunc func(Result) bool
 func DefaultShouldTerminate(result Result) bool {
 	return result.StatusCode < 400
 }
+
+// ShouldAcceptFunc validates a fanout response, along with its body, before the fanout decides whether to terminate.
+// If this function returns an error, the response is treated as a failure, with the error in Result.Err and a
+// Result.StatusCode of http.StatusBadGateway.  This allows semantically invalid responses, such as legacy backends
+// which report errors in the body of a 200 response, to be ignored in favor of the remaining endpoints.
+type ShouldAcceptFunc func(*http.Response, []byte) error
+
+// ErrEmptyJSON is returned by RejectEmptyJSON for responses without meaningful JSON content
+var ErrEmptyJSON = errors.New("The fanout response did not contain any JSON content")
+
+// RejectEmptyJSON is a ShouldAcceptFunc that rejects successful responses whose bodies are empty, or which contain
+// only an empty JSON object, an empty JSON array, or null.  Error responses are always accepted, so that their
+// status codes are preserved.
+func RejectEmptyJSON(response *http.Response, body []byte) error {
+	if response.StatusCode >= 400 {
+		return nil
+	}
+
+	switch string(bytes.Join(bytes.Fields(body), nil)) {
+	case "", "{}", "[]", "null":
+		return ErrEmptyJSON
+	default:
+		return nil
+	}
+}
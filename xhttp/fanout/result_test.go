@@ -2,6 +2,7 @@ package fanout
 
 import (
 	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -36,3 +37,31 @@ func TestDefaultShouldTerminate(t *testing.T) {
 		})
 	}
 }
+
+func TestRejectEmptyJSON(t *testing.T) {
+	testData := []struct {
+		statusCode int
+		body       string
+		expected   error
+	}{
+		{200, "", ErrEmptyJSON},
+		{200, "{}", ErrEmptyJSON},
+		{200, " { \n } ", ErrEmptyJSON},
+		{200, "[]", ErrEmptyJSON},
+		{200, "null", ErrEmptyJSON},
+		{201, `{"id": "1234"}`, nil},
+		{200, `[1, 2, 3]`, nil},
+		{404, "", nil},
+		{500, "{}", nil},
+	}
+
+	for _, record := range testData {
+		t.Run(fmt.Sprintf("StatusCode=%d,Body=%q", record.statusCode, record.body), func(t *testing.T) {
+			assert.Equal(
+				t,
+				record.expected,
+				RejectEmptyJSON(&http.Response{StatusCode: record.statusCode}, []byte(record.body)),
+			)
+		})
+	}
+}
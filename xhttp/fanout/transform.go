@@ -0,0 +1,259 @@
+package fanout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/Comcast/webpa-common/xhttp"
+)
+
+// ResponseTransformer modifies the terminating fanout response before it is written to the client.  The header
+// is the client's response header, which already holds the fanout response's Content-Type and any headers set by
+// after functions.  The body is the fanout response's entity, or the output of the previous transformer.
+//
+// A transformer returns the reader the next transformer reads from.  Transformers that wrap the body rather than
+// reading it up front, such as Envelope and ReplaceBody, are streaming transformers:  they work on any io.Reader
+// and add no copies of the entity of their own.  Note, however, that the Handler reads each fanout response in
+// full, and reads the output of the last transformer in full before writing anything to the client.  This ensures
+// that an error from any transformer, whether returned up front or while its output is read, is sent to the client
+// via the Handler's error encoder instead of a partial response.
+type ResponseTransformer func(ctx context.Context, header http.Header, body io.Reader) (io.Reader, error)
+
+// WithResponseTransformers adds zero or more transformers applied, in order, to the terminating fanout response.
+// Since transformers may change the length of the entity, the client's Content-Length header is set to the length
+// of the transformed entity when any transformers are configured.
+func WithResponseTransformers(transformers ...ResponseTransformer) Option {
+	return func(h *Handler) {
+		h.transformers = append(h.transformers, transformers...)
+	}
+}
+
+// transform applies the configured transformers to a terminating fanout response body, returning the
+// entire transformed body
+func (h *Handler) transform(ctx context.Context, header http.Header, body []byte) ([]byte, error) {
+	if len(h.transformers) == 0 {
+		return body, nil
+	}
+
+	var (
+		r   io.Reader = bytes.NewReader(body)
+		err error
+	)
+
+	for _, t := range h.transformers {
+		if r, err = t(ctx, header, r); err != nil {
+			return nil, err
+		}
+	}
+
+	if body, err = ioutil.ReadAll(r); err != nil {
+		return nil, err
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return body, nil
+}
+
+// StripHeaders creates a ResponseTransformer that removes the given headers from the client's response, e.g. internal
+// headers copied from the fanout response with FanoutHeaders.  The body is passed through unchanged.
+func StripHeaders(names ...string) ResponseTransformer {
+	return func(_ context.Context, header http.Header, body io.Reader) (io.Reader, error) {
+		for _, name := range names {
+			header.Del(name)
+		}
+
+		return body, nil
+	}
+}
+
+// Envelope creates a streaming ResponseTransformer that surrounds the body with a prefix and suffix.  For example,
+// Envelope([]byte(`{"data":`), []byte(`}`)) wraps a JSON entity in an object.
+func Envelope(prefix, suffix []byte) ResponseTransformer {
+	return func(_ context.Context, _ http.Header, body io.Reader) (io.Reader, error) {
+		return io.MultiReader(bytes.NewReader(prefix), body, bytes.NewReader(suffix)), nil
+	}
+}
+
+// ReplaceBody creates a streaming ResponseTransformer that replaces every occurrence of old with new in the body,
+// e.g. to rewrite backend URLs to their public equivalents.  Only len(old)-1 bytes are held back at any time
+// to match occurrences which span reads.  If old is empty, the body is passed through unchanged.
+func ReplaceBody(old, new []byte) ResponseTransformer {
+	return func(_ context.Context, _ http.Header, body io.Reader) (io.Reader, error) {
+		if len(old) == 0 {
+			return body, nil
+		}
+
+		return &replacingReader{
+			source: body,
+			old:    old,
+			new:    new,
+			chunk:  make([]byte, 4096),
+		}, nil
+	}
+}
+
+// replacingReader is an io.Reader that replaces each occurrence of a byte sequence in its source
+type replacingReader struct {
+	source io.Reader
+	old    []byte
+	new    []byte
+	chunk  []byte
+
+	// pending is input that has been read from the source but not yet replaced
+	pending []byte
+
+	// ready is replaced output waiting to be read
+	ready []byte
+
+	// err is the error returned by the source, which is returned once all output has been read
+	err error
+}
+
+// replace moves as much of the pending input as possible into the ready output.  If final is false, a suffix
+// of the pending input that might begin an occurrence of old is held back.
+func (rr *replacingReader) replace(final bool) {
+	for {
+		i := bytes.Index(rr.pending, rr.old)
+		if i < 0 {
+			break
+		}
+
+		rr.ready = append(rr.ready, rr.pending[:i]...)
+		rr.ready = append(rr.ready, rr.new...)
+		rr.pending = rr.pending[i+len(rr.old):]
+	}
+
+	keep := len(rr.old) - 1
+	if final {
+		keep = 0
+	}
+
+	if len(rr.pending) > keep {
+		rr.ready = append(rr.ready, rr.pending[:len(rr.pending)-keep]...)
+		rr.pending = append(rr.pending[:0], rr.pending[len(rr.pending)-keep:]...)
+	}
+}
+
+func (rr *replacingReader) Read(p []byte) (int, error) {
+	for len(rr.ready) == 0 {
+		if rr.err != nil {
+			return 0, rr.err
+		}
+
+		n, err := rr.source.Read(rr.chunk)
+		rr.pending = append(rr.pending, rr.chunk[:n]...)
+		rr.err = err
+		rr.replace(err != nil)
+	}
+
+	n := copy(p, rr.ready)
+	rr.ready = rr.ready[n:]
+	return n, nil
+}
+
+// StripJSONFields creates a ResponseTransformer that removes the given top-level fields from a JSON object body,
+// e.g. to hide internal fields from clients.  Bodies whose Content-Type is not JSON, and JSON bodies which
+// are not objects, are passed through unchanged.  The remaining fields are copied exactly as they appear in
+// the body, so their order and encoding are preserved.
+//
+// This transformer is not a streaming transformer, as it reads the entire body in order to parse it.  A JSON body
+// that cannot be parsed results in an xhttp.Error with http.StatusBadGateway.
+func StripJSONFields(fields ...string) ResponseTransformer {
+	strip := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		strip[field] = true
+	}
+
+	return func(_ context.Context, header http.Header, body io.Reader) (io.Reader, error) {
+		if mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+			return body, nil
+		}
+
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+
+		if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+			return bytes.NewReader(data), nil
+		}
+
+		stripped, err := stripJSONFields(data, strip)
+		if err != nil {
+			return nil, &xhttp.Error{Code: http.StatusBadGateway, Text: "Invalid JSON fanout response: " + err.Error()}
+		}
+
+		return bytes.NewReader(stripped), nil
+	}
+}
+
+// stripJSONFields removes the members with the given names from a JSON object.  The remaining members are copied
+// verbatim from the original, rather than decoded and reencoded.  If no members are removed, data is returned as is.
+func stripJSONFields(data []byte, strip map[string]bool) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	var (
+		output  = append(make([]byte, 0, len(data)), '{')
+		kept    int
+		removed bool
+
+		// start is the offset of the first byte after the previous member, or after the opening brace
+		start = decoder.InputOffset()
+	)
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		end := decoder.InputOffset()
+		if name, _ := key.(string); strip[name] {
+			removed = true
+		} else {
+			if kept > 0 {
+				output = append(output, ',')
+			}
+
+			// the member's bytes are preceded by the separating comma, if any, and whitespace
+			output = append(output, bytes.TrimLeft(data[start:end], " \t\r\n,")...)
+			kept++
+		}
+
+		start = end
+	}
+
+	// consume the closing brace, and ensure that nothing but whitespace follows it
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("unexpected data after the top-level object")
+		}
+
+		return nil, err
+	}
+
+	if !removed {
+		return data, nil
+	}
+
+	return append(output, '}'), nil
+}
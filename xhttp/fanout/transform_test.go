@@ -0,0 +1,354 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/Comcast/webpa-common/xhttp/xhttptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transformString applies a ResponseTransformer to a string body, returning the transformed body
+func transformString(t *testing.T, rt ResponseTransformer, header http.Header, body io.Reader) (string, error) {
+	r, err := rt(context.Background(), header, body)
+	if err != nil {
+		return "", err
+	}
+
+	require.NotNil(t, r)
+	data, err := ioutil.ReadAll(r)
+	return string(data), err
+}
+
+func TestStripHeaders(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		header  = http.Header{
+			"X-Internal": []string{"secret"},
+			"X-Other":    []string{"other"},
+			"X-Public":   []string{"public"},
+		}
+	)
+
+	body, err := transformString(t, StripHeaders("X-Internal", "X-Other", "X-Missing"), header, strings.NewReader("body"))
+	require.NoError(err)
+	assert.Equal("body", body)
+	assert.Equal(http.Header{"X-Public": []string{"public"}}, header)
+}
+
+func TestEnvelope(t *testing.T) {
+	assert := assert.New(t)
+
+	body, err := transformString(t, Envelope([]byte(`{"data":`), []byte(`}`)), http.Header{}, strings.NewReader(`[1,2,3]`))
+	assert.Equal(`{"data":[1,2,3]}`, body)
+	assert.NoError(err)
+
+	body, err = transformString(t, Envelope(nil, nil), http.Header{}, strings.NewReader("unchanged"))
+	assert.Equal("unchanged", body)
+	assert.NoError(err)
+}
+
+func TestReplaceBody(t *testing.T) {
+	testData := []struct {
+		old, new string
+		body     string
+		expected string
+	}{
+		{"", "ignored", "unchanged", "unchanged"},
+		{"http://backend:8080", "https://api.webpa.net", "", ""},
+		{"http://backend:8080", "https://api.webpa.net", "no matches", "no matches"},
+		{
+			"http://backend:8080",
+			"https://api.webpa.net",
+			`{"self": "http://backend:8080/devices/1", "next": "http://backend:8080/devices/2"}`,
+			`{"self": "https://api.webpa.net/devices/1", "next": "https://api.webpa.net/devices/2"}`,
+		},
+		{"aa", "b", "aaaaa", "bba"},
+		{"ab", "", "aabab", "a"},
+		{"x", "xx", "xyx", "xxyxx"},
+		{"abc", "X", "ababc", "abX"},
+		{"abc", "X", "abcab", "Xab"},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			rt := ReplaceBody([]byte(record.old), []byte(record.new))
+
+			t.Run("Whole", func(t *testing.T) {
+				assert := assert.New(t)
+				body, err := transformString(t, rt, http.Header{}, strings.NewReader(record.body))
+				assert.Equal(record.expected, body)
+				assert.NoError(err)
+			})
+
+			t.Run("OneByte", func(t *testing.T) {
+				assert := assert.New(t)
+				body, err := transformString(t, rt, http.Header{}, iotest.OneByteReader(strings.NewReader(record.body)))
+				assert.Equal(record.expected, body)
+				assert.NoError(err)
+			})
+
+			t.Run("DataErr", func(t *testing.T) {
+				assert := assert.New(t)
+				body, err := transformString(t, rt, http.Header{}, iotest.DataErrReader(strings.NewReader(record.body)))
+				assert.Equal(record.expected, body)
+				assert.NoError(err)
+			})
+		})
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			expectedError = errors.New("expected")
+		)
+
+		body, err := transformString(
+			t,
+			ReplaceBody([]byte("old"), []byte("new")),
+			http.Header{},
+			io.MultiReader(strings.NewReader("an old value"), iotest.ErrReader(expectedError)),
+		)
+
+		assert.Equal("an new value", body)
+		assert.Equal(expectedError, err)
+	})
+}
+
+func TestStripJSONFields(t *testing.T) {
+	var (
+		rt     = StripJSONFields("internal", "missing")
+		object = `{"internal": {"host": "backend"}, "public": 1, "list": [1, 2]}`
+	)
+
+	t.Run("Object", func(t *testing.T) {
+		assert := assert.New(t)
+		body, err := transformString(t, rt, http.Header{"Content-Type": []string{"application/json; charset=utf-8"}}, strings.NewReader(object))
+		assert.JSONEq(`{"public": 1, "list": [1, 2]}`, body)
+		assert.NoError(err)
+	})
+
+	t.Run("Verbatim", func(t *testing.T) {
+		testData := []struct {
+			body     string
+			expected string
+		}{
+			{`{"z": "<a&b>", "internal": 1, "a": "\u00e9", "m": {"internal": 2}}`, `{"z": "<a&b>","a": "\u00e9","m": {"internal": 2}}`},
+			{`{"internal": 1, "b": 2.50}`, `{"b": 2.50}`},
+			{`{"b": 2.50, "internal": 1}`, `{"b": 2.50}`},
+			{` { "internal" : [ 1 ] } `, `{}`},
+			{`{"internal": 1, "internal": 2, "b": true}`, `{"b": true}`},
+
+			// without anything to strip, the body is unchanged
+			{` {"b": "<a&b>" , "c": null} `, ` {"b": "<a&b>" , "c": null} `},
+		}
+
+		for _, record := range testData {
+			assert := assert.New(t)
+			body, err := transformString(t, rt, http.Header{"Content-Type": []string{"application/json"}}, strings.NewReader(record.body))
+			assert.Equal(record.expected, body)
+			assert.NoError(err)
+		}
+	})
+
+	t.Run("NotJSON", func(t *testing.T) {
+		for _, contentType := range []string{"", "text/plain", "application/msgpack", "invalid;;"} {
+			assert := assert.New(t)
+			body, err := transformString(t, rt, http.Header{"Content-Type": []string{contentType}}, strings.NewReader(object))
+			assert.Equal(object, body)
+			assert.NoError(err)
+		}
+	})
+
+	t.Run("NotObject", func(t *testing.T) {
+		for _, value := range []string{"", "  ", `[{"internal": 1}]`, `"internal"`, "123"} {
+			assert := assert.New(t)
+			body, err := transformString(t, rt, http.Header{"Content-Type": []string{"application/json"}}, strings.NewReader(value))
+			assert.Equal(value, body)
+			assert.NoError(err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		for _, invalid := range []string{`{"internal": `, `{"internal": 1} {}`, `{"internal": 1]`, `{1: 2}`} {
+			body, err := transformString(t, rt, http.Header{"Content-Type": []string{"application/json"}}, strings.NewReader(invalid))
+			assert.Empty(body)
+			require.Error(err)
+
+			httpErr, ok := err.(*xhttp.Error)
+			require.True(ok, invalid)
+			assert.Equal(http.StatusBadGateway, httpErr.StatusCode())
+		}
+	})
+
+	t.Run("ReadError", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			expectedError = errors.New("expected")
+		)
+
+		body, err := transformString(t, rt, http.Header{"Content-Type": []string{"application/json"}}, iotest.ErrReader(expectedError))
+		assert.Empty(body)
+		assert.Equal(expectedError, err)
+	})
+}
+
+func testHandlerTransform(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ctx      = logging.WithLogger(context.Background(), logging.NewTestLogger(nil, t))
+		original = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response = httptest.NewRecorder()
+
+		endpoints  = generateEndpoints(1)
+		transactor = new(xhttptest.MockTransactor)
+		handler    = New(
+			endpoints,
+			WithTransactor(transactor.Do),
+			WithFanoutAfter(FanoutHeaders("X-Internal", "X-Public", "Content-Length")),
+			WithResponseTransformers(
+				StripHeaders("X-Internal"),
+				StripJSONFields("internal"),
+				ReplaceBody([]byte("http://backend"), []byte("https://public")),
+				Envelope([]byte(`{"data":`), []byte(`}`)),
+			),
+		)
+	)
+
+	require.NotNil(handler)
+	transactor.OnDo(xhttptest.MatchMethod("GET")).RespondWith(xhttptest.ExpectedResponse{
+		StatusCode: 201,
+		Header: http.Header{
+			"Content-Type":   []string{"application/json"},
+			"Content-Length": []string{"74"},
+			"X-Internal":     []string{"secret"},
+			"X-Public":       []string{"public"},
+		},
+		Body: []byte(`{"internal": "secret", "self": "http://backend/devices/mac:112233445566"}`),
+	}).Once()
+
+	handler.ServeHTTP(response, original)
+	assert.Equal(201, response.Code)
+	assert.Equal("application/json", response.HeaderMap.Get("Content-Type"))
+	assert.Equal(strconv.Itoa(response.Body.Len()), response.HeaderMap.Get("Content-Length"))
+	assert.Empty(response.HeaderMap.Get("X-Internal"))
+	assert.Equal("public", response.HeaderMap.Get("X-Public"))
+	assert.JSONEq(`{"data": {"self": "https://public/devices/mac:112233445566"}}`, response.Body.String())
+
+	transactor.AssertExpectations(t)
+}
+
+func testHandlerTransformError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ctx      = logging.WithLogger(context.Background(), logging.NewTestLogger(nil, t))
+		original = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response = httptest.NewRecorder()
+
+		endpoints  = generateEndpoints(1)
+		transactor = new(xhttptest.MockTransactor)
+		handler    = New(
+			endpoints,
+			WithTransactor(transactor.Do),
+			WithResponseTransformers(
+				func(context.Context, http.Header, io.Reader) (io.Reader, error) {
+					return nil, &xhttp.Error{Code: 599, Text: "expected"}
+				},
+				func(context.Context, http.Header, io.Reader) (io.Reader, error) {
+					assert.Fail("Transformers after a failed transformer should not be called")
+					return nil, nil
+				},
+			),
+		)
+	)
+
+	require.NotNil(handler)
+	transactor.OnDo(xhttptest.MatchMethod("GET")).RespondWith(xhttptest.ExpectedResponse{
+		StatusCode: 200,
+		Body:       []byte("untransformed"),
+	}).Once()
+
+	handler.ServeHTTP(response, original)
+	assert.Equal(599, response.Code)
+	assert.NotContains(response.Body.String(), "untransformed")
+
+	transactor.AssertExpectations(t)
+}
+
+func testHandlerTransformReadError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ctx      = logging.WithLogger(context.Background(), logging.NewTestLogger(nil, t))
+		original = httptest.NewRequest("GET", "/api/v2/something", nil).WithContext(ctx)
+		response = httptest.NewRecorder()
+
+		endpoints  = generateEndpoints(1)
+		transactor = new(xhttptest.MockTransactor)
+		handler    = New(
+			endpoints,
+			WithTransactor(transactor.Do),
+			WithFanoutAfter(FanoutHeaders("X-Public", "Content-Length")),
+			WithErrorEncoder(func(_ context.Context, err error, response http.ResponseWriter) {
+				response.WriteHeader(599)
+				response.Write([]byte(err.Error()))
+			}),
+			WithResponseTransformers(
+				// a streaming transformer which fails partway through the body
+				func(_ context.Context, _ http.Header, body io.Reader) (io.Reader, error) {
+					return io.MultiReader(io.LimitReader(body, 5), iotest.ErrReader(errors.New("expected"))), nil
+				},
+			),
+		)
+	)
+
+	require.NotNil(handler)
+	transactor.OnDo(xhttptest.MatchMethod("GET")).RespondWith(xhttptest.ExpectedResponse{
+		StatusCode: 200,
+		Header: http.Header{
+			"Content-Type":   []string{"text/plain"},
+			"Content-Length": []string{"13"},
+			"X-Public":       []string{"public"},
+		},
+		Body: []byte("untransformed"),
+	}).Once()
+
+	handler.ServeHTTP(response, original)
+	assert.Equal(599, response.Code)
+	assert.Equal("expected", response.Body.String())
+
+	// headers from after functions are kept, but not those describing the fanout entity
+	assert.Equal("public", response.HeaderMap.Get("X-Public"))
+	assert.Empty(response.HeaderMap.Get("Content-Type"))
+	assert.Empty(response.HeaderMap.Get("Content-Length"))
+
+	transactor.AssertExpectations(t)
+}
+
+func TestHandlerTransform(t *testing.T) {
+	t.Run("Success", testHandlerTransform)
+	t.Run("Error", testHandlerTransformError)
+	t.Run("ReadError", testHandlerTransformReadError)
+}
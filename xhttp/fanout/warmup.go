@@ -0,0 +1,318 @@
+package fanout
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// DefaultWarmupMethod is the HTTP method of warm-up requests when none is configured
+	DefaultWarmupMethod = http.MethodHead
+
+	// DefaultWarmupPath is the URL path of warm-up requests when none is configured
+	DefaultWarmupPath = "/"
+
+	// DefaultWarmupPeriod is how often idle connections are kept alive when no period is configured.  This is
+	// well under the 90 second idle timeout of http.DefaultTransport.
+	DefaultWarmupPeriod = 30 * time.Second
+
+	// DefaultWarmupTimeout is the timeout for each warm-up request when none is configured
+	DefaultWarmupTimeout = 5 * time.Second
+
+	// warmupDrainLimit is the most of a warm-up response body that is read.  A connection whose response body is
+	// larger is closed rather than returned to the pool, which bounds the work done for misbehaving endpoints.
+	warmupDrainLimit = 64 * 1024
+)
+
+// WarmupOptions configures a Warmer
+type WarmupOptions struct {
+	// Logger is the go-kit logger for warm-up activity.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger
+
+	// Transactor sends warm-up requests.  This must share its connection pool with the transactor used for
+	// fanouts, or the warmed connections will never be used.  If unset, http.DefaultClient.Do is used, which
+	// matches the default fanout transactor.
+	Transactor func(*http.Request) (*http.Response, error)
+
+	// Method is the HTTP method of warm-up requests.  If unset, DefaultWarmupMethod is used.
+	Method string
+
+	// Path is the URL path of warm-up requests.  If unset, DefaultWarmupPath is used.
+	Path string
+
+	// Period is how often every endpoint is sent another warm-up request, which keeps its connection from
+	// being closed as idle.  If nonpositive, DefaultWarmupPeriod is used.
+	Period time.Duration
+
+	// Timeout bounds each warm-up request.  If nonpositive, DefaultWarmupTimeout is used.
+	Timeout time.Duration
+}
+
+// Warmer pre-connects to fanout endpoints, so that the first fanout after an endpoint set change does not pay
+// for TCP and TLS handshakes.  A connection is established by sending a lightweight request to each endpoint
+// through the fanout's transactor, after which the connection remains idle in the transactor's pool.  Any response,
+// regardless of status, warms the connection.
+//
+// With http.DefaultTransport, connections to https endpoints negotiate HTTP/2 where the endpoint supports it, so that
+// a single warmed connection serves all concurrent fanout requests to that endpoint.
+type Warmer struct {
+	logger     log.Logger
+	transactor func(*http.Request) (*http.Response, error)
+	method     string
+	path       string
+	timeout    time.Duration
+	scheduler  *concurrent.Scheduler
+
+	// ctx is cancelled when the Warmer is shut down, which aborts any warm-up requests in flight
+	ctx     context.Context
+	cancel  func()
+	warming sync.WaitGroup
+	runOnce sync.Once
+
+	lock      sync.Mutex
+	endpoints map[string]*url.URL
+}
+
+// NewWarmer creates a Warmer with no endpoints.  Endpoints are supplied with Update or UpdateInstances, or by
+// decorating an Endpoints strategy with Endpoints.
+func NewWarmer(o WarmupOptions) *Warmer {
+	w := &Warmer{
+		logger:     o.Logger,
+		transactor: o.Transactor,
+		method:     o.Method,
+		path:       o.Path,
+		timeout:    o.Timeout,
+		endpoints:  make(map[string]*url.URL),
+	}
+
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+
+	if w.logger == nil {
+		w.logger = logging.DefaultLogger()
+	}
+
+	if w.transactor == nil {
+		w.transactor = http.DefaultClient.Do
+	}
+
+	if len(w.method) == 0 {
+		w.method = DefaultWarmupMethod
+	}
+
+	if len(w.path) == 0 {
+		w.path = DefaultWarmupPath
+	}
+
+	if w.timeout < 1 {
+		w.timeout = DefaultWarmupTimeout
+	}
+
+	period := o.Period
+	if period < 1 {
+		period = DefaultWarmupPeriod
+	}
+
+	w.scheduler = concurrent.NewScheduler(
+		concurrent.ScheduleOptions{
+			Name:   "fanout.warmup",
+			Logger: w.logger,
+			Period: period,
+		},
+		w.warmAll,
+	)
+
+	return w
+}
+
+// baseURL reduces an endpoint to the scheme and host that identify its connections
+func baseURL(u *url.URL) *url.URL {
+	return &url.URL{Scheme: u.Scheme, Host: u.Host}
+}
+
+// diff compares a set of endpoints to the endpoints currently kept warm, returning the distinct set of base URLs
+// along with the base URLs that are not currently kept warm.  The returned flag indicates whether the sets differ.
+// This method must be called while holding the lock.
+func (w *Warmer) diff(endpoints []*url.URL) (current map[string]*url.URL, added []*url.URL, changed bool) {
+	current = make(map[string]*url.URL, len(endpoints))
+	for _, e := range endpoints {
+		base := baseURL(e)
+		key := base.String()
+		if _, ok := current[key]; ok {
+			continue
+		}
+
+		current[key] = base
+		if _, ok := w.endpoints[key]; !ok {
+			added = append(added, base)
+		}
+	}
+
+	// every current endpoint that was already known accounts for one existing endpoint, so the sets are
+	// the same exactly when nothing was added and nothing was removed
+	changed = len(added) > 0 || len(current)-len(added) != len(w.endpoints)
+	return
+}
+
+// Update replaces the set of endpoints to keep warm.  Endpoints not previously known are warmed immediately, in the
+// background.  Only the scheme and host of each URL are significant.  Once the Warmer has been shut down, new
+// endpoints are no longer warmed.
+func (w *Warmer) Update(endpoints []*url.URL) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	current, added, changed := w.diff(endpoints)
+	if !changed {
+		return
+	}
+
+	w.endpoints = current
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	w.warming.Add(len(added))
+	for _, base := range added {
+		go func(base *url.URL) {
+			defer w.warming.Done()
+			w.warm(base)
+		}(base)
+	}
+}
+
+// UpdateInstances is like Update, but accepts the instance strings produced by service discovery, e.g.
+// "https://host:8080".  This method can be used directly as a service discovery listener.  Instances that
+// are not valid URLs are logged and skipped.
+func (w *Warmer) UpdateInstances(instances []string) {
+	endpoints := make([]*url.URL, 0, len(instances))
+	for _, i := range instances {
+		u, err := url.Parse(i)
+		if err != nil || len(u.Host) == 0 {
+			w.logger.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping invalid warm-up instance", "instance", i, logging.ErrorKey(), err)
+			continue
+		}
+
+		endpoints = append(endpoints, u)
+	}
+
+	w.Update(endpoints)
+}
+
+// Current returns the endpoints currently kept warm, in sorted order
+func (w *Warmer) Current() []string {
+	w.lock.Lock()
+	current := make([]string, 0, len(w.endpoints))
+	for key := range w.endpoints {
+		current = append(current, key)
+	}
+
+	w.lock.Unlock()
+	sort.Strings(current)
+	return current
+}
+
+// Endpoints decorates an Endpoints strategy so that each set of endpoints it produces is kept warm.  This is
+// useful for strategies whose endpoints change over time, such as DNSEndpoints.
+func (w *Warmer) Endpoints(next Endpoints) Endpoints {
+	return EndpointsFunc(func(original *http.Request) ([]*url.URL, error) {
+		endpoints, err := next.NewEndpoints(original)
+		if err == nil {
+			w.Update(endpoints)
+		}
+
+		return endpoints, err
+	})
+}
+
+// Run starts the goroutine that periodically keeps every endpoint's connection alive.  When shutdown is closed,
+// any warm-up requests in flight are cancelled, and the waitGroup is not released until all of them have returned.
+// This method is idempotent.
+func (w *Warmer) Run(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
+	if err := w.scheduler.Run(waitGroup, shutdown); err != nil {
+		return err
+	}
+
+	w.runOnce.Do(func() {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			<-shutdown
+
+			w.lock.Lock()
+			w.cancel()
+			w.lock.Unlock()
+
+			w.warming.Wait()
+		}()
+	})
+
+	return nil
+}
+
+// warmAll sends a warm-up request to each current endpoint, concurrently
+func (w *Warmer) warmAll() {
+	w.lock.Lock()
+	endpoints := make([]*url.URL, 0, len(w.endpoints))
+	for _, e := range w.endpoints {
+		endpoints = append(endpoints, e)
+	}
+
+	w.lock.Unlock()
+
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(len(endpoints))
+	for _, e := range endpoints {
+		go func(e *url.URL) {
+			defer waitGroup.Done()
+			w.warm(e)
+		}(e)
+	}
+
+	waitGroup.Wait()
+}
+
+// warm sends a single warm-up request to an endpoint, consuming the response so that its connection
+// returns to the transactor's pool
+func (w *Warmer) warm(base *url.URL) {
+	ctx, cancel := context.WithTimeout(w.ctx, w.timeout)
+	defer cancel()
+
+	target := *base
+	target.Path = w.path
+	request, err := http.NewRequest(w.method, target.String(), nil)
+	if err != nil {
+		w.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to create warm-up request", "endpoint", base.String(), logging.ErrorKey(), err)
+		return
+	}
+
+	response, err := w.transactor(request.WithContext(ctx))
+	if err != nil {
+		w.logger.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "warm-up request failed", "endpoint", base.String(), logging.ErrorKey(), err)
+		return
+	}
+
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, warmupDrainLimit))
+	response.Body.Close()
+	w.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "warmed endpoint", "endpoint", base.String(), "protocol", response.Proto)
+}
+
+// WithWarmer keeps the connections to a Handler's endpoints warm by decorating its Endpoints strategy.  The Warmer
+// should use a transactor that shares its connection pool with the Handler's transactor.  If w is nil, this
+// option does nothing.
+func WithWarmer(w *Warmer) Option {
+	return func(h *Handler) {
+		if w != nil {
+			h.endpoints = w.Endpoints(h.endpoints)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package fanout
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// warmupServer is an httptest server that counts warm-up requests and new connections
+type warmupServer struct {
+	*httptest.Server
+	warmups     int32
+	connections int32
+}
+
+func newWarmupServer() *warmupServer {
+	ws := new(warmupServer)
+	ws.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodHead && request.URL.Path == "/" {
+			atomic.AddInt32(&ws.warmups, 1)
+		}
+
+		response.WriteHeader(http.StatusNoContent)
+	}))
+
+	ws.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&ws.connections, 1)
+		}
+	}
+
+	ws.Start()
+	return ws
+}
+
+func (ws *warmupServer) Warmups() int {
+	return int(atomic.LoadInt32(&ws.warmups))
+}
+
+func (ws *warmupServer) Connections() int {
+	return int(atomic.LoadInt32(&ws.connections))
+}
+
+// waitForWarmups polls until the server has received at least the expected number of warm-up requests
+func waitForWarmups(t *testing.T, ws *warmupServer, expected int) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if ws.Warmups() >= expected {
+			return
+		}
+	}
+
+	require.Fail(t, "Warm-up requests not received", "expected: %d, actual: %d", expected, ws.Warmups())
+}
+
+func testNewWarmerDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w      = NewWarmer(WarmupOptions{})
+	)
+
+	assert.NotNil(w.logger)
+	assert.NotNil(w.transactor)
+	assert.Equal(DefaultWarmupMethod, w.method)
+	assert.Equal(DefaultWarmupPath, w.path)
+	assert.Equal(DefaultWarmupTimeout, w.timeout)
+	assert.NotNil(w.scheduler)
+	assert.Empty(w.Current())
+}
+
+func testWarmerUpdate(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		first  = newWarmupServer()
+		second = newWarmupServer()
+		w      = NewWarmer(WarmupOptions{})
+	)
+
+	defer first.Close()
+	defer second.Close()
+
+	firstURL, _ := url.Parse(first.URL + "/api/v2/device")
+	w.Update([]*url.URL{firstURL, firstURL})
+	w.warming.Wait()
+	assert.Equal(1, first.Warmups())
+	assert.Equal([]string{first.URL}, w.Current())
+
+	// only endpoints that are new are warmed, regardless of their order
+	w.UpdateInstances([]string{second.URL, "%%invalid", first.URL, "nohost"})
+	w.warming.Wait()
+	assert.Equal(1, first.Warmups())
+	assert.Equal(1, second.Warmups())
+	assert.ElementsMatch([]string{first.URL, second.URL}, w.Current())
+
+	// the same set in a different order is not a change
+	w.UpdateInstances([]string{first.URL, second.URL})
+	w.warming.Wait()
+	assert.Equal(1, first.Warmups())
+	assert.Equal(1, second.Warmups())
+
+	w.UpdateInstances([]string{second.URL})
+	w.warming.Wait()
+	assert.Equal([]string{second.URL}, w.Current())
+	assert.Equal(1, first.Warmups())
+	assert.Equal(1, second.Warmups())
+
+	// an endpoint that was removed is warmed again when it returns
+	w.UpdateInstances([]string{first.URL, second.URL})
+	w.warming.Wait()
+	assert.Equal(2, first.Warmups())
+	assert.Equal(1, second.Warmups())
+}
+
+func testWarmerDiff(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w      = NewWarmer(WarmupOptions{})
+
+		first, _  = url.Parse("http://first:8080/path")
+		second, _ = url.Parse("http://second:8080")
+		third, _  = url.Parse("http://third:8080")
+	)
+
+	w.endpoints = map[string]*url.URL{
+		"http://first:8080":  baseURL(first),
+		"http://second:8080": baseURL(second),
+	}
+
+	current, added, changed := w.diff([]*url.URL{second, first, second})
+	assert.Len(current, 2)
+	assert.Empty(added)
+	assert.False(changed)
+
+	current, added, changed = w.diff([]*url.URL{first})
+	assert.Len(current, 1)
+	assert.Empty(added)
+	assert.True(changed)
+
+	current, added, changed = w.diff([]*url.URL{first, third})
+	assert.Len(current, 2)
+	assert.Equal([]*url.URL{baseURL(third)}, added)
+	assert.True(changed)
+}
+
+func testWarmerFailure(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		attempted = make(chan *http.Request, 1)
+
+		w = NewWarmer(WarmupOptions{
+			Method: http.MethodOptions,
+			Path:   "/health",
+			Transactor: func(request *http.Request) (*http.Response, error) {
+				attempted <- request
+				return nil, errors.New("expected")
+			},
+		})
+	)
+
+	w.UpdateInstances([]string{"http://unreachable:8080"})
+	select {
+	case request := <-attempted:
+		assert.Equal(http.MethodOptions, request.Method)
+		assert.Equal("http://unreachable:8080/health", request.URL.String())
+		_, ok := request.Context().Deadline()
+		assert.True(ok)
+
+	case <-time.After(5 * time.Second):
+		assert.Fail("No warm-up request was attempted")
+	}
+
+	assert.Equal([]string{"http://unreachable:8080"}, w.Current())
+}
+
+func testWarmerRun(t *testing.T) {
+	var (
+		require = require.New(t)
+		server  = newWarmupServer()
+		w       = NewWarmer(WarmupOptions{Period: 20 * time.Millisecond})
+
+		waitGroup sync.WaitGroup
+		shutdown  = make(chan struct{})
+	)
+
+	defer server.Close()
+
+	w.UpdateInstances([]string{server.URL})
+	require.NoError(w.Run(&waitGroup, shutdown))
+	require.NoError(w.Run(&waitGroup, shutdown))
+
+	// the initial warm-up plus periodic keep-alives
+	waitForWarmups(t, server, 3)
+	close(shutdown)
+	waitGroup.Wait()
+}
+
+func testWarmerShutdown(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		attempted = make(chan struct{}, 1)
+
+		w = NewWarmer(WarmupOptions{
+			Period:  time.Hour,
+			Timeout: time.Hour,
+			Transactor: func(request *http.Request) (*http.Response, error) {
+				attempted <- struct{}{}
+				<-request.Context().Done()
+				return nil, request.Context().Err()
+			},
+		})
+
+		waitGroup sync.WaitGroup
+		shutdown  = make(chan struct{})
+	)
+
+	require.NoError(w.Run(&waitGroup, shutdown))
+	w.UpdateInstances([]string{"http://blocked:8080"})
+	<-attempted
+
+	// shutting down cancels the blocked warm-up request, and the wait group tracks it
+	close(shutdown)
+	waitGroup.Wait()
+
+	// endpoints are still tracked after shutdown, but no longer warmed
+	w.UpdateInstances([]string{"http://another:8080"})
+	w.warming.Wait()
+	assert.Equal([]string{"http://another:8080"}, w.Current())
+	assert.Empty(attempted)
+}
+
+func testWarmerDrain(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		body   = new(countingReader)
+
+		w = NewWarmer(WarmupOptions{
+			Transactor: func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(body)}, nil
+			},
+		})
+	)
+
+	w.UpdateInstances([]string{"http://endless:8080"})
+	w.warming.Wait()
+	assert.Equal(int64(warmupDrainLimit), body.read)
+}
+
+// countingReader is an endless response body that counts the bytes read from it
+type countingReader struct {
+	read int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	cr.read += int64(len(p))
+	return len(p), nil
+}
+
+func testWarmerHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		server  = newWarmupServer()
+
+		transport = &http.Transport{}
+		client    = &http.Client{Transport: transport}
+		w         = NewWarmer(WarmupOptions{Transactor: client.Do})
+		handler   = New(
+			MustNewFixedEndpoints(server.URL),
+			WithTransactor(client.Do),
+			WithWarmer(w),
+			WithWarmer(nil),
+		)
+	)
+
+	defer server.Close()
+	defer transport.CloseIdleConnections()
+
+	// the first fanout warms the endpoint, so this isn't a latency test; it verifies the pooled connection is reused
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/api/v2/device", nil))
+	assert.Equal(http.StatusNoContent, response.Code)
+	waitForWarmups(t, server, 1)
+	assert.Equal([]string{server.URL}, w.Current())
+
+	for i := 0; i < 3; i++ {
+		response = httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/api/v2/device", nil))
+		require.Equal(http.StatusNoContent, response.Code)
+	}
+
+	// the endpoint set did not change, so there are no further warm-ups
+	assert.Equal(1, server.Warmups())
+	assert.True(server.Connections() <= 2)
+}
+
+func TestWarmer(t *testing.T) {
+	t.Run("Defaults", testNewWarmerDefaults)
+	t.Run("Update", testWarmerUpdate)
+	t.Run("Diff", testWarmerDiff)
+	t.Run("Failure", testWarmerFailure)
+	t.Run("Run", testWarmerRun)
+	t.Run("Shutdown", testWarmerShutdown)
+	t.Run("Drain", testWarmerDrain)
+	t.Run("Handler", testWarmerHandler)
+}
@@ -0,0 +1,373 @@
+package xhttp
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// FeatureLabel is the metric label for the name of an evaluated feature flag
+	FeatureLabel = "feature"
+
+	// DecisionLabel is the metric label for the outcome of a feature flag evaluation
+	DecisionLabel = "decision"
+
+	// EnabledDecision and DisabledDecision are the values of DecisionLabel
+	EnabledDecision  = "enabled"
+	DisabledDecision = "disabled"
+
+	// DefaultFeatureProviderTTL is the default time for which a FeatureProvider's decision is reused
+	DefaultFeatureProviderTTL = 10 * time.Second
+
+	// DefaultFeatureWarningInterval is the default minimum time between warnings about FeatureProvider failures
+	DefaultFeatureWarningInterval = time.Minute
+
+	// featureCacheSize bounds the number of cached FeatureProvider decisions
+	featureCacheSize = 10000
+)
+
+// FeatureFlag is the configured rollout of a single feature
+type FeatureFlag struct {
+	// Name identifies the feature.  Handlers test for a feature by this name with FeatureEnabled.
+	Name string `json:"name"`
+
+	// Enabled turns the feature on for every request, regardless of Percentage
+	Enabled bool `json:"enabled"`
+
+	// Percentage is the percentage, from 0 to 100, of requests for which the feature is on.  This allows
+	// a risky behavior to be ramped up gradually.
+	Percentage float64 `json:"percentage"`
+}
+
+// FeatureProvider decides feature flags from a source other than configuration, such as a remote flag service
+type FeatureProvider interface {
+	// Enabled decides whether the named feature is on for a request.  If the decision cannot be made, an error
+	// is returned and the feature's configured rollout applies instead.
+	Enabled(name string, request *http.Request) (bool, error)
+}
+
+// FeatureProviderFunc is a function type that implements FeatureProvider
+type FeatureProviderFunc func(string, *http.Request) (bool, error)
+
+func (f FeatureProviderFunc) Enabled(name string, request *http.Request) (bool, error) {
+	return f(name, request)
+}
+
+// FeatureOptions is the configurable policy for evaluating feature flags
+type FeatureOptions struct {
+	// Logger is the go-kit Logger used for logging.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger `json:"-"`
+
+	// Flags are the features evaluated for each request.  Flags without a name are ignored.
+	Flags []FeatureFlag `json:"flags"`
+
+	// Provider, if set, decides each flag ahead of its configured rollout
+	Provider FeatureProvider `json:"-"`
+
+	// ProviderTTL is how long a decision made by Provider is reused.  Decisions are cached for each feature and
+	// client identity, or for each feature alone when a request has no identity.  If unset,
+	// DefaultFeatureProviderTTL is used.  A negative value turns off caching, which a Provider that decides
+	// on more than the client identity requires.
+	ProviderTTL time.Duration `json:"providerTTL"`
+
+	// WarningInterval is the minimum time between warnings about Provider failures.  Failures in between are
+	// counted and reported with the next warning.  If unset, DefaultFeatureWarningInterval is used.
+	WarningInterval time.Duration `json:"warningInterval"`
+
+	// Identity, if set, makes percentage rollouts consistent for each client, so that a client sees the same
+	// behavior on every request as long as the percentage does not drop.  Requests without an identity, or all
+	// requests if this field is unset, are selected at random.
+	Identity IdentityFunc `json:"-"`
+
+	// Random returns a value in [0.0, 1.0) used to select requests for a percentage rollout.  If unset, rand.Float64 is used.
+	Random func() float64 `json:"-"`
+
+	// Now is the source of the current time for provider caching and warnings.  If unset, time.Now is used.
+	Now func() time.Time `json:"-"`
+
+	// Evaluations is the counter for flag evaluations, labeled by FeatureLabel and DecisionLabel.  If unset,
+	// no such metric is collected.
+	Evaluations metrics.Counter `json:"-"`
+}
+
+func (o FeatureOptions) logger() log.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+
+	return logging.DefaultLogger()
+}
+
+func (o FeatureOptions) flags() []FeatureFlag {
+	var flags []FeatureFlag
+	for _, f := range o.Flags {
+		if len(f.Name) > 0 {
+			flags = append(flags, f)
+		}
+	}
+
+	return flags
+}
+
+func (o FeatureOptions) random() func() float64 {
+	if o.Random != nil {
+		return o.Random
+	}
+
+	return rand.Float64
+}
+
+func (o FeatureOptions) providerTTL() time.Duration {
+	if o.ProviderTTL != 0 {
+		return o.ProviderTTL
+	}
+
+	return DefaultFeatureProviderTTL
+}
+
+func (o FeatureOptions) warningInterval() time.Duration {
+	if o.WarningInterval > 0 {
+		return o.WarningInterval
+	}
+
+	return DefaultFeatureWarningInterval
+}
+
+func (o FeatureOptions) now() func() time.Time {
+	if o.Now != nil {
+		return o.Now
+	}
+
+	return time.Now
+}
+
+// Features holds the feature flag decisions for a single request
+type Features map[string]bool
+
+type featuresContextKey struct{}
+
+// WithFeatures returns a new context with the given feature decisions attached
+func WithFeatures(parent context.Context, f Features) context.Context {
+	return context.WithValue(parent, featuresContextKey{}, f)
+}
+
+// GetFeatures returns the feature decisions from the context, if any are present
+func GetFeatures(ctx context.Context) (Features, bool) {
+	f, ok := ctx.Value(featuresContextKey{}).(Features)
+	return f, ok
+}
+
+// FeatureEnabled tests if the named feature is on for the request with the given context.  Features that
+// were not evaluated, including when there is no feature flag middleware, are off.
+func FeatureEnabled(ctx context.Context, name string) bool {
+	f, _ := GetFeatures(ctx)
+	return f[name]
+}
+
+// providerDecision is a cached decision made by a FeatureProvider
+type providerDecision struct {
+	enabled bool
+	expires time.Time
+}
+
+// features evaluates a set of feature flags
+type features struct {
+	logger          log.Logger
+	flags           []FeatureFlag
+	provider        FeatureProvider
+	providerTTL     time.Duration
+	warningInterval time.Duration
+	identity        IdentityFunc
+	random          func() float64
+	now             func() time.Time
+	evaluations     metrics.Counter
+
+	cacheLock sync.Mutex
+	cache     map[string]providerDecision
+
+	warningLock sync.Mutex
+	nextWarning time.Time
+	suppressed  int
+}
+
+// selected determines whether a request falls within a flag's percentage rollout
+func (fs *features) selected(f FeatureFlag, request *http.Request) bool {
+	if f.Percentage <= 0 {
+		return false
+	} else if f.Percentage >= 100 {
+		return true
+	}
+
+	var point float64
+	if identity, ok := fs.identity(request); ok {
+		// hash the flag name along with the identity, so that each flag ramps through a different set of clients
+		h := fnv.New32a()
+		h.Write([]byte(f.Name))
+		h.Write([]byte{0})
+		h.Write([]byte(identity))
+		point = float64(h.Sum32()%10000) / 10000.0
+	} else {
+		point = fs.random()
+	}
+
+	return point*100.0 < f.Percentage
+}
+
+// cached returns the unexpired provider decision stored under the given key, if any
+func (fs *features) cached(key string, now time.Time) (bool, bool) {
+	fs.cacheLock.Lock()
+	defer fs.cacheLock.Unlock()
+
+	d, ok := fs.cache[key]
+	if !ok || !now.Before(d.expires) {
+		return false, false
+	}
+
+	return d.enabled, true
+}
+
+// store caches a provider decision.  When the cache is full, expired decisions are evicted, and if that
+// is not enough the cache is cleared, so that the number of client identities cannot grow it without bound.
+func (fs *features) store(key string, enabled bool, now time.Time) {
+	fs.cacheLock.Lock()
+	defer fs.cacheLock.Unlock()
+
+	if len(fs.cache) >= featureCacheSize {
+		for k, d := range fs.cache {
+			if !now.Before(d.expires) {
+				delete(fs.cache, k)
+			}
+		}
+
+		if len(fs.cache) >= featureCacheSize {
+			fs.cache = make(map[string]providerDecision)
+		}
+	}
+
+	fs.cache[key] = providerDecision{enabled: enabled, expires: now.Add(fs.providerTTL)}
+}
+
+// providerFailed logs a provider failure, unless a warning was logged within the warning interval
+func (fs *features) providerFailed(name string, err error, now time.Time) {
+	fs.warningLock.Lock()
+	if now.Before(fs.nextWarning) {
+		fs.suppressed++
+		fs.warningLock.Unlock()
+		return
+	}
+
+	suppressed := fs.suppressed
+	fs.suppressed = 0
+	fs.nextWarning = now.Add(fs.warningInterval)
+	fs.warningLock.Unlock()
+
+	fs.logger.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "feature provider failed, using configured rollout", FeatureLabel, name, "suppressed", suppressed, logging.ErrorKey(), err)
+}
+
+// provide asks the provider to decide a flag, reusing a cached decision if there is one.  If the provider
+// fails, this method returns false for its second value.
+func (fs *features) provide(f FeatureFlag, request *http.Request) (bool, bool) {
+	var (
+		now = fs.now()
+		key = f.Name
+	)
+
+	if identity, ok := fs.identity(request); ok {
+		key = f.Name + "\x00" + identity
+	}
+
+	if fs.providerTTL > 0 {
+		if enabled, ok := fs.cached(key, now); ok {
+			return enabled, true
+		}
+	}
+
+	enabled, err := fs.provider.Enabled(f.Name, request)
+	if err != nil {
+		fs.providerFailed(f.Name, err, now)
+		return false, false
+	}
+
+	if fs.providerTTL > 0 {
+		fs.store(key, enabled, now)
+	}
+
+	return enabled, true
+}
+
+func (fs *features) evaluate(f FeatureFlag, request *http.Request) bool {
+	if fs.provider != nil {
+		if enabled, ok := fs.provide(f, request); ok {
+			return enabled
+		}
+	}
+
+	return f.Enabled || fs.selected(f, request)
+}
+
+func (fs *features) decide(request *http.Request) Features {
+	decisions := make(Features, len(fs.flags))
+	for _, f := range fs.flags {
+		enabled := fs.evaluate(f, request)
+		decisions[f.Name] = enabled
+
+		if fs.evaluations != nil {
+			decision := DisabledDecision
+			if enabled {
+				decision = EnabledDecision
+			}
+
+			fs.evaluations.With(FeatureLabel, f.Name, DecisionLabel, decision).Add(1.0)
+		}
+	}
+
+	return decisions
+}
+
+// FeatureFlags returns an Alice-style constructor that evaluates feature flags for each request.  The decisions
+// are placed into the request context, where handlers test them with FeatureEnabled.
+//
+// If no flags are configured, the returned constructor does not decorate handlers.
+func FeatureFlags(o FeatureOptions) func(http.Handler) http.Handler {
+	flags := o.flags()
+	if len(flags) == 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	fs := &features{
+		logger:          o.logger(),
+		flags:           flags,
+		provider:        o.Provider,
+		providerTTL:     o.providerTTL(),
+		warningInterval: o.warningInterval(),
+		identity:        o.Identity,
+		random:          o.random(),
+		now:             o.now(),
+		evaluations:     o.Evaluations,
+		cache:           make(map[string]providerDecision),
+	}
+
+	if fs.identity == nil {
+		fs.identity = func(*http.Request) (string, bool) { return "", false }
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			next.ServeHTTP(
+				response,
+				request.WithContext(WithFeatures(request.Context(), fs.decide(request))),
+			)
+		})
+	}
+}
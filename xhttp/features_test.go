@@ -0,0 +1,280 @@
+package xhttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeaturesContext(t *testing.T) {
+	assert := assert.New(t)
+
+	f, ok := GetFeatures(context.Background())
+	assert.Nil(f)
+	assert.False(ok)
+	assert.False(FeatureEnabled(context.Background(), "test"))
+
+	ctx := WithFeatures(context.Background(), Features{"on": true, "off": false})
+	f, ok = GetFeatures(ctx)
+	assert.Equal(Features{"on": true, "off": false}, f)
+	assert.True(ok)
+	assert.True(FeatureEnabled(ctx, "on"))
+	assert.False(FeatureEnabled(ctx, "off"))
+	assert.False(FeatureEnabled(ctx, "missing"))
+}
+
+// serveFeatures runs a request through the FeatureFlags middleware and returns the decisions a handler sees
+func serveFeatures(t *testing.T, o FeatureOptions, request *http.Request) Features {
+	var (
+		features Features
+		handled  bool
+	)
+
+	FeatureFlags(o)(
+		http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+			handled = true
+			features, _ = GetFeatures(request.Context())
+		}),
+	).ServeHTTP(httptest.NewRecorder(), request)
+
+	require.True(t, handled)
+	return features
+}
+
+func TestFeatureFlags(t *testing.T) {
+	t.Run("NoFlags", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			next   = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+		)
+
+		assert.NotNil(FeatureFlags(FeatureOptions{})(next))
+		assert.Nil(serveFeatures(t, FeatureOptions{Flags: []FeatureFlag{{Enabled: true}}}, httptest.NewRequest("GET", "/", nil)))
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			provider = xmetricstest.NewProvider(nil)
+			random   = 0.5
+
+			o = FeatureOptions{
+				Logger: logging.NewTestLogger(nil, t),
+				Flags: []FeatureFlag{
+					{Name: "on", Enabled: true},
+					{Name: "off"},
+					{Name: "all", Percentage: 100.0},
+					{Name: "above", Percentage: 60.0},
+					{Name: "below", Percentage: 40.0},
+				},
+				Random:      func() float64 { return random },
+				Evaluations: provider.NewCounter("evaluations"),
+			}
+		)
+
+		assert.Equal(
+			Features{"on": true, "off": false, "all": true, "above": true, "below": false},
+			serveFeatures(t, o, httptest.NewRequest("GET", "/", nil)),
+		)
+
+		random = 0.1
+		assert.Equal(
+			Features{"on": true, "off": false, "all": true, "above": true, "below": true},
+			serveFeatures(t, o, httptest.NewRequest("GET", "/", nil)),
+		)
+
+		provider.Assert(t, "evaluations", FeatureLabel, "on", DecisionLabel, EnabledDecision)(xmetricstest.Value(2.0))
+		provider.Assert(t, "evaluations", FeatureLabel, "off", DecisionLabel, DisabledDecision)(xmetricstest.Value(2.0))
+		provider.Assert(t, "evaluations", FeatureLabel, "below", DecisionLabel, EnabledDecision)(xmetricstest.Value(1.0))
+		provider.Assert(t, "evaluations", FeatureLabel, "below", DecisionLabel, DisabledDecision)(xmetricstest.Value(1.0))
+	})
+
+	t.Run("Identity", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o      = FeatureOptions{
+				Flags:    []FeatureFlag{{Name: "ramp", Percentage: 50.0}},
+				Identity: HeaderIdentity("X-Client"),
+				Random: func() float64 {
+					assert.Fail("Requests with an identity should not be selected at random")
+					return 0.0
+				},
+			}
+
+			enabled int
+		)
+
+		for i := 0; i < 1000; i++ {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("X-Client", fmt.Sprintf("client-%d", i))
+			first := serveFeatures(t, o, request)["ramp"]
+
+			// each client always gets the same decision
+			assert.Equal(first, serveFeatures(t, o, request)["ramp"])
+			if first {
+				enabled++
+			}
+		}
+
+		// roughly half the clients should have the feature
+		assert.True(enabled > 400 && enabled < 600, "%d clients had the feature", enabled)
+	})
+
+	t.Run("Provider", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o      = FeatureOptions{
+				Logger: logging.NewTestLogger(nil, t),
+				Flags: []FeatureFlag{
+					{Name: "provided"},
+					{Name: "failed", Enabled: true},
+				},
+				Provider: FeatureProviderFunc(func(name string, request *http.Request) (bool, error) {
+					assert.NotNil(request)
+					if name == "failed" {
+						return false, errors.New("expected")
+					}
+
+					return true, nil
+				}),
+			}
+		)
+
+		assert.Equal(
+			Features{"provided": true, "failed": true},
+			serveFeatures(t, o, httptest.NewRequest("GET", "/", nil)),
+		)
+	})
+
+	t.Run("ProviderCache", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			now     = time.Now()
+			calls   = make(map[string]int)
+
+			o = FeatureOptions{
+				Logger:      logging.NewTestLogger(nil, t),
+				Flags:       []FeatureFlag{{Name: "provided"}},
+				ProviderTTL: time.Minute,
+				Identity:    HeaderIdentity("X-Client"),
+				Now:         func() time.Time { return now },
+				Provider: FeatureProviderFunc(func(name string, request *http.Request) (bool, error) {
+					client := request.Header.Get("X-Client")
+					calls[client]++
+					return client == "enabled", nil
+				}),
+			}
+
+			decisions []Features
+			handler   = FeatureFlags(o)(
+				http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+					f, _ := GetFeatures(request.Context())
+					decisions = append(decisions, f)
+				}),
+			)
+		)
+
+		serve := func(client string) Features {
+			request := httptest.NewRequest("GET", "/", nil)
+			if len(client) > 0 {
+				request.Header.Set("X-Client", client)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+			require.NotEmpty(decisions)
+			return decisions[len(decisions)-1]
+		}
+
+		// decisions are cached for each client
+		assert.Equal(Features{"provided": true}, serve("enabled"))
+		assert.Equal(Features{"provided": false}, serve("disabled"))
+		assert.Equal(Features{"provided": true}, serve("enabled"))
+		assert.Equal(Features{"provided": false}, serve("disabled"))
+		assert.Equal(map[string]int{"enabled": 1, "disabled": 1}, calls)
+
+		// requests without an identity share a decision
+		assert.Equal(Features{"provided": false}, serve(""))
+		assert.Equal(Features{"provided": false}, serve(""))
+		assert.Equal(1, calls[""])
+
+		// decisions expire
+		now = now.Add(time.Minute)
+		assert.Equal(Features{"provided": true}, serve("enabled"))
+		assert.Equal(2, calls["enabled"])
+	})
+
+	t.Run("ProviderNoCache", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			calls  int
+			o      = FeatureOptions{
+				Flags:       []FeatureFlag{{Name: "provided"}},
+				ProviderTTL: -1,
+				Provider: FeatureProviderFunc(func(string, *http.Request) (bool, error) {
+					calls++
+					return true, nil
+				}),
+			}
+
+			handler = FeatureFlags(o)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		)
+
+		for i := 0; i < 3; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}
+
+		assert.Equal(3, calls)
+	})
+
+	t.Run("ProviderWarnings", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			now    = time.Now()
+			output bytes.Buffer
+
+			o = FeatureOptions{
+				Logger:          log.NewLogfmtLogger(&output),
+				Flags:           []FeatureFlag{{Name: "failed", Enabled: true}},
+				WarningInterval: time.Minute,
+				Now:             func() time.Time { return now },
+				Provider: FeatureProviderFunc(func(string, *http.Request) (bool, error) {
+					return false, errors.New("expected")
+				}),
+			}
+
+			decisions Features
+			handler   = FeatureFlags(o)(
+				http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+					decisions, _ = GetFeatures(request.Context())
+				}),
+			)
+		)
+
+		for i := 0; i < 5; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			assert.Equal(Features{"failed": true}, decisions)
+		}
+
+		// failures are not cached, but only the first is logged within the interval
+		assert.Equal(1, strings.Count(output.String(), "feature provider failed"))
+		assert.Contains(output.String(), "suppressed=0")
+
+		now = now.Add(time.Minute)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		assert.Equal(2, strings.Count(output.String(), "feature provider failed"))
+		assert.Contains(output.String(), "suppressed=4")
+	})
+}
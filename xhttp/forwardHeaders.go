@@ -0,0 +1,139 @@
+package xhttp
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// hopByHopHeaders are the canonicalized names of the headers that apply only to a single connection, as defined
+// by RFC 7230 section 6.1 and RFC 2616 section 13.5.1, along with the nonstandard Proxy-Connection.  None of these
+// may be forwarded to another server.
+var hopByHopHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// connectionTokens returns the canonicalized header names listed in a header's Connection values.  Each of these
+// names is also hop-by-hop for that message.
+func connectionTokens(h http.Header) map[string]bool {
+	var tokens map[string]bool
+	for _, value := range h["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); len(token) > 0 {
+				if tokens == nil {
+					tokens = make(map[string]bool)
+				}
+
+				tokens[textproto.CanonicalMIMEHeaderKey(token)] = true
+			}
+		}
+	}
+
+	return tokens
+}
+
+// IsHopByHop tests if the named header applies only to a single connection for a message with the given header,
+// and therefore must not be forwarded.  This includes both the standard hop-by-hop headers and any header named
+// by the message's Connection header.  The header h may be nil, in which case only the standard headers are considered.
+func IsHopByHop(name string, h http.Header) bool {
+	name = textproto.CanonicalMIMEHeaderKey(name)
+	return hopByHopHeaders[name] || connectionTokens(h)[name]
+}
+
+// StripHopByHop removes all hop-by-hop headers from h, in place, including those named by its Connection header
+func StripHopByHop(h http.Header) {
+	tokens := connectionTokens(h)
+	for name := range h {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if hopByHopHeaders[canonical] || tokens[canonical] {
+			delete(h, name)
+		}
+	}
+}
+
+// FoldHeaderValue normalizes a single header value.  Obsolete line folding, i.e. a line break followed by spaces
+// or tabs, is replaced by a single space as RFC 7230 section 3.2.4 requires.  Any other CR or LF is also replaced with
+// a space, so that a forwarded value can never inject headers.  Leading and trailing whitespace is removed.
+func FoldHeaderValue(value string) string {
+	if !strings.ContainsAny(value, "\r\n") {
+		return strings.TrimSpace(value)
+	}
+
+	var (
+		folded  strings.Builder
+		pending = false
+	)
+
+	folded.Grow(len(value))
+	for _, c := range value {
+		switch c {
+		case '\r', '\n':
+			pending = true
+
+		case ' ', '\t':
+			if pending {
+				continue
+			}
+
+			folded.WriteRune(c)
+
+		default:
+			if pending {
+				folded.WriteByte(' ')
+				pending = false
+			}
+
+			folded.WriteRune(c)
+		}
+	}
+
+	return strings.TrimSpace(folded.String())
+}
+
+// ForwardHeader produces a copy of h that is safe to send to another server, as when fanning out or mirroring a
+// request.  All hop-by-hop headers are omitted, names are canonicalized with values under names that differ only
+// by case merged, and each value is normalized with FoldHeaderValue.  The original header is not modified.
+func ForwardHeader(h http.Header) http.Header {
+	var (
+		tokens    = connectionTokens(h)
+		forwarded = make(http.Header, len(h))
+	)
+
+	for name, values := range h {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if hopByHopHeaders[canonical] || tokens[canonical] {
+			continue
+		}
+
+		for _, v := range values {
+			forwarded[canonical] = append(forwarded[canonical], FoldHeaderValue(v))
+		}
+	}
+
+	return forwarded
+}
+
+// CopyForwardedHeaders copies the named headers from source to target, appending to any existing values.  Hop-by-hop
+// headers are never copied, even if named, and each value is normalized with FoldHeaderValue.  Names are canonicalized
+// before lookup, so source should be canonicalized as headers parsed by net/http are.
+func CopyForwardedHeaders(target, source http.Header, names ...string) {
+	tokens := connectionTokens(source)
+	for _, name := range names {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if hopByHopHeaders[canonical] || tokens[canonical] {
+			continue
+		}
+
+		for _, v := range source[canonical] {
+			target[canonical] = append(target[canonical], FoldHeaderValue(v))
+		}
+	}
+}
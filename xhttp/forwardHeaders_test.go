@@ -0,0 +1,132 @@
+package xhttp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsHopByHop(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		header = http.Header{"Connection": []string{"keep-alive, x-private ", " , X-Other"}}
+	)
+
+	for _, name := range []string{"Connection", "keep-alive", "Proxy-Authenticate", "proxy-authorization", "Proxy-Connection", "TE", "Trailer", "Transfer-Encoding", "upgrade"} {
+		assert.True(IsHopByHop(name, nil), name)
+		assert.True(IsHopByHop(name, header), name)
+	}
+
+	for _, name := range []string{"X-Private", "x-other"} {
+		assert.False(IsHopByHop(name, nil), name)
+		assert.True(IsHopByHop(name, header), name)
+	}
+
+	for _, name := range []string{"Content-Type", "Authorization", "X-Webpa-Device-Name"} {
+		assert.False(IsHopByHop(name, nil), name)
+		assert.False(IsHopByHop(name, header), name)
+	}
+}
+
+func TestStripHopByHop(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		header = http.Header{
+			"Connection":        []string{"X-Private"},
+			"X-Private":         []string{"secret"},
+			"Keep-Alive":        []string{"timeout=5"},
+			"transfer-encoding": []string{"chunked"},
+			"Te":                []string{"trailers"},
+			"Upgrade":           []string{"h2c"},
+			"Content-Type":      []string{"application/json"},
+			"X-Custom":          []string{"value"},
+		}
+	)
+
+	StripHopByHop(header)
+	assert.Equal(
+		http.Header{
+			"Content-Type": []string{"application/json"},
+			"X-Custom":     []string{"value"},
+		},
+		header,
+	)
+
+	StripHopByHop(nil)
+}
+
+func TestFoldHeaderValue(t *testing.T) {
+	testData := []struct {
+		value    string
+		expected string
+	}{
+		{"", ""},
+		{"value", "value"},
+		{"  value\t", "value"},
+		{"multiple  internal\tspaces", "multiple  internal\tspaces"},
+		{"folded\r\n value", "folded value"},
+		{"folded\r\n\t \tvalue\r\n  again", "folded value again"},
+		{"bare\nnewline", "bare newline"},
+		{"injected\r\nX-Evil: true", "injected X-Evil: true"},
+		{"trailing\r\n", "trailing"},
+	}
+
+	for _, record := range testData {
+		assert.Equal(t, record.expected, FoldHeaderValue(record.value), "%q", record.value)
+	}
+}
+
+func TestForwardHeader(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		header = http.Header{
+			"Connection":   []string{"close, X-Private"},
+			"X-Private":    []string{"secret"},
+			"Upgrade":      []string{"websocket"},
+			"Content-Type": []string{"application/json"},
+			"x-custom":     []string{" folded\r\n value "},
+			"X-Multi":      []string{"first", "second"},
+		}
+
+		forwarded = ForwardHeader(header)
+	)
+
+	assert.Equal(
+		http.Header{
+			"Content-Type": []string{"application/json"},
+			"X-Custom":     []string{"folded value"},
+			"X-Multi":      []string{"first", "second"},
+		},
+		forwarded,
+	)
+
+	// the original is untouched
+	assert.Len(header, 6)
+	assert.Equal([]string{" folded\r\n value "}, header["x-custom"])
+
+	forwarded["X-Multi"][0] = "changed"
+	assert.Equal([]string{"first", "second"}, header["X-Multi"])
+	assert.Empty(ForwardHeader(nil))
+}
+
+func TestCopyForwardedHeaders(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		source = http.Header{
+			"Connection":   []string{"X-Private"},
+			"X-Private":    []string{"secret"},
+			"Keep-Alive":   []string{"timeout=5"},
+			"Content-Type": []string{"application/json"},
+			"X-Multi":      []string{"first", "second\r\n folded"},
+		}
+
+		target = http.Header{"X-Multi": []string{"existing"}}
+	)
+
+	CopyForwardedHeaders(target, source, "connection", "X-Private", "Keep-Alive", "x-multi", "X-Missing")
+	assert.Equal(
+		http.Header{"X-Multi": []string{"existing", "first", "second folded"}},
+		target,
+	)
+}
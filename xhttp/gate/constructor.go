@@ -2,6 +2,8 @@ package gate
 
 import (
 	"net/http"
+
+	"github.com/Comcast/webpa-common/xhttp"
 )
 
 // constructor is a configurable Alice-style decorator for HTTP handlers that controls
@@ -40,6 +42,15 @@ func WithClosedHandler(closed http.Handler) ConstructorOption {
 	}
 }
 
+// WithRetryAfter configures the closed handler to reject requests with http.StatusServiceUnavailable and a Retry-After
+// header computed by the given function, so that well-behaved clients back off while the gate is closed.  This option
+// replaces any closed handler.
+func WithRetryAfter(retryAfter xhttp.RetryAfterFunc) ConstructorOption {
+	return func(c *constructor) {
+		c.closed = xhttp.RetryAfterHandler(http.StatusServiceUnavailable, retryAfter)
+	}
+}
+
 // NewConstructor returns an Alice-style constructor which decorates HTTP handlers with gating logic.  If supplied, the closed
 // handler is invoked instead of the decorated handler whenever the gate is closed.  The closed handler may be nil, in which
 // case a default is used that returns http.StatusServiceUnavailable.
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -77,6 +78,27 @@ func testNewConstructorCustomClosed(t *testing.T) {
 	assert.Equal("foobar", response.Header().Get("X-Test"))
 }
 
+func testNewConstructorRetryAfter(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		next = http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			response.WriteHeader(201)
+		})
+
+		g = New(Closed)
+		c = NewConstructor(g, WithRetryAfter(func() time.Duration { return 90 * time.Second }))
+	)
+
+	require.NotNil(c)
+
+	response := httptest.NewRecorder()
+	c(next).ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusServiceUnavailable, response.Code)
+	assert.Equal("90", response.Header().Get("Retry-After"))
+}
+
 func TestNewConstructor(t *testing.T) {
 	t.Run("NilGate", testNewConstructorNilGate)
 	t.Run("Default", func(t *testing.T) {
@@ -88,4 +110,5 @@ func TestNewConstructor(t *testing.T) {
 	})
 
 	t.Run("CustomClosed", testNewConstructorCustomClosed)
+	t.Run("RetryAfter", testNewConstructorRetryAfter)
 }
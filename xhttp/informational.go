@@ -0,0 +1,43 @@
+package xhttp
+
+import (
+	"net/http"
+)
+
+// IsInformational tests if a status code is a 1xx informational status that precedes the final response,
+// such as 103 Early Hints.  101 Switching Protocols is not informational in this sense, since it is the last
+// response sent over the connection.
+//
+// http.ResponseWriter decorators that account for status codes should use this function to ignore interim
+// responses, so that the status they record is always that of the final response.
+func IsInformational(statusCode int) bool {
+	return statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols
+}
+
+// WriteInformational sends a 1xx informational response with the given headers, which are added to the
+// response's current headers.  Since an informational response is sent with all the response's headers at
+// that time, any headers a handler sets beforehand are included as well.  The handler may send any number of
+// informational responses before its final response.
+//
+// This function panics if statusCode is not informational, as that would commit the final response.
+func WriteInformational(response http.ResponseWriter, statusCode int, header http.Header) {
+	if !IsInformational(statusCode) {
+		panic("Not an informational status code")
+	}
+
+	target := response.Header()
+	for name, values := range header {
+		for _, value := range values {
+			target.Add(name, value)
+		}
+	}
+
+	response.WriteHeader(statusCode)
+}
+
+// WriteEarlyHints sends a 103 Early Hints response carrying the given Link header values, e.g.
+// "</style.css>; rel=preload; as=style", so that clients can begin fetching resources while the final
+// response is being prepared.  The links remain in the response's headers and are sent with the final response.
+func WriteEarlyHints(response http.ResponseWriter, links ...string) {
+	WriteInformational(response, http.StatusEarlyHints, http.Header{"Link": links})
+}
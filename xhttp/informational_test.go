@@ -0,0 +1,151 @@
+package xhttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsInformational(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, statusCode := range []int{http.StatusContinue, http.StatusProcessing, http.StatusEarlyHints, 199} {
+		assert.True(IsInformational(statusCode), strconv.Itoa(statusCode))
+	}
+
+	for _, statusCode := range []int{0, 99, http.StatusSwitchingProtocols, http.StatusOK, http.StatusNotFound, http.StatusInternalServerError} {
+		assert.False(IsInformational(statusCode), strconv.Itoa(statusCode))
+	}
+}
+
+func testWriteInformationalInvalid(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() {
+		WriteInformational(httptest.NewRecorder(), http.StatusOK, nil)
+	})
+
+	assert.Panics(func() {
+		WriteInformational(httptest.NewRecorder(), http.StatusSwitchingProtocols, nil)
+	})
+}
+
+// informationalResponses records the interim responses received by a client
+type informationalResponses struct {
+	lock      sync.Mutex
+	responses []int
+	headers   []textproto.MIMEHeader
+}
+
+func (ir *informationalResponses) got1xxResponse(code int, header textproto.MIMEHeader) error {
+	ir.lock.Lock()
+	ir.responses = append(ir.responses, code)
+	ir.headers = append(ir.headers, header)
+	ir.lock.Unlock()
+	return nil
+}
+
+// testWriteInformationalServer verifies that interim responses reach a client through a TimeoutWriter without
+// being mistaken for the final response
+func testWriteInformationalServer(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server = httptest.NewServer(EnforceTimeout(time.Minute, nil)(
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				WriteInformational(response, http.StatusProcessing, nil)
+				WriteEarlyHints(response, "</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script")
+				response.Header().Set("Content-Type", "text/plain")
+				response.WriteHeader(http.StatusAccepted)
+				response.Write([]byte("final"))
+			}),
+		))
+
+		interim informationalResponses
+	)
+
+	defer server.Close()
+
+	request, err := http.NewRequest("GET", server.URL, nil)
+	require.NoError(err)
+	request = request.WithContext(httptrace.WithClientTrace(
+		request.Context(),
+		&httptrace.ClientTrace{Got1xxResponse: interim.got1xxResponse},
+	))
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(err)
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(err)
+	assert.Equal(http.StatusAccepted, response.StatusCode)
+	assert.Equal("final", string(body))
+	assert.Equal(
+		[]string{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"},
+		response.Header["Link"],
+	)
+
+	interim.lock.Lock()
+	defer interim.lock.Unlock()
+	assert.Equal([]int{http.StatusProcessing, http.StatusEarlyHints}, interim.responses)
+	require.Len(interim.headers, 2)
+	assert.Equal(
+		[]string{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"},
+		interim.headers[1]["Link"],
+	)
+}
+
+// testWriteInformationalTimeout verifies that a timeout response can still be sent after an interim response
+func testWriteInformationalTimeout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		handlerDone = make(chan struct{})
+		server      = httptest.NewServer(EnforceTimeout(100*time.Millisecond, nil)(
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				defer close(handlerDone)
+				WriteEarlyHints(response, "</style.css>; rel=preload; as=style")
+				<-request.Context().Done()
+			}),
+		))
+
+		interim informationalResponses
+	)
+
+	defer server.Close()
+
+	request, err := http.NewRequest("GET", server.URL, nil)
+	require.NoError(err)
+	request = request.WithContext(httptrace.WithClientTrace(
+		request.Context(),
+		&httptrace.ClientTrace{Got1xxResponse: interim.got1xxResponse},
+	))
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(err)
+	response.Body.Close()
+	<-handlerDone
+
+	assert.Equal(http.StatusGatewayTimeout, response.StatusCode)
+
+	interim.lock.Lock()
+	defer interim.lock.Unlock()
+	assert.Equal([]int{http.StatusEarlyHints}, interim.responses)
+}
+
+func TestWriteInformational(t *testing.T) {
+	t.Run("Invalid", testWriteInformationalInvalid)
+	t.Run("Server", testWriteInformationalServer)
+	t.Run("Timeout", testWriteInformationalTimeout)
+}
@@ -0,0 +1,448 @@
+package xhttp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultJournalCapacity is the number of requests a Journal retains when no capacity is configured
+const DefaultJournalCapacity = 1000
+
+// ErrUnsupportedJournal indicates that memory-mapped journals are not supported on the current platform
+var ErrUnsupportedJournal = errors.New("Request journals are not supported on this platform")
+
+// The journal file is a fixed header followed by a ring of fixed-size records.  All integers are little endian.
+//
+//	header: magic [8]byte, capacity uint32, record size uint32, next sequence uint64
+//	record: sequence uint64, start uint64 (UnixNano), latency uint64 (nanoseconds), status uint16,
+//	        method length uint8, unused uint8, path length uint16, method [16]byte, path [210]byte
+//
+// A record with sequence 0 has never been written.  The sequence is written last, so that a record which
+// was being written when the process died is never reported with a mix of old and new fields.  The next
+// sequence in the header is only written when the journal is closed, so a journal that was not closed
+// continues after the highest sequence among its records.
+const (
+	journalMagic      = "WPAJRNL1"
+	journalHeaderSize = 64
+	journalRecordSize = 256
+
+	// journalStripes is the number of locks guarding records.  Each record is guarded by the lock for its
+	// position in the ring, so that concurrent requests only contend when they write the same stripe.
+	journalStripes = 64
+
+	journalMethodOffset = 30
+	journalMethodSize   = 16
+	journalPathOffset   = journalMethodOffset + journalMethodSize
+	journalPathSize     = journalRecordSize - journalPathOffset
+)
+
+// JournalOptions configures a Journal
+type JournalOptions struct {
+	// File is the path of the journal file.  If the file already holds a journal with the same capacity,
+	// its entries are preserved, so that the traffic before a crash can be examined after a restart.
+	// This field is required.
+	File string `json:"file"`
+
+	// Capacity is the number of most recent requests retained.  If nonpositive, DefaultJournalCapacity is used.
+	Capacity int `json:"capacity"`
+}
+
+func (o JournalOptions) capacity() int {
+	if o.Capacity > 0 {
+		return o.Capacity
+	}
+
+	return DefaultJournalCapacity
+}
+
+// JournalEntry is a single request recorded in a Journal
+type JournalEntry struct {
+	// Sequence is the order in which this request was recorded, starting at 1
+	Sequence uint64 `json:"sequence"`
+
+	// Time is when the request started
+	Time time.Time `json:"time"`
+
+	// Method is the HTTP method of the request, truncated to 16 bytes
+	Method string `json:"method"`
+
+	// Path is the URL path of the request, truncated to 210 bytes.  The query is never recorded, since it
+	// may carry credentials.
+	Path string `json:"path"`
+
+	// Status is the response status code
+	Status int `json:"status"`
+
+	// Latency is how long the request took to serve, in nanoseconds
+	Latency time.Duration `json:"latency"`
+}
+
+// Journal is a persistent ring of the most recent requests served, intended for reconstructing the traffic
+// just before a crash.  Entries are written to a memory-mapped file, so they survive the process dying without
+// any explicit flush.
+//
+// A Journal is an http.Handler which writes its entries, oldest first, as a JSON array.  This is normally
+// exposed as an administrative endpoint.
+//
+// Recording does not serialize requests:  each entry reserves its sequence atomically, and only entries written
+// to records guarded by the same stripe lock wait on each other.
+type Journal struct {
+	// state guards data against Close.  It is only held exclusively by Close.
+	state    sync.RWMutex
+	stripes  [journalStripes]sync.Mutex
+	data     []byte
+	capacity uint64
+
+	// last is the most recently reserved sequence, accessed atomically
+	last   uint64
+	closer func() error
+}
+
+// OpenJournal opens or creates a journal file and maps it into memory.  On platforms that do not
+// support memory-mapped files, this function returns ErrUnsupportedJournal.
+func OpenJournal(o JournalOptions) (*Journal, error) {
+	if len(o.File) == 0 {
+		return nil, errors.New("A journal file is required")
+	}
+
+	var (
+		capacity = o.capacity()
+		size     = int64(journalHeaderSize + capacity*journalRecordSize)
+	)
+
+	f, err := os.OpenFile(o.File, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err == nil && fi.Size() != size {
+		// the file is new or holds a journal of a different capacity, so start over
+		if err = f.Truncate(0); err == nil {
+			err = f.Truncate(size)
+		}
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	data, unmap, err := mapJournal(f, int(size))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	j := newJournal(data, capacity)
+	j.closer = func() error {
+		err := unmap()
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+
+		return err
+	}
+
+	return j, nil
+}
+
+// newJournal creates a Journal over a region of memory, which must hold the header and capacity records.
+// If the region does not already contain a journal with the given capacity, it is reset.
+func newJournal(data []byte, capacity int) *Journal {
+	j := &Journal{
+		data:     data,
+		capacity: uint64(capacity),
+	}
+
+	if string(data[0:8]) != journalMagic ||
+		binary.LittleEndian.Uint32(data[8:12]) != uint32(capacity) ||
+		binary.LittleEndian.Uint32(data[12:16]) != journalRecordSize {
+		for i := range data {
+			data[i] = 0
+		}
+
+		copy(data[0:8], journalMagic)
+		binary.LittleEndian.PutUint32(data[8:12], uint32(capacity))
+		binary.LittleEndian.PutUint32(data[12:16], journalRecordSize)
+	}
+
+	// continue after the most recent record, whether or not the journal was closed cleanly
+	if next := binary.LittleEndian.Uint64(data[16:24]); next > 0 {
+		j.last = next - 1
+	}
+
+	for i := uint64(0); i < j.capacity; i++ {
+		if sequence := binary.LittleEndian.Uint64(j.slot(i)[0:8]); sequence > j.last {
+			j.last = sequence
+		}
+	}
+
+	return j
+}
+
+// slot returns the record at the given position in the ring
+func (j *Journal) slot(position uint64) []byte {
+	offset := journalHeaderSize + position*journalRecordSize
+	return j.data[offset : offset+journalRecordSize]
+}
+
+// position returns the position in the ring of the record which holds the given sequence
+func (j *Journal) position(sequence uint64) uint64 {
+	return (sequence - 1) % j.capacity
+}
+
+// Record appends an entry to this journal, overwriting the oldest entry if the journal is full.
+// The Sequence of the given entry is ignored.  A nil Journal discards all entries.
+func (j *Journal) Record(e JournalEntry) {
+	if j == nil {
+		return
+	}
+
+	j.state.RLock()
+	defer j.state.RUnlock()
+
+	if j.data == nil {
+		return
+	}
+
+	var (
+		sequence = atomic.AddUint64(&j.last, 1)
+		position = j.position(sequence)
+		stripe   = &j.stripes[position%journalStripes]
+		r        = j.slot(position)
+		method   = e.Method
+		path     = e.Path
+	)
+
+	if len(method) > journalMethodSize {
+		method = method[:journalMethodSize]
+	}
+
+	if len(path) > journalPathSize {
+		path = path[:journalPathSize]
+	}
+
+	stripe.Lock()
+	defer stripe.Unlock()
+
+	if binary.LittleEndian.Uint64(r[0:8]) > sequence {
+		// a later request has already lapped this one around the ring
+		return
+	}
+
+	binary.LittleEndian.PutUint64(r[0:8], 0)
+	binary.LittleEndian.PutUint64(r[8:16], uint64(e.Time.UnixNano()))
+	binary.LittleEndian.PutUint64(r[16:24], uint64(e.Latency))
+	binary.LittleEndian.PutUint16(r[24:26], uint16(e.Status))
+	r[26] = uint8(len(method))
+	binary.LittleEndian.PutUint16(r[28:30], uint16(len(path)))
+	copy(r[journalMethodOffset:], method)
+	copy(r[journalPathOffset:], path)
+	binary.LittleEndian.PutUint64(r[0:8], sequence)
+}
+
+// Entries returns the entries in this journal, oldest first
+func (j *Journal) Entries() []JournalEntry {
+	if j == nil {
+		return nil
+	}
+
+	j.state.RLock()
+	defer j.state.RUnlock()
+
+	if j.data == nil {
+		return nil
+	}
+
+	entries := make([]JournalEntry, 0, j.capacity)
+	for i := uint64(0); i < j.capacity; i++ {
+		if e, ok := j.entry(i); ok {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Sequence < entries[j].Sequence })
+	return entries
+}
+
+// entry reads the record at the given position in the ring, returning false if the record has never been
+// written or is corrupt
+func (j *Journal) entry(position uint64) (JournalEntry, bool) {
+	stripe := &j.stripes[position%journalStripes]
+	stripe.Lock()
+	defer stripe.Unlock()
+
+	r := j.slot(position)
+	sequence := binary.LittleEndian.Uint64(r[0:8])
+	if sequence == 0 {
+		return JournalEntry{}, false
+	}
+
+	var (
+		methodLength = int(r[26])
+		pathLength   = int(binary.LittleEndian.Uint16(r[28:30]))
+	)
+
+	if methodLength > journalMethodSize || pathLength > journalPathSize {
+		// a corrupt record, which can only happen if the file was modified externally
+		return JournalEntry{}, false
+	}
+
+	return JournalEntry{
+		Sequence: sequence,
+		Time:     time.Unix(0, int64(binary.LittleEndian.Uint64(r[8:16]))).UTC(),
+		Latency:  time.Duration(binary.LittleEndian.Uint64(r[16:24])),
+		Status:   int(binary.LittleEndian.Uint16(r[24:26])),
+		Method:   string(r[journalMethodOffset : journalMethodOffset+methodLength]),
+		Path:     string(r[journalPathOffset : journalPathOffset+pathLength]),
+	}, true
+}
+
+// Dump writes the entries in this journal to the given io.Writer as JSON, one entry per line, oldest first
+func (j *Journal) Dump(output io.Writer) error {
+	encoder := json.NewEncoder(output)
+	for _, e := range j.Entries() {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (j *Journal) ServeHTTP(response http.ResponseWriter, _ *http.Request) {
+	entries := j.Entries()
+	if entries == nil {
+		entries = []JournalEntry{}
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(response).Encode(entries)
+}
+
+// Close unmaps and closes the journal file.  Once closed, a Journal discards any further entries.
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+
+	j.state.Lock()
+	defer j.state.Unlock()
+
+	if j.data == nil {
+		return nil
+	}
+
+	binary.LittleEndian.PutUint64(j.data[16:24], atomic.LoadUint64(&j.last)+1)
+	j.data = nil
+	if j.closer != nil {
+		return j.closer()
+	}
+
+	return nil
+}
+
+// journalWriter captures the status code of a response
+type journalWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (jw *journalWriter) WriteHeader(status int) {
+	if jw.status == 0 && !IsInformational(status) {
+		jw.status = status
+	}
+
+	jw.ResponseWriter.WriteHeader(status)
+}
+
+func (jw *journalWriter) Write(p []byte) (int, error) {
+	if jw.status == 0 {
+		jw.status = http.StatusOK
+	}
+
+	return jw.ResponseWriter.Write(p)
+}
+
+func (jw *journalWriter) Flush() {
+	if f, ok := jw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (jw *journalWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := jw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+
+	return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", jw.ResponseWriter)
+}
+
+// RequestJournal returns an Alice-style constructor that records each request in the given Journal.  If the
+// decorated handler panics, the request is recorded, the entire journal is dumped to panicOutput, and the panic
+// continues.  A request that panics is recorded with the status it had already written, or with
+// http.StatusInternalServerError if it had not written one.  A panic with http.ErrAbortHandler deliberately aborts
+// a response rather than signaling a crash, so the journal is not dumped for it.  If panicOutput is nil, os.Stderr
+// is used.
+//
+// If j is nil, the returned constructor does not decorate handlers.
+func RequestJournal(j *Journal, panicOutput io.Writer) func(http.Handler) http.Handler {
+	if panicOutput == nil {
+		panicOutput = os.Stderr
+	}
+
+	return func(next http.Handler) http.Handler {
+		if j == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			var (
+				start   = time.Now()
+				wrapped = &journalWriter{ResponseWriter: response}
+			)
+
+			defer func() {
+				e := JournalEntry{
+					Time:    start,
+					Method:  request.Method,
+					Path:    request.URL.Path,
+					Status:  wrapped.status,
+					Latency: time.Since(start),
+				}
+
+				r := recover()
+				if e.Status == 0 {
+					if r != nil {
+						e.Status = http.StatusInternalServerError
+					} else {
+						e.Status = http.StatusOK
+					}
+				}
+
+				j.Record(e)
+				if r != nil {
+					if r != http.ErrAbortHandler {
+						j.Dump(panicOutput)
+					}
+
+					panic(r)
+				}
+			}()
+
+			next.ServeHTTP(wrapped, request)
+		})
+	}
+}
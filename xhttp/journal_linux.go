@@ -0,0 +1,18 @@
+package xhttp
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mapJournal maps a journal file into memory as a shared mapping, so that writes reach the file even if
+// the process dies without unmapping it
+func mapJournal(f *os.File, size int) ([]byte, func() error, error) {
+	data, err := unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return unix.Munmap(data) }, nil
+}
@@ -0,0 +1,71 @@
+package xhttp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenJournal(t *testing.T) {
+	t.Run("NoFile", func(t *testing.T) {
+		assert := assert.New(t)
+		j, err := OpenJournal(JournalOptions{})
+		assert.Nil(j)
+		assert.Error(err)
+	})
+
+	t.Run("BadFile", func(t *testing.T) {
+		assert := assert.New(t)
+		j, err := OpenJournal(JournalOptions{File: "/nosuch/directory/journal"})
+		assert.Nil(j)
+		assert.Error(err)
+	})
+
+	t.Run("Persistent", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		dir, err := ioutil.TempDir("", "journal")
+		require.NoError(err)
+		defer os.RemoveAll(dir)
+
+		o := JournalOptions{File: filepath.Join(dir, "requests.journal"), Capacity: 10}
+		j, err := OpenJournal(o)
+		require.NoError(err)
+		require.NotNil(j)
+
+		j.Record(JournalEntry{Method: "GET", Path: "/first", Status: 200})
+		j.Record(JournalEntry{Method: "POST", Path: "/second", Status: 201})
+		require.NoError(j.Close())
+		assert.NoError(j.Close())
+
+		fi, err := os.Stat(o.File)
+		require.NoError(err)
+		assert.Equal(int64(journalHeaderSize+10*journalRecordSize), fi.Size())
+
+		// reopening preserves the entries
+		j, err = OpenJournal(o)
+		require.NoError(err)
+		entries := j.Entries()
+		if assert.Len(entries, 2) {
+			assert.Equal("/first", entries[0].Path)
+			assert.Equal("/second", entries[1].Path)
+			assert.Equal(201, entries[1].Status)
+		}
+
+		require.NoError(j.Close())
+
+		// reopening with a different capacity starts over
+		o.Capacity = 5
+		j, err = OpenJournal(o)
+		require.NoError(err)
+		assert.Empty(j.Entries())
+		require.NoError(j.Close())
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package xhttp
+
+import "os"
+
+func mapJournal(*os.File, int) ([]byte, func() error, error) {
+	return nil, nil, ErrUnsupportedJournal
+}
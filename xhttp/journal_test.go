@@ -0,0 +1,305 @@
+package xhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestJournal creates a Journal over plain memory
+func newTestJournal(capacity int) *Journal {
+	return newJournal(make([]byte, journalHeaderSize+capacity*journalRecordSize), capacity)
+}
+
+func TestJournalOptions(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(DefaultJournalCapacity, JournalOptions{}.capacity())
+	assert.Equal(10, JournalOptions{Capacity: 10}.capacity())
+}
+
+func TestJournalNil(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		j        *Journal
+		response = httptest.NewRecorder()
+		output   bytes.Buffer
+	)
+
+	j.Record(JournalEntry{Method: "GET", Path: "/"})
+	assert.Empty(j.Entries())
+	assert.NoError(j.Dump(&output))
+	assert.Empty(output.String())
+	assert.NoError(j.Close())
+
+	j.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.JSONEq("[]", response.Body.String())
+}
+
+func TestJournalRecord(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		j       = newTestJournal(3)
+		start   = time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	assert.Empty(j.Entries())
+	for i := 0; i < 5; i++ {
+		j.Record(JournalEntry{
+			Sequence: 1234,
+			Time:     start.Add(time.Duration(i) * time.Second),
+			Method:   "GET",
+			Path:     "/api/v2/device/" + strings.Repeat("x", i),
+			Status:   200 + i,
+			Latency:  time.Duration(i) * time.Millisecond,
+		})
+	}
+
+	entries := j.Entries()
+	require.Len(entries, 3)
+	for i, e := range entries {
+		assert.Equal(uint64(i+3), e.Sequence)
+		assert.Equal(start.Add(time.Duration(i+2)*time.Second), e.Time)
+		assert.Equal("GET", e.Method)
+		assert.Equal("/api/v2/device/"+strings.Repeat("x", i+2), e.Path)
+		assert.Equal(202+i, e.Status)
+		assert.Equal(time.Duration(i+2)*time.Millisecond, e.Latency)
+	}
+
+	// oversized fields are truncated
+	j.Record(JournalEntry{Method: strings.Repeat("M", 100), Path: strings.Repeat("p", 1000)})
+	entries = j.Entries()
+	require.Len(entries, 3)
+	assert.Equal(strings.Repeat("M", journalMethodSize), entries[2].Method)
+	assert.Equal(strings.Repeat("p", journalPathSize), entries[2].Path)
+
+	assert.NoError(j.Close())
+	j.Record(JournalEntry{Method: "GET"})
+	assert.Empty(j.Entries())
+}
+
+func TestJournalReset(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		data   = make([]byte, journalHeaderSize+2*journalRecordSize)
+		j      = newJournal(data, 2)
+	)
+
+	j.Record(JournalEntry{Method: "GET", Path: "/first"})
+	j.Record(JournalEntry{Method: "GET", Path: "/second"})
+
+	// a journal over the same memory continues where the previous one left off
+	j = newJournal(data, 2)
+	j.Record(JournalEntry{Method: "GET", Path: "/third"})
+	entries := j.Entries()
+	if assert.Len(entries, 2) {
+		assert.Equal("/second", entries[0].Path)
+		assert.Equal(uint64(3), entries[1].Sequence)
+		assert.Equal("/third", entries[1].Path)
+	}
+
+	// a journal with a different capacity starts over
+	j = newJournal(data[:journalHeaderSize+journalRecordSize], 1)
+	assert.Empty(j.Entries())
+
+	// as does a journal over memory that isn't a journal
+	copy(data, "garbage!")
+	j = newJournal(data, 2)
+	assert.Empty(j.Entries())
+}
+
+func TestJournalConcurrentRecord(t *testing.T) {
+	const (
+		goroutines = 20
+		records    = 50
+	)
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		j       = newTestJournal(16)
+
+		waitGroup sync.WaitGroup
+	)
+
+	waitGroup.Add(goroutines)
+	for g := 0; g < goroutines; g++ {
+		go func(g int) {
+			defer waitGroup.Done()
+			for i := 0; i < records; i++ {
+				j.Record(JournalEntry{Method: "GET", Path: "/" + strconv.Itoa(g), Status: 200})
+				j.Entries()
+			}
+		}(g)
+	}
+
+	waitGroup.Wait()
+	entries := j.Entries()
+	require.Len(entries, 16)
+	for i, e := range entries {
+		assert.Equal(uint64(goroutines*records-15+i), e.Sequence)
+		assert.Equal("GET", e.Method)
+		assert.Equal(200, e.Status)
+	}
+}
+
+func TestJournalDumpAndServeHTTP(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		j        = newTestJournal(5)
+		output   bytes.Buffer
+		response = httptest.NewRecorder()
+	)
+
+	j.Record(JournalEntry{Method: "GET", Path: "/first", Status: 200})
+	j.Record(JournalEntry{Method: "POST", Path: "/second", Status: 500})
+
+	require.NoError(j.Dump(&output))
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(lines, 2)
+
+	var e JournalEntry
+	require.NoError(json.Unmarshal([]byte(lines[1]), &e))
+	assert.Equal("/second", e.Path)
+	assert.Equal(500, e.Status)
+
+	j.ServeHTTP(response, httptest.NewRequest("GET", "/journal", nil))
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+
+	var entries []JournalEntry
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &entries))
+	require.Len(entries, 2)
+	assert.Equal("/first", entries[0].Path)
+}
+
+func TestRequestJournal(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			next   = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+		)
+
+		assert.NotNil(RequestJournal(nil, nil)(next))
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			j      = newTestJournal(5)
+
+			handlers = []http.HandlerFunc{
+				func(http.ResponseWriter, *http.Request) {},
+				func(response http.ResponseWriter, _ *http.Request) { response.Write([]byte("body")) },
+				func(response http.ResponseWriter, _ *http.Request) {
+					response.WriteHeader(http.StatusEarlyHints)
+					response.WriteHeader(http.StatusNotFound)
+				},
+			}
+		)
+
+		for _, h := range handlers {
+			RequestJournal(j, nil)(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/test?secret=foo", nil))
+		}
+
+		entries := j.Entries()
+		if assert.Len(entries, 3) {
+			assert.Equal(http.StatusOK, entries[0].Status)
+			assert.Equal(http.StatusOK, entries[1].Status)
+			assert.Equal(http.StatusNotFound, entries[2].Status)
+
+			for _, e := range entries {
+				assert.Equal("PUT", e.Method)
+				assert.Equal("/test", e.Path)
+				assert.False(e.Time.IsZero())
+			}
+		}
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			j       = newTestJournal(5)
+			output  bytes.Buffer
+			handler = RequestJournal(j, &output)(
+				http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+					panic("expected")
+				}),
+			)
+		)
+
+		j.Record(JournalEntry{Method: "GET", Path: "/before"})
+		assert.PanicsWithValue("expected", func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/crash", nil))
+		})
+
+		entries := j.Entries()
+		if assert.Len(entries, 2) {
+			assert.Equal("/crash", entries[1].Path)
+			assert.Equal(http.StatusInternalServerError, entries[1].Status)
+		}
+
+		assert.Contains(output.String(), "/before")
+		assert.Contains(output.String(), "/crash")
+	})
+
+	t.Run("PanicAfterStatus", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			j       = newTestJournal(5)
+			output  bytes.Buffer
+			handler = RequestJournal(j, &output)(
+				http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+					response.WriteHeader(http.StatusBadGateway)
+					panic("expected")
+				}),
+			)
+		)
+
+		assert.PanicsWithValue("expected", func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/crash", nil))
+		})
+
+		// the status the client actually received is recorded
+		entries := j.Entries()
+		if assert.Len(entries, 1) {
+			assert.Equal(http.StatusBadGateway, entries[0].Status)
+		}
+
+		assert.Contains(output.String(), "/crash")
+	})
+
+	t.Run("AbortHandler", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			j       = newTestJournal(5)
+			output  bytes.Buffer
+			handler = RequestJournal(j, &output)(
+				http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+					panic(http.ErrAbortHandler)
+				}),
+			)
+		)
+
+		assert.PanicsWithValue(http.ErrAbortHandler, func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+		})
+
+		// an aborted request is recorded, but is not a crash
+		entries := j.Entries()
+		if assert.Len(entries, 1) {
+			assert.Equal("/abort", entries[0].Path)
+		}
+
+		assert.Empty(output.String())
+	})
+}
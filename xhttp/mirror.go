@@ -0,0 +1,359 @@
+package xhttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// MirrorHeader is set on each mirrored request, so that mirror targets can distinguish mirrored traffic
+	MirrorHeader = "X-Webpa-Mirror"
+
+	DefaultMirrorMaxConcurrency = 10
+	DefaultMirrorMaxBodySize    = 1024 * 1024
+	DefaultMirrorTimeout        = 10 * time.Second
+)
+
+// mirrorCredentialHeaders are never sent to a mirror target, which is usually less trusted than the primary
+var mirrorCredentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// ErrMirrorURLNotAbsolute is returned by Mirror when the mirror URL is not an absolute URL with a host
+var ErrMirrorURLNotAbsolute = errors.New("The mirror URL must be absolute")
+
+// MirrorOptions is the configurable policy for mirroring requests
+type MirrorOptions struct {
+	// Logger is the go-kit Logger used for logging.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger
+
+	// URL is the base URL of the mirror target.  The path and query of each mirrored request are appended to it.
+	URL string
+
+	// Percentage is the percentage, from 0 to 100, of requests that are mirrored.  If nonpositive, no requests are mirrored.
+	Percentage float64
+
+	// MaxConcurrency is the maximum number of mirrored requests in flight.  A request selected for mirroring
+	// while this many are in flight is dropped.  If unset, DefaultMirrorMaxConcurrency is used.
+	MaxConcurrency int
+
+	// MaxBodySize is the largest request body that will be mirrored.  Requests with larger bodies are not
+	// mirrored.  If unset, DefaultMirrorMaxBodySize is used.
+	MaxBodySize int64
+
+	// StripHeaders are additional headers removed from mirrored requests.  Authorization, Proxy-Authorization,
+	// and Cookie are always removed, so that credentials are never sent to the mirror target.
+	StripHeaders []string
+
+	// Timeout is the time limit for each mirrored request.  If unset, DefaultMirrorTimeout is used.
+	Timeout time.Duration
+
+	// Transactor is the HTTP client transaction function used to send mirrored requests.  If unset,
+	// http.DefaultClient.Do is used.
+	Transactor func(*http.Request) (*http.Response, error)
+
+	// Random returns a value in [0.0, 1.0) used to select requests for mirroring.  If unset, rand.Float64 is used.
+	Random func() float64
+
+	// Mirrored is the counter for requests successfully sent to the mirror.  If unset, no such metric is collected.
+	Mirrored metrics.Counter
+
+	// Dropped is the counter for requests selected for mirroring which could not be sent, due to the concurrency
+	// limit, the body size limit, a body the primary handler did not read, or a transaction error.  If unset,
+	// no such metric is collected.
+	Dropped metrics.Counter
+}
+
+func (o MirrorOptions) logger() log.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+
+	return logging.DefaultLogger()
+}
+
+func (o MirrorOptions) maxConcurrency() int {
+	if o.MaxConcurrency > 0 {
+		return o.MaxConcurrency
+	}
+
+	return DefaultMirrorMaxConcurrency
+}
+
+func (o MirrorOptions) maxBodySize() int64 {
+	if o.MaxBodySize > 0 {
+		return o.MaxBodySize
+	}
+
+	return DefaultMirrorMaxBodySize
+}
+
+func (o MirrorOptions) timeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+
+	return DefaultMirrorTimeout
+}
+
+func (o MirrorOptions) transactor() func(*http.Request) (*http.Response, error) {
+	if o.Transactor != nil {
+		return o.Transactor
+	}
+
+	return http.DefaultClient.Do
+}
+
+func (o MirrorOptions) random() func() float64 {
+	if o.Random != nil {
+		return o.Random
+	}
+
+	return rand.Float64
+}
+
+// mirror holds the state for mirroring requests to a single target
+type mirror struct {
+	logger       log.Logger
+	target       *url.URL
+	fraction     float64
+	maxBodySize  int64
+	timeout      time.Duration
+	transactor   func(*http.Request) (*http.Response, error)
+	random       func() float64
+	stripHeaders []string
+	mirrored     metrics.Counter
+	dropped      metrics.Counter
+	slots        chan struct{}
+}
+
+func (m *mirror) drop() {
+	if m.dropped != nil {
+		m.dropped.Add(1.0)
+	}
+}
+
+// teeBody captures a copy of a request body as the primary handler reads it, so that mirroring never delays
+// the primary handler.  Once more than limit bytes have been read, the copy is discarded.
+type teeBody struct {
+	io.ReadCloser
+	limit    int64
+	buffer   bytes.Buffer
+	overflow bool
+	eof      bool
+}
+
+func (tb *teeBody) Read(p []byte) (int, error) {
+	n, err := tb.ReadCloser.Read(p)
+	if n > 0 && !tb.overflow {
+		if int64(tb.buffer.Len()+n) > tb.limit {
+			tb.overflow = true
+			tb.buffer = bytes.Buffer{}
+		} else {
+			tb.buffer.Write(p[:n])
+		}
+	}
+
+	if err == io.EOF {
+		tb.eof = true
+	}
+
+	return n, err
+}
+
+// body returns the captured body, or false if the body was too large or the primary handler did not read all of it
+func (tb *teeBody) body() ([]byte, bool) {
+	if tb.overflow || !tb.eof {
+		return nil, false
+	}
+
+	return tb.buffer.Bytes(), true
+}
+
+// mirrorCopy is the state of a request selected for mirroring, taken before the primary handler can modify the request
+type mirrorCopy struct {
+	method string
+	url    url.URL
+	header http.Header
+	tee    *teeBody
+}
+
+// newCopy records what is needed to mirror a request, and arranges for the body to be captured as the primary
+// handler reads it.  If the request declares a body that is too large, false is returned.
+func (m *mirror) newCopy(request *http.Request) (*mirrorCopy, bool) {
+	if request.ContentLength > m.maxBodySize {
+		return nil, false
+	}
+
+	c := &mirrorCopy{
+		method: request.Method,
+		url:    *request.URL,
+		header: ForwardHeader(request.Header),
+	}
+
+	for _, name := range mirrorCredentialHeaders {
+		c.header.Del(name)
+	}
+
+	for _, name := range m.stripHeaders {
+		c.header.Del(name)
+	}
+
+	if request.Body != nil && request.Body != http.NoBody {
+		c.tee = &teeBody{ReadCloser: request.Body, limit: m.maxBodySize}
+		request.Body = c.tee
+	}
+
+	return c, true
+}
+
+// newRequest creates the mirrored copy of a captured request, sent to the mirror target
+func (m *mirror) newRequest(ctx context.Context, c *mirrorCopy, body []byte) (*http.Request, error) {
+	target := *m.target
+	target.Path = strings.TrimSuffix(target.Path, "/") + c.url.Path
+	target.RawPath = ""
+	target.RawQuery = c.url.RawQuery
+
+	var entity io.Reader
+	if len(body) > 0 {
+		entity = bytes.NewReader(body)
+	}
+
+	request, err := http.NewRequest(c.method, target.String(), entity)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header = c.header
+	request.Header.Set(MirrorHeader, "true")
+	return request.WithContext(ctx), nil
+}
+
+// send transacts a mirrored request, releasing its concurrency slot when finished
+func (m *mirror) send(request *http.Request, cancel func()) {
+	defer func() {
+		cancel()
+		<-m.slots
+	}()
+
+	response, err := m.transactor(request)
+	if err != nil {
+		m.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "mirrored request failed", "url", request.URL.String(), logging.ErrorKey(), err)
+		m.drop()
+		return
+	}
+
+	discardResponse(response)
+	if m.mirrored != nil {
+		m.mirrored.Add(1.0)
+	}
+}
+
+// dispatch sends the mirrored copy of a captured request in the background, if a slot is available.  This
+// happens after the primary handler has finished, once the body it read is known.
+func (m *mirror) dispatch(c *mirrorCopy) {
+	var body []byte
+	if c.tee != nil {
+		var ok bool
+		if body, ok = c.tee.body(); !ok {
+			m.drop()
+			return
+		}
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		m.drop()
+		return
+	}
+
+	// mirrored requests must not be tied to the lifetime of the original request
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	mirrored, err := m.newRequest(ctx, c, body)
+	if err != nil {
+		cancel()
+		<-m.slots
+		m.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to create mirrored request", logging.ErrorKey(), err)
+		m.drop()
+		return
+	}
+
+	go m.send(mirrored, cancel)
+}
+
+// serve runs the primary handler, mirroring the request afterward if it is selected
+func (m *mirror) serve(next http.Handler, response http.ResponseWriter, request *http.Request) {
+	if m.random()*100.0 >= m.fraction {
+		next.ServeHTTP(response, request)
+		return
+	}
+
+	c, ok := m.newCopy(request)
+	if !ok {
+		m.drop()
+		next.ServeHTTP(response, request)
+		return
+	}
+
+	next.ServeHTTP(response, request)
+	m.dispatch(c)
+}
+
+// Mirror returns an Alice-style constructor that asynchronously duplicates a percentage of requests to a mirror
+// target, for traffic capture or validation of staging environments.  Mirroring never affects the primary
+// response: the body is copied as the primary handler reads it, mirrored requests are sent in the background
+// once the primary handler finishes, their responses are discarded, and requests are simply not mirrored when
+// the concurrency or body size limits are exceeded or the primary handler does not read the entire body.
+// Each mirrored request carries MirrorHeader, and credential headers are never mirrored.
+//
+// This is distinct from fanout, which sends a request to several endpoints and returns one of their responses.
+//
+// If o.Percentage is nonpositive, the returned constructor does no decoration.  An error is returned if o.URL
+// is not a valid absolute URL.
+func Mirror(o MirrorOptions) (func(http.Handler) http.Handler, error) {
+	if o.Percentage <= 0.0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+
+	target, err := url.Parse(o.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !target.IsAbs() || len(target.Host) == 0 {
+		return nil, ErrMirrorURLNotAbsolute
+	}
+
+	m := &mirror{
+		logger:       o.logger(),
+		target:       target,
+		fraction:     o.Percentage,
+		maxBodySize:  o.maxBodySize(),
+		timeout:      o.timeout(),
+		transactor:   o.transactor(),
+		random:       o.random(),
+		stripHeaders: o.StripHeaders,
+		mirrored:     o.Mirrored,
+		dropped:      o.Dropped,
+		slots:        make(chan struct{}, o.maxConcurrency()),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			m.serve(next, response, request)
+		})
+	}, nil
+}
@@ -0,0 +1,333 @@
+package xhttp
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mirrorCapture records mirrored requests, along with their bodies
+type mirrorCapture struct {
+	requests chan *http.Request
+	bodies   chan string
+	release  chan struct{}
+	err      error
+}
+
+func newMirrorCapture(err error) *mirrorCapture {
+	return &mirrorCapture{
+		requests: make(chan *http.Request, 10),
+		bodies:   make(chan string, 10),
+		release:  make(chan struct{}),
+		err:      err,
+	}
+}
+
+func (mc *mirrorCapture) transact(request *http.Request) (*http.Response, error) {
+	var body string
+	if request.Body != nil {
+		b, _ := ioutil.ReadAll(request.Body)
+		body = string(b)
+	}
+
+	mc.requests <- request
+	mc.bodies <- body
+	<-mc.release
+
+	if mc.err != nil {
+		return nil, mc.err
+	}
+
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("ignored"))}, nil
+}
+
+// primaryHandler records the body seen by the primary handler
+func primaryHandler(bodies chan<- string) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		var body string
+		if request.Body != nil {
+			b, _ := ioutil.ReadAll(request.Body)
+			body = string(b)
+		}
+
+		bodies <- body
+		response.WriteHeader(299)
+	})
+}
+
+func testMirrorNoPercentage(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		next    = Constant{Code: http.StatusOK}
+	)
+
+	constructor, err := Mirror(MirrorOptions{URL: "not a url"})
+	require.NoError(err)
+	require.NotNil(constructor)
+
+	decorated := constructor(next)
+	assert.NotNil(decorated)
+	assert.Equal(next, decorated)
+}
+
+func testMirrorBadURL(t *testing.T) {
+	assert := assert.New(t)
+	for _, value := range []string{"%%", "/relative/path", ""} {
+		constructor, err := Mirror(MirrorOptions{URL: value, Percentage: 100.0})
+		assert.Nil(constructor)
+		assert.Error(err)
+	}
+}
+
+func testMirrorSelected(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		capture  = newMirrorCapture(nil)
+		primary  = make(chan string, 1)
+		mirrored = generic.NewCounter("mirrored")
+		dropped  = generic.NewCounter("dropped")
+
+		constructor, err = Mirror(MirrorOptions{
+			Logger:       logging.NewTestLogger(nil, t),
+			URL:          "http://staging.example.com:8080/base/",
+			Percentage:   50.0,
+			Transactor:   capture.transact,
+			Random:       func() float64 { return 0.25 },
+			StripHeaders: []string{"X-Secret"},
+			Mirrored:     mirrored,
+			Dropped:      dropped,
+		})
+	)
+
+	require.NoError(err)
+	require.NotNil(constructor)
+
+	var (
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/api/v2/device?foo=bar", strings.NewReader("request body"))
+	)
+
+	request.Header.Set("X-Custom", "value")
+	request.Header.Set("Connection", "close")
+	request.Header.Set("Authorization", "Bearer token")
+	request.Header.Set("Cookie", "session=1")
+	request.Header.Set("X-Secret", "secret")
+	constructor(primaryHandler(primary)).ServeHTTP(response, request)
+	assert.Equal(299, response.Code)
+	assert.Equal("request body", <-primary)
+
+	select {
+	case m := <-capture.requests:
+		assert.Equal("POST", m.Method)
+		assert.Equal("http://staging.example.com:8080/base/api/v2/device?foo=bar", m.URL.String())
+		assert.Equal("value", m.Header.Get("X-Custom"))
+		assert.Empty(m.Header.Get("Connection"))
+		assert.Empty(m.Header.Get("Authorization"))
+		assert.Empty(m.Header.Get("Cookie"))
+		assert.Empty(m.Header.Get("X-Secret"))
+		assert.Equal("Bearer token", request.Header.Get("Authorization"))
+		assert.Equal("true", m.Header.Get(MirrorHeader))
+		assert.Empty(request.Header.Get(MirrorHeader))
+		assert.Equal("request body", <-capture.bodies)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The request was not mirrored")
+	}
+
+	close(capture.release)
+	for i := 0; i < 100 && mirrored.Value() == 0.0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(1.0, mirrored.Value())
+	assert.Zero(dropped.Value())
+}
+
+func testMirrorNotSelected(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		capture = newMirrorCapture(nil)
+		primary = make(chan string, 1)
+
+		constructor, err = Mirror(MirrorOptions{
+			Logger:     logging.NewTestLogger(nil, t),
+			URL:        "http://staging.example.com",
+			Percentage: 50.0,
+			Transactor: capture.transact,
+			Random:     func() float64 { return 0.5 },
+		})
+	)
+
+	require.NoError(err)
+
+	response := httptest.NewRecorder()
+	constructor(primaryHandler(primary)).ServeHTTP(response, httptest.NewRequest("PUT", "/", strings.NewReader("unmirrored")))
+	assert.Equal(299, response.Code)
+	assert.Equal("unmirrored", <-primary)
+	assert.Empty(capture.requests)
+}
+
+func testMirrorConcurrencyLimit(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		capture = newMirrorCapture(nil)
+		primary = make(chan string, 2)
+		dropped = generic.NewCounter("dropped")
+
+		constructor, err = Mirror(MirrorOptions{
+			Logger:         logging.NewTestLogger(nil, t),
+			URL:            "http://staging.example.com",
+			Percentage:     100.0,
+			MaxConcurrency: 1,
+			Transactor:     capture.transact,
+			Dropped:        dropped,
+		})
+	)
+
+	require.NoError(err)
+	handler := constructor(primaryHandler(primary))
+	defer close(capture.release)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/first", strings.NewReader("first")))
+	select {
+	case <-capture.requests:
+	case <-time.After(5 * time.Second):
+		require.Fail("The first request was not mirrored")
+	}
+
+	// the first mirrored request is still in flight, so the second is dropped
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("POST", "/second", strings.NewReader("second")))
+	assert.Equal(299, response.Code)
+	assert.Equal("first", <-primary)
+	assert.Equal("second", <-primary)
+	assert.Equal(1.0, dropped.Value())
+	assert.Empty(capture.requests)
+}
+
+func testMirrorBodyTooLarge(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		capture = newMirrorCapture(nil)
+		primary = make(chan string, 1)
+		dropped = generic.NewCounter("dropped")
+
+		constructor, err = Mirror(MirrorOptions{
+			Logger:      logging.NewTestLogger(nil, t),
+			URL:         "http://staging.example.com",
+			Percentage:  100.0,
+			MaxBodySize: 4,
+			Transactor:  capture.transact,
+			Dropped:     dropped,
+		})
+	)
+
+	require.NoError(err)
+	defer close(capture.release)
+
+	response := httptest.NewRecorder()
+	constructor(primaryHandler(primary)).ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("this body is too large")))
+	assert.Equal(299, response.Code)
+	assert.Equal("this body is too large", <-primary)
+	assert.Equal(1.0, dropped.Value())
+	assert.Empty(capture.requests)
+}
+
+func testMirrorBodyUnread(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		capture = newMirrorCapture(nil)
+		dropped = generic.NewCounter("dropped")
+
+		constructor, err = Mirror(MirrorOptions{
+			Logger:     logging.NewTestLogger(nil, t),
+			URL:        "http://staging.example.com",
+			Percentage: 100.0,
+			Transactor: capture.transact,
+			Dropped:    dropped,
+		})
+	)
+
+	require.NoError(err)
+	defer close(capture.release)
+
+	// the body is only captured as the primary handler reads it, so an unread body cannot be mirrored
+	response := httptest.NewRecorder()
+	constructor(Constant{Code: http.StatusAccepted}).ServeHTTP(response, httptest.NewRequest("POST", "/", strings.NewReader("unread")))
+	assert.Equal(http.StatusAccepted, response.Code)
+	assert.Equal(1.0, dropped.Value())
+	assert.Empty(capture.requests)
+}
+
+func testMirrorTransactorError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		capture  = newMirrorCapture(errors.New("expected"))
+		primary  = make(chan string, 1)
+		mirrored = generic.NewCounter("mirrored")
+		dropped  = generic.NewCounter("dropped")
+
+		constructor, err = Mirror(MirrorOptions{
+			Logger:     logging.NewTestLogger(nil, t),
+			URL:        "http://staging.example.com",
+			Percentage: 100.0,
+			Transactor: capture.transact,
+			Mirrored:   mirrored,
+			Dropped:    dropped,
+		})
+	)
+
+	require.NoError(err)
+
+	response := httptest.NewRecorder()
+	constructor(primaryHandler(primary)).ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+	assert.Empty(<-primary)
+
+	select {
+	case <-capture.requests:
+		assert.Empty(<-capture.bodies)
+	case <-time.After(5 * time.Second):
+		assert.Fail("The request was not mirrored")
+	}
+
+	close(capture.release)
+	for i := 0; i < 100 && dropped.Value() == 0.0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(1.0, dropped.Value())
+	assert.Zero(mirrored.Value())
+}
+
+func TestMirror(t *testing.T) {
+	t.Run("NoPercentage", testMirrorNoPercentage)
+	t.Run("BadURL", testMirrorBadURL)
+	t.Run("Selected", testMirrorSelected)
+	t.Run("NotSelected", testMirrorNotSelected)
+	t.Run("ConcurrencyLimit", testMirrorConcurrencyLimit)
+	t.Run("BodyTooLarge", testMirrorBodyTooLarge)
+	t.Run("BodyUnread", testMirrorBodyUnread)
+	t.Run("TransactorError", testMirrorTransactorError)
+}
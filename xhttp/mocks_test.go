@@ -1,7 +1,9 @@
 package xhttp
 
 import (
+	"bufio"
 	"net"
+	"net/http/httptest"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -56,3 +58,14 @@ func (m *mockListener) Close() error {
 func (m *mockListener) Addr() net.Addr {
 	return m.Called().Get(0).(net.Addr)
 }
+
+// hijackRecorder is an httptest.ResponseRecorder that also implements http.Hijacker
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (hr *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hr.hijacked = true
+	return nil, nil, nil
+}
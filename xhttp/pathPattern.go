@@ -0,0 +1,59 @@
+package xhttp
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PathPattern is a pattern matched against request paths.  A pattern ending in "/" matches every path with that
+// prefix, as with http.ServeMux.  Otherwise, the pattern is matched with path.Match, where "*" matches a single
+// path segment.
+//
+// Request paths are cleaned before they are matched, so that a path such as "/health/../api/secret" is matched
+// as "/api/secret" and cannot use a lenient pattern to reach a strict route.
+type PathPattern string
+
+// Validate checks that this pattern is nonempty and well formed
+func (pp PathPattern) Validate() error {
+	if len(pp) == 0 {
+		return errors.New("A path pattern is required")
+	}
+
+	if _, err := path.Match(string(pp), ""); err != nil {
+		return fmt.Errorf("Invalid path pattern %s: %s", pp, err)
+	}
+
+	return nil
+}
+
+// Matches tests if this pattern matches a request path.  A malformed pattern matches nothing.
+func (pp PathPattern) Matches(requestPath string) bool {
+	requestPath = CleanPath(requestPath)
+	if strings.HasSuffix(string(pp), "/") {
+		return strings.HasPrefix(requestPath, string(pp))
+	}
+
+	matched, _ := path.Match(string(pp), requestPath)
+	return matched
+}
+
+// CleanPath returns the canonical form of a request path, as http.ServeMux does.  The result is rooted and has
+// no "." or ".." elements and no repeated slashes.  A trailing slash is preserved.
+func CleanPath(requestPath string) string {
+	if len(requestPath) == 0 {
+		return "/"
+	}
+
+	if requestPath[0] != '/' {
+		requestPath = "/" + requestPath
+	}
+
+	cleaned := path.Clean(requestPath)
+	if cleaned != "/" && strings.HasSuffix(requestPath, "/") {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
@@ -0,0 +1,63 @@
+package xhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathPatternValidate(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(PathPattern("/api/").Validate())
+	assert.NoError(PathPattern("/api/*/stat").Validate())
+	assert.Error(PathPattern("").Validate())
+	assert.Error(PathPattern("/api/[").Validate())
+}
+
+func TestPathPatternMatches(t *testing.T) {
+	testData := []struct {
+		pattern  PathPattern
+		path     string
+		expected bool
+	}{
+		{"/api/v2/device", "/api/v2/device", true},
+		{"/api/v2/device", "/api/v2/device/mac:112233445566", false},
+		{"/api/v2/device/", "/api/v2/device/mac:112233445566/stat", true},
+		{"/api/v2/device/", "/api/v2/device/", true},
+		{"/api/v2/device/", "/api/v2/devices", false},
+		{"/api/v2/device/*/stat", "/api/v2/device/mac:112233445566/stat", true},
+		{"/api/v2/device/*/stat", "/api/v2/device/mac:112233445566/other", false},
+		{"/health", "/health/../api/secret", false},
+		{"/api/secret", "/health/../api/secret", true},
+		{"/api/", "/health/../api/secret", true},
+		{"/api/secret", "//api//./secret", true},
+		{"/api/[", "/api/[", false},
+	}
+
+	for _, record := range testData {
+		t.Run(string(record.pattern)+" "+record.path, func(t *testing.T) {
+			assert.Equal(t, record.expected, record.pattern.Matches(record.path))
+		})
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	testData := []struct {
+		path     string
+		expected string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"api", "/api"},
+		{"/api/", "/api/"},
+		{"/api/../", "/"},
+		{"/a/./b/../c", "/a/c"},
+		{"//a//b/", "/a/b/"},
+	}
+
+	for _, record := range testData {
+		t.Run(record.path, func(t *testing.T) {
+			assert.Equal(t, record.expected, CleanPath(record.path))
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// PreferHeader is the RFC 7240 header a client uses to state its preferences for request handling
+	PreferHeader = "Prefer"
+
+	// PreferenceAppliedHeader is the RFC 7240 header that indicates which client preferences were honored
+	PreferenceAppliedHeader = "Preference-Applied"
+
+	// RespondAsync is the RFC 7240 preference which requests an immediate 202 response, with the
+	// actual processing performed in the background
+	RespondAsync = "respond-async"
+
+	// Wait is the RFC 7240 preference that gives the number of seconds a client is willing to wait for a response
+	Wait = "wait"
+)
+
+// Preferences parses the RFC 7240 preferences from a set of HTTP headers.  The returned map is keyed by
+// the lowercased preference token, and each value is the preference's value with any quotes removed.
+// Preferences without a value map to the empty string.  Preference parameters are ignored.  If the same
+// preference appears more than once, the first occurrence wins.
+func Preferences(h http.Header) map[string]string {
+	var preferences map[string]string
+	for _, value := range h[PreferHeader] {
+		for _, preference := range strings.Split(value, ",") {
+			if i := strings.IndexByte(preference, ';'); i >= 0 {
+				preference = preference[:i]
+			}
+
+			var (
+				token = preference
+				v     string
+			)
+
+			if i := strings.IndexByte(preference, '='); i >= 0 {
+				token, v = preference[:i], strings.Trim(strings.TrimSpace(preference[i+1:]), `"`)
+			}
+
+			token = strings.ToLower(strings.TrimSpace(token))
+			if len(token) == 0 {
+				continue
+			}
+
+			if preferences == nil {
+				preferences = make(map[string]string)
+			}
+
+			if _, ok := preferences[token]; !ok {
+				preferences[token] = v
+			}
+		}
+	}
+
+	return preferences
+}
+
+// PrefersAsync tests if a set of HTTP headers contains the RespondAsync preference
+func PrefersAsync(h http.Header) bool {
+	_, ok := Preferences(h)[RespondAsync]
+	return ok
+}
+
+// PreferredWait returns the duration of the Wait preference.  If there is no Wait preference, or if its value is
+// not a positive integer number of seconds, this function returns false.  In particular, wait=0 is not a request
+// to wait for no time at all, and so the server's default applies.
+func PreferredWait(h http.Header) (time.Duration, bool) {
+	value, ok := Preferences(h)[Wait]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || seconds == 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+// detachedContext exposes the values of a parent context without its cancellation or deadline
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// Detach returns a context that carries the values of the given context, such as the logger and request ID,
+// but which is never canceled and has no deadline.  This is useful for background work started by an HTTP
+// request that must outlive that request.
+func Detach(parent context.Context) context.Context {
+	return detachedContext{parent}
+}
@@ -0,0 +1,98 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreferences(t *testing.T) {
+	testData := []struct {
+		values   []string
+		expected map[string]string
+	}{
+		{nil, nil},
+		{[]string{""}, nil},
+		{[]string{" , "}, nil},
+		{[]string{"respond-async"}, map[string]string{"respond-async": ""}},
+		{[]string{"Respond-Async, WAIT=10"}, map[string]string{"respond-async": "", "wait": "10"}},
+		{[]string{`return="minimal"; foo=bar`, "wait=5"}, map[string]string{"return": "minimal", "wait": "5"}},
+		{[]string{"wait=5, wait=10"}, map[string]string{"wait": "5"}},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, record.expected, Preferences(http.Header{PreferHeader: record.values}))
+		})
+	}
+}
+
+func TestPrefersAsync(t *testing.T) {
+	testData := []struct {
+		values   []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{""}, false},
+		{[]string{"return=minimal"}, false},
+		{[]string{"respond-async"}, true},
+		{[]string{"Respond-Async"}, true},
+		{[]string{"return=minimal, respond-async"}, true},
+		{[]string{"wait=10", " respond-async ; foo=bar"}, true},
+		{[]string{"respond-asynchronously"}, false},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, record.expected, PrefersAsync(http.Header{PreferHeader: record.values}))
+		})
+	}
+}
+
+func TestPreferredWait(t *testing.T) {
+	testData := []struct {
+		values       []string
+		expected     time.Duration
+		expectedOkay bool
+	}{
+		{nil, 0, false},
+		{[]string{"respond-async"}, 0, false},
+		{[]string{"wait=0"}, 0, false},
+		{[]string{"wait=15"}, 15 * time.Second, true},
+		{[]string{`respond-async, wait="3"`}, 3 * time.Second, true},
+		{[]string{"wait=-1"}, 0, false},
+		{[]string{"wait=abc"}, 0, false},
+	}
+
+	for i, record := range testData {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert := assert.New(t)
+			actual, ok := PreferredWait(http.Header{PreferHeader: record.values})
+			assert.Equal(record.expected, actual)
+			assert.Equal(record.expectedOkay, ok)
+		})
+	}
+}
+
+func TestDetach(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		parent, cancel = context.WithTimeout(context.WithValue(context.Background(), "key", "value"), time.Minute)
+		detached       = Detach(parent)
+	)
+
+	cancel()
+	assert.Error(parent.Err())
+
+	assert.Equal("value", detached.Value("key"))
+	assert.NoError(detached.Err())
+	assert.Nil(detached.Done())
+
+	_, ok := detached.Deadline()
+	assert.False(ok)
+}
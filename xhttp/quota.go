@@ -0,0 +1,327 @@
+package xhttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/store"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// RateLimitLimitHeader is the response header carrying the request limit of the most restrictive quota
+	RateLimitLimitHeader = "X-RateLimit-Limit"
+
+	// RateLimitRemainingHeader is the response header carrying the requests remaining in the most restrictive quota
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+
+	// RateLimitResetHeader is the response header carrying the time, in seconds since the epoch, at which
+	// the current window of the most restrictive quota ends
+	RateLimitResetHeader = "X-RateLimit-Reset"
+
+	// DefaultQuotaKeyPrefix is the prefix for the store.KV keys that hold request counts
+	DefaultQuotaKeyPrefix = "quota/"
+)
+
+// IdentityFunc extracts the identity of the client making a request, e.g. an API key or a JWT subject.
+// If a request has no identity, this function returns false.
+type IdentityFunc func(*http.Request) (string, bool)
+
+// HeaderIdentity returns an IdentityFunc which uses the value of a request header, such as an API key header
+func HeaderIdentity(name string) IdentityFunc {
+	return func(request *http.Request) (string, bool) {
+		value := request.Header.Get(name)
+		return value, len(value) > 0
+	}
+}
+
+// ClientIPIdentity is an IdentityFunc which uses the client IP.  The client IP placed into the request context by
+// the ClientIP constructor is preferred.  Otherwise, the peer address of the request is used.
+func ClientIPIdentity(request *http.Request) (string, bool) {
+	if ip, ok := GetClientIP(request.Context()); ok {
+		return ip.String(), true
+	}
+
+	if ip := parseHost(request.RemoteAddr); ip != nil {
+		return ip.String(), true
+	}
+
+	return "", false
+}
+
+// ContextIdentity adapts a function which reads an identity from a request context, e.g. the subject of a
+// validated JWT, into an IdentityFunc
+func ContextIdentity(f func(context.Context) (string, bool)) IdentityFunc {
+	return func(request *http.Request) (string, bool) {
+		return f(request.Context())
+	}
+}
+
+// FirstIdentity returns an IdentityFunc which uses the first identity found by the given functions, in order
+func FirstIdentity(identities ...IdentityFunc) IdentityFunc {
+	return func(request *http.Request) (string, bool) {
+		for _, f := range identities {
+			if identity, ok := f(request); ok {
+				return identity, true
+			}
+		}
+
+		return "", false
+	}
+}
+
+// QuotaLimit is the maximum number of requests a single identity may make over a period, e.g. 1000 per hour
+type QuotaLimit struct {
+	Period time.Duration
+	Limit  int
+}
+
+// QuotaOptions is the configurable policy for enforcing per-client quotas
+type QuotaOptions struct {
+	// Logger is the go-kit Logger used for logging.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger
+
+	// KV holds the request counts.  Using a shared store, such as one created with store.NewConsulKV, applies
+	// quotas across all instances of a service.  This field is required.
+	KV store.KV
+
+	// KeyPrefix is prepended to each key in the KV.  If unset, DefaultQuotaKeyPrefix is used.
+	KeyPrefix string
+
+	// Identity extracts the client identity of each request.  Requests without an identity are not subject
+	// to quotas.  If unset, ClientIPIdentity is used.
+	Identity IdentityFunc
+
+	// Limits are the quotas enforced for each identity, e.g. an hourly and a daily limit.  Limits with
+	// a nonpositive Period or Limit are ignored.
+	Limits []QuotaLimit
+
+	// Now is the source of the current time.  If unset, time.Now is used.
+	Now func() time.Time
+
+	// Exceeded is the counter for requests rejected because a quota was exceeded.  If unset, no such metric is collected.
+	Exceeded metrics.Counter
+}
+
+func (o QuotaOptions) logger() log.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+
+	return logging.DefaultLogger()
+}
+
+func (o QuotaOptions) keyPrefix() string {
+	if len(o.KeyPrefix) > 0 {
+		return o.KeyPrefix
+	}
+
+	return DefaultQuotaKeyPrefix
+}
+
+func (o QuotaOptions) identity() IdentityFunc {
+	if o.Identity != nil {
+		return o.Identity
+	}
+
+	return ClientIPIdentity
+}
+
+func (o QuotaOptions) limits() []QuotaLimit {
+	var limits []QuotaLimit
+	for _, l := range o.Limits {
+		if l.Period > 0 && l.Limit > 0 {
+			limits = append(limits, l)
+		}
+	}
+
+	return limits
+}
+
+func (o QuotaOptions) now() func() time.Time {
+	if o.Now != nil {
+		return o.Now
+	}
+
+	return time.Now
+}
+
+// quotaWindow is the state of a single QuotaLimit for an identity at a point in time
+type quotaWindow struct {
+	limit     QuotaLimit
+	key       string
+	count     int
+	remaining int
+	reset     time.Time
+}
+
+// quota enforces a set of limits against request counts held in a store.KV
+type quota struct {
+	logger   log.Logger
+	kv       store.KV
+	prefix   string
+	identity IdentityFunc
+	limits   []QuotaLimit
+	now      func() time.Time
+	exceeded metrics.Counter
+
+	// locks serialize the read-modify-write of each identity's counts made by this process, while requests
+	// from different identities proceed in parallel.  Counts shared with other processes through the KV are
+	// approximate, since a store.KV has no atomic increment.
+	locks concurrent.KeyedMutex
+}
+
+// hashIdentity produces the form of an identity used in KV keys.  Identities such as API keys are secrets, and
+// arbitrary identities could otherwise inject path separators into keys, so only a hash is ever stored.
+func hashIdentity(identity string) string {
+	sum := sha256.Sum256([]byte(identity))
+	return hex.EncodeToString(sum[:])
+}
+
+// count reads a request count, treating missing or unparseable values as zero
+func (q *quota) count(key string) int {
+	value, err := q.kv.Get(key)
+	if err != nil {
+		if err != store.ErrNotFound {
+			q.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to read quota count", "key", key, logging.ErrorKey(), err)
+		}
+
+		return 0
+	}
+
+	count, _ := strconv.Atoi(string(value))
+	return count
+}
+
+// window computes the state of a limit for a hashed identity.  Counts are kept in fixed windows aligned to the limit's
+// period, and the rolling count is estimated by weighting the previous window's count by how much of it still
+// overlaps the rolling period.
+func (q *quota) window(identity string, limit QuotaLimit, now time.Time) quotaWindow {
+	var (
+		start    = now.Truncate(limit.Period)
+		base     = q.prefix + identity + "/" + limit.Period.String() + "/"
+		key      = base + strconv.FormatInt(start.Unix(), 10)
+		current  = q.count(key)
+		previous = q.count(base + strconv.FormatInt(start.Add(-limit.Period).Unix(), 10))
+		overlap  = 1.0 - float64(now.Sub(start))/float64(limit.Period)
+		rolling  = int(math.Floor(float64(previous)*overlap)) + current
+	)
+
+	remaining := limit.Limit - rolling
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return quotaWindow{
+		limit:     limit,
+		key:       key,
+		count:     current,
+		remaining: remaining,
+		reset:     start.Add(limit.Period),
+	}
+}
+
+// serve checks and updates the quotas for a request, writing the quota headers to the response.  This method
+// returns false if the request exceeds any quota, in which case nothing is counted.
+func (q *quota) serve(response http.ResponseWriter, identity string) (time.Duration, bool) {
+	identity = hashIdentity(identity)
+	q.locks.Lock(identity)
+	defer q.locks.Unlock(identity)
+
+	var (
+		now         = q.now()
+		windows     = make([]quotaWindow, len(q.limits))
+		restrictive = 0
+	)
+
+	for i, limit := range q.limits {
+		windows[i] = q.window(identity, limit, now)
+		if windows[i].remaining < windows[restrictive].remaining {
+			restrictive = i
+		}
+	}
+
+	var (
+		header = response.Header()
+		w      = windows[restrictive]
+	)
+
+	header.Set(RateLimitLimitHeader, strconv.Itoa(w.limit.Limit))
+	header.Set(RateLimitResetHeader, strconv.FormatInt(w.reset.Unix(), 10))
+	if w.remaining < 1 {
+		header.Set(RateLimitRemainingHeader, "0")
+		return w.reset.Sub(now), false
+	}
+
+	header.Set(RateLimitRemainingHeader, strconv.Itoa(w.remaining-1))
+	for _, w := range windows {
+		// counts must outlive their window, since they are used to estimate the next window's rolling count
+		if err := q.kv.Put(w.key, []byte(strconv.Itoa(w.count+1)), 2*w.limit.Period); err != nil {
+			q.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to update quota count", "key", w.key, logging.ErrorKey(), err)
+		}
+	}
+
+	return 0, true
+}
+
+// Quota returns an Alice-style constructor that enforces per-client request quotas.  Each request is attributed
+// to an identity, and rolling request counts for each identity are checked against every configured limit.
+// Identities are hashed before they are used in KV keys, so the KV never holds client secrets such as API keys.
+// A request which exceeds any limit is rejected with http.StatusTooManyRequests and a Retry-After header.
+// Every response carries the X-RateLimit-* headers describing the most restrictive limit.
+//
+// Quotas fail open: if the KV cannot be read or written, requests are allowed.  If o.KV is nil, this function
+// panics.  If no valid limits are configured, the returned constructor does no decoration.
+func Quota(o QuotaOptions) func(http.Handler) http.Handler {
+	if o.KV == nil {
+		panic("A store.KV is required")
+	}
+
+	limits := o.limits()
+	if len(limits) == 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	q := &quota{
+		logger:   o.logger(),
+		kv:       o.KV,
+		prefix:   o.keyPrefix(),
+		identity: o.identity(),
+		limits:   limits,
+		now:      o.now(),
+		exceeded: o.Exceeded,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			identity, ok := q.identity(request)
+			if !ok {
+				next.ServeHTTP(response, request)
+				return
+			}
+
+			if retryAfter, ok := q.serve(response, identity); !ok {
+				if q.exceeded != nil {
+					q.exceeded.Add(1.0)
+				}
+
+				SetRetryAfter(response.Header(), retryAfter)
+				WriteErrorf(response, http.StatusTooManyRequests, "Quota exceeded")
+				return
+			}
+
+			next.ServeHTTP(response, request)
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/store"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingKV is a store.KV whose operations always fail
+type failingKV struct{}
+
+func (failingKV) Get(string) ([]byte, error)              { return nil, errors.New("expected") }
+func (failingKV) Put(string, []byte, time.Duration) error { return errors.New("expected") }
+func (failingKV) Delete(string) error                     { return errors.New("expected") }
+func (failingKV) List(string) ([]store.Entry, error)      { return nil, errors.New("expected") }
+
+func TestHeaderIdentity(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		identity = HeaderIdentity("X-Api-Key")
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	value, ok := identity(request)
+	assert.Empty(value)
+	assert.False(ok)
+
+	request.Header.Set("X-Api-Key", "key")
+	value, ok = identity(request)
+	assert.Equal("key", value)
+	assert.True(ok)
+}
+
+func TestClientIPIdentity(t *testing.T) {
+	assert := assert.New(t)
+
+	request := httptest.NewRequest("GET", "/", nil)
+	request.RemoteAddr = "10.1.1.1:1234"
+	value, ok := ClientIPIdentity(request)
+	assert.Equal("10.1.1.1", value)
+	assert.True(ok)
+
+	request = request.WithContext(WithClientIP(request.Context(), net.ParseIP("192.168.1.1")))
+	value, ok = ClientIPIdentity(request)
+	assert.Equal("192.168.1.1", value)
+	assert.True(ok)
+
+	request = httptest.NewRequest("GET", "/", nil)
+	request.RemoteAddr = "garbage"
+	value, ok = ClientIPIdentity(request)
+	assert.Empty(value)
+	assert.False(ok)
+}
+
+func TestContextIdentity(t *testing.T) {
+	type subjectKey struct{}
+
+	var (
+		assert   = assert.New(t)
+		identity = ContextIdentity(func(ctx context.Context) (string, bool) {
+			subject, ok := ctx.Value(subjectKey{}).(string)
+			return subject, ok
+		})
+
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	value, ok := identity(request)
+	assert.Empty(value)
+	assert.False(ok)
+
+	value, ok = identity(request.WithContext(context.WithValue(request.Context(), subjectKey{}, "subject")))
+	assert.Equal("subject", value)
+	assert.True(ok)
+}
+
+func TestFirstIdentity(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		identity = FirstIdentity(HeaderIdentity("X-Api-Key"), HeaderIdentity("X-Client"))
+		request  = httptest.NewRequest("GET", "/", nil)
+	)
+
+	value, ok := identity(request)
+	assert.Empty(value)
+	assert.False(ok)
+
+	request.Header.Set("X-Client", "client")
+	value, ok = identity(request)
+	assert.Equal("client", value)
+	assert.True(ok)
+
+	request.Header.Set("X-Api-Key", "key")
+	value, ok = identity(request)
+	assert.Equal("key", value)
+	assert.True(ok)
+}
+
+func testQuotaNoKV(t *testing.T) {
+	assert.Panics(t, func() {
+		Quota(QuotaOptions{Limits: []QuotaLimit{{Period: time.Hour, Limit: 1}}})
+	})
+}
+
+func testQuotaNoLimits(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		next   = Constant{Code: http.StatusOK}
+	)
+
+	for _, limits := range [][]QuotaLimit{nil, {{Period: 0, Limit: 10}, {Period: time.Hour, Limit: 0}}} {
+		constructor := Quota(QuotaOptions{KV: store.NewMemoryKV(store.MemoryKVOptions{}), Limits: limits})
+		assert.Equal(next, constructor(next))
+	}
+}
+
+func testQuotaNoIdentity(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		handler = Quota(QuotaOptions{
+			KV:       store.NewMemoryKV(store.MemoryKVOptions{}),
+			Identity: HeaderIdentity("X-Api-Key"),
+			Limits:   []QuotaLimit{{Period: time.Hour, Limit: 1}},
+		})(Constant{Code: http.StatusOK})
+	)
+
+	for i := 0; i < 3; i++ {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(http.StatusOK, response.Code)
+		assert.Empty(response.Header().Get(RateLimitLimitHeader))
+	}
+}
+
+func testQuotaEnforced(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		start    = time.Date(2018, time.June, 1, 10, 0, 0, 0, time.UTC)
+		now      = start
+		clock    = func() time.Time { return now }
+		exceeded = generic.NewCounter("exceeded")
+
+		handler = Quota(QuotaOptions{
+			Logger:   logging.NewTestLogger(nil, t),
+			KV:       store.NewMemoryKV(store.MemoryKVOptions{Now: clock}),
+			Identity: HeaderIdentity("X-Api-Key"),
+			Limits: []QuotaLimit{
+				{Period: 24 * time.Hour, Limit: 5},
+				{Period: time.Hour, Limit: 3},
+			},
+			Now:      clock,
+			Exceeded: exceeded,
+		})(Constant{Code: http.StatusOK})
+
+		serve = func(key string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.Header.Set("X-Api-Key", key)
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+			return response
+		}
+	)
+
+	// the hourly limit is the most restrictive
+	for i := 0; i < 3; i++ {
+		response := serve("first")
+		require.Equal(http.StatusOK, response.Code)
+		assert.Equal("3", response.Header().Get(RateLimitLimitHeader))
+		assert.Equal(strconv.Itoa(2-i), response.Header().Get(RateLimitRemainingHeader))
+		assert.Equal(strconv.FormatInt(start.Add(time.Hour).Unix(), 10), response.Header().Get(RateLimitResetHeader))
+	}
+
+	now = start.Add(30 * time.Minute)
+	response := serve("first")
+	assert.Equal(http.StatusTooManyRequests, response.Code)
+	assert.Equal("0", response.Header().Get(RateLimitRemainingHeader))
+	assert.Equal("1800", response.Header().Get(RetryAfterHeader))
+	assert.Equal(1.0, exceeded.Value())
+
+	// identities are independent
+	response = serve("second")
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("2", response.Header().Get(RateLimitRemainingHeader))
+
+	// a quarter into the next hour, three quarters of the previous hour's count remain in the rolling period
+	now = start.Add(time.Hour + 15*time.Minute)
+	response = serve("first")
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("3", response.Header().Get(RateLimitLimitHeader))
+	assert.Equal("0", response.Header().Get(RateLimitRemainingHeader))
+
+	response = serve("first")
+	assert.Equal(http.StatusTooManyRequests, response.Code)
+	assert.Equal(2.0, exceeded.Value())
+
+	// once the hourly counts have rolled off, the daily limit is the most restrictive
+	now = start.Add(3 * time.Hour)
+	response = serve("first")
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("5", response.Header().Get(RateLimitLimitHeader))
+	assert.Equal("0", response.Header().Get(RateLimitRemainingHeader))
+
+	response = serve("first")
+	assert.Equal(http.StatusTooManyRequests, response.Code)
+	assert.Equal("5", response.Header().Get(RateLimitLimitHeader))
+	assert.Equal(strconv.FormatInt(start.Add(14*time.Hour).Unix(), 10), response.Header().Get(RateLimitResetHeader))
+	assert.Equal(3.0, exceeded.Value())
+}
+
+func testQuotaHashedKeys(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		kv      = store.NewMemoryKV(store.MemoryKVOptions{})
+		handler = Quota(QuotaOptions{
+			KV:       kv,
+			Identity: HeaderIdentity("X-Api-Key"),
+			Limits:   []QuotaLimit{{Period: time.Hour, Limit: 10}},
+		})(Constant{Code: http.StatusOK})
+
+		request = httptest.NewRequest("GET", "/", nil)
+	)
+
+	request.Header.Set("X-Api-Key", "secret/../key")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	entries, err := kv.List("")
+	require.NoError(err)
+	require.Len(entries, 1)
+	assert.NotContains(entries[0].Key, "secret")
+	assert.Contains(entries[0].Key, DefaultQuotaKeyPrefix+hashIdentity("secret/../key")+"/")
+}
+
+func testQuotaConcurrent(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		handler = Quota(QuotaOptions{
+			KV:       store.NewMemoryKV(store.MemoryKVOptions{}),
+			Identity: HeaderIdentity("X-Api-Key"),
+			Limits:   []QuotaLimit{{Period: time.Hour, Limit: 50}},
+		})(Constant{Code: http.StatusOK})
+
+		waitGroup sync.WaitGroup
+		lock      sync.Mutex
+		allowed   = make(map[string]int)
+	)
+
+	for i := 0; i < 20; i++ {
+		key := "key" + strconv.Itoa(i%2)
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for j := 0; j < 10; j++ {
+				request := httptest.NewRequest("GET", "/", nil)
+				request.Header.Set("X-Api-Key", key)
+				response := httptest.NewRecorder()
+				handler.ServeHTTP(response, request)
+
+				if response.Code == http.StatusOK {
+					lock.Lock()
+					allowed[key]++
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+
+	// no increments are lost, so each identity is allowed exactly its limit
+	waitGroup.Wait()
+	assert.Equal(map[string]int{"key0": 50, "key1": 50}, allowed)
+}
+
+func testQuotaFailOpen(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		handler = Quota(QuotaOptions{
+			Logger: logging.NewTestLogger(nil, t),
+			KV:     failingKV{},
+			Limits: []QuotaLimit{{Period: time.Hour, Limit: 1}},
+		})(Constant{Code: http.StatusOK})
+	)
+
+	for i := 0; i < 3; i++ {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(http.StatusOK, response.Code)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	t.Run("NoKV", testQuotaNoKV)
+	t.Run("NoLimits", testQuotaNoLimits)
+	t.Run("NoIdentity", testQuotaNoIdentity)
+	t.Run("Enforced", testQuotaEnforced)
+	t.Run("HashedKeys", testQuotaHashedKeys)
+	t.Run("Concurrent", testQuotaConcurrent)
+	t.Run("FailOpen", testQuotaFailOpen)
+}
@@ -0,0 +1,102 @@
+package xhttp
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	// RequestIDHeader is the HTTP header used to carry a request ID, both inbound and outbound
+	RequestIDHeader = "X-Request-Id"
+
+	// MaxRequestIDLength is the maximum length of an inbound request ID
+	MaxRequestIDLength = 128
+)
+
+type requestIDContextKey struct{}
+
+var requestIDKey interface{} = "requestID"
+
+// RequestIDKey returns the contextual logging key for a request ID
+func RequestIDKey() interface{} {
+	return requestIDKey
+}
+
+// WithRequestID returns a new context with the given request ID attached
+func WithRequestID(parent context.Context, requestID string) context.Context {
+	return context.WithValue(parent, requestIDContextKey{}, requestID)
+}
+
+// GetRequestID returns the request ID from the context, if one is present
+func GetRequestID(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDContextKey{}).(string)
+	return requestID, ok
+}
+
+// NewRequestID generates a random, version 4 UUID suitable for use as a request ID
+func NewRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// ValidRequestID tests if an inbound request ID is safe to log and echo back to clients.  A valid request ID is
+// nonempty, no longer than MaxRequestIDLength, and consists only of ASCII letters, digits, '-', '_', '.', and ':'.
+func ValidRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > MaxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(requestID); i++ {
+		switch c := requestID[i]; {
+		case c >= 'a' && c <= 'z':
+		case c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// RequestID returns an Alice-style constructor that ensures every request has an ID.  An inbound RequestIDHeader
+// is used if it passes ValidRequestID, otherwise the generator is used to create one.  If generator is nil,
+// NewRequestID is used.
+//
+// The request ID is placed into the request context, where it can be retrieved with GetRequestID, and is
+// added to the context logger under RequestIDKey.  The request ID is also written as a response header.
+func RequestID(generator func() string) func(http.Handler) http.Handler {
+	if generator == nil {
+		generator = NewRequestID
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			requestID := request.Header.Get(RequestIDHeader)
+			if !ValidRequestID(requestID) {
+				requestID = generator()
+			}
+
+			ctx := WithRequestID(request.Context(), requestID)
+			ctx = logging.WithLogger(
+				ctx,
+				log.With(logging.GetLogger(ctx), requestIDKey, requestID),
+			)
+
+			response.Header().Set(RequestIDHeader, requestID)
+			next.ServeHTTP(response, request.WithContext(ctx))
+		})
+	}
+}
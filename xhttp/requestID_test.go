@@ -0,0 +1,111 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDKey(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(requestIDKey, RequestIDKey())
+}
+
+func TestGetRequestID(t *testing.T) {
+	assert := assert.New(t)
+
+	requestID, ok := GetRequestID(context.Background())
+	assert.Empty(requestID)
+	assert.False(ok)
+
+	requestID, ok = GetRequestID(WithRequestID(context.Background(), "test"))
+	assert.Equal("test", requestID)
+	assert.True(ok)
+}
+
+func TestNewRequestID(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		uuid   = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+		first  = NewRequestID()
+		second = NewRequestID()
+	)
+
+	assert.Regexp(uuid, first)
+	assert.Regexp(uuid, second)
+	assert.NotEqual(first, second)
+}
+
+func testRequestID(t *testing.T, generator func() string, inbound, expected string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+
+		nextCalled = false
+		next       = http.HandlerFunc(func(_ http.ResponseWriter, actual *http.Request) {
+			nextCalled = true
+			requestID, ok := GetRequestID(actual.Context())
+			assert.True(ok)
+			if len(expected) > 0 {
+				assert.Equal(expected, requestID)
+			} else {
+				assert.NotEmpty(requestID)
+			}
+
+			assert.NotNil(logging.GetLogger(actual.Context()))
+		})
+
+		constructor = RequestID(generator)
+	)
+
+	if len(inbound) > 0 {
+		request.Header.Set(RequestIDHeader, inbound)
+	}
+
+	require.NotNil(constructor)
+	decorated := constructor(next)
+	require.NotNil(decorated)
+
+	decorated.ServeHTTP(response, request)
+	assert.True(nextCalled)
+	if len(expected) > 0 {
+		assert.Equal(expected, response.HeaderMap.Get(RequestIDHeader))
+	} else {
+		assert.NotEmpty(response.HeaderMap.Get(RequestIDHeader))
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	generator := func() string { return "generated" }
+
+	t.Run("DefaultGenerator", func(t *testing.T) { testRequestID(t, nil, "", "") })
+	t.Run("CustomGenerator", func(t *testing.T) { testRequestID(t, generator, "", "generated") })
+	t.Run("Inbound", func(t *testing.T) { testRequestID(t, generator, "inbound", "inbound") })
+	t.Run("InvalidInbound", func(t *testing.T) { testRequestID(t, generator, "bad\tid=injected", "generated") })
+	t.Run("TooLongInbound", func(t *testing.T) {
+		testRequestID(t, generator, strings.Repeat("x", MaxRequestIDLength+1), "generated")
+	})
+}
+
+func TestValidRequestID(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, valid := range []string{"a", NewRequestID(), "trace:ABC_123.4-5", strings.Repeat("x", MaxRequestIDLength)} {
+		assert.True(ValidRequestID(valid), valid)
+	}
+
+	for _, invalid := range []string{"", " ", "has space", "new\nline", "quote\"", "key=value", "caf\u00e9", strings.Repeat("x", MaxRequestIDLength+1)} {
+		assert.False(ValidRequestID(invalid), invalid)
+	}
+}
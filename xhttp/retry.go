@@ -1,6 +1,9 @@
 package xhttp
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"time"
 
@@ -11,7 +14,12 @@ import (
 	"github.com/go-kit/kit/metrics/discard"
 )
 
-const DefaultRetryInterval = time.Second
+const (
+	DefaultRetryInterval = time.Second
+
+	// DefaultMaxRetryAfter is the longest Retry-After that is waited out when RetryOptions.MaxRetryAfter is unset
+	DefaultMaxRetryAfter = 30 * time.Second
+)
 
 // temporaryError is the expected interface for a (possibly) temporary error.
 // Several of the error types in the net package implicitely implement this interface,
@@ -35,6 +43,15 @@ func DefaultShouldRetry(err error) bool {
 	return false
 }
 
+// ShouldRetryStatusFunc is a predicate for determining if an HTTP response with the given status code should be retried
+type ShouldRetryStatusFunc func(int) bool
+
+// RetryableStatus is a ShouldRetryStatusFunc that returns true for the status codes servers use for backpressure,
+// http.StatusTooManyRequests and http.StatusServiceUnavailable.
+func RetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
 // RetryOptions are the configuration options for a retry transactor
 type RetryOptions struct {
 	// Logger is the go-kit logger to use.  Defaults to logging.DefaultLogger() if unset.
@@ -46,7 +63,8 @@ type RetryOptions struct {
 	// Interval is the time between retries.  If not set, DefaultRetryInterval is used.
 	Interval time.Duration
 
-	// Sleep is function used to wait out a duration.  If unset, time.Sleep is used.
+	// Sleep is function used to wait out a duration.  If unset, the wait ends early if the request's context
+	// is canceled, in which case the context's error is returned without retrying.
 	Sleep func(time.Duration)
 
 	// ShouldRetry is the retry predicate.  Defaults to DefaultShouldRetry if unset.
@@ -54,6 +72,35 @@ type RetryOptions struct {
 
 	// Counter is the counter for total retries.  If unset, no metrics are collected on retries.
 	Counter metrics.Counter
+
+	// ShouldRetryStatus is the retry predicate for responses.  If unset, responses are never retried, only errors.
+	// When a response is retried, any Retry-After header it carries is waited out instead of Interval.
+	ShouldRetryStatus ShouldRetryStatusFunc
+
+	// MaxRetryAfter is the longest Retry-After that will be waited out.  A response asking for a longer wait
+	// is returned to the caller without retrying.  If unset, DefaultMaxRetryAfter is used.
+	MaxRetryAfter time.Duration
+}
+
+// discardResponse drains and closes a response that is about to be retried, so that its connection can be reused
+func discardResponse(response *http.Response) {
+	if response != nil && response.Body != nil {
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+	}
+}
+
+// sleepContext waits out a duration, returning early with the context's error if the context is canceled first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // RetryTransactor returns an HTTP transactor function, of the same signature as http.Client.Do, that
@@ -82,8 +129,34 @@ func RetryTransactor(o RetryOptions, next func(*http.Request) (*http.Response, e
 		o.Interval = DefaultRetryInterval
 	}
 
-	if o.Sleep == nil {
-		o.Sleep = time.Sleep
+	if o.MaxRetryAfter < 1 {
+		o.MaxRetryAfter = DefaultMaxRetryAfter
+	}
+
+	sleep := sleepContext
+	if o.Sleep != nil {
+		sleep = func(_ context.Context, d time.Duration) error {
+			o.Sleep(d)
+			return nil
+		}
+	}
+
+	// wait computes how long to wait before the next retry, returning false if no retry should be made
+	wait := func(response *http.Response, err error) (time.Duration, bool) {
+		if err != nil {
+			return o.Interval, o.ShouldRetry(err)
+		}
+
+		if response == nil || o.ShouldRetryStatus == nil || !o.ShouldRetryStatus(response.StatusCode) {
+			return 0, false
+		}
+
+		retryAfter, ok := ParseRetryAfter(response.Header.Get(RetryAfterHeader), time.Now())
+		if !ok {
+			return o.Interval, true
+		}
+
+		return retryAfter, retryAfter <= o.MaxRetryAfter
 	}
 
 	return func(request *http.Request) (*http.Response, error) {
@@ -94,10 +167,23 @@ func RetryTransactor(o RetryOptions, next func(*http.Request) (*http.Response, e
 		// initial attempt:
 		response, err := next(request)
 
-		for r := 0; err != nil && r < o.Retries && o.ShouldRetry(err); r++ {
+		for r := 0; r < o.Retries; r++ {
+			interval, retry := wait(response, err)
+			if !retry {
+				break
+			}
+
 			o.Counter.Add(1.0)
-			o.Sleep(o.Interval)
-			o.Logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "retrying HTTP transaction", "url", request.URL.String(), logging.ErrorKey(), err, "retry", r+1)
+			if err == nil {
+				o.Logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "retrying HTTP transaction", "url", request.URL.String(), "statusCode", response.StatusCode, "retryAfter", interval, "retry", r+1)
+				discardResponse(response)
+			} else {
+				o.Logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "retrying HTTP transaction", "url", request.URL.String(), logging.ErrorKey(), err, "retry", r+1)
+			}
+
+			if err := sleep(request.Context(), interval); err != nil {
+				return nil, err
+			}
 
 			if err := Rewind(request); err != nil {
 				return nil, err
@@ -113,3 +199,35 @@ func RetryTransactor(o RetryOptions, next func(*http.Request) (*http.Response, e
 		return response, err
 	}
 }
+
+// roundTripperFunc adapts a transactor function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (rtf roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return rtf(request)
+}
+
+// RetryRoundTripper decorates an http.RoundTripper with RetryTransactor.  This allows an http.Client to retry
+// transparently, including waiting out any Retry-After sent by servers applying backpressure when o.ShouldRetryStatus
+// is set.  If next is nil, http.DefaultTransport is used.
+//
+// As required of an http.RoundTripper, the caller's request is never modified.  Each attempt uses its own copy.
+func RetryRoundTripper(o RetryOptions, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	// a transport may still refer to the request of a previous attempt, so each attempt is sent as a new copy
+	retry := RetryTransactor(o, func(attempt *http.Request) (*http.Response, error) {
+		return next.RoundTrip(attempt.Clone(attempt.Context()))
+	})
+
+	return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		if request.Body != nil && request.GetBody == nil {
+			// the body is buffered by the retry logic, so the caller's body is no longer needed once it has been read
+			defer request.Body.Close()
+		}
+
+		return retry(request.Clone(request.Context()))
+	})
+}
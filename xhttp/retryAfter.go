@@ -0,0 +1,86 @@
+package xhttp
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryAfterHeader is the standard HTTP header that tells clients how long to wait before retrying a request
+const RetryAfterHeader = "Retry-After"
+
+// RetryAfterFunc computes how long a client should wait before retrying, typically from the current state
+// of a rate limiter or gate
+type RetryAfterFunc func() time.Duration
+
+// FormatRetryAfter formats a duration as the delta-seconds form of a Retry-After header.  Durations are
+// rounded up to the nearest second, and nonpositive durations produce "0".
+func FormatRetryAfter(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+
+	seconds := d / time.Second
+	if d%time.Second != 0 {
+		seconds++
+	}
+
+	return strconv.FormatInt(int64(seconds), 10)
+}
+
+// SetRetryAfter sets the Retry-After header if d is positive
+func SetRetryAfter(h http.Header, d time.Duration) {
+	if d > 0 {
+		h.Set(RetryAfterHeader, FormatRetryAfter(d))
+	}
+}
+
+// ParseRetryAfter parses a Retry-After header value, which is either a number of seconds or an HTTP date.
+// A date is converted into a duration relative to now, and dates in the past produce a zero duration.
+// This function returns false if the value is not a valid Retry-After.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// NewRetryAfterError creates an Error with the given status code, typically http.StatusTooManyRequests or
+// http.StatusServiceUnavailable, that carries a Retry-After header.  go-kit error encoders that honor Headerer
+// will write the header.
+func NewRetryAfterError(code int, retryAfter time.Duration, text string) *Error {
+	header := make(http.Header)
+	SetRetryAfter(header, retryAfter)
+
+	return &Error{
+		Code:   code,
+		Header: header,
+		Text:   text,
+	}
+}
+
+// RetryAfterHandler returns an http.Handler that rejects every request with the given status code and a Retry-After
+// header computed by retryAfter at the time of each request.  This is useful as the handler for a closed gate or
+// an exhausted rate limiter.  If retryAfter is nil, no Retry-After header is written.
+func RetryAfterHandler(code int, retryAfter RetryAfterFunc) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		if retryAfter != nil {
+			SetRetryAfter(response.Header(), retryAfter())
+		}
+
+		WriteErrorf(response, code, "%s", http.StatusText(code))
+	})
+}
@@ -0,0 +1,107 @@
+package xhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatRetryAfter(t *testing.T) {
+	testData := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{-time.Second, "0"},
+		{0, "0"},
+		{time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{2 * time.Minute, "120"},
+	}
+
+	for _, record := range testData {
+		t.Run(record.duration.String(), func(t *testing.T) {
+			assert.Equal(t, record.expected, FormatRetryAfter(record.duration))
+		})
+	}
+}
+
+func TestSetRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	header := make(http.Header)
+	SetRetryAfter(header, 0)
+	assert.Empty(header)
+
+	SetRetryAfter(header, 30*time.Second)
+	assert.Equal("30", header.Get(RetryAfterHeader))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	var (
+		now      = time.Date(2018, time.March, 1, 12, 0, 0, 0, time.UTC)
+		testData = []struct {
+			value      string
+			expected   time.Duration
+			expectedOK bool
+		}{
+			{"", 0, false},
+			{"garbage", 0, false},
+			{"-1", 0, false},
+			{"0", 0, true},
+			{"120", 2 * time.Minute, true},
+			{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+			{now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+		}
+	)
+
+	for _, record := range testData {
+		t.Run(record.value, func(t *testing.T) {
+			assert := assert.New(t)
+			actual, ok := ParseRetryAfter(record.value, now)
+			assert.Equal(record.expected, actual)
+			assert.Equal(record.expectedOK, ok)
+		})
+	}
+}
+
+func TestNewRetryAfterError(t *testing.T) {
+	assert := assert.New(t)
+
+	err := NewRetryAfterError(http.StatusTooManyRequests, 5*time.Second, "slow down")
+	assert.Equal(http.StatusTooManyRequests, err.StatusCode())
+	assert.Equal("5", err.Headers().Get(RetryAfterHeader))
+	assert.Equal("slow down", err.Error())
+
+	err = NewRetryAfterError(http.StatusServiceUnavailable, 0, "unavailable")
+	assert.Empty(err.Headers().Get(RetryAfterHeader))
+}
+
+func TestRetryAfterHandler(t *testing.T) {
+	t.Run("WithRetryAfter", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+			handler  = RetryAfterHandler(http.StatusTooManyRequests, func() time.Duration { return 2500 * time.Millisecond })
+		)
+
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(http.StatusTooManyRequests, response.Code)
+		assert.Equal("3", response.Header().Get(RetryAfterHeader))
+		assert.Equal("application/json", response.Header().Get("Content-Type"))
+	})
+
+	t.Run("NilRetryAfter", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+		)
+
+		RetryAfterHandler(http.StatusServiceUnavailable, nil).ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(http.StatusServiceUnavailable, response.Code)
+		assert.Empty(response.Header().Get(RetryAfterHeader))
+	})
+}
@@ -1,6 +1,8 @@
 package xhttp
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -206,6 +208,156 @@ func testRetryTransactorRewindError(t *testing.T) {
 	assert.Equal(expectedError, actualError)
 }
 
+func testRetryTransactorStatus(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		counter = generic.NewCounter("test")
+
+		responses = []*http.Response{
+			{StatusCode: http.StatusTooManyRequests, Header: http.Header{RetryAfterHeader: []string{"7"}}, Body: ioutil.NopCloser(new(bytes.Buffer))},
+			{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}},
+			{StatusCode: http.StatusOK, Header: http.Header{}},
+		}
+
+		transactorCount = 0
+		transactor      = func(*http.Request) (*http.Response, error) {
+			response := responses[transactorCount]
+			transactorCount++
+			return response, nil
+		}
+
+		slept []time.Duration
+		retry = RetryTransactor(
+			RetryOptions{
+				Logger:            logging.NewTestLogger(nil, t),
+				Retries:           5,
+				Counter:           counter,
+				Interval:          time.Minute,
+				ShouldRetryStatus: RetryableStatus,
+				Sleep: func(d time.Duration) {
+					slept = append(slept, d)
+				},
+			},
+			transactor,
+		)
+	)
+
+	require.NotNil(retry)
+	response, err := retry(httptest.NewRequest("GET", "/", nil))
+	assert.NoError(err)
+	assert.True(responses[2] == response)
+	assert.Equal(3, transactorCount)
+	assert.Equal(2.0, counter.Value())
+
+	// the Retry-After is honored, otherwise the interval is used
+	assert.Equal([]time.Duration{7 * time.Second, time.Minute}, slept)
+}
+
+func testRetryTransactorMaxRetryAfter(t *testing.T, maxRetryAfter time.Duration) {
+	var (
+		assert   = assert.New(t)
+		require  = require.New(t)
+		expected = &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{RetryAfterHeader: []string{"3600"}}}
+
+		retry = RetryTransactor(
+			RetryOptions{
+				Logger:            logging.NewTestLogger(nil, t),
+				Retries:           2,
+				ShouldRetryStatus: RetryableStatus,
+				MaxRetryAfter:     maxRetryAfter,
+				Sleep: func(time.Duration) {
+					assert.Fail("Sleep should not have been called")
+				},
+			},
+			func(*http.Request) (*http.Response, error) {
+				return expected, nil
+			},
+		)
+	)
+
+	require.NotNil(retry)
+	response, err := retry(httptest.NewRequest("GET", "/", nil))
+	assert.NoError(err)
+	assert.True(expected == response)
+}
+
+func testRetryTransactorCanceled(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		attempts    = 0
+		retry       = RetryTransactor(
+			RetryOptions{
+				Logger:   logging.NewTestLogger(nil, t),
+				Retries:  2,
+				Interval: time.Hour,
+			},
+			func(*http.Request) (*http.Response, error) {
+				attempts++
+				cancel()
+				return nil, &net.DNSError{IsTemporary: true}
+			},
+		)
+	)
+
+	require.NotNil(retry)
+	response, err := retry(httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	assert.Nil(response)
+	assert.Equal(context.Canceled, err)
+	assert.Equal(1, attempts)
+}
+
+func TestRetryableStatus(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(RetryableStatus(http.StatusTooManyRequests))
+	assert.True(RetryableStatus(http.StatusServiceUnavailable))
+	assert.False(RetryableStatus(http.StatusOK))
+	assert.False(RetryableStatus(http.StatusInternalServerError))
+}
+
+func TestRetryRoundTripper(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		requests = 0
+		server   = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+			requests++
+			if requests == 1 {
+				SetRetryAfter(response.Header(), time.Second)
+				response.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			response.WriteHeader(299)
+		}))
+
+		slept  time.Duration
+		client = &http.Client{
+			Transport: RetryRoundTripper(
+				RetryOptions{
+					Retries:           1,
+					ShouldRetryStatus: RetryableStatus,
+					Sleep:             func(d time.Duration) { slept = d },
+				},
+				nil,
+			),
+		}
+	)
+
+	defer server.Close()
+	response, err := client.Get(server.URL)
+	require.NoError(err)
+	response.Body.Close()
+
+	assert.Equal(299, response.StatusCode)
+	assert.Equal(2, requests)
+	assert.Equal(time.Second, slept)
+}
+
 func TestRetryTransactor(t *testing.T) {
 	t.Run("DefaultLogger", testRetryTransactorDefaultLogger)
 	t.Run("NoRetries", testRetryTransactorNoRetries)
@@ -227,4 +379,59 @@ func TestRetryTransactor(t *testing.T) {
 
 	t.Run("NotRewindable", testRetryTransactorNotRewindable)
 	t.Run("RewindError", testRetryTransactorRewindError)
+	t.Run("Status", testRetryTransactorStatus)
+	t.Run("MaxRetryAfter", func(t *testing.T) {
+		testRetryTransactorMaxRetryAfter(t, time.Minute)
+		testRetryTransactorMaxRetryAfter(t, 0)
+	})
+
+	t.Run("Canceled", testRetryTransactorCanceled)
+}
+
+func TestRetryRoundTripperCopiesRequests(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		attempts []*http.Request
+		bodies   []string
+		next     = roundTripperFunc(func(attempt *http.Request) (*http.Response, error) {
+			body, err := ioutil.ReadAll(attempt.Body)
+			require.NoError(err)
+			attempt.Body.Close()
+
+			attempts = append(attempts, attempt)
+			bodies = append(bodies, string(body))
+			return nil, &net.DNSError{IsTemporary: true}
+		})
+
+		transport = RetryRoundTripper(
+			RetryOptions{
+				Logger:  logging.NewTestLogger(nil, t),
+				Retries: 2,
+				Sleep:   func(time.Duration) {},
+			},
+			next,
+		)
+
+		body    = ioutil.NopCloser(bytes.NewBufferString("request body"))
+		request = httptest.NewRequest("POST", "/", nil)
+	)
+
+	request.Body = body
+	response, err := transport.RoundTrip(request)
+	assert.Nil(response)
+	assert.Error(err)
+
+	// the caller's request is untouched, and every attempt is a separate copy with the complete body
+	assert.True(body == request.Body)
+	assert.Nil(request.GetBody)
+	require.Len(attempts, 3)
+	assert.Equal([]string{"request body", "request body", "request body"}, bodies)
+	for i, attempt := range attempts {
+		assert.False(request == attempt)
+		for j := i + 1; j < len(attempts); j++ {
+			assert.False(attempt == attempts[j])
+		}
+	}
 }
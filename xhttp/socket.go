@@ -0,0 +1,174 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrUnsupportedSocketOption indicates that a configured socket option is not supported on the current platform
+var ErrUnsupportedSocketOption = errors.New("That socket option is not supported on this platform")
+
+// SocketOptions describes low-level options for a listening socket and the connections it accepts.
+// The zero value leaves every option at the net package and operating system defaults.
+type SocketOptions struct {
+	// ReusePort sets SO_REUSEPORT on the listening socket, which allows multiple processes to bind the same
+	// port and have the kernel share incoming connections among them.
+	ReusePort bool `json:"reusePort,omitempty"`
+
+	// DisableNoDelay clears TCP_NODELAY on accepted connections, enabling Nagle's algorithm.  By default,
+	// the net package sets TCP_NODELAY on every TCP connection.
+	DisableNoDelay bool `json:"disableNoDelay,omitempty"`
+
+	// KeepAlive is the idle time before TCP keepalive probes are sent on accepted connections.  If zero, the
+	// net package default is used.  If negative, TCP keepalives are disabled.
+	KeepAlive time.Duration `json:"keepAlive,omitempty"`
+
+	// KeepAliveInterval is the time between unacknowledged TCP keepalive probes.  If nonpositive, the
+	// operating system default is used.
+	KeepAliveInterval time.Duration `json:"keepAliveInterval,omitempty"`
+
+	// KeepAliveCount is the number of unacknowledged TCP keepalive probes before a connection is dropped.
+	// If nonpositive, the operating system default is used.
+	KeepAliveCount int `json:"keepAliveCount,omitempty"`
+
+	// Backlog is the maximum length of the queue of pending connections.  If nonpositive, the net package
+	// default is used, which is normally the operating system maximum.
+	Backlog int `json:"backlog,omitempty"`
+}
+
+// Control is a net.ListenConfig.Control hook that applies the options for the listening socket itself
+func (so SocketOptions) Control(network, address string, c syscall.RawConn) error {
+	var controlErr error
+	err := c.Control(func(fd uintptr) {
+		controlErr = controlListener(fd, so)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return controlErr
+}
+
+// ListenConfig produces a net.ListenConfig that applies these options to the listening socket
+func (so SocketOptions) ListenConfig() net.ListenConfig {
+	return net.ListenConfig{
+		Control: so.Control,
+	}
+}
+
+// Listen creates a net.Listener with these socket options.  In addition to the options applied to the
+// listening socket, each accepted TCP connection is configured with the connection-level options.
+func (so SocketOptions) Listen(network, address string) (net.Listener, error) {
+	lc := so.ListenConfig()
+	l, err := lc.Listen(context.Background(), network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if so.Backlog > 0 {
+		if err := setBacklog(l, so.Backlog); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
+	if !so.DisableNoDelay && so.KeepAlive == 0 && so.KeepAliveInterval <= 0 && so.KeepAliveCount <= 0 {
+		// no per-connection options, so there's no reason to decorate the listener
+		return l, nil
+	}
+
+	return &socketListener{Listener: l, options: so}, nil
+}
+
+// socketListener applies connection-level socket options to each accepted TCP connection
+type socketListener struct {
+	net.Listener
+	options SocketOptions
+}
+
+func (sl *socketListener) Accept() (net.Conn, error) {
+	c, err := sl.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if tc, ok := c.(*net.TCPConn); ok {
+		if err := sl.options.configure(tc); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// configure applies the connection-level options to an accepted TCP connection
+func (so SocketOptions) configure(tc *net.TCPConn) error {
+	if so.DisableNoDelay {
+		if err := tc.SetNoDelay(false); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case so.KeepAlive < 0:
+		return tc.SetKeepAlive(false)
+
+	case so.KeepAlive > 0:
+		if err := tc.SetKeepAlive(true); err != nil {
+			return err
+		}
+
+		if err := tc.SetKeepAlivePeriod(so.KeepAlive); err != nil {
+			return err
+		}
+	}
+
+	if so.KeepAliveInterval > 0 || so.KeepAliveCount > 0 {
+		rc, err := tc.SyscallConn()
+		if err != nil {
+			return err
+		}
+
+		var controlErr error
+		err = rc.Control(func(fd uintptr) {
+			controlErr = controlKeepAlive(fd, so.KeepAliveInterval, so.KeepAliveCount)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return controlErr
+	}
+
+	return nil
+}
+
+// setBacklog changes the pending connection queue length of a listener that has already been created
+func setBacklog(l net.Listener, backlog int) error {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return ErrUnsupportedSocketOption
+	}
+
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var controlErr error
+	err = rc.Control(func(fd uintptr) {
+		controlErr = listenBacklog(fd, backlog)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return controlErr
+}
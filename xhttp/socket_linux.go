@@ -0,0 +1,36 @@
+package xhttp
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func controlListener(fd uintptr, so SocketOptions) error {
+	if so.ReusePort {
+		return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}
+
+	return nil
+}
+
+func controlKeepAlive(fd uintptr, interval time.Duration, count int) error {
+	if interval > 0 {
+		// the kernel only accepts whole seconds, and rejects zero
+		seconds := int((interval + time.Second - 1) / time.Second)
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, seconds); err != nil {
+			return err
+		}
+	}
+
+	if count > 0 {
+		return unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPCNT, count)
+	}
+
+	return nil
+}
+
+func listenBacklog(fd uintptr, backlog int) error {
+	// calling listen again on a listening socket updates its backlog
+	return unix.Listen(int(fd), backlog)
+}
@@ -0,0 +1,96 @@
+package xhttp
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// getsockopt reads an integer socket option from a connection or listener
+func getsockopt(t *testing.T, c syscall.Conn, level, option int) int {
+	rc, err := c.SyscallConn()
+	require.NoError(t, err)
+
+	var (
+		value      int
+		controlErr error
+	)
+
+	require.NoError(t, rc.Control(func(fd uintptr) {
+		value, controlErr = unix.GetsockoptInt(int(fd), level, option)
+	}))
+
+	require.NoError(t, controlErr)
+	return value
+}
+
+func TestSocketOptionsReusePort(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		options = SocketOptions{ReusePort: true, Backlog: 16}
+	)
+
+	first, err := options.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer first.Close()
+
+	assert.Equal(1, getsockopt(t, first.(syscall.Conn), unix.SOL_SOCKET, unix.SO_REUSEPORT))
+
+	// a second listener can share the port
+	second, err := options.Listen("tcp", first.Addr().String())
+	require.NoError(err)
+	defer second.Close()
+
+	// without the option, the port cannot be shared
+	third, err := SocketOptions{}.Listen("tcp", first.Addr().String())
+	assert.Nil(third)
+	assert.Error(err)
+}
+
+func TestSocketOptionsKeepAlive(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		options = SocketOptions{
+			DisableNoDelay:    true,
+			KeepAlive:         time.Minute,
+			KeepAliveInterval: 1500 * time.Millisecond,
+			KeepAliveCount:    4,
+		}
+	)
+
+	l, err := options.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer l.Close()
+
+	server, client := acceptOne(t, l)
+	defer server.Close()
+	defer client.Close()
+
+	c := server.(syscall.Conn)
+	assert.Equal(0, getsockopt(t, c, unix.IPPROTO_TCP, unix.TCP_NODELAY))
+	assert.Equal(1, getsockopt(t, c, unix.SOL_SOCKET, unix.SO_KEEPALIVE))
+	assert.Equal(60, getsockopt(t, c, unix.IPPROTO_TCP, unix.TCP_KEEPIDLE))
+	assert.Equal(2, getsockopt(t, c, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL))
+	assert.Equal(4, getsockopt(t, c, unix.IPPROTO_TCP, unix.TCP_KEEPCNT))
+}
+
+func TestSocketOptionsDisableKeepAlive(t *testing.T) {
+	require := require.New(t)
+
+	l, err := SocketOptions{KeepAlive: -1}.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer l.Close()
+
+	server, client := acceptOne(t, l)
+	defer server.Close()
+	defer client.Close()
+
+	assert.Equal(t, 0, getsockopt(t, server.(syscall.Conn), unix.SOL_SOCKET, unix.SO_KEEPALIVE))
+	assert.Equal(t, 1, getsockopt(t, server.(syscall.Conn), unix.IPPROTO_TCP, unix.TCP_NODELAY))
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package xhttp
+
+import "time"
+
+func controlListener(fd uintptr, so SocketOptions) error {
+	if so.ReusePort {
+		return ErrUnsupportedSocketOption
+	}
+
+	return nil
+}
+
+func controlKeepAlive(uintptr, time.Duration, int) error {
+	return ErrUnsupportedSocketOption
+}
+
+func listenBacklog(uintptr, int) error {
+	return ErrUnsupportedSocketOption
+}
@@ -0,0 +1,83 @@
+package xhttp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// acceptOne dials a listener and returns the accepted connection along with the client connection
+func acceptOne(t *testing.T, l net.Listener) (net.Conn, net.Conn) {
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		assert.NoError(t, err)
+		accepted <- c
+	}()
+
+	client, err := net.Dial(l.Addr().Network(), l.Addr().String())
+	require.NoError(t, err)
+
+	select {
+	case c := <-accepted:
+		require.NotNil(t, c)
+		return c, client
+	case <-time.After(5 * time.Second):
+		client.Close()
+		require.Fail(t, "No connection was accepted")
+		return nil, nil
+	}
+}
+
+func TestSocketOptionsListenDefault(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	l, err := SocketOptions{}.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer l.Close()
+
+	_, decorated := l.(*socketListener)
+	assert.False(decorated)
+
+	server, client := acceptOne(t, l)
+	server.Close()
+	client.Close()
+}
+
+func TestSocketOptionsListenConnection(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	l, err := SocketOptions{DisableNoDelay: true, KeepAlive: time.Minute}.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer l.Close()
+
+	_, decorated := l.(*socketListener)
+	assert.True(decorated)
+
+	server, client := acceptOne(t, l)
+	server.Close()
+	client.Close()
+}
+
+func TestSocketOptionsListenError(t *testing.T) {
+	assert := assert.New(t)
+
+	l, err := SocketOptions{KeepAlive: time.Minute}.Listen("tcp", "this is not a valid address")
+	assert.Nil(l)
+	assert.Error(err)
+}
+
+func TestSocketOptionsListenConfig(t *testing.T) {
+	assert := assert.New(t)
+	lc := SocketOptions{ReusePort: true}.ListenConfig()
+	assert.NotNil(lc.Control)
+}
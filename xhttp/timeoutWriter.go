@@ -0,0 +1,242 @@
+package xhttp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+// TimeoutWriter is an http.ResponseWriter that guards a response against writes from an http.Handler that
+// continues to run after its deadline.  Once a timeout response has been written with Timeout, every subsequent
+// write from the late handler is swallowed rather than reaching the underlying response.  This prevents both
+// corrupted responses and "superfluous WriteHeader" errors.
+//
+// Headers set by the handler are buffered and only copied to the underlying response when the handler writes
+// its status code, so a late handler cannot race with the timeout response's headers.
+type TimeoutWriter struct {
+	lock        sync.Mutex
+	response    http.ResponseWriter
+	header      http.Header
+	wroteHeader bool
+	timedOut    bool
+	late        bool
+	lateWrites  xmetrics.Adder
+}
+
+// NewTimeoutWriter wraps an http.ResponseWriter with a TimeoutWriter.  The lateWrites metric is incremented
+// once for each response that receives writes after a timeout response.  If lateWrites is nil, late writes
+// are not counted.
+func NewTimeoutWriter(response http.ResponseWriter, lateWrites xmetrics.Adder) *TimeoutWriter {
+	if lateWrites == nil {
+		lateWrites = discard.NewCounter()
+	}
+
+	return &TimeoutWriter{
+		response:   response,
+		header:     make(http.Header),
+		lateWrites: lateWrites,
+	}
+}
+
+// lateWrite records a write that arrived after a timeout response.  This method must be invoked under the lock.
+func (tw *TimeoutWriter) lateWrite() {
+	if !tw.late {
+		tw.late = true
+		tw.lateWrites.Add(1.0)
+	}
+}
+
+// writeHeader copies the buffered headers and writes the status code.  An informational status code does not
+// start the handler's response, so a timeout response can still follow it.  This method must be invoked under the lock.
+func (tw *TimeoutWriter) writeHeader(statusCode int) {
+	header := tw.response.Header()
+	for name, values := range tw.header {
+		header[name] = values
+	}
+
+	if !IsInformational(statusCode) {
+		tw.wroteHeader = true
+	}
+
+	tw.response.WriteHeader(statusCode)
+}
+
+// Header returns the buffered headers for the handler's response
+func (tw *TimeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// WriteHeader writes the status code to the underlying response.  Calls made after a timeout response
+// and duplicate calls are ignored.  Any number of informational responses may precede the final status code.
+func (tw *TimeoutWriter) WriteHeader(statusCode int) {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.timedOut {
+		tw.lateWrite()
+	} else if !tw.wroteHeader {
+		tw.writeHeader(statusCode)
+	}
+}
+
+// Write writes to the underlying response.  After a timeout response, this method returns http.ErrHandlerTimeout
+// and the data is discarded.
+func (tw *TimeoutWriter) Write(p []byte) (int, error) {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.timedOut {
+		tw.lateWrite()
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+
+	return tw.response.Write(p)
+}
+
+// Flush flushes the underlying response, if it supports flushing.  Flushes after a timeout response are ignored.
+func (tw *TimeoutWriter) Flush() {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if flusher, ok := tw.response.(http.Flusher); ok && !tw.timedOut {
+		if !tw.wroteHeader {
+			tw.writeHeader(http.StatusOK)
+		}
+
+		flusher.Flush()
+	}
+}
+
+// Hijack hijacks the underlying connection, if it supports hijacking.  A hijacked connection belongs to the
+// handler, so no timeout response is written afterwards.  Hijacking after a timeout response returns
+// http.ErrHandlerTimeout.
+func (tw *TimeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.timedOut {
+		tw.lateWrite()
+		return nil, nil, http.ErrHandlerTimeout
+	}
+
+	hijacker, ok := tw.response.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", tw.response)
+	}
+
+	c, rw, err := hijacker.Hijack()
+	if err == nil {
+		tw.wroteHeader = true
+	}
+
+	return c, rw, err
+}
+
+// Timeout writes a timeout response with the given status code and message, after which all writes from the
+// handler are swallowed.  If the handler has already started its response, no timeout response can be sent,
+// the handler's response is left intact, and this method returns false.
+func (tw *TimeoutWriter) Timeout(statusCode int, message string) bool {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.wroteHeader || tw.timedOut {
+		return false
+	}
+
+	tw.timedOut = true
+	WriteError(tw.response, statusCode, message)
+	return true
+}
+
+// EnforceTimeout returns an Alice-style constructor that enforces a timeout for decorated http.Handler code.
+// Each request context carries the timeout, and if the handler has not started its response when the timeout
+// elapses, a 504 response is written immediately.  Any writes the handler makes afterwards are swallowed by a
+// TimeoutWriter and counted with lateWrites, which may be nil.  A panic in the handler is propagated to the
+// calling goroutine.
+//
+// Unlike http.TimeoutHandler, responses are not buffered, so handlers may stream output.  A handler which has
+// started its response before the timeout is allowed to finish it.  Protocol upgrade requests, such as websocket
+// handshakes, are passed to the next http.Handler undecorated, since an upgraded connection outlives any timeout.
+//
+// If timeout is nonpositive, the returned constructor simply returns the next http.Handler undecorated.
+func EnforceTimeout(timeout time.Duration, lateWrites xmetrics.Adder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout < 1 {
+			return next
+		}
+
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			if isUpgrade(request) {
+				next.ServeHTTP(response, request)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(request.Context(), timeout)
+			defer cancel()
+
+			var (
+				tw     = NewTimeoutWriter(response, lateWrites)
+				done   = make(chan struct{})
+				panics = make(chan interface{}, 1)
+			)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panics <- p
+					}
+				}()
+
+				next.ServeHTTP(tw, request.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panics:
+				panic(p)
+
+			case <-done:
+				// the handler completed in time
+
+			case <-ctx.Done():
+				if !tw.Timeout(http.StatusGatewayTimeout, "The request timed out") {
+					// the handler had already started its response, so let it finish
+					select {
+					case p := <-panics:
+						panic(p)
+					case <-done:
+					}
+				}
+			}
+		})
+	}
+}
+
+// isUpgrade tests if a request asks to switch protocols, e.g. a websocket handshake
+func isUpgrade(request *http.Request) bool {
+	if len(request.Header.Get("Upgrade")) == 0 {
+		return false
+	}
+
+	for _, value := range request.Header["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
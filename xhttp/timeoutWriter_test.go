@@ -0,0 +1,246 @@
+package xhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTimeoutWriterNoTimeout(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+		tw       = NewTimeoutWriter(response, nil)
+	)
+
+	tw.Header().Set("X-Test", "value")
+	assert.Empty(response.HeaderMap.Get("X-Test"))
+
+	tw.WriteHeader(299)
+	tw.WriteHeader(500)
+	count, err := tw.Write([]byte("hello"))
+	assert.Equal(5, count)
+	assert.NoError(err)
+	tw.Flush()
+
+	assert.Equal(299, response.Code)
+	assert.Equal("value", response.HeaderMap.Get("X-Test"))
+	assert.Equal("hello", response.Body.String())
+	assert.True(response.Flushed)
+
+	assert.False(tw.Timeout(http.StatusGatewayTimeout, "too late"))
+	assert.Equal(299, response.Code)
+}
+
+func testTimeoutWriterImplicitHeader(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+		tw       = NewTimeoutWriter(response, nil)
+	)
+
+	tw.Write([]byte("hello"))
+	assert.Equal(http.StatusOK, response.Code)
+	assert.Equal("hello", response.Body.String())
+}
+
+func testTimeoutWriterLateWrites(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		response   = httptest.NewRecorder()
+		lateWrites = generic.NewCounter("test")
+		tw         = NewTimeoutWriter(response, lateWrites)
+	)
+
+	tw.Header().Set("X-Late", "true")
+	assert.True(tw.Timeout(http.StatusGatewayTimeout, "timed out"))
+	assert.False(tw.Timeout(http.StatusGatewayTimeout, "timed out"))
+	assert.Equal(http.StatusGatewayTimeout, response.Code)
+	assert.Equal(`{"code": 504, "message": "timed out"}`, response.Body.String())
+
+	tw.WriteHeader(200)
+	count, err := tw.Write([]byte("late"))
+	assert.Zero(count)
+	assert.Equal(http.ErrHandlerTimeout, err)
+	tw.Flush()
+
+	assert.Equal(http.StatusGatewayTimeout, response.Code)
+	assert.Equal(`{"code": 504, "message": "timed out"}`, response.Body.String())
+	assert.Empty(response.HeaderMap.Get("X-Late"))
+	assert.False(response.Flushed)
+	assert.Equal(1.0, lateWrites.Value())
+}
+
+func testTimeoutWriterHijack(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+		tw       = NewTimeoutWriter(response, nil)
+	)
+
+	_, _, err := tw.Hijack()
+	assert.NoError(err)
+	assert.True(response.hijacked)
+
+	// the handler owns a hijacked connection, so no timeout response can be written
+	assert.False(tw.Timeout(http.StatusGatewayTimeout, "too late"))
+
+	response = &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	tw = NewTimeoutWriter(response, nil)
+	assert.True(tw.Timeout(http.StatusGatewayTimeout, "timed out"))
+	_, _, err = tw.Hijack()
+	assert.Equal(http.ErrHandlerTimeout, err)
+	assert.False(response.hijacked)
+
+	_, _, err = NewTimeoutWriter(httptest.NewRecorder(), nil).Hijack()
+	assert.Error(err)
+}
+
+func TestTimeoutWriter(t *testing.T) {
+	t.Run("NoTimeout", testTimeoutWriterNoTimeout)
+	t.Run("ImplicitHeader", testTimeoutWriterImplicitHeader)
+	t.Run("LateWrites", testTimeoutWriterLateWrites)
+	t.Run("Hijack", testTimeoutWriterHijack)
+}
+
+func testEnforceTimeoutDisabled(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		next   = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	)
+
+	assert.NotNil(EnforceTimeout(0, nil)(next))
+	assert.NotNil(EnforceTimeout(-1, nil)(next))
+}
+
+func testEnforceTimeoutInTime(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+
+		handler = EnforceTimeout(time.Minute, nil)(
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				_, ok := request.Context().Deadline()
+				assert.True(ok)
+				response.WriteHeader(299)
+			}),
+		)
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+}
+
+func testEnforceTimeoutUpgrade(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+		request  = httptest.NewRequest("GET", "/", nil)
+
+		handler = EnforceTimeout(time.Nanosecond, nil)(
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				_, ok := request.Context().Deadline()
+				assert.False(ok)
+
+				_, ok = response.(*TimeoutWriter)
+				assert.False(ok)
+
+				time.Sleep(10 * time.Millisecond)
+				response.(http.Hijacker).Hijack()
+			}),
+		)
+	)
+
+	request.Header.Set("Connection", "keep-alive, Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	handler.ServeHTTP(response, request)
+	assert.True(response.hijacked)
+	assert.Equal(http.StatusOK, response.Code)
+}
+
+func testEnforceTimeoutLate(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		p          = xmetricstest.NewProvider(nil)
+		lateWrites = p.NewCounter("late_writes")
+		response   = httptest.NewRecorder()
+		release    = make(chan struct{})
+		finished   = make(chan error, 1)
+
+		handler = EnforceTimeout(10*time.Millisecond, lateWrites)(
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				<-request.Context().Done()
+				<-release
+				response.WriteHeader(200)
+				_, err := response.Write([]byte("late"))
+				finished <- err
+			}),
+		)
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusGatewayTimeout, response.Code)
+
+	close(release)
+	select {
+	case err := <-finished:
+		assert.Equal(http.ErrHandlerTimeout, err)
+	case <-time.After(5 * time.Second):
+		require.Fail("The late handler did not finish")
+	}
+
+	assert.Equal(http.StatusGatewayTimeout, response.Code)
+	assert.NotContains(response.Body.String(), "late")
+	p.Assert(t, "late_writes")(xmetricstest.Value(1.0))
+}
+
+func testEnforceTimeoutStarted(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+
+		handler = EnforceTimeout(10*time.Millisecond, nil)(
+			http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				response.WriteHeader(299)
+				<-request.Context().Done()
+				response.Write([]byte("finished"))
+			}),
+		)
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(299, response.Code)
+	assert.Equal("finished", response.Body.String())
+}
+
+func testEnforceTimeoutPanic(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		handler = EnforceTimeout(time.Minute, nil)(
+			http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				panic("expected")
+			}),
+		)
+	)
+
+	assert.PanicsWithValue("expected", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+}
+
+func TestEnforceTimeout(t *testing.T) {
+	t.Run("Disabled", testEnforceTimeoutDisabled)
+	t.Run("InTime", testEnforceTimeoutInTime)
+	t.Run("Upgrade", testEnforceTimeoutUpgrade)
+	t.Run("Late", testEnforceTimeoutLate)
+	t.Run("Started", testEnforceTimeoutStarted)
+	t.Run("Panic", testEnforceTimeoutPanic)
+}
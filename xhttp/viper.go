@@ -0,0 +1,35 @@
+package xhttp
+
+import (
+	"github.com/spf13/viper"
+)
+
+const (
+	// FeaturesKey is the Viper subkey under which FeatureOptions are typically stored.
+	// NewFeatureOptions *does not* assume this key.
+	FeaturesKey = "features"
+)
+
+// FeaturesSub returns the standard child Viper, using FeaturesKey, for feature flags.
+// If passed nil, this function returns nil.
+func FeaturesSub(v *viper.Viper) *viper.Viper {
+	if v != nil {
+		return v.Sub(FeaturesKey)
+	}
+
+	return nil
+}
+
+// NewFeatureOptions produces a FeatureOptions from a (possibly nil) Viper instance.  Callers should use
+// NewFeatureOptions(FeaturesSub(v)) if the standard subkey is desired.  A nil Viper produces options with
+// no flags.  Fields which cannot be configured externally, such as Provider and Evaluations, are left unset.
+func NewFeatureOptions(v *viper.Viper) (*FeatureOptions, error) {
+	o := new(FeatureOptions)
+	if v != nil {
+		if err := v.Unmarshal(o); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
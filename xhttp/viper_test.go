@@ -0,0 +1,86 @@
+package xhttp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeaturesSub(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(FeaturesSub(nil))
+
+	v := viper.New()
+	v.SetConfigType("json")
+	require.NoError(t, v.ReadConfig(strings.NewReader(`{"features": {"providerTTL": "5s"}}`)))
+
+	sub := FeaturesSub(v)
+	require.NotNil(t, sub)
+	assert.Equal("5s", sub.GetString("providerTTL"))
+}
+
+func TestNewFeatureOptions(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		o, err := NewFeatureOptions(nil)
+		require.NoError(err)
+		require.NotNil(o)
+		assert.Empty(o.Flags)
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			v       = viper.New()
+		)
+
+		v.SetConfigType("json")
+		require.NoError(v.ReadConfig(strings.NewReader(`{
+			"flags": [
+				{"name": "newCodec", "enabled": true},
+				{"name": "newAggregation", "percentage": 12.5}
+			],
+			"providerTTL": "30s",
+			"warningInterval": "5m"
+		}`)))
+
+		o, err := NewFeatureOptions(v)
+		require.NoError(err)
+		require.NotNil(o)
+
+		assert.Equal(
+			[]FeatureFlag{
+				{Name: "newCodec", Enabled: true},
+				{Name: "newAggregation", Percentage: 12.5},
+			},
+			o.Flags,
+		)
+
+		assert.Equal(30*time.Second, o.ProviderTTL)
+		assert.Equal(5*time.Minute, o.WarningInterval)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			v       = viper.New()
+		)
+
+		v.SetConfigType("json")
+		require.NoError(v.ReadConfig(strings.NewReader(`{"flags": "not a list"}`)))
+
+		o, err := NewFeatureOptions(v)
+		assert.Nil(o)
+		assert.Error(err)
+	})
+}
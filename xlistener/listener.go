@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/Comcast/webpa-common/xmetrics"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -35,7 +36,10 @@ type Options struct {
 	// Address is the address to listen on.  This value is only used if Next is unset.  Defaults to ":http" if unset.
 	Address string
 
-	// Next is the net.Listener to decorate.  If this field is set, Network and Address are ignored.
+	// Socket holds the low-level socket options used when creating a listener.  This value is only used if Next is unset.
+	Socket xhttp.SocketOptions
+
+	// Next is the net.Listener to decorate.  If this field is set, Network, Address, and Socket are ignored.
 	Next net.Listener
 }
 
@@ -73,8 +77,13 @@ func New(o Options) (net.Listener, error) {
 			o.Address = ":http"
 		}
 
+		listen := netListen
+		if o.Socket != (xhttp.SocketOptions{}) {
+			listen = o.Socket.Listen
+		}
+
 		var err error
-		next, err = netListen(o.Network, o.Address)
+		next, err = listen(o.Network, o.Address)
 		if err != nil {
 			return nil, err
 		}
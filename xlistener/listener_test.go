@@ -4,8 +4,10 @@ import (
 	"errors"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/xhttp"
 	"github.com/go-kit/kit/metrics/generic"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -107,10 +109,55 @@ func testNewListenError(t *testing.T) {
 	assert.Equal(expectedError, actualError)
 }
 
+func testNewSocket(t *testing.T) {
+	defer func() { netListen = net.Listen }()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	netListen = func(network, address string) (net.Listener, error) {
+		assert.Fail("netListen should not be used when socket options are set")
+		return nil, errors.New("unexpected")
+	}
+
+	l, err := New(Options{
+		Address: "127.0.0.1:0",
+		Socket:  xhttp.SocketOptions{KeepAlive: time.Minute},
+	})
+
+	require.NoError(err)
+	require.NotNil(l)
+	defer l.Close()
+
+	accepted := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			c.Close()
+		}
+
+		accepted <- err
+	}()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(err)
+	defer client.Close()
+
+	select {
+	case err := <-accepted:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("No connection was accepted")
+	}
+}
+
 func TestNew(t *testing.T) {
 	t.Run("Default", testNewDefault)
 	t.Run("Custom", testNewCustom)
 	t.Run("ListenError", testNewListenError)
+	t.Run("Socket", testNewSocket)
 }
 
 func testListenerAcceptError(t *testing.T, maxConnections int) {
@@ -1,3 +1,6 @@
 // Package xmetrics provides configurability for Prometheus-based metrics.  The more general go-kit interfaces
 // are used where possible.
+//
+// There is no package-level registry.  Each Registry is isolated, and several may coexist in one process.
+// Use Registries to manage a named set of them with an explicit default.
 package xmetrics
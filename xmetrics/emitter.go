@@ -0,0 +1,487 @@
+package xmetrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// EmitterProtocol is the wire protocol an Emitter uses to push metrics
+type EmitterProtocol string
+
+const (
+	// Graphite is the Graphite plaintext protocol, which sends each metric's current value with a timestamp.
+	// Counters and the counts and sums of histograms are sent as cumulative values.
+	Graphite EmitterProtocol = "graphite"
+
+	// StatsD is the StatsD protocol.  Counters and the counts and sums of histograms are sent as StatsD
+	// counters holding the change since the previous emission, while gauges are sent as StatsD gauges.
+	StatsD EmitterProtocol = "statsd"
+)
+
+const (
+	// DefaultEmitterPeriod is how often metrics are emitted when no period is configured
+	DefaultEmitterPeriod = 10 * time.Second
+
+	// DefaultEmitterTimeout is the timeout for connecting to the sink and writing each emission
+	// when no timeout is configured
+	DefaultEmitterTimeout = 5 * time.Second
+
+	// maxStatsDPacketSize keeps each StatsD datagram within a typical network MTU
+	maxStatsDPacketSize = 1432
+)
+
+// LabelRule determines how one metric label is translated into metric names for sinks which, like Graphite and
+// plain StatsD, have no notion of labels.  By default, each label becomes two dotted segments of the name, its name
+// and then its value, appended in lexical order of label names.  Labels with a rule come first, in rule order.
+type LabelRule struct {
+	// Label is the name of the label this rule applies to
+	Label string `json:"label"`
+
+	// Drop omits this label from emitted names.  Counter values of series which differ only by dropped labels are
+	// summed.  Gauges cannot be summed, so only the first of any gauges whose emitted names collide is emitted.
+	Drop bool `json:"drop"`
+
+	// Name replaces the label's name in emitted names
+	Name string `json:"name"`
+
+	// ValueOnly omits the label's name, so that only its value appears in emitted names
+	ValueOnly bool `json:"valueOnly"`
+}
+
+// EmitterOptions configures the periodic push of metrics to a StatsD or Graphite sink, for deployments whose
+// telemetry cannot scrape Prometheus.  Metrics remain available for scraping as well.
+type EmitterOptions struct {
+	// Protocol is the wire protocol used to push metrics.  This field is required.
+	Protocol EmitterProtocol `json:"protocol"`
+
+	// Address is the host:port of the sink.  This field is required.
+	Address string `json:"address"`
+
+	// Network is the network used to reach the sink, e.g. "tcp" or "udp".  If unset, Graphite uses "tcp"
+	// and StatsD uses "udp".
+	Network string `json:"network"`
+
+	// Prefix, if set, is prepended to each emitted name, separated by a dot
+	Prefix string `json:"prefix"`
+
+	// Period is how often metrics are emitted.  If nonpositive, DefaultEmitterPeriod is used.
+	Period time.Duration `json:"period"`
+
+	// Timeout bounds connecting to the sink and writing each emission.  If nonpositive, DefaultEmitterTimeout is used.
+	Timeout time.Duration `json:"timeout"`
+
+	// Labels are the rules for translating labels into emitted names
+	Labels []LabelRule `json:"labels"`
+}
+
+func (eo *EmitterOptions) network() string {
+	if len(eo.Network) > 0 {
+		return eo.Network
+	}
+
+	if eo.Protocol == StatsD {
+		return "udp"
+	}
+
+	return "tcp"
+}
+
+func (eo *EmitterOptions) period() time.Duration {
+	if eo.Period > 0 {
+		return eo.Period
+	}
+
+	return DefaultEmitterPeriod
+}
+
+func (eo *EmitterOptions) timeout() time.Duration {
+	if eo.Timeout > 0 {
+		return eo.Timeout
+	}
+
+	return DefaultEmitterTimeout
+}
+
+// Emitter periodically gathers metrics and pushes them to a StatsD or Graphite sink.  An Emitter does nothing until
+// it is started, either with Start or by running it as a concurrent.Runnable.
+//
+// Emitted names are translated from metric names and labels, so distinct series can produce the same name.  Counters
+// of the same metric are summed under that name.  Any other collision, such as between gauges or between different
+// metrics, is rejected:  only the first series is emitted under the name, and the collision is logged once.
+type Emitter struct {
+	logger   log.Logger
+	gatherer prometheus.Gatherer
+	protocol EmitterProtocol
+	network  string
+	address  string
+	prefix   string
+	period   time.Duration
+	timeout  time.Duration
+	rules    map[string]int
+	labels   []LabelRule
+	now      func() time.Time
+
+	// previous holds the last emitted value of each StatsD counter, used to compute changes
+	previous map[string]float64
+
+	// collisions holds the emitted names of rejected series that have already been logged
+	collisions map[string]bool
+	conn       net.Conn
+
+	scheduler *concurrent.Scheduler
+	runOnce   sync.Once
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	shutdown  chan struct{}
+	waitGroup sync.WaitGroup
+}
+
+// NewEmitter creates an Emitter which pushes metrics gathered from the given Gatherer.  The Emitter is not
+// started.  An error is returned if the protocol is not recognized or the address is missing.
+func NewEmitter(o EmitterOptions, g prometheus.Gatherer, logger log.Logger) (*Emitter, error) {
+	switch o.Protocol {
+	case Graphite, StatsD:
+	default:
+		return nil, fmt.Errorf("Unrecognized emitter protocol: %q", o.Protocol)
+	}
+
+	if len(o.Address) == 0 {
+		return nil, fmt.Errorf("An address is required for the %s emitter", o.Protocol)
+	}
+
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	e := &Emitter{
+		logger:     log.With(logger, "protocol", o.Protocol, "address", o.Address),
+		gatherer:   g,
+		protocol:   o.Protocol,
+		network:    o.network(),
+		address:    o.Address,
+		prefix:     o.Prefix,
+		period:     o.period(),
+		timeout:    o.timeout(),
+		rules:      make(map[string]int, len(o.Labels)),
+		labels:     append([]LabelRule(nil), o.Labels...),
+		now:        time.Now,
+		previous:   make(map[string]float64),
+		collisions: make(map[string]bool),
+		shutdown:   make(chan struct{}),
+	}
+
+	for i, rule := range e.labels {
+		e.rules[rule.Label] = i
+	}
+
+	e.scheduler = concurrent.NewScheduler(
+		concurrent.ScheduleOptions{
+			Name:   "xmetrics.emitter",
+			Logger: e.logger,
+			Period: e.period,
+		},
+		e.emit,
+	)
+
+	return e, nil
+}
+
+// Run starts the goroutine that periodically emits metrics until shutdown is closed, after which the connection
+// to the sink is closed.  The waitGroup is not released until any emission in progress has finished.  This method
+// is idempotent, and implements concurrent.Runnable.
+func (e *Emitter) Run(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
+	e.runOnce.Do(func() {
+		var scheduled sync.WaitGroup
+		e.scheduler.Run(&scheduled, shutdown)
+
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			// the connection is only closed once the scheduler can no longer use it
+			scheduled.Wait()
+			if e.conn != nil {
+				e.conn.Close()
+				e.conn = nil
+			}
+		}()
+	})
+
+	return nil
+}
+
+// Start begins emitting metrics in a background goroutine, which runs until Stop is called.  This is an
+// alternative to running the Emitter as a concurrent.Runnable.  This method is idempotent.
+func (e *Emitter) Start() {
+	e.startOnce.Do(func() {
+		e.Run(&e.waitGroup, e.shutdown)
+	})
+}
+
+// Stop halts emission started with Start and closes the connection to the sink.  Stop waits for any emission
+// in progress to finish.  This method is idempotent, and does nothing if Start was never called.
+func (e *Emitter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.shutdown)
+		e.waitGroup.Wait()
+	})
+}
+
+// emit is the scheduled task, which logs any error from Emit
+func (e *Emitter) emit() {
+	if err := e.Emit(); err != nil {
+		e.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to emit metrics", logging.ErrorKey(), err)
+	}
+}
+
+// Emit gathers and pushes metrics once.  The connection to the sink is established as needed, and is discarded
+// after any write failure so that the next emission reconnects.  This method is not safe for concurrent use,
+// and is normally only called by the Emitter's own goroutine.
+func (e *Emitter) Emit() error {
+	families, err := e.gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		return err
+	}
+
+	packets := e.format(families)
+	if len(packets) == 0 {
+		return nil
+	}
+
+	if e.conn == nil {
+		if e.conn, err = net.DialTimeout(e.network, e.address, e.timeout); err != nil {
+			e.conn = nil
+			return err
+		}
+	}
+
+	e.conn.SetWriteDeadline(e.now().Add(e.timeout))
+	for _, packet := range packets {
+		if _, err = e.conn.Write(packet); err != nil {
+			e.conn.Close()
+			e.conn = nil
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sample is a single value to emit
+type sample struct {
+	family  string
+	value   float64
+	counter bool
+}
+
+// format translates metric families into the wire format of this emitter.  Graphite output is a single
+// chunk, while StatsD output is split into datagrams.
+func (e *Emitter) format(families []*dto.MetricFamily) [][]byte {
+	samples := make(map[string]sample)
+	for _, family := range families {
+		add := func(name string, value float64, counter bool) {
+			s, ok := samples[name]
+			if !ok {
+				samples[name] = sample{family: family.GetName(), value: value, counter: counter}
+				return
+			}
+
+			// only counters of the same metric can be summed
+			if counter && s.counter && s.family == family.GetName() {
+				s.value += value
+				samples[name] = s
+				return
+			}
+
+			if !e.collisions[name] {
+				e.collisions[name] = true
+				e.logger.Log(
+					level.Key(), level.WarnValue(),
+					logging.MessageKey(), "rejecting series whose emitted name collides with another series",
+					"name", name, "metric", family.GetName(), "existing", s.family,
+				)
+			}
+		}
+
+		for _, m := range family.GetMetric() {
+			base := e.name(family.GetName(), m.GetLabel())
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add(base, m.GetCounter().GetValue(), true)
+
+			case dto.MetricType_GAUGE:
+				add(base, m.GetGauge().GetValue(), false)
+
+			case dto.MetricType_UNTYPED:
+				add(base, m.GetUntyped().GetValue(), false)
+
+			case dto.MetricType_HISTOGRAM:
+				add(base+".count", float64(m.GetHistogram().GetSampleCount()), true)
+				add(base+".sum", m.GetHistogram().GetSampleSum(), true)
+
+			case dto.MetricType_SUMMARY:
+				add(base+".count", float64(m.GetSummary().GetSampleCount()), true)
+				add(base+".sum", m.GetSummary().GetSampleSum(), true)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	if e.protocol == Graphite {
+		return e.formatGraphite(names, samples)
+	}
+
+	return e.formatStatsD(names, samples)
+}
+
+func (e *Emitter) formatGraphite(names []string, samples map[string]sample) [][]byte {
+	var (
+		output    bytes.Buffer
+		timestamp = strconv.FormatInt(e.now().Unix(), 10)
+	)
+
+	for _, name := range names {
+		output.WriteString(name)
+		output.WriteByte(' ')
+		output.WriteString(strconv.FormatFloat(samples[name].value, 'f', -1, 64))
+		output.WriteByte(' ')
+		output.WriteString(timestamp)
+		output.WriteByte('\n')
+	}
+
+	return [][]byte{output.Bytes()}
+}
+
+func (e *Emitter) formatStatsD(names []string, samples map[string]sample) [][]byte {
+	var (
+		packets [][]byte
+		packet  []byte
+	)
+
+	for _, name := range names {
+		var (
+			s    = samples[name]
+			line string
+		)
+
+		if s.counter {
+			delta := s.value
+			if previous, ok := e.previous[name]; ok && previous <= s.value {
+				delta = s.value - previous
+			}
+
+			e.previous[name] = s.value
+			if delta == 0 {
+				continue
+			}
+
+			line = name + ":" + strconv.FormatFloat(delta, 'f', -1, 64) + "|c"
+		} else {
+			line = name + ":" + strconv.FormatFloat(s.value, 'f', -1, 64) + "|g"
+		}
+
+		if len(packet) > 0 && len(packet)+1+len(line) > maxStatsDPacketSize {
+			packets = append(packets, packet)
+			packet = nil
+		}
+
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+
+		packet = append(packet, line...)
+	}
+
+	if len(packet) > 0 {
+		packets = append(packets, packet)
+	}
+
+	return packets
+}
+
+// name produces the emitted name for a metric and its labels, applying the label rules
+func (e *Emitter) name(metricName string, labels []*dto.LabelPair) string {
+	ordered := make([]*dto.LabelPair, 0, len(labels))
+	for _, l := range labels {
+		if i, ok := e.rules[l.GetName()]; !ok || !e.labels[i].Drop {
+			ordered = append(ordered, l)
+		}
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, iRuled := e.rules[ordered[i].GetName()]
+		rj, jRuled := e.rules[ordered[j].GetName()]
+		switch {
+		case iRuled && jRuled:
+			return ri < rj
+		case iRuled != jRuled:
+			return iRuled
+		default:
+			return ordered[i].GetName() < ordered[j].GetName()
+		}
+	})
+
+	segments := make([]string, 0, 2+2*len(ordered))
+	if len(e.prefix) > 0 {
+		segments = append(segments, e.prefix)
+	}
+
+	segments = append(segments, sanitizeSegment(metricName))
+	for _, l := range ordered {
+		labelName := l.GetName()
+		if i, ok := e.rules[labelName]; ok {
+			if e.labels[i].ValueOnly {
+				labelName = ""
+			} else if len(e.labels[i].Name) > 0 {
+				labelName = e.labels[i].Name
+			}
+		}
+
+		if len(labelName) > 0 {
+			segments = append(segments, sanitizeSegment(labelName))
+		}
+
+		segments = append(segments, sanitizeSegment(l.GetValue()))
+	}
+
+	return strings.Join(segments, ".")
+}
+
+// sanitizeSegment replaces the characters that delimit names in Graphite or StatsD, along with whitespace,
+// so that a label value cannot corrupt the emitted name
+func sanitizeSegment(segment string) string {
+	if len(segment) == 0 {
+		return "_"
+	}
+
+	return strings.Map(
+		func(r rune) rune {
+			switch r {
+			case '.', ':', '|', '@', '#', ' ', '\t', '\n', '\r', '/':
+				return '_'
+			default:
+				return r
+			}
+		},
+		segment,
+	)
+}
@@ -0,0 +1,497 @@
+package xmetrics
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmitterOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o      = EmitterOptions{Protocol: Graphite}
+		)
+
+		assert.Equal("tcp", o.network())
+		assert.Equal(DefaultEmitterPeriod, o.period())
+		assert.Equal(DefaultEmitterTimeout, o.timeout())
+
+		o.Protocol = StatsD
+		assert.Equal("udp", o.network())
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			o      = EmitterOptions{Protocol: StatsD, Network: "tcp", Period: time.Minute, Timeout: time.Second}
+		)
+
+		assert.Equal("tcp", o.network())
+		assert.Equal(time.Minute, o.period())
+		assert.Equal(time.Second, o.timeout())
+	})
+}
+
+func TestNewEmitter(t *testing.T) {
+	testData := []EmitterOptions{
+		{},
+		{Protocol: "nosuch", Address: "localhost:1234"},
+		{Protocol: Graphite},
+		{Protocol: StatsD},
+	}
+
+	for _, o := range testData {
+		t.Run(string(o.Protocol), func(t *testing.T) {
+			assert := assert.New(t)
+			e, err := NewEmitter(o, prometheus.NewRegistry(), nil)
+			assert.Nil(e)
+			assert.Error(err)
+		})
+	}
+}
+
+// newEmitterTestRegistry produces a registry with one metric of each type that an Emitter translates
+func newEmitterTestRegistry(t *testing.T) (*prometheus.Registry, *prometheus.CounterVec, prometheus.Gauge) {
+	var (
+		require  = require.New(t)
+		pr       = prometheus.NewRegistry()
+		requests = prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: "requests", Help: "requests"},
+			[]string{"code", "method", "instance"},
+		)
+
+		connections = prometheus.NewGauge(prometheus.GaugeOpts{Name: "connections", Help: "connections"})
+		latency     = prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency", Help: "latency"})
+	)
+
+	require.NoError(pr.Register(requests))
+	require.NoError(pr.Register(connections))
+	require.NoError(pr.Register(latency))
+
+	requests.WithLabelValues("200", "GET", "a").Add(2.0)
+	requests.WithLabelValues("200", "GET", "b").Add(3.0)
+	requests.WithLabelValues("500", "POST", "a").Add(1.0)
+	connections.Set(12.5)
+	latency.Observe(0.25)
+	latency.Observe(0.5)
+
+	return pr, requests, connections
+}
+
+func TestEmitterName(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		pr      = prometheus.NewRegistry()
+		labels  = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Name: "test_metric", Help: "test"},
+			[]string{"zone", "host", "code", "empty"},
+		)
+	)
+
+	require.NoError(pr.Register(labels))
+	labels.WithLabelValues("us-east", "web1.example.com", "200", "").Set(1.0)
+
+	testData := []struct {
+		options  EmitterOptions
+		expected string
+	}{
+		{
+			EmitterOptions{Protocol: Graphite, Address: "localhost:2003"},
+			"test_metric.code.200.empty._.host.web1_example_com.zone.us-east",
+		},
+		{
+			EmitterOptions{
+				Protocol: Graphite,
+				Address:  "localhost:2003",
+				Prefix:   "webpa",
+				Labels: []LabelRule{
+					{Label: "host", ValueOnly: true},
+					{Label: "zone", Name: "region"},
+					{Label: "empty", Drop: true},
+				},
+			},
+			"webpa.test_metric.web1_example_com.region.us-east.code.200",
+		},
+	}
+
+	for _, record := range testData {
+		e, err := NewEmitter(record.options, pr, logging.NewTestLogger(nil, t))
+		require.NoError(err)
+
+		families, err := pr.Gather()
+		require.NoError(err)
+		require.Len(families, 1)
+		require.Len(families[0].GetMetric(), 1)
+
+		assert.Equal(record.expected, e.name(families[0].GetName(), families[0].GetMetric()[0].GetLabel()))
+	}
+}
+
+func TestEmitterFormat(t *testing.T) {
+	t.Run("Graphite", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			require  = require.New(t)
+			pr, _, _ = newEmitterTestRegistry(t)
+		)
+
+		e, err := NewEmitter(
+			EmitterOptions{
+				Protocol: Graphite,
+				Address:  "localhost:2003",
+				Labels:   []LabelRule{{Label: "instance", Drop: true}},
+			},
+			pr,
+			logging.NewTestLogger(nil, t),
+		)
+
+		require.NoError(err)
+		e.now = func() time.Time { return time.Unix(1500000000, 0) }
+
+		families, err := pr.Gather()
+		require.NoError(err)
+
+		output := e.format(families)
+		require.Len(output, 1)
+		assert.Equal(
+			"connections 12.5 1500000000\n"+
+				"latency.count 2 1500000000\n"+
+				"latency.sum 0.75 1500000000\n"+
+				"requests.code.200.method.GET 5 1500000000\n"+
+				"requests.code.500.method.POST 1 1500000000\n",
+			string(output[0]),
+		)
+	})
+
+	t.Run("StatsD", func(t *testing.T) {
+		var (
+			assert                    = assert.New(t)
+			require                   = require.New(t)
+			pr, requests, connections = newEmitterTestRegistry(t)
+		)
+
+		e, err := NewEmitter(
+			EmitterOptions{
+				Protocol: StatsD,
+				Address:  "localhost:8125",
+				Labels:   []LabelRule{{Label: "instance", Drop: true}},
+			},
+			pr,
+			logging.NewTestLogger(nil, t),
+		)
+
+		require.NoError(err)
+
+		families, err := pr.Gather()
+		require.NoError(err)
+
+		output := e.format(families)
+		require.Len(output, 1)
+		assert.Equal(
+			"connections:12.5|g\n"+
+				"latency.count:2|c\n"+
+				"latency.sum:0.75|c\n"+
+				"requests.code.200.method.GET:5|c\n"+
+				"requests.code.500.method.POST:1|c",
+			string(output[0]),
+		)
+
+		// only counters that changed are emitted again, as the change since the last emission
+		requests.WithLabelValues("200", "GET", "b").Add(4.0)
+		connections.Set(3.0)
+
+		families, err = pr.Gather()
+		require.NoError(err)
+
+		output = e.format(families)
+		require.Len(output, 1)
+		assert.Equal(
+			"connections:3|g\n"+
+				"requests.code.200.method.GET:4|c",
+			string(output[0]),
+		)
+	})
+
+	t.Run("StatsDPackets", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			pr      = prometheus.NewRegistry()
+			gauges  = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "gauge", Help: "gauge"}, []string{"id"})
+		)
+
+		require.NoError(pr.Register(gauges))
+		for i := 0; i < 200; i++ {
+			gauges.WithLabelValues(strings.Repeat("x", i%10) + string(rune('a'+i%26)) + string(rune('a'+i/26))).Set(1.0)
+		}
+
+		e, err := NewEmitter(EmitterOptions{Protocol: StatsD, Address: "localhost:8125"}, pr, logging.NewTestLogger(nil, t))
+		require.NoError(err)
+
+		families, err := pr.Gather()
+		require.NoError(err)
+
+		output := e.format(families)
+		assert.True(len(output) > 1)
+
+		lines := 0
+		for _, packet := range output {
+			assert.True(len(packet) <= maxStatsDPacketSize)
+			lines += len(strings.Split(string(packet), "\n"))
+		}
+
+		assert.Equal(200, lines)
+	})
+}
+
+func TestEmitterEmit(t *testing.T) {
+	t.Run("StatsD", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(err)
+		defer listener.Close()
+
+		pr, _, _ := newEmitterTestRegistry(t)
+		e, err := NewEmitter(
+			EmitterOptions{Protocol: StatsD, Address: listener.LocalAddr().String()},
+			pr,
+			logging.NewTestLogger(nil, t),
+		)
+
+		require.NoError(err)
+		require.NoError(e.Emit())
+
+		buffer := make([]byte, maxStatsDPacketSize)
+		listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := listener.ReadFrom(buffer)
+		require.NoError(err)
+		assert.Contains(string(buffer[:n]), "connections:12.5|g")
+		assert.Contains(string(buffer[:n]), "requests.code.200.instance.a.method.GET:2|c")
+	})
+
+	t.Run("Graphite", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(err)
+		defer listener.Close()
+
+		pr, _, _ := newEmitterTestRegistry(t)
+		e, err := NewEmitter(
+			EmitterOptions{Protocol: Graphite, Address: listener.Addr().String(), Prefix: "webpa"},
+			pr,
+			logging.NewTestLogger(nil, t),
+		)
+
+		require.NoError(err)
+		require.NoError(e.Emit())
+		defer e.conn.Close()
+
+		conn, err := listener.Accept()
+		require.NoError(err)
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		require.NoError(err)
+		assert.True(strings.HasPrefix(line, "webpa.connections 12.5 "))
+	})
+
+	t.Run("DialError", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+		)
+
+		// reserve a port, then release it so that nothing is listening
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(err)
+		address := listener.Addr().String()
+		listener.Close()
+
+		pr, _, _ := newEmitterTestRegistry(t)
+		e, err := NewEmitter(EmitterOptions{Protocol: Graphite, Address: address}, pr, logging.NewTestLogger(nil, t))
+		require.NoError(err)
+
+		assert.Error(e.Emit())
+		assert.Nil(e.conn)
+	})
+}
+
+func TestRegistryEmitter(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(err)
+	defer listener.Close()
+
+	_, err = NewRegistry(&Options{Emitter: &EmitterOptions{Protocol: "nosuch", Address: "localhost:1234"}})
+	assert.Error(err)
+
+	r, err := NewRegistry(
+		&Options{
+			Logger:                  logging.DefaultLogger(),
+			DisableGoCollector:      true,
+			DisableProcessCollector: true,
+			Emitter: &EmitterOptions{
+				Protocol: StatsD,
+				Address:  listener.LocalAddr().String(),
+				Period:   10 * time.Millisecond,
+			},
+		},
+		func() []Metric {
+			return []Metric{{Name: "emitted", Type: "gauge"}}
+		},
+	)
+
+	require.NoError(err)
+	r.NewGauge("emitted").Set(7.0)
+
+	// nothing is pushed until the registry is run
+	buffer := make([]byte, maxStatsDPacketSize)
+	listener.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, _, err = listener.ReadFrom(buffer)
+	assert.Error(err)
+
+	runnable, ok := r.(concurrent.Runnable)
+	require.True(ok)
+
+	var (
+		waitGroup sync.WaitGroup
+		shutdown  = make(chan struct{})
+	)
+
+	require.NoError(runnable.Run(&waitGroup, shutdown))
+	require.NoError(runnable.Run(&waitGroup, shutdown))
+
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := listener.ReadFrom(buffer)
+	require.NoError(err)
+	assert.Equal("test_test_emitted:7|g", string(buffer[:n]))
+
+	close(shutdown)
+	waitGroup.Wait()
+
+	// a registry without an emitter has nothing to run
+	r, err = NewRegistry(nil)
+	require.NoError(err)
+	assert.NoError(r.(concurrent.Runnable).Run(&waitGroup, shutdown))
+	r.Stop()
+}
+
+func TestEmitterStartStop(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(err)
+	defer listener.Close()
+
+	pr, _, _ := newEmitterTestRegistry(t)
+	e, err := NewEmitter(
+		EmitterOptions{Protocol: StatsD, Address: listener.LocalAddr().String(), Period: 10 * time.Millisecond},
+		pr,
+		logging.NewTestLogger(nil, t),
+	)
+
+	require.NoError(err)
+
+	// stopping an emitter that was never started does nothing
+	e.Stop()
+
+	e, err = NewEmitter(
+		EmitterOptions{Protocol: StatsD, Address: listener.LocalAddr().String(), Period: 10 * time.Millisecond},
+		pr,
+		logging.NewTestLogger(nil, t),
+	)
+
+	require.NoError(err)
+	e.Start()
+	e.Start()
+
+	buffer := make([]byte, maxStatsDPacketSize)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := listener.ReadFrom(buffer)
+	require.NoError(err)
+	assert.Contains(string(buffer[:n]), "connections:12.5|g")
+
+	e.Stop()
+	e.Stop()
+	assert.Nil(e.conn)
+}
+
+func TestEmitterCollisions(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		pr      = prometheus.NewRegistry()
+
+		gauges   = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "gauge", Help: "gauge"}, []string{"instance"})
+		counters = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "counter", Help: "counter"}, []string{"instance"})
+
+		// sanitization gives these different metrics the same emitted name
+		first  = prometheus.NewCounter(prometheus.CounterOpts{Name: "hits:total", Help: "hits:total"})
+		second = prometheus.NewCounter(prometheus.CounterOpts{Name: "hits_total", Help: "hits_total"})
+	)
+
+	require.NoError(pr.Register(gauges))
+	require.NoError(pr.Register(counters))
+	require.NoError(pr.Register(first))
+	require.NoError(pr.Register(second))
+	gauges.WithLabelValues("a").Set(1.0)
+	gauges.WithLabelValues("b").Set(2.0)
+	counters.WithLabelValues("a").Add(3.0)
+	counters.WithLabelValues("b").Add(4.0)
+	first.Add(5.0)
+	second.Add(6.0)
+
+	e, err := NewEmitter(
+		EmitterOptions{
+			Protocol: StatsD,
+			Address:  "localhost:8125",
+			Labels:   []LabelRule{{Label: "instance", Drop: true}},
+		},
+		pr,
+		logging.NewTestLogger(nil, t),
+	)
+
+	require.NoError(err)
+
+	families, err := pr.Gather()
+	require.NoError(err)
+
+	// counters of the same metric are summed, but colliding gauges and different metrics are rejected
+	output := e.format(families)
+	require.Len(output, 1)
+	assert.Equal(
+		"counter:7|c\n"+
+			"gauge:1|g\n"+
+			"hits_total:5|c",
+		string(output[0]),
+	)
+
+	assert.Equal(map[string]bool{"gauge": true, "hits_total": true}, e.collisions)
+}
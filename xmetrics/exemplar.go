@@ -0,0 +1,26 @@
+package xmetrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// TraceIDLabel is the exemplar label which holds the identifier of the trace that produced an observation
+const TraceIDLabel = "trace_id"
+
+// ExemplarObserver is implemented by observers, typically histograms, which can attach an exemplar to an
+// observation.  This is the same interface that newer prometheus clients implement, so exemplar support is
+// detected automatically.  Observers from clients without exemplar support simply record the observation.
+type ExemplarObserver interface {
+	ObserveWithExemplar(value float64, exemplar prometheus.Labels)
+}
+
+// ObserveWithTraceID records an observation.  If traceID is not empty and the Observer supports exemplars,
+// the trace ID is attached as an exemplar, which allows a latency bucket to be linked to a trace.
+func ObserveWithTraceID(o Observer, value float64, traceID string) {
+	if len(traceID) > 0 {
+		if eo, ok := o.(ExemplarObserver); ok {
+			eo.ObserveWithExemplar(value, prometheus.Labels{TraceIDLabel: traceID})
+			return
+		}
+	}
+
+	o.Observe(value)
+}
@@ -0,0 +1,48 @@
+package xmetrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockExemplarObserver struct {
+	mock.Mock
+}
+
+func (m *mockExemplarObserver) Observe(value float64) {
+	m.Called(value)
+}
+
+func (m *mockExemplarObserver) ObserveWithExemplar(value float64, exemplar prometheus.Labels) {
+	m.Called(value, exemplar)
+}
+
+type mockObserver struct {
+	mock.Mock
+}
+
+func (m *mockObserver) Observe(value float64) {
+	m.Called(value)
+}
+
+func TestObserveWithTraceID(t *testing.T) {
+	t.Run("Exemplar", func(t *testing.T) {
+		o := new(mockExemplarObserver)
+		o.On("ObserveWithExemplar", 1.5, prometheus.Labels{TraceIDLabel: "4bf92f3577b34da6a3ce929d0e0e4736"}).Once()
+		o.On("Observe", 2.5).Once()
+
+		ObserveWithTraceID(o, 1.5, "4bf92f3577b34da6a3ce929d0e0e4736")
+		ObserveWithTraceID(o, 2.5, "")
+		o.AssertExpectations(t)
+	})
+
+	t.Run("NoExemplar", func(t *testing.T) {
+		o := new(mockObserver)
+		o.On("Observe", 1.5).Once()
+
+		ObserveWithTraceID(o, 1.5, "4bf92f3577b34da6a3ce929d0e0e4736")
+		o.AssertExpectations(t)
+	})
+}
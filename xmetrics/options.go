@@ -44,6 +44,13 @@ type Options struct {
 	// Any duplicate metrics will cause an error.  Duplicate metrics are defined as those having the same namespace,
 	// subsystem, and name.
 	Metrics []Metric
+
+	// Emitter, if set, configures a periodic push of all metrics to a StatsD or Graphite sink.  Metrics
+	// remain available for scraping regardless.  This field is optional.
+	//
+	// NewRegistry does not start the push.  The Registry it returns implements concurrent.Runnable, and
+	// running it starts the push.
+	Emitter *EmitterOptions
 }
 
 func (o *Options) logger() log.Logger {
@@ -114,6 +121,14 @@ func (o *Options) disableProcessCollector() bool {
 	return false
 }
 
+func (o *Options) emitter() *EmitterOptions {
+	if o != nil {
+		return o.Emitter
+	}
+
+	return nil
+}
+
 // Module acts as a metrics module function using the (normally) injected metrics.
 func (o *Options) Module() []Metric {
 	if o != nil {
@@ -20,6 +20,7 @@ func testOptionsDefault(o *Options, t *testing.T) {
 	assert.False(o.disableProcessCollector())
 	assert.NotNil(o.registry())
 	assert.Empty(o.Module())
+	assert.Nil(o.emitter())
 }
 
 func testOptionsCustom(t *testing.T) {
@@ -39,6 +40,7 @@ func testOptionsCustom(t *testing.T) {
 					Type: "counter",
 				},
 			},
+			Emitter: &EmitterOptions{Protocol: StatsD, Address: "localhost:8125"},
 		}
 	)
 
@@ -58,6 +60,8 @@ func testOptionsCustom(t *testing.T) {
 		},
 		o.Module(),
 	)
+
+	assert.Equal(&EmitterOptions{Protocol: StatsD, Address: "localhost:8125"}, o.emitter())
 }
 
 func TestOptions(t *testing.T) {
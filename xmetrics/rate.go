@@ -0,0 +1,194 @@
+package xmetrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/concurrent"
+)
+
+const (
+	// DefaultRateWindow is the window over which a Rate is computed when no window is supplied
+	DefaultRateWindow = time.Minute
+
+	// DefaultRateInterval is how often a RatePublisher samples and publishes when no interval is supplied
+	DefaultRateInterval = 10 * time.Second
+
+	// RatePublisherName is the name of a RatePublisher's scheduled task
+	RatePublisherName = "rate-publisher"
+)
+
+// rateSample is the cumulative total of a Rate at a point in time
+type rateSample struct {
+	when  time.Time
+	total float64
+}
+
+// Rate tracks the delta and rate of change of a counter over a sliding window.  Rate is an Adder, and each
+// delta is passed along to an optional delegate, which allows a Rate to be placed in front of an existing
+// counter.  This allows pre-computed rates, e.g. messages per second over the last minute, to be exposed
+// as gauges for alerting systems which cannot compute rates themselves.
+//
+// The window slides each time Sample is called.  Until the first sample falls out of the window, rates
+// are computed since the Rate was created.
+type Rate struct {
+	window   time.Duration
+	delegate Adder
+	now      func() time.Time
+
+	lock    sync.Mutex
+	total   float64
+	samples []rateSample
+}
+
+// NewRate creates a Rate with the given window and optional delegate.  If window is nonpositive,
+// DefaultRateWindow is used.
+func NewRate(window time.Duration, delegate Adder) *Rate {
+	return newRate(window, delegate, time.Now)
+}
+
+func newRate(window time.Duration, delegate Adder, now func() time.Time) *Rate {
+	if window < 1 {
+		window = DefaultRateWindow
+	}
+
+	return &Rate{
+		window:   window,
+		delegate: delegate,
+		now:      now,
+		samples:  []rateSample{{when: now()}},
+	}
+}
+
+// Add increments the cumulative total of this Rate, and adds the delta to any delegate
+func (r *Rate) Add(delta float64) {
+	r.lock.Lock()
+	r.total += delta
+	r.lock.Unlock()
+
+	if r.delegate != nil {
+		r.delegate.Add(delta)
+	}
+}
+
+// Sample records the current total, and discards any samples that have fallen out of the window.  The oldest
+// remaining sample is the baseline for computations, so rates should be sampled at regular intervals that
+// evenly divide the window.
+func (r *Rate) Sample() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	r.samples = append(r.samples, rateSample{when: now, total: r.total})
+
+	var (
+		start    = now.Add(-r.window)
+		baseline = 0
+	)
+
+	for baseline < len(r.samples)-1 && r.samples[baseline].when.Before(start) {
+		baseline++
+	}
+
+	r.samples = r.samples[baseline:]
+}
+
+// rebase discards all samples, making the current total the baseline for subsequent computations
+func (r *Rate) rebase() {
+	r.lock.Lock()
+	r.samples = append(r.samples[:0], rateSample{when: r.now(), total: r.total})
+	r.lock.Unlock()
+}
+
+// compute returns the change in the total since the baseline sample, along with the elapsed time
+func (r *Rate) compute() (float64, time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	baseline := r.samples[0]
+	return r.total - baseline.total, r.now().Sub(baseline.when)
+}
+
+// Delta returns the change in the total over the window
+func (r *Rate) Delta() float64 {
+	delta, _ := r.compute()
+	return delta
+}
+
+// PerSecond returns the average rate of change per second over the window
+func (r *Rate) PerSecond() float64 {
+	delta, elapsed := r.compute()
+	if elapsed <= 0 {
+		return 0.0
+	}
+
+	return delta / elapsed.Seconds()
+}
+
+// RateGauge associates a Rate with the gauges that expose it.  Either gauge may be nil.
+type RateGauge struct {
+	Rate      *Rate
+	PerSecond Setter
+	Delta     Setter
+}
+
+// publish samples the rate and updates the gauges
+func (rg RateGauge) publish() {
+	rg.Rate.Sample()
+	if rg.PerSecond != nil {
+		rg.PerSecond.Set(rg.Rate.PerSecond())
+	}
+
+	if rg.Delta != nil {
+		rg.Delta.Set(rg.Rate.Delta())
+	}
+}
+
+// RatePublisher periodically samples a set of Rates and publishes them to gauges.  RatePublisher
+// implements concurrent.Runnable.
+type RatePublisher struct {
+	// Interval is the time between publications.  If nonpositive, DefaultRateInterval is used.
+	Interval time.Duration
+
+	// Gauges are the rates to publish
+	Gauges []RateGauge
+}
+
+// Publish samples each rate and updates its gauges immediately
+func (rp *RatePublisher) Publish() {
+	for _, rg := range rp.Gauges {
+		rg.publish()
+	}
+}
+
+// Run starts a concurrent.Scheduler that publishes at each interval until the shutdown channel is closed.
+// The first run happens immediately and only records a baseline for each rate, so that published rates never
+// include activity from before the publisher started.
+func (rp *RatePublisher) Run(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
+	interval := rp.Interval
+	if interval < 1 {
+		interval = DefaultRateInterval
+	}
+
+	// the scheduled task always runs on the same goroutine, so started needs no synchronization
+	started := false
+	return concurrent.NewScheduler(
+		concurrent.ScheduleOptions{
+			Name:       RatePublisherName,
+			Period:     interval,
+			RunOnStart: true,
+		},
+		func() {
+			if !started {
+				started = true
+				for _, rg := range rp.Gauges {
+					rg.Rate.rebase()
+				}
+
+				return
+			}
+
+			rp.Publish()
+		},
+	).Run(waitGroup, shutdown)
+}
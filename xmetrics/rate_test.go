@@ -0,0 +1,122 @@
+package xmetrics
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(DefaultRateWindow, NewRate(0, nil).window)
+	assert.Equal(time.Hour, NewRate(time.Hour, nil).window)
+}
+
+func TestRate(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		current  = time.Now()
+		delegate = generic.NewCounter("delegate")
+		rate     = newRate(time.Minute, delegate, func() time.Time { return current })
+	)
+
+	assert.Zero(rate.Delta())
+	assert.Zero(rate.PerSecond())
+
+	// before the window has filled, rates are computed since creation
+	rate.Add(30.0)
+	current = current.Add(30 * time.Second)
+	rate.Sample()
+	assert.Equal(30.0, rate.Delta())
+	assert.Equal(1.0, rate.PerSecond())
+	assert.Equal(30.0, delegate.Value())
+
+	rate.Add(60.0)
+	current = current.Add(30 * time.Second)
+	rate.Sample()
+	assert.Equal(90.0, rate.Delta())
+	assert.Equal(1.5, rate.PerSecond())
+
+	// the creation sample falls out of the window
+	rate.Add(120.0)
+	current = current.Add(30 * time.Second)
+	rate.Sample()
+	assert.Len(rate.samples, 3)
+	assert.Equal(180.0, rate.Delta())
+	assert.Equal(3.0, rate.PerSecond())
+
+	// rates reflect the current total between samples
+	rate.Add(60.0)
+	current = current.Add(15 * time.Second)
+	assert.Equal(240.0, rate.Delta())
+	assert.Equal(240.0/75.0, rate.PerSecond())
+	assert.Equal(270.0, delegate.Value())
+
+	// rebasing discards the window
+	rate.rebase()
+	assert.Len(rate.samples, 1)
+	assert.Zero(rate.Delta())
+
+	// a long idle period leaves only the most recent sample as the baseline
+	current = current.Add(time.Hour)
+	rate.Sample()
+	assert.Len(rate.samples, 1)
+	assert.Zero(rate.Delta())
+	assert.Zero(rate.PerSecond())
+}
+
+func TestRatePublisher(t *testing.T) {
+	t.Run("Publish", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			current   = time.Now()
+			rate      = newRate(time.Minute, nil, func() time.Time { return current })
+			perSecond = generic.NewGauge("perSecond")
+			delta     = generic.NewGauge("delta")
+			publisher = RatePublisher{
+				Gauges: []RateGauge{
+					{Rate: rate, PerSecond: perSecond, Delta: delta},
+					{Rate: rate},
+				},
+			}
+		)
+
+		rate.Add(20.0)
+		current = current.Add(10 * time.Second)
+		publisher.Publish()
+		assert.Equal(2.0, perSecond.Value())
+		assert.Equal(20.0, delta.Value())
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		var (
+			assert    = assert.New(t)
+			rate      = NewRate(time.Minute, nil)
+			delta     = generic.NewGauge("delta")
+			publisher = RatePublisher{Interval: time.Millisecond, Gauges: []RateGauge{{Rate: rate, Delta: delta}}}
+			waitGroup = new(sync.WaitGroup)
+			shutdown  = make(chan struct{})
+		)
+
+		// activity before the publisher starts is excluded by the baseline taken on the first run
+		rate.Add(1000.0)
+		assert.NoError(publisher.Run(waitGroup, shutdown))
+		for timeout := time.After(5 * time.Second); delta.Value() == 0.0; {
+			rate.Add(1.0)
+			select {
+			case <-timeout:
+				assert.Fail("the rate was not published")
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+
+		close(shutdown)
+		waitGroup.Wait()
+		assert.True(delta.Value() < 1000.0)
+	})
+}
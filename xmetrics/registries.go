@@ -0,0 +1,115 @@
+package xmetrics
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrRegistryNameRequired = errors.New("A name is required for a registry")
+	ErrRegistryRequired     = errors.New("A non-nil registry is required")
+	ErrRegistryExists       = errors.New("A registry with that name already exists")
+	ErrNoSuchRegistry       = errors.New("No registry with that name exists")
+)
+
+// Registries is a set of named, isolated Registry instances within a single process.  This is useful when
+// one process hosts more than one logical service, such as a test harness that embeds a server and its
+// clients, each of which should expose only its own metrics.
+//
+// There is always exactly one default Registry, which must be chosen explicitly.  This package never
+// creates or consults a package-level Registry:  library code should accept a go-kit provider.Provider,
+// and applications should pass the appropriate Registry from a Registries to each library.
+type Registries struct {
+	lock        sync.RWMutex
+	defaultName string
+	registries  map[string]Registry
+}
+
+// NewRegistries creates a Registries with the given Registry as its default.  This function panics if the
+// default name is empty or the default Registry is nil.
+func NewRegistries(defaultName string, defaultRegistry Registry) *Registries {
+	if len(defaultName) == 0 {
+		panic(ErrRegistryNameRequired)
+	}
+
+	if defaultRegistry == nil {
+		panic(ErrRegistryRequired)
+	}
+
+	return &Registries{
+		defaultName: defaultName,
+		registries:  map[string]Registry{defaultName: defaultRegistry},
+	}
+}
+
+// Add associates a Registry with a name.  A name cannot be reused.
+func (rs *Registries) Add(name string, r Registry) error {
+	if len(name) == 0 {
+		return ErrRegistryNameRequired
+	}
+
+	if r == nil {
+		return ErrRegistryRequired
+	}
+
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	if _, ok := rs.registries[name]; ok {
+		return ErrRegistryExists
+	}
+
+	rs.registries[name] = r
+	return nil
+}
+
+// Get returns the Registry associated with the given name
+func (rs *Registries) Get(name string) (Registry, bool) {
+	rs.lock.RLock()
+	r, ok := rs.registries[name]
+	rs.lock.RUnlock()
+	return r, ok
+}
+
+// Default returns the default Registry
+func (rs *Registries) Default() Registry {
+	rs.lock.RLock()
+	r := rs.registries[rs.defaultName]
+	rs.lock.RUnlock()
+	return r
+}
+
+// DefaultName returns the name of the default Registry
+func (rs *Registries) DefaultName() string {
+	rs.lock.RLock()
+	name := rs.defaultName
+	rs.lock.RUnlock()
+	return name
+}
+
+// SetDefault changes the default Registry to the one with the given name, which must already have been added
+func (rs *Registries) SetDefault(name string) error {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	if _, ok := rs.registries[name]; !ok {
+		return ErrNoSuchRegistry
+	}
+
+	rs.defaultName = name
+	return nil
+}
+
+// Names returns the sorted names of all the registries in this set
+func (rs *Registries) Names() []string {
+	rs.lock.RLock()
+	names := make([]string, 0, len(rs.registries))
+	for name := range rs.registries {
+		names = append(names, name)
+	}
+
+	rs.lock.RUnlock()
+	sort.Strings(names)
+	return names
+}
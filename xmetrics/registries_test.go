@@ -0,0 +1,82 @@
+package xmetrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewRegistriesInvalid(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		r      = MustNewRegistry(nil)
+	)
+
+	assert.Panics(func() { NewRegistries("", r) })
+	assert.Panics(func() { NewRegistries("default", nil) })
+}
+
+func testRegistriesAddGet(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		first  = MustNewRegistry(&Options{Namespace: "first", DisableGoCollector: true, DisableProcessCollector: true})
+		second = MustNewRegistry(&Options{Namespace: "second", DisableGoCollector: true, DisableProcessCollector: true})
+		rs     = NewRegistries("first", first)
+	)
+
+	assert.Equal("first", rs.DefaultName())
+	assert.Equal(first, rs.Default())
+
+	assert.Equal(ErrRegistryNameRequired, rs.Add("", second))
+	assert.Equal(ErrRegistryRequired, rs.Add("second", nil))
+	assert.Equal(ErrRegistryExists, rs.Add("first", second))
+	require.NoError(rs.Add("second", second))
+	assert.Equal([]string{"first", "second"}, rs.Names())
+
+	actual, ok := rs.Get("second")
+	assert.True(ok)
+	assert.Equal(second, actual)
+
+	actual, ok = rs.Get("nosuch")
+	assert.False(ok)
+	assert.Nil(actual)
+
+	// the registries are isolated, so the same metric name can be used in each
+	rs.Default().NewCounter("requests").Add(1.0)
+	actual, _ = rs.Get("second")
+	actual.NewCounter("requests").Add(2.0)
+
+	for name, expectedFQN := range map[string]string{"first": "first_test_requests", "second": "second_test_requests"} {
+		r, _ := rs.Get(name)
+		families, err := r.Gather()
+		require.NoError(err)
+		require.Len(families, 1)
+		assert.Equal(expectedFQN, families[0].GetName())
+	}
+}
+
+func testRegistriesSetDefault(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		first  = MustNewRegistry(nil)
+		second = MustNewRegistry(nil)
+		rs     = NewRegistries("first", first)
+	)
+
+	assert.Equal(ErrNoSuchRegistry, rs.SetDefault("second"))
+	assert.Equal(first, rs.Default())
+
+	assert.NoError(rs.Add("second", second))
+	assert.NoError(rs.SetDefault("second"))
+	assert.Equal("second", rs.DefaultName())
+	assert.Equal(second, rs.Default())
+}
+
+func TestRegistries(t *testing.T) {
+	t.Run("Invalid", testNewRegistriesInvalid)
+	t.Run("AddGet", testRegistriesAddGet)
+	t.Run("SetDefault", testRegistriesSetDefault)
+}
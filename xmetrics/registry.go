@@ -2,6 +2,7 @@ package xmetrics
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/go-kit/kit/log"
@@ -47,6 +48,7 @@ type registry struct {
 	namespace     string
 	subsystem     string
 	preregistered map[string]prometheus.Collector
+	emitter       *Emitter
 }
 
 func (r *registry) NewCounterVec(name string) *prometheus.CounterVec {
@@ -215,8 +217,22 @@ func (r *registry) NewSummaryVecEx(namespace, subsystem, name string) *prometheu
 	return summaryVec
 }
 
-// Stop is just here to implement metrics.Provider.  This method is a noop.
+// Run starts pushing metrics to the emitter sink, if this registry was configured with one.  Otherwise, this
+// method is a noop.  This method implements concurrent.Runnable.
+func (r *registry) Run(waitGroup *sync.WaitGroup, shutdown <-chan struct{}) error {
+	if r.emitter != nil {
+		return r.emitter.Run(waitGroup, shutdown)
+	}
+
+	return nil
+}
+
+// Stop implements metrics.Provider.  If this registry is pushing metrics to an emitter sink, that is halted.
+// Otherwise, this method is a noop.
 func (r *registry) Stop() {
+	if r.emitter != nil {
+		r.emitter.Stop()
+	}
 }
 
 // NewRegistry creates an xmetrics.Registry from an externally supplied set of Options and a set
@@ -289,6 +305,21 @@ func NewRegistry(o *Options, modules ...Module) (Registry, error) {
 		r.preregistered[name] = c
 	}
 
+	if eo := o.emitter(); eo != nil {
+		e, err := NewEmitter(*eo, pr, logger)
+		if err != nil {
+			logger.Log(
+				level.Key(), level.ErrorValue(),
+				logging.MessageKey(), "unable to create metrics emitter",
+				logging.ErrorKey(), err,
+			)
+
+			return nil, err
+		}
+
+		r.emitter = e
+	}
+
 	return r, nil
 }
 
@@ -0,0 +1,102 @@
+package xmetrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// OutcomeLabel is the histogram label that a Timer uses to distinguish how an operation ended
+	OutcomeLabel = "outcome"
+
+	// SuccessOutcome indicates an operation that completed normally
+	SuccessOutcome = "success"
+
+	// ErrorOutcome indicates an operation that failed for a reason other than cancellation or timeout
+	ErrorOutcome = "error"
+
+	// CanceledOutcome indicates an operation whose context was canceled
+	CanceledOutcome = "canceled"
+
+	// TimeoutOutcome indicates an operation whose context deadline was exceeded
+	TimeoutOutcome = "timeout"
+)
+
+// Outcome determines the OutcomeLabel value for an operation.  Cancellation and timeouts, whether
+// reported by err or by the context, take precedence over any other error.  Errors that wrap
+// context.Canceled or context.DeadlineExceeded are treated as those errors.
+func Outcome(ctx context.Context, err error) string {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+	}
+
+	switch {
+	case err == nil:
+		return SuccessOutcome
+	case errors.Is(err, context.Canceled):
+		return CanceledOutcome
+	case errors.Is(err, context.DeadlineExceeded):
+		return TimeoutOutcome
+	default:
+		return ErrorOutcome
+	}
+}
+
+// Timer records the latencies of operations, in seconds, into a histogram.  Each observation is labeled
+// with OutcomeLabel, so that canceled and timed out operations don't skew the latencies of operations that
+// actually ran to completion.
+type Timer struct {
+	histogram metrics.Histogram
+	now       func() time.Time
+}
+
+// NewTimer creates a Timer that records into the given histogram
+func NewTimer(histogram metrics.Histogram) Timer {
+	return Timer{
+		histogram: histogram,
+		now:       time.Now,
+	}
+}
+
+// Start begins timing an operation that is bound to the given context
+func (t Timer) Start(ctx context.Context) Stopwatch {
+	return Stopwatch{
+		timer: t,
+		ctx:   ctx,
+		start: t.now(),
+	}
+}
+
+// ObserveDuring times the given function, labeling the observation using both the context and
+// the error returned by the function.  The function's error is returned.
+func (t Timer) ObserveDuring(ctx context.Context, f func(context.Context) error) error {
+	s := t.Start(ctx)
+	err := f(ctx)
+	s.StopWithError(err)
+	return err
+}
+
+// Stopwatch is a single timing operation in progress
+type Stopwatch struct {
+	timer Timer
+	ctx   context.Context
+	start time.Time
+}
+
+// Stop records the time elapsed since Start, labeled according to the state of the operation's context.
+// The elapsed time is returned.
+func (s Stopwatch) Stop() time.Duration {
+	return s.StopWithError(nil)
+}
+
+// StopWithError is like Stop, except that the given error is also used to label the observation
+func (s Stopwatch) StopWithError(err error) time.Duration {
+	elapsed := s.timer.now().Sub(s.start)
+	s.timer.histogram.With(OutcomeLabel, Outcome(s.ctx, err)).Observe(elapsed.Seconds())
+	return elapsed
+}
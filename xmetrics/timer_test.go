@@ -0,0 +1,134 @@
+package xmetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+// capturingHistogram records the label values and observations made through it
+type capturingHistogram struct {
+	labelsAndValues []string
+	observations    *[]observation
+}
+
+type observation struct {
+	labelsAndValues []string
+	value           float64
+}
+
+func (ch capturingHistogram) With(labelsAndValues ...string) metrics.Histogram {
+	ch.labelsAndValues = append(append([]string{}, ch.labelsAndValues...), labelsAndValues...)
+	return ch
+}
+
+func (ch capturingHistogram) Observe(value float64) {
+	*ch.observations = append(*ch.observations, observation{ch.labelsAndValues, value})
+}
+
+func TestOutcome(t *testing.T) {
+	var (
+		canceled, cancel = context.WithCancel(context.Background())
+		timedOut, expire = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		otherError       = errors.New("expected")
+	)
+
+	cancel()
+	defer expire()
+	testData := []struct {
+		ctx      context.Context
+		err      error
+		expected string
+	}{
+		{context.Background(), nil, SuccessOutcome},
+		{context.Background(), otherError, ErrorOutcome},
+		{context.Background(), context.Canceled, CanceledOutcome},
+		{context.Background(), context.DeadlineExceeded, TimeoutOutcome},
+		{canceled, nil, CanceledOutcome},
+		{canceled, otherError, ErrorOutcome},
+		{timedOut, nil, TimeoutOutcome},
+		{timedOut, context.Canceled, TimeoutOutcome},
+		{context.Background(), fmt.Errorf("wrapped: %w", context.Canceled), CanceledOutcome},
+		{context.Background(), &url.Error{Op: "Get", URL: "http://example.com", Err: context.DeadlineExceeded}, TimeoutOutcome},
+		{timedOut, fmt.Errorf("wrapped: %w", context.Canceled), TimeoutOutcome},
+	}
+
+	for i, record := range testData {
+		t.Logf("%d: %#v", i, record)
+		assert.Equal(t, record.expected, Outcome(record.ctx, record.err))
+	}
+}
+
+func newTestTimer(observations *[]observation, elapsed time.Duration) Timer {
+	var (
+		start = time.Now()
+		calls = 0
+	)
+
+	t := NewTimer(capturingHistogram{observations: observations})
+	t.now = func() time.Time {
+		calls++
+		if calls%2 == 1 {
+			return start
+		}
+
+		return start.Add(elapsed)
+	}
+
+	return t
+}
+
+func TestTimer(t *testing.T) {
+	t.Run("Stop", func(t *testing.T) {
+		var (
+			assert       = assert.New(t)
+			observations []observation
+			timer        = newTestTimer(&observations, 1500*time.Millisecond)
+
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+
+		assert.Equal(1500*time.Millisecond, timer.Start(ctx).Stop())
+		cancel()
+		assert.Equal(1500*time.Millisecond, timer.Start(ctx).Stop())
+
+		assert.Equal(
+			[]observation{
+				{[]string{OutcomeLabel, SuccessOutcome}, 1.5},
+				{[]string{OutcomeLabel, CanceledOutcome}, 1.5},
+			},
+			observations,
+		)
+	})
+
+	t.Run("ObserveDuring", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			observations  []observation
+			timer         = newTestTimer(&observations, 2*time.Second)
+			expectedError = errors.New("expected")
+		)
+
+		assert.NoError(timer.ObserveDuring(context.Background(), func(context.Context) error { return nil }))
+		assert.Equal(expectedError, timer.ObserveDuring(context.Background(), func(context.Context) error { return expectedError }))
+		assert.Equal(
+			context.DeadlineExceeded,
+			timer.ObserveDuring(context.Background(), func(context.Context) error { return context.DeadlineExceeded }),
+		)
+
+		assert.Equal(
+			[]observation{
+				{[]string{OutcomeLabel, SuccessOutcome}, 2.0},
+				{[]string{OutcomeLabel, ErrorOutcome}, 2.0},
+				{[]string{OutcomeLabel, TimeoutOutcome}, 2.0},
+			},
+			observations,
+		)
+	})
+}
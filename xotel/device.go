@@ -0,0 +1,134 @@
+package xotel
+
+import (
+	"context"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/Comcast/webpa-common/wrp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// RouteSpanName is the name of the span created for each message routed to a device
+	RouteSpanName = "device.Route"
+
+	// ReceiveSpanName is the name of the span created for each message received from a device
+	ReceiveSpanName = "device.Receive"
+
+	// WRPSourceKey is the span attribute holding a WRP message's source
+	WRPSourceKey = attribute.Key("wrp.source")
+
+	// WRPDestinationKey is the span attribute holding a WRP message's destination
+	WRPDestinationKey = attribute.Key("wrp.destination")
+
+	// WRPTransactionKey is the span attribute holding a WRP message's transaction uuid
+	WRPTransactionKey = attribute.Key("wrp.transaction_uuid")
+
+	// WRPMessageTypeKey is the span attribute holding a WRP message's type
+	WRPMessageTypeKey = attribute.Key("wrp.msg_type")
+)
+
+// messageAttributes produces the span attributes that describe a WRP message
+func messageAttributes(m *wrp.Message) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		WRPMessageTypeKey.String(m.Type.FriendlyName()),
+		WRPSourceKey.String(m.Source),
+		WRPDestinationKey.String(m.Destination),
+	}
+
+	if len(m.TransactionUUID) > 0 {
+		attributes = append(attributes, WRPTransactionKey.String(m.TransactionUUID))
+	}
+
+	return attributes
+}
+
+type tracingRouter struct {
+	next   device.Router
+	tracer trace.Tracer
+	o      Options
+}
+
+// NewRouter decorates a device.Router so that each routed message gets a span.  The span is a child of any span
+// in the request's context.  When the request's message is a *wrp.Message, the trace context is also injected into
+// a copy of that message's metadata, and the copy is what is sent to the device.  The caller's request is never
+// modified.
+func NewRouter(o Options, next device.Router) device.Router {
+	if next == nil {
+		panic("A device.Router is required")
+	}
+
+	return &tracingRouter{
+		next:   next,
+		tracer: o.tracer(),
+		o:      o,
+	}
+}
+
+func (tr *tracingRouter) Route(request *device.Request) (*device.Response, error) {
+	ctx, span := tr.tracer.Start(
+		request.Context(),
+		RouteSpanName,
+		trace.WithSpanKind(trace.SpanKindProducer),
+	)
+
+	defer span.End()
+
+	// copy the whole request, so that every field, such as TTL, is carried through
+	routed := *request
+	if m, ok := request.Message.(*wrp.Message); ok {
+		span.SetAttributes(messageAttributes(m)...)
+
+		traced := *m
+		traced.Metadata = make(map[string]string, len(m.Metadata))
+		for k, v := range m.Metadata {
+			traced.Metadata[k] = v
+		}
+
+		InjectMessage(ctx, tr.o.propagator(), &traced)
+
+		// the original contents no longer match the message, so the device infrastructure must reencode it
+		routed.Message = &traced
+		routed.Contents = nil
+	}
+
+	response, err := tr.next.Route(routed.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return response, err
+}
+
+// NewListener produces a device.Listener that creates a span for each message received from a device.  Any trace
+// context carried in the message's metadata becomes the parent of the span.
+func NewListener(o Options) device.Listener {
+	var (
+		tracer     = o.tracer()
+		propagator = o.propagator()
+	)
+
+	return func(e *device.Event) {
+		if e.Type != device.MessageReceived {
+			return
+		}
+
+		m, ok := e.Message.(*wrp.Message)
+		if !ok {
+			return
+		}
+
+		ctx := ExtractMessage(context.Background(), propagator, m)
+		_, span := tracer.Start(
+			ctx,
+			ReceiveSpanName,
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(messageAttributes(m)...),
+		)
+
+		span.End()
+	}
+}
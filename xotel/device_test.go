@@ -0,0 +1,139 @@
+package xotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewRouter(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { NewRouter(Options{}, nil) })
+}
+
+func TestTracingRouterMessage(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		o, recorder = newTestOptions()
+		next        = new(mockRouter)
+		router      = NewRouter(o, next)
+
+		original = &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Source:          "dns:talaria",
+			Destination:     "mac:112233445566/config",
+			TransactionUUID: "abc123",
+			Metadata:        map[string]string{"existing": "value"},
+		}
+
+		expected = new(device.Response)
+		request  = (&device.Request{Message: original, Format: wrp.Msgpack, Contents: []byte("original contents"), TTL: time.Minute}).
+				WithContext(context.Background())
+
+		routed *device.Request
+	)
+
+	next.On("Route", mock.AnythingOfType("*device.Request")).Return(expected, error(nil)).Once().
+		Run(func(arguments mock.Arguments) { routed = arguments.Get(0).(*device.Request) })
+
+	actual, err := router.Route(request)
+	assert.Equal(expected, actual)
+	assert.NoError(err)
+	next.AssertExpectations(t)
+
+	// the original message is untouched
+	assert.Equal(map[string]string{"existing": "value"}, original.Metadata)
+
+	require.NotNil(routed)
+	assert.Empty(routed.Contents)
+	assert.Equal(wrp.Msgpack, routed.Format)
+	assert.Equal(time.Minute, routed.TTL)
+
+	// the caller's request is untouched
+	assert.Equal([]byte("original contents"), request.Contents)
+	assert.Equal(original, request.Message)
+	assert.False(trace.SpanContextFromContext(request.Context()).IsValid())
+
+	traced := routed.Message.(*wrp.Message)
+	assert.Equal("value", traced.Metadata["existing"])
+	assert.Equal(original.Destination, traced.Destination)
+
+	spans := recorder.Ended()
+	require.Len(spans, 1)
+	assert.Equal(RouteSpanName, spans[0].Name())
+	assert.Equal(trace.SpanKindProducer, spans[0].SpanKind())
+	assert.Contains(spans[0].Attributes(), WRPDestinationKey.String("mac:112233445566/config"))
+	assert.Contains(spans[0].Attributes(), WRPTransactionKey.String("abc123"))
+	assert.Equal(spans[0].SpanContext(), trace.SpanContextFromContext(routed.Context()))
+
+	extracted := trace.SpanContextFromContext(ExtractMessage(context.Background(), propagation.TraceContext{}, traced))
+	assert.Equal(spans[0].SpanContext().SpanID(), extracted.SpanID())
+}
+
+func TestTracingRouterError(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		o, recorder = newTestOptions()
+		next        = new(mockRouter)
+		router      = NewRouter(o, next)
+		expected    = errors.New("expected")
+		request     = &device.Request{Message: new(wrp.SimpleEvent), TTL: time.Minute}
+
+		routed *device.Request
+	)
+
+	next.On("Route", mock.AnythingOfType("*device.Request")).Return(nil, expected).Once().
+		Run(func(arguments mock.Arguments) { routed = arguments.Get(0).(*device.Request) })
+
+	actual, err := router.Route(request)
+	assert.Nil(actual)
+	assert.Equal(expected, err)
+	next.AssertExpectations(t)
+
+	require.NotNil(routed)
+	assert.Equal(request.Message, routed.Message)
+	assert.Equal(time.Minute, routed.TTL)
+	assert.False(trace.SpanContextFromContext(request.Context()).IsValid())
+
+	spans := recorder.Ended()
+	require.Len(spans, 1)
+	assert.Equal(codes.Error, spans[0].Status().Code)
+}
+
+func TestNewListener(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		o, recorder = newTestOptions()
+		listener    = NewListener(o)
+
+		parentCtx, parent = o.tracer().Start(context.Background(), "parent")
+		message           = &wrp.Message{Type: wrp.SimpleEventMessageType, Source: "mac:112233445566", Destination: "event:device-status"}
+	)
+
+	parent.End()
+	InjectMessage(parentCtx, propagation.TraceContext{}, message)
+
+	listener(&device.Event{Type: device.Connect})
+	listener(&device.Event{Type: device.MessageReceived, Message: new(wrp.SimpleEvent)})
+	listener(&device.Event{Type: device.MessageReceived, Message: message})
+
+	spans := recorder.Ended()
+	require.Len(spans, 2)
+	assert.Equal(ReceiveSpanName, spans[1].Name())
+	assert.Equal(trace.SpanKindConsumer, spans[1].SpanKind())
+	assert.Equal(parent.SpanContext().SpanID(), spans[1].Parent().SpanID())
+	assert.Contains(spans[1].Attributes(), WRPSourceKey.String("mac:112233445566"))
+}
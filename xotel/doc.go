@@ -0,0 +1,18 @@
+/*
+Package xotel integrates OpenTelemetry distributed tracing with the HTTP servers, fanout handlers, and device
+routing in this library.  Use of this package is optional.  Nothing elsewhere in the library depends on it.
+
+Server spans are created by an Alice-style constructor, which continues any trace propagated in the request headers:
+
+	server.RegisterMiddleware("tracing", func(log.Logger) (alice.Constructor, error) {
+		return xotel.NewServerMiddleware(xotel.Options{}), nil
+	})
+
+Fanout endpoints each get a child span by decorating the fanout transactor:
+
+	fanout.New(endpoints, fanout.WithTransactor(xotel.NewTransactor(xotel.Options{}, client.Do)))
+
+Device message dispatch is traced by decorating a device.Router.  Trace context is carried to and from devices in
+the WRP message metadata, using the same propagation format as HTTP headers.
+*/
+package xotel
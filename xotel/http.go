@@ -0,0 +1,189 @@
+package xotel
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/Comcast/webpa-common/xhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// HTTPMethodKey is the span attribute holding an HTTP request's method
+	HTTPMethodKey = attribute.Key("http.method")
+
+	// HTTPURLKey is the span attribute holding the URL of an outbound HTTP request
+	HTTPURLKey = attribute.Key("http.url")
+
+	// HTTPTargetKey is the span attribute holding the request URI of an inbound HTTP request
+	HTTPTargetKey = attribute.Key("http.target")
+
+	// HTTPStatusCodeKey is the span attribute holding an HTTP response's status code
+	HTTPStatusCodeKey = attribute.Key("http.status_code")
+)
+
+// statusWriter records the status code written to a response.  It forwards http.Flusher and http.Hijacker, so that
+// tracing does not break streaming responses or protocol upgrades such as websockets.
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (sw *statusWriter) WriteHeader(statusCode int) {
+	if sw.statusCode == 0 && !xhttp.IsInformational(statusCode) {
+		sw.statusCode = statusCode
+	}
+
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.statusCode == 0 {
+		sw.statusCode = http.StatusOK
+	}
+
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", sw.ResponseWriter)
+	}
+
+	c, rw, err := h.Hijack()
+	if err == nil && sw.statusCode == 0 {
+		// the handler has taken over the connection, which for servers means a protocol switch
+		sw.statusCode = http.StatusSwitchingProtocols
+	}
+
+	return c, rw, err
+}
+
+// endHTTPSpan records an HTTP status code on a span and ends it.  Server errors mark the span as failed.
+func endHTTPSpan(span trace.Span, statusCode int) {
+	span.SetAttributes(HTTPStatusCodeKey.Int(statusCode))
+	if statusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(statusCode))
+	}
+
+	span.End()
+}
+
+// TraceID returns the hex-encoded identifier of the trace an HTTP request belongs to, or the empty string if the
+// request is not traced.  The span in the request's context is preferred.  Otherwise, any trace context propagated
+// in the request headers is used, which allows code outside NewServerMiddleware, such as request metrics, to
+// identify the trace.
+func TraceID(o Options, request *http.Request) string {
+	sc := trace.SpanContextFromContext(request.Context())
+	if !sc.IsValid() {
+		sc = trace.SpanContextFromContext(
+			o.propagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header)),
+		)
+	}
+
+	if sc.IsValid() {
+		return sc.TraceID().String()
+	}
+
+	return ""
+}
+
+// NewServerMiddleware returns an Alice-style constructor that creates a server span for each request.
+// Any trace context propagated in the request headers becomes the parent of the span, and the span is
+// available to decorated handlers through the request's context.  The context logger is decorated with
+// WithLogger, so log events for the request carry the trace and span identifiers.
+func NewServerMiddleware(o Options) func(http.Handler) http.Handler {
+	var (
+		tracer     = o.tracer()
+		propagator = o.propagator()
+		spanName   = o.spanName()
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			ctx := propagator.Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+			ctx, span := tracer.Start(
+				ctx,
+				spanName(request),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					HTTPMethodKey.String(request.Method),
+					HTTPTargetKey.String(request.RequestURI),
+				),
+			)
+
+			ctx = WithLogger(ctx)
+			sw := &statusWriter{ResponseWriter: response}
+			defer func() {
+				if sw.statusCode == 0 {
+					sw.statusCode = http.StatusOK
+				}
+
+				endHTTPSpan(span, sw.statusCode)
+			}()
+
+			next.ServeHTTP(sw, request.WithContext(ctx))
+		})
+	}
+}
+
+// NewTransactor decorates an HTTP client transaction function so that each transaction gets a client span,
+// with the trace context injected into a copy of the outbound request headers.  The caller's headers are never
+// modified, so the same header map may be shared by several requests, as is the case with fanout.  The span is a child of any span in the
+// request's context.  This is typically used with fanout.WithTransactor, giving each fanout endpoint its own span.
+//
+// If next is nil, http.DefaultClient.Do is used.
+func NewTransactor(o Options, next func(*http.Request) (*http.Response, error)) func(*http.Request) (*http.Response, error) {
+	if next == nil {
+		next = http.DefaultClient.Do
+	}
+
+	var (
+		tracer     = o.tracer()
+		propagator = o.propagator()
+		spanName   = o.spanName()
+	)
+
+	return func(request *http.Request) (*http.Response, error) {
+		ctx, span := tracer.Start(
+			request.Context(),
+			spanName(request),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				HTTPMethodKey.String(request.Method),
+				HTTPURLKey.String(request.URL.String()),
+			),
+		)
+
+		request = request.WithContext(ctx)
+		header := make(http.Header, len(request.Header)+2)
+		for name, values := range request.Header {
+			header[name] = append([]string(nil), values...)
+		}
+
+		request.Header = header
+		propagator.Inject(ctx, propagation.HeaderCarrier(request.Header))
+		response, err := next(request)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+			return response, err
+		}
+
+		endHTTPSpan(span, response.StatusCode)
+		return response, nil
+	}
+}
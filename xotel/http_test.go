@@ -0,0 +1,255 @@
+package xotel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceParent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+func TestTraceID(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		o, _   = newTestOptions()
+	)
+
+	request := httptest.NewRequest("GET", "/", nil)
+	assert.Empty(TraceID(o, request))
+
+	request.Header.Set("traceparent", testTraceParent)
+	assert.Equal("0af7651916cd43dd8448eb211c80319c", TraceID(o, request))
+
+	var traced string
+	NewServerMiddleware(o)(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+		traced = TraceID(Options{}, request)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	assert.Len(traced, 32)
+}
+
+func TestNewServerMiddleware(t *testing.T) {
+	testData := []struct {
+		statusCode   int
+		expectedCode int
+		expectError  bool
+	}{
+		{0, http.StatusOK, false},
+		{http.StatusAccepted, http.StatusAccepted, false},
+		{http.StatusNotFound, http.StatusNotFound, false},
+		{http.StatusServiceUnavailable, http.StatusServiceUnavailable, true},
+	}
+
+	for _, record := range testData {
+		t.Run(http.StatusText(record.expectedCode), func(t *testing.T) {
+			var (
+				assert      = assert.New(t)
+				require     = require.New(t)
+				o, recorder = newTestOptions()
+
+				request  = httptest.NewRequest("POST", "/api/v2/device?foo=bar", nil)
+				response = httptest.NewRecorder()
+
+				handlerSpan trace.SpanContext
+				handler     = NewServerMiddleware(o)(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+					handlerSpan = trace.SpanContextFromContext(request.Context())
+					if record.statusCode > 0 {
+						response.WriteHeader(record.statusCode)
+					}
+				}))
+			)
+
+			request.Header.Set("traceparent", testTraceParent)
+			handler.ServeHTTP(response, request)
+			assert.Equal(record.expectedCode, response.Code)
+
+			spans := recorder.Ended()
+			require.Len(spans, 1)
+			span := spans[0]
+			assert.Equal("POST /api/v2/device", span.Name())
+			assert.Equal(trace.SpanKindServer, span.SpanKind())
+			assert.Equal(handlerSpan, span.SpanContext())
+			assert.Equal("0af7651916cd43dd8448eb211c80319c", span.SpanContext().TraceID().String())
+			assert.Equal("b7ad6b7169203331", span.Parent().SpanID().String())
+			assert.Contains(span.Attributes(), HTTPMethodKey.String("POST"))
+			assert.Contains(span.Attributes(), HTTPTargetKey.String("/api/v2/device?foo=bar"))
+			assert.Contains(span.Attributes(), HTTPStatusCodeKey.Int(record.expectedCode))
+
+			if record.expectError {
+				assert.Equal(codes.Error, span.Status().Code)
+			} else {
+				assert.Equal(codes.Unset, span.Status().Code)
+			}
+		})
+	}
+}
+
+func TestNewServerMiddlewareInformational(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		o, recorder = newTestOptions()
+
+		handler = NewServerMiddleware(o)(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(http.StatusEarlyHints)
+			response.WriteHeader(http.StatusNotFound)
+		}))
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v2/device", nil))
+
+	spans := recorder.Ended()
+	require.Len(spans, 1)
+	assert.Contains(spans[0].Attributes(), HTTPStatusCodeKey.Int(http.StatusNotFound))
+}
+
+func TestNewServerMiddlewareFlushAndHijack(t *testing.T) {
+	t.Run("Hijacker", func(t *testing.T) {
+		var (
+			assert      = assert.New(t)
+			require     = require.New(t)
+			o, recorder = newTestOptions()
+			response    = &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+			handler = NewServerMiddleware(o)(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				flusher, ok := response.(http.Flusher)
+				require.True(ok)
+				flusher.Flush()
+
+				hijacker, ok := response.(http.Hijacker)
+				require.True(ok)
+				_, _, err := hijacker.Hijack()
+				assert.NoError(err)
+			}))
+		)
+
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/api/v2/device/connect", nil))
+		assert.True(response.Flushed)
+		assert.True(response.hijacked)
+
+		spans := recorder.Ended()
+		require.Len(spans, 1)
+		assert.Contains(spans[0].Attributes(), HTTPStatusCodeKey.Int(http.StatusSwitchingProtocols))
+	})
+
+	t.Run("NotHijacker", func(t *testing.T) {
+		var (
+			assert      = assert.New(t)
+			require     = require.New(t)
+			o, recorder = newTestOptions()
+
+			handler = NewServerMiddleware(o)(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				_, _, err := response.(http.Hijacker).Hijack()
+				assert.Error(err)
+			}))
+		)
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v2/device/connect", nil))
+		spans := recorder.Ended()
+		require.Len(spans, 1)
+		assert.Contains(spans[0].Attributes(), HTTPStatusCodeKey.Int(http.StatusOK))
+	})
+}
+
+func TestNewTransactor(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		o, recorder = newTestOptions()
+
+		parentCtx, parent = o.tracer().Start(context.Background(), "parent")
+		request           = httptest.NewRequest("GET", "http://device.example.com/api/v2/device", nil).WithContext(parentCtx)
+
+		transactor = NewTransactor(o, func(r *http.Request) (*http.Response, error) {
+			ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.HeaderCarrier(r.Header))
+			assert.Equal(trace.SpanContextFromContext(r.Context()).SpanID(), trace.SpanContextFromContext(ctx).SpanID())
+			return &http.Response{StatusCode: http.StatusBadGateway}, nil
+		})
+	)
+
+	response, err := transactor(request)
+	require.NoError(err)
+	assert.Equal(http.StatusBadGateway, response.StatusCode)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(spans, 2)
+	span := spans[0]
+	assert.Equal("GET /api/v2/device", span.Name())
+	assert.Equal(trace.SpanKindClient, span.SpanKind())
+	assert.Equal(parent.SpanContext().SpanID(), span.Parent().SpanID())
+	assert.Contains(span.Attributes(), HTTPURLKey.String("http://device.example.com/api/v2/device"))
+	assert.Contains(span.Attributes(), HTTPStatusCodeKey.Int(http.StatusBadGateway))
+	assert.Equal(codes.Error, span.Status().Code)
+}
+
+func TestNewTransactorError(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		require     = require.New(t)
+		o, recorder = newTestOptions()
+		expected    = errors.New("expected")
+
+		request    = &http.Request{Method: "GET", URL: httptest.NewRequest("GET", "http://example.com/", nil).URL}
+		transactor = NewTransactor(o, func(r *http.Request) (*http.Response, error) {
+			assert.NotEmpty(r.Header.Get("traceparent"))
+			return nil, expected
+		})
+	)
+
+	response, err := transactor(request)
+	assert.Nil(response)
+	assert.Equal(expected, err)
+
+	spans := recorder.Ended()
+	require.Len(spans, 1)
+	assert.Equal(codes.Error, spans[0].Status().Code)
+	assert.Equal("expected", spans[0].Status().Description)
+	assert.NotContains(spans[0].Attributes(), attribute.Key("http.status_code"))
+}
+
+func TestNewTransactorSharedHeader(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		require   = require.New(t)
+		o, _      = newTestOptions()
+		shared    = http.Header{"X-Existing": []string{"value"}}
+		forwarded []http.Header
+
+		transactor = NewTransactor(o, func(r *http.Request) (*http.Response, error) {
+			forwarded = append(forwarded, r.Header)
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		})
+	)
+
+	// fanout, for example, shares one header map across the requests for each endpoint
+	for i := 0; i < 2; i++ {
+		request := httptest.NewRequest("GET", "http://example.com/", nil)
+		request.Header = shared
+		_, err := transactor(request)
+		require.NoError(err)
+	}
+
+	assert.Equal(http.Header{"X-Existing": []string{"value"}}, shared)
+	require.Len(forwarded, 2)
+	for _, h := range forwarded {
+		assert.Equal("value", h.Get("X-Existing"))
+		assert.Len(h["Traceparent"], 1)
+	}
+
+	assert.NotEqual(forwarded[0].Get("traceparent"), forwarded[1].Get("traceparent"))
+}
+
+func TestNewTransactorDefault(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotNil(NewTransactor(Options{}, nil))
+}
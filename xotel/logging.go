@@ -0,0 +1,46 @@
+package xotel
+
+import (
+	"context"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	traceIDKey interface{} = "trace_id"
+	spanIDKey  interface{} = "span_id"
+)
+
+// TraceIDKey returns the contextual logging key for the trace ID of the active span
+func TraceIDKey() interface{} {
+	return traceIDKey
+}
+
+// SpanIDKey returns the contextual logging key for the span ID of the active span
+func SpanIDKey() interface{} {
+	return spanIDKey
+}
+
+// Logger decorates a go-kit logger so that each log event carries the trace and span identifiers of the span
+// active in the given context, under TraceIDKey and SpanIDKey.  This allows logs to be joined with traces in
+// the backend.  If the context has no valid span, next is returned undecorated.
+func Logger(ctx context.Context, next log.Logger) log.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return next
+	}
+
+	return log.With(next, traceIDKey, sc.TraceID().String(), spanIDKey, sc.SpanID().String())
+}
+
+// WithLogger decorates the context's logger, as returned by logging.GetLogger, using Logger.  The decorated
+// logger is placed into the returned context.  If the context has no valid span, it is returned as is.
+func WithLogger(ctx context.Context) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+
+	return logging.WithLogger(ctx, Logger(ctx, logging.GetLogger(ctx)))
+}
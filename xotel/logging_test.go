@@ -0,0 +1,118 @@
+package xotel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// captureLogger returns a logger that records the keyvals of every log event
+func captureLogger(output *[]map[interface{}]interface{}) log.Logger {
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		event := make(map[interface{}]interface{}, len(keyvals)/2)
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			event[keyvals[i]] = keyvals[i+1]
+		}
+
+		*output = append(*output, event)
+		return nil
+	})
+}
+
+func testSpanContext(t *testing.T) trace.SpanContext {
+	traceID, err := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	require.NoError(t, err)
+
+	spanID, err := trace.SpanIDFromHex("b7ad6b7169203331")
+	require.NoError(t, err)
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("NoSpan", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			output []map[interface{}]interface{}
+			next   = captureLogger(&output)
+		)
+
+		logger := Logger(context.Background(), next)
+		logger.Log("msg", "test")
+		require.Len(t, output, 1)
+		assert.NotContains(output[0], TraceIDKey())
+		assert.NotContains(output[0], SpanIDKey())
+	})
+
+	t.Run("Span", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			output []map[interface{}]interface{}
+			next   = captureLogger(&output)
+			ctx    = trace.ContextWithSpanContext(context.Background(), testSpanContext(t))
+		)
+
+		Logger(ctx, next).Log("msg", "test")
+		require.Len(t, output, 1)
+		assert.Equal("0af7651916cd43dd8448eb211c80319c", output[0][TraceIDKey()])
+		assert.Equal("b7ad6b7169203331", output[0][SpanIDKey()])
+		assert.Equal("test", output[0]["msg"])
+	})
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Run("NoSpan", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Equal(t, ctx, WithLogger(ctx))
+	})
+
+	t.Run("Span", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			output []map[interface{}]interface{}
+			ctx    = logging.WithLogger(context.Background(), captureLogger(&output))
+		)
+
+		ctx = WithLogger(trace.ContextWithSpanContext(ctx, testSpanContext(t)))
+		logging.GetLogger(ctx).Log("msg", "test")
+		require.Len(t, output, 1)
+		assert.Equal("0af7651916cd43dd8448eb211c80319c", output[0][TraceIDKey()])
+		assert.Equal("b7ad6b7169203331", output[0][SpanIDKey()])
+	})
+}
+
+func TestNewServerMiddlewareLogger(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		o, _    = newTestOptions()
+
+		output  []map[interface{}]interface{}
+		request = httptest.NewRequest("GET", "/", nil)
+
+		handlerSpan trace.SpanContext
+	)
+
+	request = request.WithContext(logging.WithLogger(request.Context(), captureLogger(&output)))
+	request.Header.Set("traceparent", testTraceParent)
+
+	NewServerMiddleware(o)(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(request.Context())
+		logging.GetLogger(request.Context()).Log("msg", "handled")
+	})).ServeHTTP(httptest.NewRecorder(), request)
+
+	require.Len(output, 1)
+	assert.Equal("0af7651916cd43dd8448eb211c80319c", output[0][TraceIDKey()])
+	assert.Equal(handlerSpan.SpanID().String(), output[0][SpanIDKey()])
+}
@@ -0,0 +1,31 @@
+package xotel
+
+import (
+	"bufio"
+	"net"
+	"net/http/httptest"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockRouter struct {
+	mock.Mock
+}
+
+func (m *mockRouter) Route(request *device.Request) (*device.Response, error) {
+	arguments := m.Called(request)
+	first, _ := arguments.Get(0).(*device.Response)
+	return first, arguments.Error(1)
+}
+
+// hijackRecorder is an httptest.ResponseRecorder that also implements http.Hijacker
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (hr *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hr.hijacked = true
+	return nil, nil, nil
+}
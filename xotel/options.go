@@ -0,0 +1,55 @@
+package xotel
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the OpenTelemetry tracer used by this package
+const InstrumentationName = "github.com/Comcast/webpa-common/xotel"
+
+// Options describes the OpenTelemetry configuration used to instrument code.  A nil Options is valid,
+// and uses the global OpenTelemetry configuration.
+type Options struct {
+	// TracerProvider is the source of tracers.  If unset, otel.GetTracerProvider() is used.
+	TracerProvider trace.TracerProvider
+
+	// Propagator injects and extracts trace context in HTTP headers and WRP metadata.
+	// If unset, otel.GetTextMapPropagator() is used.
+	Propagator propagation.TextMapPropagator
+
+	// SpanName computes the name of HTTP server and client spans.  If unset, DefaultSpanName is used.
+	SpanName func(*http.Request) string
+}
+
+func (o *Options) tracer() trace.Tracer {
+	if o != nil && o.TracerProvider != nil {
+		return o.TracerProvider.Tracer(InstrumentationName)
+	}
+
+	return otel.GetTracerProvider().Tracer(InstrumentationName)
+}
+
+func (o *Options) propagator() propagation.TextMapPropagator {
+	if o != nil && o.Propagator != nil {
+		return o.Propagator
+	}
+
+	return otel.GetTextMapPropagator()
+}
+
+func (o *Options) spanName() func(*http.Request) string {
+	if o != nil && o.SpanName != nil {
+		return o.SpanName
+	}
+
+	return DefaultSpanName
+}
+
+// DefaultSpanName names an HTTP span with the request's method and URL path
+func DefaultSpanName(request *http.Request) string {
+	return request.Method + " " + request.URL.Path
+}
@@ -0,0 +1,52 @@
+package xotel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestOptions produces Options that record spans and use W3C trace context propagation
+func newTestOptions() (Options, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	return Options{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		Propagator:     propagation.TraceContext{},
+	}, recorder
+}
+
+func testOptionsDefault(t *testing.T, o *Options) {
+	var (
+		assert  = assert.New(t)
+		request = httptest.NewRequest("GET", "/api/v2/device", nil)
+	)
+
+	assert.NotNil(o.tracer())
+	assert.Equal(otel.GetTextMapPropagator(), o.propagator())
+	assert.Equal("GET /api/v2/device", o.spanName()(request))
+}
+
+func testOptionsCustom(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		o, _    = newTestOptions()
+		request = httptest.NewRequest("GET", "/api/v2/device", nil)
+	)
+
+	o.SpanName = func(*http.Request) string { return "custom" }
+	assert.NotNil(o.tracer())
+	assert.Equal(propagation.TraceContext{}, o.propagator())
+	assert.Equal("custom", o.spanName()(request))
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) { testOptionsDefault(t, nil) })
+	t.Run("Default", func(t *testing.T) { testOptionsDefault(t, new(Options)) })
+	t.Run("Custom", testOptionsCustom)
+}
@@ -0,0 +1,45 @@
+package xotel
+
+import (
+	"context"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// MetadataCarrier adapts WRP message metadata to an OpenTelemetry TextMapCarrier
+type MetadataCarrier map[string]string
+
+var _ propagation.TextMapCarrier = MetadataCarrier(nil)
+
+func (mc MetadataCarrier) Get(key string) string {
+	return mc[key]
+}
+
+func (mc MetadataCarrier) Set(key, value string) {
+	mc[key] = value
+}
+
+func (mc MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(mc))
+	for k := range mc {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// InjectMessage places the trace context of ctx into a WRP message's metadata.  The message's metadata is
+// allocated if necessary.
+func InjectMessage(ctx context.Context, propagator propagation.TextMapPropagator, m *wrp.Message) {
+	if m.Metadata == nil {
+		m.Metadata = make(map[string]string)
+	}
+
+	propagator.Inject(ctx, MetadataCarrier(m.Metadata))
+}
+
+// ExtractMessage returns a copy of ctx carrying any trace context found in a WRP message's metadata
+func ExtractMessage(ctx context.Context, propagator propagation.TextMapPropagator, m *wrp.Message) context.Context {
+	return propagator.Extract(ctx, MetadataCarrier(m.Metadata))
+}
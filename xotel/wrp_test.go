@@ -0,0 +1,48 @@
+package xotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMetadataCarrier(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		carrier = MetadataCarrier{"existing": "value"}
+	)
+
+	assert.Equal("value", carrier.Get("existing"))
+	assert.Empty(carrier.Get("missing"))
+
+	carrier.Set("new", "another")
+	assert.Equal("another", carrier.Get("new"))
+	assert.ElementsMatch([]string{"existing", "new"}, carrier.Keys())
+}
+
+func TestInjectExtractMessage(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		o, _       = newTestOptions()
+		propagator = propagation.TraceContext{}
+		message    = new(wrp.Message)
+
+		ctx, span = o.tracer().Start(context.Background(), "test")
+	)
+
+	defer span.End()
+
+	assert.False(trace.SpanContextFromContext(ExtractMessage(context.Background(), propagator, message)).IsValid())
+
+	InjectMessage(ctx, propagator, message)
+	assert.NotEmpty(message.Metadata["traceparent"])
+
+	extracted := trace.SpanContextFromContext(ExtractMessage(context.Background(), propagator, message))
+	assert.Equal(span.SpanContext().TraceID(), extracted.TraceID())
+	assert.Equal(span.SpanContext().SpanID(), extracted.SpanID())
+	assert.True(extracted.IsRemote())
+}
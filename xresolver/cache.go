@@ -0,0 +1,230 @@
+package xresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is how long resolved routes are cached when no TTL is configured
+	DefaultCacheTTL = 30 * time.Second
+
+	// DefaultNegativeTTL is how long a failure to find a host is cached when no negative TTL is configured
+	DefaultNegativeTTL = 5 * time.Second
+
+	// refreshTimeout bounds background refreshes, which are not tied to any caller's context
+	refreshTimeout = 10 * time.Second
+)
+
+// CacheOptions configures a CachingLookup
+type CacheOptions struct {
+	// TTL is how long routes are considered fresh.  If unset, DefaultCacheTTL is used.
+	TTL time.Duration
+
+	// TTLs overrides TTL for specific hostnames
+	TTLs map[string]time.Duration
+
+	// StaleTTL is how long past expiry cached routes may still be served.  Stale routes are returned immediately
+	// while they are refreshed in the background.  If unset, expired routes are never served.
+	StaleTTL time.Duration
+
+	// NegativeTTL is how long a hostname that could not be found is remembered as such.  If unset,
+	// DefaultNegativeTTL is used.
+	NegativeTTL time.Duration
+
+	// Measures are the metrics emitted for each lookup.  If unset, metrics are discarded.
+	Measures *Measures
+
+	// Now is the source of the current time.  If unset, time.Now is used.
+	Now func() time.Time
+}
+
+// cacheEntry is the cached result for a single hostname
+type cacheEntry struct {
+	routes     []Route
+	err        error
+	expires    time.Time
+	refreshing bool
+}
+
+// evictable tests if this entry can no longer be served, either fresh or stale
+func (ce *cacheEntry) evictable(now time.Time, staleTTL time.Duration) bool {
+	if ce.err != nil {
+		return !now.Before(ce.expires)
+	}
+
+	return !now.Before(ce.expires.Add(staleTTL))
+}
+
+// cacheCall is an in-progress load of a single hostname, shared by every lookup that misses the cache
+// while it is running
+type cacheCall struct {
+	done   chan struct{}
+	routes []Route
+	err    error
+}
+
+// CachingLookup decorates another Lookup with a cache.  Successful results are cached for a per-hostname TTL,
+// and hostnames that do not exist are cached for a shorter negative TTL.  Other errors are not cached.
+// Concurrent misses for the same hostname share a single call to the next Lookup, and entries that can
+// no longer be served are evicted as new entries are cached.
+type CachingLookup struct {
+	next        Lookup
+	ttl         time.Duration
+	ttls        map[string]time.Duration
+	staleTTL    time.Duration
+	negativeTTL time.Duration
+	measures    Measures
+	now         func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+	calls   map[string]*cacheCall
+}
+
+// NewCachingLookup produces a CachingLookup that delegates to next on cache misses
+func NewCachingLookup(next Lookup, o CacheOptions) *CachingLookup {
+	cl := &CachingLookup{
+		next:        next,
+		ttl:         o.TTL,
+		ttls:        o.TTLs,
+		staleTTL:    o.StaleTTL,
+		negativeTTL: o.NegativeTTL,
+		now:         o.Now,
+		entries:     make(map[string]*cacheEntry),
+		calls:       make(map[string]*cacheCall),
+	}
+
+	if cl.ttl < 1 {
+		cl.ttl = DefaultCacheTTL
+	}
+
+	if cl.negativeTTL < 1 {
+		cl.negativeTTL = DefaultNegativeTTL
+	}
+
+	cl.measures = measuresOrDiscard(o.Measures)
+	if cl.now == nil {
+		cl.now = time.Now
+	}
+
+	return cl
+}
+
+// isNotFound tests if an error indicates that a hostname does not exist, i.e. NXDOMAIN
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// ttlFor returns the TTL for the given hostname
+func (cl *CachingLookup) ttlFor(host string) time.Duration {
+	if ttl, ok := cl.ttls[host]; ok && ttl > 0 {
+		return ttl
+	}
+
+	return cl.ttl
+}
+
+func (cl *CachingLookup) LookupRoutes(ctx context.Context, host string) ([]Route, error) {
+	cl.lock.Lock()
+	now := cl.now()
+	if entry, ok := cl.entries[host]; ok {
+		switch {
+		case now.Before(entry.expires) && entry.err != nil:
+			cl.lock.Unlock()
+			cl.measures.Cache.With(OutcomeLabel, NegativeOutcome).Add(1.0)
+			return nil, entry.err
+
+		case now.Before(entry.expires):
+			cl.lock.Unlock()
+			cl.measures.Cache.With(OutcomeLabel, HitOutcome).Add(1.0)
+			return append([]Route{}, entry.routes...), nil
+
+		case entry.err == nil && now.Before(entry.expires.Add(cl.staleTTL)):
+			routes := append([]Route{}, entry.routes...)
+			if !entry.refreshing {
+				entry.refreshing = true
+				go cl.refresh(host)
+			}
+
+			cl.lock.Unlock()
+			cl.measures.Cache.With(OutcomeLabel, StaleOutcome).Add(1.0)
+			return routes, nil
+		}
+	}
+
+	cl.lock.Unlock()
+	cl.measures.Cache.With(OutcomeLabel, MissOutcome).Add(1.0)
+	return cl.load(ctx, host)
+}
+
+// refresh reloads a stale entry in the background
+func (cl *CachingLookup) refresh(host string) {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	if _, err := cl.load(ctx, host); err != nil && !isNotFound(err) {
+		// leave the stale entry in place, but allow another refresh to be attempted
+		cl.lock.Lock()
+		if entry, ok := cl.entries[host]; ok {
+			entry.refreshing = false
+		}
+
+		cl.lock.Unlock()
+	}
+}
+
+// load consults the next Lookup and caches the result.  If a load of the same hostname is already in progress,
+// this method waits for its result instead.
+func (cl *CachingLookup) load(ctx context.Context, host string) ([]Route, error) {
+	cl.lock.Lock()
+	call, ok := cl.calls[host]
+	if ok {
+		cl.lock.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	} else {
+		call = &cacheCall{done: make(chan struct{})}
+		cl.calls[host] = call
+		cl.lock.Unlock()
+
+		call.routes, call.err = cl.next.LookupRoutes(ctx, host)
+		cl.store(host, call)
+		close(call.done)
+	}
+
+	if call.err != nil {
+		return nil, call.err
+	}
+
+	return append([]Route{}, call.routes...), nil
+}
+
+// store caches the result of a completed call, evicting any entries that can no longer be served
+func (cl *CachingLookup) store(host string, call *cacheCall) {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	delete(cl.calls, host)
+	now := cl.now()
+	for key, entry := range cl.entries {
+		if entry.evictable(now, cl.staleTTL) {
+			delete(cl.entries, key)
+		}
+	}
+
+	switch {
+	case call.err == nil:
+		cl.entries[host] = &cacheEntry{routes: call.routes, expires: now.Add(cl.ttlFor(host))}
+
+	case isNotFound(call.err):
+		cl.entries[host] = &cacheEntry{err: call.err, expires: now.Add(cl.negativeTTL)}
+	}
+}
@@ -0,0 +1,319 @@
+package xresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingLookup returns configurable results and counts how many times it was consulted
+type countingLookup struct {
+	lock    sync.Mutex
+	calls   int
+	routes  []Route
+	err     error
+	invoked chan struct{}
+}
+
+func (cl *countingLookup) LookupRoutes(context.Context, string) ([]Route, error) {
+	cl.lock.Lock()
+	cl.calls++
+	routes, err := cl.routes, cl.err
+	cl.lock.Unlock()
+
+	if cl.invoked != nil {
+		cl.invoked <- struct{}{}
+	}
+
+	return routes, err
+}
+
+func (cl *countingLookup) set(routes []Route, err error) {
+	cl.lock.Lock()
+	cl.routes, cl.err = routes, err
+	cl.lock.Unlock()
+}
+
+func (cl *countingLookup) count() int {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	return cl.calls
+}
+
+// newTestCachingLookup produces a CachingLookup with a controllable clock, which is advanced with the returned function
+func newTestCachingLookup(next Lookup, o CacheOptions) (*CachingLookup, func(time.Duration), xmetricstest.Provider) {
+	var (
+		lock     sync.Mutex
+		now      = time.Now()
+		provider = xmetricstest.NewProvider(nil, Metrics)
+		measures = NewMeasures(provider)
+	)
+
+	o.Measures = &measures
+	o.Now = func() time.Time {
+		lock.Lock()
+		defer lock.Unlock()
+		return now
+	}
+
+	advance := func(d time.Duration) {
+		lock.Lock()
+		now = now.Add(d)
+		lock.Unlock()
+	}
+
+	return NewCachingLookup(next, o), advance, provider
+}
+
+func TestNewCachingLookupDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		cl     = NewCachingLookup(new(countingLookup), CacheOptions{})
+	)
+
+	assert.Equal(DefaultCacheTTL, cl.ttl)
+	assert.Equal(DefaultNegativeTTL, cl.negativeTTL)
+	assert.Zero(cl.staleTTL)
+	assert.NotNil(cl.measures.Cache)
+	assert.NotNil(cl.now)
+}
+
+func TestIsNotFound(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(isNotFound(&net.DNSError{Err: "no such host", Name: "nosuch.example.com", IsNotFound: true}))
+	assert.True(isNotFound(fmt.Errorf("wrapped: %w", &net.DNSError{Err: "no such host", IsNotFound: true})))
+	assert.False(isNotFound(&net.DNSError{Err: "no such host"}))
+	assert.False(isNotFound(&net.DNSError{Err: "server misbehaving"}))
+	assert.False(isNotFound(errors.New("no such host")))
+	assert.False(isNotFound(nil))
+}
+
+func TestCachingLookupTTL(t *testing.T) {
+	var (
+		assert              = assert.New(t)
+		next                = &countingLookup{routes: []Route{{Host: "10.0.0.1"}}}
+		cl, advance, metric = newTestCachingLookup(next, CacheOptions{
+			TTL:  time.Minute,
+			TTLs: map[string]time.Duration{"short.example.com": time.Second},
+		})
+	)
+
+	for i := 0; i < 3; i++ {
+		routes, err := cl.LookupRoutes(context.Background(), "example.com")
+		assert.Equal([]Route{{Host: "10.0.0.1"}}, routes)
+		assert.NoError(err)
+	}
+
+	assert.Equal(1, next.count())
+
+	cl.LookupRoutes(context.Background(), "short.example.com")
+	assert.Equal(2, next.count())
+
+	// the per-host TTL expires before the default TTL
+	advance(2 * time.Second)
+	cl.LookupRoutes(context.Background(), "example.com")
+	cl.LookupRoutes(context.Background(), "short.example.com")
+	assert.Equal(3, next.count())
+
+	advance(2 * time.Minute)
+	cl.LookupRoutes(context.Background(), "example.com")
+	assert.Equal(4, next.count())
+
+	metric.Expect(CacheCounter, OutcomeLabel, MissOutcome)(xmetricstest.Value(4.0))
+	metric.Expect(CacheCounter, OutcomeLabel, HitOutcome)(xmetricstest.Value(3.0))
+	metric.AssertExpectations(t)
+}
+
+func TestCachingLookupNegative(t *testing.T) {
+	var (
+		assert              = assert.New(t)
+		notFound            = &net.DNSError{Err: "no such host", Name: "nosuch.example.com", IsNotFound: true}
+		next                = &countingLookup{err: notFound}
+		cl, advance, metric = newTestCachingLookup(next, CacheOptions{NegativeTTL: time.Second})
+	)
+
+	for i := 0; i < 3; i++ {
+		routes, err := cl.LookupRoutes(context.Background(), "nosuch.example.com")
+		assert.Empty(routes)
+		assert.Equal(notFound, err)
+	}
+
+	assert.Equal(1, next.count())
+
+	advance(2 * time.Second)
+	next.set([]Route{{Host: "10.0.0.1"}}, nil)
+	routes, err := cl.LookupRoutes(context.Background(), "nosuch.example.com")
+	assert.Equal([]Route{{Host: "10.0.0.1"}}, routes)
+	assert.NoError(err)
+
+	metric.Expect(CacheCounter, OutcomeLabel, NegativeOutcome)(xmetricstest.Value(2.0))
+	metric.Expect(CacheCounter, OutcomeLabel, MissOutcome)(xmetricstest.Value(2.0))
+	metric.AssertExpectations(t)
+}
+
+func TestCachingLookupErrorsNotCached(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		transient = errors.New("expected")
+		next      = &countingLookup{err: transient}
+		cl, _, _  = newTestCachingLookup(next, CacheOptions{})
+	)
+
+	for i := 0; i < 3; i++ {
+		_, err := cl.LookupRoutes(context.Background(), "example.com")
+		assert.Equal(transient, err)
+	}
+
+	assert.Equal(3, next.count())
+}
+
+func TestCachingLookupStaleWhileRevalidate(t *testing.T) {
+	var (
+		assert              = assert.New(t)
+		require             = require.New(t)
+		next                = &countingLookup{routes: []Route{{Host: "10.0.0.1"}}, invoked: make(chan struct{}, 10)}
+		cl, advance, metric = newTestCachingLookup(next, CacheOptions{TTL: time.Minute, StaleTTL: time.Minute})
+	)
+
+	cl.LookupRoutes(context.Background(), "example.com")
+	<-next.invoked
+
+	// the entry has expired but is within the stale window, so it is served while being refreshed
+	advance(90 * time.Second)
+	next.set([]Route{{Host: "10.0.0.2"}}, nil)
+	routes, err := cl.LookupRoutes(context.Background(), "example.com")
+	assert.Equal([]Route{{Host: "10.0.0.1"}}, routes)
+	assert.NoError(err)
+
+	select {
+	case <-next.invoked:
+	case <-time.After(5 * time.Second):
+		require.Fail("No background refresh occurred")
+	}
+
+	// wait for the refreshed entry to be stored
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if routes, _ = cl.LookupRoutes(context.Background(), "example.com"); len(routes) > 0 && routes[0].Host == "10.0.0.2" {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal([]Route{{Host: "10.0.0.2"}}, routes)
+	assert.Equal(2, next.count())
+
+	// beyond the stale window, lookups block on the next Lookup
+	advance(5 * time.Minute)
+	next.set([]Route{{Host: "10.0.0.3"}}, nil)
+	routes, err = cl.LookupRoutes(context.Background(), "example.com")
+	assert.Equal([]Route{{Host: "10.0.0.3"}}, routes)
+	assert.NoError(err)
+
+	metric.Expect(CacheCounter, OutcomeLabel, StaleOutcome)(xmetricstest.Minimum(1.0))
+	metric.AssertExpectations(t)
+}
+
+func TestCachingLookupNilMeasures(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		next   = &countingLookup{routes: []Route{{Host: "10.0.0.1"}}}
+		cl     = NewCachingLookup(next, CacheOptions{Measures: new(Measures)})
+		d      = NewDialer(DialerOptions{Measures: new(Measures)})
+	)
+
+	assert.NotPanics(func() { cl.LookupRoutes(context.Background(), "example.com") })
+	assert.NotNil(d.measures.Dial)
+	assert.NotNil(d.measures.Cache)
+}
+
+func TestCachingLookupConcurrentMisses(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		release  = make(chan struct{})
+		invoked  = make(chan struct{}, 10)
+		next     = &countingLookup{routes: []Route{{Host: "10.0.0.1"}}}
+		cl, _, _ = newTestCachingLookup(
+			LookupFunc(func(ctx context.Context, host string) ([]Route, error) {
+				invoked <- struct{}{}
+				<-release
+				return next.LookupRoutes(ctx, host)
+			}),
+			CacheOptions{},
+		)
+
+		waitGroup = new(sync.WaitGroup)
+		results   = make(chan []Route, 5)
+	)
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		routes, _ := cl.LookupRoutes(context.Background(), "example.com")
+		results <- routes
+	}()
+
+	// wait for the first miss to start loading, then pile on more misses before it completes
+	<-invoked
+	for i := 0; i < 4; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			routes, _ := cl.LookupRoutes(context.Background(), "example.com")
+			results <- routes
+		}()
+	}
+
+	// a waiting caller can give up without affecting the load
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cl.load(ctx, "example.com")
+	assert.Equal(context.Canceled, err)
+
+	close(release)
+	waitGroup.Wait()
+	close(results)
+
+	for routes := range results {
+		assert.Equal([]Route{{Host: "10.0.0.1"}}, routes)
+	}
+
+	assert.Equal(1, next.count())
+	assert.Empty(cl.calls)
+}
+
+func TestCachingLookupEviction(t *testing.T) {
+	var (
+		assert         = assert.New(t)
+		notFound       = &net.DNSError{Err: "no such host", IsNotFound: true}
+		next           = &countingLookup{routes: []Route{{Host: "10.0.0.1"}}}
+		cl, advance, _ = newTestCachingLookup(next, CacheOptions{TTL: time.Minute, StaleTTL: time.Minute, NegativeTTL: time.Second})
+	)
+
+	cl.LookupRoutes(context.Background(), "first.example.com")
+	next.set(nil, notFound)
+	cl.LookupRoutes(context.Background(), "nosuch.example.com")
+	assert.Len(cl.entries, 2)
+
+	// the negative entry has expired, but the first entry can still be served stale
+	advance(90 * time.Second)
+	next.set([]Route{{Host: "10.0.0.2"}}, nil)
+	cl.load(context.Background(), "second.example.com")
+	assert.Contains(cl.entries, "first.example.com")
+	assert.NotContains(cl.entries, "nosuch.example.com")
+
+	advance(time.Minute)
+	cl.load(context.Background(), "third.example.com")
+	assert.NotContains(cl.entries, "first.example.com")
+	assert.Contains(cl.entries, "second.example.com")
+	assert.Contains(cl.entries, "third.example.com")
+}
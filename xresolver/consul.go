@@ -0,0 +1,242 @@
+package xresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/sd"
+	gokitconsul "github.com/go-kit/kit/sd/consul"
+)
+
+const (
+	// DefaultConsulDomain is the domain suffix handled by a ConsulLookup when none is configured
+	DefaultConsulDomain = "consul"
+)
+
+// ErrConsulLookupStopped is returned by a ConsulLookup that has been stopped
+var ErrConsulLookupStopped = errors.New("The consul lookup has been stopped")
+
+// ConsulLookupOptions configures a ConsulLookup
+type ConsulLookupOptions struct {
+	// Client is the consul client used to query the catalog.  This field is required.
+	Client gokitconsul.Client
+
+	// Logger is the go-kit logger to use.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger
+
+	// Domain is the consul domain.  Only hostnames of the form [tag.]service.service.<domain> are resolved through
+	// consul.  If unset, DefaultConsulDomain is used.
+	Domain string
+
+	// PassingOnly restricts routes to service instances whose health checks are passing
+	PassingOnly bool
+}
+
+// consulEntry is the cached set of routes for a single service and tag, which is kept current by a watch
+type consulEntry struct {
+	instancer sd.Instancer
+	events    chan sd.Event
+
+	lock   sync.RWMutex
+	routes []Route
+	err    error
+}
+
+func (ce *consulEntry) update(e sd.Event) {
+	routes := make([]Route, 0, len(e.Instances))
+	for _, instance := range e.Instances {
+		host, port, err := net.SplitHostPort(instance)
+		if err != nil {
+			continue
+		}
+
+		route := Route{Host: host}
+		route.Port, _ = strconv.Atoi(port)
+		routes = append(routes, route)
+	}
+
+	ce.lock.Lock()
+	ce.routes, ce.err = routes, e.Err
+	ce.lock.Unlock()
+}
+
+// stop terminates the watch for this entry
+func (ce *consulEntry) stop() {
+	ce.instancer.Deregister(ce.events)
+	if stopper, ok := ce.instancer.(interface {
+		Stop()
+	}); ok {
+		stopper.Stop()
+	}
+
+	close(ce.events)
+}
+
+func (ce *consulEntry) get() ([]Route, error) {
+	ce.lock.RLock()
+	defer ce.lock.RUnlock()
+
+	if len(ce.routes) > 0 {
+		return append([]Route{}, ce.routes...), nil
+	}
+
+	return nil, ce.err
+}
+
+// ConsulLookup is a Lookup that resolves hostnames through the consul catalog rather than DNS, using the same
+// hostname conventions as consul's DNS interface.  The first lookup of a service starts a watch on that service, and
+// subsequent lookups are answered from a cache that the watch keeps current.
+type ConsulLookup struct {
+	logger      log.Logger
+	suffix      string
+	passingOnly bool
+
+	newInstancer func(service string, tags []string, passingOnly bool) sd.Instancer
+
+	lock    sync.Mutex
+	stopped bool
+	entries map[string]*consulEntry
+}
+
+// NewConsulLookup constructs a ConsulLookup.  This function panics if no Client is supplied.
+func NewConsulLookup(o ConsulLookupOptions) *ConsulLookup {
+	if o.Client == nil {
+		panic("A consul client is required")
+	}
+
+	cl := &ConsulLookup{
+		logger:      o.Logger,
+		suffix:      ".service." + o.Domain,
+		passingOnly: o.PassingOnly,
+		entries:     make(map[string]*consulEntry),
+	}
+
+	if cl.logger == nil {
+		cl.logger = logging.DefaultLogger()
+	}
+
+	if len(o.Domain) == 0 {
+		cl.suffix = ".service." + DefaultConsulDomain
+	}
+
+	cl.newInstancer = func(service string, tags []string, passingOnly bool) sd.Instancer {
+		return gokitconsul.NewInstancer(o.Client, cl.logger, service, tags, passingOnly)
+	}
+
+	return cl
+}
+
+// parse extracts the service and optional tag from a consul hostname.  The last return is false if the
+// hostname is not a consul service name.
+func (cl *ConsulLookup) parse(host string) (service, tag string, ok bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if !strings.HasSuffix(host, cl.suffix) {
+		return "", "", false
+	}
+
+	name := strings.TrimSuffix(host, cl.suffix)
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		tag, service = name[:i], name[i+1:]
+	} else {
+		service = name
+	}
+
+	return service, tag, len(service) > 0
+}
+
+// LookupRoutes returns the current instances of the consul service named by host.  Hostnames that are not
+// consul service names produce no routes, so that other Lookups can be consulted.
+func (cl *ConsulLookup) LookupRoutes(_ context.Context, host string) ([]Route, error) {
+	service, tag, ok := cl.parse(host)
+	if !ok {
+		return nil, nil
+	}
+
+	entry, err := cl.entry(service, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.get()
+}
+
+// existing returns the cached entry for a key, if any
+func (cl *ConsulLookup) existing(key string) (*consulEntry, bool, error) {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	if cl.stopped {
+		return nil, false, ErrConsulLookupStopped
+	}
+
+	entry, ok := cl.entries[key]
+	return entry, ok, nil
+}
+
+// entry returns the cached entry for a service and tag, starting a watch if necessary.  The watch is started
+// without holding the lock, since priming it queries consul.  If another lookup of the same service wins the
+// race to cache an entry, the watch started here is discarded in favor of the cached one.
+func (cl *ConsulLookup) entry(service, tag string) (*consulEntry, error) {
+	key := tag + "." + service
+	if entry, ok, err := cl.existing(key); ok || err != nil {
+		return entry, err
+	}
+
+	var tags []string
+	if len(tag) > 0 {
+		tags = []string{tag}
+	}
+
+	entry := &consulEntry{
+		instancer: cl.newInstancer(service, tags, cl.passingOnly),
+		events:    make(chan sd.Event, 1),
+	}
+
+	// registration dispatches the current state of the instancer, which primes the cache
+	entry.instancer.Register(entry.events)
+	entry.update(<-entry.events)
+
+	go func() {
+		for e := range entry.events {
+			entry.update(e)
+		}
+	}()
+
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	if cl.stopped {
+		entry.stop()
+		return nil, ErrConsulLookupStopped
+	}
+
+	if existing, ok := cl.entries[key]; ok {
+		entry.stop()
+		return existing, nil
+	}
+
+	cl.entries[key] = entry
+	return entry, nil
+}
+
+// Stop terminates all watches.  After this method is called, lookups of consul hostnames return errors.
+func (cl *ConsulLookup) Stop() {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+
+	if cl.stopped {
+		return
+	}
+
+	cl.stopped = true
+	for key, entry := range cl.entries {
+		entry.stop()
+		delete(cl.entries, key)
+	}
+}
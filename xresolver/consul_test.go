@@ -0,0 +1,208 @@
+package xresolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/sd"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestConsulLookup produces a ConsulLookup whose instancers are created by the test
+func newTestConsulLookup(t *testing.T, o ConsulLookupOptions) (*ConsulLookup, map[string]*testInstancer) {
+	o.Client = &testConsulClient{block: make(chan struct{})}
+	o.Logger = logging.NewTestLogger(nil, t)
+
+	var (
+		cl         = NewConsulLookup(o)
+		instancers = make(map[string]*testInstancer)
+	)
+
+	cl.newInstancer = func(service string, tags []string, passingOnly bool) sd.Instancer {
+		assert.Equal(t, o.PassingOnly, passingOnly)
+		key := service
+		if len(tags) > 0 {
+			key = tags[0] + "." + service
+		}
+
+		ti := new(testInstancer)
+		instancers[key] = ti
+		return ti
+	}
+
+	return cl, instancers
+}
+
+// waitForRoutes polls until a lookup produces the expected routes, since watch updates are asynchronous
+func waitForRoutes(t *testing.T, cl *ConsulLookup, host string, expected []Route) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		routes, _ := cl.LookupRoutes(context.Background(), host)
+		if assert.ObjectsAreEqual(expected, routes) {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			assert.Equal(t, expected, routes)
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNewConsulLookup(t *testing.T) {
+	assert := assert.New(t)
+	assert.Panics(func() { NewConsulLookup(ConsulLookupOptions{}) })
+
+	cl := NewConsulLookup(ConsulLookupOptions{Client: new(testConsulClient)})
+	assert.Equal(".service.consul", cl.suffix)
+	assert.NotNil(cl.logger)
+
+	cl = NewConsulLookup(ConsulLookupOptions{Client: new(testConsulClient), Domain: "example.net"})
+	assert.Equal(".service.example.net", cl.suffix)
+}
+
+func TestConsulLookupParse(t *testing.T) {
+	var (
+		cl       = NewConsulLookup(ConsulLookupOptions{Client: new(testConsulClient)})
+		testData = []struct {
+			host            string
+			expectedService string
+			expectedTag     string
+			expectedOK      bool
+		}{
+			{"talaria.service.consul", "talaria", "", true},
+			{"talaria.service.consul.", "talaria", "", true},
+			{"Talaria.Service.Consul", "talaria", "", true},
+			{"east.talaria.service.consul", "talaria", "east", true},
+			{"talaria.example.com", "", "", false},
+			{"talaria.node.consul", "", "", false},
+			{".service.consul", "", "", false},
+		}
+	)
+
+	for _, record := range testData {
+		t.Logf("%#v", record)
+		service, tag, ok := cl.parse(record.host)
+		assert.Equal(t, record.expectedService, service)
+		assert.Equal(t, record.expectedTag, tag)
+		assert.Equal(t, record.expectedOK, ok)
+	}
+}
+
+func TestConsulLookupRoutes(t *testing.T) {
+	var (
+		assert             = assert.New(t)
+		require            = require.New(t)
+		cl, instancers     = newTestConsulLookup(t, ConsulLookupOptions{PassingOnly: true})
+		routes, lookupErr  = cl.LookupRoutes(context.Background(), "example.com")
+		expectedInstances  = []string{"10.0.0.1:8080", "10.0.0.2:8080", "malformed"}
+		expectedRoutes     = []Route{{Host: "10.0.0.1", Port: 8080}, {Host: "10.0.0.2", Port: 8080}}
+		updatedRoutes      = []Route{{Host: "10.0.0.3", Port: 8080}}
+		talariaHost        = "talaria.service.consul"
+		taggedTalariaHost  = "east.talaria.service.consul"
+		initialTalariaHost = talariaHost
+	)
+
+	// hostnames outside the consul domain are not handled
+	assert.Empty(routes)
+	assert.NoError(lookupErr)
+	assert.Empty(instancers)
+
+	routes, lookupErr = cl.LookupRoutes(context.Background(), initialTalariaHost)
+	assert.Empty(routes)
+	assert.NoError(lookupErr)
+	require.Contains(instancers, "talaria")
+
+	instancers["talaria"].dispatch(sd.Event{Instances: expectedInstances})
+	waitForRoutes(t, cl, talariaHost, expectedRoutes)
+
+	instancers["talaria"].dispatch(sd.Event{Instances: []string{"10.0.0.3:8080"}})
+	waitForRoutes(t, cl, talariaHost, updatedRoutes)
+
+	// errors are only reported when there are no routes
+	instancers["talaria"].dispatch(sd.Event{Err: errors.New("expected")})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, lookupErr = cl.LookupRoutes(context.Background(), talariaHost)
+		if lookupErr != nil || time.Now().After(deadline) {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Error(lookupErr)
+
+	// a tag results in a separate watch
+	cl.LookupRoutes(context.Background(), taggedTalariaHost)
+	require.Contains(instancers, "east.talaria")
+	assert.Len(instancers, 2)
+
+	cl.Stop()
+	cl.Stop()
+	assert.True(instancers["talaria"].stopped)
+	assert.True(instancers["east.talaria"].stopped)
+	assert.Empty(instancers["talaria"].events)
+
+	_, lookupErr = cl.LookupRoutes(context.Background(), talariaHost)
+	assert.Equal(ErrConsulLookupStopped, lookupErr)
+}
+
+func TestConsulLookupWithClient(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		client = &testConsulClient{
+			entries: []*api.ServiceEntry{
+				{Node: &api.Node{Address: "10.0.0.1"}, Service: &api.AgentService{Port: 8080}},
+				{Node: &api.Node{Address: "10.0.0.2"}, Service: &api.AgentService{Address: "10.0.0.3", Port: 9090}},
+			},
+			block: make(chan struct{}),
+		}
+
+		cl = NewConsulLookup(ConsulLookupOptions{Client: client, Logger: logging.NewTestLogger(nil, t)})
+	)
+
+	defer close(client.block)
+	defer cl.Stop()
+
+	routes, err := cl.LookupRoutes(context.Background(), "talaria.service.consul")
+	assert.Equal([]Route{{Host: "10.0.0.1", Port: 8080}, {Host: "10.0.0.3", Port: 9090}}, routes)
+	assert.NoError(err)
+}
+
+func TestConsulLookupConcurrentWatch(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		cl, _   = newTestConsulLookup(t, ConsulLookupOptions{})
+
+		winner = &consulEntry{
+			instancer: new(testInstancer),
+			routes:    []Route{{Host: "10.0.0.1", Port: 8080}},
+		}
+
+		loser = &testInstancer{state: sd.Event{Instances: []string{"10.0.0.2:8080"}}}
+	)
+
+	// another lookup caches an entry while this lookup is priming its watch, which happens outside the lock
+	cl.newInstancer = func(string, []string, bool) sd.Instancer {
+		cl.lock.Lock()
+		cl.entries[".talaria"] = winner
+		cl.lock.Unlock()
+		return loser
+	}
+
+	routes, err := cl.LookupRoutes(context.Background(), "talaria.service.consul")
+	require.NoError(err)
+	assert.Equal([]Route{{Host: "10.0.0.1", Port: 8080}}, routes)
+	assert.True(loser.stopped)
+	assert.Empty(loser.events)
+	assert.Len(cl.entries, 1)
+}
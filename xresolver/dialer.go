@@ -0,0 +1,205 @@
+package xresolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCooldown is the period for which a route that failed to connect is excluded from dialing
+	DefaultCooldown = 30 * time.Second
+)
+
+// DialerOptions configures a Dialer
+type DialerOptions struct {
+	// Dialer is the low-level dialer used to connect to each route.  If unset, a default net.Dialer is used.
+	Dialer *net.Dialer
+
+	// Lookups are consulted in order to resolve a hostname, and the first to return routes is used.
+	// If empty, DNS is used.
+	Lookups []Lookup
+
+	// Cooldown is how long a route that failed to connect is excluded.  If unset, DefaultCooldown is used.
+	Cooldown time.Duration
+
+	// Measures are the metrics emitted for each dial.  If unset, metrics are discarded.
+	Measures *Measures
+
+	// Now is the source of the current time.  If unset, time.Now is used.
+	Now func() time.Time
+}
+
+// Dialer connects to hostnames by resolving them into routes and dialing those routes in rotation.  Each
+// connection attempt starts with the route after the one used previously for that hostname.  A route that fails
+// to connect is excluded for a cooldown period, during which the remaining routes are tried first.  If every route
+// for a hostname is excluded, they are tried anyway rather than failing outright.
+type Dialer struct {
+	dial     func(context.Context, string, string) (net.Conn, error)
+	lookups  []Lookup
+	cooldown time.Duration
+	measures Measures
+	now      func() time.Time
+
+	lock     sync.Mutex
+	next     map[string]int
+	excluded map[string]time.Time
+}
+
+// NewDialer constructs a Dialer from a set of options
+func NewDialer(o DialerOptions) *Dialer {
+	d := &Dialer{
+		lookups:  o.Lookups,
+		cooldown: o.Cooldown,
+		now:      o.Now,
+		next:     make(map[string]int),
+		excluded: make(map[string]time.Time),
+	}
+
+	if o.Dialer != nil {
+		d.dial = o.Dialer.DialContext
+	} else {
+		d.dial = new(net.Dialer).DialContext
+	}
+
+	if len(d.lookups) == 0 {
+		d.lookups = []Lookup{DNSLookup(nil)}
+	}
+
+	if d.cooldown < 1 {
+		d.cooldown = DefaultCooldown
+	}
+
+	d.measures = measuresOrDiscard(o.Measures)
+	if d.now == nil {
+		d.now = time.Now
+	}
+
+	return d
+}
+
+// Dial connects to the given address using a background context
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the given address.  The signature of this method matches net.Dialer.DialContext,
+// which allows a Dialer to be used with http.Transport.  Addresses whose host is an IP literal are dialed directly.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if net.ParseIP(host) != nil {
+		return d.dial(ctx, network, address)
+	}
+
+	routes, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	healthy, excluded := d.order(host, port, routes)
+	for _, candidates := range [][]string{healthy, excluded} {
+		for _, candidate := range candidates {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			conn, dialErr := d.dial(ctx, network, candidate)
+			if dialErr != nil && ctx.Err() != nil {
+				// the caller gave up, which says nothing about the health of the route
+				return nil, dialErr
+			}
+
+			d.record(candidate, dialErr)
+			if dialErr == nil {
+				return conn, nil
+			}
+
+			err = dialErr
+		}
+
+		// only fall back to excluded routes if there were no healthy routes to try
+		if len(healthy) > 0 {
+			break
+		}
+	}
+
+	return nil, err
+}
+
+// lookup consults each Lookup in turn
+func (d *Dialer) lookup(ctx context.Context, host string) ([]Route, error) {
+	var lastErr error
+	for _, l := range d.lookups {
+		routes, err := l.LookupRoutes(ctx, host)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if len(routes) > 0 {
+			return routes, nil
+		}
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+
+	return nil, fmt.Errorf("No routes found for host %s", host)
+}
+
+// order produces the dialable addresses for a set of routes, rotated so that successive dials to the same
+// host start at successive routes.  Addresses that are within their cooldown are returned separately.
+func (d *Dialer) order(host, port string, routes []Route) (healthy, excluded []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	start := d.next[host] % len(routes)
+	d.next[host] = start + 1
+
+	now := d.now()
+	for i := 0; i < len(routes); i++ {
+		address := routes[(start+i)%len(routes)].Address(port)
+		if until, ok := d.excluded[address]; ok {
+			if now.Before(until) {
+				excluded = append(excluded, address)
+				d.measures.Dial.With(RouteLabel, address, OutcomeLabel, ExcludedOutcome).Add(1.0)
+				continue
+			}
+
+			delete(d.excluded, address)
+		}
+
+		healthy = append(healthy, address)
+	}
+
+	return
+}
+
+// record updates the exclusions and metrics for the outcome of a dial.  Exclusions whose cooldown has elapsed
+// are removed whenever a new one is added, so that routes which are never dialed again do not accumulate.
+func (d *Dialer) record(address string, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err != nil {
+		now := d.now()
+		for excluded, until := range d.excluded {
+			if !now.Before(until) {
+				delete(d.excluded, excluded)
+			}
+		}
+
+		d.excluded[address] = now.Add(d.cooldown)
+		d.measures.Dial.With(RouteLabel, address, OutcomeLabel, FailureOutcome).Add(1.0)
+	} else {
+		delete(d.excluded, address)
+		d.measures.Dial.With(RouteLabel, address, OutcomeLabel, SuccessOutcome).Add(1.0)
+	}
+}
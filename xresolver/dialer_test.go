@@ -0,0 +1,269 @@
+package xresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDial records the addresses dialed and fails for any address in the failing set
+type testDial struct {
+	lock    sync.Mutex
+	failing map[string]bool
+	dialed  []string
+}
+
+func (td *testDial) dial(_ context.Context, network, address string) (net.Conn, error) {
+	td.lock.Lock()
+	defer td.lock.Unlock()
+
+	td.dialed = append(td.dialed, address)
+	if td.failing[address] {
+		return nil, errors.New("connection refused")
+	}
+
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+func (td *testDial) reset() []string {
+	td.lock.Lock()
+	defer td.lock.Unlock()
+	dialed := td.dialed
+	td.dialed = nil
+	return dialed
+}
+
+func staticLookup(routes ...Route) Lookup {
+	return LookupFunc(func(context.Context, string) ([]Route, error) {
+		return routes, nil
+	})
+}
+
+func newTestDialer(t *testing.T, lookups ...Lookup) (*Dialer, *testDial, *time.Time, xmetricstest.Provider) {
+	var (
+		now      = time.Now()
+		provider = xmetricstest.NewProvider(nil, Metrics)
+		measures = NewMeasures(provider)
+		td       = &testDial{failing: make(map[string]bool)}
+		d        = NewDialer(DialerOptions{
+			Lookups:  lookups,
+			Cooldown: time.Minute,
+			Measures: &measures,
+			Now:      func() time.Time { return now },
+		})
+	)
+
+	d.dial = td.dial
+	return d, td, &now, provider
+}
+
+func TestNewDialerDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = NewDialer(DialerOptions{Dialer: new(net.Dialer)})
+	)
+
+	assert.NotNil(d.dial)
+	assert.Len(d.lookups, 1)
+	assert.Equal(DefaultCooldown, d.cooldown)
+	assert.NotNil(d.measures.Dial)
+	assert.NotNil(d.now)
+}
+
+func TestDialerRotation(t *testing.T) {
+	var (
+		assert          = assert.New(t)
+		require         = require.New(t)
+		d, td, _, _     = newTestDialer(t, staticLookup(Route{Host: "10.0.0.1"}, Route{Host: "10.0.0.2"}, Route{Host: "10.0.0.3", Port: 9090}))
+		expectedDialing = []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:9090", "10.0.0.1:8080"}
+	)
+
+	for _, expected := range expectedDialing {
+		conn, err := d.Dial("tcp", "example.com:8080")
+		require.NoError(err)
+		require.NotNil(conn)
+		conn.Close()
+
+		assert.Equal([]string{expected}, td.reset())
+	}
+}
+
+func TestDialerExclusion(t *testing.T) {
+	var (
+		assert             = assert.New(t)
+		require            = require.New(t)
+		d, td, now, metric = newTestDialer(t, staticLookup(Route{Host: "10.0.0.1"}, Route{Host: "10.0.0.2"}))
+	)
+
+	td.failing["10.0.0.1:8080"] = true
+
+	// the first route fails, so the second is tried
+	conn, err := d.Dial("tcp", "example.com:8080")
+	require.NoError(err)
+	conn.Close()
+	assert.Equal([]string{"10.0.0.1:8080", "10.0.0.2:8080"}, td.reset())
+
+	// the failed route is excluded, so it isn't tried even when the rotation starts with it
+	for i := 0; i < 3; i++ {
+		conn, err = d.Dial("tcp", "example.com:8080")
+		require.NoError(err)
+		conn.Close()
+		assert.Equal([]string{"10.0.0.2:8080"}, td.reset())
+	}
+
+	// once the cooldown elapses, the route is tried again
+	*now = now.Add(2 * time.Minute)
+	delete(td.failing, "10.0.0.1:8080")
+	conn, err = d.Dial("tcp", "example.com:8080")
+	require.NoError(err)
+	conn.Close()
+	assert.Equal([]string{"10.0.0.1:8080"}, td.reset())
+
+	metric.Expect(DialCounter, RouteLabel, "10.0.0.1:8080", OutcomeLabel, FailureOutcome)(xmetricstest.Value(1.0))
+	metric.Expect(DialCounter, RouteLabel, "10.0.0.1:8080", OutcomeLabel, ExcludedOutcome)(xmetricstest.Value(3.0))
+	metric.Expect(DialCounter, RouteLabel, "10.0.0.1:8080", OutcomeLabel, SuccessOutcome)(xmetricstest.Value(1.0))
+	metric.Expect(DialCounter, RouteLabel, "10.0.0.2:8080", OutcomeLabel, SuccessOutcome)(xmetricstest.Value(4.0))
+	metric.AssertExpectations(t)
+}
+
+func TestDialerCanceledDialNotExcluded(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		d, td, _, _ = newTestDialer(t, staticLookup(Route{Host: "10.0.0.1"}, Route{Host: "10.0.0.2"}))
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	// the dial fails because the caller canceled it, not because the route is unhealthy
+	d.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		td.dial(ctx, network, address)
+		cancel()
+		return nil, context.Canceled
+	}
+
+	conn, err := d.DialContext(ctx, "tcp", "example.com:8080")
+	assert.Nil(conn)
+	assert.Equal(context.Canceled, err)
+	assert.Equal([]string{"10.0.0.1:8080"}, td.reset())
+	assert.Empty(d.excluded)
+}
+
+func TestDialerExpiredExclusions(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		d, td, now, _ = newTestDialer(t, staticLookup(Route{Host: "10.0.0.1"}))
+	)
+
+	td.failing["10.0.0.1:8080"] = true
+	_, err := d.Dial("tcp", "example.com:8080")
+	assert.Error(err)
+	assert.Contains(d.excluded, "10.0.0.1:8080")
+
+	// another route fails after the first exclusion has expired, so the first exclusion is removed
+	*now = now.Add(2 * time.Minute)
+	d.lookups = []Lookup{staticLookup(Route{Host: "10.0.0.2"})}
+	td.failing["10.0.0.2:8080"] = true
+	_, err = d.Dial("tcp", "example.com:8080")
+	assert.Error(err)
+	assert.NotContains(d.excluded, "10.0.0.1:8080")
+	assert.Contains(d.excluded, "10.0.0.2:8080")
+}
+
+func TestDialerAllExcluded(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		d, td, _, _ = newTestDialer(t, staticLookup(Route{Host: "10.0.0.1"}, Route{Host: "10.0.0.2"}))
+	)
+
+	td.failing["10.0.0.1:8080"] = true
+	td.failing["10.0.0.2:8080"] = true
+
+	conn, err := d.Dial("tcp", "example.com:8080")
+	assert.Nil(conn)
+	assert.Error(err)
+	assert.Equal([]string{"10.0.0.1:8080", "10.0.0.2:8080"}, td.reset())
+
+	// every route is excluded, so they are all tried anyway
+	delete(td.failing, "10.0.0.1:8080")
+	conn, err = d.Dial("tcp", "example.com:8080")
+	assert.NoError(err)
+	conn.Close()
+	assert.Equal([]string{"10.0.0.2:8080", "10.0.0.1:8080"}, td.reset())
+}
+
+func TestDialerIPLiteral(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		d, td, _, _ = newTestDialer(t, LookupFunc(func(context.Context, string) ([]Route, error) {
+			assert.Fail("IP literals should not be looked up")
+			return nil, nil
+		}))
+	)
+
+	conn, err := d.Dial("tcp", "192.168.1.1:8080")
+	assert.NoError(err)
+	conn.Close()
+	assert.Equal([]string{"192.168.1.1:8080"}, td.reset())
+}
+
+func TestDialerLookupErrors(t *testing.T) {
+	t.Run("BadAddress", func(t *testing.T) {
+		d, _, _, _ := newTestDialer(t, staticLookup())
+		_, err := d.Dial("tcp", "no port")
+		assert.Error(t, err)
+	})
+
+	t.Run("NoRoutes", func(t *testing.T) {
+		d, _, _, _ := newTestDialer(t, staticLookup())
+		_, err := d.Dial("tcp", "example.com:8080")
+		assert.Error(t, err)
+	})
+
+	t.Run("LookupError", func(t *testing.T) {
+		var (
+			expectedError = errors.New("expected")
+			d, _, _, _    = newTestDialer(t, LookupFunc(func(context.Context, string) ([]Route, error) {
+				return nil, expectedError
+			}))
+		)
+
+		_, err := d.Dial("tcp", "example.com:8080")
+		assert.Equal(t, expectedError, err)
+	})
+
+	t.Run("Fallthrough", func(t *testing.T) {
+		var (
+			assert      = assert.New(t)
+			d, td, _, _ = newTestDialer(t,
+				LookupFunc(func(context.Context, string) ([]Route, error) { return nil, errors.New("expected") }),
+				staticLookup(),
+				staticLookup(Route{Host: "10.0.0.1"}),
+			)
+		)
+
+		conn, err := d.Dial("tcp", "example.com:8080")
+		assert.NoError(err)
+		conn.Close()
+		assert.Equal([]string{"10.0.0.1:8080"}, td.reset())
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		var (
+			d, td, _, _ = newTestDialer(t, staticLookup(Route{Host: "10.0.0.1"}))
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+
+		cancel()
+		_, err := d.DialContext(ctx, "tcp", "example.com:8080")
+		assert.Equal(t, context.Canceled, err)
+		assert.Empty(t, td.reset())
+	})
+}
@@ -0,0 +1,7 @@
+/*
+Package xresolver provides custom name resolution and dialing.  Hostnames are resolved through one or more
+Lookups, such as DNS or a service discovery catalog, and the resulting routes are dialed in rotation.  Routes
+that fail to connect are excluded for a cooldown period, so that a single bad record does not result in
+repeated connection timeouts.
+*/
+package xresolver
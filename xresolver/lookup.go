@@ -0,0 +1,66 @@
+package xresolver
+
+import (
+	"context"
+	"net"
+	"strconv"
+)
+
+// Route is a single destination for a hostname
+type Route struct {
+	// Host is the address of the destination, usually an IP address
+	Host string
+
+	// Port is the port of the destination.  If zero, the port from the dialed address is used.
+	Port int
+}
+
+// Address produces the dialable address for this route, using defaultPort if this route has no port
+func (r Route) Address(defaultPort string) string {
+	if r.Port > 0 {
+		return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+	}
+
+	return net.JoinHostPort(r.Host, defaultPort)
+}
+
+// Lookup is a source of routes for hostnames
+type Lookup interface {
+	// LookupRoutes returns the routes for a hostname.  A Lookup that does not handle a given hostname
+	// returns an empty slice and a nil error, so that other Lookups may be consulted.
+	LookupRoutes(ctx context.Context, host string) ([]Route, error)
+}
+
+// LookupFunc is a function type that implements Lookup
+type LookupFunc func(context.Context, string) ([]Route, error)
+
+func (lf LookupFunc) LookupRoutes(ctx context.Context, host string) ([]Route, error) {
+	return lf(ctx, host)
+}
+
+// IPResolver is the behavior required to resolve hostnames into IP addresses.  *net.Resolver implements this interface.
+type IPResolver interface {
+	LookupIPAddr(context.Context, string) ([]net.IPAddr, error)
+}
+
+// DNSLookup produces a Lookup that resolves A and AAAA records.  If r is nil, net.DefaultResolver is used.
+// The resulting routes have no port.
+func DNSLookup(r IPResolver) Lookup {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+
+	return LookupFunc(func(ctx context.Context, host string) ([]Route, error) {
+		addrs, err := r.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		routes := make([]Route, len(addrs))
+		for i, addr := range addrs {
+			routes[i] = Route{Host: addr.String()}
+		}
+
+		return routes, nil
+	})
+}
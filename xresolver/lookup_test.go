@@ -0,0 +1,57 @@
+package xresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testIPResolver func(context.Context, string) ([]net.IPAddr, error)
+
+func (r testIPResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return r(ctx, host)
+}
+
+func TestRouteAddress(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("10.0.0.1:8080", Route{Host: "10.0.0.1"}.Address("8080"))
+	assert.Equal("10.0.0.1:9090", Route{Host: "10.0.0.1", Port: 9090}.Address("8080"))
+	assert.Equal("[::1]:8080", Route{Host: "::1"}.Address("8080"))
+}
+
+func TestDNSLookup(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		assert.NotNil(t, DNSLookup(nil))
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			l      = DNSLookup(testIPResolver(func(_ context.Context, host string) ([]net.IPAddr, error) {
+				assert.Equal("example.com", host)
+				return []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("fe80::1")}}, nil
+			}))
+		)
+
+		routes, err := l.LookupRoutes(context.Background(), "example.com")
+		assert.Equal([]Route{{Host: "10.0.0.1"}, {Host: "fe80::1"}}, routes)
+		assert.NoError(err)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			expectedError = errors.New("expected")
+			l             = DNSLookup(testIPResolver(func(context.Context, string) ([]net.IPAddr, error) {
+				return nil, expectedError
+			}))
+		)
+
+		routes, err := l.LookupRoutes(context.Background(), "example.com")
+		assert.Empty(routes)
+		assert.Equal(expectedError, err)
+	})
+}
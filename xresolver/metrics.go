@@ -0,0 +1,79 @@
+package xresolver
+
+import (
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/go-kit/kit/metrics/provider"
+)
+
+const (
+	DialCounter  = "xresolver_dial_count"
+	CacheCounter = "xresolver_cache_count"
+
+	// RouteLabel is the label for the resolved address that was dialed
+	RouteLabel = "route"
+
+	// OutcomeLabel is the label for the result of a dial
+	OutcomeLabel = "outcome"
+
+	SuccessOutcome  = "success"
+	FailureOutcome  = "failure"
+	ExcludedOutcome = "excluded"
+
+	HitOutcome      = "hit"
+	MissOutcome     = "miss"
+	StaleOutcome    = "stale"
+	NegativeOutcome = "negative"
+)
+
+// Metrics is the module function for this package that adds the default xresolver metrics
+func Metrics() []xmetrics.Metric {
+	return []xmetrics.Metric{
+		xmetrics.Metric{
+			Name:       DialCounter,
+			Type:       "counter",
+			Help:       "The count of dial attempts, by resolved route and outcome",
+			LabelNames: []string{RouteLabel, OutcomeLabel},
+		},
+		xmetrics.Metric{
+			Name:       CacheCounter,
+			Type:       "counter",
+			Help:       "The count of cached lookups, by outcome",
+			LabelNames: []string{OutcomeLabel},
+		},
+	}
+}
+
+// Measures holds the metric objects used by this package
+type Measures struct {
+	Dial  metrics.Counter
+	Cache metrics.Counter
+}
+
+// measuresOrDiscard returns a copy of the given Measures with a discarding counter in place of any unset metric,
+// which includes a nil Measures
+func measuresOrDiscard(m *Measures) Measures {
+	var result Measures
+	if m != nil {
+		result = *m
+	}
+
+	if result.Dial == nil {
+		result.Dial = discard.NewCounter()
+	}
+
+	if result.Cache == nil {
+		result.Cache = discard.NewCounter()
+	}
+
+	return result
+}
+
+// NewMeasures constructs a Measures given a go-kit metrics Provider
+func NewMeasures(p provider.Provider) Measures {
+	return Measures{
+		Dial:  p.NewCounter(DialCounter),
+		Cache: p.NewCounter(CacheCounter),
+	}
+}
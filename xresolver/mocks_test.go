@@ -0,0 +1,67 @@
+package xresolver
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/hashicorp/consul/api"
+)
+
+// testInstancer is an sd.Instancer whose events are dispatched explicitly by tests
+type testInstancer struct {
+	lock    sync.Mutex
+	state   sd.Event
+	events  []chan<- sd.Event
+	stopped bool
+}
+
+func (ti *testInstancer) Register(ch chan<- sd.Event) {
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+	ti.events = append(ti.events, ch)
+	ch <- ti.state
+}
+
+func (ti *testInstancer) Deregister(ch chan<- sd.Event) {
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+	for i, c := range ti.events {
+		if c == ch {
+			ti.events = append(ti.events[:i], ti.events[i+1:]...)
+			return
+		}
+	}
+}
+
+func (ti *testInstancer) Stop() {
+	ti.lock.Lock()
+	ti.stopped = true
+	ti.lock.Unlock()
+}
+
+func (ti *testInstancer) dispatch(e sd.Event) {
+	ti.lock.Lock()
+	defer ti.lock.Unlock()
+	ti.state = e
+	for _, ch := range ti.events {
+		ch <- e
+	}
+}
+
+// testConsulClient answers the initial query immediately and blocks subsequent, watching queries until closed
+type testConsulClient struct {
+	entries []*api.ServiceEntry
+	block   chan struct{}
+}
+
+func (tc *testConsulClient) Register(*api.AgentServiceRegistration) error { return nil }
+
+func (tc *testConsulClient) Deregister(*api.AgentServiceRegistration) error { return nil }
+
+func (tc *testConsulClient) Service(service, tag string, passingOnly bool, q *api.QueryOptions) ([]*api.ServiceEntry, *api.QueryMeta, error) {
+	if q != nil && q.WaitIndex > 0 {
+		<-tc.block
+	}
+
+	return tc.entries, &api.QueryMeta{LastIndex: 1}, nil
+}
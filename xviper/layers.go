@@ -0,0 +1,184 @@
+package xviper
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/spf13/viper"
+)
+
+const (
+	// DefaultRemoteType is the format assumed for a remote configuration document when none is configured
+	DefaultRemoteType = "json"
+)
+
+// ErrNoConsulKV indicates that a ConsulSource was used without a consul KV client
+var ErrNoConsulKV = errors.New("A consul KV client is required")
+
+// RemoteSource supplies a configuration document from a remote system
+type RemoteSource interface {
+	// Read returns the remote configuration document.  A nil reader with a nil error means that no
+	// remote configuration exists, which is not an error.
+	Read() (io.Reader, error)
+}
+
+// RemoteSourceFunc is a function type that implements RemoteSource
+type RemoteSourceFunc func() (io.Reader, error)
+
+func (rsf RemoteSourceFunc) Read() (io.Reader, error) {
+	return rsf()
+}
+
+// ConsulKV is the subset of the consul KV API used to read remote configuration
+type ConsulKV interface {
+	Get(key string, q *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error)
+}
+
+// ConsulSource is a RemoteSource that reads a configuration document stored under a single consul KV key
+type ConsulSource struct {
+	// KV is the consul KV client, typically obtained from api.Client.KV().  This field is required.
+	KV ConsulKV
+
+	// Key is the consul key holding the configuration document
+	Key string
+
+	// QueryOptions are the optional options passed with the query, e.g. to select a datacenter
+	QueryOptions *api.QueryOptions
+}
+
+func (cs ConsulSource) Read() (io.Reader, error) {
+	if cs.KV == nil {
+		return nil, ErrNoConsulKV
+	}
+
+	pair, _, err := cs.KV.Get(cs.Key, cs.QueryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if pair == nil {
+		return nil, nil
+	}
+
+	return bytes.NewReader(pair.Value), nil
+}
+
+// LayerOptions describes the layers of configuration read by ReadInLayers
+type LayerOptions struct {
+	// Defaults are the lowest precedence configuration values, keyed by Viper key
+	Defaults map[string]interface{}
+
+	// EnvPrefix is the prefix of environment variables that override configuration.  If unset, any prefix
+	// already set on the Viper instance is left in place.
+	EnvPrefix string
+
+	// EnvKeyReplacer maps keys to environment variable names.  For example, strings.NewReplacer(".", "_") allows
+	// nested keys to be overridden.  If unset, any replacer already set on the Viper instance is left in place.
+	EnvKeyReplacer *strings.Replacer
+
+	// Remote is the optional source of remote configuration, such as a ConsulSource
+	Remote RemoteSource
+
+	// RemoteType is the format of the remote document, e.g. "json" or "yaml".  If unset, DefaultRemoteType is used.
+	RemoteType string
+
+	// OptionalFile indicates that a missing configuration file is not an error.  Any other problem reading
+	// a configuration file that does exist is always an error.
+	OptionalFile bool
+}
+
+func (o *LayerOptions) remoteType() string {
+	if o != nil && len(o.RemoteType) > 0 {
+		return o.RemoteType
+	}
+
+	return DefaultRemoteType
+}
+
+/*
+ReadInLayers reads configuration into a Viper instance from several layers.  In order of decreasing
+precedence, the layers are:
+
+ 1. environment variables
+ 2. the configuration file, as located by the Viper instance's config name, paths, or file
+ 3. the remote document, if a RemoteSource is configured
+ 4. the defaults
+
+Environment variables are mapped to keys by prefixing, uppercasing, and applying the EnvKeyReplacer, if any.
+For example, with a prefix of "talaria" and an EnvKeyReplacer of strings.NewReplacer(".", "_"), the key
+device.manager.maxDevices is overridden by the environment variable TALARIA_DEVICE_MANAGER_MAXDEVICES.  Only keys
+that appear in another layer can be overridden.
+
+Values set explicitly on the Viper instance, or via bound flags, take precedence over all of these layers.
+*/
+func ReadInLayers(v *viper.Viper, o LayerOptions) error {
+	for key, value := range o.Defaults {
+		v.SetDefault(key, value)
+	}
+
+	if len(o.EnvPrefix) > 0 {
+		v.SetEnvPrefix(o.EnvPrefix)
+	}
+
+	if o.EnvKeyReplacer != nil {
+		v.SetEnvKeyReplacer(o.EnvKeyReplacer)
+	}
+
+	v.AutomaticEnv()
+
+	remoteRead := false
+	if o.Remote != nil {
+		document, err := o.Remote.Read()
+		if err != nil {
+			return err
+		}
+
+		if document != nil {
+			v.SetConfigType(o.remoteType())
+			err = v.ReadConfig(document)
+
+			// clear the type so that the file's format is determined by its extension
+			v.SetConfigType("")
+			if err != nil {
+				return err
+			}
+
+			remoteRead = true
+		}
+	}
+
+	var err error
+	if remoteRead {
+		err = v.MergeInConfig()
+	} else {
+		err = v.ReadInConfig()
+	}
+
+	if _, notFound := err.(viper.ConfigFileNotFoundError); notFound && o.OptionalFile {
+		err = nil
+	}
+
+	return err
+}
+
+// Unmarshal is the single entry point for turning layered configuration into a value.  If key is empty,
+// the entire configuration is unmarshaled.  Otherwise, only the subtree at key is unmarshaled, and a missing
+// key leaves the value untouched.  A nil Viper instance also leaves the value untouched.
+func Unmarshal(v *viper.Viper, key string, value interface{}) error {
+	switch {
+	case v == nil:
+		return nil
+
+	case len(key) == 0:
+		return v.Unmarshal(value)
+
+	case !v.IsSet(key):
+		return nil
+
+	default:
+		return v.UnmarshalKey(key, value)
+	}
+}
@@ -0,0 +1,210 @@
+package xviper
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLayeredConfig struct {
+	Name    string
+	Port    int
+	Region  string
+	Timeout string
+	Nested  struct {
+		Value string
+	}
+}
+
+// newTestViper produces a Viper instance that searches a temporary directory.  If fileContents
+// is nonempty, a test.json file is written into that directory.
+func newTestViper(t *testing.T, fileContents string) (*viper.Viper, func()) {
+	directory, err := ioutil.TempDir("", "xviper")
+	require.NoError(t, err)
+
+	if len(fileContents) > 0 {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(directory, "test.json"), []byte(fileContents), 0644))
+	}
+
+	v := viper.New()
+	v.AddConfigPath(directory)
+	v.SetConfigName("test")
+	return v, func() { os.RemoveAll(directory) }
+}
+
+func TestConsulSource(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		kv            = new(mockConsulKV)
+		queryOptions  = &api.QueryOptions{Datacenter: "east"}
+		expectedError = errors.New("expected")
+	)
+
+	document, err := ConsulSource{}.Read()
+	assert.Nil(document)
+	assert.Equal(ErrNoConsulKV, err)
+
+	kv.On("Get", "config/test", queryOptions).Return(&api.KVPair{Value: []byte(`{"name": "remote"}`)}, nil).Once()
+	document, err = ConsulSource{KV: kv, Key: "config/test", QueryOptions: queryOptions}.Read()
+	require.NoError(err)
+	require.NotNil(document)
+
+	data, err := ioutil.ReadAll(document)
+	assert.Equal(`{"name": "remote"}`, string(data))
+	assert.NoError(err)
+
+	kv.On("Get", "config/missing", (*api.QueryOptions)(nil)).Return(nil, nil).Once()
+	document, err = ConsulSource{KV: kv, Key: "config/missing"}.Read()
+	assert.Nil(document)
+	assert.NoError(err)
+
+	kv.On("Get", "config/error", (*api.QueryOptions)(nil)).Return(nil, expectedError).Once()
+	document, err = ConsulSource{KV: kv, Key: "config/error"}.Read()
+	assert.Nil(document)
+	assert.Equal(expectedError, err)
+
+	kv.AssertExpectations(t)
+}
+
+func TestReadInLayersPrecedence(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		v, cleanup = newTestViper(t, `{"name": "file", "port": 8080, "nested": {"value": "file"}}`)
+		remote     = RemoteSourceFunc(func() (io.Reader, error) {
+			return strings.NewReader("name: remote\nregion: remote\ntimeout: 15s\nport: 1234\n"), nil
+		})
+	)
+
+	defer cleanup()
+	os.Setenv("XVIPERTEST_NESTED_VALUE", "environment")
+	defer os.Unsetenv("XVIPERTEST_NESTED_VALUE")
+
+	require.NoError(ReadInLayers(v, LayerOptions{
+		Defaults:       map[string]interface{}{"name": "default", "timeout": "1s", "region": "default"},
+		EnvPrefix:      "xviperTest",
+		EnvKeyReplacer: strings.NewReplacer(".", "_"),
+		Remote:         remote,
+		RemoteType:     "yaml",
+	}))
+
+	var config testLayeredConfig
+	require.NoError(Unmarshal(v, "", &config))
+	assert.Equal("file", config.Name)
+	assert.Equal(8080, config.Port)
+	assert.Equal("remote", config.Region)
+	assert.Equal("15s", config.Timeout)
+	assert.Equal("environment", config.Nested.Value)
+}
+
+func TestReadInLayersEnvKeyReplacer(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		v, cleanup = newTestViper(t, `{"nested": {"value": "file"}}`)
+	)
+
+	defer cleanup()
+	os.Setenv("XVIPERTEST_NESTED-VALUE", "environment")
+	defer os.Unsetenv("XVIPERTEST_NESTED-VALUE")
+
+	// a replacer set by the caller is left in place
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "-"))
+	require.NoError(ReadInLayers(v, LayerOptions{EnvPrefix: "xviperTest"}))
+	assert.Equal("environment", v.GetString("nested.value"))
+}
+
+func TestReadInLayersNoRemoteDocument(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		v, cleanup = newTestViper(t, `{"name": "file"}`)
+	)
+
+	defer cleanup()
+	require.NoError(ReadInLayers(v, LayerOptions{
+		Defaults: map[string]interface{}{"port": 8080},
+		Remote:   RemoteSourceFunc(func() (io.Reader, error) { return nil, nil }),
+	}))
+
+	var config testLayeredConfig
+	require.NoError(Unmarshal(v, "", &config))
+	assert.Equal("file", config.Name)
+	assert.Equal(8080, config.Port)
+}
+
+func TestReadInLayersMissingFile(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	v, cleanup := newTestViper(t, "")
+	defer cleanup()
+	assert.Error(ReadInLayers(v, LayerOptions{}))
+
+	v, cleanup = newTestViper(t, "")
+	defer cleanup()
+	require.NoError(ReadInLayers(v, LayerOptions{
+		Remote:       RemoteSourceFunc(func() (io.Reader, error) { return strings.NewReader(`{"name": "remote"}`), nil }),
+		OptionalFile: true,
+	}))
+
+	assert.Equal("remote", v.GetString("name"))
+}
+
+func TestReadInLayersRemoteErrors(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+	)
+
+	v, cleanup := newTestViper(t, `{"name": "file"}`)
+	defer cleanup()
+	assert.Equal(
+		expectedError,
+		ReadInLayers(v, LayerOptions{Remote: RemoteSourceFunc(func() (io.Reader, error) { return nil, expectedError })}),
+	)
+
+	v, cleanup = newTestViper(t, `{"name": "file"}`)
+	defer cleanup()
+	assert.Error(
+		ReadInLayers(v, LayerOptions{Remote: RemoteSourceFunc(func() (io.Reader, error) { return strings.NewReader("this is not JSON"), nil })}),
+	)
+}
+
+func TestUnmarshal(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		v       = viper.New()
+		config  testLayeredConfig
+	)
+
+	require.NoError(Unmarshal(nil, "", &config))
+	assert.Empty(config.Name)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(strings.NewReader(`{"server": {"name": "test", "port": 1234}}`)))
+
+	config.Name = "untouched"
+	require.NoError(Unmarshal(v, "missing", &config))
+	assert.Equal("untouched", config.Name)
+
+	require.NoError(Unmarshal(v, "server", &config))
+	assert.Equal("test", config.Name)
+	assert.Equal(1234, config.Port)
+}
@@ -1,6 +1,9 @@
 package xviper
 
-import "github.com/stretchr/testify/mock"
+import (
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/mock"
+)
 
 type mockConfiger struct {
 	mock.Mock
@@ -33,3 +36,13 @@ type mockKeyUnmarshaler struct {
 func (m *mockKeyUnmarshaler) UnmarshalKey(k string, v interface{}) error {
 	return m.Called(k, v).Error(0)
 }
+
+type mockConsulKV struct {
+	mock.Mock
+}
+
+func (m *mockConsulKV) Get(key string, q *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error) {
+	arguments := m.Called(key, q)
+	pair, _ := arguments.Get(0).(*api.KVPair)
+	return pair, nil, arguments.Error(1)
+}
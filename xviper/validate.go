@@ -0,0 +1,51 @@
+package xviper
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ValidationErrors aggregates every problem found while validating configuration, so that all of them
+// can be reported at once, e.g. by a -validate command line flag, rather than one per startup attempt.
+type ValidationErrors []error
+
+func (ve ValidationErrors) Error() string {
+	var output bytes.Buffer
+	output.WriteString("Invalid configuration: [")
+	for i, err := range ve {
+		if i > 0 {
+			output.WriteString(", ")
+		}
+
+		output.WriteString(err.Error())
+	}
+
+	output.WriteRune(']')
+	return output.String()
+}
+
+// Add appends an error to this set.  Nil errors are ignored, and nested ValidationErrors are flattened.
+func (ve *ValidationErrors) Add(err error) {
+	switch v := err.(type) {
+	case nil:
+	case ValidationErrors:
+		*ve = append(*ve, v...)
+	default:
+		*ve = append(*ve, err)
+	}
+}
+
+// Addf appends a formatted error to this set
+func (ve *ValidationErrors) Addf(format string, args ...interface{}) {
+	*ve = append(*ve, fmt.Errorf(format, args...))
+}
+
+// Err returns nil if this set is empty, or this set as an error otherwise.  Validation functions
+// should return this method's result so that callers can simply test against nil.
+func (ve ValidationErrors) Err() error {
+	if len(ve) == 0 {
+		return nil
+	}
+
+	return ve
+}
@@ -0,0 +1,30 @@
+package xviper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationErrors(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		ve     ValidationErrors
+	)
+
+	assert.NoError(ve.Err())
+
+	ve.Add(nil)
+	assert.Empty(ve)
+	assert.NoError(ve.Err())
+
+	ve.Add(errors.New("first"))
+	ve.Addf("second: %d", 2)
+	ve.Add(ValidationErrors{errors.New("third"), errors.New("fourth")})
+	assert.Len(ve, 4)
+
+	err := ve.Err()
+	assert.Error(err)
+	assert.Equal("Invalid configuration: [first, second: 2, third, fourth]", err.Error())
+}
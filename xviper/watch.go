@@ -0,0 +1,236 @@
+package xviper
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/kit/log"
+	"github.com/spf13/viper"
+)
+
+var (
+	// ErrInvalidSection indicates that a section was registered with something other than a non-nil pointer
+	ErrInvalidSection = errors.New("A configuration section must be registered with a non-nil pointer")
+
+	// ErrSectionExists indicates that a configuration key was registered more than once
+	ErrSectionExists = errors.New("That configuration section has already been registered")
+
+	// ErrNoSuchSection indicates that a listener was added for a configuration key that was never registered
+	ErrNoSuchSection = errors.New("No such configuration section")
+)
+
+// Change describes how a registered configuration section changed
+type Change struct {
+	// Key is the Viper key of the section
+	Key string
+
+	// Old and New are pointers to the previous and current values of the section, of the type registered
+	Old interface{}
+	New interface{}
+
+	// Fields are the names of the struct fields that changed.  This is nil if the section is not a struct.
+	Fields []string
+}
+
+// Changed tests if the given struct field is among the changed fields
+func (c Change) Changed(field string) bool {
+	for _, f := range c.Fields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Listener is notified when a configuration section changes.  Listeners are invoked synchronously,
+// in the order they were added, and should not block.
+type Listener func(Change)
+
+// WatcherOptions configures a Watcher
+type WatcherOptions struct {
+	// Logger is the go-kit logger to use.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger
+
+	// Layers are the layers originally used to read the configuration.  When set, the layers are reread
+	// on each change, which preserves any remote configuration that would otherwise be lost when Viper
+	// rereads the configuration file.
+	Layers *LayerOptions
+}
+
+// section is a single registered configuration section
+type section struct {
+	key       string
+	valueType reflect.Type
+	current   reflect.Value
+	listeners []Listener
+}
+
+// Watcher unmarshals registered configuration sections into typed values and notifies listeners whenever
+// a section changes.  This allows components such as the logging level, fanout options, or rate limits to
+// pick up new settings without a restart.
+type Watcher struct {
+	v        *viper.Viper
+	errorLog log.Logger
+	infoLog  log.Logger
+	layers   *LayerOptions
+
+	lock     sync.Mutex
+	sections []*section
+}
+
+// NewWatcher creates a Watcher for the given Viper instance, which should already have read its configuration
+func NewWatcher(v *viper.Viper, o WatcherOptions) *Watcher {
+	logger := o.Logger
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	return &Watcher{
+		v:        v,
+		errorLog: logging.Error(logger),
+		infoLog:  logging.Info(logger),
+		layers:   o.Layers,
+	}
+}
+
+// Register adds a configuration section.  The target must be a non-nil pointer, and it receives the current value
+// of the section immediately.  Subsequent changes are delivered to listeners as newly allocated values of the same type.
+func (w *Watcher) Register(key string, target interface{}, listeners ...Listener) error {
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.IsNil() {
+		return ErrInvalidSection
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, s := range w.sections {
+		if s.key == key {
+			return ErrSectionExists
+		}
+	}
+
+	// unmarshal separately into the target and the retained copy, so that changes made to the
+	// target by its owner don't affect change detection
+	if err := Unmarshal(w.v, key, target); err != nil {
+		return err
+	}
+
+	s := &section{
+		key:       key,
+		valueType: targetValue.Type().Elem(),
+		listeners: listeners,
+	}
+
+	s.current = reflect.New(s.valueType)
+	if err := Unmarshal(w.v, key, s.current.Interface()); err != nil {
+		return err
+	}
+
+	w.sections = append(w.sections, s)
+	return nil
+}
+
+// AddListener adds a listener to a section that was previously registered
+func (w *Watcher) AddListener(key string, l Listener) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, s := range w.sections {
+		if s.key == key {
+			s.listeners = append(s.listeners, l)
+			return nil
+		}
+	}
+
+	return ErrNoSuchSection
+}
+
+// Reload unmarshals each registered section and notifies the listeners of any that changed.
+// A section that fails to unmarshal retains its previous value.  The changes are returned.
+func (w *Watcher) Reload() []Change {
+	type notification struct {
+		change    Change
+		listeners []Listener
+	}
+
+	var notifications []notification
+
+	w.lock.Lock()
+	for _, s := range w.sections {
+		next := reflect.New(s.valueType)
+		if err := Unmarshal(w.v, s.key, next.Interface()); err != nil {
+			w.errorLog.Log(logging.MessageKey(), "unable to reload configuration section", "key", s.key, logging.ErrorKey(), err)
+			continue
+		}
+
+		if reflect.DeepEqual(s.current.Interface(), next.Interface()) {
+			continue
+		}
+
+		notifications = append(notifications, notification{
+			change: Change{
+				Key:    s.key,
+				Old:    s.current.Interface(),
+				New:    next.Interface(),
+				Fields: changedFields(s.current.Elem(), next.Elem()),
+			},
+			listeners: append([]Listener{}, s.listeners...),
+		})
+
+		s.current = next
+	}
+
+	w.lock.Unlock()
+
+	changes := make([]Change, 0, len(notifications))
+	for _, n := range notifications {
+		w.infoLog.Log(logging.MessageKey(), "configuration section changed", "key", n.change.Key, "fields", strings.Join(n.change.Fields, ","))
+		for _, l := range n.listeners {
+			l(n.change)
+		}
+
+		changes = append(changes, n.change)
+	}
+
+	return changes
+}
+
+// Watch starts watching the configuration file, reloading the registered sections whenever it changes
+func (w *Watcher) Watch() {
+	w.v.OnConfigChange(func(fsnotify.Event) {
+		if w.layers != nil {
+			if err := ReadInLayers(w.v, *w.layers); err != nil {
+				w.errorLog.Log(logging.MessageKey(), "unable to reread configuration layers", logging.ErrorKey(), err)
+				return
+			}
+		}
+
+		w.Reload()
+	})
+
+	w.v.WatchConfig()
+}
+
+// changedFields computes the names of the fields that differ between two values of the same struct type
+func changedFields(old, new reflect.Value) []string {
+	if old.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []string
+	for i := 0; i < old.NumField(); i++ {
+		if field := old.Type().Field(i); len(field.PkgPath) == 0 {
+			if !reflect.DeepEqual(old.Field(i).Interface(), new.Field(i).Interface()) {
+				fields = append(fields, field.Name)
+			}
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,161 @@
+package xviper
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLimits struct {
+	Rate  int
+	Burst int
+	Paths []string
+}
+
+func newTestWatcherViper(t *testing.T, document string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("json")
+	require.NoError(t, v.ReadConfig(strings.NewReader(document)))
+	return v
+}
+
+func TestChange(t *testing.T) {
+	assert := assert.New(t)
+	c := Change{Fields: []string{"Rate", "Paths"}}
+	assert.True(c.Changed("Rate"))
+	assert.True(c.Changed("Paths"))
+	assert.False(c.Changed("Burst"))
+}
+
+func TestWatcherRegister(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		v       = newTestWatcherViper(t, `{"limits": {"rate": 10, "burst": 20}, "level": "info"}`)
+		w       = NewWatcher(v, WatcherOptions{})
+
+		limits testLimits
+		level  string
+	)
+
+	require.NoError(w.Register("limits", &limits))
+	assert.Equal(testLimits{Rate: 10, Burst: 20}, limits)
+	require.NoError(w.Register("level", &level))
+	assert.Equal("info", level)
+
+	assert.Equal(ErrSectionExists, w.Register("limits", new(testLimits)))
+	assert.Equal(ErrInvalidSection, w.Register("other", testLimits{}))
+	assert.Equal(ErrInvalidSection, w.Register("other", (*testLimits)(nil)))
+
+	assert.NoError(w.AddListener("limits", func(Change) {}))
+	assert.Equal(ErrNoSuchSection, w.AddListener("nosuch", func(Change) {}))
+}
+
+func TestWatcherReload(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		v       = newTestWatcherViper(t, `{"limits": {"rate": 10, "burst": 20}, "log": {"level": "error"}}`)
+		w       = NewWatcher(v, WatcherOptions{Logger: logging.NewTestLogger(nil, t)})
+
+		limits      testLimits
+		limitsCalls []Change
+		logOptions  logging.Options
+		logChanges  []Change
+	)
+
+	require.NoError(w.Register("limits", &limits, func(c Change) { limitsCalls = append(limitsCalls, c) }))
+	require.NoError(w.Register("log", &logOptions))
+	require.NoError(w.AddListener("log", func(c Change) { logChanges = append(logChanges, c) }))
+
+	// changes made to the target do not affect change detection
+	limits.Rate = 999
+	assert.Empty(w.Reload())
+	assert.Empty(limitsCalls)
+
+	require.NoError(v.ReadConfig(strings.NewReader(`{"limits": {"rate": 15, "burst": 20, "paths": ["/api"]}, "log": {"level": "error"}}`)))
+	changes := w.Reload()
+	require.Len(changes, 1)
+	require.Len(limitsCalls, 1)
+	assert.Empty(logChanges)
+
+	assert.Equal("limits", limitsCalls[0].Key)
+	assert.Equal(&testLimits{Rate: 10, Burst: 20}, limitsCalls[0].Old)
+	assert.Equal(&testLimits{Rate: 15, Burst: 20, Paths: []string{"/api"}}, limitsCalls[0].New)
+	assert.Equal([]string{"Rate", "Paths"}, limitsCalls[0].Fields)
+
+	// the log level can be applied to a running logger
+	filter := logging.NewLevelFilter(logging.NewTestLogger(nil, t), logOptions.Level)
+	require.NoError(w.AddListener("log", func(c Change) {
+		if c.Changed("Level") {
+			filter.SetLevel(c.New.(*logging.Options).Level)
+		}
+	}))
+
+	require.NoError(v.ReadConfig(strings.NewReader(`{"limits": {"rate": 15, "burst": 20, "paths": ["/api"]}, "log": {"level": "debug"}}`)))
+	changes = w.Reload()
+	require.Len(changes, 1)
+	require.Len(logChanges, 1)
+	assert.Equal([]string{"Level"}, logChanges[0].Fields)
+	assert.Equal("debug", logChanges[0].New.(*logging.Options).Level)
+	assert.Len(limitsCalls, 1)
+
+	// sections that cannot be unmarshaled keep their previous values
+	require.NoError(v.ReadConfig(strings.NewReader(`{"limits": "this is not a struct", "log": {"level": "debug"}}`)))
+	assert.Empty(w.Reload())
+	assert.Len(limitsCalls, 1)
+}
+
+func TestWatcherNonStruct(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		v       = newTestWatcherViper(t, `{"level": "info"}`)
+		w       = NewWatcher(v, WatcherOptions{})
+		level   string
+	)
+
+	require.NoError(w.Register("level", &level))
+	v.Set("level", "debug")
+
+	changes := w.Reload()
+	require.Len(changes, 1)
+	assert.Nil(changes[0].Fields)
+	assert.Equal("debug", *changes[0].New.(*string))
+}
+
+func TestWatcherWatch(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		v, cleanup = newTestViper(t, `{"limits": {"rate": 10}}`)
+		changes    = make(chan Change, 10)
+		limits     testLimits
+	)
+
+	defer cleanup()
+	require.NoError(ReadInLayers(v, LayerOptions{}))
+
+	w := NewWatcher(v, WatcherOptions{Logger: logging.NewTestLogger(nil, t), Layers: &LayerOptions{}})
+	require.NoError(w.Register("limits", &limits, func(c Change) { changes <- c }))
+	w.Watch()
+
+	// give the watch goroutine time to start watching the directory
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(ioutil.WriteFile(filepath.Join(filepath.Dir(v.ConfigFileUsed()), "test.json"), []byte(`{"limits": {"rate": 20}}`), 0644))
+
+	select {
+	case c := <-changes:
+		assert.Equal(20, c.New.(*testLimits).Rate)
+	case <-time.After(5 * time.Second):
+		assert.Fail("No change was received")
+	}
+}